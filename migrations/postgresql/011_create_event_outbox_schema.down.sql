@@ -0,0 +1,9 @@
+-- Event Outbox Schema Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_event_outbox_unpublished;
+DROP INDEX IF EXISTS idx_event_outbox_aggregate_id;
+DROP INDEX IF EXISTS idx_event_outbox_event_type;
+
+-- Drop tables
+DROP TABLE IF EXISTS event_outbox;
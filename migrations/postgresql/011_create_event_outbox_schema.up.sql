@@ -0,0 +1,21 @@
+-- Event Outbox Schema
+-- Domain events are written to the outbox in the same transaction as the
+-- business change and relayed to the message broker asynchronously.
+-- This migration runs against every service database that publishes events.
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id VARCHAR(36) PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(36) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    locked_until TIMESTAMP,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_event_outbox_unpublished ON event_outbox(created_at) WHERE published_at IS NULL;
+CREATE INDEX idx_event_outbox_aggregate_id ON event_outbox(aggregate_id);
+CREATE INDEX idx_event_outbox_event_type ON event_outbox(event_type);
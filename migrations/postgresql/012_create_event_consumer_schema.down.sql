@@ -0,0 +1,12 @@
+-- Event Consumer Schema Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_processed_events_processed_at;
+DROP INDEX IF EXISTS idx_dead_letter_events_consumer;
+DROP INDEX IF EXISTS idx_dead_letter_events_event_type;
+DROP INDEX IF EXISTS idx_dead_letter_events_event_id;
+DROP INDEX IF EXISTS idx_dead_letter_events_created_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS dead_letter_events;
+DROP TABLE IF EXISTS processed_events;
@@ -0,0 +1,34 @@
+-- Event Consumer Schema
+-- Tracks which events each consumer has handled and parks events that
+-- could not be handled after retries so they can be inspected and replayed.
+
+-- Processed events table (deduplication by consumer and event ID)
+CREATE TABLE IF NOT EXISTS processed_events (
+    consumer VARCHAR(100) NOT NULL,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (consumer, event_id)
+);
+
+-- Dead-letter events table
+CREATE TABLE IF NOT EXISTS dead_letter_events (
+    id VARCHAR(36) PRIMARY KEY,
+    consumer VARCHAR(100) NOT NULL,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(36) NOT NULL,
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    replay_count INTEGER NOT NULL DEFAULT 0,
+    replayed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
+CREATE INDEX idx_dead_letter_events_consumer ON dead_letter_events(consumer);
+CREATE INDEX idx_dead_letter_events_event_type ON dead_letter_events(event_type);
+CREATE INDEX idx_dead_letter_events_event_id ON dead_letter_events(event_id);
+CREATE INDEX idx_dead_letter_events_created_at ON dead_letter_events(created_at);
@@ -0,0 +1,9 @@
+-- Checkout Saga Schema Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_checkout_sagas_user_id;
+DROP INDEX IF EXISTS idx_checkout_sagas_order_id;
+DROP INDEX IF EXISTS idx_checkout_sagas_status_updated_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS checkout_sagas;
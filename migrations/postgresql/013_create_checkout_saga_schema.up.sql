@@ -0,0 +1,27 @@
+-- Checkout Saga Schema
+-- Persists the progress of each checkout so failed or interrupted checkouts
+-- can be compensated (stock released, payment cancelled, order cancelled).
+
+-- Checkout sagas table
+CREATE TABLE IF NOT EXISTS checkout_sagas (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    session_id VARCHAR(255),
+    cart_id VARCHAR(36),
+    status VARCHAR(20) NOT NULL DEFAULT 'started' CHECK (status IN ('started', 'completed', 'compensating', 'compensated', 'failed')),
+    current_step VARCHAR(50),
+    completed_steps JSONB NOT NULL DEFAULT '[]',
+    order_id VARCHAR(36),
+    payment_id VARCHAR(36),
+    shipment_id VARCHAR(36),
+    error TEXT,
+    compensation_errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_checkout_sagas_user_id ON checkout_sagas(user_id);
+CREATE INDEX idx_checkout_sagas_order_id ON checkout_sagas(order_id);
+CREATE INDEX idx_checkout_sagas_status_updated_at ON checkout_sagas(status, updated_at);
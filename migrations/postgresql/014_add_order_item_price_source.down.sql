@@ -0,0 +1,3 @@
+-- Order Item Price Source Rollback
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS price_source;
@@ -0,0 +1,8 @@
+-- Order Item Price Source
+-- Records whether each order item was priced from the product or the variant
+-- catalog entry. Items created before catalog pricing was enforced took their
+-- price from the request and are marked 'client'.
+
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS price_source VARCHAR(20) NOT NULL DEFAULT 'client'
+    CHECK (price_source IN ('product', 'variant', 'client'));
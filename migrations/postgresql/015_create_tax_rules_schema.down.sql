@@ -0,0 +1,15 @@
+-- Tax Rules Schema Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_tax_rules_country;
+
+-- Drop columns
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS tax_breakdown,
+    DROP COLUMN IF EXISTS tax_amount,
+    DROP COLUMN IF EXISTS tax_class;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_included;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_class;
+
+-- Drop tables
+DROP TABLE IF EXISTS tax_rules;
@@ -0,0 +1,39 @@
+-- Tax Rules Schema
+-- Rules drive the order-service tax engine. A rule applies to orders shipped
+-- to its jurisdiction (country, state, postal code prefix; empty matches any)
+-- and to items of its tax class (empty matches any class).
+
+-- Tax rules table
+CREATE TABLE IF NOT EXISTS tax_rules (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    country VARCHAR(100) NOT NULL DEFAULT '',
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code_prefix VARCHAR(20) NOT NULL DEFAULT '',
+    tax_class VARCHAR(50) NOT NULL DEFAULT '',
+    rate DECIMAL(7,6) NOT NULL CHECK (rate >= 0 AND rate < 1),
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE, -- catalog prices already include this tax
+    apply_to_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Products carry the tax class rules are matched against
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class VARCHAR(50) NOT NULL DEFAULT 'standard';
+
+-- Orders record the part of the tax already contained in prices, and each
+-- item keeps the tax charged on it broken down by rule
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_included DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_included >= 0);
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS tax_class VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_breakdown JSONB NOT NULL DEFAULT '[]';
+
+-- Keep the previous flat 10% sales tax until jurisdiction rules are configured
+INSERT INTO tax_rules (id, name, rate) VALUES
+    ('00000000-0000-0000-0000-000000000010', 'Default sales tax', 0.10)
+ON CONFLICT (id) DO NOTHING;
+
+-- Create indexes for performance
+CREATE INDEX idx_tax_rules_country ON tax_rules(country) WHERE active;
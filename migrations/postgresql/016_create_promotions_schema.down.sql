@@ -0,0 +1,14 @@
+-- Promotions Schema Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_promotion_redemptions_order_id;
+DROP INDEX IF EXISTS idx_promotion_redemptions_promotion_user;
+DROP INDEX IF EXISTS idx_promotions_active;
+DROP INDEX IF EXISTS idx_promotions_code;
+
+-- Drop columns
+ALTER TABLE order_discounts DROP COLUMN IF EXISTS promotion_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
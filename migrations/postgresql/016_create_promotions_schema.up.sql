@@ -0,0 +1,45 @@
+-- Promotions Schema
+-- Coupons and automatic promotions applied when carts and orders are priced.
+-- Redemptions back the global and per-user usage limits; applied discounts
+-- are recorded in order_discounts.
+
+-- Promotions table
+CREATE TABLE IF NOT EXISTS promotions (
+    id VARCHAR(36) PRIMARY KEY,
+    code VARCHAR(100), -- null for automatic promotions
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('percentage', 'fixed_amount', 'free_shipping', 'buy_x_get_y')),
+    value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (value >= 0),
+    min_subtotal DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_subtotal >= 0),
+    buy_quantity INTEGER NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+    get_quantity INTEGER NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+    product_ids JSONB NOT NULL DEFAULT '[]', -- empty applies to every product
+    usage_limit INTEGER NOT NULL DEFAULT 0 CHECK (usage_limit >= 0), -- 0 is unlimited
+    per_user_limit INTEGER NOT NULL DEFAULT 0 CHECK (per_user_limit >= 0), -- 0 is unlimited
+    usage_count INTEGER NOT NULL DEFAULT 0 CHECK (usage_count >= 0),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Promotion redemptions table, one row per promotion used by an order
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id VARCHAR(36) PRIMARY KEY,
+    promotion_id VARCHAR(36) NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    order_id VARCHAR(36) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(promotion_id, order_id)
+);
+
+-- Link applied discounts to the promotion that granted them
+ALTER TABLE order_discounts ADD COLUMN IF NOT EXISTS promotion_id VARCHAR(36) REFERENCES promotions(id) ON DELETE SET NULL;
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX idx_promotions_code ON promotions(UPPER(code)) WHERE code IS NOT NULL;
+CREATE INDEX idx_promotions_active ON promotions(active) WHERE code IS NULL;
+CREATE INDEX idx_promotion_redemptions_promotion_user ON promotion_redemptions(promotion_id, user_id);
+CREATE INDEX idx_promotion_redemptions_order_id ON promotion_redemptions(order_id);
@@ -0,0 +1,11 @@
+-- Partially Shipped Order Status Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_order_fulfillment_items_fulfillment_item;
+
+-- Restore the original status constraint
+UPDATE orders SET status = 'processing' WHERE status = 'partially_shipped';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+    'pending', 'confirmed', 'processing', 'shipped', 'delivered', 'cancelled', 'refunded'
+));
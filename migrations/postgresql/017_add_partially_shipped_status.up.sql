@@ -0,0 +1,12 @@
+-- Partially Shipped Order Status
+-- Orders split across several fulfillments are partially shipped once some,
+-- but not all, of their items have shipped.
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+    'pending', 'confirmed', 'processing', 'partially_shipped', 'shipped', 'delivered', 'cancelled', 'refunded'
+));
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_fulfillment_items_fulfillment_item
+    ON order_fulfillment_items(fulfillment_id, order_item_id);
@@ -0,0 +1,15 @@
+-- Order Returns Schema Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_order_returns_updated_at ON order_returns;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_order_return_items_order_item_id;
+DROP INDEX IF EXISTS idx_order_return_items_return_id;
+DROP INDEX IF EXISTS idx_order_returns_status;
+DROP INDEX IF EXISTS idx_order_returns_user_id;
+DROP INDEX IF EXISTS idx_order_returns_order_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS order_return_items;
+DROP TABLE IF EXISTS order_returns;
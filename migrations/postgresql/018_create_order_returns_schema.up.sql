@@ -0,0 +1,55 @@
+-- Order Returns Schema
+-- Return merchandise authorizations (RMAs) for delivered order items. A
+-- return is requested by the customer, approved with a return shipping
+-- label, received and inspected, and finally refunded.
+
+-- Order returns table
+CREATE TABLE IF NOT EXISTS order_returns (
+    id VARCHAR(36) PRIMARY KEY,
+    rma_number VARCHAR(100) NOT NULL UNIQUE,
+    order_id VARCHAR(36) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected', 'received', 'inspected', 'refunded', 'cancelled')),
+    reason TEXT NOT NULL,
+    notes TEXT,
+    rejection_reason TEXT,
+    shipment_id VARCHAR(36),
+    tracking_number VARCHAR(100),
+    label_url TEXT,
+    refund_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (refund_amount >= 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    refund_id VARCHAR(36),
+    approved_at TIMESTAMP,
+    received_at TIMESTAMP,
+    inspected_at TIMESTAMP,
+    refunded_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Order return items table
+CREATE TABLE IF NOT EXISTS order_return_items (
+    id VARCHAR(36) PRIMARY KEY,
+    return_id VARCHAR(36) NOT NULL REFERENCES order_returns(id) ON DELETE CASCADE,
+    order_item_id VARCHAR(36) NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    product_id VARCHAR(36) NOT NULL,
+    variant_id VARCHAR(36),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reason TEXT,
+    accepted_quantity INTEGER NOT NULL DEFAULT 0 CHECK (accepted_quantity >= 0 AND accepted_quantity <= quantity),
+    restocked_quantity INTEGER NOT NULL DEFAULT 0 CHECK (restocked_quantity >= 0 AND restocked_quantity <= accepted_quantity),
+    condition VARCHAR(50),
+    refund_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (refund_amount >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_order_returns_order_id ON order_returns(order_id);
+CREATE INDEX idx_order_returns_user_id ON order_returns(user_id);
+CREATE INDEX idx_order_returns_status ON order_returns(status);
+CREATE INDEX idx_order_return_items_return_id ON order_return_items(return_id);
+CREATE INDEX idx_order_return_items_order_item_id ON order_return_items(order_item_id);
+
+-- Create triggers for updated_at timestamps
+CREATE TRIGGER update_order_returns_updated_at BEFORE UPDATE ON order_returns
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,24 @@
+-- Payment Authorization and Capture Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_payment_captures_updated_at ON payment_captures;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_payments_authorization_expires_at;
+DROP INDEX IF EXISTS idx_payment_captures_shipment;
+DROP INDEX IF EXISTS idx_payment_captures_payment_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS payment_captures;
+
+-- Restore the original status constraint
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_captured_amount;
+UPDATE payments SET status = 'completed' WHERE status IN ('authorized', 'partially_captured');
+UPDATE payments SET status = 'cancelled' WHERE status = 'voided';
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'cancelled', 'refunded'));
+
+-- Drop columns
+ALTER TABLE payments DROP COLUMN IF EXISTS authorization_expires_at;
+ALTER TABLE payments DROP COLUMN IF EXISTS captured_amount;
+ALTER TABLE payments DROP COLUMN IF EXISTS authorized_amount;
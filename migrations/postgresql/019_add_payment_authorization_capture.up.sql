@@ -0,0 +1,42 @@
+-- Payment Authorization and Capture
+-- Orders are authorized at checkout and captured shipment by shipment, so a
+-- payment tracks how much is held and how much has been collected.
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS authorized_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS captured_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS authorization_expires_at TIMESTAMP;
+
+-- Payments completed before this migration were captured in full
+UPDATE payments SET authorized_amount = amount, captured_amount = amount
+WHERE status IN ('completed', 'refunded');
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'authorized', 'partially_captured', 'completed', 'failed', 'cancelled', 'voided', 'refunded'
+));
+ALTER TABLE payments ADD CONSTRAINT chk_payments_captured_amount CHECK (captured_amount <= authorized_amount);
+
+-- Create payment_captures table
+CREATE TABLE IF NOT EXISTS payment_captures (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    shipment_id VARCHAR(255),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount >= 0),
+    final BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    transaction_id VARCHAR(255),
+    failure_reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_payment_captures_payment_id ON payment_captures(payment_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_captures_shipment
+    ON payment_captures(payment_id, shipment_id) WHERE shipment_id IS NOT NULL AND status <> 'failed';
+CREATE INDEX IF NOT EXISTS idx_payments_authorization_expires_at
+    ON payments(authorization_expires_at) WHERE status IN ('authorized', 'partially_captured');
+
+-- Create triggers for updated_at
+CREATE TRIGGER update_payment_captures_updated_at BEFORE UPDATE ON payment_captures
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,16 @@
+-- Payment Refund Accounting Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_refunds_transaction_id;
+
+-- Restore the previous status constraint
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_refunded_amount;
+UPDATE payments SET status = 'completed' WHERE status = 'partially_refunded';
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'authorized', 'partially_captured', 'completed', 'failed', 'cancelled', 'voided', 'refunded'
+));
+
+-- Drop columns
+ALTER TABLE refunds DROP COLUMN IF EXISTS failure_reason;
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_amount;
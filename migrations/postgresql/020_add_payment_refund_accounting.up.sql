@@ -0,0 +1,24 @@
+-- Payment Refund Accounting
+-- Refunds are tracked against the captured amount so that partial refunds
+-- across several requests can never add up to more than was collected.
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+
+-- Carry over refunds made before this migration
+UPDATE payments p SET refunded_amount = LEAST(p.captured_amount, (
+    SELECT COALESCE(SUM(r.amount), 0) FROM refunds r WHERE r.payment_id = p.id AND r.status = 'completed'
+));
+UPDATE payments SET refunded_amount = captured_amount WHERE status = 'refunded' AND refunded_amount = 0;
+UPDATE payments SET status = 'partially_refunded'
+WHERE status = 'completed' AND refunded_amount > 0 AND refunded_amount < captured_amount;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'authorized', 'partially_captured', 'completed', 'failed', 'cancelled', 'voided',
+    'partially_refunded', 'refunded'
+));
+ALTER TABLE payments ADD CONSTRAINT chk_payments_refunded_amount CHECK (refunded_amount <= captured_amount);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_refunds_transaction_id ON refunds(transaction_id);
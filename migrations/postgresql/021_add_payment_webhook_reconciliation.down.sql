@@ -0,0 +1,21 @@
+-- Payment Webhook Reconciliation Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_payment_webhooks_unprocessed;
+DROP INDEX IF EXISTS idx_payment_webhooks_event_id;
+
+-- Restore the previous status constraint
+UPDATE payments SET status = 'processing' WHERE status = 'requires_action';
+UPDATE payments SET status = 'completed' WHERE status = 'disputed';
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'authorized', 'partially_captured', 'completed', 'failed', 'cancelled', 'voided',
+    'partially_refunded', 'refunded'
+));
+
+-- Drop columns
+ALTER TABLE payments DROP COLUMN IF EXISTS gateway_updated_at;
+ALTER TABLE payment_webhooks DROP COLUMN IF EXISTS last_error;
+ALTER TABLE payment_webhooks DROP COLUMN IF EXISTS attempts;
+ALTER TABLE payment_webhooks DROP COLUMN IF EXISTS event_created_at;
+ALTER TABLE payment_webhooks DROP COLUMN IF EXISTS event_id;
@@ -0,0 +1,27 @@
+-- Payment Webhook Reconciliation
+-- Gateway webhooks update payments and refunds. Events are recorded once per
+-- gateway event ID, and rows that failed to apply are kept for replay.
+
+ALTER TABLE payment_webhooks ADD COLUMN IF NOT EXISTS event_id VARCHAR(255);
+ALTER TABLE payment_webhooks ADD COLUMN IF NOT EXISTS event_created_at TIMESTAMP;
+ALTER TABLE payment_webhooks ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payment_webhooks ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+-- Webhooks stored before this migration have no gateway event ID
+UPDATE payment_webhooks SET event_id = id::text WHERE event_id IS NULL;
+UPDATE payment_webhooks SET event_created_at = created_at WHERE event_created_at IS NULL;
+ALTER TABLE payment_webhooks ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE payment_webhooks ALTER COLUMN event_created_at SET NOT NULL;
+
+-- Time of the latest gateway event applied, so late events cannot undo it
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_updated_at TIMESTAMP;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'requires_action', 'authorized', 'partially_captured', 'completed', 'failed',
+    'cancelled', 'voided', 'partially_refunded', 'refunded', 'disputed'
+));
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_webhooks_event_id ON payment_webhooks(event_id);
+CREATE INDEX IF NOT EXISTS idx_payment_webhooks_unprocessed ON payment_webhooks(event_created_at) WHERE processed = false;
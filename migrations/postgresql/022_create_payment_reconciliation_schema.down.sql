@@ -0,0 +1,16 @@
+-- Payment Settlement Reconciliation Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_settlement_discrepancies_updated_at ON settlement_discrepancies;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_refunds_processed_at;
+DROP INDEX IF EXISTS idx_payments_processed_at;
+DROP INDEX IF EXISTS idx_settlement_discrepancies_transaction_id;
+DROP INDEX IF EXISTS idx_settlement_discrepancies_status;
+DROP INDEX IF EXISTS idx_settlement_discrepancies_run_id;
+DROP INDEX IF EXISTS idx_settlement_reconciliation_runs_created_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS settlement_discrepancies;
+DROP TABLE IF EXISTS settlement_reconciliation_runs;
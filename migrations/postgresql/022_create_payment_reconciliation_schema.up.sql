@@ -0,0 +1,51 @@
+-- Payment Settlement Reconciliation
+-- Gateway settlement reports are checked against payments and refunds; every
+-- difference is kept as a discrepancy until an admin resolves it.
+
+-- Create settlement_reconciliation_runs table
+CREATE TABLE IF NOT EXISTS settlement_reconciliation_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL UNIQUE,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    matched_count INTEGER NOT NULL DEFAULT 0,
+    discrepancy_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create settlement_discrepancies table
+CREATE TABLE IF NOT EXISTS settlement_discrepancies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id UUID NOT NULL REFERENCES settlement_reconciliation_runs(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL CHECK (type IN ('missing_record', 'missing_settlement', 'amount_mismatch', 'status_mismatch')),
+    record_type VARCHAR(20) NOT NULL CHECK (record_type IN ('charge', 'refund')),
+    transaction_id VARCHAR(255) NOT NULL,
+    payment_id UUID REFERENCES payments(id),
+    refund_id UUID REFERENCES refunds(id),
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    ledger_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    settled_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ledger_status VARCHAR(50) NOT NULL DEFAULT '',
+    settlement_status VARCHAR(50) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by VARCHAR(255) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_settlement_reconciliation_runs_created_at ON settlement_reconciliation_runs(created_at);
+CREATE INDEX IF NOT EXISTS idx_settlement_discrepancies_run_id ON settlement_discrepancies(run_id);
+CREATE INDEX IF NOT EXISTS idx_settlement_discrepancies_status ON settlement_discrepancies(status);
+CREATE INDEX IF NOT EXISTS idx_settlement_discrepancies_transaction_id ON settlement_discrepancies(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_payments_processed_at ON payments(processed_at) WHERE captured_amount > 0;
+CREATE INDEX IF NOT EXISTS idx_refunds_processed_at ON refunds(processed_at) WHERE status = 'completed';
+
+-- Create triggers for updated_at
+CREATE TRIGGER update_settlement_discrepancies_updated_at BEFORE UPDATE ON settlement_discrepancies
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
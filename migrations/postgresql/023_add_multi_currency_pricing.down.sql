@@ -0,0 +1,49 @@
+-- Multi-Currency Pricing Rollback
+-- Amounts in three-decimal currencies are rounded to two decimals.
+
+-- Restore payment amounts
+ALTER TABLE settlement_discrepancies
+    ALTER COLUMN ledger_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN settled_amount TYPE DECIMAL(15,2);
+ALTER TABLE payment_captures ALTER COLUMN amount TYPE DECIMAL(15,2);
+ALTER TABLE refunds ALTER COLUMN amount TYPE DECIMAL(15,2);
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE DECIMAL(15,2),
+    ALTER COLUMN authorized_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN captured_amount TYPE DECIMAL(15,2),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(15,2);
+
+-- Restore order amounts
+ALTER TABLE order_return_items ALTER COLUMN refund_amount TYPE DECIMAL(10,2);
+ALTER TABLE order_returns ALTER COLUMN refund_amount TYPE DECIMAL(10,2);
+ALTER TABLE order_discounts ALTER COLUMN discount_amount TYPE DECIMAL(10,2);
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE DECIMAL(10,2),
+    ALTER COLUMN total TYPE DECIMAL(10,2),
+    ALTER COLUMN tax_amount TYPE DECIMAL(10,2);
+ALTER TABLE orders
+    ALTER COLUMN subtotal TYPE DECIMAL(10,2),
+    ALTER COLUMN tax TYPE DECIMAL(10,2),
+    ALTER COLUMN tax_included TYPE DECIMAL(10,2),
+    ALTER COLUMN shipping TYPE DECIMAL(10,2),
+    ALTER COLUMN discount TYPE DECIMAL(10,2),
+    ALTER COLUMN total TYPE DECIMAL(10,2);
+
+-- Restore catalog, cart and promotion amounts
+ALTER TABLE promotions
+    ALTER COLUMN value TYPE DECIMAL(10,2),
+    ALTER COLUMN min_subtotal TYPE DECIMAL(10,2);
+ALTER TABLE shopping_cart_items ALTER COLUMN price TYPE DECIMAL(10,2);
+ALTER TABLE product_variants
+    ALTER COLUMN price TYPE DECIMAL(10,2),
+    ALTER COLUMN compare_price TYPE DECIMAL(10,2),
+    ALTER COLUMN cost_price TYPE DECIMAL(10,2);
+ALTER TABLE products
+    ALTER COLUMN price TYPE DECIMAL(10,2),
+    ALTER COLUMN compare_price TYPE DECIMAL(10,2),
+    ALTER COLUMN cost_price TYPE DECIMAL(10,2);
+
+-- Drop columns
+ALTER TABLE orders DROP COLUMN IF EXISTS exchange_rates;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS prices;
+ALTER TABLE products DROP COLUMN IF EXISTS prices;
@@ -0,0 +1,53 @@
+-- Multi-Currency Pricing
+-- Products and variants carry explicit prices in other currencies, orders
+-- snapshot the exchange rates they were priced with, and money columns gain
+-- a third decimal for three-decimal currencies such as KWD and BHD.
+
+-- Per-currency price lists, keyed by ISO 4217 code
+ALTER TABLE products ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}';
+
+-- Conversions used to price an order in its currency
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rates JSONB NOT NULL DEFAULT '[]';
+
+-- Catalog, cart and promotion amounts
+ALTER TABLE products
+    ALTER COLUMN price TYPE DECIMAL(15,3),
+    ALTER COLUMN compare_price TYPE DECIMAL(15,3),
+    ALTER COLUMN cost_price TYPE DECIMAL(15,3);
+ALTER TABLE product_variants
+    ALTER COLUMN price TYPE DECIMAL(15,3),
+    ALTER COLUMN compare_price TYPE DECIMAL(15,3),
+    ALTER COLUMN cost_price TYPE DECIMAL(15,3);
+ALTER TABLE shopping_cart_items ALTER COLUMN price TYPE DECIMAL(15,3);
+ALTER TABLE promotions
+    ALTER COLUMN value TYPE DECIMAL(15,3),
+    ALTER COLUMN min_subtotal TYPE DECIMAL(15,3);
+
+-- Order amounts
+ALTER TABLE orders
+    ALTER COLUMN subtotal TYPE DECIMAL(15,3),
+    ALTER COLUMN tax TYPE DECIMAL(15,3),
+    ALTER COLUMN tax_included TYPE DECIMAL(15,3),
+    ALTER COLUMN shipping TYPE DECIMAL(15,3),
+    ALTER COLUMN discount TYPE DECIMAL(15,3),
+    ALTER COLUMN total TYPE DECIMAL(15,3);
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE DECIMAL(15,3),
+    ALTER COLUMN total TYPE DECIMAL(15,3),
+    ALTER COLUMN tax_amount TYPE DECIMAL(15,3);
+ALTER TABLE order_discounts ALTER COLUMN discount_amount TYPE DECIMAL(15,3);
+ALTER TABLE order_returns ALTER COLUMN refund_amount TYPE DECIMAL(15,3);
+ALTER TABLE order_return_items ALTER COLUMN refund_amount TYPE DECIMAL(15,3);
+
+-- Payment amounts
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE DECIMAL(18,3),
+    ALTER COLUMN authorized_amount TYPE DECIMAL(18,3),
+    ALTER COLUMN captured_amount TYPE DECIMAL(18,3),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(18,3);
+ALTER TABLE refunds ALTER COLUMN amount TYPE DECIMAL(18,3);
+ALTER TABLE payment_captures ALTER COLUMN amount TYPE DECIMAL(18,3);
+ALTER TABLE settlement_discrepancies
+    ALTER COLUMN ledger_amount TYPE DECIMAL(18,3),
+    ALTER COLUMN settled_amount TYPE DECIMAL(18,3);
@@ -0,0 +1,7 @@
+-- Idempotency Keys Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_idempotency_keys_expires_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,28 @@
+-- Payment Risk Assessments Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_risk_assessments_updated_at ON risk_assessments;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_payments_ip_address_created_at;
+DROP INDEX IF EXISTS idx_payments_payment_method_id_created_at;
+DROP INDEX IF EXISTS idx_payments_user_id_created_at;
+DROP INDEX IF EXISTS idx_risk_assessments_user_id;
+DROP INDEX IF EXISTS idx_risk_assessments_decision;
+DROP INDEX IF EXISTS idx_risk_assessments_review_queue;
+DROP INDEX IF EXISTS idx_risk_assessments_payment_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS risk_assessments;
+
+-- Restore the previous status constraint
+UPDATE payments SET status = 'pending' WHERE status = 'under_review';
+UPDATE payments SET status = 'failed' WHERE status = 'blocked';
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'requires_action', 'authorized', 'partially_captured', 'completed', 'failed',
+    'cancelled', 'voided', 'partially_refunded', 'refunded', 'disputed'
+));
+
+-- Drop columns
+ALTER TABLE payments DROP COLUMN IF EXISTS risk_context;
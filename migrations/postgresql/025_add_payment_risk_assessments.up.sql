@@ -0,0 +1,46 @@
+-- Payment Risk Assessments
+-- Payments are scored by the risk engine before they reach the gateway. Every
+-- assessment is kept with the rules it triggered; payments that need a closer
+-- look wait under review until an admin approves or rejects them.
+
+-- Signals collected at checkout for the risk rules
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS risk_context JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status CHECK (status IN (
+    'pending', 'processing', 'requires_action', 'under_review', 'authorized', 'partially_captured', 'completed',
+    'failed', 'blocked', 'cancelled', 'voided', 'partially_refunded', 'refunded', 'disputed'
+));
+
+-- Create risk_assessments table
+CREATE TABLE IF NOT EXISTS risk_assessments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    order_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    amount DECIMAL(18,3) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    score INTEGER NOT NULL DEFAULT 0 CHECK (score BETWEEN 0 AND 100),
+    decision VARCHAR(20) NOT NULL CHECK (decision IN ('allow', 'review', 'block')),
+    rules JSONB NOT NULL DEFAULT '[]',
+    review_status VARCHAR(20) NOT NULL DEFAULT 'not_required'
+        CHECK (review_status IN ('not_required', 'pending', 'approved', 'rejected')),
+    reviewed_by VARCHAR(255) NOT NULL DEFAULT '',
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_payment_id ON risk_assessments(payment_id);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_review_queue ON risk_assessments(created_at) WHERE review_status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_decision ON risk_assessments(decision, created_at);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_user_id ON risk_assessments(user_id);
+CREATE INDEX IF NOT EXISTS idx_payments_user_id_created_at ON payments(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_payment_method_id_created_at ON payments(payment_method_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_ip_address_created_at ON payments((risk_context->>'ip_address'), created_at);
+
+-- Create triggers for updated_at
+CREATE TRIGGER update_risk_assessments_updated_at BEFORE UPDATE ON risk_assessments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
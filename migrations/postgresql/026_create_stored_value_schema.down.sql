@@ -0,0 +1,25 @@
+-- Stored Value Schema Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS prevent_stored_value_ledger_changes ON stored_value_ledger;
+DROP TRIGGER IF EXISTS update_wallets_updated_at ON wallets;
+DROP TRIGGER IF EXISTS update_gift_cards_updated_at ON gift_cards;
+DROP FUNCTION IF EXISTS prevent_stored_value_ledger_changes();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_stored_value_ledger_refund_id;
+DROP INDEX IF EXISTS idx_stored_value_ledger_payment_entry;
+DROP INDEX IF EXISTS idx_stored_value_ledger_payment_id;
+DROP INDEX IF EXISTS idx_stored_value_ledger_account;
+
+-- Restore the previous payment type constraint
+UPDATE payments SET type = 'card' WHERE type IN ('gift_card', 'wallet');
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_type;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_type CHECK (type IN ('card', 'paypal', 'apple_pay', 'google_pay', 'bank_transfer'));
+
+ALTER TABLE checkout_sagas DROP COLUMN IF EXISTS stored_value_payment_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS stored_value_ledger;
+DROP TABLE IF EXISTS wallets;
+DROP TABLE IF EXISTS gift_cards;
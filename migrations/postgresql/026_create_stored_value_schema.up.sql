@@ -0,0 +1,82 @@
+-- Stored Value Schema
+-- Gift cards and customer wallets (store credit) pay for orders alongside or
+-- instead of the payment gateway. Every balance change is appended to the
+-- ledger; balances are only ever moved together with a ledger entry.
+
+-- Create gift_cards table
+CREATE TABLE IF NOT EXISTS gift_cards (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code_hash VARCHAR(64) NOT NULL UNIQUE,
+    code_last4 VARCHAR(4) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    initial_balance DECIMAL(18,3) NOT NULL CHECK (initial_balance > 0),
+    balance DECIMAL(18,3) NOT NULL CHECK (balance >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'disabled')),
+    issued_by VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create wallets table
+CREATE TABLE IF NOT EXISTS wallets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(255) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(18,3) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, currency)
+);
+
+-- Create stored_value_ledger table
+CREATE TABLE IF NOT EXISTS stored_value_ledger (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_type VARCHAR(20) NOT NULL CHECK (account_type IN ('gift_card', 'wallet')),
+    account_id UUID NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('issue', 'redemption', 'reversal', 'refund', 'adjustment')),
+    amount DECIMAL(18,3) NOT NULL CHECK (amount <> 0),
+    balance_after DECIMAL(18,3) NOT NULL CHECK (balance_after >= 0),
+    currency VARCHAR(3) NOT NULL,
+    payment_id UUID REFERENCES payments(id),
+    refund_id UUID REFERENCES refunds(id),
+    note TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A checkout can pay part of an order from stored value and the rest by card
+ALTER TABLE checkout_sagas ADD COLUMN IF NOT EXISTS stored_value_payment_id VARCHAR(36);
+
+-- Stored-value payments draw on a gift card or wallet
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_type;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_type CHECK (type IN (
+    'card', 'paypal', 'apple_pay', 'google_pay', 'bank_transfer', 'gift_card', 'wallet'
+));
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_stored_value_ledger_account ON stored_value_ledger(account_type, account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_stored_value_ledger_payment_id ON stored_value_ledger(payment_id);
+-- A payment is redeemed and reversed at most once, and a refund credited once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stored_value_ledger_payment_entry ON stored_value_ledger(payment_id, type)
+    WHERE type IN ('redemption', 'reversal');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stored_value_ledger_refund_id ON stored_value_ledger(refund_id)
+    WHERE refund_id IS NOT NULL;
+
+-- Create triggers for updated_at
+CREATE TRIGGER update_gift_cards_updated_at BEFORE UPDATE ON gift_cards
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_wallets_updated_at BEFORE UPDATE ON wallets
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- The ledger is append-only
+CREATE OR REPLACE FUNCTION prevent_stored_value_ledger_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stored_value_ledger is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER prevent_stored_value_ledger_changes BEFORE UPDATE OR DELETE ON stored_value_ledger
+    FOR EACH ROW EXECUTE FUNCTION prevent_stored_value_ledger_changes();
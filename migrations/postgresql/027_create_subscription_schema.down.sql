@@ -0,0 +1,24 @@
+-- Subscription Schema Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_subscriptions_updated_at ON subscriptions;
+DROP TRIGGER IF EXISTS update_subscription_plans_updated_at ON subscription_plans;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_subscription_renewals_subscription_id;
+DROP INDEX IF EXISTS idx_subscriptions_resume_at;
+DROP INDEX IF EXISTS idx_subscriptions_next_billing_at;
+DROP INDEX IF EXISTS idx_subscriptions_plan_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+DROP INDEX IF EXISTS idx_subscription_plans_product_id;
+
+-- Restore the previous price source constraint
+UPDATE order_items SET price_source = 'product' WHERE price_source = 'subscription';
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_price_source_check;
+ALTER TABLE order_items ADD CONSTRAINT order_items_price_source_check
+    CHECK (price_source IN ('product', 'variant', 'client'));
+
+-- Drop tables
+DROP TABLE IF EXISTS subscription_renewals;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS subscription_plans;
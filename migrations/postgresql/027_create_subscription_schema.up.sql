@@ -0,0 +1,89 @@
+-- Subscription Schema
+-- Subscription plans sell a product on a recurring basis. Each subscription
+-- is renewed at the end of its period by creating an order and charging the
+-- customer's saved payment method; failed renewals are retried (dunning)
+-- before the subscription is cancelled. Every attempt is kept in
+-- subscription_renewals.
+
+-- Create subscription_plans table
+CREATE TABLE IF NOT EXISTS subscription_plans (
+    id VARCHAR(36) PRIMARY KEY,
+    product_id VARCHAR(36) NOT NULL,
+    variant_id VARCHAR(36),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    interval VARCHAR(10) NOT NULL CHECK (interval IN ('day', 'week', 'month', 'year')),
+    interval_count INTEGER NOT NULL DEFAULT 1 CHECK (interval_count > 0),
+    trial_days INTEGER NOT NULL DEFAULT 0 CHECK (trial_days >= 0),
+    price DECIMAL(15,3) NOT NULL CHECK (price > 0),
+    currency VARCHAR(3) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create subscriptions table
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    plan_id VARCHAR(36) NOT NULL REFERENCES subscription_plans(id),
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('trialing', 'active', 'past_due', 'paused', 'cancelled')),
+    email VARCHAR(255) NOT NULL,
+    payment_method_id VARCHAR(36) NOT NULL,
+    shipping_address JSONB NOT NULL,
+    billing_address JSONB NOT NULL,
+    shipping_method_id VARCHAR(255) NOT NULL DEFAULT '',
+    trial_ends_at TIMESTAMP,
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    next_billing_at TIMESTAMP NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+    paused_at TIMESTAMP,
+    resume_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    cancellation_reason TEXT NOT NULL DEFAULT '',
+    last_order_id VARCHAR(36) REFERENCES orders(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create subscription_renewals table
+CREATE TABLE IF NOT EXISTS subscription_renewals (
+    id VARCHAR(36) PRIMARY KEY,
+    subscription_id VARCHAR(36) NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('paid', 'failed', 'skipped')),
+    attempt INTEGER NOT NULL DEFAULT 0,
+    order_id VARCHAR(36) REFERENCES orders(id),
+    payment_id VARCHAR(36),
+    amount DECIMAL(15,3) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    failure_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Renewal order items are priced by their plan rather than the catalog
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_price_source_check;
+ALTER TABLE order_items ADD CONSTRAINT order_items_price_source_check
+    CHECK (price_source IN ('product', 'variant', 'client', 'subscription'));
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_subscription_plans_product_id ON subscription_plans(product_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_plan_id ON subscriptions(plan_id);
+-- The scheduler polls for billable subscriptions that are due and paused ones to resume
+CREATE INDEX IF NOT EXISTS idx_subscriptions_next_billing_at ON subscriptions(next_billing_at)
+    WHERE status IN ('trialing', 'active', 'past_due');
+CREATE INDEX IF NOT EXISTS idx_subscriptions_resume_at ON subscriptions(resume_at)
+    WHERE status = 'paused' AND resume_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_subscription_renewals_subscription_id ON subscription_renewals(subscription_id, created_at);
+
+-- Create triggers for updated_at
+CREATE TRIGGER update_subscription_plans_updated_at BEFORE UPDATE ON subscription_plans
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_subscriptions_updated_at BEFORE UPDATE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
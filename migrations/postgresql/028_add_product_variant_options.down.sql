@@ -0,0 +1,7 @@
+-- Product Variant Options Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_product_variants_default;
+
+-- Drop columns
+ALTER TABLE products DROP COLUMN IF EXISTS options;
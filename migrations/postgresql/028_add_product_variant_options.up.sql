@@ -0,0 +1,23 @@
+-- Product Variant Options
+-- Products list the option axes their variants vary along, such as size and
+-- color. Each variant holds one value per axis in its attributes.
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '[]';
+
+-- Derive the axes of products that already have variants from their attributes
+UPDATE products p
+SET options = axes.options
+FROM (
+    SELECT product_id, jsonb_agg(jsonb_build_object('name', key, 'values', option_values) ORDER BY key) AS options
+    FROM (
+        SELECT v.product_id, a.key, jsonb_agg(DISTINCT a.value ORDER BY a.value) AS option_values
+        FROM product_variants v, jsonb_each_text(v.attributes) a
+        GROUP BY v.product_id, a.key
+    ) product_axes
+    GROUP BY product_id
+) axes
+WHERE p.id = axes.product_id AND p.options = '[]';
+
+-- Create indexes for performance
+-- A product has at most one default variant
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_variants_default ON product_variants(product_id) WHERE is_default;
@@ -0,0 +1,13 @@
+-- Stock Reservations Schema Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_stock_reservations_updated_at ON stock_reservations;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_inventory_movements_reference;
+DROP INDEX IF EXISTS idx_stock_reservations_product_id;
+DROP INDEX IF EXISTS idx_stock_reservations_expires_at;
+DROP INDEX IF EXISTS idx_stock_reservations_reference_item;
+
+-- Drop tables
+DROP TABLE IF EXISTS stock_reservations;
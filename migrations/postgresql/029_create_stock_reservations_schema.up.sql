@@ -0,0 +1,33 @@
+-- Stock Reservations Schema
+-- A reservation holds units of a product or variant for an order or cart
+-- until it is released, committed as a sale or expires. There is one row per
+-- reference and product or variant, so repeated reserve calls update the
+-- quantity held instead of stacking holds. Reservations past expires_at are
+-- released by a sweeper in product-service.
+
+-- Create stock_reservations table
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id VARCHAR(36) PRIMARY KEY,
+    reference_type VARCHAR(20) NOT NULL CHECK (reference_type IN ('order', 'cart')),
+    reference_id VARCHAR(36) NOT NULL,
+    product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id VARCHAR(36) REFERENCES product_variants(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    committed_quantity INTEGER NOT NULL DEFAULT 0 CHECK (committed_quantity >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'expired', 'committed')),
+    expires_at TIMESTAMP NOT NULL,
+    released_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_reservations_reference_item
+    ON stock_reservations(reference_type, reference_id, product_id, COALESCE(variant_id, ''));
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_expires_at ON stock_reservations(expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_product_id ON stock_reservations(product_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_reference ON inventory_movements(reference_type, reference_id);
+
+-- Create triggers for updated_at timestamps
+CREATE TRIGGER update_stock_reservations_updated_at BEFORE UPDATE ON stock_reservations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,14 @@
+-- Inventory Movement Attribution Rollback
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_inventory_movements_created_by;
+DROP INDEX IF EXISTS idx_inventory_movements_product_created_at;
+
+-- Restore constraints
+ALTER TABLE inventory_movements
+    DROP CONSTRAINT IF EXISTS inventory_movements_variant_id_fkey,
+    ADD CONSTRAINT inventory_movements_variant_id_fkey
+        FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE;
+
+-- Restore columns
+ALTER TABLE inventory_movements ALTER COLUMN created_by TYPE VARCHAR(36) USING LEFT(created_by, 36);
@@ -0,0 +1,16 @@
+-- Inventory Movement Attribution
+-- Every movement records who made it: user:<id> for a user, or
+-- service:<name> for a service, which does not fit the old user ID column.
+
+ALTER TABLE inventory_movements ALTER COLUMN created_by TYPE VARCHAR(100);
+
+-- Keep the movements of deleted variants so the product's ledger still adds up
+ALTER TABLE inventory_movements
+    DROP CONSTRAINT IF EXISTS inventory_movements_variant_id_fkey,
+    ADD CONSTRAINT inventory_movements_variant_id_fkey
+        FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL;
+
+-- Create indexes for performance
+-- The ledger is read per product in date order
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_product_created_at ON inventory_movements(product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_created_by ON inventory_movements(created_by);
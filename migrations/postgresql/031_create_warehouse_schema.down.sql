@@ -0,0 +1,51 @@
+-- Warehouse Schema Rollback
+
+-- Restore the inventory movement trigger function
+CREATE OR REPLACE FUNCTION update_product_stock()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.movement_type = 'in' THEN
+        UPDATE products SET stock = stock + NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET stock = stock + NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    ELSIF NEW.movement_type = 'out' THEN
+        UPDATE products SET stock = stock - NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET stock = stock - NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    ELSIF NEW.movement_type = 'reserved' THEN
+        UPDATE products SET reserved_stock = reserved_stock + NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET reserved_stock = reserved_stock + NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    ELSIF NEW.movement_type = 'released' THEN
+        UPDATE products SET reserved_stock = reserved_stock - NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET reserved_stock = reserved_stock - NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_stock_allocations_updated_at ON stock_allocations;
+DROP TRIGGER IF EXISTS update_warehouses_updated_at ON warehouses;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_inventory_movements_warehouse_id;
+DROP INDEX IF EXISTS idx_stock_allocations_warehouse_id;
+DROP INDEX IF EXISTS idx_stock_transfers_product_id;
+DROP INDEX IF EXISTS idx_warehouse_stock_product_id;
+DROP INDEX IF EXISTS idx_warehouse_stock_item;
+DROP INDEX IF EXISTS idx_warehouses_is_active;
+
+-- Drop columns
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS warehouse_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS stock_allocations;
+DROP TABLE IF EXISTS stock_transfers;
+DROP TABLE IF EXISTS warehouse_stock;
+DROP TABLE IF EXISTS warehouses;
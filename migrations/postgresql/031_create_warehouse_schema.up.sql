@@ -0,0 +1,116 @@
+-- Warehouse Schema
+-- Stock can be kept at warehouses. A product's stock stays the total over
+-- its warehouses plus any stock not assigned to one, and every change to a
+-- warehouse's stock is an inventory movement recording the warehouse.
+-- Allocations assign the units of a stock reservation to the warehouses
+-- they will ship from.
+
+-- Create warehouses table
+CREATE TABLE IF NOT EXISTS warehouses (
+    id VARCHAR(36) PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    country VARCHAR(100) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0, -- lower numbers are preferred
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create warehouse_stock table
+-- Maintained by the inventory movement trigger; warehouses are deactivated
+-- rather than deleted, so their stock is never orphaned
+CREATE TABLE IF NOT EXISTS warehouse_stock (
+    warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+    product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id VARCHAR(36) REFERENCES product_variants(id) ON DELETE CASCADE,
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create stock_transfers table
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id VARCHAR(36) PRIMARY KEY,
+    from_warehouse_id VARCHAR(36) REFERENCES warehouses(id), -- NULL assigns stock not kept at a warehouse
+    to_warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+    product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id VARCHAR(36) REFERENCES product_variants(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reason TEXT,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_warehouse_id IS DISTINCT FROM to_warehouse_id)
+);
+
+-- Create stock_allocations table
+CREATE TABLE IF NOT EXISTS stock_allocations (
+    id VARCHAR(36) PRIMARY KEY,
+    reservation_id VARCHAR(36) NOT NULL REFERENCES stock_reservations(id) ON DELETE CASCADE,
+    warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    committed_quantity INTEGER NOT NULL DEFAULT 0 CHECK (committed_quantity >= 0 AND committed_quantity <= quantity),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (reservation_id, warehouse_id)
+);
+
+ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id VARCHAR(36) REFERENCES warehouses(id);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_warehouses_is_active ON warehouses(is_active);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouse_stock_item
+    ON warehouse_stock(warehouse_id, product_id, COALESCE(variant_id, ''));
+CREATE INDEX IF NOT EXISTS idx_warehouse_stock_product_id ON warehouse_stock(product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_product_id ON stock_transfers(product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_allocations_warehouse_id ON stock_allocations(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_warehouse_id ON inventory_movements(warehouse_id);
+
+-- Create triggers for updated_at timestamps
+CREATE TRIGGER update_warehouses_updated_at BEFORE UPDATE ON warehouses
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_stock_allocations_updated_at BEFORE UPDATE ON stock_allocations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- In and out movements at a warehouse also move that warehouse's stock
+CREATE OR REPLACE FUNCTION update_product_stock()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.movement_type = 'in' THEN
+        UPDATE products SET stock = stock + NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET stock = stock + NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+        IF NEW.warehouse_id IS NOT NULL THEN
+            INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, stock)
+            VALUES (NEW.warehouse_id, NEW.product_id, NEW.variant_id, NEW.quantity)
+            ON CONFLICT (warehouse_id, product_id, COALESCE(variant_id, ''))
+            DO UPDATE SET stock = warehouse_stock.stock + EXCLUDED.stock, updated_at = CURRENT_TIMESTAMP;
+        END IF;
+    ELSIF NEW.movement_type = 'out' THEN
+        UPDATE products SET stock = stock - NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET stock = stock - NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+        IF NEW.warehouse_id IS NOT NULL THEN
+            UPDATE warehouse_stock SET stock = stock - NEW.quantity, updated_at = CURRENT_TIMESTAMP
+            WHERE warehouse_id = NEW.warehouse_id AND product_id = NEW.product_id
+                AND variant_id IS NOT DISTINCT FROM NEW.variant_id;
+        END IF;
+    ELSIF NEW.movement_type = 'reserved' THEN
+        UPDATE products SET reserved_stock = reserved_stock + NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET reserved_stock = reserved_stock + NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    ELSIF NEW.movement_type = 'released' THEN
+        UPDATE products SET reserved_stock = reserved_stock - NEW.quantity WHERE id = NEW.product_id;
+        IF NEW.variant_id IS NOT NULL THEN
+            UPDATE product_variants SET reserved_stock = reserved_stock - NEW.quantity WHERE id = NEW.variant_id;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
@@ -0,0 +1,4 @@
+-- Order Origin Rollback
+
+ALTER TABLE orders DROP COLUMN IF EXISTS origin_address;
+ALTER TABLE orders DROP COLUMN IF EXISTS origin_warehouse_id;
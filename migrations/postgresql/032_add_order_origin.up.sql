@@ -0,0 +1,7 @@
+-- Order Origin
+-- Records the warehouse product-service allocated an order's stock to, and
+-- its address, which the order's shipment and shipping quotes ship from.
+-- Orders without one ship from the configured default warehouse.
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS origin_warehouse_id VARCHAR(36);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS origin_address JSONB;
@@ -0,0 +1,13 @@
+-- Stock Alerts Schema Rollback
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_stock_thresholds_updated_at ON stock_thresholds;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_back_in_stock_subscriptions_user_id;
+DROP INDEX IF EXISTS idx_back_in_stock_subscriptions_pending;
+DROP INDEX IF EXISTS idx_stock_thresholds_item;
+
+-- Drop tables
+DROP TABLE IF EXISTS back_in_stock_subscriptions;
+DROP TABLE IF EXISTS stock_thresholds;
@@ -0,0 +1,38 @@
+-- Stock Alerts Schema
+-- Reorder thresholds raise a low-stock alert in admin-service when a product's
+-- or variant's stock falls to them. Back-in-stock subscriptions notify a
+-- customer once when the item is available again; notified_at marks the
+-- subscriptions already used.
+
+-- Create stock_thresholds table
+CREATE TABLE IF NOT EXISTS stock_thresholds (
+    id VARCHAR(36) PRIMARY KEY,
+    product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id VARCHAR(36) REFERENCES product_variants(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL CHECK (threshold >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create back_in_stock_subscriptions table
+CREATE TABLE IF NOT EXISTS back_in_stock_subscriptions (
+    id VARCHAR(36) PRIMARY KEY,
+    product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id VARCHAR(36) REFERENCES product_variants(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms', 'push')),
+    recipient VARCHAR(255) NOT NULL,
+    notified_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_thresholds_item
+    ON stock_thresholds(product_id, COALESCE(variant_id, ''));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_back_in_stock_subscriptions_pending
+    ON back_in_stock_subscriptions(product_id, COALESCE(variant_id, ''), user_id) WHERE notified_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_back_in_stock_subscriptions_user_id ON back_in_stock_subscriptions(user_id);
+
+-- Create triggers for updated_at timestamps
+CREATE TRIGGER update_stock_thresholds_updated_at BEFORE UPDATE ON stock_thresholds
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
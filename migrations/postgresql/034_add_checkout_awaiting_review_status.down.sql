@@ -0,0 +1,8 @@
+-- Checkout Awaiting Review Status Rollback
+
+-- Checkouts still waiting are left for stalled checkout recovery to compensate
+UPDATE checkout_sagas SET status = 'started' WHERE status = 'awaiting_review';
+ALTER TABLE checkout_sagas DROP CONSTRAINT IF EXISTS checkout_sagas_status_check;
+ALTER TABLE checkout_sagas ADD CONSTRAINT checkout_sagas_status_check CHECK (status IN (
+    'started', 'completed', 'compensating', 'compensated', 'failed'
+));
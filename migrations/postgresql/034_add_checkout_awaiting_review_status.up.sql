@@ -0,0 +1,8 @@
+-- Checkout Awaiting Review Status
+-- A checkout whose payment the risk engine holds for review waits, with its
+-- order still pending, until an admin approves or rejects the payment.
+
+ALTER TABLE checkout_sagas DROP CONSTRAINT IF EXISTS checkout_sagas_status_check;
+ALTER TABLE checkout_sagas ADD CONSTRAINT checkout_sagas_status_check CHECK (status IN (
+    'started', 'awaiting_review', 'completed', 'compensating', 'compensated', 'failed'
+));
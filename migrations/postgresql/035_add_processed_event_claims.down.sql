@@ -0,0 +1,5 @@
+-- Processed Event Claims Rollback
+
+-- Claims in progress were never handled, so they must not count as processed
+DELETE FROM processed_events WHERE claimed_until IS NOT NULL;
+ALTER TABLE processed_events DROP COLUMN IF EXISTS claimed_until;
@@ -0,0 +1,6 @@
+-- Processed Event Claims
+-- Consumers claim an event before handling it so that concurrent deliveries
+-- of the same event are not handled twice. A row with claimed_until set is a
+-- claim in progress; it lapses at that time if the handler never finished.
+
+ALTER TABLE processed_events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
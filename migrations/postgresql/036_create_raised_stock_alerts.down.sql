@@ -0,0 +1,4 @@
+-- Raised Stock Alerts Rollback
+
+DROP INDEX IF EXISTS idx_raised_stock_alerts_raised_at;
+DROP TABLE IF EXISTS raised_stock_alerts;
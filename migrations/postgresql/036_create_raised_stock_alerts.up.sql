@@ -0,0 +1,14 @@
+-- Raised Stock Alerts
+-- Records the low-stock alerts each inventory event raised, so that an event
+-- delivered again or retried after a partial failure does not raise the same
+-- alert twice. variant_id is empty for a product's own alert.
+
+CREATE TABLE IF NOT EXISTS raised_stock_alerts (
+    event_id VARCHAR(36) NOT NULL,
+    product_id VARCHAR(36) NOT NULL,
+    variant_id VARCHAR(36) NOT NULL DEFAULT '',
+    raised_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, product_id, variant_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_raised_stock_alerts_raised_at ON raised_stock_alerts(raised_at);
@@ -0,0 +1,12 @@
+-- Subscription Versions Rollback
+
+DROP INDEX IF EXISTS idx_subscription_renewals_pending;
+
+-- Renewals still in progress were never completed
+UPDATE subscription_renewals SET status = 'failed', failure_reason = 'renewal interrupted'
+    WHERE status = 'pending';
+ALTER TABLE subscription_renewals DROP CONSTRAINT IF EXISTS subscription_renewals_status_check;
+ALTER TABLE subscription_renewals ADD CONSTRAINT subscription_renewals_status_check
+    CHECK (status IN ('paid', 'failed', 'skipped'));
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS version;
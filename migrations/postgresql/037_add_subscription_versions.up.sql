@@ -0,0 +1,17 @@
+-- Subscription Versions
+-- Every change to a subscription bumps its version and is only saved if the
+-- version is the one the change was made from, so a customer's change and
+-- the renewal scheduler cannot overwrite each other. A renewal is recorded as
+-- pending before it is charged and completed in the same transaction that
+-- starts the period it paid for, so an interrupted renewal is resumed rather
+-- than charged twice.
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE subscription_renewals DROP CONSTRAINT IF EXISTS subscription_renewals_status_check;
+ALTER TABLE subscription_renewals ADD CONSTRAINT subscription_renewals_status_check
+    CHECK (status IN ('pending', 'paid', 'failed', 'skipped'));
+
+-- A subscription has at most one renewal in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_renewals_pending ON subscription_renewals(subscription_id)
+    WHERE status = 'pending';
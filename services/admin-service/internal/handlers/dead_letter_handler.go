@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/admin-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// DeadLetterHandler exposes the event dead-letter store to admins
+type DeadLetterHandler struct {
+	admin   *AdminHandler
+	service service.DeadLetterService
+}
+
+func NewDeadLetterHandler(admin *AdminHandler, service service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		admin:   admin,
+		service: service,
+	}
+}
+
+func (h *DeadLetterHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionSystemAdmin); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	includeReplayed := query.Get("include_replayed") == "true"
+
+	letters, total, err := h.service.ListDeadLetters(r.Context(), query.Get("consumer"),
+		models.EventType(query.Get("event_type")), includeReplayed, page, limit)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"data":  letters,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+func (h *DeadLetterHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionSystemAdmin); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	letter, err := h.service.GetDeadLetter(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, letter)
+}
+
+func (h *DeadLetterHandler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionSystemAdmin); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	letter, err := h.service.ReplayDeadLetter(r.Context(), id)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	h.admin.logActivity(r, "replay", "dead_letter_event", nil, map[string]interface{}{
+		"dead_letter_id": id,
+		"event_id":       letter.Event.ID,
+		"consumer":       letter.Consumer,
+	})
+	utils.WriteJSONResponse(w, http.StatusOK, letter)
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		utils.WriteAppErrorResponse(w, appErr)
+		return
+	}
+	utils.WriteInternalErrorResponse(w, err.Error())
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/admin-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// RiskReviewHandler exposes the queue of payments held for risk review to admins
+type RiskReviewHandler struct {
+	admin   *AdminHandler
+	service service.RiskReviewService
+}
+
+func NewRiskReviewHandler(admin *AdminHandler, service service.RiskReviewService) *RiskReviewHandler {
+	return &RiskReviewHandler{
+		admin:   admin,
+		service: service,
+	}
+}
+
+func (h *RiskReviewHandler) ListRiskReviews(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionOrdersRead); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	reviews, err := h.service.ListPendingReviews(r.Context(), page, limit)
+	if err != nil {
+		writeRiskReviewError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"data":  reviews,
+		"page":  page,
+		"limit": limit,
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+func (h *RiskReviewHandler) GetRiskReview(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionOrdersRead); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	review, err := h.service.GetReview(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeRiskReviewError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, review)
+}
+
+func (h *RiskReviewHandler) ReviewPayment(w http.ResponseWriter, r *http.Request) {
+	if err := h.admin.requirePermission(r, models.PermissionOrdersWrite); err != nil {
+		utils.WriteForbiddenResponse(w, "Insufficient permissions")
+		return
+	}
+
+	var req service.RiskReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteValidationErrorResponse(w, "Invalid request body")
+		return
+	}
+
+	// The reviewer is always the calling admin
+	adminUserID, _ := h.admin.getAdminUserIDFromContext(r)
+	req.ReviewedBy = adminUserID.String()
+
+	id := mux.Vars(r)["id"]
+	payment, err := h.service.ReviewPayment(r.Context(), id, &req)
+	if err != nil {
+		writeRiskReviewError(w, err)
+		return
+	}
+
+	h.admin.logActivity(r, req.Decision, "payment_risk_review", nil, map[string]interface{}{
+		"assessment_id":  id,
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"note":           req.Note,
+	})
+	utils.WriteJSONResponse(w, http.StatusOK, payment)
+}
+
+func writeRiskReviewError(w http.ResponseWriter, err error) {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		utils.WriteAppErrorResponse(w, appErr)
+		return
+	}
+	utils.WriteInternalErrorResponse(w, err.Error())
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// DeadLetterService lets admins inspect and replay events that consumers gave up on
+type DeadLetterService interface {
+	ListDeadLetters(ctx context.Context, consumer string, eventType models.EventType, includeReplayed bool, page, limit int) ([]*events.DeadLetter, int, error)
+	GetDeadLetter(ctx context.Context, id string) (*events.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id string) (*events.DeadLetter, error)
+}
+
+type deadLetterService struct {
+	store     events.DeadLetterStore
+	publisher events.Publisher
+	logger    *utils.StructuredLogger
+}
+
+func NewDeadLetterService(store events.DeadLetterStore, publisher events.Publisher, logger *utils.StructuredLogger) DeadLetterService {
+	return &deadLetterService{
+		store:     store,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+func (s *deadLetterService) ListDeadLetters(ctx context.Context, consumer string, eventType models.EventType, includeReplayed bool, page, limit int) ([]*events.DeadLetter, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	letters, total, err := s.store.List(ctx, events.DeadLetterFilter{
+		Consumer:        consumer,
+		EventType:       eventType,
+		IncludeReplayed: includeReplayed,
+		Limit:           limit,
+		Offset:          (page - 1) * limit,
+	})
+	if err != nil {
+		s.logger.Error(ctx, "Failed to list dead-letter events", err)
+		return nil, 0, fmt.Errorf("failed to list dead-letter events: %w", err)
+	}
+
+	return letters, total, nil
+}
+
+func (s *deadLetterService) GetDeadLetter(ctx context.Context, id string) (*events.DeadLetter, error) {
+	return s.store.Get(ctx, id)
+}
+
+func (s *deadLetterService) ReplayDeadLetter(ctx context.Context, id string) (*events.DeadLetter, error) {
+	if s.publisher == nil {
+		return nil, utils.NewAppError(utils.ErrServiceUnavailable, "event broker is not configured", nil)
+	}
+
+	letter, err := events.ReplayDeadLetter(ctx, s.store, s.publisher, id)
+	if err != nil {
+		s.logger.Error(ctx, "Failed to replay dead-letter event", err, map[string]interface{}{"dead_letter_id": id})
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Dead-letter event replayed", map[string]interface{}{
+		"dead_letter_id": id,
+		"event_id":       letter.Event.ID,
+		"consumer":       letter.Consumer,
+	})
+	return letter, nil
+}
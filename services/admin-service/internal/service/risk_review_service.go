@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// RiskReviewService works the queue of payments payment-service held for
+// manual risk review
+type RiskReviewService interface {
+	ListPendingReviews(ctx context.Context, page, limit int) ([]*models.RiskAssessment, error)
+	GetReview(ctx context.Context, id string) (*models.RiskAssessment, error)
+	// ReviewPayment approves or rejects a held payment and returns the payment afterwards
+	ReviewPayment(ctx context.Context, id string, req *RiskReviewRequest) (*models.Payment, error)
+}
+
+// RiskReviewRequest is an admin's decision on a held payment
+type RiskReviewRequest struct {
+	Decision   string `json:"decision"` // approve or reject
+	ReviewedBy string `json:"reviewed_by"`
+	Note       string `json:"note"`
+}
+
+type riskReviewService struct {
+	baseURL    string
+	httpClient *http.Client
+	logger     *utils.StructuredLogger
+}
+
+// NewRiskReviewService creates a risk review service backed by payment-service at baseURL
+func NewRiskReviewService(baseURL string, logger *utils.StructuredLogger) RiskReviewService {
+	return &riskReviewService{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+func (s *riskReviewService) ListPendingReviews(ctx context.Context, page, limit int) ([]*models.RiskAssessment, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := url.Values{}
+	query.Set("review_status", string(models.RiskReviewPending))
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa((page-1)*limit))
+
+	var response struct {
+		Assessments []*models.RiskAssessment `json:"assessments"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/admin/risk/assessments?"+query.Encode(), nil, &response); err != nil {
+		s.logger.Error(ctx, "Failed to list risk reviews", err)
+		return nil, err
+	}
+
+	return response.Assessments, nil
+}
+
+func (s *riskReviewService) GetReview(ctx context.Context, id string) (*models.RiskAssessment, error) {
+	var assessment models.RiskAssessment
+	if err := s.do(ctx, http.MethodGet, "/admin/risk/assessments/"+url.PathEscape(id), nil, &assessment); err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+func (s *riskReviewService) ReviewPayment(ctx context.Context, id string, req *RiskReviewRequest) (*models.Payment, error) {
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return nil, utils.NewValidationError("decision must be approve or reject")
+	}
+
+	var payment models.Payment
+	if err := s.do(ctx, http.MethodPost, "/admin/risk/assessments/"+url.PathEscape(id)+"/review", req, &payment); err != nil {
+		s.logger.Error(ctx, "Failed to review held payment", err, map[string]interface{}{"assessment_id": id})
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Held payment reviewed", map[string]interface{}{
+		"assessment_id":  id,
+		"payment_id":     payment.ID,
+		"decision":       req.Decision,
+		"reviewed_by":    req.ReviewedBy,
+		"payment_status": payment.Status,
+	})
+	return &payment, nil
+}
+
+// do calls payment-service and turns its error responses into AppErrors
+// carrying the same status
+func (s *riskReviewService) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return utils.NewAppError(utils.ErrServiceUnavailable, "payment-service is unavailable", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errBody utils.ErrorResponse
+		message := http.StatusText(resp.StatusCode)
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil && errBody.Error.Message != "" {
+			message = errBody.Error.Message
+		}
+		return utils.NewAppError(paymentServiceErrorCode(resp.StatusCode), message, nil)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode payment-service response: %w", err)
+	}
+	return nil
+}
+
+func paymentServiceErrorCode(status int) utils.ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return utils.ErrValidation
+	case http.StatusNotFound:
+		return utils.ErrNotFound
+	case http.StatusConflict:
+		return utils.ErrConflict
+	case http.StatusServiceUnavailable:
+		return utils.ErrServiceUnavailable
+	default:
+		return utils.ErrInternal
+	}
+}
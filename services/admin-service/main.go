@@ -12,6 +12,7 @@ import (
 	"github.com/shopsphere/admin-service/internal/handlers"
 	"github.com/shopsphere/admin-service/internal/repository"
 	"github.com/shopsphere/admin-service/internal/service"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/utils"
 )
 
@@ -51,8 +52,24 @@ func main() {
 	// Initialize service
 	adminService := service.NewAdminService(adminRepo, logger)
 
+	// Dead-letter replay publishes back to the event broker, so it needs Redis
+	var eventPublisher events.Publisher
+	redisClient, err := utils.NewRedisConfig().Connect()
+	if err != nil {
+		logger.Error(ctx, "Failed to connect to Redis, dead-letter replay disabled", err)
+	} else {
+		defer redisClient.Close()
+		eventPublisher = events.NewRedisBroker(redisClient)
+	}
+	deadLetterService := service.NewDeadLetterService(events.NewPostgresDeadLetterStore(db), eventPublisher, logger)
+
+	// Payments held by the risk checks are reviewed through payment-service
+	riskReviewService := service.NewRiskReviewService(getEnv("PAYMENT_SERVICE_URL", "http://localhost:8006"), logger)
+
 	// Initialize handlers
 	adminHandler := handlers.NewAdminHandler(adminService, logger)
+	deadLetterHandler := handlers.NewDeadLetterHandler(adminHandler, deadLetterService)
+	riskReviewHandler := handlers.NewRiskReviewHandler(adminHandler, riskReviewService)
 
 	// Setup routes
 	router := mux.NewRouter()
@@ -93,6 +110,16 @@ func main() {
 	router.HandleFunc("/admin/bulk-operations/{id}", adminHandler.GetBulkOperation).Methods("GET")
 	router.HandleFunc("/admin/bulk-operations", adminHandler.ListBulkOperations).Methods("GET")
 
+	// Event Dead Letters
+	router.HandleFunc("/admin/dead-letters", deadLetterHandler.ListDeadLetters).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/{id}", deadLetterHandler.GetDeadLetter).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/{id}/replay", deadLetterHandler.ReplayDeadLetter).Methods("POST")
+
+	// Payment Risk Reviews
+	router.HandleFunc("/admin/risk/reviews", riskReviewHandler.ListRiskReviews).Methods("GET")
+	router.HandleFunc("/admin/risk/reviews/{id}", riskReviewHandler.GetRiskReview).Methods("GET")
+	router.HandleFunc("/admin/risk/reviews/{id}/review", riskReviewHandler.ReviewPayment).Methods("POST")
+
 	// System Metrics
 	router.HandleFunc("/admin/metrics/update", adminHandler.UpdateSystemMetrics).Methods("POST")
 
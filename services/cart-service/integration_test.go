@@ -51,7 +51,7 @@ func TestCartRepository_Integration(t *testing.T) {
 	
 	t.Run("SaveAndGetCart", func(t *testing.T) {
 		cart := models.NewCart("user1", "")
-		cart.AddItem("prod1", "SKU1", "Product 1", decimal.NewFromFloat(19.99), 2)
+		cart.AddItem("prod1", "", "SKU1", "Product 1", decimal.NewFromFloat(19.99), 2)
 		
 		// Save cart
 		err := repo.SaveCart(ctx, cart)
@@ -84,7 +84,7 @@ func TestCartRepository_Integration(t *testing.T) {
 	
 	t.Run("GetCartByID", func(t *testing.T) {
 		cart := models.NewCart("user2", "")
-		cart.AddItem("prod2", "SKU2", "Product 2", decimal.NewFromFloat(29.99), 1)
+		cart.AddItem("prod2", "", "SKU2", "Product 2", decimal.NewFromFloat(29.99), 1)
 		
 		err := repo.SaveCart(ctx, cart)
 		if err != nil {
@@ -152,7 +152,7 @@ func TestCartRepository_Integration(t *testing.T) {
 	t.Run("MigrateGuestCartToUser", func(t *testing.T) {
 		// Create guest cart
 		guestCart := models.NewCart("", "session123")
-		guestCart.AddItem("prod5", "SKU5", "Product 5", decimal.NewFromFloat(39.99), 1)
+		guestCart.AddItem("prod5", "", "SKU5", "Product 5", decimal.NewFromFloat(39.99), 1)
 		
 		err := repo.SaveCart(ctx, guestCart)
 		if err != nil {
@@ -196,7 +196,7 @@ func TestCartService_Integration(t *testing.T) {
 	defer cleanup()
 	
 	ctx := context.Background()
-	cartService := service.NewCartService(repo, nil)
+	cartService := service.NewCartService(repo, nil, nil, nil)
 	
 	t.Run("CompleteCartWorkflow", func(t *testing.T) {
 		userID := "integration_user"
@@ -204,7 +204,7 @@ func TestCartService_Integration(t *testing.T) {
 		price2 := decimal.NewFromFloat(29.99)
 		
 		// Add first item
-		cart, err := cartService.AddItem(ctx, userID, "", "prod1", "SKU1", "Product 1", price1, 2)
+		cart, err := cartService.AddItem(ctx, userID, "", "prod1", "", "SKU1", "Product 1", price1, 2)
 		if err != nil {
 			t.Fatalf("Failed to add first item: %v", err)
 		}
@@ -214,7 +214,7 @@ func TestCartService_Integration(t *testing.T) {
 		}
 		
 		// Add second item
-		cart, err = cartService.AddItem(ctx, userID, "", "prod2", "SKU2", "Product 2", price2, 1)
+		cart, err = cartService.AddItem(ctx, userID, "", "prod2", "", "SKU2", "Product 2", price2, 1)
 		if err != nil {
 			t.Fatalf("Failed to add second item: %v", err)
 		}
@@ -230,7 +230,7 @@ func TestCartService_Integration(t *testing.T) {
 		}
 		
 		// Update item quantity
-		cart, err = cartService.UpdateItem(ctx, userID, "", "prod1", 3)
+		cart, err = cartService.UpdateItem(ctx, userID, "", "prod1", "", 3)
 		if err != nil {
 			t.Fatalf("Failed to update item: %v", err)
 		}
@@ -253,7 +253,7 @@ func TestCartService_Integration(t *testing.T) {
 		}
 		
 		// Remove item
-		cart, err = cartService.RemoveItem(ctx, userID, "", "prod2")
+		cart, err = cartService.RemoveItem(ctx, userID, "", "prod2", "")
 		if err != nil {
 			t.Fatalf("Failed to remove item: %v", err)
 		}
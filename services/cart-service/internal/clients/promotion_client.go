@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shopsphere/cart-service/internal/service"
+	"github.com/shopsphere/shared/utils"
+)
+
+// PromotionClient quotes cart discounts from order-service, which owns
+// promotions and coupons
+type PromotionClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewPromotionClient creates a client for the order-service promotion API
+func NewPromotionClient(baseURL string) *PromotionClient {
+	return &PromotionClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Quote implements service.PromotionService
+func (c *PromotionClient) Quote(ctx context.Context, req *service.PromotionQuoteRequest) (*service.PromotionQuote, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quote request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/promotions/quote", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build quote request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if traceID := utils.GetTraceID(ctx); traceID != "" {
+		httpReq.Header.Set("X-Trace-ID", traceID)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("promotion quote failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("promotion quote failed with status %d", resp.StatusCode)
+	}
+
+	var quote service.PromotionQuote
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+		return nil, fmt.Errorf("failed to decode promotion quote: %w", err)
+	}
+	return &quote, nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -26,6 +27,7 @@ func NewCartHandler(cartService service.CartService) *CartHandler {
 // AddItemRequest represents the request to add an item to cart
 type AddItemRequest struct {
 	ProductID string          `json:"product_id" validate:"required"`
+	VariantID string          `json:"variant_id"`
 	SKU       string          `json:"sku" validate:"required"`
 	Name      string          `json:"name" validate:"required"`
 	Price     decimal.Decimal `json:"price" validate:"required"`
@@ -43,6 +45,16 @@ type MigrateCartRequest struct {
 	UserID    string `json:"user_id" validate:"required"`
 }
 
+// ApplyCouponRequest represents the request to apply a coupon to the cart
+type ApplyCouponRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// SetCurrencyRequest represents the request to change the cart currency
+type SetCurrencyRequest struct {
+	Currency string `json:"currency" validate:"required"`
+}
+
 // ExtendExpiryRequest represents the request to extend cart expiry
 type ExtendExpiryRequest struct {
 	Hours int `json:"hours" validate:"required,min=1,max=168"` // Max 7 days
@@ -94,7 +106,7 @@ func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cart, err := h.cartService.AddItem(ctx, userID, sessionID, req.ProductID, req.SKU, req.Name, req.Price, req.Quantity)
+	cart, err := h.cartService.AddItem(ctx, userID, sessionID, req.ProductID, req.VariantID, req.SKU, req.Name, req.Price, req.Quantity)
 	if err != nil {
 		utils.Logger.Error(ctx, "Failed to add item to cart", err, map[string]interface{}{
 			"user_id":    userID,
@@ -115,7 +127,8 @@ func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponse(w, http.StatusOK, cart)
 }
 
-// UpdateItem updates the quantity of an item in the cart
+// UpdateItem updates the quantity of an item in the cart. A variant's line
+// is addressed with the variant_id query parameter.
 func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-ID")
@@ -123,6 +136,7 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	
 	vars := mux.Vars(r)
 	productID := vars["productId"]
+	variantID := r.URL.Query().Get("variant_id")
 
 	if userID == "" && sessionID == "" {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
@@ -140,7 +154,7 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cart, err := h.cartService.UpdateItem(ctx, userID, sessionID, productID, req.Quantity)
+	cart, err := h.cartService.UpdateItem(ctx, userID, sessionID, productID, variantID, req.Quantity)
 	if err != nil {
 		utils.Logger.Error(ctx, "Failed to update cart item", err, map[string]interface{}{
 			"user_id":    userID,
@@ -166,7 +180,8 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponse(w, http.StatusOK, cart)
 }
 
-// RemoveItem removes an item from the cart
+// RemoveItem removes an item from the cart. A variant's line is addressed
+// with the variant_id query parameter.
 func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-ID")
@@ -174,13 +189,14 @@ func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
 	
 	vars := mux.Vars(r)
 	productID := vars["productId"]
+	variantID := r.URL.Query().Get("variant_id")
 
 	if userID == "" && sessionID == "" {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
 		return
 	}
 
-	cart, err := h.cartService.RemoveItem(ctx, userID, sessionID, productID)
+	cart, err := h.cartService.RemoveItem(ctx, userID, sessionID, productID, variantID)
 	if err != nil {
 		utils.Logger.Error(ctx, "Failed to remove cart item", err, map[string]interface{}{
 			"user_id":    userID,
@@ -348,6 +364,7 @@ func (h *CartHandler) GetCartSummary(w http.ResponseWriter, r *http.Request) {
 		"cart_id":    cart.ID,
 		"item_count": cart.GetItemCount(),
 		"subtotal":   cart.Subtotal,
+		"discount":   cart.Discount,
 		"currency":   cart.Currency,
 		"expires_at": cart.ExpiresAt,
 		"updated_at": cart.UpdatedAt,
@@ -356,6 +373,124 @@ func (h *CartHandler) GetCartSummary(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponse(w, http.StatusOK, summary)
 }
 
+// ApplyCoupon applies a coupon code to the cart
+func (h *CartHandler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-ID")
+	sessionID := r.Header.Get("X-Session-ID")
+
+	if userID == "" && sessionID == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
+		return
+	}
+
+	var req ApplyCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	cart, err := h.cartService.ApplyCoupon(ctx, userID, sessionID, req.Code)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to apply coupon", err, map[string]interface{}{
+			"user_id":    userID,
+			"session_id": sessionID,
+			"code":       req.Code,
+		})
+
+		switch {
+		case strings.Contains(err.Error(), "invalid coupon"):
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", err.Error())
+		case strings.Contains(err.Error(), "not available"):
+			utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "COUPONS_UNAVAILABLE", "Coupons are not available")
+		default:
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "APPLY_COUPON_FAILED", "Failed to apply coupon")
+		}
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, cart)
+}
+
+// RemoveCoupon removes a coupon code from the cart
+func (h *CartHandler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-ID")
+	sessionID := r.Header.Get("X-Session-ID")
+	code := mux.Vars(r)["code"]
+
+	if userID == "" && sessionID == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
+		return
+	}
+
+	cart, err := h.cartService.RemoveCoupon(ctx, userID, sessionID, code)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to remove coupon", err, map[string]interface{}{
+			"user_id":    userID,
+			"session_id": sessionID,
+			"code":       code,
+		})
+
+		if err.Error() == "coupon not found in cart" {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "COUPON_NOT_FOUND", "Coupon not found in cart")
+			return
+		}
+
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "REMOVE_COUPON_FAILED", "Failed to remove coupon")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, cart)
+}
+
+// SetCurrency changes the currency the cart is priced in
+func (h *CartHandler) SetCurrency(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-ID")
+	sessionID := r.Header.Get("X-Session-ID")
+
+	if userID == "" && sessionID == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
+		return
+	}
+
+	var req SetCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	cart, err := h.cartService.SetCurrency(ctx, userID, sessionID, req.Currency)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to set cart currency", err, map[string]interface{}{
+			"user_id":    userID,
+			"session_id": sessionID,
+			"currency":   req.Currency,
+		})
+
+		if strings.Contains(err.Error(), "invalid currency") {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_CURRENCY", err.Error())
+			return
+		}
+
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "SET_CURRENCY_FAILED", "Failed to set cart currency")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, cart)
+}
+
 // CleanupExpiredCarts removes expired carts (admin endpoint)
 func (h *CartHandler) CleanupExpiredCarts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
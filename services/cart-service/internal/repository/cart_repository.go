@@ -249,7 +249,7 @@ func (r *RedisCartRepository) MigrateGuestCartToUser(ctx context.Context, sessio
 		for _, guestItem := range guestCart.Items {
 			found := false
 			for i, userItem := range userCart.Items {
-				if userItem.ProductID == guestItem.ProductID && userItem.SKU == guestItem.SKU {
+				if userItem.ProductID == guestItem.ProductID && userItem.VariantID == guestItem.VariantID && userItem.SKU == guestItem.SKU {
 					// Update quantity and total
 					userCart.Items[i].Quantity += guestItem.Quantity
 					userCart.Items[i].Total = userItem.Price.Mul(userCart.Items[i].Total.Div(userItem.Price).Add(guestItem.Total.Div(guestItem.Price)))
@@ -3,9 +3,11 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shopsphere/cart-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 	"github.com/shopspring/decimal"
@@ -14,14 +16,17 @@ import (
 // CartService defines the interface for cart business logic
 type CartService interface {
 	GetCart(ctx context.Context, userID, sessionID string) (*models.Cart, error)
-	AddItem(ctx context.Context, userID, sessionID, productID, sku, name string, price decimal.Decimal, quantity int) (*models.Cart, error)
-	UpdateItem(ctx context.Context, userID, sessionID, productID string, quantity int) (*models.Cart, error)
-	RemoveItem(ctx context.Context, userID, sessionID, productID string) (*models.Cart, error)
+	AddItem(ctx context.Context, userID, sessionID, productID, variantID, sku, name string, price decimal.Decimal, quantity int) (*models.Cart, error)
+	UpdateItem(ctx context.Context, userID, sessionID, productID, variantID string, quantity int) (*models.Cart, error)
+	RemoveItem(ctx context.Context, userID, sessionID, productID, variantID string) (*models.Cart, error)
 	ClearCart(ctx context.Context, userID, sessionID string) error
 	MigrateGuestCart(ctx context.Context, sessionID, userID string) (*models.Cart, error)
 	ValidateCart(ctx context.Context, cart *models.Cart) (*CartValidationResult, error)
 	ExtendCartExpiry(ctx context.Context, userID, sessionID string, duration time.Duration) (*models.Cart, error)
 	CleanupExpiredCarts(ctx context.Context) error
+	ApplyCoupon(ctx context.Context, userID, sessionID, code string) (*models.Cart, error)
+	RemoveCoupon(ctx context.Context, userID, sessionID, code string) (*models.Cart, error)
+	SetCurrency(ctx context.Context, userID, sessionID, code string) (*models.Cart, error)
 }
 
 // CartValidationResult represents the result of cart validation
@@ -36,6 +41,7 @@ type CartValidationResult struct {
 // CartValidationItem represents an invalid cart item
 type CartValidationItem struct {
 	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
 	SKU       string `json:"sku"`
 	Name      string `json:"name"`
 	Reason    string `json:"reason"`
@@ -44,6 +50,7 @@ type CartValidationItem struct {
 // CartPriceChange represents a price change in cart items
 type CartPriceChange struct {
 	ProductID string          `json:"product_id"`
+	VariantID string          `json:"variant_id,omitempty"`
 	SKU       string          `json:"sku"`
 	Name      string          `json:"name"`
 	OldPrice  decimal.Decimal `json:"old_price"`
@@ -52,14 +59,18 @@ type CartPriceChange struct {
 
 // cartService implements CartService
 type cartService struct {
-	cartRepo        repository.CartRepository
-	productService  ProductService // Interface to product service for validation
+	cartRepo         repository.CartRepository
+	productService   ProductService // Interface to product service for validation
+	promotionService PromotionService
+	rates            ExchangeRates
 }
 
-// ProductService interface for product validation
+// ProductService interface for product validation. Stock is checked against
+// the variant when variantID is set.
 type ProductService interface {
 	GetProduct(ctx context.Context, productID string) (*ProductInfo, error)
-	ValidateStock(ctx context.Context, productID string, quantity int) (bool, error)
+	GetVariant(ctx context.Context, productID, variantID string) (*ProductInfo, error)
+	ValidateStock(ctx context.Context, productID, variantID string, quantity int) (bool, error)
 }
 
 // ProductInfo represents basic product information for validation
@@ -72,16 +83,71 @@ type ProductInfo struct {
 	IsAvailable bool            `json:"is_available"`
 }
 
-// NewCartService creates a new cart service
-func NewCartService(cartRepo repository.CartRepository, productService ProductService) CartService {
+// PromotionService prices a cart against the promotions and coupons
+// managed by order-service
+type PromotionService interface {
+	Quote(ctx context.Context, req *PromotionQuoteRequest) (*PromotionQuote, error)
+}
+
+// PromotionQuoteRequest mirrors order-service's promotion request
+type PromotionQuoteRequest struct {
+	UserID      string               `json:"user_id"`
+	CouponCodes []string             `json:"coupon_codes"`
+	Items       []PromotionQuoteItem `json:"items"`
+	Currency    string               `json:"currency"`
+}
+
+// PromotionQuoteItem is a cart line being priced
+type PromotionQuoteItem struct {
+	ProductID string          `json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+}
+
+// PromotionQuote mirrors order-service's promotion result
+type PromotionQuote struct {
+	Discounts []models.OrderDiscount `json:"discounts"`
+	Discount  decimal.Decimal        `json:"discount"`
+	Rejected  []CouponRejection      `json:"rejected,omitempty"`
+}
+
+// CouponRejection explains why a coupon code did not apply
+type CouponRejection struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// ExchangeRates converts between currencies. *currency.Rates, loaded from
+// FX_RATES_FILE, implements it.
+type ExchangeRates interface {
+	Rate(from, to string) (currency.Conversion, error)
+}
+
+// NewCartService creates a new cart service. Without a promotion service
+// carts carry no discounts and coupons cannot be applied; without rates
+// only empty carts can change currency.
+func NewCartService(cartRepo repository.CartRepository, productService ProductService, promotionService PromotionService, rates ExchangeRates) CartService {
 	return &cartService{
-		cartRepo:       cartRepo,
-		productService: productService,
+		cartRepo:         cartRepo,
+		productService:   productService,
+		promotionService: promotionService,
+		rates:            rates,
 	}
 }
 
-// GetCart retrieves or creates a cart for the user/session
+// GetCart retrieves or creates a cart for the user/session, priced with the
+// discounts it currently qualifies for
 func (s *cartService) GetCart(ctx context.Context, userID, sessionID string) (*models.Cart, error) {
+	cart, err := s.loadCart(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	s.applyDiscounts(ctx, cart)
+	return cart, nil
+}
+
+// loadCart retrieves or creates a cart for the user/session
+func (s *cartService) loadCart(ctx context.Context, userID, sessionID string) (*models.Cart, error) {
 	cart, err := s.cartRepo.GetCart(ctx, userID, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cart: %w", err)
@@ -105,16 +171,17 @@ func (s *cartService) GetCart(ctx context.Context, userID, sessionID string) (*m
 }
 
 // AddItem adds an item to the cart
-func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID, sku, name string, price decimal.Decimal, quantity int) (*models.Cart, error) {
+func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID, variantID, sku, name string, price decimal.Decimal, quantity int) (*models.Cart, error) {
 	if quantity <= 0 {
 		return nil, fmt.Errorf("quantity must be greater than 0")
 	}
 
 	// Validate product if product service is available
 	if s.productService != nil {
-		if available, err := s.productService.ValidateStock(ctx, productID, quantity); err != nil {
+		if available, err := s.productService.ValidateStock(ctx, productID, variantID, quantity); err != nil {
 			utils.Logger.Error(ctx, "Failed to validate product stock", err, map[string]interface{}{
 				"product_id": productID,
+				"variant_id": variantID,
 				"quantity":   quantity,
 			})
 		} else if !available {
@@ -122,7 +189,7 @@ func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID,
 		}
 	}
 
-	cart, err := s.GetCart(ctx, userID, sessionID)
+	cart, err := s.loadCart(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +197,7 @@ func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID,
 	// Check if item already exists in cart
 	found := false
 	for i, item := range cart.Items {
-		if item.ProductID == productID && item.SKU == sku {
+		if item.ProductID == productID && item.VariantID == variantID && item.SKU == sku {
 			// Update existing item
 			cart.Items[i].Quantity += quantity
 			cart.Items[i].Total = item.Price.Mul(decimal.NewFromInt(int64(cart.Items[i].Quantity)))
@@ -142,7 +209,7 @@ func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID,
 
 	if !found {
 		// Add new item
-		cart.AddItem(productID, sku, name, price, quantity)
+		cart.AddItem(productID, variantID, sku, name, price, quantity)
 	}
 
 	cart.UpdatedAt = time.Now()
@@ -155,17 +222,19 @@ func (s *cartService) AddItem(ctx context.Context, userID, sessionID, productID,
 	utils.Logger.Info(ctx, "Added item to cart", map[string]interface{}{
 		"cart_id":    cart.ID,
 		"product_id": productID,
+		"variant_id": variantID,
 		"quantity":   quantity,
 		"user_id":    userID,
 		"session_id": sessionID,
 	})
 
+	s.applyDiscounts(ctx, cart)
 	return cart, nil
 }
 
 // UpdateItem updates the quantity of an item in the cart
-func (s *cartService) UpdateItem(ctx context.Context, userID, sessionID, productID string, quantity int) (*models.Cart, error) {
-	cart, err := s.GetCart(ctx, userID, sessionID)
+func (s *cartService) UpdateItem(ctx context.Context, userID, sessionID, productID, variantID string, quantity int) (*models.Cart, error) {
+	cart, err := s.loadCart(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -173,9 +242,10 @@ func (s *cartService) UpdateItem(ctx context.Context, userID, sessionID, product
 	if quantity > 0 {
 		// Validate stock if product service is available
 		if s.productService != nil {
-			if available, err := s.productService.ValidateStock(ctx, productID, quantity); err != nil {
+			if available, err := s.productService.ValidateStock(ctx, productID, variantID, quantity); err != nil {
 				utils.Logger.Error(ctx, "Failed to validate product stock", err, map[string]interface{}{
 					"product_id": productID,
+					"variant_id": variantID,
 					"quantity":   quantity,
 				})
 			} else if !available {
@@ -184,7 +254,7 @@ func (s *cartService) UpdateItem(ctx context.Context, userID, sessionID, product
 		}
 	}
 
-	if !cart.UpdateItem(productID, quantity) {
+	if !cart.UpdateItem(productID, variantID, quantity) {
 		return nil, fmt.Errorf("item not found in cart")
 	}
 
@@ -195,22 +265,24 @@ func (s *cartService) UpdateItem(ctx context.Context, userID, sessionID, product
 	utils.Logger.Info(ctx, "Updated cart item", map[string]interface{}{
 		"cart_id":    cart.ID,
 		"product_id": productID,
+		"variant_id": variantID,
 		"quantity":   quantity,
 		"user_id":    userID,
 		"session_id": sessionID,
 	})
 
+	s.applyDiscounts(ctx, cart)
 	return cart, nil
 }
 
 // RemoveItem removes an item from the cart
-func (s *cartService) RemoveItem(ctx context.Context, userID, sessionID, productID string) (*models.Cart, error) {
-	cart, err := s.GetCart(ctx, userID, sessionID)
+func (s *cartService) RemoveItem(ctx context.Context, userID, sessionID, productID, variantID string) (*models.Cart, error) {
+	cart, err := s.loadCart(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	if !cart.RemoveItem(productID) {
+	if !cart.RemoveItem(productID, variantID) {
 		return nil, fmt.Errorf("item not found in cart")
 	}
 
@@ -221,22 +293,27 @@ func (s *cartService) RemoveItem(ctx context.Context, userID, sessionID, product
 	utils.Logger.Info(ctx, "Removed item from cart", map[string]interface{}{
 		"cart_id":    cart.ID,
 		"product_id": productID,
+		"variant_id": variantID,
 		"user_id":    userID,
 		"session_id": sessionID,
 	})
 
+	s.applyDiscounts(ctx, cart)
 	return cart, nil
 }
 
 // ClearCart removes all items from the cart
 func (s *cartService) ClearCart(ctx context.Context, userID, sessionID string) error {
-	cart, err := s.GetCart(ctx, userID, sessionID)
+	cart, err := s.loadCart(ctx, userID, sessionID)
 	if err != nil {
 		return err
 	}
 
 	cart.Items = []models.CartItem{}
 	cart.Subtotal = decimal.Zero
+	cart.CouponCodes = nil
+	cart.Discount = decimal.Zero
+	cart.Discounts = nil
 	cart.UpdatedAt = time.Now()
 
 	if err := s.cartRepo.SaveCart(ctx, cart); err != nil {
@@ -302,11 +379,30 @@ func (s *cartService) ValidateCart(ctx context.Context, cart *models.Cart) (*Car
 			continue
 		}
 
+		// A variant has a price of its own
+		price := productInfo.Price
+		if item.VariantID != "" {
+			variantInfo, err := s.productService.GetVariant(ctx, item.ProductID, item.VariantID)
+			if err != nil {
+				result.IsValid = false
+				result.InvalidItems = append(result.InvalidItems, CartValidationItem{
+					ProductID: item.ProductID,
+					VariantID: item.VariantID,
+					SKU:       item.SKU,
+					Name:      item.Name,
+					Reason:    "Variant not found",
+				})
+				continue
+			}
+			price = variantInfo.Price
+		}
+
 		// Check availability
 		if !productInfo.IsAvailable {
 			result.IsValid = false
 			result.UnavailableItems = append(result.UnavailableItems, CartValidationItem{
 				ProductID: item.ProductID,
+				VariantID: item.VariantID,
 				SKU:       item.SKU,
 				Name:      item.Name,
 				Reason:    "Product no longer available",
@@ -315,10 +411,11 @@ func (s *cartService) ValidateCart(ctx context.Context, cart *models.Cart) (*Car
 		}
 
 		// Check stock
-		if available, err := s.productService.ValidateStock(ctx, item.ProductID, item.Quantity); err != nil || !available {
+		if available, err := s.productService.ValidateStock(ctx, item.ProductID, item.VariantID, item.Quantity); err != nil || !available {
 			result.IsValid = false
 			result.UnavailableItems = append(result.UnavailableItems, CartValidationItem{
 				ProductID: item.ProductID,
+				VariantID: item.VariantID,
 				SKU:       item.SKU,
 				Name:      item.Name,
 				Reason:    "Insufficient stock",
@@ -327,16 +424,17 @@ func (s *cartService) ValidateCart(ctx context.Context, cart *models.Cart) (*Car
 		}
 
 		// Check price changes
-		if !item.Price.Equal(productInfo.Price) {
+		if !item.Price.Equal(price) {
 			result.PriceChanges = append(result.PriceChanges, CartPriceChange{
 				ProductID: item.ProductID,
+				VariantID: item.VariantID,
 				SKU:       item.SKU,
 				Name:      item.Name,
 				OldPrice:  item.Price,
-				NewPrice:  productInfo.Price,
+				NewPrice:  price,
 			})
 			// Update total with new price
-			result.TotalAmount = result.TotalAmount.Add(productInfo.Price.Mul(decimal.NewFromInt(int64(item.Quantity))))
+			result.TotalAmount = result.TotalAmount.Add(price.Mul(decimal.NewFromInt(int64(item.Quantity))))
 		} else {
 			result.TotalAmount = result.TotalAmount.Add(item.Total)
 		}
@@ -347,7 +445,7 @@ func (s *cartService) ValidateCart(ctx context.Context, cart *models.Cart) (*Car
 
 // ExtendCartExpiry extends the expiry time of a cart
 func (s *cartService) ExtendCartExpiry(ctx context.Context, userID, sessionID string, duration time.Duration) (*models.Cart, error) {
-	cart, err := s.GetCart(ctx, userID, sessionID)
+	cart, err := s.loadCart(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -365,6 +463,7 @@ func (s *cartService) ExtendCartExpiry(ctx context.Context, userID, sessionID st
 		"expires_at": cart.ExpiresAt,
 	})
 
+	s.applyDiscounts(ctx, cart)
 	return cart, nil
 }
 
@@ -377,3 +476,176 @@ func (s *cartService) CleanupExpiredCarts(ctx context.Context) error {
 	utils.Logger.Info(ctx, "Cleaned up expired carts", nil)
 	return nil
 }
+
+// ApplyCoupon adds a coupon code to the cart. The code is rejected if it
+// does not apply to the cart as it stands.
+func (s *cartService) ApplyCoupon(ctx context.Context, userID, sessionID, code string) (*models.Cart, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("invalid coupon: code is required")
+	}
+	if s.promotionService == nil {
+		return nil, fmt.Errorf("coupons are not available")
+	}
+
+	cart, err := s.loadCart(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if couponIndex(cart, code) < 0 {
+		quote, err := s.promotionService.Quote(ctx, newPromotionQuoteRequest(cart, append(cart.CouponCodes, code)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check coupon: %w", err)
+		}
+		for _, rejection := range quote.Rejected {
+			if strings.EqualFold(rejection.Code, code) {
+				return nil, fmt.Errorf("invalid coupon %s: %s", code, rejection.Reason)
+			}
+		}
+
+		cart.CouponCodes = append(cart.CouponCodes, code)
+		cart.UpdatedAt = time.Now()
+		if err := s.cartRepo.SaveCart(ctx, cart); err != nil {
+			return nil, fmt.Errorf("failed to save cart: %w", err)
+		}
+
+		utils.Logger.Info(ctx, "Applied coupon to cart", map[string]interface{}{
+			"cart_id":    cart.ID,
+			"code":       code,
+			"user_id":    userID,
+			"session_id": sessionID,
+		})
+	}
+
+	s.applyDiscounts(ctx, cart)
+	return cart, nil
+}
+
+// RemoveCoupon removes a coupon code from the cart
+func (s *cartService) RemoveCoupon(ctx context.Context, userID, sessionID, code string) (*models.Cart, error) {
+	cart, err := s.loadCart(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	i := couponIndex(cart, code)
+	if i < 0 {
+		return nil, fmt.Errorf("coupon not found in cart")
+	}
+	cart.CouponCodes = append(cart.CouponCodes[:i], cart.CouponCodes[i+1:]...)
+	cart.UpdatedAt = time.Now()
+
+	if err := s.cartRepo.SaveCart(ctx, cart); err != nil {
+		return nil, fmt.Errorf("failed to save cart: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Removed coupon from cart", map[string]interface{}{
+		"cart_id":    cart.ID,
+		"code":       code,
+		"user_id":    userID,
+		"session_id": sessionID,
+	})
+
+	s.applyDiscounts(ctx, cart)
+	return cart, nil
+}
+
+// SetCurrency changes the currency the cart is shown in, converting the
+// prices of the items already in it. Checkout prices the order from the
+// catalog in the same currency.
+func (s *cartService) SetCurrency(ctx context.Context, userID, sessionID, code string) (*models.Cart, error) {
+	target, err := currency.Lookup(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid currency: %w", err)
+	}
+
+	cart, err := s.loadCart(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.Currency != target.Code {
+		if len(cart.Items) > 0 {
+			if s.rates == nil {
+				return nil, fmt.Errorf("invalid currency: no exchange rates configured to convert %s to %s", cart.Currency, target.Code)
+			}
+			conversion, err := s.rates.Rate(cart.Currency, target.Code)
+			if err != nil {
+				return nil, fmt.Errorf("invalid currency: %w", err)
+			}
+			for i, item := range cart.Items {
+				cart.Items[i].Price = conversion.Apply(item.Price)
+				cart.Items[i].Total = cart.Items[i].Price.Mul(decimal.NewFromInt(int64(item.Quantity)))
+			}
+		}
+
+		previous := cart.Currency
+		cart.Currency = target.Code
+		cart.CalculateSubtotal()
+		cart.UpdatedAt = time.Now()
+		if err := s.cartRepo.SaveCart(ctx, cart); err != nil {
+			return nil, fmt.Errorf("failed to save cart: %w", err)
+		}
+
+		utils.Logger.Info(ctx, "Changed cart currency", map[string]interface{}{
+			"cart_id":    cart.ID,
+			"from":       previous,
+			"to":         cart.Currency,
+			"user_id":    userID,
+			"session_id": sessionID,
+		})
+	}
+
+	s.applyDiscounts(ctx, cart)
+	return cart, nil
+}
+
+// applyDiscounts prices the cart against the current promotions. Discounts
+// are not saved with the cart since promotions change independently of it;
+// if they cannot be quoted the cart is shown without them.
+func (s *cartService) applyDiscounts(ctx context.Context, cart *models.Cart) {
+	cart.Discount = decimal.Zero
+	cart.Discounts = nil
+	if s.promotionService == nil || len(cart.Items) == 0 {
+		return
+	}
+
+	quote, err := s.promotionService.Quote(ctx, newPromotionQuoteRequest(cart, cart.CouponCodes))
+	if err != nil {
+		utils.Logger.Warn(ctx, "Failed to quote cart discounts", map[string]interface{}{
+			"cart_id": cart.ID,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	cart.Discount = quote.Discount
+	cart.Discounts = quote.Discounts
+}
+
+func newPromotionQuoteRequest(cart *models.Cart, coupons []string) *PromotionQuoteRequest {
+	req := &PromotionQuoteRequest{
+		UserID:      cart.UserID,
+		CouponCodes: coupons,
+		Items:       make([]PromotionQuoteItem, 0, len(cart.Items)),
+		Currency:    cart.Currency,
+	}
+	for _, item := range cart.Items {
+		req.Items = append(req.Items, PromotionQuoteItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+		})
+	}
+	return req
+}
+
+func couponIndex(cart *models.Cart, code string) int {
+	for i, applied := range cart.CouponCodes {
+		if strings.EqualFold(applied, strings.TrimSpace(code)) {
+			return i
+		}
+	}
+	return -1
+}
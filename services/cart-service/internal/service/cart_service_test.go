@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopspring/decimal"
 )
@@ -152,7 +155,7 @@ func (m *MockCartRepository) MigrateGuestCartToUser(ctx context.Context, session
 func TestCartService_GetCart(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	// Test getting a new cart
 	cart, err := service.GetCart(ctx, "user1", "")
@@ -179,12 +182,12 @@ func TestCartService_GetCart(t *testing.T) {
 func TestCartService_AddItem(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	price := decimal.NewFromFloat(19.99)
 	
 	// Test adding item to new cart
-	cart, err := service.AddItem(ctx, "user1", "", "prod1", "SKU1", "Product 1", price, 2)
+	cart, err := service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", price, 2)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -207,7 +210,7 @@ func TestCartService_AddItem(t *testing.T) {
 	}
 	
 	// Test adding same item again (should update quantity)
-	cart, err = service.AddItem(ctx, "user1", "", "prod1", "SKU1", "Product 1", price, 1)
+	cart, err = service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", price, 1)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -224,18 +227,18 @@ func TestCartService_AddItem(t *testing.T) {
 func TestCartService_UpdateItem(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	price := decimal.NewFromFloat(19.99)
 	
 	// Add item first
-	cart, err := service.AddItem(ctx, "user1", "", "prod1", "SKU1", "Product 1", price, 2)
+	cart, err := service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", price, 2)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 	
 	// Update quantity
-	cart, err = service.UpdateItem(ctx, "user1", "", "prod1", 5)
+	cart, err = service.UpdateItem(ctx, "user1", "", "prod1", "", 5)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -245,7 +248,7 @@ func TestCartService_UpdateItem(t *testing.T) {
 	}
 	
 	// Test removing item by setting quantity to 0
-	cart, err = service.UpdateItem(ctx, "user1", "", "prod1", 0)
+	cart, err = service.UpdateItem(ctx, "user1", "", "prod1", "", 0)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -258,18 +261,18 @@ func TestCartService_UpdateItem(t *testing.T) {
 func TestCartService_RemoveItem(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	price := decimal.NewFromFloat(19.99)
 	
 	// Add item first
-	cart, err := service.AddItem(ctx, "user1", "", "prod1", "SKU1", "Product 1", price, 2)
+	cart, err := service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", price, 2)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 	
 	// Remove item
-	cart, err = service.RemoveItem(ctx, "user1", "", "prod1")
+	cart, err = service.RemoveItem(ctx, "user1", "", "prod1", "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -279,22 +282,60 @@ func TestCartService_RemoveItem(t *testing.T) {
 	}
 	
 	// Test removing non-existent item
-	_, err = service.RemoveItem(ctx, "user1", "", "nonexistent")
+	_, err = service.RemoveItem(ctx, "user1", "", "nonexistent", "")
 	if err == nil {
 		t.Error("Expected error for non-existent item")
 	}
 }
 
+func TestCartService_VariantsAreSeparateLines(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockCartRepository()
+	service := NewCartService(repo, nil, nil, nil)
+
+	price := decimal.NewFromFloat(19.99)
+
+	service.AddItem(ctx, "user1", "", "prod1", "var-red", "SKU1-RED", "Product 1 - Red", price, 1)
+	cart, err := service.AddItem(ctx, "user1", "", "prod1", "var-blue", "SKU1-BLUE", "Product 1 - Blue", price, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(cart.Items))
+	}
+
+	cart, err = service.UpdateItem(ctx, "user1", "", "prod1", "var-blue", 5)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cart.Items[0].Quantity != 1 || cart.Items[1].Quantity != 5 {
+		t.Errorf("Expected only the blue line to change, got %d and %d", cart.Items[0].Quantity, cart.Items[1].Quantity)
+	}
+
+	// The product without a variant is not in the cart
+	if _, err := service.RemoveItem(ctx, "user1", "", "prod1", ""); err == nil {
+		t.Error("Expected error for removing the product without a variant")
+	}
+
+	cart, err = service.RemoveItem(ctx, "user1", "", "prod1", "var-red")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].VariantID != "var-blue" {
+		t.Errorf("Expected only the blue line to remain, got %+v", cart.Items)
+	}
+}
+
 func TestCartService_ClearCart(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	price := decimal.NewFromFloat(19.99)
 	
 	// Add items first
-	service.AddItem(ctx, "user1", "", "prod1", "SKU1", "Product 1", price, 2)
-	service.AddItem(ctx, "user1", "", "prod2", "SKU2", "Product 2", price, 1)
+	service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", price, 2)
+	service.AddItem(ctx, "user1", "", "prod2", "", "SKU2", "Product 2", price, 1)
 	
 	// Clear cart
 	err := service.ClearCart(ctx, "user1", "")
@@ -320,12 +361,12 @@ func TestCartService_ClearCart(t *testing.T) {
 func TestCartService_MigrateGuestCart(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	price := decimal.NewFromFloat(19.99)
 	
 	// Add item to guest cart
-	service.AddItem(ctx, "", "session1", "prod1", "SKU1", "Product 1", price, 2)
+	service.AddItem(ctx, "", "session1", "prod1", "", "SKU1", "Product 1", price, 2)
 	
 	// Migrate to user cart
 	cart, err := service.MigrateGuestCart(ctx, "session1", "user1")
@@ -355,7 +396,7 @@ func TestCartService_MigrateGuestCart(t *testing.T) {
 func TestCartService_ExtendCartExpiry(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockCartRepository()
-	service := NewCartService(repo, nil)
+	service := NewCartService(repo, nil, nil, nil)
 	
 	// Create cart
 	cart, err := service.GetCart(ctx, "user1", "")
@@ -376,3 +417,131 @@ func TestCartService_ExtendCartExpiry(t *testing.T) {
 		t.Errorf("Expected expiry to be extended by at least 1 hour, got %v", cart.ExpiresAt.Sub(originalExpiry))
 	}
 }
+
+// MockPromotionService implements PromotionService for testing: SAVE10 takes
+// 10% off, every other code is rejected
+type MockPromotionService struct {
+	err error
+}
+
+func (m *MockPromotionService) Quote(ctx context.Context, req *PromotionQuoteRequest) (*PromotionQuote, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	quote := &PromotionQuote{}
+	for _, code := range req.CouponCodes {
+		if !strings.EqualFold(code, "SAVE10") {
+			quote.Rejected = append(quote.Rejected, CouponRejection{Code: code, Reason: "coupon does not exist"})
+			continue
+		}
+		subtotal := decimal.Zero
+		for _, item := range req.Items {
+			subtotal = subtotal.Add(item.UnitPrice.Mul(decimal.NewFromInt(int64(item.Quantity))))
+		}
+		discount := subtotal.Div(decimal.NewFromInt(10)).Round(2)
+		quote.Discount = quote.Discount.Add(discount)
+		quote.Discounts = append(quote.Discounts, models.OrderDiscount{Code: "SAVE10", Amount: discount})
+	}
+	return quote, nil
+}
+
+func TestCartService_ApplyCoupon(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockCartRepository()
+	promotions := &MockPromotionService{}
+	service := NewCartService(repo, nil, promotions, nil)
+
+	if _, err := service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", decimal.NewFromFloat(50), 2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cart, err := service.ApplyCoupon(ctx, "user1", "", "save10")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.CouponCodes) != 1 || !cart.Discount.Equal(decimal.NewFromFloat(10)) {
+		t.Errorf("Expected one coupon worth 10.00, got %v worth %s", cart.CouponCodes, cart.Discount)
+	}
+
+	// Discounts follow the cart contents
+	cart, _ = service.UpdateItem(ctx, "user1", "", "prod1", "", 4)
+	if !cart.Discount.Equal(decimal.NewFromFloat(20)) {
+		t.Errorf("Expected discount 20.00 after update, got %s", cart.Discount)
+	}
+
+	// Applying the same code again is a no-op
+	cart, _ = service.ApplyCoupon(ctx, "user1", "", "SAVE10")
+	if len(cart.CouponCodes) != 1 {
+		t.Errorf("Expected coupon to be applied once, got %v", cart.CouponCodes)
+	}
+
+	if _, err := service.ApplyCoupon(ctx, "user1", "", "BOGUS"); err == nil || !strings.Contains(err.Error(), "invalid coupon") {
+		t.Errorf("Expected invalid coupon error, got %v", err)
+	}
+
+	// A quote failure shows the cart without discounts rather than failing
+	promotions.err = errors.New("order-service unavailable")
+	cart, err = service.GetCart(ctx, "user1", "")
+	if err != nil || !cart.Discount.IsZero() || len(cart.CouponCodes) != 1 {
+		t.Errorf("Expected undiscounted cart keeping its coupon, got %v discount %s coupons %v", err, cart.Discount, cart.CouponCodes)
+	}
+}
+
+func TestCartService_RemoveCoupon(t *testing.T) {
+	ctx := context.Background()
+	service := NewCartService(NewMockCartRepository(), nil, &MockPromotionService{}, nil)
+
+	service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", decimal.NewFromFloat(50), 1)
+	service.ApplyCoupon(ctx, "user1", "", "SAVE10")
+
+	cart, err := service.RemoveCoupon(ctx, "user1", "", "save10")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cart.CouponCodes) != 0 || !cart.Discount.IsZero() {
+		t.Errorf("Expected no coupons or discount, got %v and %s", cart.CouponCodes, cart.Discount)
+	}
+
+	if _, err := service.RemoveCoupon(ctx, "user1", "", "SAVE10"); err == nil {
+		t.Error("Expected error removing a coupon that is not applied")
+	}
+}
+
+func TestCartService_SetCurrency(t *testing.T) {
+	ctx := context.Background()
+	rates, err := currency.NewRates("USD", time.Now(), map[string]decimal.Decimal{
+		"JPY": decimal.RequireFromString("150.3"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rates: %v", err)
+	}
+	service := NewCartService(NewMockCartRepository(), nil, nil, rates)
+
+	service.AddItem(ctx, "user1", "", "prod1", "", "SKU1", "Product 1", decimal.RequireFromString("19.99"), 2)
+
+	cart, err := service.SetCurrency(ctx, "user1", "", "jpy")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cart.Currency != "JPY" {
+		t.Errorf("Expected currency JPY, got %s", cart.Currency)
+	}
+	// 19.99 * 150.3 = 3004.497, rounded to whole yen
+	if !cart.Items[0].Price.Equal(decimal.NewFromInt(3004)) || !cart.Subtotal.Equal(decimal.NewFromInt(6008)) {
+		t.Errorf("Expected 3004 JPY per item and subtotal 6008, got %s and %s", cart.Items[0].Price, cart.Subtotal)
+	}
+
+	if _, err := service.SetCurrency(ctx, "user1", "", "EUR"); err == nil || !strings.Contains(err.Error(), "no exchange rate") {
+		t.Errorf("Expected missing rate error, got %v", err)
+	}
+	if _, err := service.SetCurrency(ctx, "user1", "", "XYZ"); err == nil || !strings.Contains(err.Error(), "invalid currency") {
+		t.Errorf("Expected invalid currency error, got %v", err)
+	}
+
+	// Empty carts can switch currency without rates
+	service = NewCartService(NewMockCartRepository(), nil, nil, nil)
+	if cart, err := service.SetCurrency(ctx, "user2", "", "EUR"); err != nil || cart.Currency != "EUR" {
+		t.Errorf("Expected empty cart in EUR, got %v", err)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopsphere/cart-service/internal/clients"
 	"github.com/shopsphere/cart-service/internal/handlers"
 	"github.com/shopsphere/cart-service/internal/repository"
 	"github.com/shopsphere/cart-service/internal/service"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/utils"
 )
 
@@ -39,7 +41,21 @@ func main() {
 
 	// Initialize services
 	// Note: ProductService is nil for now - can be integrated later for validation
-	cartService := service.NewCartService(cartRepo, nil)
+	// Promotions and coupons are owned by order-service
+	orderServiceURL := os.Getenv("ORDER_SERVICE_URL")
+	if orderServiceURL == "" {
+		orderServiceURL = "http://localhost:8005"
+	}
+	// Exchange rates convert carts between display currencies
+	var rates service.ExchangeRates
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		table, err := currency.LoadRatesFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+		rates = table
+	}
+	cartService := service.NewCartService(cartRepo, nil, clients.NewPromotionClient(orderServiceURL), rates)
 
 	// Initialize handlers
 	cartHandler := handlers.NewCartHandler(cartService)
@@ -65,6 +81,9 @@ func main() {
 	cartRoutes.HandleFunc("/validate", cartHandler.ValidateCart).Methods("GET")
 	cartRoutes.HandleFunc("/extend-expiry", cartHandler.ExtendExpiry).Methods("POST")
 	cartRoutes.HandleFunc("/summary", cartHandler.GetCartSummary).Methods("GET")
+	cartRoutes.HandleFunc("/coupons", cartHandler.ApplyCoupon).Methods("POST")
+	cartRoutes.HandleFunc("/coupons/{code}", cartHandler.RemoveCoupon).Methods("DELETE")
+	cartRoutes.HandleFunc("/currency", cartHandler.SetCurrency).Methods("PUT")
 
 	// Admin routes
 	adminRoutes := router.PathPrefix("/admin").Subrouter()
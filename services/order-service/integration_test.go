@@ -76,7 +76,7 @@ func TestOrderServiceIntegration(t *testing.T) {
 	repo := repository.NewPostgresOrderRepository(db)
 	productService := &MockProductService{}
 	inventoryService := &MockInventoryService{}
-	orderService := service.NewOrderService(repo, productService, inventoryService)
+	orderService := service.NewOrderService(repo, productService, inventoryService, nil, nil, nil, nil)
 	handler := handlers.NewOrderHandler(orderService)
 
 	// Setup router
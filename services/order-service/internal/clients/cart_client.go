@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+)
+
+// CartClient talks to cart-service over HTTP and implements the checkout
+// saga's CartService interface
+type CartClient struct {
+	client *serviceClient
+}
+
+// NewCartClient creates a new cart-service client
+func NewCartClient(config Config) *CartClient {
+	return &CartClient{client: newServiceClient("cart-service", config)}
+}
+
+// GetCart fetches the caller's cart from GET /cart
+func (c *CartClient) GetCart(ctx context.Context, userID, sessionID string) (*models.Cart, error) {
+	var cart models.Cart
+	if err := c.client.do(ctx, http.MethodGet, "/cart", cartHeader(userID, sessionID), nil, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// ValidateCart checks the caller's cart via GET /cart/validate
+func (c *CartClient) ValidateCart(ctx context.Context, userID, sessionID string) (*service.CartValidation, error) {
+	var validation service.CartValidation
+	if err := c.client.do(ctx, http.MethodGet, "/cart/validate", cartHeader(userID, sessionID), nil, &validation); err != nil {
+		return nil, err
+	}
+	return &validation, nil
+}
+
+// ClearCart empties the caller's cart via POST /cart/clear
+func (c *CartClient) ClearCart(ctx context.Context, userID, sessionID string) error {
+	return c.client.do(ctx, http.MethodPost, "/cart/clear", cartHeader(userID, sessionID), nil, nil)
+}
+
+// cartHeader identifies the cart owner the way cart-service expects
+func cartHeader(userID, sessionID string) http.Header {
+	header := http.Header{}
+	if userID != "" {
+		header.Set("X-User-ID", userID)
+	}
+	if sessionID != "" {
+		header.Set("X-Session-ID", sessionID)
+	}
+	return header
+}
@@ -0,0 +1,159 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestCartClient_SendsCartOwnerHeaders(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-User-ID") != "user-1" || r.Header.Get("X-Session-ID") != "session-1" {
+			writeError(w, http.StatusBadRequest, "USER_ID_OR_SESSION_REQUIRED", "Either user ID or session ID is required")
+			return
+		}
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/cart":
+			writeJSON(w, http.StatusOK, models.Cart{ID: "cart-1", Items: []models.CartItem{{ProductID: "product-1", Quantity: 2}}})
+		case "/cart/validate":
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"is_valid":          false,
+				"unavailable_items": []map[string]string{{"product_id": "product-1", "reason": "Product no longer available"}},
+			})
+		default:
+			writeJSON(w, http.StatusOK, map[string]string{"message": "Cart cleared successfully"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewCartClient(Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	cart, err := client.GetCart(ctx, "user-1", "session-1")
+	if err != nil || cart.ID != "cart-1" || len(cart.Items) != 1 {
+		t.Fatalf("Unexpected cart %+v, error %v", cart, err)
+	}
+	validation, err := client.ValidateCart(ctx, "user-1", "session-1")
+	if err != nil || validation.IsValid || len(validation.UnavailableItems) != 1 {
+		t.Fatalf("Unexpected validation %+v, error %v", validation, err)
+	}
+	if err := client.ClearCart(ctx, "user-1", "session-1"); err != nil {
+		t.Fatalf("ClearCart failed: %v", err)
+	}
+
+	expected := []string{"GET /cart", "GET /cart/validate", "POST /cart/clear"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected requests %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Request %d: expected %s, got %s", i, expected[i], paths[i])
+		}
+	}
+}
+
+func TestPaymentClient_Contract(t *testing.T) {
+	var refund map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /payments":
+			var req service.PaymentRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			writeJSON(w, http.StatusCreated, models.Payment{ID: "payment-1", OrderID: req.OrderID, Amount: req.Amount, Status: models.PaymentPending})
+		case "POST /payments/payment-1/process":
+			writeJSON(w, http.StatusOK, models.Payment{ID: "payment-1", Status: models.PaymentCompleted})
+		case "POST /refunds":
+			json.NewDecoder(r.Body).Decode(&refund)
+			writeJSON(w, http.StatusCreated, map[string]string{"id": "refund-1"})
+		default:
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+		}
+	}))
+	defer server.Close()
+
+	client := NewPaymentClient(Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	payment, err := client.CreatePayment(ctx, &service.PaymentRequest{OrderID: "order-1", Amount: decimal.NewFromFloat(25.50), Currency: "USD"})
+	if err != nil || payment.ID != "payment-1" || payment.OrderID != "order-1" || !payment.Amount.Equal(decimal.NewFromFloat(25.50)) {
+		t.Fatalf("Unexpected payment %+v, error %v", payment, err)
+	}
+	if payment, err = client.ProcessPayment(ctx, "payment-1"); err != nil || payment.Status != models.PaymentCompleted {
+		t.Fatalf("Unexpected processed payment %+v, error %v", payment, err)
+	}
+	if err := client.RefundPayment(ctx, "payment-1", "Checkout failed"); err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund["payment_id"] != "payment-1" || refund["reason"] != "Checkout failed" {
+		t.Errorf("Unexpected refund request: %v", refund)
+	}
+}
+
+func TestPaymentClient_CreateRefund(t *testing.T) {
+	var req service.RefundRequest
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method+" "+r.URL.Path != "POST /refunds" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+			return
+		}
+		key = r.Header.Get("Idempotency-Key")
+		json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusCreated, models.Refund{ID: "refund-1", PaymentID: req.PaymentID, Amount: req.Amount})
+	}))
+	defer server.Close()
+
+	client := NewPaymentClient(Config{BaseURL: server.URL})
+	refund, err := client.CreateRefund(context.Background(), &service.RefundRequest{
+		IdempotencyKey: "return-refund-ret-1",
+		PaymentID:      "payment-1",
+		Amount:         decimal.NewFromFloat(21.60),
+		Reason:         "Return RMA-1",
+	})
+	if err != nil || refund.ID != "refund-1" || !refund.Amount.Equal(decimal.NewFromFloat(21.60)) {
+		t.Fatalf("Unexpected refund %+v, error %v", refund, err)
+	}
+	if req.PaymentID != "payment-1" || !req.Amount.Equal(decimal.NewFromFloat(21.60)) || req.Reason != "Return RMA-1" {
+		t.Errorf("Unexpected refund request: %+v", req)
+	}
+	if key != "return-refund-ret-1" {
+		t.Errorf("Expected the idempotency key to be sent, got %q", key)
+	}
+}
+
+func TestNotificationClient_SendNotification(t *testing.T) {
+	var req models.NotificationRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method+" "+r.URL.Path != "POST /notifications" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusCreated, map[string]string{"id": "notification-1"})
+	}))
+	defer server.Close()
+
+	subject, body := "Payment failed", "We will try again"
+	client := NewNotificationClient(Config{BaseURL: server.URL})
+	err := client.SendNotification(context.Background(), &models.NotificationRequest{
+		UserID:    "user-1",
+		Channel:   models.ChannelEmail,
+		Recipient: "user@example.com",
+		Subject:   &subject,
+		Body:      &body,
+	})
+	if err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+	if req.UserID != "user-1" || req.Channel != models.ChannelEmail || req.Recipient != "user@example.com" || req.Body == nil || *req.Body != body {
+		t.Errorf("Unexpected notification request: %+v", req)
+	}
+}
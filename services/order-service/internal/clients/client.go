@@ -0,0 +1,204 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/shopsphere/shared/middleware"
+	"github.com/shopsphere/shared/utils"
+)
+
+// serviceName identifies order-service to the services it calls
+const serviceName = "order-service"
+
+// Config holds configuration for a downstream service HTTP client
+type Config struct {
+	BaseURL      string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// DefaultConfig returns a client configuration with sensible defaults
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:      baseURL,
+		Timeout:      5 * time.Second,
+		MaxRetries:   2,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+// APIError is a non-2xx response from a downstream service
+type APIError struct {
+	Service    string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Service, e.Message)
+}
+
+// errorResponse mirrors the shared utils.ErrorResponse body
+type errorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// serviceClient is the transport shared by the service clients: JSON over
+// HTTP behind a circuit breaker, with bounded retries for transient failures.
+type serviceClient struct {
+	service      string
+	baseURL      string
+	httpClient   *http.Client
+	breaker      *utils.CircuitBreaker
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func newServiceClient(service string, config Config) *serviceClient {
+	defaults := DefaultConfig(config.BaseURL)
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaults.RetryBackoff
+	}
+
+	breakerConfig := utils.DefaultCircuitBreakerConfig(service)
+	// 4xx responses mean the service is healthy and said no
+	breakerConfig.IsSuccessful = func(err error) bool {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return apiErr.StatusCode < http.StatusInternalServerError
+		}
+		return err == nil
+	}
+
+	return &serviceClient{
+		service:      service,
+		baseURL:      config.BaseURL,
+		httpClient:   &http.Client{Timeout: config.Timeout},
+		breaker:      utils.NewCircuitBreaker(breakerConfig),
+		maxRetries:   config.MaxRetries,
+		retryBackoff: config.RetryBackoff,
+	}
+}
+
+// do sends a request through the circuit breaker, retrying transient failures
+func (c *serviceClient) do(ctx context.Context, method, path string, header http.Header, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryBackoff * time.Duration(1<<(attempt-1))):
+			}
+		}
+
+		_, err = c.breaker.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+			return nil, c.send(ctx, method, path, header, payload, result)
+		})
+		if err == nil || !isRetryable(method, err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *serviceClient) send(ctx context.Context, method, path string, header http.Header, payload []byte, result interface{}) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if traceID := utils.GetTraceID(ctx); traceID != "" {
+		req.Header.Set("X-Trace-ID", traceID)
+	}
+	req.Header.Set(middleware.ServiceNameHeader, serviceName)
+	if userID := utils.GetUserID(ctx); userID != "" {
+		req.Header.Set(middleware.UserIDHeader, userID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{Service: c.service, StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var errBody errorResponse
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil && errBody.Error.Message != "" {
+			apiErr.Code = errBody.Error.Code
+			apiErr.Message = errBody.Error.Message
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", c.service, err)
+		}
+	}
+	return nil
+}
+
+// isRetryable decides whether a failed call may be repeated. Reads are retried
+// on any transport error or 5xx. Writes are not idempotent, so they are only
+// retried when the request provably never reached the service; a 502, 503 or
+// 504 may come from a proxy after the service already applied the write.
+func isRetryable(method string, err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return method == http.MethodGet && apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	// Open circuit breaker or other transport failures
+	return method == http.MethodGet
+}
@@ -0,0 +1,24 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// NotificationClient talks to notification-service over HTTP and implements
+// the subscription service's Notifier interface
+type NotificationClient struct {
+	client *serviceClient
+}
+
+// NewNotificationClient creates a new notification-service client
+func NewNotificationClient(config Config) *NotificationClient {
+	return &NotificationClient{client: newServiceClient("notification-service", config)}
+}
+
+// SendNotification sends a notification via POST /notifications
+func (c *NotificationClient) SendNotification(ctx context.Context, req *models.NotificationRequest) error {
+	return c.client.do(ctx, http.MethodPost, "/notifications", nil, req, nil)
+}
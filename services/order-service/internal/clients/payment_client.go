@@ -0,0 +1,92 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/middleware"
+	"github.com/shopsphere/shared/models"
+)
+
+// PaymentClient talks to payment-service over HTTP and implements the
+// checkout saga's PaymentService interface
+type PaymentClient struct {
+	client *serviceClient
+}
+
+// NewPaymentClient creates a new payment-service client
+func NewPaymentClient(config Config) *PaymentClient {
+	return &PaymentClient{client: newServiceClient("payment-service", config)}
+}
+
+// CreatePayment creates a payment via POST /payments, sending the request's
+// idempotency key so payment-service creates it once
+func (c *PaymentClient) CreatePayment(ctx context.Context, req *service.PaymentRequest) (*models.Payment, error) {
+	var payment models.Payment
+	if err := c.client.do(ctx, http.MethodPost, "/payments", idempotencyHeader(req.IdempotencyKey), req, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// ProcessPayment charges a payment via POST /payments/{id}/process
+func (c *PaymentClient) ProcessPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	path := "/payments/" + url.PathEscape(paymentID) + "/process"
+	if err := c.client.do(ctx, http.MethodPost, path, nil, nil, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetPayment fetches a payment from GET /payments/{id}
+func (c *PaymentClient) GetPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := c.client.do(ctx, http.MethodGet, "/payments/"+url.PathEscape(paymentID), nil, nil, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// CancelPayment cancels an uncaptured payment via POST /payments/{id}/cancel
+func (c *PaymentClient) CancelPayment(ctx context.Context, paymentID, reason string) error {
+	body := map[string]string{"reason": reason}
+	return c.client.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(paymentID)+"/cancel", nil, body, nil)
+}
+
+// RefundPayment refunds a captured payment in full via POST /refunds
+func (c *PaymentClient) RefundPayment(ctx context.Context, paymentID, reason string) error {
+	body := map[string]string{"payment_id": paymentID, "reason": reason}
+	return c.client.do(ctx, http.MethodPost, "/refunds", nil, body, nil)
+}
+
+// CreateRefund refunds part or all of a captured payment via POST /refunds,
+// sending the request's idempotency key so payment-service issues it once
+func (c *PaymentClient) CreateRefund(ctx context.Context, req *service.RefundRequest) (*models.Refund, error) {
+	var refund models.Refund
+	if err := c.client.do(ctx, http.MethodPost, "/refunds", idempotencyHeader(req.IdempotencyKey), req, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// GetPaymentMethod fetches a saved payment method from GET /payment-methods/{id}
+func (c *PaymentClient) GetPaymentMethod(ctx context.Context, id string) (*models.PaymentMethodInfo, error) {
+	var method models.PaymentMethodInfo
+	if err := c.client.do(ctx, http.MethodGet, "/payment-methods/"+url.PathEscape(id), nil, nil, &method); err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// idempotencyHeader carries an idempotency key, if there is one
+func idempotencyHeader(key string) http.Header {
+	if key == "" {
+		return nil
+	}
+	header := http.Header{}
+	header.Set(middleware.IdempotencyKeyHeader, key)
+	return header
+}
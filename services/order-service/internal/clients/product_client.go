@@ -0,0 +1,200 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// orderReservationTTL is how long product-service holds an order's stock.
+// Orders usually ship well within it; committing a reservation that has
+// expired still takes its units out of stock.
+const orderReservationTTL = 7 * 24 * time.Hour
+
+type stockReservationRequest struct {
+	ProductID     string `json:"product_id"`
+	VariantID     string `json:"variant_id,omitempty"`
+	Quantity      int    `json:"quantity"`
+	ReferenceType string `json:"reference_type,omitempty"`
+	ReferenceID   string `json:"reference_id,omitempty"`
+	TTLSeconds    int    `json:"ttl_seconds,omitempty"`
+}
+
+type stockAllocationRequest struct {
+	ReferenceType   string         `json:"reference_type"`
+	ReferenceID     string         `json:"reference_id"`
+	ShippingAddress models.Address `json:"shipping_address"`
+}
+
+type stockUpdateRequest struct {
+	ProductID     string `json:"product_id"`
+	VariantID     string `json:"variant_id,omitempty"`
+	Quantity      int    `json:"quantity"`
+	Type          string `json:"type"`
+	Reason        string `json:"reason"`
+	ReferenceType string `json:"reference_type,omitempty"`
+	ReferenceID   string `json:"reference_id,omitempty"`
+}
+
+// ProductClient talks to product-service over HTTP. It implements both the
+// order service's ProductService and InventoryService interfaces.
+type ProductClient struct {
+	client *serviceClient
+}
+
+// NewProductClient creates a new product-service client
+func NewProductClient(config Config) *ProductClient {
+	return &ProductClient{client: newServiceClient("product-service", config)}
+}
+
+// GetProduct fetches a product from GET /products/{id}
+func (c *ProductClient) GetProduct(ctx context.Context, id string) (*models.Product, error) {
+	var product models.Product
+	if err := c.client.do(ctx, http.MethodGet, "/products/"+url.PathEscape(id), nil, nil, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetVariant fetches a product variant from GET /products/{id}/variants/{variantId}
+func (c *ProductClient) GetVariant(ctx context.Context, productID, variantID string) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	path := "/products/" + url.PathEscape(productID) + "/variants/" + url.PathEscape(variantID)
+	if err := c.client.do(ctx, http.MethodGet, path, nil, nil, &variant); err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// ValidateStock checks that the product has at least quantity units in stock
+// that are not reserved. It is advisory only; ReserveStock is what actually
+// claims the units.
+func (c *ProductClient) ValidateStock(ctx context.Context, productID string, quantity int) error {
+	product, err := c.GetProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if available := product.AvailableStock(); available < quantity {
+		return fmt.Errorf("insufficient stock for product %s: requested %d, available %d", productID, quantity, available)
+	}
+	return nil
+}
+
+// ReserveStock reserves every item via POST /products/{id}/reserve-stock,
+// against the item's variant when it has one. Items of an order are held as
+// reservations for the order, so reserving them again does not hold them twice.
+// If any reservation fails, the ones already made are released again.
+func (c *ProductClient) ReserveStock(ctx context.Context, items []models.OrderItem) error {
+	items = mergeReservationItems(items)
+	for i, item := range items {
+		if err := c.reserve(ctx, item); err != nil {
+			if releaseErr := c.ReleaseStock(ctx, items[:i]); releaseErr != nil {
+				utils.Logger.Error(ctx, "Failed to release partially reserved stock", releaseErr)
+			}
+			return fmt.Errorf("failed to reserve stock for product %s: %w", item.ProductID, err)
+		}
+	}
+	return nil
+}
+
+// ReleaseStock releases every item via POST /products/{id}/release-stock.
+// It keeps going after a failure so one bad item does not strand the rest.
+func (c *ProductClient) ReleaseStock(ctx context.Context, items []models.OrderItem) error {
+	var errs []error
+	for _, item := range mergeReservationItems(items) {
+		path := "/products/" + url.PathEscape(item.ProductID) + "/release-stock"
+		if err := c.client.do(ctx, http.MethodPost, path, nil, reservationRequest(item), nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release stock for product %s: %w", item.ProductID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CommitStock takes shipped items out of stock via POST /products/{id}/commit-stock,
+// using up the units their order reserved. Like ReleaseStock it keeps going
+// after a failure.
+func (c *ProductClient) CommitStock(ctx context.Context, items []models.OrderItem) error {
+	var errs []error
+	for _, item := range mergeReservationItems(items) {
+		path := "/products/" + url.PathEscape(item.ProductID) + "/commit-stock"
+		if err := c.client.do(ctx, http.MethodPost, path, nil, reservationRequest(item), nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to commit stock for product %s: %w", item.ProductID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AllocateStock allocates the stock reserved for an order to the warehouses
+// that will ship it via POST /inventory/allocations, using product-service's
+// configured strategy
+func (c *ProductClient) AllocateStock(ctx context.Context, orderID string, shipTo models.Address) (*models.AllocationPlan, error) {
+	body := stockAllocationRequest{ReferenceType: "order", ReferenceID: orderID, ShippingAddress: shipTo}
+	var plan models.AllocationPlan
+	if err := c.client.do(ctx, http.MethodPost, "/inventory/allocations", nil, body, &plan); err != nil {
+		return nil, fmt.Errorf("failed to allocate stock for order %s: %w", orderID, err)
+	}
+	return &plan, nil
+}
+
+// RestockItems adds the items back to stock in one POST /products/bulk-stock-update,
+// which product-service applies atomically
+func (c *ProductClient) RestockItems(ctx context.Context, items []models.OrderItem, reason string) error {
+	updates := make([]stockUpdateRequest, 0, len(items))
+	for _, item := range items {
+		update := stockUpdateRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity, Type: "in", Reason: reason}
+		if item.OrderID != "" {
+			update.ReferenceType = "order"
+			update.ReferenceID = item.OrderID
+		}
+		updates = append(updates, update)
+	}
+	if err := c.client.do(ctx, http.MethodPost, "/products/bulk-stock-update", nil, updates, nil); err != nil {
+		return fmt.Errorf("failed to restock items: %w", err)
+	}
+	return nil
+}
+
+func (c *ProductClient) reserve(ctx context.Context, item models.OrderItem) error {
+	body := reservationRequest(item)
+	if body.ReferenceID != "" {
+		body.TTLSeconds = int(orderReservationTTL.Seconds())
+	}
+	path := "/products/" + url.PathEscape(item.ProductID) + "/reserve-stock"
+	return c.client.do(ctx, http.MethodPost, path, nil, body, nil)
+}
+
+// reservationRequest builds the stock request for an item, referencing its
+// order when it has one
+func reservationRequest(item models.OrderItem) stockReservationRequest {
+	body := stockReservationRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+	if item.OrderID != "" {
+		body.ReferenceType = "order"
+		body.ReferenceID = item.OrderID
+	}
+	return body
+}
+
+// mergeReservationItems adds up the quantities of items for the same order,
+// product and variant, since product-service keeps one reservation for them
+func mergeReservationItems(items []models.OrderItem) []models.OrderItem {
+	type itemKey struct{ orderID, productID, variantID string }
+
+	merged := make([]models.OrderItem, 0, len(items))
+	index := make(map[itemKey]int, len(items))
+	for _, item := range items {
+		key := itemKey{item.OrderID, item.ProductID, item.VariantID}
+		if i, ok := index[key]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
@@ -0,0 +1,486 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	_ service.ProductService   = (*ProductClient)(nil)
+	_ service.InventoryService = (*ProductClient)(nil)
+	_ service.RestockService   = (*ProductClient)(nil)
+	_ service.StockCommitter   = (*ProductClient)(nil)
+	_ service.CartService      = (*CartClient)(nil)
+	_ service.PaymentService   = (*PaymentClient)(nil)
+	_ service.RefundService    = (*PaymentClient)(nil)
+	_ service.ShippingService  = (*ShippingClient)(nil)
+)
+
+// fakeProductService mimics the product-service endpoints the client relies on
+type fakeProductService struct {
+	mutex    sync.Mutex
+	products map[string]*models.Product
+	requests []string
+	bodies   []stockReservationRequest
+	// failReserve makes reserve-stock for this product return 409
+	failReserve string
+}
+
+func newFakeProductService() *fakeProductService {
+	return &fakeProductService{
+		products: map[string]*models.Product{
+			"product-1": {ID: "product-1", SKU: "SKU-1", Name: "Widget", Price: decimal.NewFromFloat(19.99), Stock: 10},
+			"product-2": {ID: "product-2", SKU: "SKU-2", Name: "Gadget", Price: decimal.NewFromFloat(5.00), Stock: 1},
+		},
+	}
+}
+
+func (f *fakeProductService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "products" {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+		return
+	}
+	product, ok := f.products[parts[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "product not found")
+		return
+	}
+
+	if len(parts) == 2 && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, product)
+		return
+	}
+
+	var req stockReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProductID != product.ID || req.Quantity <= 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "invalid request body")
+		return
+	}
+	f.bodies = append(f.bodies, req)
+
+	switch parts[2] {
+	case "reserve-stock":
+		if product.ID == f.failReserve || product.Stock < req.Quantity {
+			writeError(w, http.StatusConflict, "CONFLICT", "insufficient stock available")
+			return
+		}
+		product.Stock -= req.Quantity
+	case "release-stock":
+		product.Stock += req.Quantity
+	case "commit-stock":
+		// Committed units were already taken out of the available stock
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (f *fakeProductService) stock(id string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.products[id].Stock
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":    map[string]string{"code": code, "message": message},
+		"trace_id": "test-trace",
+	})
+}
+
+func newTestClient(url string) *ProductClient {
+	return NewProductClient(Config{
+		BaseURL:      url,
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+}
+
+func TestProductClient_GetProduct(t *testing.T) {
+	fake := newFakeProductService()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	product, err := newTestClient(server.URL).GetProduct(context.Background(), "product-1")
+	if err != nil {
+		t.Fatalf("GetProduct failed: %v", err)
+	}
+	if product.ID != "product-1" || !product.Price.Equal(decimal.NewFromFloat(19.99)) || product.Stock != 10 {
+		t.Errorf("Unexpected product: %+v", product)
+	}
+}
+
+func TestProductClient_GetProduct_NotFound(t *testing.T) {
+	server := httptest.NewServer(newFakeProductService())
+	defer server.Close()
+
+	_, err := newTestClient(server.URL).GetProduct(context.Background(), "missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 APIError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected error to mention 'not found', got %q", err.Error())
+	}
+}
+
+func TestProductClient_ValidateStock(t *testing.T) {
+	fake := newFakeProductService()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newTestClient(server.URL)
+
+	if err := client.ValidateStock(context.Background(), "product-1", 10); err != nil {
+		t.Errorf("Expected stock to be sufficient, got %v", err)
+	}
+	err := client.ValidateStock(context.Background(), "product-2", 2)
+	if err == nil || !strings.Contains(err.Error(), "insufficient stock") {
+		t.Errorf("Expected insufficient stock error, got %v", err)
+	}
+
+	// Units held by other reservations are not available
+	fake.products["product-1"].ReservedStock = 8
+	err = client.ValidateStock(context.Background(), "product-1", 3)
+	if err == nil || !strings.Contains(err.Error(), "available 2") {
+		t.Errorf("Expected reserved stock to be excluded, got %v", err)
+	}
+}
+
+func TestProductClient_ReserveAndReleaseStock(t *testing.T) {
+	fake := newFakeProductService()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newTestClient(server.URL)
+
+	items := []models.OrderItem{
+		{ProductID: "product-1", Quantity: 3},
+		{ProductID: "product-2", Quantity: 1},
+	}
+	if err := client.ReserveStock(context.Background(), items); err != nil {
+		t.Fatalf("ReserveStock failed: %v", err)
+	}
+	if fake.stock("product-1") != 7 || fake.stock("product-2") != 0 {
+		t.Errorf("Unexpected stock after reserve: %d, %d", fake.stock("product-1"), fake.stock("product-2"))
+	}
+
+	if err := client.ReleaseStock(context.Background(), items); err != nil {
+		t.Fatalf("ReleaseStock failed: %v", err)
+	}
+	if fake.stock("product-1") != 10 || fake.stock("product-2") != 1 {
+		t.Errorf("Unexpected stock after release: %d, %d", fake.stock("product-1"), fake.stock("product-2"))
+	}
+
+	expected := []string{
+		"POST /products/product-1/reserve-stock",
+		"POST /products/product-2/reserve-stock",
+		"POST /products/product-1/release-stock",
+		"POST /products/product-2/release-stock",
+	}
+	if strings.Join(fake.requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected requests: %v", fake.requests)
+	}
+}
+
+func TestProductClient_ReserveAndCommitOrderStock(t *testing.T) {
+	fake := newFakeProductService()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newTestClient(server.URL)
+
+	// Two lines of the same product share the order's reservation
+	items := []models.OrderItem{
+		{OrderID: "order-1", ProductID: "product-1", Quantity: 2},
+		{OrderID: "order-1", ProductID: "product-1", Quantity: 1},
+	}
+	if err := client.ReserveStock(context.Background(), items); err != nil {
+		t.Fatalf("ReserveStock failed: %v", err)
+	}
+	if err := client.CommitStock(context.Background(), items); err != nil {
+		t.Fatalf("CommitStock failed: %v", err)
+	}
+
+	expected := []string{
+		"POST /products/product-1/reserve-stock",
+		"POST /products/product-1/commit-stock",
+	}
+	if strings.Join(fake.requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected requests: %v", fake.requests)
+	}
+	if fake.stock("product-1") != 7 {
+		t.Errorf("Expected 7 units available, got %d", fake.stock("product-1"))
+	}
+
+	reserve, commit := fake.bodies[0], fake.bodies[1]
+	if reserve.ReferenceType != "order" || reserve.ReferenceID != "order-1" || reserve.Quantity != 3 {
+		t.Errorf("Unexpected reserve request: %+v", reserve)
+	}
+	if reserve.TTLSeconds != int(orderReservationTTL.Seconds()) {
+		t.Errorf("Expected the order reservation TTL, got %d", reserve.TTLSeconds)
+	}
+	if commit.ReferenceID != "order-1" || commit.Quantity != 3 || commit.TTLSeconds != 0 {
+		t.Errorf("Unexpected commit request: %+v", commit)
+	}
+}
+
+func TestProductClient_RestockItems(t *testing.T) {
+	var updates []stockUpdateRequest
+	var caller string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/products/bulk-stock-update" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+			return
+		}
+		caller = r.Header.Get("X-Service-Name")
+		json.NewDecoder(r.Body).Decode(&updates)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL)
+
+	items := []models.OrderItem{
+		{OrderID: "order-1", ProductID: "product-1", VariantID: "variant-1", Quantity: 2},
+		{ProductID: "product-2", Quantity: 1},
+	}
+	if err := client.RestockItems(context.Background(), items, "Return RMA-1"); err != nil {
+		t.Fatalf("RestockItems failed: %v", err)
+	}
+
+	expected := []stockUpdateRequest{
+		{ProductID: "product-1", VariantID: "variant-1", Quantity: 2, Type: "in", Reason: "Return RMA-1", ReferenceType: "order", ReferenceID: "order-1"},
+		{ProductID: "product-2", Quantity: 1, Type: "in", Reason: "Return RMA-1"},
+	}
+	if len(updates) != len(expected) {
+		t.Fatalf("Expected updates %v, got %v", expected, updates)
+	}
+	for i := range expected {
+		if updates[i] != expected[i] {
+			t.Errorf("Update %d: expected %+v, got %+v", i, expected[i], updates[i])
+		}
+	}
+	if caller != "order-service" {
+		t.Errorf("Expected the update to be attributed to order-service, got %q", caller)
+	}
+}
+
+func TestProductClient_AllocateStock(t *testing.T) {
+	var req stockAllocationRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/inventory/allocations" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusOK, models.AllocationPlan{
+			ReferenceType: req.ReferenceType,
+			ReferenceID:   req.ReferenceID,
+			Strategy:      models.AllocationNearest,
+			Shipments: []models.AllocationShipment{{
+				Warehouse: &models.Warehouse{ID: "warehouse-1", Address: models.Address{City: "Reno", Country: "US"}},
+				Lines:     []models.AllocationLine{{ProductID: "product-1", Quantity: 2}},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	shipTo := models.Address{City: "Sparks", State: "NV", Country: "US"}
+	plan, err := newTestClient(server.URL).AllocateStock(context.Background(), "order-1", shipTo)
+	if err != nil {
+		t.Fatalf("AllocateStock failed: %v", err)
+	}
+	if req.ReferenceType != "order" || req.ReferenceID != "order-1" || req.ShippingAddress != shipTo {
+		t.Errorf("Unexpected allocation request: %+v", req)
+	}
+	if origin := plan.Origin(); origin == nil || origin.ID != "warehouse-1" || origin.Address.City != "Reno" {
+		t.Errorf("Expected the order to ship from warehouse-1, got %+v", origin)
+	}
+}
+
+func TestProductClient_ReserveStock_ReleasesOnFailure(t *testing.T) {
+	fake := newFakeProductService()
+	fake.failReserve = "product-2"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	items := []models.OrderItem{
+		{ProductID: "product-1", Quantity: 4},
+		{ProductID: "product-2", Quantity: 1},
+	}
+	err := newTestClient(server.URL).ReserveStock(context.Background(), items)
+	if err == nil || !strings.Contains(err.Error(), "insufficient stock available") {
+		t.Fatalf("Expected insufficient stock error, got %v", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected wrapped 409 APIError, got %v", err)
+	}
+	if fake.stock("product-1") != 10 {
+		t.Errorf("Expected product-1 reservation to be released, stock is %d", fake.stock("product-1"))
+	}
+}
+
+func TestProductClient_RetriesReadsOnServerError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "database unavailable")
+			return
+		}
+		writeJSON(w, http.StatusOK, models.Product{ID: "product-1", Stock: 5})
+	}))
+	defer server.Close()
+
+	product, err := newTestClient(server.URL).GetProduct(context.Background(), "product-1")
+	if err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if product.Stock != 5 || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected 3 calls and stock 5, got %d calls and %+v", calls, product)
+	}
+}
+
+func TestProductClient_DoesNotRetryReservationOnServerError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to reserve stock")
+	}))
+	defer server.Close()
+
+	err := newTestClient(server.URL).ReserveStock(context.Background(), []models.OrderItem{{ProductID: "product-1", Quantity: 1}})
+	if err == nil {
+		t.Fatal("Expected reservation to fail")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a single reservation attempt, got %d", calls)
+	}
+}
+
+func TestProductClient_DoesNotRetryReservationOnBadGateway(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// A proxy may time out after product-service reserved the stock
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(status)
+		}))
+
+		err := newTestClient(server.URL).ReserveStock(context.Background(), []models.OrderItem{{ProductID: "product-1", Quantity: 1}})
+		server.Close()
+		if err == nil {
+			t.Fatalf("Expected reservation to fail on %d", status)
+		}
+		if atomic.LoadInt32(&calls) != 1 {
+			t.Errorf("Expected a single reservation attempt on %d, got %d", status, calls)
+		}
+	}
+}
+
+func TestProductClient_RetriesReservationWhenServiceUnreachable(t *testing.T) {
+	server := httptest.NewServer(newFakeProductService())
+	addr := server.Listener.Addr().String()
+	server.Close()
+
+	var calls int32
+	client := NewProductClient(Config{BaseURL: "http://" + addr, Timeout: time.Second, MaxRetries: 2, RetryBackoff: time.Millisecond})
+	client.client.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	if err := client.ReserveStock(context.Background(), []models.OrderItem{{ProductID: "product-1", Quantity: 1}}); err == nil {
+		t.Fatal("Expected reservation to fail")
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected refused connections to be retried, got %d attempts", calls)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestProductClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewProductClient(Config{BaseURL: server.URL, Timeout: 20 * time.Millisecond, RetryBackoff: time.Millisecond})
+	start := time.Now()
+	if _, err := client.GetProduct(context.Background(), "product-1"); err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected request to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestProductClient_CircuitBreakerOpens(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewProductClient(Config{BaseURL: server.URL, Timeout: time.Second, RetryBackoff: time.Millisecond})
+	for i := 0; i < 20; i++ {
+		client.GetProduct(context.Background(), "product-1")
+	}
+
+	before := atomic.LoadInt32(&calls)
+	_, err := client.GetProduct(context.Background(), "product-1")
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker is open") {
+		t.Fatalf("Expected open circuit breaker, got %v", err)
+	}
+	if atomic.LoadInt32(&calls) != before {
+		t.Error("Expected no request to reach product-service while the breaker is open")
+	}
+}
+
+func TestProductClient_ClientErrorsDoNotTripBreaker(t *testing.T) {
+	server := httptest.NewServer(newFakeProductService())
+	defer server.Close()
+
+	client := NewProductClient(Config{BaseURL: server.URL, Timeout: time.Second, RetryBackoff: time.Millisecond})
+	for i := 0; i < 25; i++ {
+		client.GetProduct(context.Background(), "missing")
+	}
+
+	if _, err := client.GetProduct(context.Background(), "product-1"); err != nil {
+		t.Errorf("Expected breaker to stay closed after 404s, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+)
+
+// ShippingClient talks to shipping-service over HTTP and implements the
+// checkout saga's ShippingService interface
+type ShippingClient struct {
+	client *serviceClient
+}
+
+// NewShippingClient creates a new shipping-service client
+func NewShippingClient(config Config) *ShippingClient {
+	return &ShippingClient{client: newServiceClient("shipping-service", config)}
+}
+
+// CreateShipment creates a shipment via POST /shipments
+func (c *ShippingClient) CreateShipment(ctx context.Context, req *service.ShipmentRequest) (*models.Shipment, error) {
+	var shipment models.Shipment
+	if err := c.client.do(ctx, http.MethodPost, "/shipments", nil, req, &shipment); err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// GetShippingQuotes quotes every shipping method via POST /quotes
+func (c *ShippingClient) GetShippingQuotes(ctx context.Context, req *models.ShippingQuoteRequest) ([]*models.ShippingQuote, error) {
+	var resp struct {
+		Quotes []*models.ShippingQuote `json:"quotes"`
+	}
+	if err := c.client.do(ctx, http.MethodPost, "/quotes", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Quotes, nil
+}
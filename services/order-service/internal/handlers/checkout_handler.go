@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// CheckoutHandler handles HTTP requests for checkout
+type CheckoutHandler struct {
+	service service.CheckoutService
+}
+
+// NewCheckoutHandler creates a new checkout handler
+func NewCheckoutHandler(service service.CheckoutService) *CheckoutHandler {
+	return &CheckoutHandler{
+		service: service,
+	}
+}
+
+// checkoutFailure is returned when the saga failed and was compensated, so
+// clients get both the error and the saga they can poll
+type checkoutFailure struct {
+	Error    utils.ErrorDetail    `json:"error"`
+	Checkout *models.CheckoutSaga `json:"checkout"`
+}
+
+// Checkout handles POST /checkout
+func (h *CheckoutHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req service.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = r.Header.Get("X-User-ID")
+	}
+	if req.SessionID == "" {
+		req.SessionID = r.Header.Get("X-Session-ID")
+	}
+	req.ClientIP = utils.ClientIP(r)
+
+	saga, err := h.service.Checkout(ctx, &req)
+	if err != nil {
+		var checkoutErr *service.CheckoutError
+		if !errors.As(err, &checkoutErr) {
+			if strings.Contains(err.Error(), "invalid") {
+				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_CHECKOUT", err.Error())
+			} else {
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "CHECKOUT_FAILED", err.Error())
+			}
+			return
+		}
+
+		status, code := checkoutErrorStatus(checkoutErr)
+		utils.WriteJSONResponse(w, status, checkoutFailure{
+			Error:    utils.ErrorDetail{Code: code, Message: checkoutErr.Err.Error(), Details: string(checkoutErr.Step)},
+			Checkout: saga,
+		})
+		return
+	}
+
+	// A checkout held for payment review is accepted but not yet complete
+	if saga.Status == models.CheckoutAwaitingReview {
+		utils.WriteJSONResponse(w, http.StatusAccepted, saga)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusCreated, saga)
+}
+
+// GetCheckout handles GET /checkout/{id}
+func (h *CheckoutHandler) GetCheckout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	checkoutID := mux.Vars(r)["id"]
+
+	saga, err := h.service.GetCheckout(ctx, checkoutID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "CHECKOUT_NOT_FOUND", err.Error())
+		} else {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "CHECKOUT_RETRIEVAL_FAILED", err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, saga)
+}
+
+// QuoteShipping handles GET /orders/{id}/shipping-quotes
+func (h *CheckoutHandler) QuoteShipping(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderID := mux.Vars(r)["id"]
+
+	quotes, err := h.service.QuoteShipping(ctx, orderID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "ORDER_NOT_FOUND", err.Error())
+		} else {
+			utils.WriteErrorResponse(w, http.StatusBadGateway, "QUOTE_FAILED", err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"quotes": quotes,
+	})
+}
+
+// checkoutErrorStatus maps the failed saga step to a response status and code
+func checkoutErrorStatus(err *service.CheckoutError) (int, string) {
+	switch err.Step {
+	case models.CheckoutStepValidateCart:
+		if strings.Contains(err.Err.Error(), "invalid") {
+			return http.StatusUnprocessableEntity, "INVALID_CART"
+		}
+	case models.CheckoutStepCreateOrder:
+		if strings.Contains(err.Err.Error(), "invalid coupon") {
+			return http.StatusBadRequest, "INVALID_COUPON"
+		}
+		if strings.Contains(err.Err.Error(), "invalid") {
+			return http.StatusBadRequest, "INVALID_ORDER"
+		}
+		if strings.Contains(err.Err.Error(), "price mismatch") {
+			return http.StatusConflict, "PRICE_MISMATCH"
+		}
+	case models.CheckoutStepReserveStock:
+		if strings.Contains(err.Err.Error(), "stock") {
+			return http.StatusConflict, "INSUFFICIENT_STOCK"
+		}
+	case models.CheckoutStepCreatePayment, models.CheckoutStepProcessPayment:
+		return http.StatusPaymentRequired, "PAYMENT_FAILED"
+	}
+	return http.StatusBadGateway, "CHECKOUT_FAILED"
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/utils"
+)
+
+// FulfillmentHandler handles HTTP requests for order fulfillments
+type FulfillmentHandler struct {
+	service service.FulfillmentService
+}
+
+// NewFulfillmentHandler creates a new fulfillment handler
+func NewFulfillmentHandler(service service.FulfillmentService) *FulfillmentHandler {
+	return &FulfillmentHandler{
+		service: service,
+	}
+}
+
+// CreateFulfillment handles POST /orders/{id}/fulfillments
+func (h *FulfillmentHandler) CreateFulfillment(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateFulfillmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	fulfillment, err := h.service.CreateFulfillment(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeFulfillmentError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, fulfillment)
+}
+
+// ListFulfillments handles GET /orders/{id}/fulfillments
+func (h *FulfillmentHandler) ListFulfillments(w http.ResponseWriter, r *http.Request) {
+	fulfillments, err := h.service.ListFulfillments(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeFulfillmentError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"fulfillments": fulfillments,
+		"count":        len(fulfillments),
+	})
+}
+
+// GetFulfillment handles GET /fulfillments/{id}
+func (h *FulfillmentHandler) GetFulfillment(w http.ResponseWriter, r *http.Request) {
+	fulfillment, err := h.service.GetFulfillment(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeFulfillmentError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, fulfillment)
+}
+
+// UpdateFulfillment handles PATCH /fulfillments/{id}
+func (h *FulfillmentHandler) UpdateFulfillment(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateFulfillmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	fulfillment, err := h.service.UpdateFulfillment(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeFulfillmentError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, fulfillment)
+}
+
+func writeFulfillmentError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "order not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "ORDER_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "FULFILLMENT_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "invalid status transition"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_STATUS_TRANSITION", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_FULFILLMENT", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "FULFILLMENT_FAILED", err.Error())
+	}
+}
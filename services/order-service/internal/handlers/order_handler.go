@@ -36,8 +36,12 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 	order, err := h.service.CreateOrder(ctx, &req)
 	if err != nil {
-		if strings.Contains(err.Error(), "invalid") {
+		if strings.Contains(err.Error(), "invalid coupon") {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", err.Error())
+		} else if strings.Contains(err.Error(), "invalid") {
 			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_ORDER", err.Error())
+		} else if strings.Contains(err.Error(), "price mismatch") {
+			utils.WriteErrorResponse(w, http.StatusConflict, "PRICE_MISMATCH", err.Error())
 		} else if strings.Contains(err.Error(), "stock") {
 			utils.WriteErrorResponse(w, http.StatusConflict, "INSUFFICIENT_STOCK", err.Error())
 		} else {
@@ -122,16 +126,15 @@ func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+	// Only the notes and addresses can be changed; totals, status and
+	// payment are not taken from the client
+	var req service.UpdateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
 		return
 	}
 
-	// Ensure the ID matches the URL parameter
-	order.ID = orderID
-
-	if err := h.service.UpdateOrder(ctx, &order); err != nil {
+	if err := h.service.UpdateOrder(ctx, orderID, &req); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			utils.WriteErrorResponse(w, http.StatusNotFound, "Order not found", err.Error())
 		} else if strings.Contains(err.Error(), "cannot update") {
@@ -328,6 +331,10 @@ func (h *OrderHandler) ValidateOrder(w http.ResponseWriter, r *http.Request) {
 	// Calculate totals
 	totals, err := h.service.CalculateOrderTotals(ctx, &req)
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid coupon") {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", err.Error())
+			return
+		}
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to calculate totals", err.Error())
 		return
 	}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/utils"
+)
+
+// PromotionHandler handles HTTP requests for promotions and coupons
+type PromotionHandler struct {
+	service service.PromotionService
+}
+
+// NewPromotionHandler creates a new promotion handler
+func NewPromotionHandler(service service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		service: service,
+	}
+}
+
+// CreatePromotion handles POST /admin/promotions
+func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var req service.PromotionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	promotion, err := h.service.CreatePromotion(r.Context(), &req)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, promotion)
+}
+
+// ListPromotions handles GET /admin/promotions
+func (h *PromotionHandler) ListPromotions(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	promotions, err := h.service.ListPromotions(r.Context(), limit, offset)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"promotions": promotions,
+		"count":      len(promotions),
+	})
+}
+
+// GetPromotion handles GET /admin/promotions/{id}
+func (h *PromotionHandler) GetPromotion(w http.ResponseWriter, r *http.Request) {
+	promotion, err := h.service.GetPromotion(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, promotion)
+}
+
+// UpdatePromotion handles PUT /admin/promotions/{id}
+func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	var req service.PromotionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	promotion, err := h.service.UpdatePromotion(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, promotion)
+}
+
+// QuotePromotions handles POST /promotions/quote. Coupons that do not apply
+// are listed in the result rather than failing the request.
+func (h *PromotionHandler) QuotePromotions(w http.ResponseWriter, r *http.Request) {
+	var req service.PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	result, err := h.service.Apply(r.Context(), &req)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+func writePromotionError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "PROMOTION_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "already exists"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "PROMOTION_EXISTS", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_PROMOTION", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "PROMOTION_FAILED", err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// ReturnHandler handles HTTP requests for returns (RMAs)
+type ReturnHandler struct {
+	service service.ReturnService
+}
+
+// NewReturnHandler creates a new return handler
+func NewReturnHandler(service service.ReturnService) *ReturnHandler {
+	return &ReturnHandler{
+		service: service,
+	}
+}
+
+// RequestReturn handles POST /orders/{id}/returns
+func (h *ReturnHandler) RequestReturn(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	ret, err := h.service.RequestReturn(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, ret)
+}
+
+// ListReturns handles GET /orders/{id}/returns
+func (h *ReturnHandler) ListReturns(w http.ResponseWriter, r *http.Request) {
+	returns, err := h.service.ListReturns(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"returns": returns,
+		"count":   len(returns),
+	})
+}
+
+// GetReturn handles GET /returns/{id}
+func (h *ReturnHandler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	ret, err := h.service.GetReturn(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, ret)
+}
+
+// ApproveReturn handles POST /returns/{id}/approve
+func (h *ReturnHandler) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	var req service.ApproveReturnRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	ret, err := h.service.ApproveReturn(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, ret)
+}
+
+// RejectReturn handles POST /returns/{id}/reject
+func (h *ReturnHandler) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.RejectReturn)
+}
+
+// CancelReturn handles POST /returns/{id}/cancel
+func (h *ReturnHandler) CancelReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.CancelReturn)
+}
+
+// ReceiveReturn handles POST /returns/{id}/receive
+func (h *ReturnHandler) ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.ReceiveReturn)
+}
+
+// RefundReturn handles POST /returns/{id}/refund, retrying a failed refund
+func (h *ReturnHandler) RefundReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.RefundReturn)
+}
+
+// InspectReturn handles POST /returns/{id}/inspect
+func (h *ReturnHandler) InspectReturn(w http.ResponseWriter, r *http.Request) {
+	var req service.InspectReturnRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	ret, err := h.service.InspectReturn(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, ret)
+}
+
+type returnAction func(ctx context.Context, id string, req *service.ReturnActionRequest) (*models.Return, error)
+
+func (h *ReturnHandler) handleAction(w http.ResponseWriter, r *http.Request, action returnAction) {
+	var req service.ReturnActionRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	ret, err := action(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, ret)
+}
+
+// decodeOptionalBody decodes a JSON body, allowing it to be empty
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func writeReturnError(w http.ResponseWriter, err error) {
+	switch {
+	// Failures in other services come first: their messages may themselves say "not found" or "invalid"
+	case strings.HasPrefix(err.Error(), "failed to create return label"),
+		strings.HasPrefix(err.Error(), "failed to restock"),
+		strings.HasPrefix(err.Error(), "failed to refund"):
+		utils.WriteErrorResponse(w, http.StatusBadGateway, "RETURN_STEP_FAILED", err.Error())
+	case strings.Contains(err.Error(), "order not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "ORDER_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "RETURN_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "invalid status transition"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "INVALID_STATUS_TRANSITION", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_RETURN", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "RETURN_FAILED", err.Error())
+	}
+}
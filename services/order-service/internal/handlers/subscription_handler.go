@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// SubscriptionHandler handles HTTP requests for subscription plans and subscriptions
+type SubscriptionHandler struct {
+	service service.SubscriptionService
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(service service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		service: service,
+	}
+}
+
+// CreatePlan handles POST /admin/subscription-plans
+func (h *SubscriptionHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req service.SubscriptionPlanInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	plan, err := h.service.CreatePlan(r.Context(), &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, plan)
+}
+
+// UpdatePlan handles PUT /admin/subscription-plans/{id}
+func (h *SubscriptionHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	var req service.SubscriptionPlanInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	plan, err := h.service.UpdatePlan(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, plan)
+}
+
+// ListAllPlans handles GET /admin/subscription-plans, including inactive plans
+func (h *SubscriptionHandler) ListAllPlans(w http.ResponseWriter, r *http.Request) {
+	h.listPlans(w, r, false)
+}
+
+// ListPlans handles GET /subscription-plans, optionally filtered by product_id
+func (h *SubscriptionHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	h.listPlans(w, r, true)
+}
+
+// GetPlan handles GET /subscription-plans/{id}
+func (h *SubscriptionHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := h.service.GetPlan(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, plan)
+}
+
+// Subscribe handles POST /subscriptions
+func (h *SubscriptionHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req service.SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	subscription, err := h.service.Subscribe(r.Context(), &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, subscription)
+}
+
+// GetSubscription handles GET /subscriptions/{id}
+func (h *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.service.GetSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, subscription)
+}
+
+// ListUserSubscriptions handles GET /subscriptions/user/{userId}
+func (h *SubscriptionHandler) ListUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.service.ListSubscriptions(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// ListRenewals handles GET /subscriptions/{id}/renewals
+func (h *SubscriptionHandler) ListRenewals(w http.ResponseWriter, r *http.Request) {
+	renewals, err := h.service.ListRenewals(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"renewals": renewals,
+		"count":    len(renewals),
+	})
+}
+
+// UpdatePaymentMethod handles PUT /subscriptions/{id}/payment-method
+func (h *SubscriptionHandler) UpdatePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateSubscriptionPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	subscription, err := h.service.UpdatePaymentMethod(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, subscription)
+}
+
+// PauseSubscription handles POST /subscriptions/{id}/pause
+func (h *SubscriptionHandler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	var req service.PauseSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	subscription, err := h.service.Pause(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, subscription)
+}
+
+// ResumeSubscription handles POST /subscriptions/{id}/resume
+func (h *SubscriptionHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.Resume)
+}
+
+// SkipSubscription handles POST /subscriptions/{id}/skip
+func (h *SubscriptionHandler) SkipSubscription(w http.ResponseWriter, r *http.Request) {
+	h.handleAction(w, r, h.service.Skip)
+}
+
+// CancelSubscription handles POST /subscriptions/{id}/cancel
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	var req service.CancelSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	subscription, err := h.service.Cancel(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, subscription)
+}
+
+type subscriptionAction func(ctx context.Context, id string, req *service.SubscriptionActionRequest) (*models.Subscription, error)
+
+func (h *SubscriptionHandler) handleAction(w http.ResponseWriter, r *http.Request, action subscriptionAction) {
+	var req service.SubscriptionActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	subscription, err := action(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, subscription)
+}
+
+func (h *SubscriptionHandler) listPlans(w http.ResponseWriter, r *http.Request, activeOnly bool) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	plans, err := h.service.ListPlans(r.Context(), r.URL.Query().Get("product_id"), activeOnly, limit, offset)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"plans": plans,
+		"count": len(plans),
+	})
+}
+
+func writeSubscriptionError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "failed to charge subscription"):
+		utils.WriteErrorResponse(w, http.StatusPaymentRequired, "PAYMENT_FAILED", err.Error())
+	case strings.Contains(err.Error(), "invalid status transition"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "INVALID_STATUS_TRANSITION", err.Error())
+	// Checked before "not found": an unknown product or payment method is a bad request
+	case strings.HasPrefix(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_SUBSCRIPTION", err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "SUBSCRIPTION_FAILED", err.Error())
+	}
+}
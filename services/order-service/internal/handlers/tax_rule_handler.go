@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/utils"
+)
+
+// TaxRuleHandler handles admin HTTP requests for tax rules
+type TaxRuleHandler struct {
+	service service.TaxRuleService
+}
+
+// NewTaxRuleHandler creates a new tax rule handler
+func NewTaxRuleHandler(service service.TaxRuleService) *TaxRuleHandler {
+	return &TaxRuleHandler{
+		service: service,
+	}
+}
+
+// CreateTaxRule handles POST /admin/tax-rules
+func (h *TaxRuleHandler) CreateTaxRule(w http.ResponseWriter, r *http.Request) {
+	var req service.TaxRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	rule, err := h.service.CreateRule(r.Context(), &req)
+	if err != nil {
+		writeTaxRuleError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, rule)
+}
+
+// ListTaxRules handles GET /admin/tax-rules
+func (h *TaxRuleHandler) ListTaxRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.service.ListRules(r.Context())
+	if err != nil {
+		writeTaxRuleError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// GetTaxRule handles GET /admin/tax-rules/{id}
+func (h *TaxRuleHandler) GetTaxRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.service.GetRule(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeTaxRuleError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, rule)
+}
+
+// UpdateTaxRule handles PUT /admin/tax-rules/{id}
+func (h *TaxRuleHandler) UpdateTaxRule(w http.ResponseWriter, r *http.Request) {
+	var req service.TaxRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	rule, err := h.service.UpdateRule(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeTaxRuleError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, rule)
+}
+
+// DeleteTaxRule handles DELETE /admin/tax-rules/{id}
+func (h *TaxRuleHandler) DeleteTaxRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteRule(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeTaxRuleError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Tax rule deleted successfully"})
+}
+
+func writeTaxRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "TAX_RULE_NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_TAX_RULE", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "TAX_RULE_FAILED", err.Error())
+	}
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// CheckoutRepository persists checkout saga state
+type CheckoutRepository interface {
+	Create(ctx context.Context, saga *models.CheckoutSaga) error
+	Update(ctx context.Context, saga *models.CheckoutSaga) error
+	GetByID(ctx context.Context, id string) (*models.CheckoutSaga, error)
+	// ListStalled returns unfinished sagas that have not progressed since before
+	ListStalled(ctx context.Context, before time.Time, limit int) ([]*models.CheckoutSaga, error)
+	// ListAwaitingReview returns sagas whose payment is held for risk review
+	ListAwaitingReview(ctx context.Context, limit int) ([]*models.CheckoutSaga, error)
+}
+
+// PostgresCheckoutRepository implements CheckoutRepository using PostgreSQL
+type PostgresCheckoutRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresCheckoutRepository creates a new PostgreSQL checkout repository
+func NewPostgresCheckoutRepository(db *sql.DB) CheckoutRepository {
+	return &PostgresCheckoutRepository{db: db}
+}
+
+// Create inserts a new checkout saga
+func (r *PostgresCheckoutRepository) Create(ctx context.Context, saga *models.CheckoutSaga) error {
+	completedSteps, _ := json.Marshal(saga.CompletedSteps)
+	compensationErrors, _ := json.Marshal(saga.CompensationErrors)
+
+	query := `
+		INSERT INTO checkout_sagas (
+			id, user_id, session_id, cart_id, status, current_step, completed_steps,
+			order_id, payment_id, stored_value_payment_id, shipment_id, error, compensation_errors,
+			created_at, updated_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		saga.ID, saga.UserID, nullString(saga.SessionID), nullString(saga.CartID), saga.Status,
+		nullString(string(saga.CurrentStep)), completedSteps, nullString(saga.OrderID),
+		nullString(saga.PaymentID), nullString(saga.StoredValuePaymentID), nullString(saga.ShipmentID),
+		nullString(saga.Error), compensationErrors, saga.CreatedAt, saga.UpdatedAt, saga.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create checkout saga: %w", err)
+	}
+	return nil
+}
+
+// Update saves the current state of a checkout saga
+func (r *PostgresCheckoutRepository) Update(ctx context.Context, saga *models.CheckoutSaga) error {
+	saga.UpdatedAt = time.Now()
+	completedSteps, _ := json.Marshal(saga.CompletedSteps)
+	compensationErrors, _ := json.Marshal(saga.CompensationErrors)
+
+	query := `
+		UPDATE checkout_sagas SET
+			cart_id = $2, status = $3, current_step = $4, completed_steps = $5, order_id = $6,
+			payment_id = $7, shipment_id = $8, error = $9, compensation_errors = $10,
+			updated_at = $11, completed_at = $12, stored_value_payment_id = $13
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		saga.ID, nullString(saga.CartID), saga.Status, nullString(string(saga.CurrentStep)),
+		completedSteps, nullString(saga.OrderID), nullString(saga.PaymentID),
+		nullString(saga.ShipmentID), nullString(saga.Error), compensationErrors,
+		saga.UpdatedAt, saga.CompletedAt, nullString(saga.StoredValuePaymentID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update checkout saga: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("checkout not found")
+	}
+	return nil
+}
+
+// GetByID retrieves a checkout saga by ID
+func (r *PostgresCheckoutRepository) GetByID(ctx context.Context, id string) (*models.CheckoutSaga, error) {
+	query := `
+		SELECT id, user_id, session_id, cart_id, status, current_step, completed_steps,
+			   order_id, payment_id, stored_value_payment_id, shipment_id, error, compensation_errors,
+			   created_at, updated_at, completed_at
+		FROM checkout_sagas WHERE id = $1`
+
+	saga, err := scanCheckoutSaga(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("checkout not found")
+		}
+		return nil, fmt.Errorf("failed to get checkout saga: %w", err)
+	}
+	return saga, nil
+}
+
+// ListStalled returns started or compensating sagas last updated before the given time
+func (r *PostgresCheckoutRepository) ListStalled(ctx context.Context, before time.Time, limit int) ([]*models.CheckoutSaga, error) {
+	query := `
+		SELECT id, user_id, session_id, cart_id, status, current_step, completed_steps,
+			   order_id, payment_id, stored_value_payment_id, shipment_id, error, compensation_errors,
+			   created_at, updated_at, completed_at
+		FROM checkout_sagas
+		WHERE status IN ('started', 'compensating') AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stalled checkout sagas: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCheckoutSagas(rows)
+}
+
+// ListAwaitingReview returns sagas awaiting a payment review, longest waiting first
+func (r *PostgresCheckoutRepository) ListAwaitingReview(ctx context.Context, limit int) ([]*models.CheckoutSaga, error) {
+	query := `
+		SELECT id, user_id, session_id, cart_id, status, current_step, completed_steps,
+			   order_id, payment_id, stored_value_payment_id, shipment_id, error, compensation_errors,
+			   created_at, updated_at, completed_at
+		FROM checkout_sagas
+		WHERE status = 'awaiting_review'
+		ORDER BY updated_at
+		LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkout sagas awaiting review: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCheckoutSagas(rows)
+}
+
+func scanCheckoutSagas(rows *sql.Rows) ([]*models.CheckoutSaga, error) {
+	var sagas []*models.CheckoutSaga
+	for rows.Next() {
+		saga, err := scanCheckoutSaga(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan checkout saga: %w", err)
+		}
+		sagas = append(sagas, saga)
+	}
+	return sagas, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCheckoutSaga(row rowScanner) (*models.CheckoutSaga, error) {
+	var saga models.CheckoutSaga
+	var sessionID, cartID, currentStep, orderID, paymentID, storedValuePaymentID, shipmentID, sagaError sql.NullString
+	var completedSteps, compensationErrors []byte
+	var completedAt sql.NullTime
+
+	err := row.Scan(
+		&saga.ID, &saga.UserID, &sessionID, &cartID, &saga.Status, &currentStep, &completedSteps,
+		&orderID, &paymentID, &storedValuePaymentID, &shipmentID, &sagaError, &compensationErrors,
+		&saga.CreatedAt, &saga.UpdatedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	saga.SessionID = sessionID.String
+	saga.CartID = cartID.String
+	saga.CurrentStep = models.CheckoutStep(currentStep.String)
+	saga.OrderID = orderID.String
+	saga.PaymentID = paymentID.String
+	saga.StoredValuePaymentID = storedValuePaymentID.String
+	saga.ShipmentID = shipmentID.String
+	saga.Error = sagaError.String
+	json.Unmarshal(completedSteps, &saga.CompletedSteps)
+	json.Unmarshal(compensationErrors, &saga.CompensationErrors)
+	if completedAt.Valid {
+		saga.CompletedAt = &completedAt.Time
+	}
+
+	return &saga, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/shared/models"
+)
+
+// FulfillmentRepository persists order fulfillments and their items
+type FulfillmentRepository interface {
+	// Create inserts a fulfillment and its items. It fails if an item would
+	// be fulfilled beyond its ordered quantity, even under concurrent calls.
+	Create(ctx context.Context, fulfillment *models.Fulfillment) error
+	// Update saves the fulfillment's status, carrier, tracking and notes if
+	// the fulfillment is still in status from. It returns
+	// ErrFulfillmentChanged when another request moved it on first.
+	Update(ctx context.Context, fulfillment *models.Fulfillment, from models.FulfillmentStatus) error
+	GetByID(ctx context.Context, id string) (*models.Fulfillment, error)
+	ListByOrder(ctx context.Context, orderID string) ([]models.Fulfillment, error)
+}
+
+// ErrFulfillmentChanged is returned by Update when the fulfillment is no
+// longer in the status the caller read it in
+var ErrFulfillmentChanged = errors.New("invalid status transition: fulfillment was changed by another request")
+
+// PostgresFulfillmentRepository implements FulfillmentRepository using PostgreSQL
+type PostgresFulfillmentRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresFulfillmentRepository creates a new PostgreSQL fulfillment repository
+func NewPostgresFulfillmentRepository(db *sql.DB) FulfillmentRepository {
+	return &PostgresFulfillmentRepository{db: db}
+}
+
+const fulfillmentColumns = `id, order_id, fulfillment_status, carrier, tracking_number, tracking_url,
+	shipped_at, delivered_at, notes, created_at, updated_at`
+
+// Create inserts a new fulfillment
+func (r *PostgresFulfillmentRepository) Create(ctx context.Context, fulfillment *models.Fulfillment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent fulfillments see each other's items
+	var orderID string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM orders WHERE id = $1 FOR UPDATE`, fulfillment.OrderID).Scan(&orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	for _, item := range fulfillment.Items {
+		var ordered, allocated int
+		err := tx.QueryRowContext(ctx, `
+			SELECT oi.quantity, COALESCE((
+				SELECT SUM(fi.quantity)
+				FROM order_fulfillment_items fi
+				JOIN order_fulfillments f ON f.id = fi.fulfillment_id
+				WHERE fi.order_item_id = oi.id AND f.fulfillment_status <> 'cancelled'
+			), 0)
+			FROM order_items oi
+			WHERE oi.id = $1 AND oi.order_id = $2`,
+			item.OrderItemID, fulfillment.OrderID,
+		).Scan(&ordered, &allocated)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("invalid fulfillment: order item %s is not part of the order", item.OrderItemID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check fulfilled quantity: %w", err)
+		}
+		if allocated+item.Quantity > ordered {
+			return fmt.Errorf("invalid fulfillment: only %d of order item %s remain unfulfilled", ordered-allocated, item.OrderItemID)
+		}
+	}
+
+	query := `
+		INSERT INTO order_fulfillments (` + fulfillmentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err = tx.ExecContext(ctx, query,
+		fulfillment.ID, fulfillment.OrderID, fulfillment.Status, nullString(fulfillment.Carrier),
+		nullString(fulfillment.TrackingNumber), nullString(fulfillment.TrackingURL),
+		fulfillment.ShippedAt, fulfillment.DeliveredAt, nullString(fulfillment.Notes),
+		fulfillment.CreatedAt, fulfillment.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert fulfillment: %w", err)
+	}
+
+	for i := range fulfillment.Items {
+		item := &fulfillment.Items[i]
+		if item.ID == "" {
+			item.ID = uuid.New().String()
+		}
+		item.FulfillmentID = fulfillment.ID
+		item.CreatedAt = fulfillment.CreatedAt
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_fulfillment_items (id, fulfillment_id, order_item_id, quantity, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			item.ID, item.FulfillmentID, item.OrderItemID, item.Quantity, item.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert fulfillment item: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Update saves changes to a fulfillment
+func (r *PostgresFulfillmentRepository) Update(ctx context.Context, fulfillment *models.Fulfillment, from models.FulfillmentStatus) error {
+	fulfillment.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE order_fulfillments SET
+			fulfillment_status = $2, carrier = $3, tracking_number = $4, tracking_url = $5,
+			shipped_at = $6, delivered_at = $7, notes = $8, updated_at = $9
+		WHERE id = $1 AND fulfillment_status = $10`
+
+	result, err := r.db.ExecContext(ctx, query,
+		fulfillment.ID, fulfillment.Status, nullString(fulfillment.Carrier),
+		nullString(fulfillment.TrackingNumber), nullString(fulfillment.TrackingURL),
+		fulfillment.ShippedAt, fulfillment.DeliveredAt, nullString(fulfillment.Notes),
+		fulfillment.UpdatedAt, from,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update fulfillment: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM order_fulfillments WHERE id = $1)`, fulfillment.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check fulfillment: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("fulfillment not found")
+		}
+		return ErrFulfillmentChanged
+	}
+	return nil
+}
+
+// GetByID retrieves a fulfillment and its items
+func (r *PostgresFulfillmentRepository) GetByID(ctx context.Context, id string) (*models.Fulfillment, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+fulfillmentColumns+` FROM order_fulfillments WHERE id = $1`, id)
+	fulfillment, err := scanFulfillment(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("fulfillment not found")
+		}
+		return nil, fmt.Errorf("failed to get fulfillment: %w", err)
+	}
+
+	items, err := getFulfillmentItems(ctx, r.db, `WHERE fi.fulfillment_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	fulfillment.Items = items[fulfillment.ID]
+	if fulfillment.Items == nil {
+		fulfillment.Items = []models.FulfillmentItem{}
+	}
+	return fulfillment, nil
+}
+
+// ListByOrder returns an order's fulfillments, oldest first
+func (r *PostgresFulfillmentRepository) ListByOrder(ctx context.Context, orderID string) ([]models.Fulfillment, error) {
+	return getOrderFulfillments(ctx, r.db, orderID)
+}
+
+func getOrderFulfillments(ctx context.Context, db *sql.DB, orderID string) ([]models.Fulfillment, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+fulfillmentColumns+` FROM order_fulfillments WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fulfillments: %w", err)
+	}
+	defer rows.Close()
+
+	fulfillments := []models.Fulfillment{}
+	for rows.Next() {
+		fulfillment, err := scanFulfillment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fulfillment: %w", err)
+		}
+		fulfillments = append(fulfillments, *fulfillment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := getFulfillmentItems(ctx, db, `JOIN order_fulfillments f ON f.id = fi.fulfillment_id WHERE f.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fulfillments {
+		if fulfillments[i].Items = items[fulfillments[i].ID]; fulfillments[i].Items == nil {
+			fulfillments[i].Items = []models.FulfillmentItem{}
+		}
+	}
+	return fulfillments, nil
+}
+
+// getFulfillmentItems returns fulfillment items matching the clause, keyed by fulfillment ID
+func getFulfillmentItems(ctx context.Context, db *sql.DB, clause string, arg interface{}) (map[string][]models.FulfillmentItem, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT fi.id, fi.fulfillment_id, fi.order_item_id, fi.quantity, fi.created_at
+		FROM order_fulfillment_items fi `+clause+`
+		ORDER BY fi.created_at, fi.id`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fulfillment items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string][]models.FulfillmentItem)
+	for rows.Next() {
+		var item models.FulfillmentItem
+		if err := rows.Scan(&item.ID, &item.FulfillmentID, &item.OrderItemID, &item.Quantity, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan fulfillment item: %w", err)
+		}
+		items[item.FulfillmentID] = append(items[item.FulfillmentID], item)
+	}
+	return items, rows.Err()
+}
+
+func scanFulfillment(row rowScanner) (*models.Fulfillment, error) {
+	var fulfillment models.Fulfillment
+	var carrier, trackingNumber, trackingURL, notes sql.NullString
+	var shippedAt, deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&fulfillment.ID, &fulfillment.OrderID, &fulfillment.Status, &carrier, &trackingNumber,
+		&trackingURL, &shippedAt, &deliveredAt, &notes, &fulfillment.CreatedAt, &fulfillment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fulfillment.Carrier = carrier.String
+	fulfillment.TrackingNumber = trackingNumber.String
+	fulfillment.TrackingURL = trackingURL.String
+	fulfillment.Notes = notes.String
+	if shippedAt.Valid {
+		fulfillment.ShippedAt = &shippedAt.Time
+	}
+	if deliveredAt.Valid {
+		fulfillment.DeliveredAt = &deliveredAt.Time
+	}
+	return &fulfillment, nil
+}
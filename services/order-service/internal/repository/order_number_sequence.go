@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// OrderNumberSequence hands out order number sequence values. Values are
+// never reused, even by concurrent callers on different replicas.
+type OrderNumberSequence interface {
+	Next(ctx context.Context) (int64, error)
+}
+
+// PostgresOrderNumberSequence implements OrderNumberSequence with the
+// order_number_seq sequence
+type PostgresOrderNumberSequence struct {
+	db *sql.DB
+}
+
+// NewPostgresOrderNumberSequence creates a new PostgreSQL order number sequence
+func NewPostgresOrderNumberSequence(db *sql.DB) OrderNumberSequence {
+	return &PostgresOrderNumberSequence{db: db}
+}
+
+// Next returns the next sequence value
+func (s *PostgresOrderNumberSequence) Next(ctx context.Context) (int64, error) {
+	var value int64
+	if err := s.db.QueryRowContext(ctx, `SELECT nextval('order_number_seq')`).Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to allocate order number: %w", err)
+	}
+	return value, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 )
@@ -18,6 +19,11 @@ type OrderRepository interface {
 	GetByID(ctx context.Context, id string) (*models.Order, error)
 	GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*models.Order, error)
 	Update(ctx context.Context, order *models.Order) error
+	// UpdateDetails saves the details of an order its customer can edit:
+	// the notes and the shipping and billing addresses
+	UpdateDetails(ctx context.Context, order *models.Order) error
+	// UpdatePayment records the status and reference of an order's payment
+	UpdatePayment(ctx context.Context, orderID, paymentStatus, paymentReference string) error
 	UpdateStatus(ctx context.Context, orderID string, status models.OrderStatus, reason string, changedBy string) error
 	Delete(ctx context.Context, id string) error
 	Search(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]*models.Order, error)
@@ -26,12 +32,16 @@ type OrderRepository interface {
 
 // PostgresOrderRepository implements OrderRepository using PostgreSQL
 type PostgresOrderRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *events.PostgresOutbox
 }
 
 // NewPostgresOrderRepository creates a new PostgreSQL order repository
 func NewPostgresOrderRepository(db *sql.DB) OrderRepository {
-	return &PostgresOrderRepository{db: db}
+	return &PostgresOrderRepository{
+		db:     db,
+		outbox: events.NewPostgresOutbox(db),
+	}
 }
 
 // Create creates a new order
@@ -42,86 +52,114 @@ func (r *PostgresOrderRepository) Create(ctx context.Context, order *models.Orde
 	}
 	defer tx.Rollback()
 
-	// Generate order number if not set
-	if order.OrderNumber == "" {
-		order.OrderNumber = generateOrderNumber()
-	}
-
 	// Marshal addresses and payment method to JSON
 	shippingAddr, _ := json.Marshal(order.ShippingAddress)
 	billingAddr, _ := json.Marshal(order.BillingAddress)
 	paymentMethod, _ := json.Marshal(order.PaymentMethod)
+	exchangeRates, _ := json.Marshal(order.ExchangeRates)
+	originAddr := originAddressJSON(order)
 
 	// Insert order
 	query := `
 		INSERT INTO orders (
-			id, order_number, user_id, status, subtotal, tax, shipping, discount, total, currency,
-			shipping_address, billing_address, payment_method, payment_status, payment_reference,
-			shipping_method, tracking_number, estimated_delivery_date, notes, source, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)`
-
-	_, err = tx.ExecContext(ctx, query,
-		order.ID, order.OrderNumber, order.UserID, order.Status, order.Subtotal, order.Tax,
-		order.Shipping, order.Discount, order.Total, order.Currency,
-		shippingAddr, billingAddr, paymentMethod, order.PaymentStatus, order.PaymentReference,
+			id, order_number, user_id, status, subtotal, tax, tax_included, shipping, discount, total, currency,
+			exchange_rates, shipping_address, billing_address, payment_method, payment_status, payment_reference,
+			shipping_method, tracking_number, estimated_delivery_date, notes, source, origin_warehouse_id, origin_address,
+			created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+		RETURNING order_number`
+
+	// Without an order number the database assigns one from order_number_seq
+	err = tx.QueryRowContext(ctx, query,
+		order.ID, nullString(order.OrderNumber), order.UserID, order.Status, order.Subtotal, order.Tax,
+		order.TaxIncluded, order.Shipping, order.Discount, order.Total, order.Currency,
+		exchangeRates, shippingAddr, billingAddr, paymentMethod, order.PaymentStatus, order.PaymentReference,
 		order.ShippingMethod, order.TrackingNumber, order.EstimatedDeliveryDate, order.Notes,
-		order.Source, order.CreatedAt, order.UpdatedAt,
-	)
+		order.Source, nullString(order.OriginWarehouseID), originAddr, order.CreatedAt, order.UpdatedAt,
+	).Scan(&order.OrderNumber)
 	if err != nil {
 		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
 	// Insert order items
-	for _, item := range order.Items {
+	for i := range order.Items {
+		item := &order.Items[i]
 		if item.ID == "" {
 			item.ID = uuid.New().String()
 		}
 		item.OrderID = order.ID
 
 		attrs, _ := json.Marshal(item.ProductAttributes)
+		taxBreakdown, _ := json.Marshal(item.TaxBreakdown)
 		itemQuery := `
 			INSERT INTO order_items (
-				id, order_id, product_id, variant_id, sku, name, description, price, quantity, total,
-				product_attributes, image_url, created_at
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+				id, order_id, product_id, variant_id, sku, name, description, price, price_source,
+				quantity, total, tax_class, tax_amount, tax_breakdown, product_attributes, image_url, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
 
 		_, err = tx.ExecContext(ctx, itemQuery,
 			item.ID, item.OrderID, item.ProductID, item.VariantID, item.SKU, item.Name,
-			item.Description, item.Price, item.Quantity, item.Total, attrs, item.ImageURL, item.CreatedAt,
+			item.Description, item.Price, item.PriceSource, item.Quantity, item.Total,
+			item.TaxClass, item.Tax, taxBreakdown, attrs, item.ImageURL, item.CreatedAt,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert order item: %w", err)
 		}
 	}
 
+	// Record applied discounts and redeem their promotions
+	if err := saveOrderDiscounts(ctx, tx, order); err != nil {
+		return err
+	}
+
 	// Record initial status
 	if err := r.recordStatusChange(ctx, tx, order.ID, "", string(order.Status), "Order created", order.UserID); err != nil {
 		return fmt.Errorf("failed to record status change: %w", err)
 	}
 
+	// Publish order.created through the outbox so it commits with the order
+	event, err := models.NewDomainEvent(models.EventOrderCreated, order.ID, models.OrderCreatedData{
+		OrderID:         order.ID,
+		UserID:          order.UserID,
+		Items:           order.Items,
+		Total:           order.Total,
+		Currency:        order.Currency,
+		ShippingAddress: order.ShippingAddress,
+		BillingAddress:  order.BillingAddress,
+	}, events.MetadataFromContext(ctx, "order-service"))
+	if err != nil {
+		return fmt.Errorf("failed to create order event: %w", err)
+	}
+	if err := r.outbox.Save(ctx, tx, event); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
 // GetByID retrieves an order by ID
 func (r *PostgresOrderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
 	query := `
-		SELECT id, order_number, user_id, status, subtotal, tax, shipping, discount, total, currency,
-			   shipping_address, billing_address, payment_method, payment_status, payment_reference,
-			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date,
+		SELECT id, order_number, user_id, status, subtotal, tax, tax_included, shipping, discount, total, currency,
+			   exchange_rates, shipping_address, billing_address, payment_method, payment_status, payment_reference,
+			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date, origin_warehouse_id, origin_address,
 			   notes, internal_notes, source, confirmed_at, shipped_at, delivered_at, cancelled_at,
 			   created_at, updated_at
 		FROM orders WHERE id = $1`
 
 	var order models.Order
-	var shippingAddr, billingAddr, paymentMethod []byte
+	var shippingAddr, billingAddr, paymentMethod, exchangeRates []byte
 	var confirmedAt, shippedAt, deliveredAt, cancelledAt sql.NullTime
 	var actualDeliveryDate sql.NullTime
+	var originWarehouseID sql.NullString
+	var originAddr []byte
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&order.ID, &order.OrderNumber, &order.UserID, &order.Status, &order.Subtotal,
-		&order.Tax, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
-		&shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
+		&order.Tax, &order.TaxIncluded, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
+		&exchangeRates, &shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
 		&order.ShippingMethod, &order.TrackingNumber, &order.EstimatedDeliveryDate, &actualDeliveryDate,
+		&originWarehouseID, &originAddr,
 		&order.Notes, &order.InternalNotes, &order.Source, &confirmedAt, &shippedAt,
 		&deliveredAt, &cancelledAt, &order.CreatedAt, &order.UpdatedAt,
 	)
@@ -136,6 +174,8 @@ func (r *PostgresOrderRepository) GetByID(ctx context.Context, id string) (*mode
 	json.Unmarshal(shippingAddr, &order.ShippingAddress)
 	json.Unmarshal(billingAddr, &order.BillingAddress)
 	json.Unmarshal(paymentMethod, &order.PaymentMethod)
+	json.Unmarshal(exchangeRates, &order.ExchangeRates)
+	setOrderOrigin(&order, originWarehouseID, originAddr)
 
 	// Handle nullable timestamps
 	if confirmedAt.Valid {
@@ -161,15 +201,29 @@ func (r *PostgresOrderRepository) GetByID(ctx context.Context, id string) (*mode
 	}
 	order.Items = items
 
+	// Load applied discounts
+	discounts, err := getOrderDiscounts(ctx, r.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order discounts: %w", err)
+	}
+	order.Discounts = discounts
+
+	// Load fulfillments
+	fulfillments, err := getOrderFulfillments(ctx, r.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order fulfillments: %w", err)
+	}
+	order.Fulfillments = fulfillments
+
 	return &order, nil
 }
 
 // GetByUserID retrieves orders for a specific user
 func (r *PostgresOrderRepository) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*models.Order, error) {
 	query := `
-		SELECT id, order_number, user_id, status, subtotal, tax, shipping, discount, total, currency,
-			   shipping_address, billing_address, payment_method, payment_status, payment_reference,
-			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date,
+		SELECT id, order_number, user_id, status, subtotal, tax, tax_included, shipping, discount, total, currency,
+			   exchange_rates, shipping_address, billing_address, payment_method, payment_status, payment_reference,
+			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date, origin_warehouse_id, origin_address,
 			   notes, internal_notes, source, confirmed_at, shipped_at, delivered_at, cancelled_at,
 			   created_at, updated_at
 		FROM orders 
@@ -186,15 +240,18 @@ func (r *PostgresOrderRepository) GetByUserID(ctx context.Context, userID string
 	var orders []*models.Order
 	for rows.Next() {
 		var order models.Order
-		var shippingAddr, billingAddr, paymentMethod []byte
+		var shippingAddr, billingAddr, paymentMethod, exchangeRates []byte
 		var confirmedAt, shippedAt, deliveredAt, cancelledAt sql.NullTime
 		var actualDeliveryDate sql.NullTime
+		var originWarehouseID sql.NullString
+		var originAddr []byte
 
 		err := rows.Scan(
 			&order.ID, &order.OrderNumber, &order.UserID, &order.Status, &order.Subtotal,
-			&order.Tax, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
-			&shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
+			&order.Tax, &order.TaxIncluded, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
+			&exchangeRates, &shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
 			&order.ShippingMethod, &order.TrackingNumber, &order.EstimatedDeliveryDate, &actualDeliveryDate,
+			&originWarehouseID, &originAddr,
 			&order.Notes, &order.InternalNotes, &order.Source, &confirmedAt, &shippedAt,
 			&deliveredAt, &cancelledAt, &order.CreatedAt, &order.UpdatedAt,
 		)
@@ -206,6 +263,8 @@ func (r *PostgresOrderRepository) GetByUserID(ctx context.Context, userID string
 		json.Unmarshal(shippingAddr, &order.ShippingAddress)
 		json.Unmarshal(billingAddr, &order.BillingAddress)
 		json.Unmarshal(paymentMethod, &order.PaymentMethod)
+		json.Unmarshal(exchangeRates, &order.ExchangeRates)
+		setOrderOrigin(&order, originWarehouseID, originAddr)
 
 		// Handle nullable timestamps
 		if confirmedAt.Valid {
@@ -245,20 +304,23 @@ func (r *PostgresOrderRepository) Update(ctx context.Context, order *models.Orde
 	shippingAddr, _ := json.Marshal(order.ShippingAddress)
 	billingAddr, _ := json.Marshal(order.BillingAddress)
 	paymentMethod, _ := json.Marshal(order.PaymentMethod)
+	originAddr := originAddressJSON(order)
 
 	query := `
 		UPDATE orders SET
-			status = $2, subtotal = $3, tax = $4, shipping = $5, discount = $6, total = $7,
-			shipping_address = $8, billing_address = $9, payment_method = $10, payment_status = $11,
-			payment_reference = $12, shipping_method = $13, tracking_number = $14,
-			estimated_delivery_date = $15, notes = $16, updated_at = $17
+			status = $2, subtotal = $3, tax = $4, tax_included = $5, shipping = $6, discount = $7,
+			total = $8, shipping_address = $9, billing_address = $10, payment_method = $11,
+			payment_status = $12, payment_reference = $13, shipping_method = $14, tracking_number = $15,
+			estimated_delivery_date = $16, notes = $17, origin_warehouse_id = $18, origin_address = $19,
+			updated_at = $20
 		WHERE id = $1`
 
 	_, err := r.db.ExecContext(ctx, query,
-		order.ID, order.Status, order.Subtotal, order.Tax, order.Shipping, order.Discount,
-		order.Total, shippingAddr, billingAddr, paymentMethod, order.PaymentStatus,
+		order.ID, order.Status, order.Subtotal, order.Tax, order.TaxIncluded, order.Shipping,
+		order.Discount, order.Total, shippingAddr, billingAddr, paymentMethod, order.PaymentStatus,
 		order.PaymentReference, order.ShippingMethod, order.TrackingNumber,
-		order.EstimatedDeliveryDate, order.Notes, order.UpdatedAt,
+		order.EstimatedDeliveryDate, order.Notes, nullString(order.OriginWarehouseID), originAddr,
+		order.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
@@ -267,6 +329,48 @@ func (r *PostgresOrderRepository) Update(ctx context.Context, order *models.Orde
 	return nil
 }
 
+// UpdateDetails updates an order's notes and addresses, leaving its totals,
+// status and payment as they are
+func (r *PostgresOrderRepository) UpdateDetails(ctx context.Context, order *models.Order) error {
+	order.UpdatedAt = time.Now()
+
+	shippingAddr, _ := json.Marshal(order.ShippingAddress)
+	billingAddr, _ := json.Marshal(order.BillingAddress)
+
+	query := `
+		UPDATE orders SET
+			shipping_address = $2, billing_address = $3, notes = $4, updated_at = $5
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, order.ID, shippingAddr, billingAddr, order.Notes, order.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("order not found")
+	}
+
+	return nil
+}
+
+// UpdatePayment records an order's payment status and reference
+func (r *PostgresOrderRepository) UpdatePayment(ctx context.Context, orderID, paymentStatus, paymentReference string) error {
+	query := `
+		UPDATE orders SET
+			payment_status = $2, payment_reference = $3, updated_at = $4
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, orderID, paymentStatus, paymentReference, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update order payment: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("order not found")
+	}
+
+	return nil
+}
+
 // UpdateStatus updates order status and records the change
 func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, orderID string, status models.OrderStatus, reason string, changedBy string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -310,6 +414,13 @@ func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, orderID stri
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
+	// Cancelled orders give their promotion uses back
+	if status == models.OrderCancelled && currentStatus != string(models.OrderCancelled) {
+		if err := releasePromotions(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+
 	// Record status change
 	if err := r.recordStatusChange(ctx, tx, orderID, currentStatus, string(status), reason, changedBy); err != nil {
 		return fmt.Errorf("failed to record status change: %w", err)
@@ -326,9 +437,9 @@ func (r *PostgresOrderRepository) Delete(ctx context.Context, id string) error {
 // Search searches orders based on filters
 func (r *PostgresOrderRepository) Search(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]*models.Order, error) {
 	query := `
-		SELECT id, order_number, user_id, status, subtotal, tax, shipping, discount, total, currency,
-			   shipping_address, billing_address, payment_method, payment_status, payment_reference,
-			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date,
+		SELECT id, order_number, user_id, status, subtotal, tax, tax_included, shipping, discount, total, currency,
+			   exchange_rates, shipping_address, billing_address, payment_method, payment_status, payment_reference,
+			   shipping_method, tracking_number, estimated_delivery_date, actual_delivery_date, origin_warehouse_id, origin_address,
 			   notes, internal_notes, source, confirmed_at, shipped_at, delivered_at, cancelled_at,
 			   created_at, updated_at
 		FROM orders WHERE 1=1`
@@ -386,15 +497,18 @@ func (r *PostgresOrderRepository) Search(ctx context.Context, filters map[string
 	var orders []*models.Order
 	for rows.Next() {
 		var order models.Order
-		var shippingAddr, billingAddr, paymentMethod []byte
+		var shippingAddr, billingAddr, paymentMethod, exchangeRates []byte
 		var confirmedAt, shippedAt, deliveredAt, cancelledAt sql.NullTime
 		var actualDeliveryDate sql.NullTime
+		var originWarehouseID sql.NullString
+		var originAddr []byte
 
 		err := rows.Scan(
 			&order.ID, &order.OrderNumber, &order.UserID, &order.Status, &order.Subtotal,
-			&order.Tax, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
-			&shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
+			&order.Tax, &order.TaxIncluded, &order.Shipping, &order.Discount, &order.Total, &order.Currency,
+			&exchangeRates, &shippingAddr, &billingAddr, &paymentMethod, &order.PaymentStatus, &order.PaymentReference,
 			&order.ShippingMethod, &order.TrackingNumber, &order.EstimatedDeliveryDate, &actualDeliveryDate,
+			&originWarehouseID, &originAddr,
 			&order.Notes, &order.InternalNotes, &order.Source, &confirmedAt, &shippedAt,
 			&deliveredAt, &cancelledAt, &order.CreatedAt, &order.UpdatedAt,
 		)
@@ -406,6 +520,8 @@ func (r *PostgresOrderRepository) Search(ctx context.Context, filters map[string
 		json.Unmarshal(shippingAddr, &order.ShippingAddress)
 		json.Unmarshal(billingAddr, &order.BillingAddress)
 		json.Unmarshal(paymentMethod, &order.PaymentMethod)
+		json.Unmarshal(exchangeRates, &order.ExchangeRates)
+		setOrderOrigin(&order, originWarehouseID, originAddr)
 
 		// Handle nullable timestamps
 		if confirmedAt.Valid {
@@ -476,8 +592,8 @@ func (r *PostgresOrderRepository) GetStatusHistory(ctx context.Context, orderID
 
 func (r *PostgresOrderRepository) getOrderItems(ctx context.Context, orderID string) ([]models.OrderItem, error) {
 	query := `
-		SELECT id, order_id, product_id, variant_id, sku, name, description, price, quantity, total,
-			   product_attributes, image_url, created_at
+		SELECT id, order_id, product_id, variant_id, sku, name, description, price, price_source,
+			   quantity, total, tax_class, tax_amount, tax_breakdown, product_attributes, image_url, created_at
 		FROM order_items
 		WHERE order_id = $1
 		ORDER BY created_at ASC`
@@ -493,12 +609,14 @@ func (r *PostgresOrderRepository) getOrderItems(ctx context.Context, orderID str
 		var item models.OrderItem
 		var variantID sql.NullString
 		var description sql.NullString
-		var attrs []byte
+		var taxClass sql.NullString
+		var attrs, taxBreakdown []byte
 		var imageURL sql.NullString
 
 		err := rows.Scan(
 			&item.ID, &item.OrderID, &item.ProductID, &variantID, &item.SKU, &item.Name,
-			&description, &item.Price, &item.Quantity, &item.Total, &attrs, &imageURL, &item.CreatedAt,
+			&description, &item.Price, &item.PriceSource, &item.Quantity, &item.Total,
+			&taxClass, &item.Tax, &taxBreakdown, &attrs, &imageURL, &item.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
@@ -513,11 +631,15 @@ func (r *PostgresOrderRepository) getOrderItems(ctx context.Context, orderID str
 		if imageURL.Valid {
 			item.ImageURL = imageURL.String
 		}
+		item.TaxClass = taxClass.String
 
-		// Unmarshal product attributes
+		// Unmarshal product attributes and tax breakdown
 		if len(attrs) > 0 {
 			json.Unmarshal(attrs, &item.ProductAttributes)
 		}
+		if len(taxBreakdown) > 0 {
+			json.Unmarshal(taxBreakdown, &item.TaxBreakdown)
+		}
 
 		items = append(items, item)
 	}
@@ -541,6 +663,21 @@ func (r *PostgresOrderRepository) recordStatusChange(ctx context.Context, tx *sq
 	return err
 }
 
-func generateOrderNumber() string {
-	return fmt.Sprintf("ORD-%d", time.Now().Unix())
+// setOrderOrigin sets the warehouse an order ships from, if it was allocated one
+func setOrderOrigin(order *models.Order, warehouseID sql.NullString, address []byte) {
+	order.OriginWarehouseID = warehouseID.String
+	if address != nil {
+		order.OriginAddress = &models.Address{}
+		json.Unmarshal(address, order.OriginAddress)
+	}
+}
+
+// originAddressJSON returns an order's origin address as JSON, or nil to
+// store NULL when it ships from the default warehouse
+func originAddressJSON(order *models.Order) []byte {
+	if order.OriginAddress == nil {
+		return nil
+	}
+	address, _ := json.Marshal(order.OriginAddress)
+	return address
 }
@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/models"
+)
+
+// PromotionRepository persists promotions and their redemptions
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	Update(ctx context.Context, promotion *models.Promotion) error
+	GetByID(ctx context.Context, id string) (*models.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*models.Promotion, error)
+	List(ctx context.Context, limit, offset int) ([]*models.Promotion, error)
+	// ListAutomatic returns active promotions without a code whose window contains at
+	ListAutomatic(ctx context.Context, at time.Time) ([]*models.Promotion, error)
+	// CountRedemptions returns how many orders of the user used the promotion
+	CountRedemptions(ctx context.Context, promotionID, userID string) (int, error)
+}
+
+// ErrPromotionNotFound is returned when no promotion has the requested ID or
+// code
+var ErrPromotionNotFound = errors.New("promotion not found")
+
+// PostgresPromotionRepository implements PromotionRepository using PostgreSQL
+type PostgresPromotionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresPromotionRepository creates a new PostgreSQL promotion repository
+func NewPostgresPromotionRepository(db *sql.DB) PromotionRepository {
+	return &PostgresPromotionRepository{db: db}
+}
+
+const promotionColumns = `id, code, name, description, type, value, min_subtotal, buy_quantity,
+	get_quantity, product_ids, usage_limit, per_user_limit, usage_count, starts_at, ends_at,
+	active, created_at, updated_at`
+
+// Create inserts a new promotion
+func (r *PostgresPromotionRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	productIDs, _ := json.Marshal(promotionProductIDs(promotion))
+
+	query := `
+		INSERT INTO promotions (` + promotionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		promotion.ID, nullString(promotion.Code), promotion.Name, nullString(promotion.Description),
+		promotion.Type, promotion.Value, promotion.MinSubtotal, promotion.BuyQuantity,
+		promotion.GetQuantity, productIDs, promotion.UsageLimit, promotion.PerUserLimit,
+		promotion.UsageCount, promotion.StartsAt, promotion.EndsAt, promotion.Active,
+		promotion.CreatedAt, promotion.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("promotion code %s already exists", promotion.Code)
+		}
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return nil
+}
+
+// Update saves changes to a promotion. The usage count is owned by order
+// creation and cancellation, so it is not written here.
+func (r *PostgresPromotionRepository) Update(ctx context.Context, promotion *models.Promotion) error {
+	promotion.UpdatedAt = time.Now()
+	productIDs, _ := json.Marshal(promotionProductIDs(promotion))
+
+	query := `
+		UPDATE promotions SET
+			code = $2, name = $3, description = $4, type = $5, value = $6, min_subtotal = $7,
+			buy_quantity = $8, get_quantity = $9, product_ids = $10, usage_limit = $11,
+			per_user_limit = $12, starts_at = $13, ends_at = $14, active = $15, updated_at = $16
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		promotion.ID, nullString(promotion.Code), promotion.Name, nullString(promotion.Description),
+		promotion.Type, promotion.Value, promotion.MinSubtotal, promotion.BuyQuantity,
+		promotion.GetQuantity, productIDs, promotion.UsageLimit, promotion.PerUserLimit,
+		promotion.StartsAt, promotion.EndsAt, promotion.Active, promotion.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("promotion code %s already exists", promotion.Code)
+		}
+		return fmt.Errorf("failed to update promotion: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// GetByID retrieves a promotion by ID
+func (r *PostgresPromotionRepository) GetByID(ctx context.Context, id string) (*models.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = $1`
+	return r.getOne(ctx, query, id)
+}
+
+// GetByCode retrieves a coupon by its code, ignoring case
+func (r *PostgresPromotionRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE UPPER(code) = UPPER($1)`
+	return r.getOne(ctx, query, code)
+}
+
+// List returns promotions, newest first
+func (r *PostgresPromotionRepository) List(ctx context.Context, limit, offset int) ([]*models.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+	return r.getMany(ctx, query, limit, offset)
+}
+
+// ListAutomatic returns the automatic promotions running at the given time
+func (r *PostgresPromotionRepository) ListAutomatic(ctx context.Context, at time.Time) ([]*models.Promotion, error) {
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE code IS NULL AND active
+		  AND (starts_at IS NULL OR starts_at <= $1)
+		  AND (ends_at IS NULL OR ends_at > $1)
+		ORDER BY created_at`
+	return r.getMany(ctx, query, at)
+}
+
+// CountRedemptions counts the user's orders that redeemed the promotion
+func (r *PostgresPromotionRepository) CountRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND user_id = $2`
+	if err := r.db.QueryRowContext(ctx, query, promotionID, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count promotion redemptions: %w", err)
+	}
+	return count, nil
+}
+
+func (r *PostgresPromotionRepository) getOne(ctx context.Context, query string, arg interface{}) (*models.Promotion, error) {
+	promotion, err := scanPromotion(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+	return promotion, nil
+}
+
+func (r *PostgresPromotionRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]*models.Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promotions []*models.Promotion
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, promotion)
+	}
+	return promotions, rows.Err()
+}
+
+func scanPromotion(row rowScanner) (*models.Promotion, error) {
+	var promotion models.Promotion
+	var code, description sql.NullString
+	var productIDs []byte
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&promotion.ID, &code, &promotion.Name, &description, &promotion.Type, &promotion.Value,
+		&promotion.MinSubtotal, &promotion.BuyQuantity, &promotion.GetQuantity, &productIDs,
+		&promotion.UsageLimit, &promotion.PerUserLimit, &promotion.UsageCount, &startsAt, &endsAt,
+		&promotion.Active, &promotion.CreatedAt, &promotion.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	promotion.Code = code.String
+	promotion.Description = description.String
+	json.Unmarshal(productIDs, &promotion.ProductIDs)
+	if startsAt.Valid {
+		promotion.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		promotion.EndsAt = &endsAt.Time
+	}
+
+	return &promotion, nil
+}
+
+func promotionProductIDs(promotion *models.Promotion) []string {
+	if promotion.ProductIDs == nil {
+		return []string{}
+	}
+	return promotion.ProductIDs
+}
+
+// saveOrderDiscounts records the order's discounts and redeems the promotions
+// behind them. Redeeming locks the promotion row, so concurrent orders cannot
+// both take the last use of a promotion or a user's last allowed use.
+func saveOrderDiscounts(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	redeemed := make(map[string]bool)
+	for i := range order.Discounts {
+		discount := &order.Discounts[i]
+		discount.OrderID = order.ID
+		if discount.CreatedAt.IsZero() {
+			discount.CreatedAt = order.CreatedAt
+		}
+
+		if discount.PromotionID != "" && !redeemed[discount.PromotionID] {
+			if err := redeemPromotion(ctx, tx, discount.PromotionID, order); err != nil {
+				return err
+			}
+			redeemed[discount.PromotionID] = true
+		}
+
+		var percentage interface{}
+		if !discount.Percentage.IsZero() {
+			percentage = discount.Percentage
+		}
+
+		query := `
+			INSERT INTO order_discounts (
+				id, order_id, promotion_id, discount_type, discount_code, discount_name,
+				discount_amount, discount_percentage, applied_to, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+		_, err := tx.ExecContext(ctx, query,
+			discount.ID, order.ID, nullString(discount.PromotionID), discount.Type,
+			nullString(discount.Code), discount.Name, discount.Amount, percentage,
+			discount.AppliedTo, discount.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert order discount: %w", err)
+		}
+	}
+	return nil
+}
+
+func redeemPromotion(ctx context.Context, tx *sql.Tx, promotionID string, order *models.Order) error {
+	var name string
+	var perUserLimit int
+	query := `
+		UPDATE promotions SET usage_count = usage_count + 1
+		WHERE id = $1 AND active AND (usage_limit = 0 OR usage_count < usage_limit)
+		RETURNING name, per_user_limit`
+	if err := tx.QueryRowContext(ctx, query, promotionID).Scan(&name, &perUserLimit); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("invalid coupon: promotion %s has ended or reached its usage limit", promotionID)
+		}
+		return fmt.Errorf("failed to redeem promotion: %w", err)
+	}
+
+	if perUserLimit > 0 {
+		var used int
+		countQuery := `SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND user_id = $2`
+		if err := tx.QueryRowContext(ctx, countQuery, promotionID, order.UserID).Scan(&used); err != nil {
+			return fmt.Errorf("failed to count promotion redemptions: %w", err)
+		}
+		if used >= perUserLimit {
+			return fmt.Errorf("invalid coupon: %s has already been used the maximum number of times", name)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO promotion_redemptions (id, promotion_id, order_id, user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New().String(), promotionID, order.ID, order.UserID, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record promotion redemption: %w", err)
+	}
+	return nil
+}
+
+// releasePromotions gives back the promotion uses of a cancelled order
+func releasePromotions(ctx context.Context, tx *sql.Tx, orderID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE promotions p SET usage_count = GREATEST(p.usage_count - 1, 0)
+		FROM promotion_redemptions r
+		WHERE r.order_id = $1 AND r.promotion_id = p.id`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to release promotion usage: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM promotion_redemptions WHERE order_id = $1`, orderID); err != nil {
+		return fmt.Errorf("failed to delete promotion redemptions: %w", err)
+	}
+	return nil
+}
+
+// getOrderDiscounts loads the discounts applied to an order
+func getOrderDiscounts(ctx context.Context, db *sql.DB, orderID string) ([]models.OrderDiscount, error) {
+	query := `
+		SELECT id, order_id, promotion_id, discount_type, discount_code, discount_name,
+			   discount_amount, discount_percentage, applied_to, created_at
+		FROM order_discounts
+		WHERE order_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order discounts: %w", err)
+	}
+	defer rows.Close()
+
+	discounts := []models.OrderDiscount{}
+	for rows.Next() {
+		var discount models.OrderDiscount
+		var promotionID, code sql.NullString
+		var percentage decimal.NullDecimal
+		err := rows.Scan(
+			&discount.ID, &discount.OrderID, &promotionID, &discount.Type, &code, &discount.Name,
+			&discount.Amount, &percentage, &discount.AppliedTo, &discount.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order discount: %w", err)
+		}
+		discount.PromotionID = promotionID.String
+		discount.Code = code.String
+		if percentage.Valid {
+			discount.Percentage = percentage.Decimal
+		}
+		discounts = append(discounts, discount)
+	}
+	return discounts, rows.Err()
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/shared/models"
+)
+
+// ReturnRepository persists returns (RMAs) and their items. Every change is
+// also written to the order's status history, in the same transaction.
+type ReturnRepository interface {
+	// Create inserts a return and its items and assigns its RMA number. It
+	// fails if an item would be returned beyond its ordered quantity, even
+	// under concurrent calls.
+	Create(ctx context.Context, ret *models.Return, reason, changedBy string) error
+	// Update saves the return's status, label, refund and inspection results
+	// if the return is still in status from. It returns ErrReturnChanged when
+	// another request moved the return on first.
+	Update(ctx context.Context, ret *models.Return, from models.ReturnStatus, reason, changedBy string) error
+	GetByID(ctx context.Context, id string) (*models.Return, error)
+	ListByOrder(ctx context.Context, orderID string) ([]models.Return, error)
+}
+
+// ErrReturnChanged is returned by Update when the return is no longer in the
+// status the caller read it in
+var ErrReturnChanged = errors.New("invalid status transition: return was changed by another request")
+
+// PostgresReturnRepository implements ReturnRepository using PostgreSQL
+type PostgresReturnRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresReturnRepository creates a new PostgreSQL return repository
+func NewPostgresReturnRepository(db *sql.DB) ReturnRepository {
+	return &PostgresReturnRepository{db: db}
+}
+
+const returnColumns = `id, rma_number, order_id, user_id, status, reason, notes, rejection_reason,
+	shipment_id, tracking_number, label_url, refund_amount, currency, refund_id,
+	approved_at, received_at, inspected_at, refunded_at, created_at, updated_at`
+
+// Create inserts a new return
+func (r *PostgresReturnRepository) Create(ctx context.Context, ret *models.Return, reason, changedBy string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent returns see each other's items
+	var orderNumber sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT order_number FROM orders WHERE id = $1 FOR UPDATE`, ret.OrderID).Scan(&orderNumber)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	for _, item := range ret.Items {
+		var ordered, returned int
+		err := tx.QueryRowContext(ctx, `
+			SELECT oi.quantity, COALESCE((
+				SELECT SUM(ri.quantity)
+				FROM order_return_items ri
+				JOIN order_returns rt ON rt.id = ri.return_id
+				WHERE ri.order_item_id = oi.id AND rt.status NOT IN ('rejected', 'cancelled')
+			), 0)
+			FROM order_items oi
+			WHERE oi.id = $1 AND oi.order_id = $2`,
+			item.OrderItemID, ret.OrderID,
+		).Scan(&ordered, &returned)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("invalid return: order item %s is not part of the order", item.OrderItemID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check returned quantity: %w", err)
+		}
+		if returned+item.Quantity > ordered {
+			return fmt.Errorf("invalid return: only %d of order item %s can still be returned", ordered-returned, item.OrderItemID)
+		}
+	}
+
+	if ret.RMANumber == "" {
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM order_returns WHERE order_id = $1`, ret.OrderID).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count returns: %w", err)
+		}
+		ret.RMANumber = models.ReturnNumber(orderNumber.String, count+1)
+	}
+
+	query := `
+		INSERT INTO order_returns (` + returnColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
+
+	_, err = tx.ExecContext(ctx, query,
+		ret.ID, ret.RMANumber, ret.OrderID, ret.UserID, ret.Status, ret.Reason, nullString(ret.Notes),
+		nullString(ret.RejectionReason), nullString(ret.ShipmentID), nullString(ret.TrackingNumber),
+		nullString(ret.LabelURL), ret.RefundAmount, ret.Currency, nullString(ret.RefundID),
+		ret.ApprovedAt, ret.ReceivedAt, ret.InspectedAt, ret.RefundedAt, ret.CreatedAt, ret.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert return: %w", err)
+	}
+
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		if item.ID == "" {
+			item.ID = uuid.New().String()
+		}
+		item.ReturnID = ret.ID
+		item.CreatedAt = ret.CreatedAt
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_return_items (id, return_id, order_item_id, product_id, variant_id, quantity, reason,
+				accepted_quantity, restocked_quantity, condition, refund_amount, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			item.ID, item.ReturnID, item.OrderItemID, item.ProductID, nullString(item.VariantID), item.Quantity,
+			nullString(item.Reason), item.AcceptedQuantity, item.RestockedQuantity, nullString(item.Condition),
+			item.RefundAmount, item.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert return item: %w", err)
+		}
+	}
+
+	if err := recordReturnStep(ctx, tx, ret, reason, changedBy); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves changes to a return and its items, provided the return is
+// still in status from
+func (r *PostgresReturnRepository) Update(ctx context.Context, ret *models.Return, from models.ReturnStatus, reason, changedBy string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ret.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE order_returns SET
+			status = $2, notes = $3, rejection_reason = $4, shipment_id = $5, tracking_number = $6,
+			label_url = $7, refund_amount = $8, refund_id = $9, approved_at = $10, received_at = $11,
+			inspected_at = $12, refunded_at = $13, updated_at = $14
+		WHERE id = $1 AND status = $15`
+
+	result, err := tx.ExecContext(ctx, query,
+		ret.ID, ret.Status, nullString(ret.Notes), nullString(ret.RejectionReason), nullString(ret.ShipmentID),
+		nullString(ret.TrackingNumber), nullString(ret.LabelURL), ret.RefundAmount, nullString(ret.RefundID),
+		ret.ApprovedAt, ret.ReceivedAt, ret.InspectedAt, ret.RefundedAt, ret.UpdatedAt, from,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update return: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM order_returns WHERE id = $1)`, ret.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check return: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("return not found")
+		}
+		return ErrReturnChanged
+	}
+
+	for _, item := range ret.Items {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE order_return_items SET
+				accepted_quantity = $2, restocked_quantity = $3, condition = $4, refund_amount = $5
+			WHERE id = $1`,
+			item.ID, item.AcceptedQuantity, item.RestockedQuantity, nullString(item.Condition), item.RefundAmount,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update return item: %w", err)
+		}
+	}
+
+	if err := recordReturnStep(ctx, tx, ret, reason, changedBy); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID retrieves a return and its items
+func (r *PostgresReturnRepository) GetByID(ctx context.Context, id string) (*models.Return, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+returnColumns+` FROM order_returns WHERE id = $1`, id)
+	ret, err := scanReturn(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("return not found")
+		}
+		return nil, fmt.Errorf("failed to get return: %w", err)
+	}
+
+	items, err := r.getReturnItems(ctx, `WHERE ri.return_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Items = items[ret.ID]; ret.Items == nil {
+		ret.Items = []models.ReturnItem{}
+	}
+	return ret, nil
+}
+
+// ListByOrder returns an order's returns, oldest first
+func (r *PostgresReturnRepository) ListByOrder(ctx context.Context, orderID string) ([]models.Return, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+returnColumns+` FROM order_returns WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query returns: %w", err)
+	}
+	defer rows.Close()
+
+	returns := []models.Return{}
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan return: %w", err)
+		}
+		returns = append(returns, *ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := r.getReturnItems(ctx, `JOIN order_returns rt ON rt.id = ri.return_id WHERE rt.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range returns {
+		if returns[i].Items = items[returns[i].ID]; returns[i].Items == nil {
+			returns[i].Items = []models.ReturnItem{}
+		}
+	}
+	return returns, nil
+}
+
+// getReturnItems returns return items matching the clause, keyed by return ID
+func (r *PostgresReturnRepository) getReturnItems(ctx context.Context, clause string, arg interface{}) (map[string][]models.ReturnItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ri.id, ri.return_id, ri.order_item_id, ri.product_id, ri.variant_id, ri.quantity, ri.reason,
+			ri.accepted_quantity, ri.restocked_quantity, ri.condition, ri.refund_amount, ri.created_at
+		FROM order_return_items ri `+clause+`
+		ORDER BY ri.created_at, ri.id`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string][]models.ReturnItem)
+	for rows.Next() {
+		var item models.ReturnItem
+		var variantID, reason, condition sql.NullString
+		err := rows.Scan(
+			&item.ID, &item.ReturnID, &item.OrderItemID, &item.ProductID, &variantID, &item.Quantity, &reason,
+			&item.AcceptedQuantity, &item.RestockedQuantity, &condition, &item.RefundAmount, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan return item: %w", err)
+		}
+		item.VariantID = variantID.String
+		item.Reason = reason.String
+		item.Condition = condition.String
+		items[item.ReturnID] = append(items[item.ReturnID], item)
+	}
+	return items, rows.Err()
+}
+
+// recordReturnStep writes a return step to the order's status history. The
+// order's status is unchanged, so it is both the from and to status.
+func recordReturnStep(ctx context.Context, tx *sql.Tx, ret *models.Return, reason, changedBy string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (id, order_id, from_status, to_status, reason, notes, changed_by, created_at)
+		SELECT $1, id, status, status, $2, $3, $4, $5 FROM orders WHERE id = $6`,
+		uuid.New().String(), reason, ret.RMANumber, nullString(changedBy), time.Now(), ret.OrderID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record return in order history: %w", err)
+	}
+	return nil
+}
+
+func scanReturn(row rowScanner) (*models.Return, error) {
+	var ret models.Return
+	var notes, rejectionReason, shipmentID, trackingNumber, labelURL, refundID sql.NullString
+	var approvedAt, receivedAt, inspectedAt, refundedAt sql.NullTime
+
+	err := row.Scan(
+		&ret.ID, &ret.RMANumber, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Reason, &notes, &rejectionReason,
+		&shipmentID, &trackingNumber, &labelURL, &ret.RefundAmount, &ret.Currency, &refundID,
+		&approvedAt, &receivedAt, &inspectedAt, &refundedAt, &ret.CreatedAt, &ret.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Notes = notes.String
+	ret.RejectionReason = rejectionReason.String
+	ret.ShipmentID = shipmentID.String
+	ret.TrackingNumber = trackingNumber.String
+	ret.LabelURL = labelURL.String
+	ret.RefundID = refundID.String
+	if approvedAt.Valid {
+		ret.ApprovedAt = &approvedAt.Time
+	}
+	if receivedAt.Valid {
+		ret.ReceivedAt = &receivedAt.Time
+	}
+	if inspectedAt.Valid {
+		ret.InspectedAt = &inspectedAt.Time
+	}
+	if refundedAt.Valid {
+		ret.RefundedAt = &refundedAt.Time
+	}
+	return &ret, nil
+}
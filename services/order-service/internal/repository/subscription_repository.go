@@ -0,0 +1,524 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// SubscriptionRepository persists subscription plans, subscriptions and
+// their renewal attempts
+type SubscriptionRepository interface {
+	CreatePlan(ctx context.Context, plan *models.SubscriptionPlan) error
+	UpdatePlan(ctx context.Context, plan *models.SubscriptionPlan) error
+	GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error)
+	// ListPlans returns plans, newest first; an empty productID lists every product's plans
+	ListPlans(ctx context.Context, productID string, activeOnly bool, limit, offset int) ([]*models.SubscriptionPlan, error)
+
+	Create(ctx context.Context, subscription *models.Subscription) error
+	// Update saves the subscription if it is still at its version, and bumps
+	// the version. It returns ErrSubscriptionChanged when the subscription was
+	// changed since it was read.
+	Update(ctx context.Context, subscription *models.Subscription) error
+	GetByID(ctx context.Context, id string) (*models.Subscription, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.Subscription, error)
+	// ClaimDue returns billable subscriptions due at or before at and paused
+	// ones whose resume time has come. Claimed subscriptions are pushed back
+	// to leaseUntil, so concurrent schedulers do not pick them up again and a
+	// crashed one's work is retried once the lease runs out.
+	ClaimDue(ctx context.Context, at, leaseUntil time.Time, limit int) ([]*models.Subscription, error)
+
+	CreateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error
+	// BeginRenewal records a pending renewal and bumps the subscription's
+	// version, so changes made while the renewal is charged are refused. It
+	// returns ErrSubscriptionChanged when the subscription was changed since
+	// it was read.
+	BeginRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error
+	// UpdateRenewal saves the progress of a pending renewal: its order and payment
+	UpdateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error
+	// CompleteRenewal saves the outcome of a renewal together with the
+	// subscription it moved on, in one transaction
+	CompleteRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error
+	// GetPendingRenewal returns the subscription's pending renewal, or nil if
+	// it has none
+	GetPendingRenewal(ctx context.Context, subscriptionID string) (*models.SubscriptionRenewal, error)
+	// ListRenewals returns a subscription's renewal attempts, newest first
+	ListRenewals(ctx context.Context, subscriptionID string) ([]*models.SubscriptionRenewal, error)
+}
+
+// ErrSubscriptionChanged is returned when a subscription is saved from a
+// copy older than the stored one
+var ErrSubscriptionChanged = errors.New("invalid status transition: subscription was changed by another request")
+
+// PostgresSubscriptionRepository implements SubscriptionRepository using PostgreSQL
+type PostgresSubscriptionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSubscriptionRepository creates a new PostgreSQL subscription repository
+func NewPostgresSubscriptionRepository(db *sql.DB) SubscriptionRepository {
+	return &PostgresSubscriptionRepository{db: db}
+}
+
+const subscriptionPlanColumns = `id, product_id, variant_id, name, description, interval, interval_count,
+	trial_days, price, currency, active, created_at, updated_at`
+
+const subscriptionColumns = `id, user_id, plan_id, quantity, status, email, payment_method_id,
+	shipping_address, billing_address, shipping_method_id, trial_ends_at, current_period_start,
+	current_period_end, next_billing_at, failed_attempts, cancel_at_period_end, paused_at, resume_at,
+	cancelled_at, cancellation_reason, last_order_id, version, created_at, updated_at`
+
+const subscriptionRenewalColumns = `id, subscription_id, period_start, period_end, status, attempt,
+	order_id, payment_id, amount, currency, failure_reason, created_at`
+
+// CreatePlan inserts a new subscription plan
+func (r *PostgresSubscriptionRepository) CreatePlan(ctx context.Context, plan *models.SubscriptionPlan) error {
+	query := `
+		INSERT INTO subscription_plans (` + subscriptionPlanColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		plan.ID, plan.ProductID, nullString(plan.VariantID), plan.Name, plan.Description, plan.Interval,
+		plan.IntervalCount, plan.TrialDays, plan.Price, plan.Currency, plan.Active, plan.CreatedAt, plan.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription plan: %w", err)
+	}
+	return nil
+}
+
+// UpdatePlan saves changes to a subscription plan. Price changes apply from
+// each subscription's next renewal.
+func (r *PostgresSubscriptionRepository) UpdatePlan(ctx context.Context, plan *models.SubscriptionPlan) error {
+	plan.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE subscription_plans SET
+			name = $2, description = $3, interval = $4, interval_count = $5, trial_days = $6,
+			price = $7, currency = $8, active = $9, updated_at = $10
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		plan.ID, plan.Name, plan.Description, plan.Interval, plan.IntervalCount, plan.TrialDays,
+		plan.Price, plan.Currency, plan.Active, plan.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription plan: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("subscription plan not found")
+	}
+	return nil
+}
+
+// GetPlan retrieves a subscription plan by ID
+func (r *PostgresSubscriptionRepository) GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error) {
+	query := `SELECT ` + subscriptionPlanColumns + ` FROM subscription_plans WHERE id = $1`
+
+	plan, err := scanSubscriptionPlan(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("subscription plan not found")
+		}
+		return nil, fmt.Errorf("failed to get subscription plan: %w", err)
+	}
+	return plan, nil
+}
+
+// ListPlans returns subscription plans, optionally of one product
+func (r *PostgresSubscriptionRepository) ListPlans(ctx context.Context, productID string, activeOnly bool, limit, offset int) ([]*models.SubscriptionPlan, error) {
+	query := `
+		SELECT ` + subscriptionPlanColumns + `
+		FROM subscription_plans
+		WHERE ($1 = '' OR product_id = $1) AND (active OR NOT $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.QueryContext(ctx, query, productID, activeOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []*models.SubscriptionPlan
+	for rows.Next() {
+		plan, err := scanSubscriptionPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription plan: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+// Create inserts a new subscription
+func (r *PostgresSubscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+	shippingAddr, _ := json.Marshal(subscription.ShippingAddress)
+	billingAddr, _ := json.Marshal(subscription.BillingAddress)
+	subscription.Version = 1
+
+	query := `
+		INSERT INTO subscriptions (` + subscriptionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		subscription.ID, subscription.UserID, subscription.PlanID, subscription.Quantity, subscription.Status,
+		subscription.Email, subscription.PaymentMethodID, shippingAddr, billingAddr, subscription.ShippingMethodID,
+		subscription.TrialEndsAt, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd,
+		subscription.NextBillingAt, subscription.FailedAttempts, subscription.CancelAtPeriodEnd,
+		subscription.PausedAt, subscription.ResumeAt, subscription.CancelledAt, subscription.CancellationReason,
+		nullString(subscription.LastOrderID), subscription.Version, subscription.CreatedAt, subscription.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+	return nil
+}
+
+// Update saves a subscription's status, schedule and payment details
+func (r *PostgresSubscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+	return updateSubscription(ctx, r.db, subscription)
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// updateSubscription saves a subscription if it is still at the version it
+// was read at, and moves it to the next version
+func updateSubscription(ctx context.Context, db execQuerier, subscription *models.Subscription) error {
+	updatedAt := time.Now()
+	shippingAddr, _ := json.Marshal(subscription.ShippingAddress)
+	billingAddr, _ := json.Marshal(subscription.BillingAddress)
+
+	query := `
+		UPDATE subscriptions SET
+			quantity = $2, status = $3, email = $4, payment_method_id = $5, shipping_address = $6,
+			billing_address = $7, shipping_method_id = $8, trial_ends_at = $9, current_period_start = $10,
+			current_period_end = $11, next_billing_at = $12, failed_attempts = $13, cancel_at_period_end = $14,
+			paused_at = $15, resume_at = $16, cancelled_at = $17, cancellation_reason = $18,
+			last_order_id = $19, updated_at = $20, version = version + 1
+		WHERE id = $1 AND version = $21`
+
+	result, err := db.ExecContext(ctx, query,
+		subscription.ID, subscription.Quantity, subscription.Status, subscription.Email,
+		subscription.PaymentMethodID, shippingAddr, billingAddr, subscription.ShippingMethodID,
+		subscription.TrialEndsAt, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd,
+		subscription.NextBillingAt, subscription.FailedAttempts, subscription.CancelAtPeriodEnd,
+		subscription.PausedAt, subscription.ResumeAt, subscription.CancelledAt, subscription.CancellationReason,
+		nullString(subscription.LastOrderID), updatedAt, subscription.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return subscriptionUpdateMissed(ctx, db, subscription.ID)
+	}
+
+	subscription.UpdatedAt = updatedAt
+	subscription.Version++
+	return nil
+}
+
+// bumpSubscriptionVersion moves a subscription to its next version without
+// changing it, provided it is still at the version it was read at
+func bumpSubscriptionVersion(ctx context.Context, db execQuerier, subscription *models.Subscription) error {
+	result, err := db.ExecContext(ctx,
+		`UPDATE subscriptions SET version = version + 1 WHERE id = $1 AND version = $2`,
+		subscription.ID, subscription.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return subscriptionUpdateMissed(ctx, db, subscription.ID)
+	}
+
+	subscription.Version++
+	return nil
+}
+
+// subscriptionUpdateMissed explains why a versioned update changed no rows
+func subscriptionUpdateMissed(ctx context.Context, db execQuerier, id string) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("subscription not found")
+	}
+	return ErrSubscriptionChanged
+}
+
+// GetByID retrieves a subscription by ID
+func (r *PostgresSubscriptionRepository) GetByID(ctx context.Context, id string) (*models.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+
+	subscription, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("subscription not found")
+		}
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// ListByUser returns a user's subscriptions, newest first
+func (r *PostgresSubscriptionRepository) ListByUser(ctx context.Context, userID string) ([]*models.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.getMany(ctx, query, userID)
+}
+
+// ClaimDue claims due subscriptions for the scheduler. SKIP LOCKED lets
+// concurrent schedulers claim disjoint batches. Claiming bumps the version,
+// so a change made from a copy read before the claim cannot undo it.
+func (r *PostgresSubscriptionRepository) ClaimDue(ctx context.Context, at, leaseUntil time.Time, limit int) ([]*models.Subscription, error) {
+	query := `
+		UPDATE subscriptions SET
+			next_billing_at = CASE WHEN status = 'paused' THEN next_billing_at ELSE $2 END,
+			resume_at = CASE WHEN status = 'paused' THEN $2 ELSE resume_at END,
+			version = version + 1
+		WHERE id IN (
+			SELECT id FROM subscriptions
+			WHERE (status IN ('trialing', 'active', 'past_due') AND next_billing_at <= $1)
+			   OR (status = 'paused' AND resume_at <= $1)
+			ORDER BY next_billing_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + subscriptionColumns
+
+	return r.getMany(ctx, query, at, leaseUntil, limit)
+}
+
+// CreateRenewal records a renewal attempt
+func (r *PostgresSubscriptionRepository) CreateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error {
+	return insertRenewal(ctx, r.db, renewal)
+}
+
+// BeginRenewal records a pending renewal and moves the subscription to its
+// next version in one transaction
+func (r *PostgresSubscriptionRepository) BeginRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := bumpSubscriptionVersion(ctx, tx, subscription); err != nil {
+		return err
+	}
+	if err := insertRenewal(ctx, tx, renewal); err != nil {
+		subscription.Version--
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		subscription.Version--
+		return fmt.Errorf("failed to commit subscription renewal: %w", err)
+	}
+	return nil
+}
+
+// UpdateRenewal saves a renewal's order, payment and outcome
+func (r *PostgresSubscriptionRepository) UpdateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error {
+	return updateRenewal(ctx, r.db, renewal)
+}
+
+// CompleteRenewal saves a renewal's outcome and the subscription it moved on
+// in one transaction, so a paid period is never left without the
+// subscription advancing past it
+func (r *PostgresSubscriptionRepository) CompleteRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateRenewal(ctx, tx, renewal); err != nil {
+		return err
+	}
+	if err := updateSubscription(ctx, tx, subscription); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		subscription.Version--
+		return fmt.Errorf("failed to commit subscription renewal: %w", err)
+	}
+	return nil
+}
+
+// GetPendingRenewal returns the subscription's renewal in progress, if any
+func (r *PostgresSubscriptionRepository) GetPendingRenewal(ctx context.Context, subscriptionID string) (*models.SubscriptionRenewal, error) {
+	query := `
+		SELECT ` + subscriptionRenewalColumns + `
+		FROM subscription_renewals
+		WHERE subscription_id = $1 AND status = 'pending'`
+
+	renewal, err := scanSubscriptionRenewal(r.db.QueryRowContext(ctx, query, subscriptionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending subscription renewal: %w", err)
+	}
+	return renewal, nil
+}
+
+func insertRenewal(ctx context.Context, db execQuerier, renewal *models.SubscriptionRenewal) error {
+	query := `
+		INSERT INTO subscription_renewals (` + subscriptionRenewalColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := db.ExecContext(ctx, query,
+		renewal.ID, renewal.SubscriptionID, renewal.PeriodStart, renewal.PeriodEnd, renewal.Status,
+		renewal.Attempt, nullString(renewal.OrderID), nullString(renewal.PaymentID), renewal.Amount,
+		renewal.Currency, renewal.FailureReason, renewal.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record subscription renewal: %w", err)
+	}
+	return nil
+}
+
+func updateRenewal(ctx context.Context, db execQuerier, renewal *models.SubscriptionRenewal) error {
+	query := `
+		UPDATE subscription_renewals SET
+			status = $2, order_id = $3, payment_id = $4, amount = $5, currency = $6, failure_reason = $7
+		WHERE id = $1`
+
+	result, err := db.ExecContext(ctx, query,
+		renewal.ID, renewal.Status, nullString(renewal.OrderID), nullString(renewal.PaymentID),
+		renewal.Amount, renewal.Currency, renewal.FailureReason,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription renewal: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("subscription renewal not found")
+	}
+	return nil
+}
+
+// ListRenewals returns the renewal attempts of a subscription
+func (r *PostgresSubscriptionRepository) ListRenewals(ctx context.Context, subscriptionID string) ([]*models.SubscriptionRenewal, error) {
+	query := `
+		SELECT ` + subscriptionRenewalColumns + `
+		FROM subscription_renewals
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription renewals: %w", err)
+	}
+	defer rows.Close()
+
+	var renewals []*models.SubscriptionRenewal
+	for rows.Next() {
+		renewal, err := scanSubscriptionRenewal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription renewal: %w", err)
+		}
+		renewals = append(renewals, renewal)
+	}
+	return renewals, rows.Err()
+}
+
+func (r *PostgresSubscriptionRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]*models.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*models.Subscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func scanSubscriptionPlan(row rowScanner) (*models.SubscriptionPlan, error) {
+	var plan models.SubscriptionPlan
+	var variantID sql.NullString
+
+	err := row.Scan(
+		&plan.ID, &plan.ProductID, &variantID, &plan.Name, &plan.Description, &plan.Interval,
+		&plan.IntervalCount, &plan.TrialDays, &plan.Price, &plan.Currency, &plan.Active,
+		&plan.CreatedAt, &plan.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.VariantID = variantID.String
+	return &plan, nil
+}
+
+func scanSubscriptionRenewal(row rowScanner) (*models.SubscriptionRenewal, error) {
+	var renewal models.SubscriptionRenewal
+	var orderID, paymentID sql.NullString
+
+	err := row.Scan(
+		&renewal.ID, &renewal.SubscriptionID, &renewal.PeriodStart, &renewal.PeriodEnd, &renewal.Status,
+		&renewal.Attempt, &orderID, &paymentID, &renewal.Amount, &renewal.Currency,
+		&renewal.FailureReason, &renewal.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	renewal.OrderID = orderID.String
+	renewal.PaymentID = paymentID.String
+	return &renewal, nil
+}
+
+func scanSubscription(row rowScanner) (*models.Subscription, error) {
+	var subscription models.Subscription
+	var shippingAddr, billingAddr []byte
+	var trialEndsAt, pausedAt, resumeAt, cancelledAt sql.NullTime
+	var lastOrderID sql.NullString
+
+	err := row.Scan(
+		&subscription.ID, &subscription.UserID, &subscription.PlanID, &subscription.Quantity,
+		&subscription.Status, &subscription.Email, &subscription.PaymentMethodID, &shippingAddr,
+		&billingAddr, &subscription.ShippingMethodID, &trialEndsAt, &subscription.CurrentPeriodStart,
+		&subscription.CurrentPeriodEnd, &subscription.NextBillingAt, &subscription.FailedAttempts,
+		&subscription.CancelAtPeriodEnd, &pausedAt, &resumeAt, &cancelledAt,
+		&subscription.CancellationReason, &lastOrderID, &subscription.Version, &subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(shippingAddr, &subscription.ShippingAddress)
+	json.Unmarshal(billingAddr, &subscription.BillingAddress)
+	subscription.LastOrderID = lastOrderID.String
+	if trialEndsAt.Valid {
+		subscription.TrialEndsAt = &trialEndsAt.Time
+	}
+	if pausedAt.Valid {
+		subscription.PausedAt = &pausedAt.Time
+	}
+	if resumeAt.Valid {
+		subscription.ResumeAt = &resumeAt.Time
+	}
+	if cancelledAt.Valid {
+		subscription.CancelledAt = &cancelledAt.Time
+	}
+
+	return &subscription, nil
+}
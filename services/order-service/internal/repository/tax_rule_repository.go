@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// TaxRuleRepository persists the rules used by the tax engine
+type TaxRuleRepository interface {
+	Create(ctx context.Context, rule *models.TaxRule) error
+	Update(ctx context.Context, rule *models.TaxRule) error
+	Delete(ctx context.Context, id string) error
+	GetByID(ctx context.Context, id string) (*models.TaxRule, error)
+	List(ctx context.Context) ([]*models.TaxRule, error)
+	// ListApplicable returns the active rules for a country, including rules that apply to every country
+	ListApplicable(ctx context.Context, country string) ([]models.TaxRule, error)
+}
+
+// PostgresTaxRuleRepository implements TaxRuleRepository using PostgreSQL
+type PostgresTaxRuleRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresTaxRuleRepository creates a new PostgreSQL tax rule repository
+func NewPostgresTaxRuleRepository(db *sql.DB) TaxRuleRepository {
+	return &PostgresTaxRuleRepository{db: db}
+}
+
+const taxRuleColumns = `id, name, country, state, postal_code_prefix, tax_class, rate,
+	inclusive, apply_to_shipping, active, created_at, updated_at`
+
+// Create inserts a new tax rule
+func (r *PostgresTaxRuleRepository) Create(ctx context.Context, rule *models.TaxRule) error {
+	query := `
+		INSERT INTO tax_rules (` + taxRuleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		rule.ID, rule.Name, rule.Country, rule.State, rule.PostalCodePrefix, rule.TaxClass,
+		rule.Rate, rule.Inclusive, rule.ApplyToShipping, rule.Active, rule.CreatedAt, rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create tax rule: %w", err)
+	}
+	return nil
+}
+
+// Update saves changes to a tax rule
+func (r *PostgresTaxRuleRepository) Update(ctx context.Context, rule *models.TaxRule) error {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE tax_rules SET
+			name = $2, country = $3, state = $4, postal_code_prefix = $5, tax_class = $6,
+			rate = $7, inclusive = $8, apply_to_shipping = $9, active = $10, updated_at = $11
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		rule.ID, rule.Name, rule.Country, rule.State, rule.PostalCodePrefix, rule.TaxClass,
+		rule.Rate, rule.Inclusive, rule.ApplyToShipping, rule.Active, rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update tax rule: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("tax rule not found")
+	}
+	return nil
+}
+
+// Delete removes a tax rule
+func (r *PostgresTaxRuleRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tax_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tax rule: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("tax rule not found")
+	}
+	return nil
+}
+
+// GetByID retrieves a tax rule by ID
+func (r *PostgresTaxRuleRepository) GetByID(ctx context.Context, id string) (*models.TaxRule, error) {
+	query := `SELECT ` + taxRuleColumns + ` FROM tax_rules WHERE id = $1`
+
+	var rule models.TaxRule
+	if err := scanTaxRule(r.db.QueryRowContext(ctx, query, id), &rule); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("tax rule not found")
+		}
+		return nil, fmt.Errorf("failed to get tax rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// List returns every tax rule, active or not
+func (r *PostgresTaxRuleRepository) List(ctx context.Context) ([]*models.TaxRule, error) {
+	query := `SELECT ` + taxRuleColumns + ` FROM tax_rules ORDER BY country, state, postal_code_prefix, tax_class, name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.TaxRule
+	for rows.Next() {
+		var rule models.TaxRule
+		if err := scanTaxRule(rows, &rule); err != nil {
+			return nil, fmt.Errorf("failed to scan tax rule: %w", err)
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// ListApplicable returns active rules for the country or for every country
+func (r *PostgresTaxRuleRepository) ListApplicable(ctx context.Context, country string) ([]models.TaxRule, error) {
+	query := `
+		SELECT ` + taxRuleColumns + `
+		FROM tax_rules
+		WHERE active AND (country = '' OR UPPER(country) = UPPER($1))
+		ORDER BY country, state, postal_code_prefix, name`
+
+	rows, err := r.db.QueryContext(ctx, query, country)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applicable tax rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.TaxRule
+	for rows.Next() {
+		var rule models.TaxRule
+		if err := scanTaxRule(rows, &rule); err != nil {
+			return nil, fmt.Errorf("failed to scan tax rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func scanTaxRule(row rowScanner, rule *models.TaxRule) error {
+	return row.Scan(
+		&rule.ID, &rule.Name, &rule.Country, &rule.State, &rule.PostalCodePrefix, &rule.TaxClass,
+		&rule.Rate, &rule.Inclusive, &rule.ApplyToShipping, &rule.Active, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+}
@@ -0,0 +1,825 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// CheckoutService turns a cart into a paid, shipped order
+type CheckoutService interface {
+	Checkout(ctx context.Context, req *CheckoutRequest) (*models.CheckoutSaga, error)
+	GetCheckout(ctx context.Context, id string) (*models.CheckoutSaga, error)
+	QuoteShipping(ctx context.Context, orderID string) ([]*models.ShippingQuote, error)
+	RecoverStalled(ctx context.Context, olderThan time.Duration) (int, error)
+	ResumeReviewed(ctx context.Context) (int, error)
+}
+
+// CheckoutRequest represents a request to check out the caller's cart
+type CheckoutRequest struct {
+	UserID           string               `json:"user_id" validate:"required"`
+	SessionID        string               `json:"session_id"`
+	ShippingAddress  models.Address       `json:"shipping_address" validate:"required"`
+	BillingAddress   models.Address       `json:"billing_address" validate:"required"`
+	PaymentMethod    models.PaymentMethod `json:"payment_method" validate:"required"`
+	PaymentMethodID  string               `json:"payment_method_id"` // optional when stored value covers the order
+	ShippingMethodID string               `json:"shipping_method_id" validate:"required"`
+	Notes            string               `json:"notes"`
+	CouponCodes      []string             `json:"coupon_codes"` // in addition to those applied to the cart
+	// StoredValue pays as much of the order as it holds; the payment method pays the rest
+	StoredValue *StoredValueTender `json:"stored_value,omitempty"`
+	// ClientIP is the customer's address, set by the handler for the payment risk checks
+	ClientIP string `json:"-"`
+}
+
+// StoredValueTender is a gift card or the customer's wallet used at checkout
+type StoredValueTender struct {
+	Type         models.PaymentType `json:"type"` // gift_card or wallet
+	GiftCardCode string             `json:"gift_card_code,omitempty"`
+}
+
+// CartService interface for the cart-service operations checkout needs
+type CartService interface {
+	GetCart(ctx context.Context, userID, sessionID string) (*models.Cart, error)
+	ValidateCart(ctx context.Context, userID, sessionID string) (*CartValidation, error)
+	ClearCart(ctx context.Context, userID, sessionID string) error
+}
+
+// CartValidation mirrors cart-service's cart validation result
+type CartValidation struct {
+	IsValid          bool              `json:"is_valid"`
+	InvalidItems     []CartIssue       `json:"invalid_items,omitempty"`
+	PriceChanges     []CartPriceChange `json:"price_changes,omitempty"`
+	UnavailableItems []CartIssue       `json:"unavailable_items,omitempty"`
+}
+
+// CartIssue is a cart item that cannot be ordered
+type CartIssue struct {
+	ProductID string `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// CartPriceChange is a cart item whose price changed since it was added
+type CartPriceChange struct {
+	ProductID string          `json:"product_id"`
+	SKU       string          `json:"sku"`
+	Name      string          `json:"name"`
+	OldPrice  decimal.Decimal `json:"old_price"`
+	NewPrice  decimal.Decimal `json:"new_price"`
+}
+
+// PaymentService interface for the payment-service operations checkout needs
+type PaymentService interface {
+	CreatePayment(ctx context.Context, req *PaymentRequest) (*models.Payment, error)
+	ProcessPayment(ctx context.Context, paymentID string) (*models.Payment, error)
+	GetPayment(ctx context.Context, paymentID string) (*models.Payment, error)
+	CancelPayment(ctx context.Context, paymentID, reason string) error
+	RefundPayment(ctx context.Context, paymentID, reason string) error
+}
+
+// PaymentRequest represents a payment to create for an order.
+// IdempotencyKey is sent as the request's Idempotency-Key header so a retried
+// request does not create a second payment.
+type PaymentRequest struct {
+	IdempotencyKey  string          `json:"-"`
+	OrderID         string          `json:"order_id"`
+	UserID          string          `json:"user_id"`
+	Amount          decimal.Decimal `json:"amount"`
+	Currency        string          `json:"currency"`
+	PaymentMethodID string          `json:"payment_method_id"`
+	Description     string          `json:"description"`
+	AutoCapture     bool            `json:"auto_capture"`
+	// Risk carries the checkout signals payment-service scores the payment on
+	Risk models.PaymentRiskContext `json:"risk"`
+	// Type, GiftCardCode and AllowPartial describe a stored-value payment;
+	// card payments leave them empty
+	Type         models.PaymentType `json:"type,omitempty"`
+	GiftCardCode string             `json:"gift_card_code,omitempty"`
+	AllowPartial bool               `json:"allow_partial,omitempty"`
+}
+
+// ShippingService interface for the shipping-service operations checkout needs
+type ShippingService interface {
+	CreateShipment(ctx context.Context, req *ShipmentRequest) (*models.Shipment, error)
+	GetShippingQuotes(ctx context.Context, req *models.ShippingQuoteRequest) ([]*models.ShippingQuote, error)
+}
+
+// ShipmentRequest represents a shipment to create for an order
+type ShipmentRequest struct {
+	OrderID          string          `json:"order_id"`
+	UserID           string          `json:"user_id"`
+	ShippingMethodID string          `json:"shipping_method_id"`
+	FromAddress      models.Address  `json:"from_address"`
+	ToAddress        models.Address  `json:"to_address"`
+	WeightKg         decimal.Decimal `json:"weight_kg"`
+	DeclaredValue    decimal.Decimal `json:"declared_value"`
+}
+
+// CheckoutConfig holds checkout settings
+type CheckoutConfig struct {
+	// WarehouseAddress is the ship-from address for orders whose stock was
+	// not allocated to a warehouse
+	WarehouseAddress models.Address
+	// DefaultItemWeightKg is used for products without a weight attribute
+	DefaultItemWeightKg decimal.Decimal
+}
+
+// DefaultCheckoutConfig returns the default checkout configuration
+func DefaultCheckoutConfig() CheckoutConfig {
+	return CheckoutConfig{
+		WarehouseAddress: models.Address{
+			Street:     "1 Fulfillment Way",
+			City:       "Columbus",
+			State:      "OH",
+			PostalCode: "43215",
+			Country:    "US",
+		},
+		DefaultItemWeightKg: decimal.NewFromFloat(0.5),
+	}
+}
+
+// CheckoutError reports the saga step that failed. By the time it is returned
+// the steps already taken have been compensated.
+type CheckoutError struct {
+	CheckoutID string
+	Step       models.CheckoutStep
+	Err        error
+}
+
+func (e *CheckoutError) Error() string {
+	return fmt.Sprintf("checkout failed at %s: %v", e.Step, e.Err)
+}
+
+func (e *CheckoutError) Unwrap() error {
+	return e.Err
+}
+
+// errPaymentUnderReview pauses a checkout whose payment the risk engine held
+// for review; the checkout resumes once the review is decided
+var errPaymentUnderReview = errors.New("payment is held for risk review")
+
+// checkoutService implements CheckoutService
+type checkoutService struct {
+	repo             repository.CheckoutRepository
+	orderRepo        repository.OrderRepository
+	orders           *orderService
+	inventoryService InventoryService
+	cartService      CartService
+	paymentService   PaymentService
+	shippingService  ShippingService
+	config           CheckoutConfig
+}
+
+// NewCheckoutService creates a new checkout service
+func NewCheckoutService(
+	repo repository.CheckoutRepository,
+	orderRepo repository.OrderRepository,
+	productService ProductService,
+	inventoryService InventoryService,
+	cartService CartService,
+	paymentService PaymentService,
+	shippingService ShippingService,
+	taxCalculator TaxCalculator,
+	promotions PromotionEngine,
+	orderNumbers OrderNumberAllocator,
+	rates ExchangeRates,
+	config CheckoutConfig,
+) CheckoutService {
+	if taxCalculator == nil {
+		taxCalculator = DefaultTaxCalculator()
+	}
+	if promotions == nil {
+		promotions = noPromotions{}
+	}
+	if orderNumbers == nil {
+		orderNumbers = NewOrderNumberAllocator(nil, DefaultOrderNumberFormat())
+	}
+	return &checkoutService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		// Stock is reserved by its own saga step, so the order service used
+		// here must not reserve it again
+		orders: &orderService{
+			repo:           orderRepo,
+			productService: productService,
+			taxCalculator:  taxCalculator,
+			promotions:     promotions,
+			orderNumbers:   orderNumbers,
+			rates:          rates,
+		},
+		inventoryService: inventoryService,
+		cartService:      cartService,
+		paymentService:   paymentService,
+		shippingService:  shippingService,
+		config:           config,
+	}
+}
+
+// checkoutState carries data between the steps of one checkout
+type checkoutState struct {
+	saga  *models.CheckoutSaga
+	req   *CheckoutRequest
+	cart  *models.Cart
+	order *models.Order
+	// resumed is set when a checkout continues after its payment review
+	resumed bool
+}
+
+type checkoutStep struct {
+	name models.CheckoutStep
+	run  func(ctx context.Context, state *checkoutState) error
+}
+
+// Checkout runs the checkout saga. If a step fails, the steps already taken
+// are compensated and a *CheckoutError is returned along with the saga. If
+// the payment is held for risk review, the saga is returned awaiting review
+// and the order stays pending until ResumeReviewed picks it up again.
+func (s *checkoutService) Checkout(ctx context.Context, req *CheckoutRequest) (*models.CheckoutSaga, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.StoredValue != nil && !req.StoredValue.Type.IsStoredValue() {
+		return nil, fmt.Errorf("invalid request: stored value must be a gift_card or wallet")
+	}
+	if req.PaymentMethodID == "" && req.StoredValue == nil {
+		return nil, fmt.Errorf("invalid request: payment_method_id is required")
+	}
+
+	saga := models.NewCheckoutSaga(req.UserID, req.SessionID)
+	saga.CurrentStep = models.CheckoutStepValidateCart
+	if err := s.repo.Create(ctx, saga); err != nil {
+		return nil, fmt.Errorf("failed to start checkout: %w", err)
+	}
+
+	state := &checkoutState{saga: saga, req: req}
+	steps := []checkoutStep{
+		{models.CheckoutStepValidateCart, s.validateCart},
+		{models.CheckoutStepCreateOrder, s.createOrder},
+		{models.CheckoutStepReserveStock, s.reserveStock},
+		{models.CheckoutStepCreatePayment, s.createPayment},
+		{models.CheckoutStepProcessPayment, s.processPayment},
+		{models.CheckoutStepCreateShipment, s.createShipment},
+	}
+
+	return saga, s.run(ctx, state, steps)
+}
+
+// run runs the remaining steps of a checkout and completes it. It stops
+// early, without compensating, when a payment is held for risk review.
+func (s *checkoutService) run(ctx context.Context, state *checkoutState, steps []checkoutStep) error {
+	saga := state.saga
+	for _, step := range steps {
+		saga.CurrentStep = step.name
+		if err := step.run(ctx, state); err != nil {
+			if errors.Is(err, errPaymentUnderReview) {
+				return s.awaitReview(ctx, saga)
+			}
+			return s.abort(ctx, saga, step.name, err)
+		}
+		saga.CompletedSteps = append(saga.CompletedSteps, step.name)
+		if err := s.repo.Update(ctx, saga); err != nil {
+			return s.abort(ctx, saga, step.name, err)
+		}
+	}
+
+	// Everything after the shipment is best effort: the order is paid for and
+	// on its way, so a stale cart is not worth undoing it over
+	saga.CurrentStep = models.CheckoutStepClearCart
+	s.clearCart(ctx, saga)
+
+	if err := s.complete(ctx, saga); err != nil {
+		return err
+	}
+
+	utils.Logger.Info(ctx, "Checkout completed", map[string]interface{}{
+		"checkout_id":             saga.ID,
+		"order_id":                saga.OrderID,
+		"payment_id":              saga.PaymentID,
+		"stored_value_payment_id": saga.StoredValuePaymentID,
+		"shipment_id":             saga.ShipmentID,
+	})
+
+	return nil
+}
+
+// awaitReview parks a checkout until its payment review is decided
+func (s *checkoutService) awaitReview(ctx context.Context, saga *models.CheckoutSaga) error {
+	utils.Logger.Info(ctx, "Checkout awaiting payment review", map[string]interface{}{
+		"checkout_id": saga.ID,
+		"order_id":    saga.OrderID,
+	})
+
+	saga.Status = models.CheckoutAwaitingReview
+	if err := s.repo.Update(ctx, saga); err != nil {
+		return fmt.Errorf("failed to save checkout: %w", err)
+	}
+	return nil
+}
+
+// GetCheckout retrieves a checkout saga by ID
+func (s *checkoutService) GetCheckout(ctx context.Context, id string) (*models.CheckoutSaga, error) {
+	if id == "" {
+		return nil, fmt.Errorf("checkout ID is required")
+	}
+
+	saga, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout: %w", err)
+	}
+	return saga, nil
+}
+
+// QuoteShipping quotes shipping an order from the warehouse its stock was
+// allocated to
+func (s *checkoutService) QuoteShipping(ctx context.Context, orderID string) ([]*models.ShippingQuote, error) {
+	if orderID == "" {
+		return nil, fmt.Errorf("order ID is required")
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	quotes, err := s.shippingService.GetShippingQuotes(ctx, &models.ShippingQuoteRequest{
+		FromAddress:   s.shipFrom(order),
+		ToAddress:     order.ShippingAddress,
+		WeightKg:      s.shipmentWeight(order.Items),
+		DeclaredValue: order.Subtotal,
+		OrderValue:    order.Subtotal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipping quotes: %w", err)
+	}
+	return quotes, nil
+}
+
+// RecoverStalled finishes sagas that stopped making progress, for example
+// because the process crashed mid-checkout. Sagas that already have a
+// shipment are rolled forward; all others are compensated.
+func (s *checkoutService) RecoverStalled(ctx context.Context, olderThan time.Duration) (int, error) {
+	sagas, err := s.repo.ListStalled(ctx, time.Now().Add(-olderThan), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, saga := range sagas {
+		utils.Logger.Warn(ctx, "Recovering stalled checkout", map[string]interface{}{
+			"checkout_id":  saga.ID,
+			"status":       saga.Status,
+			"current_step": saga.CurrentStep,
+		})
+
+		if saga.Status == models.CheckoutStarted && saga.HasCompleted(models.CheckoutStepCreateShipment) {
+			s.clearCart(ctx, saga)
+			if err := s.complete(ctx, saga); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if saga.Error == "" {
+			saga.Error = fmt.Sprintf("checkout interrupted at %s", saga.CurrentStep)
+		}
+		if err := s.compensate(ctx, saga); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sagas), nil
+}
+
+// ResumeReviewed continues the checkouts whose payment review was decided.
+// An approved payment completes the checkout and ships the order; a rejected
+// one fails the payment step, which is compensated as usual. Checkouts still
+// under review are left waiting.
+func (s *checkoutService) ResumeReviewed(ctx context.Context) (int, error) {
+	sagas, err := s.repo.ListAwaitingReview(ctx, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for _, saga := range sagas {
+		order, err := s.orderRepo.GetByID(ctx, saga.OrderID)
+		if err != nil {
+			return resumed, fmt.Errorf("failed to load order for checkout %s: %w", saga.ID, err)
+		}
+
+		state := &checkoutState{saga: saga, order: order, resumed: true}
+		held, err := s.paymentService.GetPayment(ctx, order.PaymentReference)
+		if err != nil {
+			utils.Logger.Error(ctx, "Failed to check payment review", err, map[string]interface{}{
+				"checkout_id": saga.ID,
+				"payment_id":  order.PaymentReference,
+			})
+			continue
+		}
+		if awaitingReview(state, held) {
+			continue
+		}
+
+		saga.Status = models.CheckoutStarted
+		err = s.run(ctx, state, []checkoutStep{
+			{models.CheckoutStepProcessPayment, s.processPayment},
+			{models.CheckoutStepCreateShipment, s.createShipment},
+		})
+
+		// A failed step has been compensated; only errors saving the saga are returned
+		var checkoutErr *CheckoutError
+		if err != nil && !errors.As(err, &checkoutErr) {
+			return resumed, err
+		}
+		resumed++
+	}
+	return resumed, nil
+}
+
+func (s *checkoutService) validateCart(ctx context.Context, state *checkoutState) error {
+	cart, err := s.cartService.GetCart(ctx, state.req.UserID, state.req.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get cart: %w", err)
+	}
+	if len(cart.Items) == 0 {
+		return fmt.Errorf("invalid cart: cart is empty")
+	}
+
+	validation, err := s.cartService.ValidateCart(ctx, state.req.UserID, state.req.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to validate cart: %w", err)
+	}
+	if !validation.IsValid {
+		var reasons []string
+		for _, item := range append(validation.InvalidItems, validation.UnavailableItems...) {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", item.ProductID, item.Reason))
+		}
+		for _, change := range validation.PriceChanges {
+			reasons = append(reasons, fmt.Sprintf("%s: price changed from %s to %s", change.ProductID, change.OldPrice, change.NewPrice))
+		}
+		return fmt.Errorf("invalid cart: %s", strings.Join(reasons, "; "))
+	}
+
+	state.cart = cart
+	state.saga.CartID = cart.ID
+	return nil
+}
+
+func (s *checkoutService) createOrder(ctx context.Context, state *checkoutState) error {
+	items := make([]OrderItemRequest, 0, len(state.cart.Items))
+	for _, item := range state.cart.Items {
+		items = append(items, OrderItemRequest{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+		})
+	}
+
+	order, err := s.orders.CreateOrder(ctx, &CreateOrderRequest{
+		UserID:          state.req.UserID,
+		Items:           items,
+		ShippingAddress: state.req.ShippingAddress,
+		BillingAddress:  state.req.BillingAddress,
+		PaymentMethod:   state.req.PaymentMethod,
+		ShippingMethod:  state.req.ShippingMethodID,
+		Notes:           state.req.Notes,
+		Source:          "checkout",
+		CouponCodes:     append(append([]string{}, state.cart.CouponCodes...), state.req.CouponCodes...),
+		Currency:        state.cart.Currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	state.order = order
+	state.saga.OrderID = order.ID
+	return nil
+}
+
+func (s *checkoutService) reserveStock(ctx context.Context, state *checkoutState) error {
+	if err := s.inventoryService.ReserveStock(ctx, state.order.Items); err != nil {
+		return err
+	}
+
+	allocateOrigin(ctx, s.inventoryService, state.order)
+	if state.order.OriginAddress == nil {
+		return nil
+	}
+	if err := s.orderRepo.Update(ctx, state.order); err != nil {
+		utils.Logger.Error(ctx, "Failed to record origin warehouse on order", err, map[string]interface{}{
+			"order_id":     state.order.ID,
+			"warehouse_id": state.order.OriginWarehouseID,
+		})
+	}
+	return nil
+}
+
+// createPayment creates the order's payments: a stored-value payment for as
+// much as the gift card or wallet holds, if one was given, and a card payment
+// for the rest
+func (s *checkoutService) createPayment(ctx context.Context, state *checkoutState) error {
+	remaining := state.order.Total
+
+	if tender := state.req.StoredValue; tender != nil {
+		req := s.paymentRequest(state, remaining)
+		req.PaymentMethodID = ""
+		req.Type = tender.Type
+		req.GiftCardCode = tender.GiftCardCode
+		req.AllowPartial = true
+
+		payment, err := s.paymentService.CreatePayment(ctx, req)
+		if err != nil {
+			return fmt.Errorf("failed to create %s payment: %w", tender.Type, err)
+		}
+
+		state.saga.StoredValuePaymentID = payment.ID
+		remaining = remaining.Sub(payment.Amount)
+		if !remaining.IsPositive() {
+			return nil
+		}
+	}
+
+	if state.req.PaymentMethodID == "" {
+		return fmt.Errorf("failed to create payment: %s %s left to pay and no payment method given", remaining, state.order.Currency)
+	}
+
+	payment, err := s.paymentService.CreatePayment(ctx, s.paymentRequest(state, remaining))
+	if err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	state.saga.PaymentID = payment.ID
+	return nil
+}
+
+// paymentRequest builds a card payment request for part or all of the order
+func (s *checkoutService) paymentRequest(state *checkoutState, amount decimal.Decimal) *PaymentRequest {
+	return &PaymentRequest{
+		OrderID:         state.order.ID,
+		UserID:          state.order.UserID,
+		Amount:          amount,
+		Currency:        state.order.Currency,
+		PaymentMethodID: state.req.PaymentMethodID,
+		Description:     fmt.Sprintf("Order %s", state.order.OrderNumber),
+		// Funds are only held here; payment-service captures them as shipments ship
+		AutoCapture: false,
+		Risk: models.PaymentRiskContext{
+			IPAddress:       state.req.ClientIP,
+			BillingCountry:  state.req.BillingAddress.Country,
+			ShippingCountry: state.req.ShippingAddress.Country,
+		},
+	}
+}
+
+// processPayment redeems the stored value before the card is charged, so a
+// failed redemption never leaves a card authorization behind. The order
+// references the card payment when there is one. A payment held for risk
+// review pauses the checkout with the order still pending.
+func (s *checkoutService) processPayment(ctx context.Context, state *checkoutState) error {
+	var payment *models.Payment
+	for _, paymentID := range []string{state.saga.StoredValuePaymentID, state.saga.PaymentID} {
+		if paymentID == "" {
+			continue
+		}
+
+		processed, err := s.settlePayment(ctx, state, paymentID)
+		if err != nil {
+			return fmt.Errorf("payment failed: %w", err)
+		}
+		if awaitingReview(state, processed) {
+			return s.holdForReview(ctx, state, processed)
+		}
+		if processed.Status != models.PaymentAuthorized && processed.Status != models.PaymentCompleted {
+			return fmt.Errorf("payment failed: status %s %s", processed.Status, processed.FailureReason)
+		}
+		payment = processed
+	}
+
+	if err := s.orders.UpdateOrderStatus(ctx, state.order.ID, models.OrderConfirmed, "Payment "+string(payment.Status), "checkout"); err != nil {
+		return err
+	}
+
+	state.order.Status = models.OrderConfirmed
+	state.order.PaymentStatus = string(payment.Status)
+	state.order.PaymentReference = payment.ID
+	return s.orderRepo.Update(ctx, state.order)
+}
+
+// settlePayment processes a payment. A resumed checkout reads the payments it
+// already processed instead, and only processes those it never reached.
+func (s *checkoutService) settlePayment(ctx context.Context, state *checkoutState, paymentID string) (*models.Payment, error) {
+	if state.resumed {
+		payment, err := s.paymentService.GetPayment(ctx, paymentID)
+		if err != nil || payment.Status != models.PaymentPending || paymentID == state.order.PaymentReference {
+			return payment, err
+		}
+	}
+	return s.paymentService.ProcessPayment(ctx, paymentID)
+}
+
+// awaitingReview reports whether a payment is still held for risk review.
+// payment-service confirms an approved payment with the gateway itself, so
+// the checkout keeps waiting until that confirmation settles.
+func awaitingReview(state *checkoutState, payment *models.Payment) bool {
+	switch payment.Status {
+	case models.PaymentUnderReview:
+		return true
+	case models.PaymentPending, models.PaymentProcessing:
+		return state.resumed && payment.ID == state.order.PaymentReference
+	}
+	return false
+}
+
+// holdForReview records the held payment on the order and pauses the checkout
+func (s *checkoutService) holdForReview(ctx context.Context, state *checkoutState, payment *models.Payment) error {
+	state.order.PaymentStatus = string(payment.Status)
+	state.order.PaymentReference = payment.ID
+	if err := s.orderRepo.Update(ctx, state.order); err != nil {
+		return fmt.Errorf("failed to record payment review on order: %w", err)
+	}
+	return errPaymentUnderReview
+}
+
+func (s *checkoutService) createShipment(ctx context.Context, state *checkoutState) error {
+	shipment, err := s.shippingService.CreateShipment(ctx, &ShipmentRequest{
+		OrderID:          state.order.ID,
+		UserID:           state.order.UserID,
+		ShippingMethodID: state.order.ShippingMethod,
+		FromAddress:      s.shipFrom(state.order),
+		ToAddress:        state.order.ShippingAddress,
+		WeightKg:         s.shipmentWeight(state.order.Items),
+		DeclaredValue:    state.order.Subtotal,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create shipment: %w", err)
+	}
+
+	state.saga.ShipmentID = shipment.ID
+
+	state.order.TrackingNumber = shipment.TrackingNumber
+	if err := s.orderRepo.Update(ctx, state.order); err != nil {
+		utils.Logger.Error(ctx, "Failed to record tracking number on order", err, map[string]interface{}{
+			"order_id":    state.order.ID,
+			"shipment_id": shipment.ID,
+		})
+	}
+	return nil
+}
+
+func (s *checkoutService) clearCart(ctx context.Context, saga *models.CheckoutSaga) {
+	if err := s.cartService.ClearCart(ctx, saga.UserID, saga.SessionID); err != nil {
+		utils.Logger.Error(ctx, "Failed to clear cart after checkout", err, map[string]interface{}{
+			"checkout_id": saga.ID,
+			"cart_id":     saga.CartID,
+		})
+		return
+	}
+	saga.CompletedSteps = append(saga.CompletedSteps, models.CheckoutStepClearCart)
+}
+
+func (s *checkoutService) complete(ctx context.Context, saga *models.CheckoutSaga) error {
+	now := time.Now()
+	saga.Status = models.CheckoutCompleted
+	saga.CurrentStep = ""
+	saga.CompletedAt = &now
+	if err := s.repo.Update(ctx, saga); err != nil {
+		return fmt.Errorf("failed to complete checkout: %w", err)
+	}
+	return nil
+}
+
+// shipFrom is the address an order ships from: its origin warehouse, or the
+// default warehouse when its stock was not allocated
+func (s *checkoutService) shipFrom(order *models.Order) models.Address {
+	if order.OriginAddress != nil {
+		return *order.OriginAddress
+	}
+	return s.config.WarehouseAddress
+}
+
+func (s *checkoutService) shipmentWeight(items []models.OrderItem) decimal.Decimal {
+	return itemsWeight(items, s.config.DefaultItemWeightKg)
+}
+
+// itemsWeight estimates the weight of items from their weight attribute
+func itemsWeight(items []models.OrderItem, defaultWeight decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, item := range items {
+		weight := defaultWeight
+		if w, ok := item.ProductAttributes["weight"].(float64); ok && w > 0 {
+			weight = decimal.NewFromFloat(w)
+		}
+		total = total.Add(weight.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+	return total
+}
+
+// abort records the failure, compensates and returns the error for the caller
+func (s *checkoutService) abort(ctx context.Context, saga *models.CheckoutSaga, step models.CheckoutStep, cause error) error {
+	utils.Logger.Warn(ctx, "Checkout step failed, compensating", map[string]interface{}{
+		"checkout_id": saga.ID,
+		"step":        step,
+		"error":       cause.Error(),
+	})
+
+	saga.Error = cause.Error()
+	if err := s.compensate(ctx, saga); err != nil {
+		utils.Logger.Error(ctx, "Failed to record checkout compensation", err, map[string]interface{}{
+			"checkout_id": saga.ID,
+		})
+	}
+
+	return &CheckoutError{CheckoutID: saga.ID, Step: step, Err: cause}
+}
+
+// compensate undoes the steps recorded on the saga in reverse order. It must
+// be safe to run more than once, since a crash can interrupt it.
+func (s *checkoutService) compensate(ctx context.Context, saga *models.CheckoutSaga) error {
+	// Compensation must finish even if the caller has gone away
+	ctx = context.WithoutCancel(ctx)
+
+	saga.Status = models.CheckoutCompensating
+	if err := s.repo.Update(ctx, saga); err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, paymentID := range []string{saga.PaymentID, saga.StoredValuePaymentID} {
+		if paymentID == "" {
+			continue
+		}
+		if err := s.compensatePayment(ctx, paymentID); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if saga.OrderID != "" {
+		order, err := s.orderRepo.GetByID(ctx, saga.OrderID)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to load order for compensation: %v", err))
+		} else if order.Status != models.OrderCancelled {
+			// A failed reservation releases its own partial holds
+			if saga.HasCompleted(models.CheckoutStepReserveStock) {
+				if err := s.inventoryService.ReleaseStock(ctx, order.Items); err != nil {
+					errs = append(errs, fmt.Sprintf("failed to release stock: %v", err))
+				}
+			}
+			if err := s.orders.CancelOrder(ctx, order.ID, "Checkout failed: "+saga.Error, "checkout"); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to cancel order: %v", err))
+			}
+		}
+	}
+
+	saga.CompensationErrors = errs
+	saga.Status = models.CheckoutCompensated
+	if len(errs) > 0 {
+		saga.Status = models.CheckoutFailed
+		utils.Logger.Error(ctx, "Checkout compensation incomplete", fmt.Errorf("%s", strings.Join(errs, "; ")), map[string]interface{}{
+			"checkout_id":             saga.ID,
+			"order_id":                saga.OrderID,
+			"payment_id":              saga.PaymentID,
+			"stored_value_payment_id": saga.StoredValuePaymentID,
+		})
+	}
+
+	return s.repo.Update(ctx, saga)
+}
+
+// compensatePayment refunds a captured payment and cancels an open or
+// authorized one, which voids its hold. Failed and already cancelled, voided
+// or refunded payments need nothing. Refunded gift card and wallet payments
+// are credited back to the stored value they came from.
+func (s *checkoutService) compensatePayment(ctx context.Context, paymentID string) error {
+	return voidPayment(ctx, s.paymentService, paymentID, "Checkout failed")
+}
+
+// voidPayment undoes a payment whatever state it reached, as
+// compensatePayment describes
+func voidPayment(ctx context.Context, payments PaymentService, paymentID, reason string) error {
+	payment, err := payments.GetPayment(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to load payment for compensation: %w", err)
+	}
+
+	switch payment.Status {
+	case models.PaymentCompleted:
+		if err := payments.RefundPayment(ctx, paymentID, reason); err != nil {
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+	case models.PaymentPending, models.PaymentProcessing, models.PaymentRequiresAction, models.PaymentUnderReview, models.PaymentAuthorized:
+		// A payment held for risk review must not be approved for an order that no longer exists
+		if err := payments.CancelPayment(ctx, paymentID, reason); err != nil {
+			return fmt.Errorf("failed to cancel payment: %w", err)
+		}
+	}
+	return nil
+}
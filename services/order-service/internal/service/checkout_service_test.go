@@ -0,0 +1,673 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/models"
+)
+
+// MockCheckoutRepository implements CheckoutRepository for testing
+type MockCheckoutRepository struct {
+	sagas map[string]models.CheckoutSaga
+}
+
+func NewMockCheckoutRepository() *MockCheckoutRepository {
+	return &MockCheckoutRepository{sagas: make(map[string]models.CheckoutSaga)}
+}
+
+func (m *MockCheckoutRepository) Create(ctx context.Context, saga *models.CheckoutSaga) error {
+	m.sagas[saga.ID] = copySaga(saga)
+	return nil
+}
+
+func (m *MockCheckoutRepository) Update(ctx context.Context, saga *models.CheckoutSaga) error {
+	if _, exists := m.sagas[saga.ID]; !exists {
+		return &NotFoundError{Resource: "checkout", ID: saga.ID}
+	}
+	m.sagas[saga.ID] = copySaga(saga)
+	return nil
+}
+
+func (m *MockCheckoutRepository) GetByID(ctx context.Context, id string) (*models.CheckoutSaga, error) {
+	saga, exists := m.sagas[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "checkout", ID: id}
+	}
+	result := copySaga(&saga)
+	return &result, nil
+}
+
+func (m *MockCheckoutRepository) ListStalled(ctx context.Context, before time.Time, limit int) ([]*models.CheckoutSaga, error) {
+	var sagas []*models.CheckoutSaga
+	for _, saga := range m.sagas {
+		if (saga.Status == models.CheckoutStarted || saga.Status == models.CheckoutCompensating) && saga.UpdatedAt.Before(before) {
+			result := copySaga(&saga)
+			sagas = append(sagas, &result)
+		}
+	}
+	return sagas, nil
+}
+
+func (m *MockCheckoutRepository) ListAwaitingReview(ctx context.Context, limit int) ([]*models.CheckoutSaga, error) {
+	var sagas []*models.CheckoutSaga
+	for _, saga := range m.sagas {
+		if saga.Status == models.CheckoutAwaitingReview {
+			result := copySaga(&saga)
+			sagas = append(sagas, &result)
+		}
+	}
+	return sagas, nil
+}
+
+func copySaga(saga *models.CheckoutSaga) models.CheckoutSaga {
+	result := *saga
+	result.CompletedSteps = append([]models.CheckoutStep{}, saga.CompletedSteps...)
+	result.CompensationErrors = append([]string{}, saga.CompensationErrors...)
+	return result
+}
+
+// MockInventoryService implements InventoryService for testing
+type MockInventoryService struct {
+	reserved map[string]int
+	plan     *models.AllocationPlan
+	err      error
+}
+
+func NewMockInventoryService() *MockInventoryService {
+	return &MockInventoryService{reserved: make(map[string]int)}
+}
+
+func (m *MockInventoryService) ReserveStock(ctx context.Context, items []models.OrderItem) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, item := range items {
+		m.reserved[item.ProductID] += item.Quantity
+	}
+	return nil
+}
+
+func (m *MockInventoryService) ReleaseStock(ctx context.Context, items []models.OrderItem) error {
+	for _, item := range items {
+		m.reserved[item.ProductID] -= item.Quantity
+	}
+	return nil
+}
+
+func (m *MockInventoryService) AllocateStock(ctx context.Context, orderID string, shipTo models.Address) (*models.AllocationPlan, error) {
+	if m.plan == nil {
+		return nil, errors.New("no warehouse stock")
+	}
+	return m.plan, nil
+}
+
+// MockCartService implements CartService for testing
+type MockCartService struct {
+	cart       *models.Cart
+	validation *CartValidation
+	cleared    bool
+}
+
+func NewMockCartService() *MockCartService {
+	return &MockCartService{
+		cart: &models.Cart{
+			ID:     "cart1",
+			UserID: "user1",
+			Items: []models.CartItem{
+				{ProductID: "prod1", SKU: "SKU001", Quantity: 2, Price: decimal.NewFromFloat(99.99)},
+			},
+		},
+		validation: &CartValidation{IsValid: true},
+	}
+}
+
+func (m *MockCartService) GetCart(ctx context.Context, userID, sessionID string) (*models.Cart, error) {
+	return m.cart, nil
+}
+
+func (m *MockCartService) ValidateCart(ctx context.Context, userID, sessionID string) (*CartValidation, error) {
+	return m.validation, nil
+}
+
+func (m *MockCartService) ClearCart(ctx context.Context, userID, sessionID string) error {
+	m.cleared = true
+	return nil
+}
+
+// MockPaymentService implements PaymentService for testing
+type MockPaymentService struct {
+	payments      map[string]*models.Payment
+	autoCapture   map[string]bool
+	declineReason string
+	// holdForReview makes card payments come back held by the risk engine
+	holdForReview bool
+	// storedValue is the balance of the gift card or wallet stored-value payments draw on
+	storedValue decimal.Decimal
+}
+
+func NewMockPaymentService() *MockPaymentService {
+	return &MockPaymentService{payments: make(map[string]*models.Payment), autoCapture: make(map[string]bool)}
+}
+
+func (m *MockPaymentService) CreatePayment(ctx context.Context, req *PaymentRequest) (*models.Payment, error) {
+	if req.Type.IsStoredValue() {
+		amount := decimal.Min(req.Amount, m.storedValue)
+		payment := &models.Payment{ID: "sv-" + req.OrderID, OrderID: req.OrderID, Amount: amount, Type: req.Type, Status: models.PaymentPending}
+		m.payments[payment.ID] = payment
+		return payment, nil
+	}
+
+	payment := &models.Payment{ID: "pay-" + req.OrderID, OrderID: req.OrderID, Amount: req.Amount, Status: models.PaymentPending}
+	m.payments[payment.ID] = payment
+	m.autoCapture[payment.ID] = req.AutoCapture
+	return payment, nil
+}
+
+func (m *MockPaymentService) ProcessPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	payment := m.payments[paymentID]
+	if payment.Type.IsStoredValue() {
+		// Stored value is redeemed outright
+		m.storedValue = m.storedValue.Sub(payment.Amount)
+		payment.Status = models.PaymentCompleted
+		return payment, nil
+	}
+	if m.declineReason != "" {
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = m.declineReason
+		return nil, errors.New("payment processing failed: " + m.declineReason)
+	}
+	if m.holdForReview {
+		payment.Status = models.PaymentUnderReview
+		return payment, nil
+	}
+	payment.Status = models.PaymentAuthorized
+	if m.autoCapture[paymentID] {
+		payment.Status = models.PaymentCompleted
+	}
+	return payment, nil
+}
+
+func (m *MockPaymentService) GetPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	payment, exists := m.payments[paymentID]
+	if !exists {
+		return nil, &NotFoundError{Resource: "payment", ID: paymentID}
+	}
+	return payment, nil
+}
+
+func (m *MockPaymentService) CancelPayment(ctx context.Context, paymentID, reason string) error {
+	m.payments[paymentID].Status = models.PaymentCancelled
+	return nil
+}
+
+func (m *MockPaymentService) RefundPayment(ctx context.Context, paymentID, reason string) error {
+	payment := m.payments[paymentID]
+	if payment.Type.IsStoredValue() {
+		m.storedValue = m.storedValue.Add(payment.Amount)
+	}
+	payment.Status = models.PaymentRefunded
+	return nil
+}
+
+// MockShippingService implements ShippingService for testing
+type MockShippingService struct {
+	requests      []*ShipmentRequest
+	quoteRequests []*models.ShippingQuoteRequest
+	err           error
+}
+
+func (m *MockShippingService) CreateShipment(ctx context.Context, req *ShipmentRequest) (*models.Shipment, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.requests = append(m.requests, req)
+	return &models.Shipment{ID: "ship-" + req.OrderID, OrderID: req.OrderID, TrackingNumber: "TRK123"}, nil
+}
+
+func (m *MockShippingService) GetShippingQuotes(ctx context.Context, req *models.ShippingQuoteRequest) ([]*models.ShippingQuote, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.quoteRequests = append(m.quoteRequests, req)
+	return []*models.ShippingQuote{{ShippingMethodID: "standard", Cost: decimal.NewFromInt(5)}}, nil
+}
+
+type checkoutFixture struct {
+	service   CheckoutService
+	sagas     *MockCheckoutRepository
+	orders    *MockOrderRepository
+	inventory *MockInventoryService
+	cart      *MockCartService
+	payments  *MockPaymentService
+	shipping  *MockShippingService
+}
+
+func newCheckoutFixture() *checkoutFixture {
+	f := &checkoutFixture{
+		sagas:     NewMockCheckoutRepository(),
+		orders:    NewMockOrderRepository(),
+		inventory: NewMockInventoryService(),
+		cart:      NewMockCartService(),
+		payments:  NewMockPaymentService(),
+		shipping:  &MockShippingService{},
+	}
+	f.service = NewCheckoutService(f.sagas, f.orders, NewMockProductService(), f.inventory,
+		f.cart, f.payments, f.shipping, nil, nil, nil, nil, DefaultCheckoutConfig())
+	return f
+}
+
+func newCheckoutRequest() *CheckoutRequest {
+	address := models.Address{
+		Street:     "123 Test St",
+		City:       "Test City",
+		State:      "TS",
+		PostalCode: "12345",
+		Country:    "US",
+	}
+	return &CheckoutRequest{
+		UserID:           "user1",
+		ShippingAddress:  address,
+		BillingAddress:   address,
+		PaymentMethod:    models.PaymentMethod{Type: "card", Last4: "1234", Brand: "visa"},
+		PaymentMethodID:  "pm_123",
+		ShippingMethodID: "standard",
+	}
+}
+
+func TestCheckoutService_Checkout(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if saga.Status != models.CheckoutCompleted || saga.CompletedAt == nil {
+		t.Errorf("Expected completed saga, got %+v", saga)
+	}
+	if len(saga.CompletedSteps) != 7 {
+		t.Errorf("Expected all 7 steps to complete, got %v", saga.CompletedSteps)
+	}
+
+	order := f.orders.orders[saga.OrderID]
+	if order.Status != models.OrderConfirmed || order.PaymentReference != saga.PaymentID || order.TrackingNumber != "TRK123" {
+		t.Errorf("Unexpected order state: status=%s payment=%s tracking=%s", order.Status, order.PaymentReference, order.TrackingNumber)
+	}
+	if f.inventory.reserved["prod1"] != 2 {
+		t.Errorf("Expected 2 units reserved, got %d", f.inventory.reserved["prod1"])
+	}
+	if !f.payments.payments[saga.PaymentID].Amount.Equal(order.Total) {
+		t.Errorf("Expected payment for order total %s", order.Total)
+	}
+	if !f.shipping.requests[0].WeightKg.Equal(decimal.NewFromFloat(1.0)) {
+		t.Errorf("Expected default weight of 1kg for 2 items, got %s", f.shipping.requests[0].WeightKg)
+	}
+	if !f.cart.cleared {
+		t.Error("Expected cart to be cleared")
+	}
+
+	stored, _ := f.sagas.GetByID(ctx, saga.ID)
+	if stored.Status != models.CheckoutCompleted {
+		t.Errorf("Expected persisted saga to be completed, got %s", stored.Status)
+	}
+}
+
+func TestCheckoutService_Checkout_OrdersCartVariant(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.cart.cart.Items = []models.CartItem{
+		{ProductID: "prod1", VariantID: "var1", SKU: "SKU001-XL", Quantity: 2, Price: decimal.NewFromFloat(109.99)},
+	}
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	item := f.orders.orders[saga.OrderID].Items[0]
+	if item.VariantID != "var1" || item.SKU != "SKU001-XL" || !item.Price.Equal(decimal.NewFromFloat(109.99)) {
+		t.Errorf("Expected the variant to be ordered at its price, got %q %s at %s", item.VariantID, item.SKU, item.Price)
+	}
+}
+
+func TestCheckoutService_Checkout_ShipsFromAllocatedWarehouse(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	west := models.NewWarehouse("WEST", "Reno", models.Address{City: "Reno", State: "NV", PostalCode: "89501", Country: "US"}, 1)
+	f.inventory.plan = &models.AllocationPlan{
+		Shipments: []models.AllocationShipment{
+			{Warehouse: west, Lines: []models.AllocationLine{{ProductID: "prod1", Quantity: 2}}},
+		},
+	}
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	order := f.orders.orders[saga.OrderID]
+	if order.OriginWarehouseID != west.ID || order.OriginAddress == nil {
+		t.Fatalf("Expected the order to record its origin warehouse, got %q", order.OriginWarehouseID)
+	}
+	if f.shipping.requests[0].FromAddress != west.Address {
+		t.Errorf("Expected the shipment to leave from %+v, got %+v", west.Address, f.shipping.requests[0].FromAddress)
+	}
+
+	if _, err := f.service.QuoteShipping(ctx, order.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	quote := f.shipping.quoteRequests[0]
+	if quote.FromAddress != west.Address || quote.ToAddress != order.ShippingAddress {
+		t.Errorf("Expected a quote from %+v to %+v, got %+v", west.Address, order.ShippingAddress, quote)
+	}
+}
+
+func TestCheckoutService_Checkout_ShipsFromDefaultWarehouseWithoutAllocation(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected allocation failures not to fail checkout, got %v", err)
+	}
+
+	if order := f.orders.orders[saga.OrderID]; order.OriginWarehouseID != "" || order.OriginAddress != nil {
+		t.Errorf("Expected no origin warehouse, got %q", order.OriginWarehouseID)
+	}
+	if f.shipping.requests[0].FromAddress != DefaultCheckoutConfig().WarehouseAddress {
+		t.Errorf("Expected the shipment to leave from the default warehouse, got %+v", f.shipping.requests[0].FromAddress)
+	}
+}
+
+func TestCheckoutService_Checkout_InvalidCart(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.cart.validation = &CartValidation{
+		UnavailableItems: []CartIssue{{ProductID: "prod1", Reason: "Product no longer available"}},
+	}
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	var checkoutErr *CheckoutError
+	if !errors.As(err, &checkoutErr) || checkoutErr.Step != models.CheckoutStepValidateCart {
+		t.Fatalf("Expected validate_cart failure, got %v", err)
+	}
+	if saga.Status != models.CheckoutCompensated || saga.OrderID != "" {
+		t.Errorf("Expected compensated saga without an order, got %+v", saga)
+	}
+	if len(f.orders.orders) != 0 || f.cart.cleared {
+		t.Error("Expected no order and an untouched cart")
+	}
+}
+
+func TestCheckoutService_Checkout_PaymentDeclined(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.declineReason = "card_declined"
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	var checkoutErr *CheckoutError
+	if !errors.As(err, &checkoutErr) || checkoutErr.Step != models.CheckoutStepProcessPayment {
+		t.Fatalf("Expected process_payment failure, got %v", err)
+	}
+
+	if saga.Status != models.CheckoutCompensated || len(saga.CompensationErrors) != 0 {
+		t.Errorf("Expected clean compensation, got %+v", saga)
+	}
+	if f.inventory.reserved["prod1"] != 0 {
+		t.Errorf("Expected stock to be released, %d units still reserved", f.inventory.reserved["prod1"])
+	}
+	if f.orders.orders[saga.OrderID].Status != models.OrderCancelled {
+		t.Errorf("Expected order to be cancelled, got %s", f.orders.orders[saga.OrderID].Status)
+	}
+	if f.payments.payments[saga.PaymentID].Status != models.PaymentFailed {
+		t.Errorf("Declined payment should be left as failed, got %s", f.payments.payments[saga.PaymentID].Status)
+	}
+	if f.cart.cleared {
+		t.Error("Cart must not be cleared when checkout fails")
+	}
+}
+
+func TestCheckoutService_Checkout_ShipmentFailureVoidsPayment(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.shipping.err = errors.New("shipping-service: carrier unavailable")
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	var checkoutErr *CheckoutError
+	if !errors.As(err, &checkoutErr) || checkoutErr.Step != models.CheckoutStepCreateShipment {
+		t.Fatalf("Expected create_shipment failure, got %v", err)
+	}
+
+	// Checkout only authorizes, so the hold is voided rather than refunded
+	if f.payments.payments[saga.PaymentID].Status != models.PaymentCancelled {
+		t.Errorf("Expected authorized payment to be cancelled, got %s", f.payments.payments[saga.PaymentID].Status)
+	}
+	if f.inventory.reserved["prod1"] != 0 {
+		t.Errorf("Expected stock to be released, %d units still reserved", f.inventory.reserved["prod1"])
+	}
+	if f.orders.orders[saga.OrderID].Status != models.OrderCancelled {
+		t.Errorf("Expected order to be cancelled, got %s", f.orders.orders[saga.OrderID].Status)
+	}
+}
+
+func TestCheckoutService_Checkout_PaymentUnderReview(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.holdForReview = true
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected a held payment not to fail checkout, got %v", err)
+	}
+	if saga.Status != models.CheckoutAwaitingReview || saga.HasCompleted(models.CheckoutStepProcessPayment) {
+		t.Fatalf("Expected saga awaiting review, got %+v", saga)
+	}
+
+	order := f.orders.orders[saga.OrderID]
+	if order.Status != models.OrderPending || order.PaymentStatus != string(models.PaymentUnderReview) {
+		t.Errorf("Expected order pending review, got status=%s payment=%s", order.Status, order.PaymentStatus)
+	}
+	if f.payments.payments[saga.PaymentID].Status != models.PaymentUnderReview {
+		t.Errorf("Held payment must stay under review, got %s", f.payments.payments[saga.PaymentID].Status)
+	}
+	if f.inventory.reserved["prod1"] != 2 || f.cart.cleared || len(f.shipping.requests) != 0 {
+		t.Error("Expected stock to stay reserved and the order not to ship while under review")
+	}
+
+	// Nothing happens until the review is decided
+	if resumed, err := f.service.ResumeReviewed(ctx); err != nil || resumed != 0 {
+		t.Fatalf("Expected no checkout to resume, got %d, %v", resumed, err)
+	}
+
+	f.payments.payments[saga.PaymentID].Status = models.PaymentAuthorized
+	if resumed, err := f.service.ResumeReviewed(ctx); err != nil || resumed != 1 {
+		t.Fatalf("Expected the approved checkout to resume, got %d, %v", resumed, err)
+	}
+
+	stored, _ := f.sagas.GetByID(ctx, saga.ID)
+	if stored.Status != models.CheckoutCompleted || len(stored.CompletedSteps) != 7 {
+		t.Errorf("Expected completed saga, got %+v", stored)
+	}
+	if order.Status != models.OrderConfirmed || order.TrackingNumber != "TRK123" {
+		t.Errorf("Expected confirmed, shipped order, got status=%s tracking=%s", order.Status, order.TrackingNumber)
+	}
+	if to := f.shipping.requests[0].ToAddress; to != order.ShippingAddress {
+		t.Errorf("Expected shipment to the order's address, got %+v", to)
+	}
+	if !f.cart.cleared {
+		t.Error("Expected cart to be cleared")
+	}
+}
+
+func TestCheckoutService_ResumeReviewed_Rejected(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.holdForReview = true
+
+	saga, err := f.service.Checkout(ctx, newCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	f.payments.payments[saga.PaymentID].Status = models.PaymentBlocked
+	if resumed, err := f.service.ResumeReviewed(ctx); err != nil || resumed != 1 {
+		t.Fatalf("Expected the rejected checkout to resume, got %d, %v", resumed, err)
+	}
+
+	stored, _ := f.sagas.GetByID(ctx, saga.ID)
+	if stored.Status != models.CheckoutCompensated {
+		t.Errorf("Expected compensated saga, got %s", stored.Status)
+	}
+	if f.inventory.reserved["prod1"] != 0 || f.orders.orders[saga.OrderID].Status != models.OrderCancelled {
+		t.Error("Expected stock released and order cancelled")
+	}
+	if f.payments.payments[saga.PaymentID].Status != models.PaymentBlocked {
+		t.Errorf("Rejected payment should be left as blocked, got %s", f.payments.payments[saga.PaymentID].Status)
+	}
+}
+
+func TestCheckoutService_Checkout_SplitsStoredValueAndCard(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.storedValue = decimal.NewFromInt(10)
+
+	req := newCheckoutRequest()
+	req.StoredValue = &StoredValueTender{Type: models.PaymentTypeGiftCard, GiftCardCode: "ABCD-EFGH-JKLM-NPQR"}
+
+	saga, err := f.service.Checkout(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	order := f.orders.orders[saga.OrderID]
+	giftCard := f.payments.payments[saga.StoredValuePaymentID]
+	card := f.payments.payments[saga.PaymentID]
+	if giftCard == nil || card == nil {
+		t.Fatalf("Expected a gift card and a card payment, got %+v", saga)
+	}
+	if !giftCard.Amount.Equal(decimal.NewFromInt(10)) || giftCard.Status != models.PaymentCompleted {
+		t.Errorf("Expected the gift card to pay 10, got %s (%s)", giftCard.Amount, giftCard.Status)
+	}
+	if !card.Amount.Equal(order.Total.Sub(decimal.NewFromInt(10))) || card.Status != models.PaymentAuthorized {
+		t.Errorf("Expected the card to be authorized for the rest of %s, got %s (%s)", order.Total, card.Amount, card.Status)
+	}
+	if order.PaymentReference != saga.PaymentID {
+		t.Errorf("Expected the order to reference the card payment, got %s", order.PaymentReference)
+	}
+}
+
+func TestCheckoutService_Checkout_StoredValueCoversOrder(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.storedValue = decimal.NewFromInt(1000)
+
+	req := newCheckoutRequest()
+	req.PaymentMethodID = ""
+	req.StoredValue = &StoredValueTender{Type: models.PaymentTypeWallet}
+
+	saga, err := f.service.Checkout(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	order := f.orders.orders[saga.OrderID]
+	if saga.PaymentID != "" || order.PaymentReference != saga.StoredValuePaymentID {
+		t.Errorf("Expected the wallet alone to pay, got saga %+v", saga)
+	}
+	if !f.payments.payments[saga.StoredValuePaymentID].Amount.Equal(order.Total) {
+		t.Errorf("Expected the wallet to pay the order total %s", order.Total)
+	}
+}
+
+func TestCheckoutService_Checkout_CardDeclinedRefundsStoredValue(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+	f.payments.storedValue = decimal.NewFromInt(10)
+	f.payments.declineReason = "card_declined"
+
+	req := newCheckoutRequest()
+	req.StoredValue = &StoredValueTender{Type: models.PaymentTypeWallet}
+
+	saga, err := f.service.Checkout(ctx, req)
+	var checkoutErr *CheckoutError
+	if !errors.As(err, &checkoutErr) || checkoutErr.Step != models.CheckoutStepProcessPayment {
+		t.Fatalf("Expected process_payment failure, got %v", err)
+	}
+
+	if f.payments.payments[saga.StoredValuePaymentID].Status != models.PaymentRefunded {
+		t.Errorf("Expected the redeemed wallet payment to be refunded, got %s", f.payments.payments[saga.StoredValuePaymentID].Status)
+	}
+	if !f.payments.storedValue.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Expected the wallet balance to be restored to 10, got %s", f.payments.storedValue)
+	}
+	if saga.Status != models.CheckoutCompensated {
+		t.Errorf("Expected clean compensation, got %+v", saga)
+	}
+}
+
+func TestCheckoutService_Checkout_RequiresPaymentMethod(t *testing.T) {
+	f := newCheckoutFixture()
+
+	req := newCheckoutRequest()
+	req.PaymentMethodID = ""
+
+	if _, err := f.service.Checkout(context.Background(), req); err == nil {
+		t.Fatal("Expected an error without a payment method or stored value")
+	}
+	if len(f.orders.orders) != 0 {
+		t.Error("Expected no order to be created")
+	}
+}
+
+func TestCheckoutService_RecoverStalled(t *testing.T) {
+	ctx := context.Background()
+	f := newCheckoutFixture()
+
+	// Simulate a crash after the payment was created but before it was processed
+	order := &models.Order{
+		ID:     "order1",
+		UserID: "user1",
+		Status: models.OrderPending,
+		Items:  []models.OrderItem{{ProductID: "prod1", Quantity: 3}},
+	}
+	f.orders.Create(ctx, order)
+	f.inventory.ReserveStock(ctx, order.Items)
+	payment, _ := f.payments.CreatePayment(ctx, &PaymentRequest{OrderID: order.ID})
+
+	stalled := models.NewCheckoutSaga("user1", "")
+	stalled.OrderID = order.ID
+	stalled.PaymentID = payment.ID
+	stalled.CurrentStep = models.CheckoutStepProcessPayment
+	stalled.CompletedSteps = []models.CheckoutStep{
+		models.CheckoutStepValidateCart,
+		models.CheckoutStepCreateOrder,
+		models.CheckoutStepReserveStock,
+		models.CheckoutStepCreatePayment,
+	}
+	stalled.UpdatedAt = time.Now().Add(-time.Hour)
+	f.sagas.Create(ctx, stalled)
+
+	recovered, err := f.service.RecoverStalled(ctx, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if recovered != 1 {
+		t.Fatalf("Expected 1 recovered saga, got %d", recovered)
+	}
+
+	saga, _ := f.service.GetCheckout(ctx, stalled.ID)
+	if saga.Status != models.CheckoutCompensated {
+		t.Errorf("Expected compensated saga, got %s", saga.Status)
+	}
+	if f.payments.payments[payment.ID].Status != models.PaymentCancelled {
+		t.Errorf("Expected pending payment to be cancelled, got %s", f.payments.payments[payment.ID].Status)
+	}
+	if f.inventory.reserved["prod1"] != 0 || f.orders.orders[order.ID].Status != models.OrderCancelled {
+		t.Error("Expected stock released and order cancelled")
+	}
+}
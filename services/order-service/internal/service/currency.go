@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRates converts between currencies. *currency.Rates, loaded from
+// FX_RATES_FILE, implements it.
+type ExchangeRates interface {
+	Rate(from, to string) (currency.Conversion, error)
+}
+
+// resolveCurrency looks up a requested currency, defaulting to the store's
+func resolveCurrency(code string) (currency.Currency, error) {
+	if strings.TrimSpace(code) == "" {
+		code = currency.DefaultCode
+	}
+	return currency.Lookup(code)
+}
+
+// priceConverter prices amounts in the currency of an order and remembers
+// the conversions it used so they can be snapshotted on the order
+type priceConverter struct {
+	currency currency.Currency
+	rates    ExchangeRates
+	used     []models.ExchangeRate
+}
+
+func newPriceConverter(target currency.Currency, rates ExchangeRates) *priceConverter {
+	return &priceConverter{currency: target, rates: rates, used: []models.ExchangeRate{}}
+}
+
+// convert converts an amount into the target currency, rounded to its
+// minor units. Amounts already in the target currency are returned as is.
+func (c *priceConverter) convert(amount decimal.Decimal, from string) (decimal.Decimal, error) {
+	if from == "" {
+		from = currency.DefaultCode
+	}
+	if strings.EqualFold(from, c.currency.Code) {
+		return amount, nil
+	}
+	if c.rates == nil {
+		return decimal.Zero, fmt.Errorf("no exchange rates configured to convert %s to %s", from, c.currency.Code)
+	}
+
+	conversion, err := c.rates.Rate(from, c.currency.Code)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	c.record(conversion)
+	return conversion.Apply(amount), nil
+}
+
+// catalogPrice returns the unit price of a product or variant in the target
+// currency, preferring an explicit list price over a converted one
+func (c *priceConverter) catalogPrice(product *models.Product, variant *models.ProductVariant) (decimal.Decimal, error) {
+	productCurrency := product.Currency
+	if productCurrency == "" {
+		productCurrency = currency.DefaultCode
+	}
+
+	if variant != nil {
+		if price, ok := variant.PriceIn(c.currency.Code, productCurrency); ok {
+			return price, nil
+		}
+		return c.convert(variant.Price, productCurrency)
+	}
+	if price, ok := product.PriceIn(c.currency.Code); ok {
+		return price, nil
+	}
+	return c.convert(product.Price, productCurrency)
+}
+
+func (c *priceConverter) record(conversion currency.Conversion) {
+	for _, used := range c.used {
+		if used.From == conversion.From && used.To == conversion.To {
+			return
+		}
+	}
+	c.used = append(c.used, conversion)
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// FulfillmentService splits orders into fulfillments that ship separately
+// and keeps the order status in step with them
+type FulfillmentService interface {
+	CreateFulfillment(ctx context.Context, orderID string, req *CreateFulfillmentRequest) (*models.Fulfillment, error)
+	UpdateFulfillment(ctx context.Context, id string, req *UpdateFulfillmentRequest) (*models.Fulfillment, error)
+	GetFulfillment(ctx context.Context, id string) (*models.Fulfillment, error)
+	ListFulfillments(ctx context.Context, orderID string) ([]models.Fulfillment, error)
+}
+
+// CreateFulfillmentRequest represents a request to fulfill part of an order.
+// Without items, everything not yet fulfilled is included.
+type CreateFulfillmentRequest struct {
+	Items          []FulfillmentItemRequest `json:"items"`
+	Carrier        string                   `json:"carrier"`
+	TrackingNumber string                   `json:"tracking_number"`
+	TrackingURL    string                   `json:"tracking_url"`
+	Notes          string                   `json:"notes"`
+}
+
+// FulfillmentItemRequest is a quantity of an order item to fulfill
+type FulfillmentItemRequest struct {
+	OrderItemID string `json:"order_item_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,min=1"`
+}
+
+// UpdateFulfillmentRequest moves a fulfillment to a new status. Carrier and
+// tracking details replace the stored ones when set.
+type UpdateFulfillmentRequest struct {
+	Status         models.FulfillmentStatus `json:"status" validate:"required"`
+	Carrier        string                   `json:"carrier"`
+	TrackingNumber string                   `json:"tracking_number"`
+	TrackingURL    string                   `json:"tracking_url"`
+	Notes          string                   `json:"notes"`
+	ChangedBy      string                   `json:"changed_by"`
+}
+
+// fulfillmentTransitions lists the statuses each fulfillment status can move to
+var fulfillmentTransitions = map[models.FulfillmentStatus][]models.FulfillmentStatus{
+	models.FulfillmentPending:    {models.FulfillmentProcessing, models.FulfillmentShipped, models.FulfillmentCancelled},
+	models.FulfillmentProcessing: {models.FulfillmentShipped, models.FulfillmentCancelled},
+	models.FulfillmentShipped:    {models.FulfillmentDelivered},
+	models.FulfillmentDelivered:  {},
+	models.FulfillmentCancelled:  {},
+}
+
+// StockCommitter interface for taking shipped units out of the stock their
+// order reserved
+type StockCommitter interface {
+	CommitStock(ctx context.Context, items []models.OrderItem) error
+}
+
+// fulfillmentService implements FulfillmentService
+type fulfillmentService struct {
+	repo      repository.FulfillmentRepository
+	orderRepo repository.OrderRepository
+	stock     StockCommitter
+}
+
+// NewFulfillmentService creates a new fulfillment service
+func NewFulfillmentService(repo repository.FulfillmentRepository, orderRepo repository.OrderRepository, stock StockCommitter) FulfillmentService {
+	return &fulfillmentService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		stock:     stock,
+	}
+}
+
+// CreateFulfillment creates a pending fulfillment for some of an order's items
+func (s *fulfillmentService) CreateFulfillment(ctx context.Context, orderID string, req *CreateFulfillmentRequest) (*models.Fulfillment, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch order.Status {
+	case models.OrderConfirmed, models.OrderProcessing, models.OrderPartiallyShipped:
+	default:
+		return nil, fmt.Errorf("invalid fulfillment: order is %s", order.Status)
+	}
+
+	existing, err := s.repo.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := unfulfilledQuantities(order, existing)
+
+	fulfillment := models.NewFulfillment(orderID)
+	fulfillment.Carrier = req.Carrier
+	fulfillment.TrackingNumber = req.TrackingNumber
+	fulfillment.TrackingURL = req.TrackingURL
+	fulfillment.Notes = req.Notes
+
+	if len(req.Items) == 0 {
+		for _, item := range order.Items {
+			if remaining[item.ID] > 0 {
+				fulfillment.Items = append(fulfillment.Items, models.FulfillmentItem{OrderItemID: item.ID, Quantity: remaining[item.ID]})
+			}
+		}
+		if len(fulfillment.Items) == 0 {
+			return nil, fmt.Errorf("invalid fulfillment: every item is already fulfilled")
+		}
+	}
+
+	requested := make(map[string]int)
+	for _, item := range req.Items {
+		if err := utils.ValidateStruct(&item); err != nil {
+			return nil, fmt.Errorf("invalid fulfillment: %w", err)
+		}
+		left, exists := remaining[item.OrderItemID]
+		if !exists {
+			return nil, fmt.Errorf("invalid fulfillment: order item %s is not part of the order", item.OrderItemID)
+		}
+		requested[item.OrderItemID] += item.Quantity
+		if requested[item.OrderItemID] > left {
+			return nil, fmt.Errorf("invalid fulfillment: only %d of order item %s remain unfulfilled", left, item.OrderItemID)
+		}
+	}
+	for _, item := range order.Items {
+		if quantity := requested[item.ID]; quantity > 0 {
+			fulfillment.Items = append(fulfillment.Items, models.FulfillmentItem{OrderItemID: item.ID, Quantity: quantity})
+		}
+	}
+
+	if err := s.repo.Create(ctx, fulfillment); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Fulfillment created", map[string]interface{}{
+		"order_id":       orderID,
+		"fulfillment_id": fulfillment.ID,
+		"item_count":     len(fulfillment.Items),
+	})
+
+	s.syncOrderStatus(ctx, order, "fulfillment created", "system")
+	return fulfillment, nil
+}
+
+// UpdateFulfillment moves a fulfillment to a new status
+func (s *fulfillmentService) UpdateFulfillment(ctx context.Context, id string, req *UpdateFulfillmentRequest) (*models.Fulfillment, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid fulfillment update: %w", err)
+	}
+
+	fulfillment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderRepo.GetByID(ctx, fulfillment.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransitionFulfillment(fulfillment.Status, req.Status) {
+		return nil, fmt.Errorf("invalid status transition: cannot move fulfillment from %s to %s", fulfillment.Status, req.Status)
+	}
+	if order.Status == models.OrderCancelled && req.Status != models.FulfillmentCancelled {
+		return nil, fmt.Errorf("invalid fulfillment update: order is cancelled")
+	}
+
+	if req.Carrier != "" {
+		fulfillment.Carrier = req.Carrier
+	}
+	if req.TrackingNumber != "" {
+		fulfillment.TrackingNumber = req.TrackingNumber
+	}
+	if req.TrackingURL != "" {
+		fulfillment.TrackingURL = req.TrackingURL
+	}
+	if req.Notes != "" {
+		fulfillment.Notes = req.Notes
+	}
+
+	from := fulfillment.Status
+	now := time.Now()
+	switch req.Status {
+	case models.FulfillmentShipped:
+		if fulfillment.Carrier == "" || fulfillment.TrackingNumber == "" {
+			return nil, fmt.Errorf("invalid fulfillment update: carrier and tracking number are required to ship")
+		}
+		fulfillment.ShippedAt = &now
+	case models.FulfillmentDelivered:
+		fulfillment.DeliveredAt = &now
+	}
+	fulfillment.Status = req.Status
+
+	// Only the request that moves the fulfillment on commits its stock, so
+	// concurrent ships of one fulfillment take its units out once
+	if err := s.repo.Update(ctx, fulfillment, from); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Fulfillment updated", map[string]interface{}{
+		"order_id":       fulfillment.OrderID,
+		"fulfillment_id": fulfillment.ID,
+		"status":         fulfillment.Status,
+	})
+
+	if fulfillment.Status == models.FulfillmentShipped {
+		s.commitStock(ctx, order, fulfillment)
+	}
+
+	changedBy := req.ChangedBy
+	if changedBy == "" {
+		changedBy = "system"
+	}
+	s.syncOrderStatus(ctx, order, "fulfillment "+string(fulfillment.Status), changedBy)
+	return fulfillment, nil
+}
+
+// commitStock takes a shipped fulfillment's units out of stock. The shipment
+// has already happened, so a failure is logged rather than returned.
+func (s *fulfillmentService) commitStock(ctx context.Context, order *models.Order, fulfillment *models.Fulfillment) {
+	if s.stock == nil {
+		return
+	}
+
+	orderItems := make(map[string]models.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+
+	items := make([]models.OrderItem, 0, len(fulfillment.Items))
+	for _, fulfilled := range fulfillment.Items {
+		item, ok := orderItems[fulfilled.OrderItemID]
+		if !ok {
+			continue
+		}
+		item.Quantity = fulfilled.Quantity
+		items = append(items, item)
+	}
+
+	if err := s.stock.CommitStock(ctx, items); err != nil {
+		utils.Logger.Error(ctx, "Failed to commit stock for shipped fulfillment", err, map[string]interface{}{
+			"order_id":       fulfillment.OrderID,
+			"fulfillment_id": fulfillment.ID,
+		})
+	}
+}
+
+// GetFulfillment retrieves a fulfillment by ID
+func (s *fulfillmentService) GetFulfillment(ctx context.Context, id string) (*models.Fulfillment, error) {
+	if id == "" {
+		return nil, fmt.Errorf("fulfillment ID is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListFulfillments returns an order's fulfillments
+func (s *fulfillmentService) ListFulfillments(ctx context.Context, orderID string) ([]models.Fulfillment, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+// syncOrderStatus moves the order to the status its fulfillments imply. The
+// fulfillment change has already been saved, so failures are only logged;
+// the next fulfillment change derives the status again.
+func (s *fulfillmentService) syncOrderStatus(ctx context.Context, order *models.Order, reason, changedBy string) {
+	fulfillments, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to load fulfillments for order status", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+		return
+	}
+
+	status := deriveOrderStatus(order, fulfillments)
+	if status == order.Status {
+		return
+	}
+	if !canTransitionOrder(order.Status, status) {
+		utils.Logger.Warn(ctx, "Fulfillments imply an unreachable order status", map[string]interface{}{
+			"order_id": order.ID,
+			"from":     order.Status,
+			"to":       status,
+		})
+		return
+	}
+
+	if err := s.orderRepo.UpdateStatus(ctx, order.ID, status, reason, changedBy); err != nil {
+		utils.Logger.Error(ctx, "Failed to update order status from fulfillments", err, map[string]interface{}{
+			"order_id": order.ID,
+			"status":   status,
+		})
+		return
+	}
+	order.Status = status
+}
+
+// deriveOrderStatus works out an order's status from its fulfillments:
+// delivered or shipped once every unit has, partially shipped once some
+// units have shipped, and processing while fulfillments are being prepared
+func deriveOrderStatus(order *models.Order, fulfillments []models.Fulfillment) models.OrderStatus {
+	ordered := 0
+	for _, item := range order.Items {
+		ordered += item.Quantity
+	}
+
+	var allocated, shipped, delivered int
+	for _, fulfillment := range fulfillments {
+		if !fulfillment.IsActive() {
+			continue
+		}
+		for _, item := range fulfillment.Items {
+			allocated += item.Quantity
+			if fulfillment.HasShipped() {
+				shipped += item.Quantity
+			}
+			if fulfillment.Status == models.FulfillmentDelivered {
+				delivered += item.Quantity
+			}
+		}
+	}
+
+	switch {
+	case ordered > 0 && delivered == ordered:
+		return models.OrderDelivered
+	case ordered > 0 && shipped == ordered:
+		return models.OrderShipped
+	case shipped > 0:
+		return models.OrderPartiallyShipped
+	case allocated > 0 && order.Status == models.OrderConfirmed:
+		return models.OrderProcessing
+	default:
+		return order.Status
+	}
+}
+
+// unfulfilledQuantities returns, per order item, the quantity not yet in an
+// active fulfillment
+func unfulfilledQuantities(order *models.Order, fulfillments []models.Fulfillment) map[string]int {
+	remaining := make(map[string]int, len(order.Items))
+	for _, item := range order.Items {
+		remaining[item.ID] += item.Quantity
+	}
+	for _, fulfillment := range fulfillments {
+		if !fulfillment.IsActive() {
+			continue
+		}
+		for _, item := range fulfillment.Items {
+			remaining[item.OrderItemID] -= item.Quantity
+		}
+	}
+	return remaining
+}
+
+func canTransitionFulfillment(from, to models.FulfillmentStatus) bool {
+	for _, allowed := range fulfillmentTransitions[from] {
+		if to == allowed {
+			return true
+		}
+	}
+	return false
+}
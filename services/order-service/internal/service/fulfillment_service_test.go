@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockFulfillmentRepository implements FulfillmentRepository for testing
+type MockFulfillmentRepository struct {
+	fulfillments map[string]models.Fulfillment
+	order        []string
+	// beforeUpdate runs before an update is saved, to interleave a request with it
+	beforeUpdate func()
+}
+
+func NewMockFulfillmentRepository() *MockFulfillmentRepository {
+	return &MockFulfillmentRepository{
+		fulfillments: make(map[string]models.Fulfillment),
+	}
+}
+
+func (m *MockFulfillmentRepository) Create(ctx context.Context, fulfillment *models.Fulfillment) error {
+	m.fulfillments[fulfillment.ID] = *fulfillment
+	m.order = append(m.order, fulfillment.ID)
+	return nil
+}
+
+func (m *MockFulfillmentRepository) Update(ctx context.Context, fulfillment *models.Fulfillment, from models.FulfillmentStatus) error {
+	if hook := m.beforeUpdate; hook != nil {
+		m.beforeUpdate = nil
+		hook()
+	}
+	stored, exists := m.fulfillments[fulfillment.ID]
+	if !exists {
+		return &NotFoundError{Resource: "fulfillment", ID: fulfillment.ID}
+	}
+	if stored.Status != from {
+		return repository.ErrFulfillmentChanged
+	}
+	m.fulfillments[fulfillment.ID] = *fulfillment
+	return nil
+}
+
+func (m *MockFulfillmentRepository) GetByID(ctx context.Context, id string) (*models.Fulfillment, error) {
+	fulfillment, exists := m.fulfillments[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "fulfillment", ID: id}
+	}
+	return &fulfillment, nil
+}
+
+func (m *MockFulfillmentRepository) ListByOrder(ctx context.Context, orderID string) ([]models.Fulfillment, error) {
+	fulfillments := []models.Fulfillment{}
+	for _, id := range m.order {
+		if fulfillment := m.fulfillments[id]; fulfillment.OrderID == orderID {
+			fulfillments = append(fulfillments, fulfillment)
+		}
+	}
+	return fulfillments, nil
+}
+
+// MockStockCommitter implements StockCommitter for testing
+type MockStockCommitter struct {
+	committed map[string]int
+}
+
+func (m *MockStockCommitter) CommitStock(ctx context.Context, items []models.OrderItem) error {
+	if m.committed == nil {
+		m.committed = make(map[string]int)
+	}
+	for _, item := range items {
+		m.committed[item.ProductID] += item.Quantity
+	}
+	return nil
+}
+
+// newFulfillmentTestOrder stores a confirmed order with two items: two of
+// item1 and one of item2
+func newFulfillmentTestOrder(t *testing.T, orderRepo *MockOrderRepository) *models.Order {
+	order := models.NewOrder("user1")
+	order.Status = models.OrderConfirmed
+	order.Items = []models.OrderItem{
+		{ID: "item1", OrderID: order.ID, ProductID: "prod1", Quantity: 2},
+		{ID: "item2", OrderID: order.ID, ProductID: "prod2", Quantity: 1},
+	}
+	require.NoError(t, orderRepo.Create(context.Background(), order))
+	return order
+}
+
+func shipFulfillment(t *testing.T, service FulfillmentService, id string) *models.Fulfillment {
+	fulfillment, err := service.UpdateFulfillment(context.Background(), id, &UpdateFulfillmentRequest{
+		Status:         models.FulfillmentShipped,
+		Carrier:        "UPS",
+		TrackingNumber: "1Z999-" + id,
+	})
+	require.NoError(t, err)
+	return fulfillment
+}
+
+func TestFulfillmentService_SplitShipment(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := NewMockOrderRepository()
+	stock := &MockStockCommitter{}
+	service := NewFulfillmentService(NewMockFulfillmentRepository(), orderRepo, stock)
+	order := newFulfillmentTestOrder(t, orderRepo)
+
+	first, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{
+		Items: []FulfillmentItemRequest{{OrderItemID: "item1", Quantity: 2}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.FulfillmentPending, first.Status)
+	assert.Equal(t, models.OrderProcessing, order.Status)
+
+	// Without items the second fulfillment takes whatever is left
+	second, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	assert.Equal(t, "item2", second.Items[0].OrderItemID)
+	assert.Equal(t, 1, second.Items[0].Quantity)
+
+	shipped := shipFulfillment(t, service, first.ID)
+	assert.NotNil(t, shipped.ShippedAt)
+	assert.Equal(t, models.OrderPartiallyShipped, order.Status)
+	assert.Equal(t, map[string]int{"prod1": 2}, stock.committed)
+
+	shipFulfillment(t, service, second.ID)
+	assert.Equal(t, models.OrderShipped, order.Status)
+	assert.Equal(t, map[string]int{"prod1": 2, "prod2": 1}, stock.committed)
+
+	for _, fulfillment := range []*models.Fulfillment{first, second} {
+		delivered, err := service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{Status: models.FulfillmentDelivered})
+		require.NoError(t, err)
+		assert.NotNil(t, delivered.DeliveredAt)
+	}
+	assert.Equal(t, models.OrderDelivered, order.Status)
+
+	history, err := orderRepo.GetStatusHistory(ctx, order.ID)
+	require.NoError(t, err)
+	var statuses []string
+	for _, change := range history {
+		statuses = append(statuses, change.ToStatus)
+	}
+	assert.Equal(t, []string{"processing", "partially_shipped", "shipped", "delivered"}, statuses)
+}
+
+func TestFulfillmentService_CreateFulfillment_OverAllocation(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := NewMockOrderRepository()
+	service := NewFulfillmentService(NewMockFulfillmentRepository(), orderRepo, nil)
+	order := newFulfillmentTestOrder(t, orderRepo)
+
+	_, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{
+		Items: []FulfillmentItemRequest{{OrderItemID: "item1", Quantity: 1}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		items []FulfillmentItemRequest
+		want  string
+	}{
+		{"more than remain", []FulfillmentItemRequest{{OrderItemID: "item1", Quantity: 2}}, "only 1 of order item item1 remain"},
+		{"duplicate entries", []FulfillmentItemRequest{{OrderItemID: "item1", Quantity: 1}, {OrderItemID: "item1", Quantity: 1}}, "only 1 of order item item1 remain"},
+		{"unknown item", []FulfillmentItemRequest{{OrderItemID: "item9", Quantity: 1}}, "not part of the order"},
+		{"zero quantity", []FulfillmentItemRequest{{OrderItemID: "item2", Quantity: 0}}, "invalid fulfillment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{Items: tt.items})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestFulfillmentService_CancelledFulfillmentReleasesItems(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := NewMockOrderRepository()
+	service := NewFulfillmentService(NewMockFulfillmentRepository(), orderRepo, nil)
+	order := newFulfillmentTestOrder(t, orderRepo)
+
+	fulfillment, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.NoError(t, err)
+
+	_, err = service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already fulfilled")
+
+	_, err = service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{Status: models.FulfillmentCancelled})
+	require.NoError(t, err)
+
+	replacement, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.NoError(t, err)
+	assert.Len(t, replacement.Items, 2)
+}
+
+func TestFulfillmentService_UpdateFulfillment_Validation(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := NewMockOrderRepository()
+	service := NewFulfillmentService(NewMockFulfillmentRepository(), orderRepo, nil)
+	order := newFulfillmentTestOrder(t, orderRepo)
+
+	fulfillment, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.NoError(t, err)
+
+	_, err = service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{Status: models.FulfillmentShipped, Carrier: "UPS"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tracking number are required")
+
+	_, err = service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{Status: models.FulfillmentDelivered})
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "invalid status transition"))
+
+	shipFulfillment(t, service, fulfillment.ID)
+	_, err = service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{Status: models.FulfillmentCancelled})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status transition")
+}
+
+func TestFulfillmentService_ConcurrentShipCommitsStockOnce(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := NewMockOrderRepository()
+	fulfillments := NewMockFulfillmentRepository()
+	stock := &MockStockCommitter{}
+	service := NewFulfillmentService(fulfillments, orderRepo, stock)
+	order := newFulfillmentTestOrder(t, orderRepo)
+
+	fulfillment, err := service.CreateFulfillment(ctx, order.ID, &CreateFulfillmentRequest{})
+	require.NoError(t, err)
+
+	// Another request ships the fulfillment after this one has read it
+	fulfillments.beforeUpdate = func() {
+		shipFulfillment(t, service, fulfillment.ID)
+	}
+	_, err = service.UpdateFulfillment(ctx, fulfillment.ID, &UpdateFulfillmentRequest{
+		Status:         models.FulfillmentShipped,
+		Carrier:        "FedEx",
+		TrackingNumber: "7489",
+	})
+	require.ErrorIs(t, err, repository.ErrFulfillmentChanged)
+
+	assert.Equal(t, map[string]int{"prod1": 2, "prod2": 1}, stock.committed)
+	stored, err := fulfillments.GetByID(ctx, fulfillment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "UPS", stored.Carrier)
+}
+
+func TestFulfillmentService_CreateFulfillment_OrderNotReady(t *testing.T) {
+	orderRepo := NewMockOrderRepository()
+	service := NewFulfillmentService(NewMockFulfillmentRepository(), orderRepo, nil)
+	order := newFulfillmentTestOrder(t, orderRepo)
+	order.Status = models.OrderPending
+
+	_, err := service.CreateFulfillment(context.Background(), order.ID, &CreateFulfillmentRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid fulfillment: order is pending")
+}
+
+func TestDeriveOrderStatus(t *testing.T) {
+	order := &models.Order{
+		Status: models.OrderProcessing,
+		Items:  []models.OrderItem{{ID: "item1", Quantity: 3}},
+	}
+	fulfillment := func(status models.FulfillmentStatus, quantity int) models.Fulfillment {
+		return models.Fulfillment{Status: status, Items: []models.FulfillmentItem{{OrderItemID: "item1", Quantity: quantity}}}
+	}
+
+	tests := []struct {
+		name         string
+		fulfillments []models.Fulfillment
+		want         models.OrderStatus
+	}{
+		{"none", nil, models.OrderProcessing},
+		{"pending only", []models.Fulfillment{fulfillment(models.FulfillmentPending, 3)}, models.OrderProcessing},
+		{"some shipped", []models.Fulfillment{fulfillment(models.FulfillmentShipped, 1), fulfillment(models.FulfillmentPending, 2)}, models.OrderPartiallyShipped},
+		{"some delivered", []models.Fulfillment{fulfillment(models.FulfillmentDelivered, 1), fulfillment(models.FulfillmentPending, 2)}, models.OrderPartiallyShipped},
+		{"all shipped", []models.Fulfillment{fulfillment(models.FulfillmentShipped, 1), fulfillment(models.FulfillmentDelivered, 2)}, models.OrderShipped},
+		{"all delivered", []models.Fulfillment{fulfillment(models.FulfillmentDelivered, 3)}, models.OrderDelivered},
+		{"cancelled ignored", []models.Fulfillment{fulfillment(models.FulfillmentCancelled, 3), fulfillment(models.FulfillmentShipped, 3)}, models.OrderShipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, deriveOrderStatus(order, tt.fulfillments))
+		})
+	}
+}
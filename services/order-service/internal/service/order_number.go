@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopsphere/order-service/internal/repository"
+)
+
+// OrderNumberAllocator assigns human-readable order numbers
+type OrderNumberAllocator interface {
+	Next(ctx context.Context) (string, error)
+}
+
+// OrderNumberFormat describes how order numbers are written, for example
+// ORD-20260601-0000422: prefix, date, zero-padded sequence and check digit
+type OrderNumberFormat struct {
+	Prefix     string // optional; must not contain '-'
+	DateLayout string // Go time layout for the date segment; empty omits it
+	Digits     int    // minimum width of the sequence segment
+	CheckDigit bool   // append a Luhn check digit to the sequence segment
+}
+
+// DefaultOrderNumberFormat returns the format used by ShopSphere
+func DefaultOrderNumberFormat() OrderNumberFormat {
+	return OrderNumberFormat{
+		Prefix:     "ORD",
+		DateLayout: "20060102",
+		Digits:     6,
+		CheckDigit: true,
+	}
+}
+
+// Format writes the order number for a sequence value allocated at the given time
+func (f OrderNumberFormat) Format(at time.Time, sequence int64) string {
+	number := fmt.Sprintf("%0*d", f.Digits, sequence)
+	if f.CheckDigit {
+		number += strconv.Itoa(luhnCheckDigit(number))
+	}
+
+	segments := make([]string, 0, 3)
+	if f.Prefix != "" {
+		segments = append(segments, f.Prefix)
+	}
+	if f.DateLayout != "" {
+		segments = append(segments, at.UTC().Format(f.DateLayout))
+	}
+	return strings.Join(append(segments, number), "-")
+}
+
+// Valid reports whether number is well formed, including its check digit.
+// It catches mistyped order numbers before they are looked up.
+func (f OrderNumberFormat) Valid(number string) bool {
+	segments := strings.Split(number, "-")
+	if f.Prefix != "" {
+		if len(segments) == 0 || segments[0] != f.Prefix {
+			return false
+		}
+		segments = segments[1:]
+	}
+	if f.DateLayout != "" {
+		if len(segments) == 0 {
+			return false
+		}
+		if _, err := time.Parse(f.DateLayout, segments[0]); err != nil {
+			return false
+		}
+		segments = segments[1:]
+	}
+	if len(segments) != 1 {
+		return false
+	}
+
+	sequence := segments[0]
+	minimum := f.Digits
+	if f.CheckDigit {
+		minimum++
+	}
+	if len(sequence) < minimum {
+		return false
+	}
+	if _, err := strconv.ParseUint(sequence, 10, 64); err != nil {
+		return false
+	}
+	if f.CheckDigit {
+		last := len(sequence) - 1
+		return strconv.Itoa(luhnCheckDigit(sequence[:last])) == sequence[last:]
+	}
+	return true
+}
+
+// luhnCheckDigit returns the Luhn check digit for a string of digits
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// sequenceOrderNumberAllocator formats values taken from a shared sequence,
+// so numbers stay unique however many replicas allocate them
+type sequenceOrderNumberAllocator struct {
+	sequence repository.OrderNumberSequence
+	format   OrderNumberFormat
+	now      func() time.Time
+}
+
+// NewOrderNumberAllocator creates an allocator that formats values from
+// sequence. A nil sequence counts in memory, which is only unique within
+// a single process.
+func NewOrderNumberAllocator(sequence repository.OrderNumberSequence, format OrderNumberFormat) OrderNumberAllocator {
+	if sequence == nil {
+		sequence = &memoryOrderNumberSequence{}
+	}
+	if format.Digits <= 0 {
+		format.Digits = DefaultOrderNumberFormat().Digits
+	}
+	return &sequenceOrderNumberAllocator{sequence: sequence, format: format, now: time.Now}
+}
+
+// Next allocates the next order number
+func (a *sequenceOrderNumberAllocator) Next(ctx context.Context) (string, error) {
+	value, err := a.sequence.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return a.format.Format(a.now(), value), nil
+}
+
+// memoryOrderNumberSequence is an in-process OrderNumberSequence
+type memoryOrderNumberSequence struct {
+	last int64
+}
+
+func (s *memoryOrderNumberSequence) Next(ctx context.Context) (int64, error) {
+	return atomic.AddInt64(&s.last, 1), nil
+}
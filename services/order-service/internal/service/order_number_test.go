@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderNumberFormat_Format(t *testing.T) {
+	at := time.Date(2026, 6, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		format   OrderNumberFormat
+		sequence int64
+		want     string
+	}{
+		{DefaultOrderNumberFormat(), 42, "ORD-20260601-0000422"},
+		{DefaultOrderNumberFormat(), 12345678, "ORD-20260601-123456782"},
+		{OrderNumberFormat{Prefix: "SS", Digits: 4}, 7, "SS-0007"},
+		{OrderNumberFormat{DateLayout: "0601", Digits: 3, CheckDigit: true}, 15, "2606-0158"},
+	}
+
+	for _, tt := range tests {
+		got := tt.format.Format(at, tt.sequence)
+		if got != tt.want {
+			t.Errorf("Format(%+v, %d) = %s, want %s", tt.format, tt.sequence, got, tt.want)
+		}
+		if !tt.format.Valid(got) {
+			t.Errorf("Expected %s to be valid for %+v", got, tt.format)
+		}
+	}
+}
+
+func TestOrderNumberFormat_ValidRejectsMistypedNumbers(t *testing.T) {
+	format := DefaultOrderNumberFormat()
+
+	invalid := []string{
+		"ORD-20260601-0000423", // wrong check digit
+		"ORD-20260601-0004022", // transposed digits
+		"ORD-20261301-0000422", // no such date
+		"INV-20260601-0000422",
+		"ORD-20260601-042",
+		"ORD-1717200000",
+	}
+	for _, number := range invalid {
+		if format.Valid(number) {
+			t.Errorf("Expected %s to be invalid", number)
+		}
+	}
+}
+
+func TestOrderNumberAllocator_UniqueAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	// Replicas share one sequence, as they share order_number_seq
+	sequence := &memoryOrderNumberSequence{}
+	replicas := []OrderNumberAllocator{
+		NewOrderNumberAllocator(sequence, DefaultOrderNumberFormat()),
+		NewOrderNumberAllocator(sequence, DefaultOrderNumberFormat()),
+		NewOrderNumberAllocator(sequence, DefaultOrderNumberFormat()),
+	}
+
+	const perReplica = 2000
+	numbers := make(chan string, perReplica*len(replicas))
+	var wg sync.WaitGroup
+	for _, allocator := range replicas {
+		for i := 0; i < perReplica; i++ {
+			wg.Add(1)
+			go func(allocator OrderNumberAllocator) {
+				defer wg.Done()
+				number, err := allocator.Next(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				numbers <- number
+			}(allocator)
+		}
+	}
+	wg.Wait()
+	close(numbers)
+
+	seen := make(map[string]bool)
+	for number := range numbers {
+		if seen[number] {
+			t.Fatalf("Order number %s allocated twice", number)
+		}
+		seen[number] = true
+	}
+	if len(seen) != perReplica*len(replicas) {
+		t.Errorf("Expected %d order numbers, got %d", perReplica*len(replicas), len(seen))
+	}
+}
+
+func TestOrderService_CreateOrder_ConcurrentOrdersGetUniqueNumbers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockOrderRepository()
+	service := NewOrderService(repo, NewMockProductService(), nil, nil, nil, nil, nil)
+
+	const orders = 2000
+	var wg sync.WaitGroup
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, order := range repo.orders {
+		if seen[order.OrderNumber] {
+			t.Fatalf("Order number %s used by two orders", order.OrderNumber)
+		}
+		seen[order.OrderNumber] = true
+	}
+	if len(seen) != orders {
+		t.Errorf("Expected %d distinct order numbers, got %d", orders, len(seen))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 )
@@ -17,7 +18,8 @@ type OrderService interface {
 	CreateOrder(ctx context.Context, req *CreateOrderRequest) (*models.Order, error)
 	GetOrder(ctx context.Context, id string) (*models.Order, error)
 	GetOrdersByUser(ctx context.Context, userID string, limit, offset int) ([]*models.Order, error)
-	UpdateOrder(ctx context.Context, order *models.Order) error
+	UpdateOrder(ctx context.Context, id string, req *UpdateOrderRequest) error
+	RecordPayment(ctx context.Context, orderID, paymentStatus, paymentReference string) error
 	UpdateOrderStatus(ctx context.Context, orderID string, status models.OrderStatus, reason string, changedBy string) error
 	CancelOrder(ctx context.Context, orderID string, reason string, cancelledBy string) error
 	SearchOrders(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]*models.Order, error)
@@ -36,35 +38,67 @@ type CreateOrderRequest struct {
 	ShippingMethod  string              `json:"shipping_method"`
 	Notes           string              `json:"notes"`
 	Source          string              `json:"source"`
+	CouponCodes     []string            `json:"coupon_codes"`
+	Currency        string              `json:"currency"`
 }
 
-// OrderItemRequest represents an item in an order request
+// UpdateOrderRequest changes the details of an order its customer can edit.
+// Fields left out are kept; totals, status and payment are never taken from
+// the client.
+type UpdateOrderRequest struct {
+	Notes           *string         `json:"notes"`
+	ShippingAddress *models.Address `json:"shipping_address"`
+	BillingAddress  *models.Address `json:"billing_address"`
+}
+
+// OrderItemRequest represents an item in an order request. Price is the unit
+// price the client was shown; it is optional, but when set it must match the
+// catalog price. The order is always charged at the catalog price.
 type OrderItemRequest struct {
 	ProductID string          `json:"product_id" validate:"required"`
 	VariantID string          `json:"variant_id"`
 	Quantity  int             `json:"quantity" validate:"required,min=1"`
-	Price     decimal.Decimal `json:"price" validate:"required"`
+	Price     decimal.Decimal `json:"price"`
+	// PlanPrice is the unit price of a subscription renewal. It replaces the
+	// catalog price and can only be set by the subscription scheduler.
+	PlanPrice decimal.Decimal `json:"-"`
 }
 
 // OrderTotals represents calculated order totals
 type OrderTotals struct {
-	Subtotal decimal.Decimal `json:"subtotal"`
-	Tax      decimal.Decimal `json:"tax"`
-	Shipping decimal.Decimal `json:"shipping"`
-	Discount decimal.Decimal `json:"discount"`
-	Total    decimal.Decimal `json:"total"`
+	Subtotal    decimal.Decimal `json:"subtotal"`
+	Tax         decimal.Decimal `json:"tax"`
+	TaxIncluded decimal.Decimal `json:"tax_included"`
+	Shipping    decimal.Decimal `json:"shipping"`
+	Discount    decimal.Decimal `json:"discount"`
+	Total       decimal.Decimal `json:"total"`
+
+	Discounts []models.OrderDiscount `json:"discounts"`
 }
 
 // ProductService interface for product validation
 type ProductService interface {
 	GetProduct(ctx context.Context, id string) (*models.Product, error)
+	GetVariant(ctx context.Context, productID, variantID string) (*models.ProductVariant, error)
 	ValidateStock(ctx context.Context, productID string, quantity int) error
 }
 
+// pricedItem is an order item request resolved against the catalog
+type pricedItem struct {
+	request   OrderItemRequest
+	product   *models.Product
+	variant   *models.ProductVariant
+	unitPrice decimal.Decimal
+	source    models.PriceSource
+}
+
 // InventoryService interface for inventory management
 type InventoryService interface {
 	ReserveStock(ctx context.Context, items []models.OrderItem) error
 	ReleaseStock(ctx context.Context, items []models.OrderItem) error
+	// AllocateStock picks the warehouses the stock reserved for an order
+	// ships from
+	AllocateStock(ctx context.Context, orderID string, shipTo models.Address) (*models.AllocationPlan, error)
 }
 
 // orderService implements OrderService
@@ -72,14 +106,34 @@ type orderService struct {
 	repo             repository.OrderRepository
 	productService   ProductService
 	inventoryService InventoryService
+	taxCalculator    TaxCalculator
+	promotions       PromotionEngine
+	orderNumbers     OrderNumberAllocator
+	rates            ExchangeRates
 }
 
-// NewOrderService creates a new order service
-func NewOrderService(repo repository.OrderRepository, productService ProductService, inventoryService InventoryService) OrderService {
+// NewOrderService creates a new order service. A nil taxCalculator charges
+// the default flat sales tax, a nil promotions engine applies no discounts,
+// a nil orderNumbers allocator numbers orders in memory and nil rates only
+// allow orders in currencies every item has a list price in.
+func NewOrderService(repo repository.OrderRepository, productService ProductService, inventoryService InventoryService, taxCalculator TaxCalculator, promotions PromotionEngine, orderNumbers OrderNumberAllocator, rates ExchangeRates) OrderService {
+	if taxCalculator == nil {
+		taxCalculator = DefaultTaxCalculator()
+	}
+	if promotions == nil {
+		promotions = noPromotions{}
+	}
+	if orderNumbers == nil {
+		orderNumbers = NewOrderNumberAllocator(nil, DefaultOrderNumberFormat())
+	}
 	return &orderService{
 		repo:             repo,
 		productService:   productService,
 		inventoryService: inventoryService,
+		taxCalculator:    taxCalculator,
+		promotions:       promotions,
+		orderNumbers:     orderNumbers,
+		rates:            rates,
 	}
 }
 
@@ -90,29 +144,47 @@ func (s *orderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		return nil, fmt.Errorf("invalid request: %w", err)
 	}
 
-	// Validate order items
-	if err := s.ValidateOrderItems(ctx, req.Items); err != nil {
+	if s.productService == nil {
+		return nil, fmt.Errorf("product catalog is not configured")
+	}
+
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	converter := newPriceConverter(money, s.rates)
+
+	// Price every item from the catalog; client prices are only cross-checked
+	pricedItems, err := s.priceItems(ctx, req.Items, converter)
+	if err != nil {
 		return nil, fmt.Errorf("invalid order items: %w", err)
 	}
 
 	// Calculate totals
-	totals, err := s.CalculateOrderTotals(ctx, req)
+	totals, taxes, err := s.calculateTotals(ctx, req, pricedItems, converter)
+	if err != nil {
+		return nil, err
+	}
+
+	orderNumber, err := s.orderNumbers.Next(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate totals: %w", err)
+		return nil, err
 	}
 
 	// Create order
 	order := &models.Order{
 		ID:              uuid.New().String(),
-		OrderNumber:     generateOrderNumber(),
+		OrderNumber:     orderNumber,
 		UserID:          req.UserID,
 		Status:          models.OrderPending,
 		Subtotal:        totals.Subtotal,
 		Tax:             totals.Tax,
+		TaxIncluded:     totals.TaxIncluded,
 		Shipping:        totals.Shipping,
 		Discount:        totals.Discount,
 		Total:           totals.Total,
-		Currency:        "USD",
+		Currency:        money.Code,
+		ExchangeRates:   converter.used,
 		ShippingAddress: req.ShippingAddress,
 		BillingAddress:  req.BillingAddress,
 		PaymentMethod:   req.PaymentMethod,
@@ -121,6 +193,7 @@ func (s *orderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		Notes:           req.Notes,
 		Source:          req.Source,
 		Items:           make([]models.OrderItem, 0, len(req.Items)),
+		Discounts:       totals.Discounts,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
@@ -129,46 +202,11 @@ func (s *orderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		order.Source = "web"
 	}
 
-	// Convert request items to order items
-	for _, itemReq := range req.Items {
-		// Get product details
-		product, err := s.productService.GetProduct(ctx, itemReq.ProductID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get product %s: %w", itemReq.ProductID, err)
-		}
-
-		item := models.OrderItem{
-			ID:          uuid.New().String(),
-			OrderID:     order.ID,
-			ProductID:   itemReq.ProductID,
-			VariantID:   itemReq.VariantID,
-			SKU:         product.SKU,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       itemReq.Price,
-			Quantity:    itemReq.Quantity,
-			Total:       itemReq.Price.Mul(decimal.NewFromInt(int64(itemReq.Quantity))),
-			CreatedAt:   time.Now(),
-		}
-
-		// Add product attributes snapshot
-		attributesMap := map[string]interface{}{
-			"brand":      product.Attributes.Brand,
-			"color":      product.Attributes.Color,
-			"size":       product.Attributes.Size,
-			"weight":     product.Attributes.Weight,
-			"dimensions": product.Attributes.Dimensions,
-		}
-		for k, v := range product.Attributes.Custom {
-			attributesMap[k] = v
-		}
-		item.ProductAttributes = attributesMap
-
-		// Add primary image
-		if len(product.Images) > 0 {
-			item.ImageURL = product.Images[0]
-		}
-
+	// Convert priced items to order items
+	for i, priced := range pricedItems {
+		item := newOrderItem(order.ID, priced)
+		item.Tax = taxes.Lines[i].Tax
+		item.TaxBreakdown = taxes.Lines[i].Breakdown
 		order.Items = append(order.Items, item)
 	}
 
@@ -177,6 +215,7 @@ func (s *orderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		if err := s.inventoryService.ReserveStock(ctx, order.Items); err != nil {
 			return nil, fmt.Errorf("failed to reserve stock: %w", err)
 		}
+		allocateOrigin(ctx, s.inventoryService, order)
 	}
 
 	// Create order in database
@@ -199,6 +238,27 @@ func (s *orderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 	return order, nil
 }
 
+// allocateOrigin allocates an order's reserved stock to warehouses and ships
+// the order from the one most of it is allocated to. An order that cannot be
+// allocated ships from the default warehouse, so failures are only logged.
+func allocateOrigin(ctx context.Context, inventory InventoryService, order *models.Order) {
+	plan, err := inventory.AllocateStock(ctx, order.ID, order.ShippingAddress)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to allocate order stock to warehouses", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+		return
+	}
+
+	origin := plan.Origin()
+	if origin == nil {
+		return
+	}
+	address := origin.Address
+	order.OriginWarehouseID = origin.ID
+	order.OriginAddress = &address
+}
+
 // GetOrder retrieves an order by ID
 func (s *orderService) GetOrder(ctx context.Context, id string) (*models.Order, error) {
 	if id == "" {
@@ -234,26 +294,34 @@ func (s *orderService) GetOrdersByUser(ctx context.Context, userID string, limit
 	return orders, nil
 }
 
-// UpdateOrder updates an existing order
-func (s *orderService) UpdateOrder(ctx context.Context, order *models.Order) error {
-	if order == nil || order.ID == "" {
+// UpdateOrder updates the notes and addresses of an existing order
+func (s *orderService) UpdateOrder(ctx context.Context, id string, req *UpdateOrderRequest) error {
+	if id == "" || req == nil {
 		return fmt.Errorf("invalid order")
 	}
 
 	// Validate that order exists
-	existing, err := s.repo.GetByID(ctx, order.ID)
+	order, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("order not found: %w", err)
 	}
 
 	// Prevent updates to cancelled or completed orders
-	if existing.Status == models.OrderCancelled || existing.Status == models.OrderDelivered {
-		return fmt.Errorf("cannot update order in status: %s", existing.Status)
+	if order.Status == models.OrderCancelled || order.Status == models.OrderDelivered {
+		return fmt.Errorf("cannot update order in status: %s", order.Status)
 	}
 
-	order.UpdatedAt = time.Now()
+	if req.Notes != nil {
+		order.Notes = *req.Notes
+	}
+	if req.ShippingAddress != nil {
+		order.ShippingAddress = *req.ShippingAddress
+	}
+	if req.BillingAddress != nil {
+		order.BillingAddress = *req.BillingAddress
+	}
 
-	if err := s.repo.Update(ctx, order); err != nil {
+	if err := s.repo.UpdateDetails(ctx, order); err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
 	}
 
@@ -265,6 +333,18 @@ func (s *orderService) UpdateOrder(ctx context.Context, order *models.Order) err
 	return nil
 }
 
+// RecordPayment records the status and reference of the payment for an order
+func (s *orderService) RecordPayment(ctx context.Context, orderID, paymentStatus, paymentReference string) error {
+	if orderID == "" {
+		return fmt.Errorf("order ID is required")
+	}
+
+	if err := s.repo.UpdatePayment(ctx, orderID, paymentStatus, paymentReference); err != nil {
+		return fmt.Errorf("failed to record order payment: %w", err)
+	}
+	return nil
+}
+
 // UpdateOrderStatus updates the status of an order
 func (s *orderService) UpdateOrderStatus(ctx context.Context, orderID string, status models.OrderStatus, reason string, changedBy string) error {
 	if orderID == "" {
@@ -304,7 +384,7 @@ func (s *orderService) CancelOrder(ctx context.Context, orderID string, reason s
 	if order.Status == models.OrderDelivered {
 		return fmt.Errorf("cannot cancel delivered order")
 	}
-	if order.Status == models.OrderShipped {
+	if order.Status == models.OrderShipped || order.Status == models.OrderPartiallyShipped {
 		return fmt.Errorf("cannot cancel shipped order")
 	}
 
@@ -364,6 +444,114 @@ func (s *orderService) GetOrderStatusHistory(ctx context.Context, orderID string
 
 // ValidateOrderItems validates order items against product catalog
 func (s *orderService) ValidateOrderItems(ctx context.Context, items []OrderItemRequest) error {
+	if s.productService == nil {
+		return validateItemRequests(items)
+	}
+
+	money, err := resolveCurrency("")
+	if err != nil {
+		return err
+	}
+	_, err = s.priceItems(ctx, items, newPriceConverter(money, s.rates))
+	return err
+}
+
+// CalculateOrderTotals calculates order totals including tax and shipping.
+// Items are priced from the catalog; request prices are only used when no
+// catalog is configured.
+func (s *orderService) CalculateOrderTotals(ctx context.Context, req *CreateOrderRequest) (*OrderTotals, error) {
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	converter := newPriceConverter(money, s.rates)
+
+	var pricedItems []pricedItem
+	if s.productService == nil {
+		for _, item := range req.Items {
+			pricedItems = append(pricedItems, pricedItem{
+				request:   item,
+				product:   &models.Product{ID: item.ProductID, TaxClass: models.TaxClassStandard},
+				unitPrice: item.Price,
+				source:    models.PriceSourceClient,
+			})
+		}
+	} else if pricedItems, err = s.priceItems(ctx, req.Items, converter); err != nil {
+		return nil, err
+	}
+
+	totals, _, err := s.calculateTotals(ctx, req, pricedItems, converter)
+	return totals, err
+}
+
+// priceItems resolves each item against the catalog in the converter's
+// currency, rejecting unknown products or variants, client prices that
+// disagree with the catalog and quantities that exceed available stock
+func (s *orderService) priceItems(ctx context.Context, items []OrderItemRequest, converter *priceConverter) ([]pricedItem, error) {
+	if err := validateItemRequests(items); err != nil {
+		return nil, err
+	}
+
+	pricedItems := make([]pricedItem, 0, len(items))
+	for i, item := range items {
+		product, err := s.productService.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: invalid product %s: %w", i, item.ProductID, err)
+		}
+
+		priced := pricedItem{
+			request: item,
+			product: product,
+			source:  models.PriceSourceProduct,
+		}
+
+		if item.VariantID != "" {
+			variant, err := s.productService.GetVariant(ctx, item.ProductID, item.VariantID)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: invalid variant %s: %w", i, item.VariantID, err)
+			}
+			if variant.ProductID != "" && variant.ProductID != item.ProductID {
+				return nil, fmt.Errorf("item %d: invalid variant %s for product %s", i, item.VariantID, item.ProductID)
+			}
+			priced.variant = variant
+			priced.source = models.PriceSourceVariant
+		}
+
+		if item.PlanPrice.IsPositive() {
+			priced.unitPrice = item.PlanPrice
+			priced.source = models.PriceSourceSubscription
+		} else if priced.unitPrice, err = converter.catalogPrice(product, priced.variant); err != nil {
+			return nil, fmt.Errorf("item %d: cannot price product %s in %s: %w", i, item.ProductID, converter.currency.Code, err)
+		}
+
+		if priced.unitPrice.LessThanOrEqual(decimal.Zero) {
+			return nil, fmt.Errorf("item %d: product %s has no valid price", i, item.ProductID)
+		}
+
+		// Validate price matches current catalog price
+		if !item.Price.IsZero() && !item.Price.Equal(priced.unitPrice) {
+			return nil, fmt.Errorf("item %d: price mismatch for product %s: requested %s, catalog price %s",
+				i, item.ProductID, item.Price, priced.unitPrice)
+		}
+
+		// Validate stock availability; a variant has stock of its own
+		if variant := priced.variant; variant != nil {
+			if available := variant.AvailableStock(); available < item.Quantity {
+				return nil, fmt.Errorf("item %d: stock validation failed for product %s: insufficient stock for variant %s: requested %d, available %d",
+					i, item.ProductID, item.VariantID, item.Quantity, available)
+			}
+		} else if err := s.productService.ValidateStock(ctx, item.ProductID, item.Quantity); err != nil {
+			return nil, fmt.Errorf("item %d: stock validation failed for product %s: %w", i, item.ProductID, err)
+		}
+
+		pricedItems = append(pricedItems, priced)
+	}
+
+	return pricedItems, nil
+}
+
+// validateItemRequests checks what can be checked without the catalog
+func validateItemRequests(items []OrderItemRequest) error {
 	if len(items) == 0 {
 		return fmt.Errorf("order must contain at least one item")
 	}
@@ -375,64 +563,173 @@ func (s *orderService) ValidateOrderItems(ctx context.Context, items []OrderItem
 		if item.Quantity <= 0 {
 			return fmt.Errorf("item %d: quantity must be positive", i)
 		}
-		if item.Price.LessThanOrEqual(decimal.Zero) {
+		if item.Price.IsNegative() {
 			return fmt.Errorf("item %d: price must be positive", i)
 		}
+	}
 
-		// Validate product exists
-		if s.productService != nil {
-			product, err := s.productService.GetProduct(ctx, item.ProductID)
-			if err != nil {
-				return fmt.Errorf("item %d: invalid product %s: %w", i, item.ProductID, err)
-			}
-
-			// Validate price matches current product price
-			if !item.Price.Equal(product.Price) {
-				return fmt.Errorf("item %d: price mismatch for product %s", i, item.ProductID)
-			}
+	return nil
+}
 
-			// Validate stock availability
-			if err := s.productService.ValidateStock(ctx, item.ProductID, item.Quantity); err != nil {
-				return fmt.Errorf("item %d: stock validation failed for product %s: %w", i, item.ProductID, err)
-			}
+// newOrderItem snapshots a priced catalog entry into an order item
+func newOrderItem(orderID string, priced pricedItem) models.OrderItem {
+	product := priced.product
+	item := models.OrderItem{
+		ID:          uuid.New().String(),
+		OrderID:     orderID,
+		ProductID:   priced.request.ProductID,
+		VariantID:   priced.request.VariantID,
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       priced.unitPrice,
+		PriceSource: priced.source,
+		Quantity:    priced.request.Quantity,
+		Total:       priced.lineTotal(),
+		TaxClass:    priced.taxClass(),
+		CreatedAt:   time.Now(),
+	}
+
+	// Add product attributes snapshot
+	attributesMap := map[string]interface{}{
+		"brand":      product.Attributes.Brand,
+		"color":      product.Attributes.Color,
+		"size":       product.Attributes.Size,
+		"weight":     product.Attributes.Weight,
+		"dimensions": product.Attributes.Dimensions,
+	}
+	for k, v := range product.Attributes.Custom {
+		attributesMap[k] = v
+	}
+
+	// Add primary image
+	if len(product.Images) > 0 {
+		item.ImageURL = product.Images[0]
+	}
+
+	// Variant details override the product's
+	if variant := priced.variant; variant != nil {
+		item.SKU = variant.SKU
+		attributesMap["variant_name"] = variant.Name
+		if variant.Weight > 0 {
+			attributesMap["weight"] = variant.Weight
+		}
+		for k, v := range variant.Attributes {
+			attributesMap[k] = v
+		}
+		if variant.ImageURL != "" {
+			item.ImageURL = variant.ImageURL
 		}
 	}
+	item.ProductAttributes = attributesMap
 
-	return nil
+	return item
 }
 
-// CalculateOrderTotals calculates order totals including tax and shipping
-func (s *orderService) CalculateOrderTotals(ctx context.Context, req *CreateOrderRequest) (*OrderTotals, error) {
+func (p pricedItem) lineTotal() decimal.Decimal {
+	return p.unitPrice.Mul(decimal.NewFromInt(int64(p.request.Quantity)))
+}
+
+func (p pricedItem) taxClass() string {
+	if p.product.TaxClass == "" {
+		return models.TaxClassStandard
+	}
+	return p.product.TaxClass
+}
+
+// calculateTotals derives shipping, discounts and tax for the priced items.
+// Tax is charged on the discounted amounts. Tax from tax-inclusive rules is
+// already part of the subtotal and shipping, so only the remaining tax is
+// added to the total.
+func (s *orderService) calculateTotals(ctx context.Context, req *CreateOrderRequest, items []pricedItem, converter *priceConverter) (*OrderTotals, *TaxResult, error) {
 	subtotal := decimal.Zero
-	
-	// Calculate subtotal
-	for _, item := range req.Items {
-		itemTotal := item.Price.Mul(decimal.NewFromInt(int64(item.Quantity)))
-		subtotal = subtotal.Add(itemTotal)
+	promoReq := &PromotionRequest{UserID: req.UserID, CouponCodes: req.CouponCodes, Currency: converter.currency.Code}
+	for _, item := range items {
+		subtotal = subtotal.Add(item.lineTotal())
+		promoReq.Items = append(promoReq.Items, PromotionItem{
+			ProductID: item.request.ProductID,
+			Quantity:  item.request.Quantity,
+			UnitPrice: item.unitPrice,
+		})
+	}
+
+	// Calculate shipping (simplified - flat rate for now), set in the store
+	// currency
+	shipping, err := converter.convert(decimal.NewFromFloat(10.00), currency.DefaultCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to price shipping: %w", err)
 	}
+	freeShippingOver, err := converter.convert(decimal.NewFromFloat(100.00), currency.DefaultCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to price shipping: %w", err)
+	}
+	if subtotal.GreaterThan(freeShippingOver) {
+		shipping = decimal.Zero // Free shipping over $100
+	}
+	promoReq.Shipping = shipping
 
-	// Calculate tax (simplified - 10% for now)
-	tax := subtotal.Mul(decimal.NewFromFloat(0.10))
+	promotions, err := s.promotions.Apply(ctx, promoReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to apply promotions: %w", err)
+	}
+	if len(promotions.Rejected) > 0 {
+		rejected := promotions.Rejected[0]
+		return nil, nil, fmt.Errorf("invalid coupon %s: %s", rejected.Code, rejected.Reason)
+	}
 
-	// Calculate shipping (simplified - flat rate for now)
-	shipping := decimal.NewFromFloat(10.00)
-	if subtotal.GreaterThan(decimal.NewFromFloat(100.00)) {
-		shipping = decimal.Zero // Free shipping over $100
+	taxReq := &TaxRequest{
+		Currency: converter.currency.Code,
+		Address:  req.ShippingAddress,
+		Lines:    make([]TaxableLine, 0, len(items)),
+		Shipping: shipping.Sub(promotions.ShippingDiscount),
+	}
+	for i, item := range items {
+		taxReq.Lines = append(taxReq.Lines, TaxableLine{
+			TaxClass: item.taxClass(),
+			Amount:   item.lineTotal().Sub(promotions.LineDiscounts[i]),
+		})
 	}
 
-	// Calculate discount (none for now)
-	discount := decimal.Zero
+	taxes, err := s.taxCalculator.Calculate(ctx, taxReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to calculate tax: %w", err)
+	}
 
 	// Calculate total
-	total := subtotal.Add(tax).Add(shipping).Sub(discount)
+	discount := promotions.Discount
+	total := subtotal.Add(shipping).Sub(discount).Add(taxes.Tax.Sub(taxes.Included))
 
 	return &OrderTotals{
-		Subtotal: subtotal,
-		Tax:      tax,
-		Shipping: shipping,
-		Discount: discount,
-		Total:    total,
-	}, nil
+		Subtotal:    subtotal,
+		Tax:         taxes.Tax,
+		TaxIncluded: taxes.Included,
+		Shipping:    shipping,
+		Discount:    discount,
+		Total:       total,
+		Discounts:   promotions.Discounts,
+	}, taxes, nil
+}
+
+// orderStatusTransitions lists the statuses each order status can move to
+var orderStatusTransitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderPending:          {models.OrderConfirmed, models.OrderCancelled},
+	models.OrderConfirmed:        {models.OrderProcessing, models.OrderCancelled},
+	models.OrderProcessing:       {models.OrderPartiallyShipped, models.OrderShipped, models.OrderCancelled},
+	models.OrderPartiallyShipped: {models.OrderShipped},
+	models.OrderShipped:          {models.OrderDelivered},
+	models.OrderDelivered:        {models.OrderRefunded},
+	models.OrderCancelled:        {}, // No transitions from cancelled
+	models.OrderRefunded:         {}, // No transitions from refunded
+}
+
+// canTransitionOrder reports whether an order may move from one status to another
+func canTransitionOrder(from, to models.OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[from] {
+		if to == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 // validateStatusTransition validates if a status transition is allowed
@@ -443,34 +740,12 @@ func (s *orderService) validateStatusTransition(ctx context.Context, orderID str
 	}
 
 	currentStatus := order.Status
-
-	// Define valid transitions
-	validTransitions := map[models.OrderStatus][]models.OrderStatus{
-		models.OrderPending:    {models.OrderConfirmed, models.OrderCancelled},
-		models.OrderConfirmed:  {models.OrderProcessing, models.OrderCancelled},
-		models.OrderProcessing: {models.OrderShipped, models.OrderCancelled},
-		models.OrderShipped:    {models.OrderDelivered},
-		models.OrderDelivered:  {models.OrderRefunded},
-		models.OrderCancelled:  {}, // No transitions from cancelled
-		models.OrderRefunded:   {}, // No transitions from refunded
-	}
-
-	allowedStatuses, exists := validTransitions[currentStatus]
-	if !exists {
+	if _, exists := orderStatusTransitions[currentStatus]; !exists {
 		return fmt.Errorf("unknown current status: %s", currentStatus)
 	}
-
-	// Check if transition is allowed
-	for _, allowed := range allowedStatuses {
-		if newStatus == allowed {
-			return nil
-		}
+	if canTransitionOrder(currentStatus, newStatus) {
+		return nil
 	}
 
 	return fmt.Errorf("cannot transition from %s to %s", currentStatus, newStatus)
 }
-
-// generateOrderNumber generates a unique order number
-func generateOrderNumber() string {
-	return fmt.Sprintf("ORD-%d", time.Now().Unix())
-}
@@ -2,15 +2,19 @@ package service
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 )
 
 // MockOrderRepository implements OrderRepository for testing
 type MockOrderRepository struct {
+	mu            sync.Mutex // guards orders for concurrent Create calls
 	orders        map[string]*models.Order
 	statusHistory map[string][]models.OrderStatusHistory
 }
@@ -23,6 +27,8 @@ func NewMockOrderRepository() *MockOrderRepository {
 }
 
 func (m *MockOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.orders[order.ID] = order
 	return nil
 }
@@ -53,6 +59,27 @@ func (m *MockOrderRepository) Update(ctx context.Context, order *models.Order) e
 	return nil
 }
 
+func (m *MockOrderRepository) UpdateDetails(ctx context.Context, order *models.Order) error {
+	stored, exists := m.orders[order.ID]
+	if !exists {
+		return &NotFoundError{Resource: "order", ID: order.ID}
+	}
+	stored.Notes = order.Notes
+	stored.ShippingAddress = order.ShippingAddress
+	stored.BillingAddress = order.BillingAddress
+	return nil
+}
+
+func (m *MockOrderRepository) UpdatePayment(ctx context.Context, orderID, paymentStatus, paymentReference string) error {
+	order, exists := m.orders[orderID]
+	if !exists {
+		return &NotFoundError{Resource: "order", ID: orderID}
+	}
+	order.PaymentStatus = paymentStatus
+	order.PaymentReference = paymentReference
+	return nil
+}
+
 func (m *MockOrderRepository) UpdateStatus(ctx context.Context, orderID string, status models.OrderStatus, reason string, changedBy string) error {
 	order, exists := m.orders[orderID]
 	if !exists {
@@ -112,6 +139,7 @@ func (m *MockOrderRepository) GetStatusHistory(ctx context.Context, orderID stri
 // MockProductService implements ProductService for testing
 type MockProductService struct {
 	products map[string]*models.Product
+	variants map[string]*models.ProductVariant
 }
 
 func NewMockProductService() *MockProductService {
@@ -135,6 +163,18 @@ func NewMockProductService() *MockProductService {
 				},
 			},
 		},
+		variants: map[string]*models.ProductVariant{
+			"var1": {
+				ID:         "var1",
+				ProductID:  "prod1",
+				SKU:        "SKU001-XL",
+				Name:       "Extra Large",
+				Price:      decimal.NewFromFloat(109.99),
+				Stock:      5,
+				Weight:     1.2,
+				Attributes: map[string]interface{}{"size": "XL"},
+			},
+		},
 	}
 }
 
@@ -146,6 +186,14 @@ func (m *MockProductService) GetProduct(ctx context.Context, id string) (*models
 	return product, nil
 }
 
+func (m *MockProductService) GetVariant(ctx context.Context, productID, variantID string) (*models.ProductVariant, error) {
+	variant, exists := m.variants[variantID]
+	if !exists {
+		return nil, &NotFoundError{Resource: "variant", ID: variantID}
+	}
+	return variant, nil
+}
+
 func (m *MockProductService) ValidateStock(ctx context.Context, productID string, quantity int) error {
 	product, exists := m.products[productID]
 	if !exists {
@@ -181,7 +229,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
 	productService := NewMockProductService()
-	service := NewOrderService(repo, productService, nil)
+	service := NewOrderService(repo, productService, nil, nil, nil, nil, nil)
 
 	req := &CreateOrderRequest{
 		UserID: "user1",
@@ -231,10 +279,9 @@ func TestOrderService_CreateOrder(t *testing.T) {
 	}
 
 	// Subtotal: 99.99 * 2 = 199.98
-	// Tax: 199.98 * 0.10 = 19.998
-	// Shipping: 10.00 (since subtotal > 100, shipping should be 0, but let's check actual logic)
-	// Total: 199.98 + 19.998 + 10.00 = 229.978
-	expectedTotal := decimal.NewFromFloat(219.978) // Based on actual calculation: 199.98 + 19.998 + 0 (free shipping)
+	// Tax: 199.98 * 0.10 = 19.998, rounded to 20.00
+	// Shipping: 0 (free shipping over $100)
+	expectedTotal := decimal.NewFromFloat(219.98)
 	if !order.Total.Equal(expectedTotal) {
 		t.Errorf("Expected total %s, got %s", expectedTotal, order.Total)
 	}
@@ -243,7 +290,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 func TestOrderService_GetOrder(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	// Create test order
 	testOrder := &models.Order{
@@ -267,7 +314,7 @@ func TestOrderService_GetOrder(t *testing.T) {
 func TestOrderService_GetOrder_NotFound(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	_, err := service.GetOrder(ctx, "nonexistent")
 	if err == nil {
@@ -275,10 +322,45 @@ func TestOrderService_GetOrder_NotFound(t *testing.T) {
 	}
 }
 
+func TestOrderService_UpdateOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockOrderRepository()
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
+
+	billing := models.Address{Street: "1 Billing Rd", Country: "US"}
+	repo.Create(ctx, &models.Order{
+		ID:             "order1",
+		UserID:         "user1",
+		Status:         models.OrderConfirmed,
+		Total:          decimal.NewFromFloat(100.00),
+		BillingAddress: billing,
+		PaymentStatus:  "completed",
+	})
+
+	notes := "Leave at the back door"
+	shipping := models.Address{Street: "2 New St", Country: "US"}
+	if err := service.UpdateOrder(ctx, "order1", &UpdateOrderRequest{Notes: &notes, ShippingAddress: &shipping}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	order, _ := repo.GetByID(ctx, "order1")
+	if order.Notes != notes || order.ShippingAddress != shipping || order.BillingAddress != billing {
+		t.Errorf("Expected notes and shipping address to change, got %q %+v %+v", order.Notes, order.ShippingAddress, order.BillingAddress)
+	}
+	if !order.Total.Equal(decimal.NewFromFloat(100.00)) || order.Status != models.OrderConfirmed || order.PaymentStatus != "completed" {
+		t.Errorf("Expected totals, status and payment to be kept, got %s %s %s", order.Total, order.Status, order.PaymentStatus)
+	}
+
+	repo.UpdateStatus(ctx, "order1", models.OrderCancelled, "", "system")
+	if err := service.UpdateOrder(ctx, "order1", &UpdateOrderRequest{Notes: &notes}); err == nil || !strings.Contains(err.Error(), "cannot update") {
+		t.Errorf("Expected a cancelled order not to be editable, got %v", err)
+	}
+}
+
 func TestOrderService_UpdateOrderStatus(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	// Create test order
 	testOrder := &models.Order{
@@ -310,7 +392,7 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 func TestOrderService_UpdateOrderStatus_InvalidTransition(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	// Create test order in cancelled status
 	testOrder := &models.Order{
@@ -330,7 +412,7 @@ func TestOrderService_UpdateOrderStatus_InvalidTransition(t *testing.T) {
 func TestOrderService_CancelOrder(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	// Create test order
 	testOrder := &models.Order{
@@ -356,7 +438,7 @@ func TestOrderService_CancelOrder(t *testing.T) {
 func TestOrderService_CancelOrder_AlreadyCancelled(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	// Create test order in cancelled status
 	testOrder := &models.Order{
@@ -377,7 +459,7 @@ func TestOrderService_ValidateOrderItems(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
 	productService := NewMockProductService()
-	service := NewOrderService(repo, productService, nil)
+	service := NewOrderService(repo, productService, nil, nil, nil, nil, nil)
 
 	items := []OrderItemRequest{
 		{
@@ -397,7 +479,7 @@ func TestOrderService_ValidateOrderItems_InvalidProduct(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
 	productService := NewMockProductService()
-	service := NewOrderService(repo, productService, nil)
+	service := NewOrderService(repo, productService, nil, nil, nil, nil, nil)
 
 	items := []OrderItemRequest{
 		{
@@ -416,7 +498,7 @@ func TestOrderService_ValidateOrderItems_InvalidProduct(t *testing.T) {
 func TestOrderService_CalculateOrderTotals(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMockOrderRepository()
-	service := NewOrderService(repo, nil, nil)
+	service := NewOrderService(repo, nil, nil, nil, nil, nil, nil)
 
 	req := &CreateOrderRequest{
 		Items: []OrderItemRequest{
@@ -453,3 +535,170 @@ func TestOrderService_CalculateOrderTotals(t *testing.T) {
 		t.Errorf("Expected total %s, got %s", expectedTotal, totals.Total)
 	}
 }
+
+func newPricingRequest(items ...OrderItemRequest) *CreateOrderRequest {
+	address := models.Address{Street: "123 Test St", City: "Test City", State: "TS", PostalCode: "12345", Country: "US"}
+	return &CreateOrderRequest{
+		UserID:          "user1",
+		Items:           items,
+		ShippingAddress: address,
+		BillingAddress:  address,
+		PaymentMethod:   models.PaymentMethod{Type: "card", Last4: "1234", Brand: "visa"},
+	}
+}
+
+func TestOrderService_CreateOrder_UsesCatalogPrice(t *testing.T) {
+	ctx := context.Background()
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, nil, nil, nil, nil)
+
+	// No client price: the catalog price is charged
+	order, err := service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	item := order.Items[0]
+	if !item.Price.Equal(decimal.NewFromFloat(99.99)) || item.PriceSource != models.PriceSourceProduct {
+		t.Errorf("Expected catalog price 99.99 from product, got %s from %s", item.Price, item.PriceSource)
+	}
+	if !order.Subtotal.Equal(decimal.NewFromFloat(99.99)) {
+		t.Errorf("Expected subtotal 99.99, got %s", order.Subtotal)
+	}
+}
+
+func TestOrderService_CreateOrder_RejectsPriceMismatch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockOrderRepository()
+	service := NewOrderService(repo, NewMockProductService(), nil, nil, nil, nil, nil)
+
+	_, err := service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{
+		ProductID: "prod1",
+		Quantity:  5,
+		Price:     decimal.NewFromFloat(0.01),
+	}))
+	if err == nil || !strings.Contains(err.Error(), "price mismatch") {
+		t.Fatalf("Expected price mismatch error, got %v", err)
+	}
+	if len(repo.orders) != 0 {
+		t.Error("Expected no order to be created")
+	}
+}
+
+func TestOrderService_CreateOrder_UsesVariantPrice(t *testing.T) {
+	ctx := context.Background()
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, nil, nil, nil, nil)
+
+	order, err := service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{
+		ProductID: "prod1",
+		VariantID: "var1",
+		Quantity:  2,
+		Price:     decimal.NewFromFloat(109.99),
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	item := order.Items[0]
+	if !item.Price.Equal(decimal.NewFromFloat(109.99)) || item.PriceSource != models.PriceSourceVariant {
+		t.Errorf("Expected variant price 109.99, got %s from %s", item.Price, item.PriceSource)
+	}
+	if item.SKU != "SKU001-XL" || item.ProductAttributes["size"] != "XL" {
+		t.Errorf("Expected variant SKU and attributes, got %s %v", item.SKU, item.ProductAttributes)
+	}
+	if !order.Subtotal.Equal(decimal.NewFromFloat(219.98)) {
+		t.Errorf("Expected subtotal 219.98, got %s", order.Subtotal)
+	}
+
+	// The product price is not accepted for a variant
+	_, err = service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{
+		ProductID: "prod1",
+		VariantID: "var1",
+		Quantity:  1,
+		Price:     decimal.NewFromFloat(99.99),
+	}))
+	if err == nil || !strings.Contains(err.Error(), "price mismatch") {
+		t.Errorf("Expected price mismatch for variant, got %v", err)
+	}
+
+	// The variant's own stock applies, not the product's
+	_, err = service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{
+		ProductID: "prod1",
+		VariantID: "var1",
+		Quantity:  6,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "insufficient stock for variant var1") {
+		t.Errorf("Expected insufficient variant stock, got %v", err)
+	}
+}
+
+func TestOrderService_CalculateOrderTotals_UsesCatalogPrice(t *testing.T) {
+	ctx := context.Background()
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, nil, nil, nil, nil)
+
+	totals, err := service.CalculateOrderTotals(ctx, newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !totals.Subtotal.Equal(decimal.NewFromFloat(99.99)) {
+		t.Errorf("Expected subtotal from catalog price, got %s", totals.Subtotal)
+	}
+}
+
+func TestOrderService_CreateOrder_Currency(t *testing.T) {
+	ctx := context.Background()
+	productService := NewMockProductService()
+	productService.products["prod1"].Prices = models.PriceList{"EUR": decimal.RequireFromString("92.50")}
+	rates, err := currency.NewRates("USD", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), map[string]decimal.Decimal{
+		"EUR": decimal.RequireFromString("0.92"),
+		"JPY": decimal.RequireFromString("150"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rates: %v", err)
+	}
+	service := NewOrderService(NewMockOrderRepository(), productService, nil, nil, nil, nil, rates)
+
+	// EUR uses the product's list price; shipping is converted from USD
+	req := newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})
+	req.Currency = "eur"
+	order, err := service.CreateOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.Currency != "EUR" || !order.Items[0].Price.Equal(decimal.RequireFromString("92.50")) {
+		t.Errorf("Expected list price 92.50 EUR, got %s %s", order.Items[0].Price, order.Currency)
+	}
+	if !order.Shipping.IsZero() {
+		t.Errorf("Expected free shipping over the converted threshold of 92.00 EUR, got %s", order.Shipping)
+	}
+	if len(order.ExchangeRates) != 1 || order.ExchangeRates[0].From != "USD" || !order.ExchangeRates[0].Rate.Equal(decimal.RequireFromString("0.92")) {
+		t.Errorf("Expected the USD to EUR rate to be snapshotted, got %+v", order.ExchangeRates)
+	}
+
+	// JPY has no list price, so the USD price is converted and rounded to whole yen
+	req = newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})
+	req.Currency = "JPY"
+	order, err = service.CreateOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !order.Items[0].Price.Equal(decimal.NewFromInt(14999)) {
+		t.Errorf("Expected converted price 14999 JPY, got %s", order.Items[0].Price)
+	}
+	if !order.Shipping.Equal(decimal.NewFromInt(1500)) {
+		t.Errorf("Expected shipping 1500 JPY, got %s", order.Shipping)
+	}
+	if !order.Tax.Equal(decimal.NewFromInt(1500)) || !order.Total.Equal(decimal.NewFromInt(17999)) {
+		t.Errorf("Expected whole-yen tax 1500 and total 17999, got %s and %s", order.Tax, order.Total)
+	}
+
+	// Without rates only currencies with list prices can be charged
+	service = NewOrderService(NewMockOrderRepository(), productService, nil, nil, nil, nil, nil)
+	if _, err := service.CreateOrder(ctx, req); err == nil || !strings.Contains(err.Error(), "no exchange rates") {
+		t.Errorf("Expected missing exchange rates error, got %v", err)
+	}
+
+	req.Currency = "XYZ"
+	if _, err := service.CreateOrder(ctx, req); err == nil || !strings.Contains(err.Error(), "unsupported currency") {
+		t.Errorf("Expected unsupported currency error, got %v", err)
+	}
+}
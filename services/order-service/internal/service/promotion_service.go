@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// PromotionEngine works out the discounts a cart or order qualifies for
+type PromotionEngine interface {
+	Apply(ctx context.Context, req *PromotionRequest) (*PromotionResult, error)
+}
+
+// PromotionService manages promotions and prices carts against them
+type PromotionService interface {
+	PromotionEngine
+	CreatePromotion(ctx context.Context, req *PromotionInput) (*models.Promotion, error)
+	UpdatePromotion(ctx context.Context, id string, req *PromotionInput) (*models.Promotion, error)
+	GetPromotion(ctx context.Context, id string) (*models.Promotion, error)
+	ListPromotions(ctx context.Context, limit, offset int) ([]*models.Promotion, error)
+}
+
+// PromotionRequest describes the items being priced and the coupons entered.
+// Prices are in Currency, which defaults to the store currency; promotion
+// amounts are set in the store currency and converted.
+type PromotionRequest struct {
+	UserID      string          `json:"user_id"`
+	CouponCodes []string        `json:"coupon_codes"`
+	Items       []PromotionItem `json:"items"`
+	Shipping    decimal.Decimal `json:"shipping"`
+	Currency    string          `json:"currency"`
+}
+
+// PromotionItem is one line of the cart or order being priced
+type PromotionItem struct {
+	ProductID string          `json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+}
+
+// PromotionResult holds the discounts that apply. LineDiscounts spreads
+// ItemDiscount over the request items, in request order, so tax can be
+// charged on the discounted amounts.
+type PromotionResult struct {
+	Discounts        []models.OrderDiscount `json:"discounts"`
+	ItemDiscount     decimal.Decimal        `json:"item_discount"`
+	ShippingDiscount decimal.Decimal        `json:"shipping_discount"`
+	Discount         decimal.Decimal        `json:"discount"`
+	LineDiscounts    []decimal.Decimal      `json:"line_discounts"`
+	Rejected         []CouponRejection      `json:"rejected,omitempty"`
+}
+
+// CouponRejection explains why a coupon code did not apply
+type CouponRejection struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// PromotionInput creates or replaces a promotion. Active defaults to true.
+type PromotionInput struct {
+	Code         string               `json:"code"`
+	Name         string               `json:"name" validate:"required"`
+	Description  string               `json:"description"`
+	Type         models.PromotionType `json:"type" validate:"required"`
+	Value        decimal.Decimal      `json:"value"`
+	MinSubtotal  decimal.Decimal      `json:"min_subtotal"`
+	BuyQuantity  int                  `json:"buy_quantity"`
+	GetQuantity  int                  `json:"get_quantity"`
+	ProductIDs   []string             `json:"product_ids"`
+	UsageLimit   int                  `json:"usage_limit"`
+	PerUserLimit int                  `json:"per_user_limit"`
+	StartsAt     *time.Time           `json:"starts_at"`
+	EndsAt       *time.Time           `json:"ends_at"`
+	Active       *bool                `json:"active"`
+}
+
+// promotionService implements PromotionService
+type promotionService struct {
+	repo  repository.PromotionRepository
+	rates ExchangeRates
+	now   func() time.Time
+}
+
+// NewPromotionService creates a new promotion service. Without rates,
+// promotions only apply to carts and orders in the store currency.
+func NewPromotionService(repo repository.PromotionRepository, rates ExchangeRates) PromotionService {
+	return &promotionService{repo: repo, rates: rates, now: time.Now}
+}
+
+// CreatePromotion validates and stores a new promotion
+func (s *promotionService) CreatePromotion(ctx context.Context, req *PromotionInput) (*models.Promotion, error) {
+	if err := validatePromotionInput(req); err != nil {
+		return nil, err
+	}
+
+	promotion := models.NewPromotion(req.Code, req.Name, req.Type, req.Value)
+	applyPromotionInput(promotion, req)
+
+	if err := s.repo.Create(ctx, promotion); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Promotion created", map[string]interface{}{
+		"promotion_id": promotion.ID,
+		"code":         promotion.Code,
+		"type":         promotion.Type,
+	})
+
+	return promotion, nil
+}
+
+// UpdatePromotion replaces an existing promotion, keeping its usage count
+func (s *promotionService) UpdatePromotion(ctx context.Context, id string, req *PromotionInput) (*models.Promotion, error) {
+	if err := validatePromotionInput(req); err != nil {
+		return nil, err
+	}
+
+	promotion, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyPromotionInput(promotion, req)
+
+	if err := s.repo.Update(ctx, promotion); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Promotion updated", map[string]interface{}{
+		"promotion_id": promotion.ID,
+		"active":       promotion.Active,
+	})
+
+	return promotion, nil
+}
+
+// GetPromotion retrieves a promotion by ID
+func (s *promotionService) GetPromotion(ctx context.Context, id string) (*models.Promotion, error) {
+	if id == "" {
+		return nil, fmt.Errorf("promotion ID is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListPromotions returns promotions, newest first
+func (s *promotionService) ListPromotions(ctx context.Context, limit, offset int) ([]*models.Promotion, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.List(ctx, limit, offset)
+}
+
+// Apply applies the running automatic promotions and the given coupons.
+// Automatic promotions that do not qualify are skipped; coupons that do not
+// qualify are reported in Rejected.
+func (s *promotionService) Apply(ctx context.Context, req *PromotionRequest) (*PromotionResult, error) {
+	now := s.now()
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	converter := newPriceConverter(money, s.rates)
+	pricing := newPromotionPricing(req, money)
+
+	automatic, err := s.repo.ListAutomatic(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load promotions: %w", err)
+	}
+	for _, promotion := range automatic {
+		if promotion, err = convertPromotion(promotion, converter); err != nil {
+			return nil, err
+		}
+		if reason, err := s.ineligibility(ctx, promotion, req.UserID, pricing, now); err != nil {
+			return nil, err
+		} else if reason == "" {
+			pricing.apply(promotion)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range req.CouponCodes {
+		code = strings.TrimSpace(code)
+		if code == "" || seen[strings.ToUpper(code)] {
+			continue
+		}
+		seen[strings.ToUpper(code)] = true
+
+		promotion, err := s.repo.GetByCode(ctx, code)
+		if err != nil {
+			if !errors.Is(err, repository.ErrPromotionNotFound) {
+				return nil, fmt.Errorf("failed to load coupon %s: %w", code, err)
+			}
+			pricing.reject(code, "coupon does not exist")
+			continue
+		}
+		if promotion, err = convertPromotion(promotion, converter); err != nil {
+			return nil, err
+		}
+
+		reason, err := s.ineligibility(ctx, promotion, req.UserID, pricing, now)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" && !pricing.apply(promotion) {
+			reason = "coupon does not apply to this order"
+		}
+		if reason != "" {
+			pricing.reject(code, reason)
+		}
+	}
+
+	return pricing.result(), nil
+}
+
+// convertPromotion returns a copy of the promotion with its amounts in the
+// converter's currency
+func convertPromotion(promotion *models.Promotion, converter *priceConverter) (*models.Promotion, error) {
+	if converter.currency.Code == currency.DefaultCode {
+		return promotion, nil
+	}
+
+	converted := *promotion
+	var err error
+	if converted.MinSubtotal, err = converter.convert(promotion.MinSubtotal, currency.DefaultCode); err != nil {
+		return nil, fmt.Errorf("failed to convert promotion %s: %w", promotion.ID, err)
+	}
+	if promotion.Type == models.PromotionFixedAmount {
+		if converted.Value, err = converter.convert(promotion.Value, currency.DefaultCode); err != nil {
+			return nil, fmt.Errorf("failed to convert promotion %s: %w", promotion.ID, err)
+		}
+	}
+	return &converted, nil
+}
+
+// ineligibility returns why the promotion cannot be used, or "" if it can
+func (s *promotionService) ineligibility(ctx context.Context, promotion *models.Promotion, userID string, pricing *promotionPricing, now time.Time) (string, error) {
+	switch {
+	case !promotion.Active:
+		return "coupon is not active", nil
+	case promotion.StartsAt != nil && now.Before(*promotion.StartsAt):
+		return "coupon is not valid yet", nil
+	case !promotion.InWindow(now):
+		return "coupon has expired", nil
+	case promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit:
+		return "coupon has reached its usage limit", nil
+	case pricing.subtotal.LessThan(promotion.MinSubtotal):
+		return fmt.Sprintf("coupon requires a minimum spend of %s", pricing.currency.Format(promotion.MinSubtotal)), nil
+	}
+
+	if promotion.PerUserLimit > 0 {
+		if userID == "" {
+			return "coupon requires a signed-in customer", nil
+		}
+		used, err := s.repo.CountRedemptions(ctx, promotion.ID, userID)
+		if err != nil {
+			return "", err
+		}
+		if used >= promotion.PerUserLimit {
+			return "coupon has already been used the maximum number of times", nil
+		}
+	}
+
+	return "", nil
+}
+
+// promotionPricing accumulates discounts so later promotions only discount
+// what earlier ones left
+type promotionPricing struct {
+	currency  currency.Currency
+	items     []PromotionItem
+	remaining []decimal.Decimal
+	discounts []decimal.Decimal
+	subtotal  decimal.Decimal
+	shipping  decimal.Decimal
+	applied   PromotionResult
+}
+
+func newPromotionPricing(req *PromotionRequest, money currency.Currency) *promotionPricing {
+	p := &promotionPricing{
+		currency:  money,
+		items:     req.Items,
+		remaining: make([]decimal.Decimal, len(req.Items)),
+		discounts: make([]decimal.Decimal, len(req.Items)),
+		shipping:  req.Shipping,
+		applied:   PromotionResult{Discounts: []models.OrderDiscount{}},
+	}
+	for i, item := range req.Items {
+		p.remaining[i] = item.UnitPrice.Mul(decimal.NewFromInt(int64(item.Quantity)))
+		p.subtotal = p.subtotal.Add(p.remaining[i])
+	}
+	return p
+}
+
+// apply adds the promotion's discount, reporting whether it discounted anything
+func (p *promotionPricing) apply(promotion *models.Promotion) bool {
+	discount := models.OrderDiscount{
+		ID:          uuid.New().String(),
+		PromotionID: promotion.ID,
+		Type:        models.DiscountPromotion,
+		Code:        promotion.Code,
+		Name:        promotion.Name,
+		AppliedTo:   models.DiscountOnOrder,
+		CreatedAt:   time.Now(),
+	}
+	if promotion.IsCoupon() {
+		discount.Type = models.DiscountCoupon
+	}
+	if len(promotion.ProductIDs) > 0 {
+		discount.AppliedTo = models.DiscountOnItem
+	}
+
+	var lineDiscounts []decimal.Decimal
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		discount.Percentage = promotion.Value
+		lineDiscounts = p.percentageOff(promotion)
+	case models.PromotionFixedAmount:
+		lineDiscounts = p.amountOff(promotion)
+	case models.PromotionBuyXGetY:
+		discount.AppliedTo = models.DiscountOnItem
+		lineDiscounts = p.freeUnits(promotion)
+	case models.PromotionFreeShipping:
+		discount.AppliedTo = models.DiscountOnShipping
+		// Shipping that is already free still accepts the coupon
+		discount.Amount = p.shipping.Sub(p.applied.ShippingDiscount)
+		if !discount.Amount.IsPositive() {
+			return true
+		}
+		p.applied.ShippingDiscount = p.applied.ShippingDiscount.Add(discount.Amount)
+		p.applied.Discounts = append(p.applied.Discounts, discount)
+		return true
+	default:
+		return false
+	}
+
+	for i, amount := range lineDiscounts {
+		discount.Amount = discount.Amount.Add(amount)
+		p.remaining[i] = p.remaining[i].Sub(amount)
+		p.discounts[i] = p.discounts[i].Add(amount)
+	}
+	if !discount.Amount.IsPositive() {
+		return false
+	}
+	p.applied.ItemDiscount = p.applied.ItemDiscount.Add(discount.Amount)
+	p.applied.Discounts = append(p.applied.Discounts, discount)
+	return true
+}
+
+func (p *promotionPricing) percentageOff(promotion *models.Promotion) []decimal.Decimal {
+	rate := promotion.Value.Div(decimal.NewFromInt(100))
+	amounts := make([]decimal.Decimal, len(p.items))
+	for i, item := range p.items {
+		if promotion.AppliesToProduct(item.ProductID) {
+			amounts[i] = decimal.Min(p.currency.Round(p.remaining[i].Mul(rate)), p.remaining[i])
+		}
+	}
+	return amounts
+}
+
+// amountOff spreads a fixed discount over the eligible lines in proportion
+// to what is left to pay on each
+func (p *promotionPricing) amountOff(promotion *models.Promotion) []decimal.Decimal {
+	amounts := make([]decimal.Decimal, len(p.items))
+	var eligible []int
+	eligibleTotal := decimal.Zero
+	for i, item := range p.items {
+		if promotion.AppliesToProduct(item.ProductID) && p.remaining[i].IsPositive() {
+			eligible = append(eligible, i)
+			eligibleTotal = eligibleTotal.Add(p.remaining[i])
+		}
+	}
+	if len(eligible) == 0 {
+		return amounts
+	}
+
+	total := decimal.Min(promotion.Value, eligibleTotal)
+	allocated := decimal.Zero
+	for n, i := range eligible {
+		if n == len(eligible)-1 {
+			amounts[i] = total.Sub(allocated)
+			break
+		}
+		amounts[i] = p.currency.Round(total.Mul(p.remaining[i]).Div(eligibleTotal))
+		allocated = allocated.Add(amounts[i])
+	}
+	return amounts
+}
+
+// freeUnits makes GetQuantity units free for every BuyQuantity+GetQuantity
+// eligible units, always giving away the cheapest ones
+func (p *promotionPricing) freeUnits(promotion *models.Promotion) []decimal.Decimal {
+	amounts := make([]decimal.Decimal, len(p.items))
+	if promotion.BuyQuantity <= 0 || promotion.GetQuantity <= 0 {
+		return amounts
+	}
+
+	type unit struct {
+		line  int
+		price decimal.Decimal
+	}
+	var units []unit
+	for i, item := range p.items {
+		if promotion.AppliesToProduct(item.ProductID) {
+			for n := 0; n < item.Quantity; n++ {
+				units = append(units, unit{line: i, price: item.UnitPrice})
+			}
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price.LessThan(units[b].price) })
+
+	free := len(units) / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+	for _, u := range units[:free] {
+		amounts[u.line] = amounts[u.line].Add(u.price)
+	}
+	for i := range amounts {
+		amounts[i] = decimal.Min(amounts[i], p.remaining[i])
+	}
+	return amounts
+}
+
+func (p *promotionPricing) reject(code, reason string) {
+	p.applied.Rejected = append(p.applied.Rejected, CouponRejection{Code: code, Reason: reason})
+}
+
+func (p *promotionPricing) result() *PromotionResult {
+	result := p.applied
+	result.LineDiscounts = p.discounts
+	result.Discount = result.ItemDiscount.Add(result.ShippingDiscount)
+	return &result
+}
+
+// noPromotions is the engine used when promotions are not configured
+type noPromotions struct{}
+
+func (noPromotions) Apply(ctx context.Context, req *PromotionRequest) (*PromotionResult, error) {
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	result := newPromotionPricing(&PromotionRequest{Items: req.Items, Shipping: req.Shipping}, money)
+	for _, code := range req.CouponCodes {
+		result.reject(code, "coupons are not available")
+	}
+	return result.result(), nil
+}
+
+func validatePromotionInput(req *PromotionInput) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return fmt.Errorf("invalid promotion: %w", err)
+	}
+
+	switch req.Type {
+	case models.PromotionPercentage:
+		if !req.Value.IsPositive() || req.Value.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("invalid promotion: percentage must be between 0 and 100")
+		}
+	case models.PromotionFixedAmount:
+		if !req.Value.IsPositive() {
+			return fmt.Errorf("invalid promotion: amount must be positive")
+		}
+	case models.PromotionBuyXGetY:
+		if req.BuyQuantity <= 0 || req.GetQuantity <= 0 {
+			return fmt.Errorf("invalid promotion: buy and get quantities must be positive")
+		}
+	case models.PromotionFreeShipping:
+	default:
+		return fmt.Errorf("invalid promotion: unknown type %q", req.Type)
+	}
+
+	if req.MinSubtotal.IsNegative() || req.UsageLimit < 0 || req.PerUserLimit < 0 {
+		return fmt.Errorf("invalid promotion: limits cannot be negative")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return fmt.Errorf("invalid promotion: ends_at must be after starts_at")
+	}
+	return nil
+}
+
+func applyPromotionInput(promotion *models.Promotion, req *PromotionInput) {
+	promotion.Code = strings.TrimSpace(req.Code)
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.MinSubtotal = req.MinSubtotal
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.ProductIDs = req.ProductIDs
+	promotion.UsageLimit = req.UsageLimit
+	promotion.PerUserLimit = req.PerUserLimit
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	promotion.Active = req.Active == nil || *req.Active
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+)
+
+// MockPromotionRepository implements PromotionRepository for testing
+type MockPromotionRepository struct {
+	promotions  map[string]*models.Promotion
+	redemptions map[string]int // promotion ID + user ID
+}
+
+func NewMockPromotionRepository(promotions ...*models.Promotion) *MockPromotionRepository {
+	m := &MockPromotionRepository{
+		promotions:  make(map[string]*models.Promotion),
+		redemptions: make(map[string]int),
+	}
+	for _, promotion := range promotions {
+		m.promotions[promotion.ID] = promotion
+	}
+	return m
+}
+
+func (m *MockPromotionRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	m.promotions[promotion.ID] = promotion
+	return nil
+}
+
+func (m *MockPromotionRepository) Update(ctx context.Context, promotion *models.Promotion) error {
+	if _, exists := m.promotions[promotion.ID]; !exists {
+		return repository.ErrPromotionNotFound
+	}
+	m.promotions[promotion.ID] = promotion
+	return nil
+}
+
+func (m *MockPromotionRepository) GetByID(ctx context.Context, id string) (*models.Promotion, error) {
+	promotion, exists := m.promotions[id]
+	if !exists {
+		return nil, repository.ErrPromotionNotFound
+	}
+	return promotion, nil
+}
+
+func (m *MockPromotionRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	for _, promotion := range m.promotions {
+		if promotion.IsCoupon() && strings.EqualFold(promotion.Code, code) {
+			return promotion, nil
+		}
+	}
+	return nil, repository.ErrPromotionNotFound
+}
+
+func (m *MockPromotionRepository) List(ctx context.Context, limit, offset int) ([]*models.Promotion, error) {
+	var promotions []*models.Promotion
+	for _, promotion := range m.promotions {
+		promotions = append(promotions, promotion)
+	}
+	return promotions, nil
+}
+
+func (m *MockPromotionRepository) ListAutomatic(ctx context.Context, at time.Time) ([]*models.Promotion, error) {
+	var promotions []*models.Promotion
+	for _, promotion := range m.promotions {
+		if !promotion.IsCoupon() && promotion.Active && promotion.InWindow(at) {
+			promotions = append(promotions, promotion)
+		}
+	}
+	return promotions, nil
+}
+
+func (m *MockPromotionRepository) CountRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	return m.redemptions[promotionID+userID], nil
+}
+
+var promotionTestTime = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestPromotionService(promotions ...*models.Promotion) (*promotionService, *MockPromotionRepository) {
+	repo := NewMockPromotionRepository(promotions...)
+	return &promotionService{repo: repo, now: func() time.Time { return promotionTestTime }}, repo
+}
+
+func coupon(code string, promotionType models.PromotionType, value float64) *models.Promotion {
+	return models.NewPromotion(code, code, promotionType, decimal.NewFromFloat(value))
+}
+
+func promotionRequest(coupons ...string) *PromotionRequest {
+	return &PromotionRequest{
+		UserID:      "user1",
+		CouponCodes: coupons,
+		Items: []PromotionItem{
+			{ProductID: "shirt", Quantity: 2, UnitPrice: decimal.NewFromFloat(50)},
+			{ProductID: "socks", Quantity: 1, UnitPrice: decimal.NewFromFloat(20)},
+		},
+		Shipping: decimal.NewFromFloat(10),
+	}
+}
+
+func expectDecimals(t *testing.T, label string, got []decimal.Decimal, want ...float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected %v, got %v", label, want, got)
+	}
+	for i := range want {
+		if !got[i].Equal(decimal.NewFromFloat(want[i])) {
+			t.Errorf("%s: expected %v, got %v", label, want, got)
+			return
+		}
+	}
+}
+
+func TestPromotionService_Apply_PercentageCoupon(t *testing.T) {
+	service, _ := newTestPromotionService(coupon("SAVE10", models.PromotionPercentage, 10))
+
+	result, err := service.Apply(context.Background(), promotionRequest("save10"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result.Rejected) != 0 || len(result.Discounts) != 1 {
+		t.Fatalf("Expected one applied discount, got %+v rejected %+v", result.Discounts, result.Rejected)
+	}
+	discount := result.Discounts[0]
+	if discount.Type != models.DiscountCoupon || discount.AppliedTo != models.DiscountOnOrder ||
+		!discount.Percentage.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Unexpected discount %+v", discount)
+	}
+	if !result.Discount.Equal(decimal.NewFromFloat(12)) || !result.ShippingDiscount.IsZero() {
+		t.Errorf("Expected 12.00 off the items only, got %s (%s on shipping)", result.Discount, result.ShippingDiscount)
+	}
+	expectDecimals(t, "line discounts", result.LineDiscounts, 10, 2)
+}
+
+func TestPromotionService_Apply_FixedAmountOnProducts(t *testing.T) {
+	sale := coupon("SOCKS5", models.PromotionFixedAmount, 25)
+	sale.ProductIDs = []string{"socks"}
+	service, _ := newTestPromotionService(sale)
+
+	result, err := service.Apply(context.Background(), promotionRequest("SOCKS5"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The discount cannot exceed the eligible line
+	if !result.Discount.Equal(decimal.NewFromFloat(20)) || result.Discounts[0].AppliedTo != models.DiscountOnItem {
+		t.Errorf("Expected 20.00 off the socks, got %s on %s", result.Discount, result.Discounts[0].AppliedTo)
+	}
+	expectDecimals(t, "line discounts", result.LineDiscounts, 0, 20)
+}
+
+func TestPromotionService_Apply_FreeShippingAndBuyXGetY(t *testing.T) {
+	bogo := coupon("", models.PromotionBuyXGetY, 0)
+	bogo.BuyQuantity = 2
+	bogo.GetQuantity = 1
+	service, _ := newTestPromotionService(bogo, coupon("SHIPFREE", models.PromotionFreeShipping, 0))
+
+	req := promotionRequest("SHIPFREE")
+	req.Items[0].Quantity = 3
+	result, err := service.Apply(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Four eligible units make the cheapest one free
+	if !result.ItemDiscount.Equal(decimal.NewFromFloat(20)) || !result.ShippingDiscount.Equal(decimal.NewFromFloat(10)) {
+		t.Errorf("Expected 20.00 off items and 10.00 off shipping, got %s and %s", result.ItemDiscount, result.ShippingDiscount)
+	}
+	if len(result.Discounts) != 2 || result.Discounts[0].Type != models.DiscountPromotion {
+		t.Errorf("Expected the automatic promotion and the coupon, got %+v", result.Discounts)
+	}
+	expectDecimals(t, "line discounts", result.LineDiscounts, 0, 20)
+}
+
+func TestPromotionService_Apply_RejectsIneligibleCoupons(t *testing.T) {
+	past := promotionTestTime.Add(-time.Hour)
+	future := promotionTestTime.Add(time.Hour)
+
+	minSpend := coupon("BIGSPEND", models.PromotionPercentage, 10)
+	minSpend.MinSubtotal = decimal.NewFromFloat(500)
+	expired := coupon("EXPIRED", models.PromotionPercentage, 10)
+	expired.EndsAt = &past
+	early := coupon("EARLY", models.PromotionPercentage, 10)
+	early.StartsAt = &future
+	usedUp := coupon("USEDUP", models.PromotionPercentage, 10)
+	usedUp.UsageLimit = 100
+	usedUp.UsageCount = 100
+	oncePerUser := coupon("ONCE", models.PromotionPercentage, 10)
+	oncePerUser.PerUserLimit = 1
+
+	service, repo := newTestPromotionService(minSpend, expired, early, usedUp, oncePerUser)
+	repo.redemptions[oncePerUser.ID+"user1"] = 1
+
+	codes := []string{"BIGSPEND", "EXPIRED", "EARLY", "USEDUP", "ONCE", "NOSUCH"}
+	result, err := service.Apply(context.Background(), promotionRequest(codes...))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !result.Discount.IsZero() || len(result.Discounts) != 0 {
+		t.Errorf("Expected no discount, got %s from %+v", result.Discount, result.Discounts)
+	}
+	reasons := []string{"minimum spend", "expired", "not valid yet", "usage limit", "maximum number of times", "does not exist"}
+	if len(result.Rejected) != len(codes) {
+		t.Fatalf("Expected every coupon to be rejected, got %+v", result.Rejected)
+	}
+	for i, rejection := range result.Rejected {
+		if rejection.Code != codes[i] || !strings.Contains(rejection.Reason, reasons[i]) {
+			t.Errorf("Expected %s to be rejected for %q, got %+v", codes[i], reasons[i], rejection)
+		}
+	}
+}
+
+func TestPromotionService_Apply_SkipsIneligibleAutomaticPromotions(t *testing.T) {
+	automatic := coupon("", models.PromotionFixedAmount, 15)
+	automatic.MinSubtotal = decimal.NewFromFloat(200)
+	service, _ := newTestPromotionService(automatic)
+
+	result, err := service.Apply(context.Background(), promotionRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Discounts) != 0 || len(result.Rejected) != 0 {
+		t.Errorf("Expected the promotion to be skipped silently, got %+v rejected %+v", result.Discounts, result.Rejected)
+	}
+}
+
+func TestPromotionService_CreatePromotion_Validates(t *testing.T) {
+	service, _ := newTestPromotionService()
+	ctx := context.Background()
+
+	invalid := []*PromotionInput{
+		{Name: "Too much", Type: models.PromotionPercentage, Value: decimal.NewFromFloat(150)},
+		{Name: "Nothing off", Type: models.PromotionFixedAmount},
+		{Name: "No quantities", Type: models.PromotionBuyXGetY},
+		{Name: "Unknown", Type: "mystery"},
+	}
+	for _, req := range invalid {
+		if _, err := service.CreatePromotion(ctx, req); err == nil || !strings.Contains(err.Error(), "invalid promotion") {
+			t.Errorf("Expected %q to be invalid, got %v", req.Name, err)
+		}
+	}
+
+	promotion, err := service.CreatePromotion(ctx, &PromotionInput{
+		Code: " WELCOME ", Name: "Welcome", Type: models.PromotionPercentage, Value: decimal.NewFromFloat(15),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if promotion.Code != "WELCOME" || !promotion.Active {
+		t.Errorf("Expected an active WELCOME coupon, got %+v", promotion)
+	}
+}
+
+func TestOrderService_CreateOrder_AppliesCoupon(t *testing.T) {
+	ctx := context.Background()
+	promotions, _ := newTestPromotionService(coupon("SAVE10", models.PromotionPercentage, 10))
+	calculator := NewRuleTaxCalculator(StaticTaxRules{taxRule("TS state tax", "US", "TS", "", 0.05)})
+	repo := NewMockOrderRepository()
+	service := NewOrderService(repo, NewMockProductService(), nil, calculator, promotions, nil, nil)
+
+	req := newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})
+	req.CouponCodes = []string{"SAVE10"}
+	order, err := service.CreateOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 10.00 off 99.99, with tax charged on the discounted 89.99
+	if !order.Discount.Equal(decimal.NewFromFloat(10)) || !order.Tax.Equal(decimal.NewFromFloat(4.5)) {
+		t.Errorf("Expected discount 10.00 and tax 4.50, got %s and %s", order.Discount, order.Tax)
+	}
+	if !order.Total.Equal(decimal.NewFromFloat(104.49)) {
+		t.Errorf("Expected total 104.49, got %s", order.Total)
+	}
+	if len(order.Discounts) != 1 || order.Discounts[0].Code != "SAVE10" {
+		t.Errorf("Expected the coupon to be recorded on the order, got %+v", order.Discounts)
+	}
+
+	req.CouponCodes = []string{"BOGUS"}
+	_, err = service.CreateOrder(ctx, req)
+	if err == nil || !strings.Contains(err.Error(), "invalid coupon BOGUS") {
+		t.Errorf("Expected invalid coupon error, got %v", err)
+	}
+	if len(repo.orders) != 1 {
+		t.Errorf("Expected only the first order to be created, got %d", len(repo.orders))
+	}
+}
+
+func TestOrderService_CreateOrder_RejectsCouponsWithoutEngine(t *testing.T) {
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, nil, nil, nil, nil)
+
+	req := newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})
+	req.CouponCodes = []string{"SAVE10"}
+	if _, err := service.CreateOrder(context.Background(), req); err == nil || !strings.Contains(err.Error(), "invalid coupon") {
+		t.Errorf("Expected invalid coupon error, got %v", err)
+	}
+}
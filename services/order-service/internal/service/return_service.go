@@ -0,0 +1,699 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// ReturnService manages returns (RMAs): a customer requests a return of
+// delivered items, an admin approves it with a return label, and once the
+// items arrive they are inspected, restocked and refunded
+type ReturnService interface {
+	RequestReturn(ctx context.Context, orderID string, req *CreateReturnRequest) (*models.Return, error)
+	ApproveReturn(ctx context.Context, id string, req *ApproveReturnRequest) (*models.Return, error)
+	RejectReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error)
+	CancelReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error)
+	ReceiveReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error)
+	InspectReturn(ctx context.Context, id string, req *InspectReturnRequest) (*models.Return, error)
+	RefundReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error)
+	GetReturn(ctx context.Context, id string) (*models.Return, error)
+	ListReturns(ctx context.Context, orderID string) ([]models.Return, error)
+}
+
+// RefundService interface for issuing refunds through payment-service
+type RefundService interface {
+	CreateRefund(ctx context.Context, req *RefundRequest) (*models.Refund, error)
+}
+
+// RefundRequest represents a partial or full refund of a payment.
+// IdempotencyKey is sent as the request's Idempotency-Key header so a retried
+// refund is not issued twice.
+type RefundRequest struct {
+	IdempotencyKey string                 `json:"-"`
+	PaymentID      string                 `json:"payment_id"`
+	Amount         decimal.Decimal        `json:"amount"`
+	Reason         string                 `json:"reason"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// RestockService interface for putting returned units back into stock
+type RestockService interface {
+	RestockItems(ctx context.Context, items []models.OrderItem, reason string) error
+}
+
+// CreateReturnRequest represents a customer's request to return order items
+type CreateReturnRequest struct {
+	UserID string              `json:"user_id" validate:"required"`
+	Reason string              `json:"reason" validate:"required"`
+	Notes  string              `json:"notes"`
+	Items  []ReturnItemRequest `json:"items" validate:"required"`
+}
+
+// ReturnItemRequest is a quantity of an order item to return
+type ReturnItemRequest struct {
+	OrderItemID string `json:"order_item_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,min=1"`
+	Reason      string `json:"reason"`
+}
+
+// ApproveReturnRequest approves a return and issues its shipping label.
+// ShippingMethodID overrides the configured return shipping method.
+type ApproveReturnRequest struct {
+	ApprovedBy       string `json:"approved_by"`
+	ShippingMethodID string `json:"shipping_method_id"`
+	Notes            string `json:"notes"`
+}
+
+// ReturnActionRequest records who took a step on a return and why
+type ReturnActionRequest struct {
+	ChangedBy string `json:"changed_by"`
+	Reason    string `json:"reason"`
+}
+
+// InspectReturnRequest records the outcome of inspecting returned items.
+// Items left out are accepted and restocked in full.
+type InspectReturnRequest struct {
+	InspectedBy string                 `json:"inspected_by"`
+	Items       []ReturnInspectionItem `json:"items"`
+}
+
+// ReturnInspectionItem is the inspection result for one return item.
+// Accepted units are refunded; restocked units go back into stock and must
+// be among the accepted ones.
+type ReturnInspectionItem struct {
+	ReturnItemID     string `json:"return_item_id"`
+	AcceptedQuantity int    `json:"accepted_quantity"`
+	RestockQuantity  int    `json:"restock_quantity"`
+	Condition        string `json:"condition"`
+}
+
+// ReturnConfig holds return settings
+type ReturnConfig struct {
+	// WarehouseAddress is where returned items are shipped to
+	WarehouseAddress models.Address
+	// ShippingMethodID is used for return labels unless approval names one
+	ShippingMethodID string
+	// Window is how long after delivery a return can be requested; zero disables the limit
+	Window time.Duration
+	// DefaultItemWeightKg is used for products without a weight attribute
+	DefaultItemWeightKg decimal.Decimal
+}
+
+// DefaultReturnConfig returns the default return configuration
+func DefaultReturnConfig() ReturnConfig {
+	checkout := DefaultCheckoutConfig()
+	return ReturnConfig{
+		WarehouseAddress:    checkout.WarehouseAddress,
+		Window:              30 * 24 * time.Hour,
+		DefaultItemWeightKg: checkout.DefaultItemWeightKg,
+	}
+}
+
+// returnTransitions lists the statuses each return status can move to
+var returnTransitions = map[models.ReturnStatus][]models.ReturnStatus{
+	models.ReturnRequested: {models.ReturnApproved, models.ReturnRejected, models.ReturnCancelled},
+	models.ReturnApproved:  {models.ReturnReceived, models.ReturnCancelled},
+	models.ReturnReceived:  {models.ReturnInspected},
+	models.ReturnInspected: {models.ReturnRefunded},
+	models.ReturnRejected:  {},
+	models.ReturnRefunded:  {},
+	models.ReturnCancelled: {},
+}
+
+// returnService implements ReturnService
+type returnService struct {
+	repo            repository.ReturnRepository
+	orderRepo       repository.OrderRepository
+	shippingService ShippingService
+	restockService  RestockService
+	refundService   RefundService
+	config          ReturnConfig
+	now             func() time.Time
+}
+
+// NewReturnService creates a new return service
+func NewReturnService(
+	repo repository.ReturnRepository,
+	orderRepo repository.OrderRepository,
+	shippingService ShippingService,
+	restockService RestockService,
+	refundService RefundService,
+	config ReturnConfig,
+) ReturnService {
+	return &returnService{
+		repo:            repo,
+		orderRepo:       orderRepo,
+		shippingService: shippingService,
+		restockService:  restockService,
+		refundService:   refundService,
+		config:          config,
+		now:             time.Now,
+	}
+}
+
+// RequestReturn creates a return for shipped items of an order
+func (s *returnService) RequestReturn(ctx context.Context, orderID string, req *CreateReturnRequest) (*models.Return, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid return: %w", err)
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("invalid return: at least one item is required")
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != req.UserID {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	switch order.Status {
+	case models.OrderPartiallyShipped, models.OrderShipped, models.OrderDelivered:
+	default:
+		return nil, fmt.Errorf("invalid return: order is %s", order.Status)
+	}
+	if s.config.Window > 0 && order.DeliveredAt != nil && s.now().After(order.DeliveredAt.Add(s.config.Window)) {
+		return nil, fmt.Errorf("invalid return: the return window for this order has closed")
+	}
+
+	existing, err := s.repo.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	returnable := returnableQuantities(order, existing)
+
+	ret := models.NewReturn(order.ID, order.UserID, order.Currency)
+	ret.Reason = req.Reason
+	ret.Notes = req.Notes
+
+	requested := make(map[string]int)
+	for _, item := range req.Items {
+		if err := utils.ValidateStruct(&item); err != nil {
+			return nil, fmt.Errorf("invalid return: %w", err)
+		}
+		left, exists := returnable[item.OrderItemID]
+		if !exists {
+			return nil, fmt.Errorf("invalid return: order item %s is not part of the order", item.OrderItemID)
+		}
+		requested[item.OrderItemID] += item.Quantity
+		if requested[item.OrderItemID] > left {
+			return nil, fmt.Errorf("invalid return: only %d of order item %s can still be returned", left, item.OrderItemID)
+		}
+	}
+	for _, orderItem := range order.Items {
+		quantity := requested[orderItem.ID]
+		if quantity == 0 {
+			continue
+		}
+		reason := req.Reason
+		for _, item := range req.Items {
+			if item.OrderItemID == orderItem.ID && item.Reason != "" {
+				reason = item.Reason
+			}
+		}
+		ret.Items = append(ret.Items, models.ReturnItem{
+			OrderItemID:  orderItem.ID,
+			ProductID:    orderItem.ProductID,
+			VariantID:    orderItem.VariantID,
+			Quantity:     quantity,
+			Reason:       reason,
+			RefundAmount: decimal.Zero,
+		})
+	}
+
+	if err := s.repo.Create(ctx, ret, "return requested: "+req.Reason, req.UserID); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Return requested", map[string]interface{}{
+		"order_id":   order.ID,
+		"return_id":  ret.ID,
+		"rma_number": ret.RMANumber,
+	})
+
+	return ret, nil
+}
+
+// ApproveReturn approves a requested return and issues a return shipping label
+func (s *returnService) ApproveReturn(ctx context.Context, id string, req *ApproveReturnRequest) (*models.Return, error) {
+	ret, err := s.loadForTransition(ctx, id, models.ReturnApproved)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderRepo.GetByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	methodID := req.ShippingMethodID
+	if methodID == "" {
+		methodID = s.config.ShippingMethodID
+	}
+	if methodID == "" {
+		return nil, fmt.Errorf("invalid return approval: shipping method is required for the return label")
+	}
+
+	items := returnedOrderItems(order, ret, func(item models.ReturnItem) int { return item.Quantity })
+	declared := decimal.Zero
+	for _, item := range items {
+		declared = declared.Add(item.Price.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+
+	shipment, err := s.shippingService.CreateShipment(ctx, &ShipmentRequest{
+		OrderID:          order.ID,
+		UserID:           order.UserID,
+		ShippingMethodID: methodID,
+		FromAddress:      order.ShippingAddress,
+		ToAddress:        s.config.WarehouseAddress,
+		WeightKg:         itemsWeight(items, s.config.DefaultItemWeightKg),
+		DeclaredValue:    declared,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create return label: %w", err)
+	}
+
+	now := s.now()
+	from := ret.Status
+	ret.Status = models.ReturnApproved
+	ret.ApprovedAt = &now
+	ret.ShipmentID = shipment.ID
+	ret.TrackingNumber = shipment.TrackingNumber
+	ret.LabelURL = shipment.LabelURL
+	if req.Notes != "" {
+		ret.Notes = req.Notes
+	}
+
+	if err := s.repo.Update(ctx, ret, from, "return approved: label "+shipment.TrackingNumber+" issued", actor(req.ApprovedBy)); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Return approved", map[string]interface{}{
+		"return_id":       ret.ID,
+		"shipment_id":     shipment.ID,
+		"tracking_number": shipment.TrackingNumber,
+	})
+
+	return ret, nil
+}
+
+// RejectReturn declines a requested return
+func (s *returnService) RejectReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error) {
+	if req.Reason == "" {
+		return nil, fmt.Errorf("invalid return rejection: reason is required")
+	}
+
+	ret, err := s.loadForTransition(ctx, id, models.ReturnRejected)
+	if err != nil {
+		return nil, err
+	}
+
+	from := ret.Status
+	ret.Status = models.ReturnRejected
+	ret.RejectionReason = req.Reason
+	if err := s.repo.Update(ctx, ret, from, "return rejected: "+req.Reason, actor(req.ChangedBy)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CancelReturn withdraws a return before its items have been received
+func (s *returnService) CancelReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error) {
+	ret, err := s.loadForTransition(ctx, id, models.ReturnCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "return cancelled"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+
+	from := ret.Status
+	ret.Status = models.ReturnCancelled
+	if err := s.repo.Update(ctx, ret, from, reason, actor(req.ChangedBy)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ReceiveReturn records that the returned package arrived at the warehouse
+func (s *returnService) ReceiveReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error) {
+	ret, err := s.loadForTransition(ctx, id, models.ReturnReceived)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	from := ret.Status
+	ret.Status = models.ReturnReceived
+	ret.ReceivedAt = &now
+	if err := s.repo.Update(ctx, ret, from, "return received", actor(req.ChangedBy)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// InspectReturn records which units were accepted, puts restockable units
+// back into stock and refunds the accepted units. The inspection is saved
+// before anything is restocked, so of two concurrent inspections only the
+// one that saved it restocks; if restocking fails the return goes back to
+// received to be inspected again.
+func (s *returnService) InspectReturn(ctx context.Context, id string, req *InspectReturnRequest) (*models.Return, error) {
+	ret, err := s.loadForTransition(ctx, id, models.ReturnInspected)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderRepo.GetByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]ReturnInspectionItem, len(req.Items))
+	for _, result := range req.Items {
+		results[result.ReturnItemID] = result
+	}
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		result, inspected := results[item.ID]
+		if !inspected {
+			result = ReturnInspectionItem{AcceptedQuantity: item.Quantity, RestockQuantity: item.Quantity}
+		}
+		delete(results, item.ID)
+
+		if result.AcceptedQuantity < 0 || result.AcceptedQuantity > item.Quantity {
+			return nil, fmt.Errorf("invalid inspection: accepted quantity for return item %s must be between 0 and %d", item.ID, item.Quantity)
+		}
+		if result.RestockQuantity < 0 || result.RestockQuantity > result.AcceptedQuantity {
+			return nil, fmt.Errorf("invalid inspection: restock quantity for return item %s must be between 0 and %d", item.ID, result.AcceptedQuantity)
+		}
+		item.AcceptedQuantity = result.AcceptedQuantity
+		item.RestockedQuantity = result.RestockQuantity
+		item.Condition = result.Condition
+	}
+	for itemID := range results {
+		return nil, fmt.Errorf("invalid inspection: return item %s is not part of the return", itemID)
+	}
+
+	money, err := resolveCurrency(order.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order currency: %w", err)
+	}
+	previous, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	calculateReturnRefund(money, order, ret, refundedByReturns(previous, ret.ID))
+
+	accepted, total := 0, 0
+	for _, item := range ret.Items {
+		accepted += item.AcceptedQuantity
+		total += item.Quantity
+	}
+
+	now := s.now()
+	from := ret.Status
+	ret.Status = models.ReturnInspected
+	ret.InspectedAt = &now
+	reason := fmt.Sprintf("return inspected: %d of %d units accepted", accepted, total)
+	if err := s.repo.Update(ctx, ret, from, reason, actor(req.InspectedBy)); err != nil {
+		return nil, err
+	}
+
+	restock := returnedOrderItems(order, ret, func(item models.ReturnItem) int { return item.RestockedQuantity })
+	if len(restock) > 0 {
+		if err := s.restockService.RestockItems(ctx, restock, "Return "+ret.RMANumber); err != nil {
+			ret.Status = from
+			ret.InspectedAt = nil
+			if undoErr := s.repo.Update(ctx, ret, models.ReturnInspected, "return inspection undone: restock failed", actor(req.InspectedBy)); undoErr != nil {
+				utils.Logger.Error(ctx, "Failed to undo return inspection", undoErr, map[string]interface{}{
+					"return_id": ret.ID,
+				})
+			}
+			return nil, fmt.Errorf("failed to restock returned items: %w", err)
+		}
+	}
+
+	return s.refund(ctx, ret, order, actor(req.InspectedBy))
+}
+
+// RefundReturn retries the refund of an inspected return
+func (s *returnService) RefundReturn(ctx context.Context, id string, req *ReturnActionRequest) (*models.Return, error) {
+	ret, err := s.loadForTransition(ctx, id, models.ReturnRefunded)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderRepo.GetByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	return s.refund(ctx, ret, order, actor(req.ChangedBy))
+}
+
+// GetReturn retrieves a return by ID
+func (s *returnService) GetReturn(ctx context.Context, id string) (*models.Return, error) {
+	if id == "" {
+		return nil, fmt.Errorf("return ID is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListReturns returns an order's returns
+func (s *returnService) ListReturns(ctx context.Context, orderID string) ([]models.Return, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+// refund issues the payment refund for an inspected return. If the refund
+// fails the return stays inspected so the refund can be retried. The refund
+// carries an idempotency key derived from the return, so payment-service
+// refunds a return once however often it is retried.
+func (s *returnService) refund(ctx context.Context, ret *models.Return, order *models.Order, changedBy string) (*models.Return, error) {
+	money, err := resolveCurrency(ret.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid return currency: %w", err)
+	}
+
+	if ret.RefundAmount.IsPositive() {
+		if order.PaymentReference == "" {
+			return nil, fmt.Errorf("invalid return refund: order %s has no payment to refund", order.ID)
+		}
+
+		refund, err := s.refundService.CreateRefund(ctx, &RefundRequest{
+			IdempotencyKey: "return-refund-" + ret.ID,
+			PaymentID:      order.PaymentReference,
+			Amount:         ret.RefundAmount,
+			Reason:         "Return " + ret.RMANumber + ": " + ret.Reason,
+			Metadata: map[string]interface{}{
+				"order_id":   order.ID,
+				"return_id":  ret.ID,
+				"rma_number": ret.RMANumber,
+			},
+		})
+		if err != nil {
+			utils.Logger.Error(ctx, "Failed to refund return", err, map[string]interface{}{
+				"return_id": ret.ID,
+				"amount":    ret.RefundAmount,
+			})
+			return nil, fmt.Errorf("failed to refund return: %w", err)
+		}
+		ret.RefundID = refund.ID
+	}
+
+	now := s.now()
+	ret.Status = models.ReturnRefunded
+	ret.RefundedAt = &now
+	reason := "return refunded: " + money.Format(ret.RefundAmount)
+	if err := s.repo.Update(ctx, ret, models.ReturnInspected, reason, changedBy); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Return refunded", map[string]interface{}{
+		"order_id":  order.ID,
+		"return_id": ret.ID,
+		"refund_id": ret.RefundID,
+		"amount":    ret.RefundAmount,
+	})
+
+	s.markOrderRefunded(ctx, order, changedBy)
+	return ret, nil
+}
+
+// markOrderRefunded moves a delivered order to refunded once every unit has
+// been returned and refunded
+func (s *returnService) markOrderRefunded(ctx context.Context, order *models.Order, changedBy string) {
+	if order.Status != models.OrderDelivered {
+		return
+	}
+
+	returns, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to load returns for order status", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+		return
+	}
+
+	refunded := make(map[string]int)
+	for _, ret := range returns {
+		if ret.Status != models.ReturnRefunded {
+			continue
+		}
+		for _, item := range ret.Items {
+			refunded[item.OrderItemID] += item.AcceptedQuantity
+		}
+	}
+	for _, item := range order.Items {
+		if refunded[item.ID] < item.Quantity {
+			return
+		}
+	}
+
+	if err := s.orderRepo.UpdateStatus(ctx, order.ID, models.OrderRefunded, "all items returned and refunded", changedBy); err != nil {
+		utils.Logger.Error(ctx, "Failed to mark order refunded", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+		return
+	}
+	order.Status = models.OrderRefunded
+}
+
+// loadForTransition loads a return and checks it can move to status
+func (s *returnService) loadForTransition(ctx context.Context, id string, status models.ReturnStatus) (*models.Return, error) {
+	ret, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, allowed := range returnTransitions[ret.Status] {
+		if allowed == status {
+			return ret, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid status transition: cannot move return from %s to %s", ret.Status, status)
+}
+
+// returnableQuantities returns, per order item, the shipped quantity not yet
+// claimed by an active return. Orders without fulfillments count every unit
+// as shipped.
+func returnableQuantities(order *models.Order, returns []models.Return) map[string]int {
+	returnable := make(map[string]int, len(order.Items))
+	if len(order.Fulfillments) == 0 {
+		for _, item := range order.Items {
+			returnable[item.ID] = item.Quantity
+		}
+	} else {
+		for _, item := range order.Items {
+			returnable[item.ID] = 0
+		}
+		for _, fulfillment := range order.Fulfillments {
+			if !fulfillment.HasShipped() {
+				continue
+			}
+			for _, item := range fulfillment.Items {
+				returnable[item.OrderItemID] += item.Quantity
+			}
+		}
+	}
+
+	for _, ret := range returns {
+		if !ret.IsActive() {
+			continue
+		}
+		for _, item := range ret.Items {
+			returnable[item.OrderItemID] -= item.Quantity
+		}
+	}
+	return returnable
+}
+
+// calculateReturnRefund sets the refund for each return item and the return.
+// Each accepted unit is refunded at what the customer paid for it: its price
+// less its share of order discounts, plus tax not already in the price.
+// Refunds are rounded to the minor units of the order's currency. Shipping is
+// not refunded, and the refunds of an order's returns never add up to more
+// than the order total.
+func calculateReturnRefund(money currency.Currency, order *models.Order, ret *models.Return, alreadyRefunded decimal.Decimal) {
+	discountRate := decimal.Zero
+	if order.Subtotal.IsPositive() {
+		discountRate = order.Discount.Div(order.Subtotal)
+	}
+	exclusiveTaxRate := decimal.NewFromInt(1)
+	if order.Tax.IsPositive() {
+		exclusiveTaxRate = order.Tax.Sub(order.TaxIncluded).Div(order.Tax)
+	}
+
+	total := decimal.Zero
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		item.RefundAmount = decimal.Zero
+
+		orderItem := findOrderItem(order, item.OrderItemID)
+		if orderItem == nil || orderItem.Quantity == 0 || item.AcceptedQuantity == 0 {
+			continue
+		}
+
+		paid := orderItem.Total.Sub(orderItem.Total.Mul(discountRate)).Add(orderItem.Tax.Mul(exclusiveTaxRate))
+		share := decimal.NewFromInt(int64(item.AcceptedQuantity)).Div(decimal.NewFromInt(int64(orderItem.Quantity)))
+		item.RefundAmount = money.Round(paid.Mul(share))
+		total = total.Add(item.RefundAmount)
+	}
+
+	if remaining := order.Total.Sub(alreadyRefunded); total.GreaterThan(remaining) {
+		total = decimal.Max(remaining, decimal.Zero)
+	}
+	ret.RefundAmount = total
+}
+
+// refundedByReturns sums the refunds of an order's other refunded returns
+func refundedByReturns(returns []models.Return, excludeID string) decimal.Decimal {
+	total := decimal.Zero
+	for _, ret := range returns {
+		if ret.ID != excludeID && ret.Status == models.ReturnRefunded {
+			total = total.Add(ret.RefundAmount)
+		}
+	}
+	return total
+}
+
+// returnedOrderItems lists the order items of a return with the quantity
+// picked by quantityOf, skipping items where it is zero
+func returnedOrderItems(order *models.Order, ret *models.Return, quantityOf func(models.ReturnItem) int) []models.OrderItem {
+	var items []models.OrderItem
+	for _, item := range ret.Items {
+		quantity := quantityOf(item)
+		orderItem := findOrderItem(order, item.OrderItemID)
+		if quantity == 0 || orderItem == nil {
+			continue
+		}
+		returned := *orderItem
+		returned.Quantity = quantity
+		items = append(items, returned)
+	}
+	return items
+}
+
+func findOrderItem(order *models.Order, id string) *models.OrderItem {
+	for i := range order.Items {
+		if order.Items[i].ID == id {
+			return &order.Items[i]
+		}
+	}
+	return nil
+}
+
+// actor returns who changed a return, defaulting to the system
+func actor(changedBy string) string {
+	if changedBy == "" {
+		return "system"
+	}
+	return changedBy
+}
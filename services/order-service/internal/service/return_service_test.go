@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockReturnRepository implements ReturnRepository for testing. Like the
+// PostgreSQL repository it writes each step to the order's status history.
+type MockReturnRepository struct {
+	orders  *MockOrderRepository
+	returns map[string]models.Return
+	order   []string
+}
+
+func NewMockReturnRepository(orders *MockOrderRepository) *MockReturnRepository {
+	return &MockReturnRepository{orders: orders, returns: make(map[string]models.Return)}
+}
+
+func (m *MockReturnRepository) Create(ctx context.Context, ret *models.Return, reason, changedBy string) error {
+	order, exists := m.orders.orders[ret.OrderID]
+	if !exists {
+		return &NotFoundError{Resource: "order", ID: ret.OrderID}
+	}
+	count := 0
+	for _, existing := range m.returns {
+		if existing.OrderID == ret.OrderID {
+			count++
+		}
+	}
+	ret.RMANumber = models.ReturnNumber(order.OrderNumber, count+1)
+	for i := range ret.Items {
+		ret.Items[i].ID = fmt.Sprintf("%s-item-%d", ret.ID, i+1)
+		ret.Items[i].ReturnID = ret.ID
+	}
+	m.save(ret)
+	m.order = append(m.order, ret.ID)
+	m.record(order, ret, reason, changedBy)
+	return nil
+}
+
+func (m *MockReturnRepository) Update(ctx context.Context, ret *models.Return, from models.ReturnStatus, reason, changedBy string) error {
+	stored, exists := m.returns[ret.ID]
+	if !exists {
+		return &NotFoundError{Resource: "return", ID: ret.ID}
+	}
+	if stored.Status != from {
+		return repository.ErrReturnChanged
+	}
+	m.save(ret)
+	m.record(m.orders.orders[ret.OrderID], ret, reason, changedBy)
+	return nil
+}
+
+func (m *MockReturnRepository) GetByID(ctx context.Context, id string) (*models.Return, error) {
+	ret, exists := m.returns[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "return", ID: id}
+	}
+	ret.Items = append([]models.ReturnItem(nil), ret.Items...)
+	return &ret, nil
+}
+
+func (m *MockReturnRepository) ListByOrder(ctx context.Context, orderID string) ([]models.Return, error) {
+	returns := []models.Return{}
+	for _, id := range m.order {
+		if ret := m.returns[id]; ret.OrderID == orderID {
+			returns = append(returns, ret)
+		}
+	}
+	return returns, nil
+}
+
+func (m *MockReturnRepository) save(ret *models.Return) {
+	stored := *ret
+	stored.Items = append([]models.ReturnItem(nil), ret.Items...)
+	m.returns[ret.ID] = stored
+}
+
+func (m *MockReturnRepository) record(order *models.Order, ret *models.Return, reason, changedBy string) {
+	m.orders.statusHistory[order.ID] = append(m.orders.statusHistory[order.ID], models.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: string(order.Status),
+		ToStatus:   string(order.Status),
+		Reason:     reason,
+		Notes:      ret.RMANumber,
+		ChangedBy:  changedBy,
+	})
+}
+
+// MockRestockService implements RestockService for testing
+type MockRestockService struct {
+	restocked map[string]int
+	err       error
+	during    func() // called while restocking, to run a concurrent request
+}
+
+func (m *MockRestockService) RestockItems(ctx context.Context, items []models.OrderItem, reason string) error {
+	if m.during != nil {
+		during := m.during
+		m.during = nil
+		during()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	if m.restocked == nil {
+		m.restocked = make(map[string]int)
+	}
+	for _, item := range items {
+		m.restocked[item.ProductID] += item.Quantity
+	}
+	return nil
+}
+
+// MockRefundService implements RefundService for testing. Like
+// payment-service it answers a repeated idempotency key with the refund the
+// key already produced.
+type MockRefundService struct {
+	refunds []*RefundRequest
+	byKey   map[string]*models.Refund
+	err     error
+}
+
+func (m *MockRefundService) CreateRefund(ctx context.Context, req *RefundRequest) (*models.Refund, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if refund, exists := m.byKey[req.IdempotencyKey]; exists && req.IdempotencyKey != "" {
+		return refund, nil
+	}
+	m.refunds = append(m.refunds, req)
+	refund := &models.Refund{ID: fmt.Sprintf("refund-%d", len(m.refunds)), PaymentID: req.PaymentID, Amount: req.Amount}
+	if m.byKey == nil {
+		m.byKey = make(map[string]*models.Refund)
+	}
+	m.byKey[req.IdempotencyKey] = refund
+	return refund, nil
+}
+
+type returnFixture struct {
+	service  ReturnService
+	orders   *MockOrderRepository
+	returns  *MockReturnRepository
+	shipping *MockShippingService
+	restock  *MockRestockService
+	refunds  *MockRefundService
+	order    *models.Order
+}
+
+// newReturnFixture stores a delivered, paid order for user1: two units of
+// prod1 at 50.00 with 8.00 tax, and one unit of prod2 at 20.00 with 1.60 tax
+func newReturnFixture(t *testing.T) *returnFixture {
+	f := &returnFixture{
+		orders:   NewMockOrderRepository(),
+		shipping: &MockShippingService{},
+		restock:  &MockRestockService{},
+		refunds:  &MockRefundService{},
+	}
+	f.returns = NewMockReturnRepository(f.orders)
+
+	config := DefaultReturnConfig()
+	config.ShippingMethodID = "ground"
+	f.service = NewReturnService(f.returns, f.orders, f.shipping, f.restock, f.refunds, config)
+
+	delivered := time.Now().Add(-48 * time.Hour)
+	order := models.NewOrder("user1")
+	order.OrderNumber = "ORD-20260601-0000422"
+	order.Status = models.OrderDelivered
+	order.DeliveredAt = &delivered
+	order.PaymentReference = "pay-1"
+	order.Items = []models.OrderItem{
+		{ID: "item1", OrderID: order.ID, ProductID: "prod1", Price: decimal.NewFromInt(50), Quantity: 2, Total: decimal.NewFromInt(100), Tax: decimal.NewFromInt(8)},
+		{ID: "item2", OrderID: order.ID, ProductID: "prod2", Price: decimal.NewFromInt(20), Quantity: 1, Total: decimal.NewFromInt(20), Tax: decimal.NewFromFloat(1.6)},
+	}
+	order.Subtotal = decimal.NewFromInt(120)
+	order.Tax = decimal.NewFromFloat(9.6)
+	order.Total = decimal.NewFromFloat(129.6)
+	require.NoError(t, f.orders.Create(context.Background(), order))
+	f.order = order
+	return f
+}
+
+func (f *returnFixture) request(t *testing.T, items ...ReturnItemRequest) *models.Return {
+	ret, err := f.service.RequestReturn(context.Background(), f.order.ID, &CreateReturnRequest{
+		UserID: "user1",
+		Reason: "damaged",
+		Items:  items,
+	})
+	require.NoError(t, err)
+	return ret
+}
+
+func (f *returnFixture) approveAndReceive(t *testing.T, id string) {
+	ctx := context.Background()
+	_, err := f.service.ApproveReturn(ctx, id, &ApproveReturnRequest{ApprovedBy: "admin1"})
+	require.NoError(t, err)
+	_, err = f.service.ReceiveReturn(ctx, id, &ReturnActionRequest{ChangedBy: "warehouse1"})
+	require.NoError(t, err)
+}
+
+func TestReturnService_FullWorkflow(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 2})
+	assert.Equal(t, models.ReturnRequested, ret.Status)
+	assert.Equal(t, "RMA-ORD-20260601-0000422-1", ret.RMANumber)
+
+	approved, err := f.service.ApproveReturn(ctx, ret.ID, &ApproveReturnRequest{ApprovedBy: "admin1"})
+	require.NoError(t, err)
+	assert.Equal(t, "TRK123", approved.TrackingNumber)
+	require.Len(t, f.shipping.requests, 1)
+	label := f.shipping.requests[0]
+	assert.Equal(t, "ground", label.ShippingMethodID)
+	assert.Equal(t, DefaultReturnConfig().WarehouseAddress, label.ToAddress)
+	assert.True(t, label.DeclaredValue.Equal(decimal.NewFromInt(100)))
+
+	_, err = f.service.ReceiveReturn(ctx, ret.ID, &ReturnActionRequest{ChangedBy: "warehouse1"})
+	require.NoError(t, err)
+
+	// One unit arrived damaged: it is refunded but not restocked
+	refunded, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{
+		InspectedBy: "warehouse1",
+		Items: []ReturnInspectionItem{
+			{ReturnItemID: ret.Items[0].ID, AcceptedQuantity: 2, RestockQuantity: 1, Condition: "one damaged"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnRefunded, refunded.Status)
+	assert.Equal(t, "refund-1", refunded.RefundID)
+	assert.True(t, refunded.RefundAmount.Equal(decimal.NewFromInt(108)), "got %s", refunded.RefundAmount)
+	assert.Equal(t, map[string]int{"prod1": 1}, f.restock.restocked)
+
+	require.Len(t, f.refunds.refunds, 1)
+	assert.Equal(t, "pay-1", f.refunds.refunds[0].PaymentID)
+	assert.Equal(t, "return-refund-"+ret.ID, f.refunds.refunds[0].IdempotencyKey)
+	assert.True(t, f.refunds.refunds[0].Amount.Equal(decimal.NewFromInt(108)))
+
+	// Every step shows up in the order history; the order keeps item2 so stays delivered
+	history, err := f.orders.GetStatusHistory(ctx, f.order.ID)
+	require.NoError(t, err)
+	var reasons []string
+	for _, change := range history {
+		assert.Equal(t, ret.RMANumber, change.Notes)
+		reasons = append(reasons, change.Reason)
+	}
+	assert.Equal(t, []string{
+		"return requested: damaged",
+		"return approved: label TRK123 issued",
+		"return received",
+		"return inspected: 2 of 2 units accepted",
+		"return refunded: 108.00 USD",
+	}, reasons)
+	assert.Equal(t, models.OrderDelivered, f.order.Status)
+}
+
+func TestReturnService_RefundProratesDiscountsAndCapsAtTotal(t *testing.T) {
+	f := newReturnFixture(t)
+	f.order.Discount = decimal.NewFromInt(12) // 10% of the subtotal
+	f.order.Total = decimal.NewFromFloat(117.6)
+
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 1}, ReturnItemRequest{OrderItemID: "item2", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	refunded, err := f.service.InspectReturn(context.Background(), ret.ID, &InspectReturnRequest{})
+	require.NoError(t, err)
+	// item1: (100 - 10) / 2 + 8 / 2 = 49.00; item2: 20 - 2 + 1.60 = 19.60
+	assert.True(t, refunded.Items[0].RefundAmount.Equal(decimal.NewFromInt(49)), "got %s", refunded.Items[0].RefundAmount)
+	assert.True(t, refunded.Items[1].RefundAmount.Equal(decimal.NewFromFloat(19.6)), "got %s", refunded.Items[1].RefundAmount)
+	assert.True(t, refunded.RefundAmount.Equal(decimal.NewFromFloat(68.6)))
+
+	usd, err := currency.Lookup("USD")
+	require.NoError(t, err)
+	calculateReturnRefund(usd, f.order, refunded, decimal.NewFromInt(100))
+	assert.True(t, refunded.RefundAmount.Equal(decimal.NewFromFloat(17.6)), "refunds must not exceed the order total")
+}
+
+func TestReturnService_RefundRoundsToCurrencyMinorUnits(t *testing.T) {
+	f := newReturnFixture(t)
+	f.order.Currency = "JPY"
+	f.order.Items[0].Total = decimal.NewFromInt(1001)
+	f.order.Items[0].Tax = decimal.Zero
+	f.order.Tax = decimal.Zero
+	f.order.Subtotal = decimal.NewFromInt(1021)
+	f.order.Total = decimal.NewFromInt(1021)
+
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	refunded, err := f.service.InspectReturn(context.Background(), ret.ID, &InspectReturnRequest{})
+	require.NoError(t, err)
+	// Half of 1001 yen is 500.5, which rounds to a whole yen
+	assert.True(t, refunded.RefundAmount.Equal(decimal.NewFromInt(501)), "got %s", refunded.RefundAmount)
+
+	history, err := f.orders.GetStatusHistory(context.Background(), f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "return refunded: 501 JPY", history[len(history)-1].Reason)
+}
+
+func TestReturnService_FullReturnMarksOrderRefunded(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 2}, ReturnItemRequest{OrderItemID: "item2", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	_, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderRefunded, f.order.Status)
+	assert.Equal(t, map[string]int{"prod1": 2, "prod2": 1}, f.restock.restocked)
+	assert.True(t, f.refunds.refunds[0].Amount.Equal(f.order.Total))
+}
+
+func TestReturnService_RequestReturn_Validation(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 1})
+
+	tests := []struct {
+		name string
+		req  *CreateReturnRequest
+		want string
+	}{
+		{"no items", &CreateReturnRequest{UserID: "user1", Reason: "damaged"}, "invalid return"},
+		{"no reason", &CreateReturnRequest{UserID: "user1", Items: []ReturnItemRequest{{OrderItemID: "item2", Quantity: 1}}}, "invalid return"},
+		{"other user", &CreateReturnRequest{UserID: "user2", Reason: "damaged", Items: []ReturnItemRequest{{OrderItemID: "item2", Quantity: 1}}}, "order not found"},
+		{"unknown item", &CreateReturnRequest{UserID: "user1", Reason: "damaged", Items: []ReturnItemRequest{{OrderItemID: "item9", Quantity: 1}}}, "not part of the order"},
+		{"already returned", &CreateReturnRequest{UserID: "user1", Reason: "damaged", Items: []ReturnItemRequest{{OrderItemID: "item1", Quantity: 2}}}, "only 1 of order item item1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.RequestReturn(ctx, f.order.ID, tt.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestReturnService_RequestReturn_OrderState(t *testing.T) {
+	ctx := context.Background()
+	req := &CreateReturnRequest{UserID: "user1", Reason: "damaged", Items: []ReturnItemRequest{{OrderItemID: "item1", Quantity: 1}}}
+
+	f := newReturnFixture(t)
+	f.order.Status = models.OrderProcessing
+	_, err := f.service.RequestReturn(ctx, f.order.ID, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "order is processing")
+
+	f = newReturnFixture(t)
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	f.order.DeliveredAt = &longAgo
+	_, err = f.service.RequestReturn(ctx, f.order.ID, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "return window")
+
+	// Only shipped fulfillments can be returned from a partially shipped order
+	f = newReturnFixture(t)
+	f.order.Status = models.OrderPartiallyShipped
+	f.order.Fulfillments = []models.Fulfillment{
+		{Status: models.FulfillmentShipped, Items: []models.FulfillmentItem{{OrderItemID: "item2", Quantity: 1}}},
+		{Status: models.FulfillmentPending, Items: []models.FulfillmentItem{{OrderItemID: "item1", Quantity: 2}}},
+	}
+	_, err = f.service.RequestReturn(ctx, f.order.ID, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only 0 of order item item1")
+}
+
+func TestReturnService_RejectedReturnReleasesItems(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 2})
+
+	_, err := f.service.RejectReturn(ctx, ret.ID, &ReturnActionRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reason is required")
+
+	rejected, err := f.service.RejectReturn(ctx, ret.ID, &ReturnActionRequest{ChangedBy: "admin1", Reason: "outside policy"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnRejected, rejected.Status)
+
+	_, err = f.service.ApproveReturn(ctx, ret.ID, &ApproveReturnRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status transition")
+
+	second := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 2})
+	assert.Equal(t, "RMA-ORD-20260601-0000422-2", second.RMANumber)
+}
+
+func TestReturnService_FailedRefundCanBeRetried(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item2", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	f.refunds.err = errors.New("payment-service unavailable")
+	_, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.Error(t, err)
+
+	inspected, err := f.service.GetReturn(ctx, ret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnInspected, inspected.Status)
+	assert.Equal(t, map[string]int{"prod2": 1}, f.restock.restocked)
+
+	f.refunds.err = nil
+	refunded, err := f.service.RefundReturn(ctx, ret.ID, &ReturnActionRequest{ChangedBy: "admin1"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnRefunded, refunded.Status)
+	assert.True(t, refunded.RefundAmount.Equal(decimal.NewFromFloat(21.6)))
+	assert.Equal(t, map[string]int{"prod2": 1}, f.restock.restocked, "retrying the refund must not restock again")
+}
+
+func TestReturnService_ConcurrentInspectionRestocksAndRefundsOnce(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item2", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	// A second inspection read the return as received before the first saved it
+	stale, err := f.service.GetReturn(ctx, ret.ID)
+	require.NoError(t, err)
+	f.restock.during = func() {
+		stale.Status = models.ReturnInspected
+		err := f.returns.Update(ctx, stale, models.ReturnReceived, "return inspected", "warehouse2")
+		assert.ErrorIs(t, err, repository.ErrReturnChanged)
+	}
+
+	_, err = f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"prod2": 1}, f.restock.restocked)
+
+	_, err = f.service.RefundReturn(ctx, ret.ID, &ReturnActionRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status transition")
+	assert.Len(t, f.refunds.refunds, 1)
+}
+
+func TestReturnService_FailedRestockUndoesInspection(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item2", Quantity: 1})
+	f.approveAndReceive(t, ret.ID)
+
+	f.restock.err = errors.New("product-service unavailable")
+	_, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to restock")
+
+	received, err := f.service.GetReturn(ctx, ret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnReceived, received.Status)
+	assert.Empty(t, f.refunds.refunds)
+
+	f.restock.err = nil
+	refunded, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReturnRefunded, refunded.Status)
+	assert.Equal(t, map[string]int{"prod2": 1}, f.restock.restocked)
+}
+
+func TestReturnService_InspectReturn_Validation(t *testing.T) {
+	ctx := context.Background()
+	f := newReturnFixture(t)
+	ret := f.request(t, ReturnItemRequest{OrderItemID: "item1", Quantity: 1})
+
+	_, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status transition")
+
+	f.approveAndReceive(t, ret.ID)
+	tests := []struct {
+		name string
+		item ReturnInspectionItem
+		want string
+	}{
+		{"too many accepted", ReturnInspectionItem{ReturnItemID: ret.Items[0].ID, AcceptedQuantity: 2}, "accepted quantity"},
+		{"restock more than accepted", ReturnInspectionItem{ReturnItemID: ret.Items[0].ID, AcceptedQuantity: 0, RestockQuantity: 1}, "restock quantity"},
+		{"unknown item", ReturnInspectionItem{ReturnItemID: "other", AcceptedQuantity: 1}, "not part of the return"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.InspectReturn(ctx, ret.ID, &InspectReturnRequest{Items: []ReturnInspectionItem{tt.item}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+	assert.Empty(t, f.restock.restocked)
+	assert.Empty(t, f.refunds.refunds)
+}
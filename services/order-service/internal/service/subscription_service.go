@@ -0,0 +1,1003 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionService manages subscription plans and customers'
+// subscriptions to them. RunDue is the scheduler: it renews subscriptions
+// as they come due by placing an order and charging the saved payment
+// method, and retries failed renewals before giving up on them.
+type SubscriptionService interface {
+	CreatePlan(ctx context.Context, req *SubscriptionPlanInput) (*models.SubscriptionPlan, error)
+	UpdatePlan(ctx context.Context, id string, req *SubscriptionPlanInput) (*models.SubscriptionPlan, error)
+	GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error)
+	ListPlans(ctx context.Context, productID string, activeOnly bool, limit, offset int) ([]*models.SubscriptionPlan, error)
+
+	Subscribe(ctx context.Context, req *SubscribeRequest) (*models.Subscription, error)
+	GetSubscription(ctx context.Context, id string) (*models.Subscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]*models.Subscription, error)
+	ListRenewals(ctx context.Context, id string) ([]*models.SubscriptionRenewal, error)
+	UpdatePaymentMethod(ctx context.Context, id string, req *UpdateSubscriptionPaymentRequest) (*models.Subscription, error)
+	Pause(ctx context.Context, id string, req *PauseSubscriptionRequest) (*models.Subscription, error)
+	Resume(ctx context.Context, id string, req *SubscriptionActionRequest) (*models.Subscription, error)
+	Skip(ctx context.Context, id string, req *SubscriptionActionRequest) (*models.Subscription, error)
+	Cancel(ctx context.Context, id string, req *CancelSubscriptionRequest) (*models.Subscription, error)
+
+	// RunDue renews the subscriptions that are due and resumes paused ones
+	// whose resume time has come. It returns how many it handled.
+	RunDue(ctx context.Context) (int, error)
+}
+
+// PaymentMethodService interface for looking up saved payment methods
+type PaymentMethodService interface {
+	GetPaymentMethod(ctx context.Context, id string) (*models.PaymentMethodInfo, error)
+}
+
+// Notifier interface for sending customer notifications through notification-service
+type Notifier interface {
+	SendNotification(ctx context.Context, req *models.NotificationRequest) error
+}
+
+// SubscriptionPlanInput creates or replaces a subscription plan. The product
+// and variant of an existing plan cannot be changed. IntervalCount defaults
+// to 1, Currency to the store currency and Active to true.
+type SubscriptionPlanInput struct {
+	ProductID     string                      `json:"product_id" validate:"required"`
+	VariantID     string                      `json:"variant_id"`
+	Name          string                      `json:"name" validate:"required"`
+	Description   string                      `json:"description"`
+	Interval      models.SubscriptionInterval `json:"interval" validate:"required"`
+	IntervalCount int                         `json:"interval_count"`
+	TrialDays     int                         `json:"trial_days"`
+	Price         decimal.Decimal             `json:"price"`
+	Currency      string                      `json:"currency"`
+	Active        *bool                       `json:"active"`
+}
+
+// SubscribeRequest subscribes a customer to a plan. Quantity defaults to 1.
+type SubscribeRequest struct {
+	UserID           string         `json:"user_id" validate:"required"`
+	PlanID           string         `json:"plan_id" validate:"required"`
+	Quantity         int            `json:"quantity"`
+	Email            string         `json:"email" validate:"required"`
+	PaymentMethodID  string         `json:"payment_method_id" validate:"required"`
+	ShippingAddress  models.Address `json:"shipping_address" validate:"required"`
+	BillingAddress   models.Address `json:"billing_address" validate:"required"`
+	ShippingMethodID string         `json:"shipping_method_id"`
+}
+
+// SubscriptionActionRequest identifies the customer acting on their subscription
+type SubscriptionActionRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// PauseSubscriptionRequest pauses a subscription, until ResumeAt if it is set
+type PauseSubscriptionRequest struct {
+	UserID   string     `json:"user_id" validate:"required"`
+	ResumeAt *time.Time `json:"resume_at"`
+}
+
+// CancelSubscriptionRequest cancels a subscription now, or when its current
+// period ends if AtPeriodEnd is set
+type CancelSubscriptionRequest struct {
+	UserID      string `json:"user_id" validate:"required"`
+	Reason      string `json:"reason"`
+	AtPeriodEnd bool   `json:"at_period_end"`
+}
+
+// UpdateSubscriptionPaymentRequest changes the payment method renewals are charged to
+type UpdateSubscriptionPaymentRequest struct {
+	UserID          string `json:"user_id" validate:"required"`
+	PaymentMethodID string `json:"payment_method_id" validate:"required"`
+}
+
+// SubscriptionConfig holds subscription billing settings
+type SubscriptionConfig struct {
+	// RetrySchedule is how long to wait after each failed renewal before
+	// retrying it. A subscription whose renewal still fails after the last
+	// retry is cancelled.
+	RetrySchedule []time.Duration
+	// BatchSize is the most subscriptions one RunDue handles
+	BatchSize int
+	// ClaimLease is how long a subscription claimed by RunDue is hidden from
+	// other schedulers; it must outlast one renewal
+	ClaimLease time.Duration
+}
+
+// DefaultSubscriptionConfig returns the default subscription configuration
+func DefaultSubscriptionConfig() SubscriptionConfig {
+	return SubscriptionConfig{
+		RetrySchedule: []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 5 * 24 * time.Hour},
+		BatchSize:     50,
+		ClaimLease:    10 * time.Minute,
+	}
+}
+
+// subscriptionService implements SubscriptionService
+type subscriptionService struct {
+	repo           repository.SubscriptionRepository
+	orders         OrderService
+	productService ProductService
+	payments       PaymentService
+	paymentMethods PaymentMethodService
+	notifier       Notifier
+	config         SubscriptionConfig
+	now            func() time.Time
+}
+
+// NewSubscriptionService creates a new subscription service. Renewal orders
+// are created through orders, so they are priced, taxed and reserve stock
+// like any other order. Without a notifier, no billing notices are sent.
+func NewSubscriptionService(
+	repo repository.SubscriptionRepository,
+	orders OrderService,
+	productService ProductService,
+	payments PaymentService,
+	paymentMethods PaymentMethodService,
+	notifier Notifier,
+	config SubscriptionConfig,
+) SubscriptionService {
+	defaults := DefaultSubscriptionConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.ClaimLease <= 0 {
+		config.ClaimLease = defaults.ClaimLease
+	}
+	return &subscriptionService{
+		repo:           repo,
+		orders:         orders,
+		productService: productService,
+		payments:       payments,
+		paymentMethods: paymentMethods,
+		notifier:       notifier,
+		config:         config,
+		now:            time.Now,
+	}
+}
+
+// CreatePlan validates and stores a new subscription plan
+func (s *subscriptionService) CreatePlan(ctx context.Context, req *SubscriptionPlanInput) (*models.SubscriptionPlan, error) {
+	if err := s.validatePlanInput(ctx, req); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	plan := &models.SubscriptionPlan{
+		ID:        uuid.New().String(),
+		ProductID: req.ProductID,
+		VariantID: req.VariantID,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	applyPlanInput(plan, req)
+
+	if err := s.repo.CreatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Subscription plan created", map[string]interface{}{
+		"plan_id":    plan.ID,
+		"product_id": plan.ProductID,
+		"interval":   plan.Interval,
+		"price":      plan.Price,
+	})
+
+	return plan, nil
+}
+
+// UpdatePlan replaces a plan's terms. Existing subscriptions pick them up at
+// their next renewal; deactivating a plan only stops new subscriptions.
+func (s *subscriptionService) UpdatePlan(ctx context.Context, id string, req *SubscriptionPlanInput) (*models.SubscriptionPlan, error) {
+	plan, err := s.repo.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The product is fixed, so only the rest of the input is validated
+	req.ProductID, req.VariantID = plan.ProductID, plan.VariantID
+	if err := validatePlanTerms(req); err != nil {
+		return nil, err
+	}
+	applyPlanInput(plan, req)
+
+	if err := s.repo.UpdatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Subscription plan updated", map[string]interface{}{
+		"plan_id": plan.ID,
+		"active":  plan.Active,
+		"price":   plan.Price,
+	})
+
+	return plan, nil
+}
+
+// GetPlan retrieves a subscription plan by ID
+func (s *subscriptionService) GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error) {
+	if id == "" {
+		return nil, fmt.Errorf("subscription plan ID is required")
+	}
+	return s.repo.GetPlan(ctx, id)
+}
+
+// ListPlans returns subscription plans, newest first
+func (s *subscriptionService) ListPlans(ctx context.Context, productID string, activeOnly bool, limit, offset int) ([]*models.SubscriptionPlan, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListPlans(ctx, productID, activeOnly, limit, offset)
+}
+
+// Subscribe starts a subscription. Plans with a trial are first charged when
+// the trial ends; others are charged, and their first order placed, right
+// away. If that first charge fails the subscription is cancelled.
+func (s *subscriptionService) Subscribe(ctx context.Context, req *SubscribeRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid subscription: %w", err)
+	}
+	if req.Quantity < 0 {
+		return nil, fmt.Errorf("invalid subscription: quantity must be positive")
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	plan, err := s.repo.GetPlan(ctx, req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Active {
+		return nil, fmt.Errorf("invalid subscription: plan %s is no longer offered", plan.Name)
+	}
+	if err := s.checkPaymentMethod(ctx, req.UserID, req.PaymentMethodID); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	subscription := &models.Subscription{
+		ID:                 uuid.New().String(),
+		UserID:             req.UserID,
+		PlanID:             plan.ID,
+		Quantity:           req.Quantity,
+		Status:             models.SubscriptionActive,
+		Email:              req.Email,
+		PaymentMethodID:    req.PaymentMethodID,
+		ShippingAddress:    req.ShippingAddress,
+		BillingAddress:     req.BillingAddress,
+		ShippingMethodID:   req.ShippingMethodID,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now,
+		NextBillingAt:      now,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if plan.TrialDays > 0 {
+		trialEnd := now.AddDate(0, 0, plan.TrialDays)
+		subscription.Status = models.SubscriptionTrialing
+		subscription.TrialEndsAt = &trialEnd
+		subscription.CurrentPeriodEnd = trialEnd
+		subscription.NextBillingAt = trialEnd
+	}
+
+	if err := s.repo.Create(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	if subscription.Status == models.SubscriptionActive {
+		renewal, err := s.bill(ctx, subscription, plan)
+		if err != nil {
+			return nil, err
+		}
+		if renewal.Status == models.RenewalFailed {
+			s.cancel(subscription, "Initial payment failed")
+			if err := s.repo.CompleteRenewal(ctx, subscription, renewal); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to charge subscription: %s", renewal.FailureReason)
+		}
+		s.advance(subscription, renewal)
+		if err := s.repo.CompleteRenewal(ctx, subscription, renewal); err != nil {
+			return nil, err
+		}
+	}
+
+	utils.Logger.Info(ctx, "Subscription started", map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"user_id":         subscription.UserID,
+		"plan_id":         plan.ID,
+		"status":          subscription.Status,
+		"next_billing_at": subscription.NextBillingAt,
+	})
+
+	return subscription, nil
+}
+
+// GetSubscription retrieves a subscription by ID
+func (s *subscriptionService) GetSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	if id == "" {
+		return nil, fmt.Errorf("subscription ID is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListSubscriptions returns a user's subscriptions, newest first
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, userID string) ([]*models.Subscription, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// ListRenewals returns a subscription's renewal attempts, newest first
+func (s *subscriptionService) ListRenewals(ctx context.Context, id string) ([]*models.SubscriptionRenewal, error) {
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRenewals(ctx, id)
+}
+
+// UpdatePaymentMethod changes the payment method renewals are charged to. A
+// past due subscription is retried with it on the scheduler's next run.
+func (s *subscriptionService) UpdatePaymentMethod(ctx context.Context, id string, req *UpdateSubscriptionPaymentRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	subscription, err := s.changeableSubscription(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status == models.SubscriptionCancelled {
+		return nil, fmt.Errorf("invalid status transition: subscription is cancelled")
+	}
+	if err := s.checkPaymentMethod(ctx, req.UserID, req.PaymentMethodID); err != nil {
+		return nil, err
+	}
+
+	subscription.PaymentMethodID = req.PaymentMethodID
+	if subscription.Status == models.SubscriptionPastDue {
+		subscription.NextBillingAt = s.now()
+	}
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Pause stops renewals until the subscription is resumed, by the customer or
+// automatically at ResumeAt. Periods that end while it is paused are not
+// billed; the first renewal after it resumes starts a new period.
+func (s *subscriptionService) Pause(ctx context.Context, id string, req *PauseSubscriptionRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	now := s.now()
+	if req.ResumeAt != nil && !req.ResumeAt.After(now) {
+		return nil, fmt.Errorf("invalid request: resume_at must be in the future")
+	}
+
+	subscription, err := s.changeableSubscription(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status != models.SubscriptionActive && subscription.Status != models.SubscriptionTrialing {
+		return nil, fmt.Errorf("invalid status transition: cannot pause a %s subscription", subscription.Status)
+	}
+
+	subscription.Status = models.SubscriptionPaused
+	subscription.PausedAt = &now
+	subscription.ResumeAt = req.ResumeAt
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Subscription paused", map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"resume_at":       subscription.ResumeAt,
+	})
+
+	return subscription, nil
+}
+
+// Resume resumes a paused subscription, or keeps one that was set to cancel
+// at the end of its period
+func (s *subscriptionService) Resume(ctx context.Context, id string, req *SubscriptionActionRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	subscription, err := s.changeableSubscription(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case subscription.Status == models.SubscriptionPaused:
+		s.resume(subscription, s.now())
+	case subscription.CancelAtPeriodEnd && subscription.IsBillable():
+		subscription.CancelAtPeriodEnd = false
+		subscription.CancellationReason = ""
+	default:
+		return nil, fmt.Errorf("invalid status transition: cannot resume a %s subscription", subscription.Status)
+	}
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Skip skips the next period: it is neither ordered nor charged, and the
+// renewal after it is billed as usual
+func (s *subscriptionService) Skip(ctx context.Context, id string, req *SubscriptionActionRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	subscription, err := s.changeableSubscription(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status != models.SubscriptionActive && subscription.Status != models.SubscriptionTrialing {
+		return nil, fmt.Errorf("invalid status transition: cannot skip a period of a %s subscription", subscription.Status)
+	}
+	if subscription.CancelAtPeriodEnd {
+		return nil, fmt.Errorf("invalid status transition: subscription ends with the current period")
+	}
+
+	plan, err := s.repo.GetPlan(ctx, subscription.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The period is moved on before the skip is recorded, so a skip refused
+	// because the scheduler got to the subscription first leaves no record
+	now := s.now()
+	start := subscription.CurrentPeriodEnd
+	renewal := models.NewSubscriptionRenewal(subscription.ID, start, plan.PeriodEnd(start), models.RenewalSkipped, now)
+	renewal.Currency = plan.Currency
+
+	subscription.CurrentPeriodStart = renewal.PeriodStart
+	subscription.CurrentPeriodEnd = renewal.PeriodEnd
+	subscription.NextBillingAt = renewal.PeriodEnd
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRenewal(ctx, renewal); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Subscription period skipped", map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"period_start":    renewal.PeriodStart,
+		"next_billing_at": subscription.NextBillingAt,
+	})
+
+	return subscription, nil
+}
+
+// Cancel cancels a subscription. Cancelling at period end only applies to
+// trialing and active subscriptions; the others have no paid period left
+// and are cancelled right away.
+func (s *subscriptionService) Cancel(ctx context.Context, id string, req *CancelSubscriptionRequest) (*models.Subscription, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	subscription, err := s.changeableSubscription(ctx, id, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status == models.SubscriptionCancelled {
+		return nil, fmt.Errorf("invalid status transition: subscription is already cancelled")
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "Cancelled by customer"
+	}
+
+	if req.AtPeriodEnd && (subscription.Status == models.SubscriptionActive || subscription.Status == models.SubscriptionTrialing) {
+		subscription.CancelAtPeriodEnd = true
+		subscription.CancellationReason = reason
+	} else {
+		s.cancel(subscription, reason)
+	}
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Subscription cancelled", map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"at_period_end":   subscription.CancelAtPeriodEnd,
+		"reason":          reason,
+	})
+
+	return subscription, nil
+}
+
+// RunDue claims the due subscriptions and handles each one. A subscription
+// that fails is logged and left for its claim to expire, so one bad
+// subscription does not hold up the rest.
+func (s *subscriptionService) RunDue(ctx context.Context) (int, error) {
+	now := s.now()
+	due, err := s.repo.ClaimDue(ctx, now, now.Add(s.config.ClaimLease), s.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim due subscriptions: %w", err)
+	}
+
+	for _, subscription := range due {
+		if err := s.process(ctx, subscription); err != nil {
+			utils.Logger.Error(ctx, "Failed to process subscription", err, map[string]interface{}{
+				"subscription_id": subscription.ID,
+				"status":          subscription.Status,
+			})
+		}
+	}
+
+	return len(due), nil
+}
+
+func (s *subscriptionService) process(ctx context.Context, subscription *models.Subscription) error {
+	switch {
+	case subscription.Status == models.SubscriptionPaused:
+		s.resume(subscription, s.now())
+		return s.repo.Update(ctx, subscription)
+	case subscription.CancelAtPeriodEnd:
+		s.cancel(subscription, subscription.CancellationReason)
+		return s.repo.Update(ctx, subscription)
+	default:
+		return s.renew(ctx, subscription)
+	}
+}
+
+// renew bills the next period of a due subscription, starting dunning if
+// the charge fails
+func (s *subscriptionService) renew(ctx context.Context, subscription *models.Subscription) error {
+	plan, err := s.repo.GetPlan(ctx, subscription.PlanID)
+	if err != nil {
+		return err
+	}
+
+	renewal, err := s.bill(ctx, subscription, plan)
+	if err != nil {
+		return err
+	}
+	if renewal.Status == models.RenewalFailed {
+		return s.dun(ctx, subscription, renewal)
+	}
+
+	recovered := subscription.Status == models.SubscriptionPastDue
+	s.advance(subscription, renewal)
+	if err := s.repo.CompleteRenewal(ctx, subscription, renewal); err != nil {
+		return err
+	}
+
+	utils.Logger.Info(ctx, "Subscription renewed", map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"order_id":        renewal.OrderID,
+		"amount":          renewal.Amount,
+		"next_billing_at": subscription.NextBillingAt,
+	})
+
+	if recovered {
+		s.notify(ctx, subscription, "Your subscription payment went through",
+			fmt.Sprintf("We charged %s %s for your subscription and it is active again. Thank you!", renewal.Amount, renewal.Currency))
+	}
+	return nil
+}
+
+// bill places the order for the subscription's next period and charges it.
+// The renewal is recorded as pending before anything is ordered or charged,
+// and its order and payment are saved as they are made, so a renewal cut
+// short by a crash is resumed by the next run rather than charged again. A
+// failed order or charge is reported through the renewal's status; the error
+// is only set if the renewal cannot be recorded or its payment's outcome is
+// unknown, in which case it stays pending. Callers save the outcome with
+// CompleteRenewal.
+func (s *subscriptionService) bill(ctx context.Context, subscription *models.Subscription, plan *models.SubscriptionPlan) (*models.SubscriptionRenewal, error) {
+	renewal, err := s.repo.GetPendingRenewal(ctx, subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if renewal == nil {
+		now := s.now()
+		start := subscription.CurrentPeriodEnd
+		if !plan.PeriodEnd(start).After(now) {
+			// Billing is so late that the period would already be over
+			start = now
+		}
+
+		renewal = models.NewSubscriptionRenewal(subscription.ID, start, plan.PeriodEnd(start), models.RenewalPending, now)
+		renewal.Attempt = subscription.FailedAttempts + 1
+		renewal.Currency = plan.Currency
+		if err := s.repo.BeginRenewal(ctx, subscription, renewal); err != nil {
+			return nil, err
+		}
+	}
+
+	declined, err := s.charge(ctx, subscription, plan, renewal)
+	if err != nil {
+		return nil, err
+	}
+
+	renewal.Status = models.RenewalPaid
+	if declined != nil {
+		renewal.Status = models.RenewalFailed
+		renewal.FailureReason = declined.Error()
+		utils.Logger.Warn(ctx, "Subscription renewal failed", map[string]interface{}{
+			"subscription_id": subscription.ID,
+			"attempt":         renewal.Attempt,
+			"error":           declined.Error(),
+		})
+	}
+	return renewal, nil
+}
+
+// charge places the renewal order and charges the saved payment method,
+// picking up after the steps an interrupted attempt already took. The
+// payment carries an idempotency key derived from the renewal, so it is
+// created once however often the renewal is resumed. A declined order or
+// charge is returned as declined, after the order is cancelled to release
+// its stock; err is set when the outcome of an earlier attempt's payment
+// cannot be read.
+func (s *subscriptionService) charge(ctx context.Context, subscription *models.Subscription, plan *models.SubscriptionPlan, renewal *models.SubscriptionRenewal) (declined error, err error) {
+	order, declined, err := s.renewalOrder(ctx, subscription, plan, renewal)
+	if declined != nil || err != nil {
+		return declined, err
+	}
+
+	var payment *models.Payment
+	if renewal.PaymentID == "" {
+		payment, err = s.payments.CreatePayment(ctx, &PaymentRequest{
+			IdempotencyKey:  "subscription-renewal-" + renewal.ID,
+			OrderID:         order.ID,
+			UserID:          order.UserID,
+			Amount:          order.Total,
+			Currency:        order.Currency,
+			PaymentMethodID: subscription.PaymentMethodID,
+			Description:     fmt.Sprintf("Subscription renewal, order %s", order.OrderNumber),
+			// Renewals are charged when they are placed rather than held until shipping
+			AutoCapture: true,
+			Risk: models.PaymentRiskContext{
+				BillingCountry:  subscription.BillingAddress.Country,
+				ShippingCountry: subscription.ShippingAddress.Country,
+			},
+		})
+		if err != nil {
+			s.cancelOrder(ctx, order, err)
+			return fmt.Errorf("failed to create payment: %w", err), nil
+		}
+
+		// A resumed renewal that lost the payment ID gets the same payment
+		// back through the idempotency key, so this is not fatal
+		renewal.PaymentID = payment.ID
+		if err := s.repo.UpdateRenewal(ctx, renewal); err != nil {
+			utils.Logger.Error(ctx, "Failed to record renewal payment", err, map[string]interface{}{
+				"renewal_id": renewal.ID,
+				"payment_id": payment.ID,
+			})
+		}
+	} else if payment, err = s.payments.GetPayment(ctx, renewal.PaymentID); err != nil {
+		return nil, fmt.Errorf("failed to load renewal payment: %w", err)
+	}
+
+	processed := payment
+	if payment.Status == models.PaymentPending {
+		processed, err = s.payments.ProcessPayment(ctx, payment.ID)
+	}
+	if err == nil && processed.Status != models.PaymentCompleted && processed.Status != models.PaymentAuthorized {
+		err = fmt.Errorf("status %s %s", processed.Status, processed.FailureReason)
+	}
+	if err != nil {
+		if voidErr := voidPayment(context.WithoutCancel(ctx), s.payments, payment.ID, "Subscription renewal failed"); voidErr != nil {
+			utils.Logger.Error(ctx, "Failed to void renewal payment", voidErr, map[string]interface{}{
+				"payment_id": payment.ID,
+			})
+		}
+		s.cancelOrder(ctx, order, err)
+		return fmt.Errorf("payment failed: %w", err), nil
+	}
+
+	// The customer has paid, so failing to record it on the order must not
+	// fail the renewal and get them charged again
+	if order.Status == models.OrderConfirmed {
+		return nil, nil
+	}
+	if err := s.orders.UpdateOrderStatus(ctx, order.ID, models.OrderConfirmed, "Payment "+string(processed.Status), "subscriptions"); err != nil {
+		utils.Logger.Error(ctx, "Failed to confirm renewal order", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+	} else {
+		if err := s.orders.RecordPayment(ctx, order.ID, string(processed.Status), processed.ID); err != nil {
+			utils.Logger.Error(ctx, "Failed to record payment on renewal order", err, map[string]interface{}{
+				"order_id":   order.ID,
+				"payment_id": processed.ID,
+			})
+		}
+	}
+
+	return nil, nil
+}
+
+// renewalOrder returns the renewal's order, creating it and saving it on the
+// renewal unless an earlier attempt already did
+func (s *subscriptionService) renewalOrder(ctx context.Context, subscription *models.Subscription, plan *models.SubscriptionPlan, renewal *models.SubscriptionRenewal) (order *models.Order, declined error, err error) {
+	if renewal.OrderID != "" {
+		if order, err = s.orders.GetOrder(ctx, renewal.OrderID); err != nil {
+			return nil, nil, fmt.Errorf("failed to load renewal order: %w", err)
+		}
+		return order, nil, nil
+	}
+
+	method, err := s.paymentMethods.GetPaymentMethod(ctx, subscription.PaymentMethodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment method: %w", err), nil
+	}
+
+	order, err = s.orders.CreateOrder(ctx, &CreateOrderRequest{
+		UserID: subscription.UserID,
+		Items: []OrderItemRequest{{
+			ProductID: plan.ProductID,
+			VariantID: plan.VariantID,
+			Quantity:  subscription.Quantity,
+			PlanPrice: plan.Price,
+		}},
+		ShippingAddress: subscription.ShippingAddress,
+		BillingAddress:  subscription.BillingAddress,
+		PaymentMethod:   orderPaymentMethod(method),
+		ShippingMethod:  subscription.ShippingMethodID,
+		Notes:           fmt.Sprintf("Subscription %s renewal", subscription.ID),
+		Source:          "subscription",
+		Currency:        plan.Currency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renewal order: %w", err), nil
+	}
+
+	renewal.OrderID = order.ID
+	renewal.Amount = order.Total
+	renewal.Currency = order.Currency
+	if err := s.repo.UpdateRenewal(ctx, renewal); err != nil {
+		// Charging an order the renewal does not know about could not be resumed
+		s.cancelOrder(ctx, order, err)
+		return order, fmt.Errorf("failed to record renewal order: %w", err), nil
+	}
+	return order, nil, nil
+}
+
+func (s *subscriptionService) cancelOrder(ctx context.Context, order *models.Order, cause error) {
+	if err := s.orders.CancelOrder(context.WithoutCancel(ctx), order.ID, "Subscription renewal failed: "+cause.Error(), "subscriptions"); err != nil {
+		utils.Logger.Error(ctx, "Failed to cancel renewal order", err, map[string]interface{}{
+			"order_id": order.ID,
+		})
+	}
+}
+
+// dun schedules the next retry of a failed renewal, or cancels the
+// subscription when the retries are used up, and tells the customer
+func (s *subscriptionService) dun(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error {
+	subscription.FailedAttempts++
+
+	if subscription.FailedAttempts > len(s.config.RetrySchedule) {
+		s.cancel(subscription, "Renewal payment failed")
+		if err := s.repo.CompleteRenewal(ctx, subscription, renewal); err != nil {
+			return err
+		}
+
+		utils.Logger.Warn(ctx, "Subscription cancelled after failed renewals", map[string]interface{}{
+			"subscription_id": subscription.ID,
+			"attempts":        subscription.FailedAttempts,
+		})
+		s.notify(ctx, subscription, "Your subscription has been cancelled",
+			"We could not charge your payment method for your subscription after several attempts, so it has been cancelled.")
+		return nil
+	}
+
+	subscription.Status = models.SubscriptionPastDue
+	subscription.NextBillingAt = s.now().Add(s.config.RetrySchedule[subscription.FailedAttempts-1])
+	if err := s.repo.CompleteRenewal(ctx, subscription, renewal); err != nil {
+		return err
+	}
+
+	s.notify(ctx, subscription, "We could not charge your subscription",
+		fmt.Sprintf("Your subscription payment failed (%s). We will try again on %s; you can update your payment method before then.",
+			renewal.FailureReason, subscription.NextBillingAt.Format("January 2, 2006")))
+	return nil
+}
+
+// advance starts the period a successful renewal paid for
+func (s *subscriptionService) advance(subscription *models.Subscription, renewal *models.SubscriptionRenewal) {
+	subscription.Status = models.SubscriptionActive
+	subscription.FailedAttempts = 0
+	subscription.CurrentPeriodStart = renewal.PeriodStart
+	subscription.CurrentPeriodEnd = renewal.PeriodEnd
+	subscription.NextBillingAt = renewal.PeriodEnd
+	subscription.LastOrderID = renewal.OrderID
+}
+
+// resume reactivates a paused subscription. A period that ended while it was
+// paused is billed on the scheduler's next run.
+func (s *subscriptionService) resume(subscription *models.Subscription, now time.Time) {
+	subscription.Status = models.SubscriptionActive
+	if subscription.TrialEndsAt != nil && now.Before(*subscription.TrialEndsAt) {
+		subscription.Status = models.SubscriptionTrialing
+	}
+	subscription.PausedAt = nil
+	subscription.ResumeAt = nil
+	if subscription.CurrentPeriodEnd.Before(now) {
+		subscription.CurrentPeriodEnd = now
+	}
+	subscription.NextBillingAt = subscription.CurrentPeriodEnd
+}
+
+func (s *subscriptionService) cancel(subscription *models.Subscription, reason string) {
+	now := s.now()
+	subscription.Status = models.SubscriptionCancelled
+	subscription.CancelledAt = &now
+	subscription.CancellationReason = reason
+	subscription.CancelAtPeriodEnd = false
+	subscription.ResumeAt = nil
+}
+
+// notify sends a billing notice. Notices are best effort: a notification
+// failure never undoes the billing change it describes.
+func (s *subscriptionService) notify(ctx context.Context, subscription *models.Subscription, subject, body string) {
+	if s.notifier == nil {
+		return
+	}
+
+	err := s.notifier.SendNotification(ctx, &models.NotificationRequest{
+		UserID:    subscription.UserID,
+		Channel:   models.ChannelEmail,
+		Recipient: subscription.Email,
+		Subject:   &subject,
+		Body:      &body,
+		Variables: map[string]interface{}{
+			"subscription_id": subscription.ID,
+			"status":          subscription.Status,
+		},
+	})
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to send subscription notification", err, map[string]interface{}{
+			"subscription_id": subscription.ID,
+		})
+	}
+}
+
+// ownedSubscription loads a subscription of the user; other users'
+// subscriptions are reported as not found
+func (s *subscriptionService) ownedSubscription(ctx context.Context, id, userID string) (*models.Subscription, error) {
+	subscription, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID != userID {
+		return nil, fmt.Errorf("subscription not found")
+	}
+	return subscription, nil
+}
+
+// changeableSubscription loads a subscription of the user for the user to
+// change. A subscription whose renewal is being charged cannot be changed
+// until the renewal completes.
+func (s *subscriptionService) changeableSubscription(ctx context.Context, id, userID string) (*models.Subscription, error) {
+	subscription, err := s.ownedSubscription(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := s.repo.GetPendingRenewal(ctx, subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("invalid status transition: a renewal of this subscription is being charged")
+	}
+	return subscription, nil
+}
+
+// checkPaymentMethod checks that a saved payment method belongs to the user
+// and can be charged without them being present
+func (s *subscriptionService) checkPaymentMethod(ctx context.Context, userID, paymentMethodID string) error {
+	method, err := s.paymentMethods.GetPaymentMethod(ctx, paymentMethodID)
+	if err != nil {
+		return fmt.Errorf("invalid payment method: %w", err)
+	}
+	if method.UserID != userID {
+		return fmt.Errorf("invalid payment method: payment method not found")
+	}
+	if method.Type.IsStoredValue() {
+		return fmt.Errorf("invalid payment method: %s cannot pay for subscriptions", method.Type)
+	}
+	return nil
+}
+
+func (s *subscriptionService) validatePlanInput(ctx context.Context, req *SubscriptionPlanInput) error {
+	if err := validatePlanTerms(req); err != nil {
+		return err
+	}
+	if s.productService == nil {
+		return nil
+	}
+
+	if _, err := s.productService.GetProduct(ctx, req.ProductID); err != nil {
+		return fmt.Errorf("invalid subscription plan: product %s: %w", req.ProductID, err)
+	}
+	if req.VariantID != "" {
+		if _, err := s.productService.GetVariant(ctx, req.ProductID, req.VariantID); err != nil {
+			return fmt.Errorf("invalid subscription plan: variant %s: %w", req.VariantID, err)
+		}
+	}
+	return nil
+}
+
+func validatePlanTerms(req *SubscriptionPlanInput) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return fmt.Errorf("invalid subscription plan: %w", err)
+	}
+	if !req.Interval.IsValid() {
+		return fmt.Errorf("invalid subscription plan: unknown interval %q", req.Interval)
+	}
+	if req.IntervalCount < 0 || req.TrialDays < 0 {
+		return fmt.Errorf("invalid subscription plan: interval count and trial days cannot be negative")
+	}
+
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return fmt.Errorf("invalid subscription plan: %w", err)
+	}
+	if !req.Price.IsPositive() || !money.IsRounded(req.Price) {
+		return fmt.Errorf("invalid subscription plan: price must be a positive amount in %s", money.Code)
+	}
+	req.Currency = money.Code
+	return nil
+}
+
+func applyPlanInput(plan *models.SubscriptionPlan, req *SubscriptionPlanInput) {
+	plan.Name = req.Name
+	plan.Description = req.Description
+	plan.Interval = req.Interval
+	plan.IntervalCount = req.IntervalCount
+	if plan.IntervalCount == 0 {
+		plan.IntervalCount = 1
+	}
+	plan.TrialDays = req.TrialDays
+	plan.Price = req.Price
+	plan.Currency = req.Currency
+	if req.Active != nil {
+		plan.Active = *req.Active
+	}
+}
+
+// orderPaymentMethod snapshots a saved payment method onto an order
+func orderPaymentMethod(method *models.PaymentMethodInfo) models.PaymentMethod {
+	payment := models.PaymentMethod{Type: string(method.Type)}
+	if method.CardInfo != nil {
+		payment.Last4 = method.CardInfo.Last4
+		payment.Brand = method.CardInfo.Brand
+		payment.ExpiryMonth = method.CardInfo.ExpiryMonth
+		payment.ExpiryYear = method.CardInfo.ExpiryYear
+	}
+	return payment
+}
@@ -0,0 +1,716 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// MockSubscriptionRepository implements SubscriptionRepository for testing
+type MockSubscriptionRepository struct {
+	plans         map[string]models.SubscriptionPlan
+	subscriptions map[string]models.Subscription
+	renewals      []models.SubscriptionRenewal
+	// beforeBegin runs before a renewal is begun, to interleave a request with it
+	beforeBegin func()
+	// completeErr fails the next CompleteRenewal, as if the scheduler crashed after charging
+	completeErr error
+}
+
+func NewMockSubscriptionRepository() *MockSubscriptionRepository {
+	return &MockSubscriptionRepository{
+		plans:         make(map[string]models.SubscriptionPlan),
+		subscriptions: make(map[string]models.Subscription),
+	}
+}
+
+func (m *MockSubscriptionRepository) CreatePlan(ctx context.Context, plan *models.SubscriptionPlan) error {
+	m.plans[plan.ID] = *plan
+	return nil
+}
+
+func (m *MockSubscriptionRepository) UpdatePlan(ctx context.Context, plan *models.SubscriptionPlan) error {
+	if _, exists := m.plans[plan.ID]; !exists {
+		return &NotFoundError{Resource: "subscription plan", ID: plan.ID}
+	}
+	m.plans[plan.ID] = *plan
+	return nil
+}
+
+func (m *MockSubscriptionRepository) GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error) {
+	plan, exists := m.plans[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "subscription plan", ID: id}
+	}
+	return &plan, nil
+}
+
+func (m *MockSubscriptionRepository) ListPlans(ctx context.Context, productID string, activeOnly bool, limit, offset int) ([]*models.SubscriptionPlan, error) {
+	var plans []*models.SubscriptionPlan
+	for _, plan := range m.plans {
+		plan := plan
+		if (productID == "" || plan.ProductID == productID) && (plan.Active || !activeOnly) {
+			plans = append(plans, &plan)
+		}
+	}
+	return plans, nil
+}
+
+func (m *MockSubscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+	subscription.Version = 1
+	m.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+func (m *MockSubscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+	if err := m.checkVersion(subscription); err != nil {
+		return err
+	}
+	subscription.Version++
+	m.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+func (m *MockSubscriptionRepository) checkVersion(subscription *models.Subscription) error {
+	stored, exists := m.subscriptions[subscription.ID]
+	if !exists {
+		return &NotFoundError{Resource: "subscription", ID: subscription.ID}
+	}
+	if stored.Version != subscription.Version {
+		return repository.ErrSubscriptionChanged
+	}
+	return nil
+}
+
+func (m *MockSubscriptionRepository) GetByID(ctx context.Context, id string) (*models.Subscription, error) {
+	subscription, exists := m.subscriptions[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "subscription", ID: id}
+	}
+	return &subscription, nil
+}
+
+func (m *MockSubscriptionRepository) ListByUser(ctx context.Context, userID string) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	for _, subscription := range m.subscriptions {
+		subscription := subscription
+		if subscription.UserID == userID {
+			subscriptions = append(subscriptions, &subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *MockSubscriptionRepository) ClaimDue(ctx context.Context, at, leaseUntil time.Time, limit int) ([]*models.Subscription, error) {
+	var due []*models.Subscription
+	for id, subscription := range m.subscriptions {
+		switch {
+		case subscription.IsBillable() && !subscription.NextBillingAt.After(at):
+			subscription.NextBillingAt = leaseUntil
+		case subscription.Status == models.SubscriptionPaused && subscription.ResumeAt != nil && !subscription.ResumeAt.After(at):
+			subscription.ResumeAt = &leaseUntil
+		default:
+			continue
+		}
+		subscription.Version++
+		m.subscriptions[id] = subscription
+		claimed := subscription
+		due = append(due, &claimed)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due, nil
+}
+
+func (m *MockSubscriptionRepository) CreateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error {
+	m.renewals = append(m.renewals, *renewal)
+	return nil
+}
+
+func (m *MockSubscriptionRepository) BeginRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error {
+	if m.beforeBegin != nil {
+		m.beforeBegin()
+	}
+	if err := m.checkVersion(subscription); err != nil {
+		return err
+	}
+	subscription.Version++
+	stored := m.subscriptions[subscription.ID]
+	stored.Version = subscription.Version
+	m.subscriptions[subscription.ID] = stored
+	m.renewals = append(m.renewals, *renewal)
+	return nil
+}
+
+func (m *MockSubscriptionRepository) UpdateRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error {
+	for i := range m.renewals {
+		if m.renewals[i].ID == renewal.ID {
+			m.renewals[i] = *renewal
+			return nil
+		}
+	}
+	return &NotFoundError{Resource: "subscription renewal", ID: renewal.ID}
+}
+
+func (m *MockSubscriptionRepository) CompleteRenewal(ctx context.Context, subscription *models.Subscription, renewal *models.SubscriptionRenewal) error {
+	if err := m.completeErr; err != nil {
+		m.completeErr = nil
+		return err
+	}
+	if err := m.checkVersion(subscription); err != nil {
+		return err
+	}
+	if err := m.UpdateRenewal(ctx, renewal); err != nil {
+		return err
+	}
+	return m.Update(ctx, subscription)
+}
+
+func (m *MockSubscriptionRepository) GetPendingRenewal(ctx context.Context, subscriptionID string) (*models.SubscriptionRenewal, error) {
+	for _, renewal := range m.renewals {
+		if renewal.SubscriptionID == subscriptionID && renewal.Status == models.RenewalPending {
+			return &renewal, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockSubscriptionRepository) ListRenewals(ctx context.Context, subscriptionID string) ([]*models.SubscriptionRenewal, error) {
+	var renewals []*models.SubscriptionRenewal
+	for i := len(m.renewals) - 1; i >= 0; i-- {
+		if m.renewals[i].SubscriptionID == subscriptionID {
+			renewal := m.renewals[i]
+			renewals = append(renewals, &renewal)
+		}
+	}
+	return renewals, nil
+}
+
+// MockPaymentMethodService implements PaymentMethodService for testing
+type MockPaymentMethodService struct {
+	methods map[string]*models.PaymentMethodInfo
+}
+
+func (m *MockPaymentMethodService) GetPaymentMethod(ctx context.Context, id string) (*models.PaymentMethodInfo, error) {
+	method, exists := m.methods[id]
+	if !exists {
+		return nil, &NotFoundError{Resource: "payment method", ID: id}
+	}
+	return method, nil
+}
+
+// MockNotifier implements Notifier for testing
+type MockNotifier struct {
+	sent []*models.NotificationRequest
+}
+
+func (m *MockNotifier) SendNotification(ctx context.Context, req *models.NotificationRequest) error {
+	m.sent = append(m.sent, req)
+	return nil
+}
+
+// fakeClock is a settable clock for driving the subscription scheduler
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.now = t
+}
+
+type subscriptionFixture struct {
+	service   SubscriptionService
+	repo      *MockSubscriptionRepository
+	orders    *MockOrderRepository
+	payments  *MockPaymentService
+	notifier  *MockNotifier
+	clock     *fakeClock
+	inventory *MockInventoryService
+}
+
+// newSubscriptionFixture starts the clock at the end of January, so monthly
+// periods exercise month-end clamping
+func newSubscriptionFixture() *subscriptionFixture {
+	f := &subscriptionFixture{
+		repo:      NewMockSubscriptionRepository(),
+		orders:    NewMockOrderRepository(),
+		payments:  NewMockPaymentService(),
+		notifier:  &MockNotifier{},
+		clock:     &fakeClock{now: time.Date(2026, time.January, 31, 10, 0, 0, 0, time.UTC)},
+		inventory: NewMockInventoryService(),
+	}
+
+	products := NewMockProductService()
+	orders := NewOrderService(f.orders, products, f.inventory, nil, nil, nil, nil)
+	methods := &MockPaymentMethodService{methods: map[string]*models.PaymentMethodInfo{
+		"pm_card":  {ID: "pm_card", UserID: "user1", Type: models.PaymentTypeCard, CardInfo: &models.CardInfo{Last4: "4242", Brand: "visa"}},
+		"pm_new":   {ID: "pm_new", UserID: "user1", Type: models.PaymentTypeCard},
+		"pm_other": {ID: "pm_other", UserID: "user2", Type: models.PaymentTypeCard},
+	}}
+
+	service := NewSubscriptionService(f.repo, orders, products, f.payments, methods, f.notifier, DefaultSubscriptionConfig())
+	service.(*subscriptionService).now = f.clock.Now
+	f.service = service
+	return f
+}
+
+func (f *subscriptionFixture) createPlan(t *testing.T, trialDays int) *models.SubscriptionPlan {
+	plan, err := f.service.CreatePlan(context.Background(), &SubscriptionPlanInput{
+		ProductID: "prod1",
+		Name:      "Monthly refill",
+		Interval:  models.SubscriptionIntervalMonth,
+		TrialDays: trialDays,
+		Price:     decimal.NewFromInt(80),
+	})
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	return plan
+}
+
+func (f *subscriptionFixture) subscribe(t *testing.T, plan *models.SubscriptionPlan) *models.Subscription {
+	address := models.Address{Street: "123 Test St", City: "Test City", State: "TS", PostalCode: "12345", Country: "US"}
+	subscription, err := f.service.Subscribe(context.Background(), &SubscribeRequest{
+		UserID:           "user1",
+		PlanID:           plan.ID,
+		Quantity:         2,
+		Email:            "user1@example.com",
+		PaymentMethodID:  "pm_card",
+		ShippingAddress:  address,
+		BillingAddress:   address,
+		ShippingMethodID: "standard",
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	return subscription
+}
+
+func (f *subscriptionFixture) runAt(t *testing.T, at time.Time) {
+	f.clock.Set(at)
+	if _, err := f.service.RunDue(context.Background()); err != nil {
+		t.Fatalf("RunDue failed: %v", err)
+	}
+}
+
+func (f *subscriptionFixture) get(t *testing.T, id string) *models.Subscription {
+	subscription, err := f.service.GetSubscription(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSubscription failed: %v", err)
+	}
+	return subscription
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 10, 0, 0, 0, time.UTC)
+}
+
+func TestSubscriptionService_SubscribeChargesFirstPeriod(t *testing.T) {
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	if subscription.Status != models.SubscriptionActive {
+		t.Errorf("Expected active subscription, got %s", subscription.Status)
+	}
+	// January 31 plus a month ends on the last day of February
+	if !subscription.CurrentPeriodEnd.Equal(date(time.February, 28)) || !subscription.NextBillingAt.Equal(date(time.February, 28)) {
+		t.Errorf("Expected period to end on February 28, got %s", subscription.CurrentPeriodEnd)
+	}
+
+	order, err := f.orders.GetByID(context.Background(), subscription.LastOrderID)
+	if err != nil {
+		t.Fatalf("Expected renewal order: %v", err)
+	}
+	if order.Status != models.OrderConfirmed || order.Source != "subscription" || order.PaymentReference != "pay-"+order.ID {
+		t.Errorf("Unexpected renewal order: status %s, source %s, payment %s", order.Status, order.Source, order.PaymentReference)
+	}
+	item := order.Items[0]
+	if item.PriceSource != models.PriceSourceSubscription || !item.Price.Equal(decimal.NewFromInt(80)) || item.Quantity != 2 {
+		t.Errorf("Expected 2 units at the plan price, got %d at %s (%s)", item.Quantity, item.Price, item.PriceSource)
+	}
+	if order.PaymentMethod.Last4 != "4242" {
+		t.Errorf("Expected saved card on order, got %+v", order.PaymentMethod)
+	}
+	if payment := f.payments.payments["pay-"+order.ID]; payment.Status != models.PaymentCompleted {
+		t.Errorf("Expected renewal to be charged, got %s", payment.Status)
+	}
+
+	renewals, _ := f.service.ListRenewals(context.Background(), subscription.ID)
+	if len(renewals) != 1 || renewals[0].Status != models.RenewalPaid || !renewals[0].Amount.Equal(order.Total) {
+		t.Errorf("Expected one paid renewal for the order total, got %+v", renewals)
+	}
+}
+
+func TestSubscriptionService_SubscribeCancelsWhenFirstChargeFails(t *testing.T) {
+	f := newSubscriptionFixture()
+	plan := f.createPlan(t, 0)
+	f.payments.declineReason = "card_declined"
+
+	_, err := f.service.Subscribe(context.Background(), &SubscribeRequest{
+		UserID:          "user1",
+		PlanID:          plan.ID,
+		Email:           "user1@example.com",
+		PaymentMethodID: "pm_card",
+		ShippingAddress: models.Address{Country: "US"},
+		BillingAddress:  models.Address{Country: "US"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "card_declined") {
+		t.Fatalf("Expected declined charge error, got %v", err)
+	}
+
+	for _, subscription := range f.repo.subscriptions {
+		if subscription.Status != models.SubscriptionCancelled {
+			t.Errorf("Expected subscription to be cancelled, got %s", subscription.Status)
+		}
+	}
+	for _, order := range f.orders.orders {
+		if order.Status != models.OrderCancelled {
+			t.Errorf("Expected renewal order to be cancelled, got %s", order.Status)
+		}
+	}
+	if f.inventory.reserved["prod1"] != 0 {
+		t.Errorf("Expected stock to be released, got %d reserved", f.inventory.reserved["prod1"])
+	}
+	if len(f.notifier.sent) != 0 {
+		t.Errorf("Expected no dunning notices for a first charge, got %d", len(f.notifier.sent))
+	}
+}
+
+func TestSubscriptionService_TrialIsBilledWhenItEnds(t *testing.T) {
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 14))
+
+	if subscription.Status != models.SubscriptionTrialing || len(f.orders.orders) != 0 {
+		t.Fatalf("Expected an uncharged trial, got %s with %d orders", subscription.Status, len(f.orders.orders))
+	}
+	trialEnd := date(time.February, 14)
+	if subscription.TrialEndsAt == nil || !subscription.TrialEndsAt.Equal(trialEnd) || !subscription.NextBillingAt.Equal(trialEnd) {
+		t.Fatalf("Expected trial to end and be billed on %s, got %+v", trialEnd, subscription)
+	}
+
+	f.runAt(t, trialEnd.Add(-time.Minute))
+	if len(f.orders.orders) != 0 {
+		t.Fatalf("Expected no order before the trial ends")
+	}
+
+	f.runAt(t, trialEnd)
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionActive || subscription.LastOrderID == "" {
+		t.Errorf("Expected trial to convert with an order, got %s", subscription.Status)
+	}
+	if !subscription.CurrentPeriodStart.Equal(trialEnd) || !subscription.NextBillingAt.Equal(date(time.March, 14)) {
+		t.Errorf("Expected period February 14 to March 14, got %s to %s", subscription.CurrentPeriodStart, subscription.NextBillingAt)
+	}
+}
+
+func TestSubscriptionService_DunningRetriesThenCancels(t *testing.T) {
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+	f.payments.declineReason = "insufficient_funds"
+
+	retries := []time.Time{date(time.March, 1), date(time.March, 4), date(time.March, 9)}
+	f.runAt(t, date(time.February, 28))
+	for i, retryAt := range retries {
+		subscription = f.get(t, subscription.ID)
+		if subscription.Status != models.SubscriptionPastDue || subscription.FailedAttempts != i+1 {
+			t.Fatalf("Attempt %d: expected past due with %d failures, got %s with %d", i+1, i+1, subscription.Status, subscription.FailedAttempts)
+		}
+		if !subscription.NextBillingAt.Equal(retryAt) {
+			t.Fatalf("Attempt %d: expected retry at %s, got %s", i+1, retryAt, subscription.NextBillingAt)
+		}
+
+		// Nothing happens between retries
+		f.runAt(t, retryAt.Add(-time.Hour))
+		if f.get(t, subscription.ID).FailedAttempts != i+1 {
+			t.Fatalf("Attempt %d: retried before it was due", i+1)
+		}
+		f.runAt(t, retryAt)
+	}
+
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionCancelled || subscription.CancellationReason != "Renewal payment failed" {
+		t.Errorf("Expected cancellation after the last retry, got %s (%s)", subscription.Status, subscription.CancellationReason)
+	}
+
+	if len(f.notifier.sent) != 4 {
+		t.Fatalf("Expected three failure notices and a cancellation notice, got %d", len(f.notifier.sent))
+	}
+	if notice := f.notifier.sent[0]; notice.Recipient != "user1@example.com" || !strings.Contains(*notice.Body, "March 1, 2026") {
+		t.Errorf("Expected first notice to announce the retry date, got %s", *notice.Body)
+	}
+	if !strings.Contains(*f.notifier.sent[3].Subject, "cancelled") {
+		t.Errorf("Expected last notice to announce the cancellation, got %s", *f.notifier.sent[3].Subject)
+	}
+
+	renewals, _ := f.service.ListRenewals(context.Background(), subscription.ID)
+	if len(renewals) != 5 || renewals[0].Status != models.RenewalFailed || renewals[0].Attempt != 4 {
+		t.Errorf("Expected the paid first period and four failed attempts, got %d renewals", len(renewals))
+	}
+	cancelled := 0
+	for _, order := range f.orders.orders {
+		if order.Status == models.OrderCancelled {
+			cancelled++
+		}
+	}
+	if cancelled != 4 {
+		t.Errorf("Expected every unpaid renewal order to be cancelled, got %d", cancelled)
+	}
+
+	// A cancelled subscription is never billed again
+	f.runAt(t, date(time.June, 1))
+	if len(f.repo.renewals) != 5 {
+		t.Errorf("Expected no renewals after cancellation")
+	}
+}
+
+func TestSubscriptionService_DunningRecoversWithNewPaymentMethod(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	f.payments.declineReason = "card_expired"
+	f.runAt(t, date(time.February, 28))
+
+	// A new card is tried on the next run instead of waiting for the retry
+	f.payments.declineReason = ""
+	f.clock.Set(date(time.February, 28).Add(2 * time.Hour))
+	updated, err := f.service.UpdatePaymentMethod(ctx, subscription.ID, &UpdateSubscriptionPaymentRequest{UserID: "user1", PaymentMethodID: "pm_new"})
+	if err != nil {
+		t.Fatalf("UpdatePaymentMethod failed: %v", err)
+	}
+	if !updated.NextBillingAt.Equal(f.clock.Now()) {
+		t.Errorf("Expected immediate retry, got %s", updated.NextBillingAt)
+	}
+	f.runAt(t, f.clock.Now())
+
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionActive || subscription.FailedAttempts != 0 {
+		t.Errorf("Expected recovered subscription, got %s with %d failures", subscription.Status, subscription.FailedAttempts)
+	}
+	// The period keeps its original start, so the billing day does not move
+	if !subscription.CurrentPeriodStart.Equal(date(time.February, 28)) || !subscription.NextBillingAt.Equal(date(time.March, 28)) {
+		t.Errorf("Expected period February 28 to March 28, got %s to %s", subscription.CurrentPeriodStart, subscription.NextBillingAt)
+	}
+	if len(f.notifier.sent) != 2 || !strings.Contains(*f.notifier.sent[1].Subject, "went through") {
+		t.Errorf("Expected a failure notice and a recovery notice, got %d", len(f.notifier.sent))
+	}
+}
+
+func TestSubscriptionService_PauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	resumeAt := date(time.April, 10)
+	paused, err := f.service.Pause(ctx, subscription.ID, &PauseSubscriptionRequest{UserID: "user1", ResumeAt: &resumeAt})
+	if err != nil || paused.Status != models.SubscriptionPaused {
+		t.Fatalf("Expected paused subscription, got %+v, error %v", paused, err)
+	}
+
+	// Periods that end while paused are not billed
+	f.runAt(t, date(time.March, 28))
+	if len(f.orders.orders) != 1 {
+		t.Fatalf("Expected no renewal while paused, got %d orders", len(f.orders.orders))
+	}
+
+	// The scheduler resumes it, and the next run bills the new period
+	f.runAt(t, resumeAt)
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionActive || subscription.PausedAt != nil || !subscription.NextBillingAt.Equal(resumeAt) {
+		t.Fatalf("Expected resumed subscription due at %s, got %s due at %s", resumeAt, subscription.Status, subscription.NextBillingAt)
+	}
+	f.runAt(t, resumeAt)
+	subscription = f.get(t, subscription.ID)
+	if len(f.orders.orders) != 2 || !subscription.NextBillingAt.Equal(date(time.May, 10)) {
+		t.Errorf("Expected a renewal starting on resume, got %d orders and next billing %s", len(f.orders.orders), subscription.NextBillingAt)
+	}
+
+	if _, err := f.service.Resume(ctx, subscription.ID, &SubscriptionActionRequest{UserID: "user1"}); err == nil || !strings.Contains(err.Error(), "invalid status transition") {
+		t.Errorf("Expected resuming an active subscription to fail, got %v", err)
+	}
+}
+
+func TestSubscriptionService_SkipNextPeriod(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	skipped, err := f.service.Skip(ctx, subscription.ID, &SubscriptionActionRequest{UserID: "user1"})
+	if err != nil {
+		t.Fatalf("Skip failed: %v", err)
+	}
+	if !skipped.NextBillingAt.Equal(date(time.March, 28)) {
+		t.Errorf("Expected next billing on March 28, got %s", skipped.NextBillingAt)
+	}
+
+	f.runAt(t, date(time.February, 28))
+	if len(f.orders.orders) != 1 {
+		t.Errorf("Expected the skipped period not to be ordered, got %d orders", len(f.orders.orders))
+	}
+	f.runAt(t, date(time.March, 28))
+	if len(f.orders.orders) != 2 {
+		t.Errorf("Expected the period after the skip to be ordered, got %d orders", len(f.orders.orders))
+	}
+
+	renewals, _ := f.service.ListRenewals(ctx, subscription.ID)
+	if len(renewals) != 3 || renewals[1].Status != models.RenewalSkipped || !renewals[1].PeriodStart.Equal(date(time.February, 28)) {
+		t.Errorf("Expected the skipped period to be recorded, got %d renewals", len(renewals))
+	}
+}
+
+func TestSubscriptionService_CancelAtPeriodEnd(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	cancelled, err := f.service.Cancel(ctx, subscription.ID, &CancelSubscriptionRequest{UserID: "user1", Reason: "Moving", AtPeriodEnd: true})
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if cancelled.Status != models.SubscriptionActive || !cancelled.CancelAtPeriodEnd {
+		t.Fatalf("Expected subscription to run to the end of its period, got %s", cancelled.Status)
+	}
+
+	f.runAt(t, date(time.February, 28))
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionCancelled || subscription.CancellationReason != "Moving" || subscription.CancelledAt == nil {
+		t.Errorf("Expected cancellation at period end, got %s (%s)", subscription.Status, subscription.CancellationReason)
+	}
+	if len(f.orders.orders) != 1 {
+		t.Errorf("Expected no renewal after cancelling, got %d orders", len(f.orders.orders))
+	}
+
+	if _, err := f.service.Cancel(ctx, subscription.ID, &CancelSubscriptionRequest{UserID: "user1"}); err == nil {
+		t.Errorf("Expected cancelling twice to fail")
+	}
+}
+
+func TestSubscriptionService_ResumeUndoesPendingCancellation(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	if _, err := f.service.Cancel(ctx, subscription.ID, &CancelSubscriptionRequest{UserID: "user1", AtPeriodEnd: true}); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if _, err := f.service.Resume(ctx, subscription.ID, &SubscriptionActionRequest{UserID: "user1"}); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	f.runAt(t, date(time.February, 28))
+	if subscription = f.get(t, subscription.ID); subscription.Status != models.SubscriptionActive || len(f.orders.orders) != 2 {
+		t.Errorf("Expected subscription to renew, got %s with %d orders", subscription.Status, len(f.orders.orders))
+	}
+}
+
+func TestSubscriptionService_CancelDuringClaimIsNotOverwritten(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	// The customer cancels after the scheduler has claimed the subscription
+	// but before it starts billing, leaving the scheduler a stale copy
+	f.repo.beforeBegin = func() {
+		f.repo.beforeBegin = nil
+		if _, err := f.service.Cancel(ctx, subscription.ID, &CancelSubscriptionRequest{UserID: "user1", Reason: "Moving"}); err != nil {
+			t.Fatalf("Cancel failed: %v", err)
+		}
+	}
+	f.runAt(t, date(time.February, 28))
+
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionCancelled || subscription.CancellationReason != "Moving" {
+		t.Errorf("Expected the cancellation to stand, got %s (%s)", subscription.Status, subscription.CancellationReason)
+	}
+	if len(f.orders.orders) != 1 || len(f.payments.payments) != 1 {
+		t.Errorf("Expected the cancelled subscription not to be charged, got %d orders and %d payments", len(f.orders.orders), len(f.payments.payments))
+	}
+}
+
+func TestSubscriptionService_InterruptedRenewalIsResumedWithoutChargingTwice(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	subscription := f.subscribe(t, f.createPlan(t, 0))
+
+	f.repo.completeErr = errors.New("connection reset")
+	f.runAt(t, date(time.February, 28))
+	if len(f.payments.payments) != 2 {
+		t.Fatalf("Expected the renewal to be charged, got %d payments", len(f.payments.payments))
+	}
+
+	// The customer cannot change the subscription under the pending renewal
+	if _, err := f.service.Cancel(ctx, subscription.ID, &CancelSubscriptionRequest{UserID: "user1"}); err == nil || !strings.Contains(err.Error(), "being charged") {
+		t.Errorf("Expected cancelling during a renewal to be refused, got %v", err)
+	}
+
+	f.runAt(t, date(time.February, 28).Add(time.Hour))
+	subscription = f.get(t, subscription.ID)
+	if subscription.Status != models.SubscriptionActive || !subscription.NextBillingAt.Equal(date(time.March, 28)) {
+		t.Errorf("Expected the renewal to complete, got %s billed next at %s", subscription.Status, subscription.NextBillingAt)
+	}
+	if len(f.orders.orders) != 2 || len(f.payments.payments) != 2 {
+		t.Errorf("Expected one order and payment for the renewal, got %d orders and %d payments", len(f.orders.orders), len(f.payments.payments))
+	}
+
+	renewals, _ := f.service.ListRenewals(ctx, subscription.ID)
+	if len(renewals) != 2 || renewals[0].Status != models.RenewalPaid || renewals[0].OrderID != subscription.LastOrderID {
+		t.Errorf("Expected the resumed renewal to be paid, got %+v", renewals)
+	}
+}
+
+func TestSubscriptionService_Validation(t *testing.T) {
+	ctx := context.Background()
+	f := newSubscriptionFixture()
+	plan := f.createPlan(t, 0)
+	subscription := f.subscribe(t, plan)
+
+	if _, err := f.service.CreatePlan(ctx, &SubscriptionPlanInput{ProductID: "prod1", Name: "Bad", Interval: "fortnight", Price: decimal.NewFromInt(10)}); err == nil {
+		t.Errorf("Expected unknown interval to be rejected")
+	}
+	if _, err := f.service.CreatePlan(ctx, &SubscriptionPlanInput{ProductID: "missing", Name: "Bad", Interval: models.SubscriptionIntervalWeek, Price: decimal.NewFromInt(10)}); err == nil {
+		t.Errorf("Expected unknown product to be rejected")
+	}
+	if _, err := f.service.CreatePlan(ctx, &SubscriptionPlanInput{ProductID: "prod1", Name: "Bad", Interval: models.SubscriptionIntervalWeek, Price: decimal.NewFromFloat(10.5), Currency: "JPY"}); err == nil {
+		t.Errorf("Expected fractional yen price to be rejected")
+	}
+
+	// Other customers' subscriptions and payment methods are off limits
+	if _, err := f.service.Pause(ctx, subscription.ID, &PauseSubscriptionRequest{UserID: "user2"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected another user's subscription to be hidden, got %v", err)
+	}
+	if _, err := f.service.UpdatePaymentMethod(ctx, subscription.ID, &UpdateSubscriptionPaymentRequest{UserID: "user1", PaymentMethodID: "pm_other"}); err == nil {
+		t.Errorf("Expected another user's payment method to be rejected")
+	}
+
+	inactive := false
+	if _, err := f.service.UpdatePlan(ctx, plan.ID, &SubscriptionPlanInput{Name: plan.Name, Interval: plan.Interval, Price: plan.Price, Active: &inactive}); err != nil {
+		t.Fatalf("UpdatePlan failed: %v", err)
+	}
+	_, err := f.service.Subscribe(ctx, &SubscribeRequest{
+		UserID: "user1", PlanID: plan.ID, Email: "user1@example.com", PaymentMethodID: "pm_card",
+		ShippingAddress: models.Address{Country: "US"}, BillingAddress: models.Address{Country: "US"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no longer offered") {
+		t.Errorf("Expected inactive plan to be rejected, got %v", err)
+	}
+
+	// Existing subscribers keep renewing on a retired plan
+	f.runAt(t, date(time.February, 28))
+	if len(f.orders.orders) != 2 {
+		t.Errorf("Expected existing subscription to renew, got %d orders", len(f.orders.orders))
+	}
+}
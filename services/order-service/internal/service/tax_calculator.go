@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+)
+
+// TaxCalculator computes the tax owed on an order
+type TaxCalculator interface {
+	Calculate(ctx context.Context, req *TaxRequest) (*TaxResult, error)
+}
+
+// TaxRequest describes what is being taxed and where it ships to. Amounts
+// are in Currency, which defaults to the store currency.
+type TaxRequest struct {
+	Currency string
+	Address  models.Address
+	Lines    []TaxableLine
+	Shipping decimal.Decimal
+}
+
+// TaxableLine is one order line; Amount is the line total at catalog prices
+type TaxableLine struct {
+	TaxClass string
+	Amount   decimal.Decimal
+}
+
+// LineTax is the tax on one line. Included is the part of Tax that the line
+// amount already contains because of tax-inclusive rules.
+type LineTax struct {
+	Tax       decimal.Decimal
+	Included  decimal.Decimal
+	Breakdown []models.TaxLine
+}
+
+// TaxResult holds the tax per line, in request order, and for shipping
+type TaxResult struct {
+	Lines    []LineTax
+	Shipping LineTax
+	Tax      decimal.Decimal
+	Included decimal.Decimal
+}
+
+// TaxRuleSource provides the active rules that may apply in a country
+type TaxRuleSource interface {
+	ListApplicable(ctx context.Context, country string) ([]models.TaxRule, error)
+}
+
+// StaticTaxRules is a fixed rule set, used when no rule store is configured
+type StaticTaxRules []models.TaxRule
+
+// ListApplicable returns the active rules for the country or for every country
+func (r StaticTaxRules) ListApplicable(ctx context.Context, country string) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	for _, rule := range r {
+		if rule.Active && (rule.Country == "" || strings.EqualFold(rule.Country, country)) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// ruleTaxCalculator is the built-in rule engine. Every rule matching the
+// shipping address and the line's tax class applies, so country, state and
+// local rates stack. Within one jurisdiction a rule naming the line's tax
+// class replaces the rules that apply to every class.
+type ruleTaxCalculator struct {
+	rules TaxRuleSource
+}
+
+// NewRuleTaxCalculator creates a tax calculator driven by tax rules
+func NewRuleTaxCalculator(rules TaxRuleSource) TaxCalculator {
+	return &ruleTaxCalculator{rules: rules}
+}
+
+// DefaultTaxCalculator charges the flat 10% sales tax orders used before tax
+// rules existed
+func DefaultTaxCalculator() TaxCalculator {
+	return NewRuleTaxCalculator(StaticTaxRules{
+		{ID: "default", Name: "Sales tax", Rate: decimal.NewFromFloat(0.10), Active: true},
+	})
+}
+
+// Calculate applies the matching rules to every line and to shipping
+func (c *ruleTaxCalculator) Calculate(ctx context.Context, req *TaxRequest) (*TaxResult, error) {
+	money, err := resolveCurrency(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := c.rules.ListApplicable(ctx, req.Address.Country)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tax rules: %w", err)
+	}
+
+	// Narrow the country's rules down to the state and postal code
+	var local []models.TaxRule
+	for _, rule := range rules {
+		if matchesAddress(rule, req.Address) {
+			local = append(local, rule)
+		}
+	}
+
+	result := &TaxResult{Lines: make([]LineTax, len(req.Lines))}
+	for i, line := range req.Lines {
+		taxClass := line.TaxClass
+		if taxClass == "" {
+			taxClass = models.TaxClassStandard
+		}
+		result.Lines[i] = applyTaxRules(selectTaxRules(local, taxClass, false), line.Amount, money)
+		result.Tax = result.Tax.Add(result.Lines[i].Tax)
+		result.Included = result.Included.Add(result.Lines[i].Included)
+	}
+
+	if req.Shipping.IsPositive() {
+		result.Shipping = applyTaxRules(selectTaxRules(local, models.TaxClassShipping, true), req.Shipping, money)
+		result.Tax = result.Tax.Add(result.Shipping.Tax)
+		result.Included = result.Included.Add(result.Shipping.Included)
+	}
+
+	return result, nil
+}
+
+func matchesAddress(rule models.TaxRule, address models.Address) bool {
+	if rule.Country != "" && !strings.EqualFold(rule.Country, address.Country) {
+		return false
+	}
+	if rule.State != "" && !strings.EqualFold(rule.State, address.State) {
+		return false
+	}
+	postalCode := strings.ToUpper(strings.ReplaceAll(address.PostalCode, " ", ""))
+	prefix := strings.ToUpper(strings.ReplaceAll(rule.PostalCodePrefix, " ", ""))
+	return strings.HasPrefix(postalCode, prefix)
+}
+
+type taxJurisdiction struct {
+	country, state, postalCodePrefix string
+}
+
+// selectTaxRules picks the rules for a tax class, letting class-specific rules
+// override the catch-all rule of the same jurisdiction
+func selectTaxRules(rules []models.TaxRule, taxClass string, shipping bool) []models.TaxRule {
+	var candidates []models.TaxRule
+	specific := make(map[taxJurisdiction]bool)
+	for _, rule := range rules {
+		if shipping && !rule.ApplyToShipping {
+			continue
+		}
+		if rule.TaxClass != "" && !strings.EqualFold(rule.TaxClass, taxClass) {
+			continue
+		}
+		candidates = append(candidates, rule)
+		if rule.TaxClass != "" {
+			specific[jurisdictionOf(rule)] = true
+		}
+	}
+
+	var selected []models.TaxRule
+	for _, rule := range candidates {
+		if rule.TaxClass == "" && specific[jurisdictionOf(rule)] {
+			continue
+		}
+		selected = append(selected, rule)
+	}
+	return selected
+}
+
+func jurisdictionOf(rule models.TaxRule) taxJurisdiction {
+	return taxJurisdiction{
+		country:          strings.ToUpper(rule.Country),
+		state:            strings.ToUpper(rule.State),
+		postalCodePrefix: strings.ToUpper(rule.PostalCodePrefix),
+	}
+}
+
+// applyTaxRules taxes an amount. Inclusive rules are backed out of the amount
+// first, and every rule is then charged on the resulting net amount and
+// rounded to the currency's minor units.
+func applyTaxRules(rules []models.TaxRule, amount decimal.Decimal, money currency.Currency) LineTax {
+	inclusiveRate := decimal.Zero
+	for _, rule := range rules {
+		if rule.Inclusive {
+			inclusiveRate = inclusiveRate.Add(rule.Rate)
+		}
+	}
+	net := amount
+	if inclusiveRate.IsPositive() {
+		net = amount.Div(decimal.NewFromInt(1).Add(inclusiveRate))
+	}
+
+	lineTax := LineTax{Breakdown: []models.TaxLine{}}
+	for _, rule := range rules {
+		tax := money.Round(net.Mul(rule.Rate))
+		lineTax.Breakdown = append(lineTax.Breakdown, models.TaxLine{
+			RuleID:    rule.ID,
+			Name:      rule.Name,
+			Rate:      rule.Rate,
+			Amount:    tax,
+			Inclusive: rule.Inclusive,
+		})
+		lineTax.Tax = lineTax.Tax.Add(tax)
+		if rule.Inclusive {
+			lineTax.Included = lineTax.Included.Add(tax)
+		}
+	}
+	return lineTax
+}
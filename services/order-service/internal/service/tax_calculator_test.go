@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/models"
+)
+
+func taxRule(name, country, state, taxClass string, rate float64) models.TaxRule {
+	return models.TaxRule{
+		ID:       name,
+		Name:     name,
+		Country:  country,
+		State:    state,
+		TaxClass: taxClass,
+		Rate:     decimal.NewFromFloat(rate),
+		Active:   true,
+	}
+}
+
+func TestRuleTaxCalculator_StacksJurisdictions(t *testing.T) {
+	ctx := context.Background()
+	stateRule := taxRule("CA state tax", "US", "CA", "", 0.06)
+	stateRule.ApplyToShipping = true
+	countyRule := taxRule("LA county tax", "US", "CA", "", 0.0125)
+	countyRule.PostalCodePrefix = "900"
+	calculator := NewRuleTaxCalculator(StaticTaxRules{
+		stateRule,
+		countyRule,
+		taxRule("NY state tax", "US", "NY", "", 0.04),
+	})
+
+	result, err := calculator.Calculate(ctx, &TaxRequest{
+		Address:  models.Address{Country: "us", State: "CA", PostalCode: "90012"},
+		Lines:    []TaxableLine{{Amount: decimal.NewFromFloat(100)}},
+		Shipping: decimal.NewFromFloat(10),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	line := result.Lines[0]
+	if len(line.Breakdown) != 2 || !line.Tax.Equal(decimal.NewFromFloat(7.25)) {
+		t.Errorf("Expected state and county tax of 7.25, got %s from %+v", line.Tax, line.Breakdown)
+	}
+	// Only the state rule taxes shipping
+	if !result.Shipping.Tax.Equal(decimal.NewFromFloat(0.6)) {
+		t.Errorf("Expected shipping tax 0.60, got %s", result.Shipping.Tax)
+	}
+	if !result.Tax.Equal(decimal.NewFromFloat(7.85)) || !result.Included.IsZero() {
+		t.Errorf("Expected total tax 7.85 with none included, got %s (%s included)", result.Tax, result.Included)
+	}
+
+	// Outside the county only the state rule applies
+	result, _ = calculator.Calculate(ctx, &TaxRequest{
+		Address: models.Address{Country: "US", State: "CA", PostalCode: "94105"},
+		Lines:   []TaxableLine{{Amount: decimal.NewFromFloat(100)}},
+	})
+	if !result.Tax.Equal(decimal.NewFromFloat(6)) {
+		t.Errorf("Expected state tax only, got %s", result.Tax)
+	}
+}
+
+func TestRuleTaxCalculator_TaxClassOverridesCatchAll(t *testing.T) {
+	ctx := context.Background()
+	calculator := NewRuleTaxCalculator(StaticTaxRules{
+		taxRule("DE VAT", "DE", "", "", 0.19),
+		taxRule("DE reduced VAT", "DE", "", "reduced", 0.07),
+		taxRule("DE exempt", "DE", "", "exempt", 0),
+	})
+
+	result, err := calculator.Calculate(ctx, &TaxRequest{
+		Address: models.Address{Country: "DE", PostalCode: "10115"},
+		Lines: []TaxableLine{
+			{TaxClass: models.TaxClassStandard, Amount: decimal.NewFromFloat(100)},
+			{TaxClass: "reduced", Amount: decimal.NewFromFloat(100)},
+			{TaxClass: "exempt", Amount: decimal.NewFromFloat(100)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []float64{19, 7, 0}
+	for i, want := range expected {
+		if !result.Lines[i].Tax.Equal(decimal.NewFromFloat(want)) || len(result.Lines[i].Breakdown) != 1 {
+			t.Errorf("Line %d: expected a single rule charging %v, got %s from %+v",
+				i, want, result.Lines[i].Tax, result.Lines[i].Breakdown)
+		}
+	}
+}
+
+func TestRuleTaxCalculator_InclusivePricing(t *testing.T) {
+	ctx := context.Background()
+	vat := taxRule("UK VAT", "GB", "", "", 0.20)
+	vat.Inclusive = true
+	vat.ApplyToShipping = true
+	calculator := NewRuleTaxCalculator(StaticTaxRules{vat})
+
+	result, err := calculator.Calculate(ctx, &TaxRequest{
+		Address:  models.Address{Country: "GB"},
+		Lines:    []TaxableLine{{Amount: decimal.NewFromFloat(120)}},
+		Shipping: decimal.NewFromFloat(6),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 120 including 20% VAT is 100 net plus 20 tax
+	if !result.Lines[0].Tax.Equal(decimal.NewFromFloat(20)) || !result.Lines[0].Included.Equal(decimal.NewFromFloat(20)) {
+		t.Errorf("Expected 20 of included tax, got %s (%s included)", result.Lines[0].Tax, result.Lines[0].Included)
+	}
+	if !result.Tax.Equal(decimal.NewFromFloat(21)) || !result.Included.Equal(result.Tax) {
+		t.Errorf("Expected all 21 of tax to be included, got %s (%s included)", result.Tax, result.Included)
+	}
+}
+
+func TestOrderService_CalculateOrderTotals_TaxInclusiveRules(t *testing.T) {
+	ctx := context.Background()
+	vat := taxRule("UK VAT", "GB", "", "", 0.20)
+	vat.Inclusive = true
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, NewRuleTaxCalculator(StaticTaxRules{vat}), nil, nil, nil)
+
+	req := newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1})
+	req.ShippingAddress.Country = "GB"
+
+	totals, err := service.CalculateOrderTotals(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 99.99 includes 16.67 of VAT, so only shipping is added on top
+	if !totals.Tax.Equal(decimal.NewFromFloat(16.67)) || !totals.TaxIncluded.Equal(totals.Tax) {
+		t.Errorf("Expected 16.67 of included tax, got %s (%s included)", totals.Tax, totals.TaxIncluded)
+	}
+	if !totals.Total.Equal(decimal.NewFromFloat(109.99)) {
+		t.Errorf("Expected total 109.99, got %s", totals.Total)
+	}
+}
+
+func TestOrderService_CreateOrder_RecordsLineTax(t *testing.T) {
+	ctx := context.Background()
+	calculator := NewRuleTaxCalculator(StaticTaxRules{taxRule("TS state tax", "US", "TS", "", 0.05)})
+	service := NewOrderService(NewMockOrderRepository(), NewMockProductService(), nil, calculator, nil, nil, nil)
+
+	order, err := service.CreateOrder(ctx, newPricingRequest(OrderItemRequest{ProductID: "prod1", Quantity: 1}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	item := order.Items[0]
+	if !item.Tax.Equal(decimal.NewFromFloat(5)) || len(item.TaxBreakdown) != 1 || item.TaxBreakdown[0].RuleID != "TS state tax" {
+		t.Errorf("Expected line tax of 5.00 from the state rule, got %s from %+v", item.Tax, item.TaxBreakdown)
+	}
+	if item.TaxClass != models.TaxClassStandard {
+		t.Errorf("Expected standard tax class, got %q", item.TaxClass)
+	}
+	if !order.Tax.Equal(item.Tax) || !order.Total.Equal(decimal.NewFromFloat(114.99)) {
+		t.Errorf("Expected order tax 5.00 and total 114.99, got %s and %s", order.Tax, order.Total)
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/order-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// TaxRuleService manages the rules behind the tax engine
+type TaxRuleService interface {
+	CreateRule(ctx context.Context, req *TaxRuleRequest) (*models.TaxRule, error)
+	UpdateRule(ctx context.Context, id string, req *TaxRuleRequest) (*models.TaxRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	GetRule(ctx context.Context, id string) (*models.TaxRule, error)
+	ListRules(ctx context.Context) ([]*models.TaxRule, error)
+}
+
+// TaxRuleRequest creates or replaces a tax rule. Active defaults to true.
+type TaxRuleRequest struct {
+	Name             string          `json:"name" validate:"required"`
+	Country          string          `json:"country"`
+	State            string          `json:"state"`
+	PostalCodePrefix string          `json:"postal_code_prefix"`
+	TaxClass         string          `json:"tax_class"`
+	Rate             decimal.Decimal `json:"rate"`
+	Inclusive        bool            `json:"inclusive"`
+	ApplyToShipping  bool            `json:"apply_to_shipping"`
+	Active           *bool           `json:"active"`
+}
+
+// taxRuleService implements TaxRuleService
+type taxRuleService struct {
+	repo repository.TaxRuleRepository
+}
+
+// NewTaxRuleService creates a new tax rule service
+func NewTaxRuleService(repo repository.TaxRuleRepository) TaxRuleService {
+	return &taxRuleService{repo: repo}
+}
+
+// CreateRule validates and stores a new tax rule
+func (s *taxRuleService) CreateRule(ctx context.Context, req *TaxRuleRequest) (*models.TaxRule, error) {
+	if err := validateTaxRuleRequest(req); err != nil {
+		return nil, err
+	}
+
+	rule := models.NewTaxRule(req.Name, req.Rate)
+	applyTaxRuleRequest(rule, req)
+
+	if err := s.repo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Tax rule created", map[string]interface{}{
+		"rule_id": rule.ID,
+		"country": rule.Country,
+		"rate":    rule.Rate,
+	})
+
+	return rule, nil
+}
+
+// UpdateRule replaces an existing tax rule
+func (s *taxRuleService) UpdateRule(ctx context.Context, id string, req *TaxRuleRequest) (*models.TaxRule, error) {
+	if err := validateTaxRuleRequest(req); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyTaxRuleRequest(rule, req)
+
+	if err := s.repo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Tax rule updated", map[string]interface{}{
+		"rule_id": rule.ID,
+		"rate":    rule.Rate,
+		"active":  rule.Active,
+	})
+
+	return rule, nil
+}
+
+// DeleteRule removes a tax rule
+func (s *taxRuleService) DeleteRule(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("tax rule ID is required")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// GetRule retrieves a tax rule by ID
+func (s *taxRuleService) GetRule(ctx context.Context, id string) (*models.TaxRule, error) {
+	if id == "" {
+		return nil, fmt.Errorf("tax rule ID is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListRules returns every tax rule
+func (s *taxRuleService) ListRules(ctx context.Context) ([]*models.TaxRule, error) {
+	return s.repo.List(ctx)
+}
+
+func validateTaxRuleRequest(req *TaxRuleRequest) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return fmt.Errorf("invalid tax rule: %w", err)
+	}
+	if req.Rate.IsNegative() || req.Rate.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return fmt.Errorf("invalid tax rule: rate must be a fraction between 0 and 1, got %s", req.Rate)
+	}
+	if req.State != "" && req.Country == "" {
+		return fmt.Errorf("invalid tax rule: state requires a country")
+	}
+	if req.PostalCodePrefix != "" && req.Country == "" {
+		return fmt.Errorf("invalid tax rule: postal code prefix requires a country")
+	}
+	return nil
+}
+
+func applyTaxRuleRequest(rule *models.TaxRule, req *TaxRuleRequest) {
+	rule.Name = req.Name
+	rule.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+	rule.State = strings.TrimSpace(req.State)
+	rule.PostalCodePrefix = strings.TrimSpace(req.PostalCodePrefix)
+	rule.TaxClass = strings.TrimSpace(req.TaxClass)
+	rule.Rate = req.Rate
+	rule.Inclusive = req.Inclusive
+	rule.ApplyToShipping = req.ApplyToShipping
+	rule.Active = req.Active == nil || *req.Active
+}
@@ -5,11 +5,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopsphere/order-service/internal/clients"
 	"github.com/shopsphere/order-service/internal/handlers"
 	"github.com/shopsphere/order-service/internal/repository"
 	"github.com/shopsphere/order-service/internal/service"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/middleware"
 	"github.com/shopsphere/shared/utils"
 )
 
@@ -29,14 +34,89 @@ func main() {
 	}
 	defer db.Close()
 
+	// Relay outbox events to the broker; events stay queued in the outbox while
+	// Redis is unavailable and the relay starts once it connects
+	events.ConnectRedisBroker(ctx, utils.NewRedisConfig(), func(broker *events.RedisBroker) {
+		events.NewRelay(events.NewPostgresOutbox(db), broker, events.DefaultRelayConfig()).Run(ctx)
+	})
+
 	// Initialize repository
 	orderRepo := repository.NewPostgresOrderRepository(db)
 
-	// Initialize service (with nil product and inventory services for now)
-	orderService := service.NewOrderService(orderRepo, nil, nil)
+	// Product-service backs both product lookups and stock reservations
+	productClient := clients.NewProductClient(clients.DefaultConfig(getEnv("PRODUCT_SERVICE_URL", "http://localhost:8003")))
+	cartClient := clients.NewCartClient(clients.DefaultConfig(getEnv("CART_SERVICE_URL", "http://localhost:8004")))
+	paymentClient := clients.NewPaymentClient(clients.DefaultConfig(getEnv("PAYMENT_SERVICE_URL", "http://localhost:8006")))
+	shippingClient := clients.NewShippingClient(clients.DefaultConfig(getEnv("SHIPPING_SERVICE_URL", "http://localhost:8007")))
+	notificationClient := clients.NewNotificationClient(clients.DefaultConfig(getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8009")))
+
+	// Tax is computed from the rules managed under /admin/tax-rules
+	taxRuleRepo := repository.NewPostgresTaxRuleRepository(db)
+	taxCalculator := service.NewRuleTaxCalculator(taxRuleRepo)
+
+	// Exchange rates price orders in currencies items have no list price in
+	var rates service.ExchangeRates
+	if path := getEnv("FX_RATES_FILE", ""); path != "" {
+		table, err := currency.LoadRatesFile(path)
+		if err != nil {
+			utils.Logger.Fatal(ctx, "Failed to load exchange rates", err)
+		}
+		rates = table
+	}
+
+	// Coupons and automatic promotions are managed under /admin/promotions
+	promotionService := service.NewPromotionService(repository.NewPostgresPromotionRepository(db), rates)
+
+	// Order numbers come from a database sequence so replicas never collide
+	orderNumberFormat := service.DefaultOrderNumberFormat()
+	orderNumberFormat.Prefix = getEnv("ORDER_NUMBER_PREFIX", orderNumberFormat.Prefix)
+	orderNumbers := service.NewOrderNumberAllocator(repository.NewPostgresOrderNumberSequence(db), orderNumberFormat)
+
+	// Initialize services
+	orderService := service.NewOrderService(orderRepo, productClient, productClient, taxCalculator, promotionService, orderNumbers, rates)
+	checkoutService := service.NewCheckoutService(
+		repository.NewPostgresCheckoutRepository(db), orderRepo, productClient, productClient,
+		cartClient, paymentClient, shippingClient, taxCalculator, promotionService, orderNumbers, rates, service.DefaultCheckoutConfig(),
+	)
+	taxRuleService := service.NewTaxRuleService(taxRuleRepo)
+	fulfillmentService := service.NewFulfillmentService(repository.NewPostgresFulfillmentRepository(db), orderRepo, productClient)
+
+	// Returns ship back to the checkout warehouse; stock and refunds go through product- and payment-service
+	returnConfig := service.DefaultReturnConfig()
+	returnConfig.ShippingMethodID = getEnv("RETURN_SHIPPING_METHOD_ID", "")
+	returnService := service.NewReturnService(
+		repository.NewPostgresReturnRepository(db), orderRepo, shippingClient, productClient, paymentClient, returnConfig,
+	)
+
+	// Subscriptions renew through the order service and charge saved payment methods
+	subscriptionService := service.NewSubscriptionService(
+		repository.NewPostgresSubscriptionRepository(db), orderService, productClient, paymentClient, paymentClient,
+		notificationClient, service.DefaultSubscriptionConfig(),
+	)
+
+	// Compensate checkouts left half-done by a crash or restart
+	go recoverStalledCheckouts(ctx, checkoutService)
+
+	// Renew due subscriptions and retry failed renewals
+	go runSubscriptionBilling(ctx, subscriptionService)
+
+	// Idempotency keys make retried order and checkout requests safe
+	idempotencyConfig := middleware.DefaultIdempotencyConfig("order-service")
+	if ttl, err := time.ParseDuration(getEnv("IDEMPOTENCY_KEY_TTL", "")); err == nil {
+		idempotencyConfig.TTL = ttl
+	}
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(db)
+	idempotency := middleware.NewIdempotencyMiddleware(idempotencyStore, idempotencyConfig)
+	go middleware.DeleteExpiredIdempotencyKeys(ctx, idempotencyStore, time.Hour)
 
 	// Initialize handlers
 	orderHandler := handlers.NewOrderHandler(orderService)
+	checkoutHandler := handlers.NewCheckoutHandler(checkoutService)
+	taxRuleHandler := handlers.NewTaxRuleHandler(taxRuleService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	fulfillmentHandler := handlers.NewFulfillmentHandler(fulfillmentService)
+	returnHandler := handlers.NewReturnHandler(returnService)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService)
 
 	// Create router
 	router := mux.NewRouter()
@@ -45,7 +125,7 @@ func main() {
 	api := router.PathPrefix("/api/v1").Subrouter()
 
 	// Order routes
-	api.HandleFunc("/orders", orderHandler.CreateOrder).Methods("POST")
+	api.Handle("/orders", idempotency.HandlerFunc(orderHandler.CreateOrder)).Methods("POST")
 	api.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET")
 	api.HandleFunc("/orders/{id}", orderHandler.UpdateOrder).Methods("PUT")
 	api.HandleFunc("/orders/{id}/status", orderHandler.UpdateOrderStatus).Methods("PATCH")
@@ -56,15 +136,118 @@ func main() {
 	api.HandleFunc("/orders/search", orderHandler.SearchOrders).Methods("GET")
 	api.HandleFunc("/orders/validate", orderHandler.ValidateOrder).Methods("POST")
 
+	// Fulfillment routes
+	api.HandleFunc("/orders/{id}/fulfillments", fulfillmentHandler.CreateFulfillment).Methods("POST")
+	api.HandleFunc("/orders/{id}/fulfillments", fulfillmentHandler.ListFulfillments).Methods("GET")
+	api.HandleFunc("/fulfillments/{id}", fulfillmentHandler.GetFulfillment).Methods("GET")
+	api.HandleFunc("/fulfillments/{id}", fulfillmentHandler.UpdateFulfillment).Methods("PATCH")
+
+	// Return routes
+	api.HandleFunc("/orders/{id}/returns", returnHandler.RequestReturn).Methods("POST")
+	api.HandleFunc("/orders/{id}/returns", returnHandler.ListReturns).Methods("GET")
+	api.HandleFunc("/returns/{id}", returnHandler.GetReturn).Methods("GET")
+	api.HandleFunc("/returns/{id}/approve", returnHandler.ApproveReturn).Methods("POST")
+	api.HandleFunc("/returns/{id}/reject", returnHandler.RejectReturn).Methods("POST")
+	api.HandleFunc("/returns/{id}/cancel", returnHandler.CancelReturn).Methods("POST")
+	api.HandleFunc("/returns/{id}/receive", returnHandler.ReceiveReturn).Methods("POST")
+	api.HandleFunc("/returns/{id}/inspect", returnHandler.InspectReturn).Methods("POST")
+	api.HandleFunc("/returns/{id}/refund", returnHandler.RefundReturn).Methods("POST")
+
+	// Checkout routes
+	api.Handle("/checkout", idempotency.HandlerFunc(checkoutHandler.Checkout)).Methods("POST")
+	api.HandleFunc("/checkout/{id}", checkoutHandler.GetCheckout).Methods("GET")
+	api.HandleFunc("/orders/{id}/shipping-quotes", checkoutHandler.QuoteShipping).Methods("GET")
+
+	// Admin tax rule routes
+	api.HandleFunc("/admin/tax-rules", taxRuleHandler.CreateTaxRule).Methods("POST")
+	api.HandleFunc("/admin/tax-rules", taxRuleHandler.ListTaxRules).Methods("GET")
+	api.HandleFunc("/admin/tax-rules/{id}", taxRuleHandler.GetTaxRule).Methods("GET")
+	api.HandleFunc("/admin/tax-rules/{id}", taxRuleHandler.UpdateTaxRule).Methods("PUT")
+	api.HandleFunc("/admin/tax-rules/{id}", taxRuleHandler.DeleteTaxRule).Methods("DELETE")
+
+	// Promotion routes
+	api.HandleFunc("/promotions/quote", promotionHandler.QuotePromotions).Methods("POST")
+	api.HandleFunc("/admin/promotions", promotionHandler.CreatePromotion).Methods("POST")
+	api.HandleFunc("/admin/promotions", promotionHandler.ListPromotions).Methods("GET")
+	api.HandleFunc("/admin/promotions/{id}", promotionHandler.GetPromotion).Methods("GET")
+	api.HandleFunc("/admin/promotions/{id}", promotionHandler.UpdatePromotion).Methods("PUT")
+
+	// Subscription routes; subscribing charges the first period, so it is idempotent like checkout
+	api.HandleFunc("/subscription-plans", subscriptionHandler.ListPlans).Methods("GET")
+	api.HandleFunc("/subscription-plans/{id}", subscriptionHandler.GetPlan).Methods("GET")
+	api.Handle("/subscriptions", idempotency.HandlerFunc(subscriptionHandler.Subscribe)).Methods("POST")
+	api.HandleFunc("/subscriptions/user/{userId}", subscriptionHandler.ListUserSubscriptions).Methods("GET")
+	api.HandleFunc("/subscriptions/{id}", subscriptionHandler.GetSubscription).Methods("GET")
+	api.HandleFunc("/subscriptions/{id}/renewals", subscriptionHandler.ListRenewals).Methods("GET")
+	api.HandleFunc("/subscriptions/{id}/payment-method", subscriptionHandler.UpdatePaymentMethod).Methods("PUT")
+	api.HandleFunc("/subscriptions/{id}/pause", subscriptionHandler.PauseSubscription).Methods("POST")
+	api.HandleFunc("/subscriptions/{id}/resume", subscriptionHandler.ResumeSubscription).Methods("POST")
+	api.HandleFunc("/subscriptions/{id}/skip", subscriptionHandler.SkipSubscription).Methods("POST")
+	api.HandleFunc("/subscriptions/{id}/cancel", subscriptionHandler.CancelSubscription).Methods("POST")
+	api.HandleFunc("/admin/subscription-plans", subscriptionHandler.CreatePlan).Methods("POST")
+	api.HandleFunc("/admin/subscription-plans", subscriptionHandler.ListAllPlans).Methods("GET")
+	api.HandleFunc("/admin/subscription-plans/{id}", subscriptionHandler.UpdatePlan).Methods("PUT")
+
 	// Health check endpoint
 	router.HandleFunc("/health", orderHandler.HealthCheck).Methods("GET")
 
 	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8005"
-	}
+	port := getEnv("PORT", "8005")
 
 	utils.Logger.Info(ctx, "Order Service listening", nil, map[string]interface{}{"port": port})
 	log.Fatal(http.ListenAndServe(":"+port, router))
-}
\ No newline at end of file
+}
+
+// recoverStalledCheckouts periodically compensates checkouts that stopped
+// progressing and resumes those whose payment review was decided
+func recoverStalledCheckouts(ctx context.Context, checkoutService service.CheckoutService) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if recovered, err := checkoutService.RecoverStalled(ctx, 5*time.Minute); err != nil {
+			utils.Logger.Error(ctx, "Failed to recover stalled checkouts", err)
+		} else if recovered > 0 {
+			utils.Logger.Info(ctx, "Recovered stalled checkouts", map[string]interface{}{"count": recovered})
+		}
+		if resumed, err := checkoutService.ResumeReviewed(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to resume reviewed checkouts", err)
+		} else if resumed > 0 {
+			utils.Logger.Info(ctx, "Resumed reviewed checkouts", map[string]interface{}{"count": resumed})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSubscriptionBilling periodically renews the subscriptions that are due
+func runSubscriptionBilling(ctx context.Context, subscriptionService service.SubscriptionService) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if handled, err := subscriptionService.RunDue(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to run subscription billing", err)
+		} else if handled > 0 {
+			utils.Logger.Info(ctx, "Processed due subscriptions", map[string]interface{}{"count": handled})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getEnv gets an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,896 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// Test card numbers recognised by FakeGateway. They follow Stripe's test
+// cards where Stripe has one, so the same numbers work against both gateways.
+const (
+	FakeCardSuccess           = "4242424242424242"
+	FakeCardDeclined          = "4000000000000002"
+	FakeCardInsufficientFunds = "4000000000009995"
+	FakeCardRequiresAction    = "4000002500003155"
+	FakeCardDelayed           = "4000000000001000"
+)
+
+// Test amounts recognised by FakeGateway for payment methods it did not
+// issue, keyed by the last two digits of the amount in minor units (e.g.
+// 10.91 USD or 1091 JPY is declined)
+const (
+	FakeCentsDeclined          = 91
+	FakeCentsInsufficientFunds = 92
+	FakeCentsRequiresAction    = 93
+	FakeCentsDelayed           = 94
+)
+
+// fakeOutcome is what happens when a payment intent is confirmed
+type fakeOutcome string
+
+const (
+	fakeSucceed           fakeOutcome = "succeed"
+	fakeDecline           fakeOutcome = "decline"
+	fakeInsufficientFunds fakeOutcome = "insufficient_funds"
+	fakeRequireAction     fakeOutcome = "require_action"
+	fakeDelay             fakeOutcome = "delay"
+)
+
+// fakeWellKnownMethods mirrors Stripe's pm_card_* test payment methods
+var fakeWellKnownMethods = map[string]string{
+	"pm_card_visa":                            FakeCardSuccess,
+	"pm_card_chargeDeclined":                  FakeCardDeclined,
+	"pm_card_chargeDeclinedInsufficientFunds": FakeCardInsufficientFunds,
+	"pm_card_threeDSecure2Required":           FakeCardRequiresAction,
+}
+
+// FakeGatewayConfig configures the in-process fake gateway
+type FakeGatewayConfig struct {
+	// WebhookURL receives signed webhook events; when empty events are only recorded
+	WebhookURL    string
+	WebhookSecret string
+	// SettlementDelay is how long delayed payments stay processing, and
+	// refunds of them pending. Zero leaves them until Settle or SettleRefund
+	// is called.
+	SettlementDelay time.Duration
+	HTTPClient      *http.Client
+}
+
+// DefaultFakeGatewayConfig returns the default fake gateway configuration
+func DefaultFakeGatewayConfig() FakeGatewayConfig {
+	return FakeGatewayConfig{
+		WebhookSecret:   "whsec_fake",
+		SettlementDelay: 5 * time.Second,
+		HTTPClient:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type fakePaymentIntent struct {
+	id               string
+	amount           int64
+	amountCapturable int64
+	amountReceived   int64
+	amountRefunded   int64
+	currency         string
+	status           string
+	captureMethod    string
+	paymentMethodID  string
+	customerID       string
+	description      string
+	metadata         map[string]interface{}
+	clientSecret     string
+	outcome          fakeOutcome
+	lastError        map[string]interface{}
+	created          time.Time
+}
+
+type fakeRefund struct {
+	id            string
+	intent        *fakePaymentIntent
+	amount        int64
+	reason        string
+	status        string
+	failureReason string
+	metadata      map[string]interface{}
+	created       time.Time
+}
+
+type fakePaymentMethod struct {
+	id         string
+	number     string
+	card       *CardInfo
+	customerID string
+	created    time.Time
+}
+
+// FakeGateway is an in-memory PaymentGateway for development, staging and
+// tests. Outcomes are decided by the card number or, failing that, the amount,
+// and every state change is announced with a Stripe-style signed webhook.
+type FakeGateway struct {
+	config FakeGatewayConfig
+
+	mu             sync.Mutex
+	intents        map[string]*fakePaymentIntent
+	refunds        map[string]*fakeRefund
+	paymentMethods map[string]*fakePaymentMethod
+	customers      map[string]*CustomerResult
+	events         []*WebhookEvent
+
+	deliveries sync.WaitGroup
+}
+
+// NewFakeGateway creates a new fake payment gateway
+func NewFakeGateway(config FakeGatewayConfig) *FakeGateway {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &FakeGateway{
+		config:         config,
+		intents:        make(map[string]*fakePaymentIntent),
+		refunds:        make(map[string]*fakeRefund),
+		paymentMethods: make(map[string]*fakePaymentMethod),
+		customers:      make(map[string]*CustomerResult),
+	}
+}
+
+// CreatePaymentIntent creates a payment intent awaiting confirmation
+func (g *FakeGateway) CreatePaymentIntent(ctx context.Context, req *CreatePaymentIntentRequest) (*models.PaymentIntent, error) {
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("failed to create payment intent: amount must be positive")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	money, err := currency.Lookup(req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment intent: %w", err)
+	}
+
+	pi := &fakePaymentIntent{
+		id:              fakeID("pi"),
+		amount:          money.ToMinorUnits(req.Amount),
+		currency:        strings.ToLower(money.Code),
+		status:          "requires_confirmation",
+		captureMethod:   "manual",
+		paymentMethodID: req.PaymentMethodID,
+		customerID:      req.CustomerID,
+		description:     req.Description,
+		metadata:        req.Metadata,
+		created:         time.Now(),
+	}
+	if req.AutomaticCapture {
+		pi.captureMethod = "automatic"
+	}
+	if pi.paymentMethodID == "" {
+		pi.status = "requires_payment_method"
+	}
+	pi.clientSecret = pi.id + "_secret_" + randomToken()
+	pi.outcome = g.outcomeFor(pi)
+	g.intents[pi.id] = pi
+
+	g.emit("payment_intent.created", pi.object())
+
+	return &models.PaymentIntent{
+		ID:                 pi.id,
+		Amount:             fromMinorUnits(pi.amount, pi.currency),
+		Currency:           pi.currency,
+		PaymentMethodID:    pi.paymentMethodID,
+		CustomerID:         pi.customerID,
+		Description:        pi.description,
+		Metadata:           pi.metadata,
+		ConfirmationMethod: "manual",
+		Status:             pi.status,
+		ClientSecret:       pi.clientSecret,
+		CreatedAt:          pi.created,
+	}, nil
+}
+
+// ConfirmPayment confirms a payment intent, applying its test outcome
+func (g *FakeGateway) ConfirmPayment(ctx context.Context, paymentIntentID string) (*PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to confirm payment: no such payment_intent: %s", paymentIntentID)
+	}
+	if pi.status != "requires_confirmation" && pi.status != "requires_payment_method" {
+		return nil, fmt.Errorf("failed to confirm payment: payment_intent %s has status %s", pi.id, pi.status)
+	}
+
+	switch pi.outcome {
+	case fakeDecline:
+		g.fail(pi, "card_declined", "generic_decline", "Your card was declined.")
+		return nil, fmt.Errorf("failed to confirm payment: card_declined: Your card was declined.")
+	case fakeInsufficientFunds:
+		g.fail(pi, "card_declined", "insufficient_funds", "Your card has insufficient funds.")
+		return nil, fmt.Errorf("failed to confirm payment: card_declined: Your card has insufficient funds.")
+	case fakeRequireAction:
+		pi.status = "requires_action"
+		g.emit("payment_intent.requires_action", pi.object())
+	case fakeDelay:
+		pi.status = "processing"
+		g.emit("payment_intent.processing", pi.object())
+		if g.config.SettlementDelay > 0 {
+			time.AfterFunc(g.config.SettlementDelay, func() {
+				_ = g.Settle(pi.id)
+			})
+		}
+	default:
+		g.authorize(pi)
+	}
+
+	return pi.result(), nil
+}
+
+// CapturePayment captures part or, with a zero amount, all of what is left of
+// an authorization. Captures stay open for more until one is final or nothing
+// remains, like Stripe's multicapture.
+func (g *FakeGateway) CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal, currencyCode string, final bool) (*PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to capture payment: no such payment_intent: %s", paymentIntentID)
+	}
+	if pi.status != "requires_capture" {
+		return nil, fmt.Errorf("failed to capture payment: payment_intent %s has status %s", pi.id, pi.status)
+	}
+
+	toCapture := pi.amountCapturable
+	if !amount.IsZero() {
+		if !strings.EqualFold(currencyCode, pi.currency) {
+			return nil, fmt.Errorf("failed to capture payment: currency %s does not match payment_intent currency %s", currencyCode, pi.currency)
+		}
+		toCapture = toMinorUnits(amount, pi.currency)
+	}
+	if toCapture <= 0 || toCapture > pi.amountCapturable {
+		return nil, fmt.Errorf("failed to capture payment: amount to capture exceeds capturable amount")
+	}
+
+	pi.amountReceived += toCapture
+	pi.amountCapturable -= toCapture
+	if !final && pi.amountCapturable > 0 {
+		g.emit("payment_intent.amount_capturable_updated", pi.object())
+		return pi.result(), nil
+	}
+
+	pi.amountCapturable = 0
+	pi.status = "succeeded"
+	g.emit("payment_intent.succeeded", pi.object())
+
+	return pi.result(), nil
+}
+
+// GetPaymentIntent returns the current state of a payment intent
+func (g *FakeGateway) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get payment intent: no such payment_intent: %s", paymentIntentID)
+	}
+	return pi.result(), nil
+}
+
+// CancelPaymentIntent voids a payment intent that has not been fully captured.
+// Whatever was already captured stays captured.
+func (g *FakeGateway) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to cancel payment intent: no such payment_intent: %s", paymentIntentID)
+	}
+
+	switch pi.status {
+	case "requires_payment_method", "requires_confirmation", "requires_action", "requires_capture":
+	default:
+		return nil, fmt.Errorf("failed to cancel payment intent: payment_intent %s has status %s", pi.id, pi.status)
+	}
+
+	pi.amountCapturable = 0
+	if pi.amountReceived > 0 {
+		pi.status = "succeeded"
+		g.emit("payment_intent.succeeded", pi.object())
+	} else {
+		pi.status = "canceled"
+		g.emit("payment_intent.canceled", pi.object())
+	}
+
+	return pi.result(), nil
+}
+
+// CompleteAction simulates the customer finishing (or failing) 3-D Secure
+// authentication for a payment intent in requires_action
+func (g *FakeGateway) CompleteAction(paymentIntentID string, authenticated bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return fmt.Errorf("no such payment_intent: %s", paymentIntentID)
+	}
+	if pi.status != "requires_action" {
+		return fmt.Errorf("payment_intent %s has status %s", pi.id, pi.status)
+	}
+
+	if !authenticated {
+		g.fail(pi, "payment_intent_authentication_failure", "", "The provided payment method has failed authentication.")
+		return nil
+	}
+	g.authorize(pi)
+	return nil
+}
+
+// Settle completes a delayed payment intent that is still processing
+func (g *FakeGateway) Settle(paymentIntentID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[paymentIntentID]
+	if !ok {
+		return fmt.Errorf("no such payment_intent: %s", paymentIntentID)
+	}
+	if pi.status != "processing" {
+		return fmt.Errorf("payment_intent %s has status %s", pi.id, pi.status)
+	}
+
+	g.authorize(pi)
+	return nil
+}
+
+// CreatePaymentMethod tokenizes a card
+func (g *FakeGateway) CreatePaymentMethod(ctx context.Context, req *CreatePaymentMethodRequest) (*PaymentMethodResult, error) {
+	if req.Type != "card" || req.Card == nil {
+		return nil, fmt.Errorf("failed to create payment method: unsupported type %q", req.Type)
+	}
+
+	number := strings.ReplaceAll(req.Card.Number, " ", "")
+	if !luhnValid(number) {
+		return nil, fmt.Errorf("failed to create payment method: incorrect_number: Your card number is incorrect.")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pm := &fakePaymentMethod{
+		id:     fakeID("pm"),
+		number: number,
+		card: &CardInfo{
+			Brand:       cardBrand(number),
+			Last4:       number[len(number)-4:],
+			ExpMonth:    req.Card.ExpMonth,
+			ExpYear:     req.Card.ExpYear,
+			Fingerprint: cardFingerprint(number),
+		},
+		created: time.Now(),
+	}
+	g.paymentMethods[pm.id] = pm
+
+	return pm.result(), nil
+}
+
+// AttachPaymentMethodToCustomer attaches a payment method to a customer
+func (g *FakeGateway) AttachPaymentMethodToCustomer(ctx context.Context, paymentMethodID, customerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pm, ok := g.paymentMethods[paymentMethodID]
+	if !ok {
+		return fmt.Errorf("failed to attach payment method: no such payment_method: %s", paymentMethodID)
+	}
+	if _, ok := g.customers[customerID]; !ok {
+		return fmt.Errorf("failed to attach payment method: no such customer: %s", customerID)
+	}
+
+	pm.customerID = customerID
+	g.emit("payment_method.attached", pm.object())
+	return nil
+}
+
+// DetachPaymentMethod detaches a payment method from its customer
+func (g *FakeGateway) DetachPaymentMethod(ctx context.Context, paymentMethodID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pm, ok := g.paymentMethods[paymentMethodID]
+	if !ok {
+		return fmt.Errorf("failed to detach payment method: no such payment_method: %s", paymentMethodID)
+	}
+
+	pm.customerID = ""
+	g.emit("payment_method.detached", pm.object())
+	return nil
+}
+
+// CreateCustomer creates a customer
+func (g *FakeGateway) CreateCustomer(ctx context.Context, req *CreateCustomerRequest) (*CustomerResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cust := &CustomerResult{
+		ID:          fakeID("cus"),
+		Email:       req.Email,
+		Name:        req.Name,
+		Phone:       req.Phone,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		CreatedAt:   time.Now(),
+	}
+	g.customers[cust.ID] = cust
+
+	copied := *cust
+	return &copied, nil
+}
+
+// GetCustomer retrieves a customer
+func (g *FakeGateway) GetCustomer(ctx context.Context, customerID string) (*CustomerResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cust, ok := g.customers[customerID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get customer: no such customer: %s", customerID)
+	}
+
+	copied := *cust
+	return &copied, nil
+}
+
+// CreateRefund refunds part or all of a captured payment intent
+func (g *FakeGateway) CreateRefund(ctx context.Context, req *CreateRefundRequest) (*RefundResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pi, ok := g.intents[req.PaymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to create refund: no such payment_intent: %s", req.PaymentIntentID)
+	}
+	if pi.amountReceived == 0 {
+		return nil, fmt.Errorf("failed to create refund: payment_intent %s has no captured funds", pi.id)
+	}
+
+	remaining := pi.amountReceived - pi.amountRefunded
+	amount := remaining
+	if !req.Amount.IsZero() {
+		amount = toMinorUnits(req.Amount, pi.currency)
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, fmt.Errorf("failed to create refund: amount exceeds the unrefunded amount of the charge")
+	}
+	pi.amountRefunded += amount
+
+	// Refunds of delayed payments settle later, like bank debits do
+	refund := &fakeRefund{
+		id:       fakeID("re"),
+		intent:   pi,
+		amount:   amount,
+		reason:   req.Reason,
+		status:   "succeeded",
+		metadata: req.Metadata,
+		created:  time.Now(),
+	}
+	if pi.outcome == fakeDelay {
+		refund.status = "pending"
+		if g.config.SettlementDelay > 0 {
+			time.AfterFunc(g.config.SettlementDelay, func() {
+				_ = g.SettleRefund(refund.id, true)
+			})
+		}
+	}
+	g.refunds[refund.id] = refund
+
+	g.emit("refund.created", refund.object())
+	if refund.status == "succeeded" {
+		g.emit("charge.refunded", pi.charge())
+	}
+
+	return refund.result(), nil
+}
+
+// SettleRefund completes a pending refund, or fails it and returns the funds
+// to the charge
+func (g *FakeGateway) SettleRefund(refundID string, succeeded bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	refund, ok := g.refunds[refundID]
+	if !ok {
+		return fmt.Errorf("no such refund: %s", refundID)
+	}
+	if refund.status != "pending" {
+		return fmt.Errorf("refund %s has status %s", refund.id, refund.status)
+	}
+
+	if !succeeded {
+		refund.status = "failed"
+		refund.failureReason = "expired_or_canceled_card"
+		refund.intent.amountRefunded -= refund.amount
+		g.emit("refund.failed", refund.object())
+		return nil
+	}
+
+	refund.status = "succeeded"
+	g.emit("refund.updated", refund.object())
+	g.emit("charge.refunded", refund.intent.charge())
+	return nil
+}
+
+// VerifyWebhookSignature verifies a Stripe-Signature header, falling back to
+// the configured secret when none is given
+func (g *FakeGateway) VerifyWebhookSignature(payload []byte, signature, secret string) error {
+	if secret == "" {
+		secret = g.config.WebhookSecret
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("failed to verify webhook signature: malformed header")
+	}
+
+	expected := signPayload(payload, timestamp, secret)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to verify webhook signature: no matching signature")
+}
+
+// ParseWebhookEvent parses a webhook event payload
+func (g *FakeGateway) ParseWebhookEvent(payload []byte) (*WebhookEvent, error) {
+	var event struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Created int64  `json:"created"`
+		Data    struct {
+			Object map[string]interface{} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook event: %w", err)
+	}
+
+	return &WebhookEvent{
+		ID:      event.ID,
+		Type:    event.Type,
+		Data:    event.Data.Object,
+		Created: time.Unix(event.Created, 0),
+	}, nil
+}
+
+// Events returns the webhook events emitted so far, oldest first
+func (g *FakeGateway) Events() []*WebhookEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	events := make([]*WebhookEvent, len(g.events))
+	copy(events, g.events)
+	return events
+}
+
+// Flush waits for in-flight webhook deliveries to finish
+func (g *FakeGateway) Flush() {
+	g.deliveries.Wait()
+}
+
+// outcomeFor picks the confirmation outcome from the card, then the amount
+func (g *FakeGateway) outcomeFor(pi *fakePaymentIntent) fakeOutcome {
+	number, ok := fakeWellKnownMethods[pi.paymentMethodID]
+	if pm, issued := g.paymentMethods[pi.paymentMethodID]; issued {
+		number, ok = pm.number, true
+	}
+
+	if ok {
+		switch number {
+		case FakeCardDeclined:
+			return fakeDecline
+		case FakeCardInsufficientFunds:
+			return fakeInsufficientFunds
+		case FakeCardRequiresAction:
+			return fakeRequireAction
+		case FakeCardDelayed:
+			return fakeDelay
+		}
+		return fakeSucceed
+	}
+
+	switch pi.amount % 100 {
+	case FakeCentsDeclined:
+		return fakeDecline
+	case FakeCentsInsufficientFunds:
+		return fakeInsufficientFunds
+	case FakeCentsRequiresAction:
+		return fakeRequireAction
+	case FakeCentsDelayed:
+		return fakeDelay
+	}
+	return fakeSucceed
+}
+
+// authorize moves a payment intent to requires_capture or, for automatic
+// capture, straight to succeeded
+func (g *FakeGateway) authorize(pi *fakePaymentIntent) {
+	pi.lastError = nil
+	if pi.captureMethod == "automatic" {
+		pi.amountReceived = pi.amount
+		pi.status = "succeeded"
+		g.emit("payment_intent.succeeded", pi.object())
+		return
+	}
+
+	pi.amountCapturable = pi.amount
+	pi.status = "requires_capture"
+	g.emit("payment_intent.amount_capturable_updated", pi.object())
+}
+
+func (g *FakeGateway) fail(pi *fakePaymentIntent, code, declineCode, message string) {
+	pi.status = "requires_payment_method"
+	pi.lastError = map[string]interface{}{
+		"type":    "card_error",
+		"code":    code,
+		"message": message,
+	}
+	if declineCode != "" {
+		pi.lastError["decline_code"] = declineCode
+	}
+	g.emit("payment_intent.payment_failed", pi.object())
+}
+
+// emit records a webhook event and delivers it asynchronously, like the real
+// gateway would. Callers must hold g.mu.
+func (g *FakeGateway) emit(eventType string, object map[string]interface{}) {
+	created := time.Now()
+	event := &WebhookEvent{
+		ID:      fakeID("evt"),
+		Type:    eventType,
+		Data:    object,
+		Created: created,
+	}
+	g.events = append(g.events, event)
+
+	if g.config.WebhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":               event.ID,
+		"object":           "event",
+		"api_version":      stripe.APIVersion,
+		"created":          created.Unix(),
+		"type":             eventType,
+		"livemode":         false,
+		"pending_webhooks": 1,
+		"data":             map[string]interface{}{"object": object},
+		"request":          map[string]interface{}{"id": nil, "idempotency_key": nil},
+	})
+	if err != nil {
+		return
+	}
+
+	g.deliveries.Add(1)
+	go func() {
+		defer g.deliveries.Done()
+		g.deliver(payload, created)
+	}()
+}
+
+func (g *FakeGateway) deliver(payload []byte, created time.Time) {
+	timestamp := strconv.FormatInt(created.Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, g.config.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", "t="+timestamp+",v1="+signPayload(payload, timestamp, g.config.WebhookSecret))
+
+	resp, err := g.config.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func (pi *fakePaymentIntent) object() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 pi.id,
+		"object":             "payment_intent",
+		"amount":             pi.amount,
+		"amount_capturable":  pi.amountCapturable,
+		"amount_received":    pi.amountReceived,
+		"currency":           pi.currency,
+		"status":             pi.status,
+		"capture_method":     pi.captureMethod,
+		"payment_method":     pi.paymentMethodID,
+		"customer":           pi.customerID,
+		"description":        pi.description,
+		"metadata":           pi.metadata,
+		"last_payment_error": pi.lastError,
+		"created":            pi.created.Unix(),
+	}
+}
+
+func (r *fakeRefund) object() map[string]interface{} {
+	object := map[string]interface{}{
+		"id":             r.id,
+		"object":         "refund",
+		"amount":         r.amount,
+		"currency":       r.intent.currency,
+		"payment_intent": r.intent.id,
+		"reason":         r.reason,
+		"status":         r.status,
+		"metadata":       r.metadata,
+		"created":        r.created.Unix(),
+	}
+	if r.failureReason != "" {
+		object["failure_reason"] = r.failureReason
+	}
+	return object
+}
+
+func (r *fakeRefund) result() *RefundResult {
+	return &RefundResult{
+		ID:              r.id,
+		PaymentIntentID: r.intent.id,
+		Amount:          fromMinorUnits(r.amount, r.intent.currency),
+		Currency:        r.intent.currency,
+		Status:          r.status,
+		Reason:          r.reason,
+		CreatedAt:       r.created,
+	}
+}
+
+func (pi *fakePaymentIntent) charge() map[string]interface{} {
+	return map[string]interface{}{
+		"id":              "ch_" + strings.TrimPrefix(pi.id, "pi_"),
+		"object":          "charge",
+		"amount":          pi.amountReceived,
+		"amount_captured": pi.amountReceived,
+		"amount_refunded": pi.amountRefunded,
+		"currency":        pi.currency,
+		"payment_intent":  pi.id,
+		"refunded":        pi.amountRefunded == pi.amountReceived,
+		"status":          "succeeded",
+		"created":         pi.created.Unix(),
+	}
+}
+
+func (pi *fakePaymentIntent) result() *PaymentResult {
+	result := &PaymentResult{
+		ID:              pi.id,
+		Status:          pi.status,
+		Amount:          fromMinorUnits(pi.amount, pi.currency),
+		AmountCaptured:  fromMinorUnits(pi.amountReceived, pi.currency),
+		Currency:        pi.currency,
+		PaymentMethodID: pi.paymentMethodID,
+		Metadata:        pi.metadata,
+		CreatedAt:       pi.created,
+	}
+	if pi.status == "requires_action" {
+		result.ClientSecret = pi.clientSecret
+	}
+	return result
+}
+
+func (pm *fakePaymentMethod) object() map[string]interface{} {
+	return map[string]interface{}{
+		"id":       pm.id,
+		"object":   "payment_method",
+		"type":     "card",
+		"customer": pm.customerID,
+		"card": map[string]interface{}{
+			"brand":       pm.card.Brand,
+			"last4":       pm.card.Last4,
+			"exp_month":   pm.card.ExpMonth,
+			"exp_year":    pm.card.ExpYear,
+			"fingerprint": pm.card.Fingerprint,
+		},
+		"created": pm.created.Unix(),
+	}
+}
+
+func (pm *fakePaymentMethod) result() *PaymentMethodResult {
+	card := *pm.card
+	return &PaymentMethodResult{
+		ID:         pm.id,
+		Type:       "card",
+		Card:       &card,
+		CustomerID: pm.customerID,
+		CreatedAt:  pm.created,
+	}
+}
+
+// signPayload computes a Stripe v1 webhook signature
+func signPayload(payload []byte, timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func fakeID(prefix string) string {
+	return prefix + "_" + randomToken()
+}
+
+func randomToken() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+}
+
+
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "5"), strings.HasPrefix(number, "2"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	case strings.HasPrefix(number, "6"):
+		return "discover"
+	default:
+		return "unknown"
+	}
+}
+
+func cardFingerprint(number string) string {
+	sum := sha256.Sum256([]byte(number))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func luhnValid(number string) bool {
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		c := number[len(number)-1-i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,387 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+func newTestFakeGateway() *FakeGateway {
+	config := DefaultFakeGatewayConfig()
+	config.SettlementDelay = 0
+	return NewFakeGateway(config)
+}
+
+func createFakeIntent(t *testing.T, g *FakeGateway, paymentMethodID, amount string, automaticCapture bool) string {
+	pi, err := g.CreatePaymentIntent(context.Background(), &CreatePaymentIntentRequest{
+		Amount:           decimal.RequireFromString(amount),
+		Currency:         "USD",
+		PaymentMethodID:  paymentMethodID,
+		AutomaticCapture: automaticCapture,
+	})
+	require.NoError(t, err)
+	return pi.ID
+}
+
+func createFakeCard(t *testing.T, g *FakeGateway, number string) string {
+	pm, err := g.CreatePaymentMethod(context.Background(), &CreatePaymentMethodRequest{
+		Type: "card",
+		Card: &CardDetails{Number: number, ExpMonth: 12, ExpYear: 2030, CVC: "123"},
+	})
+	require.NoError(t, err)
+	return pm.ID
+}
+
+func eventTypes(g *FakeGateway) []string {
+	var types []string
+	for _, event := range g.Events() {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestFakeGateway_ConfirmByCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		card       string
+		wantStatus string
+		wantErr    string
+		wantEvent  string
+	}{
+		{"success", FakeCardSuccess, "succeeded", "", "payment_intent.succeeded"},
+		{"declined", FakeCardDeclined, "", "Your card was declined", "payment_intent.payment_failed"},
+		{"insufficient funds", FakeCardInsufficientFunds, "", "insufficient funds", "payment_intent.payment_failed"},
+		{"requires action", FakeCardRequiresAction, "requires_action", "", "payment_intent.requires_action"},
+		{"delayed", FakeCardDelayed, "processing", "", "payment_intent.processing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestFakeGateway()
+			// Amount triggers must not override an issued card
+			id := createFakeIntent(t, g, createFakeCard(t, g, tt.card), "10.91", true)
+
+			result, err := g.ConfirmPayment(context.Background(), id)
+
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, result.Status)
+			}
+			types := eventTypes(g)
+			assert.Equal(t, tt.wantEvent, types[len(types)-1])
+		})
+	}
+}
+
+func TestFakeGateway_ConfirmByAmount(t *testing.T) {
+	tests := []struct {
+		amount     string
+		wantStatus string
+		wantErr    bool
+	}{
+		{"25.00", "succeeded", false},
+		{"25.91", "", true},
+		{"25.92", "", true},
+		{"25.93", "requires_action", false},
+		{"25.94", "processing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			g := newTestFakeGateway()
+			// A payment method the fake did not issue falls back to the amount
+			id := createFakeIntent(t, g, "pm_unknown", tt.amount, true)
+
+			result, err := g.ConfirmPayment(context.Background(), id)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, result.Status)
+		})
+	}
+}
+
+func TestFakeGateway_WellKnownPaymentMethods(t *testing.T) {
+	g := newTestFakeGateway()
+
+	id := createFakeIntent(t, g, "pm_card_chargeDeclinedInsufficientFunds", "10.00", true)
+	_, err := g.ConfirmPayment(context.Background(), id)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient funds")
+}
+
+func TestFakeGateway_CompleteAction(t *testing.T) {
+	g := newTestFakeGateway()
+	ctx := context.Background()
+
+	approved := createFakeIntent(t, g, "pm_card_threeDSecure2Required", "40.00", false)
+	result, err := g.ConfirmPayment(ctx, approved)
+	require.NoError(t, err)
+	assert.Equal(t, "requires_action", result.Status)
+	assert.NotEmpty(t, result.ClientSecret)
+
+	require.NoError(t, g.CompleteAction(approved, true))
+	captured, err := g.CapturePayment(ctx, approved, decimal.Zero, "USD", true)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", captured.Status)
+
+	rejected := createFakeIntent(t, g, "pm_card_threeDSecure2Required", "40.00", false)
+	_, err = g.ConfirmPayment(ctx, rejected)
+	require.NoError(t, err)
+	require.NoError(t, g.CompleteAction(rejected, false))
+	_, err = g.CapturePayment(ctx, rejected, decimal.Zero, "USD", true)
+	assert.Error(t, err)
+
+	// Only intents waiting on the customer can complete an action
+	assert.Error(t, g.CompleteAction(approved, true))
+}
+
+func TestFakeGateway_Settle(t *testing.T) {
+	g := newTestFakeGateway()
+
+	id := createFakeIntent(t, g, createFakeCard(t, g, FakeCardDelayed), "15.00", true)
+	_, err := g.ConfirmPayment(context.Background(), id)
+	require.NoError(t, err)
+
+	require.NoError(t, g.Settle(id))
+	assert.Error(t, g.Settle(id))
+
+	types := eventTypes(g)
+	assert.Equal(t, "payment_intent.succeeded", types[len(types)-1])
+}
+
+func TestFakeGateway_CaptureAndRefund(t *testing.T) {
+	g := newTestFakeGateway()
+	ctx := context.Background()
+
+	id := createFakeIntent(t, g, "pm_card_visa", "100.00", false)
+	result, err := g.ConfirmPayment(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "requires_capture", result.Status)
+
+	_, err = g.CapturePayment(ctx, id, decimal.NewFromInt(150), "USD", true)
+	assert.Error(t, err)
+
+	_, err = g.CapturePayment(ctx, id, decimal.NewFromInt(80), "USD", true)
+	require.NoError(t, err)
+
+	refund, err := g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(50)})
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", refund.Status)
+	assert.True(t, decimal.NewFromInt(50).Equal(refund.Amount))
+
+	// Only the captured 80.00 can be refunded
+	_, err = g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(40)})
+	assert.Error(t, err)
+
+	rest, err := g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(30).Equal(rest.Amount))
+}
+
+func TestFakeGateway_MinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+		capture  string
+		minor    int64
+	}{
+		{"USD", "12.34", "10.00", 1234},
+		{"JPY", "1500", "1000", 1500},
+		{"KWD", "12.345", "10.005", 12345},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			g := newTestFakeGateway()
+			ctx := context.Background()
+
+			pi, err := g.CreatePaymentIntent(ctx, &CreatePaymentIntentRequest{
+				Amount:          decimal.RequireFromString(tt.amount),
+				Currency:        tt.currency,
+				PaymentMethodID: "pm_card_visa",
+			})
+			require.NoError(t, err)
+			assert.True(t, decimal.RequireFromString(tt.amount).Equal(pi.Amount))
+			assert.Equal(t, tt.minor, g.Events()[0].Data["amount"])
+
+			_, err = g.ConfirmPayment(ctx, pi.ID)
+			require.NoError(t, err)
+
+			captured, err := g.CapturePayment(ctx, pi.ID, decimal.RequireFromString(tt.capture), tt.currency, true)
+			require.NoError(t, err)
+			assert.True(t, decimal.RequireFromString(tt.capture).Equal(captured.AmountCaptured))
+		})
+	}
+
+	_, err := newTestFakeGateway().CreatePaymentIntent(context.Background(), &CreatePaymentIntentRequest{
+		Amount:   decimal.NewFromInt(10),
+		Currency: "XYZ",
+	})
+	assert.Error(t, err)
+}
+
+func TestFakeGateway_MultipleCaptures(t *testing.T) {
+	g := newTestFakeGateway()
+	ctx := context.Background()
+
+	id := createFakeIntent(t, g, "pm_card_visa", "100.00", false)
+	_, err := g.ConfirmPayment(ctx, id)
+	require.NoError(t, err)
+
+	first, err := g.CapturePayment(ctx, id, decimal.NewFromInt(30), "USD", false)
+	require.NoError(t, err)
+	assert.Equal(t, "requires_capture", first.Status)
+	assert.True(t, decimal.NewFromInt(30).Equal(first.AmountCaptured))
+
+	// Captured funds can be refunded while the rest is still held
+	_, err = g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(10)})
+	require.NoError(t, err)
+
+	second, err := g.CapturePayment(ctx, id, decimal.NewFromInt(50), "USD", true)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", second.Status)
+	assert.True(t, decimal.NewFromInt(80).Equal(second.AmountCaptured))
+
+	_, err = g.CapturePayment(ctx, id, decimal.NewFromInt(20), "USD", true)
+	assert.Error(t, err)
+}
+
+func TestFakeGateway_CancelPaymentIntent(t *testing.T) {
+	g := newTestFakeGateway()
+	ctx := context.Background()
+
+	voided := createFakeIntent(t, g, "pm_card_visa", "100.00", false)
+	_, err := g.ConfirmPayment(ctx, voided)
+	require.NoError(t, err)
+
+	result, err := g.CancelPaymentIntent(ctx, voided)
+	require.NoError(t, err)
+	assert.Equal(t, "canceled", result.Status)
+	_, err = g.CapturePayment(ctx, voided, decimal.Zero, "USD", true)
+	assert.Error(t, err)
+
+	// Cancelling a partly captured intent releases only the remainder
+	partial := createFakeIntent(t, g, "pm_card_visa", "100.00", false)
+	_, err = g.ConfirmPayment(ctx, partial)
+	require.NoError(t, err)
+	_, err = g.CapturePayment(ctx, partial, decimal.NewFromInt(40), "USD", false)
+	require.NoError(t, err)
+
+	result, err = g.CancelPaymentIntent(ctx, partial)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", result.Status)
+	assert.True(t, decimal.NewFromInt(40).Equal(result.AmountCaptured))
+}
+
+func TestFakeGateway_PendingRefunds(t *testing.T) {
+	g := newTestFakeGateway()
+	ctx := context.Background()
+
+	id := createFakeIntent(t, g, createFakeCard(t, g, FakeCardDelayed), "60.00", true)
+	_, err := g.ConfirmPayment(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, g.Settle(id))
+
+	failed, err := g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(60)})
+	require.NoError(t, err)
+	assert.Equal(t, "pending", failed.Status)
+
+	// Pending refunds hold their amount until they settle
+	_, err = g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(1)})
+	assert.Error(t, err)
+
+	require.NoError(t, g.SettleRefund(failed.ID, false))
+	assert.Error(t, g.SettleRefund(failed.ID, true))
+	events := g.Events()
+	last := events[len(events)-1]
+	assert.Equal(t, "refund.failed", last.Type)
+	assert.Equal(t, "expired_or_canceled_card", last.Data["failure_reason"])
+
+	// A failed refund returns the funds to the charge
+	refund, err := g.CreateRefund(ctx, &CreateRefundRequest{PaymentIntentID: id, Amount: decimal.NewFromInt(25)})
+	require.NoError(t, err)
+	require.NoError(t, g.SettleRefund(refund.ID, true))
+
+	types := eventTypes(g)
+	assert.Equal(t, []string{"refund.updated", "charge.refunded"}, types[len(types)-2:])
+}
+
+func TestFakeGateway_CreatePaymentMethod(t *testing.T) {
+	g := newTestFakeGateway()
+
+	pm, err := g.CreatePaymentMethod(context.Background(), &CreatePaymentMethodRequest{
+		Type: "card",
+		Card: &CardDetails{Number: "5555 5555 5555 4444", ExpMonth: 1, ExpYear: 2031, CVC: "123"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "mastercard", pm.Card.Brand)
+	assert.Equal(t, "4444", pm.Card.Last4)
+	assert.NotEmpty(t, pm.Card.Fingerprint)
+
+	_, err = g.CreatePaymentMethod(context.Background(), &CreatePaymentMethodRequest{
+		Type: "card",
+		Card: &CardDetails{Number: "4242424242424241", ExpMonth: 1, ExpYear: 2031, CVC: "123"},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "incorrect_number")
+}
+
+func TestFakeGateway_DeliversSignedWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := DefaultFakeGatewayConfig()
+	config.WebhookURL = server.URL
+	config.WebhookSecret = "whsec_test"
+	g := NewFakeGateway(config)
+
+	id := createFakeIntent(t, g, "pm_card_visa", "20.00", true)
+	_, err := g.ConfirmPayment(context.Background(), id)
+	require.NoError(t, err)
+	g.Flush()
+
+	require.Len(t, received, 2)
+	for i, r := range received {
+		signature := r.Header.Get("Stripe-Signature")
+
+		assert.NoError(t, g.VerifyWebhookSignature(bodies[i], signature, "whsec_test"))
+		assert.Error(t, g.VerifyWebhookSignature(bodies[i], signature, "whsec_other"))
+
+		// The payloads are real enough for the Stripe SDK to accept
+		event, err := webhook.ConstructEvent(bodies[i], signature, "whsec_test")
+		require.NoError(t, err)
+
+		parsed, err := g.ParseWebhookEvent(bodies[i])
+		require.NoError(t, err)
+		assert.Equal(t, event.ID, parsed.ID)
+		assert.Equal(t, string(event.Type), parsed.Type)
+		assert.Equal(t, id, parsed.Data["id"])
+	}
+}
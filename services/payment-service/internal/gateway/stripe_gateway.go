@@ -2,10 +2,13 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/customer"
@@ -20,7 +23,14 @@ type PaymentGateway interface {
 	// Payment processing
 	CreatePaymentIntent(ctx context.Context, req *CreatePaymentIntentRequest) (*models.PaymentIntent, error)
 	ConfirmPayment(ctx context.Context, paymentIntentID string) (*PaymentResult, error)
-	CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal) (*PaymentResult, error)
+	// CapturePayment captures amount (zero for everything left) of an authorization
+	// in the payment's currency; unless final is set the rest stays authorized for
+	// further captures
+	CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal, currency string, final bool) (*PaymentResult, error)
+	// CancelPaymentIntent voids an uncaptured payment intent, releasing the authorization
+	CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error)
+	// GetPaymentIntent retrieves the current state of a payment intent
+	GetPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error)
 	
 	// Payment methods
 	CreatePaymentMethod(ctx context.Context, req *CreatePaymentMethodRequest) (*PaymentMethodResult, error)
@@ -76,6 +86,7 @@ type CreateCustomerRequest struct {
 type CreateRefundRequest struct {
 	PaymentIntentID string          `json:"payment_intent_id"`
 	Amount          decimal.Decimal `json:"amount,omitempty"`
+	Currency        string          `json:"currency"` // of the payment, to express Amount in minor units
 	Reason          string          `json:"reason,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -84,6 +95,7 @@ type PaymentResult struct {
 	ID              string                 `json:"id"`
 	Status          string                 `json:"status"`
 	Amount          decimal.Decimal        `json:"amount"`
+	AmountCaptured  decimal.Decimal        `json:"amount_captured"`
 	Currency        string                 `json:"currency"`
 	PaymentMethodID string                 `json:"payment_method_id"`
 	ClientSecret    string                 `json:"client_secret,omitempty"`
@@ -151,12 +163,15 @@ func NewStripeGateway(secretKey, webhookSecret string) PaymentGateway {
 
 // CreatePaymentIntent creates a payment intent in Stripe
 func (s *StripeGateway) CreatePaymentIntent(ctx context.Context, req *CreatePaymentIntentRequest) (*models.PaymentIntent, error) {
-	// Convert decimal amount to cents (Stripe expects integer cents)
-	amountCents := req.Amount.Mul(decimal.NewFromInt(100)).IntPart()
+	// Stripe expects the amount as an integer count of the currency's minor units
+	money, err := currency.Lookup(req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment intent: %w", err)
+	}
 
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(amountCents),
-		Currency: stripe.String(req.Currency),
+		Amount:   stripe.Int64(money.ToMinorUnits(req.Amount)),
+		Currency: stripe.String(strings.ToLower(money.Code)),
 	}
 
 	if req.CustomerID != "" {
@@ -181,6 +196,12 @@ func (s *StripeGateway) CreatePaymentIntent(ctx context.Context, req *CreatePaym
 		params.CaptureMethod = stripe.String("automatic")
 	} else {
 		params.CaptureMethod = stripe.String("manual")
+		// Split shipments are captured one by one
+		params.PaymentMethodOptions = &stripe.PaymentIntentPaymentMethodOptionsParams{
+			Card: &stripe.PaymentIntentPaymentMethodOptionsCardParams{
+				RequestMulticapture: stripe.String("if_available"),
+			},
+		}
 	}
 
 	// Add metadata
@@ -198,7 +219,7 @@ func (s *StripeGateway) CreatePaymentIntent(ctx context.Context, req *CreatePaym
 
 	return &models.PaymentIntent{
 		ID:                pi.ID,
-		Amount:            decimal.NewFromInt(pi.Amount).Div(decimal.NewFromInt(100)),
+		Amount:            fromMinorUnits(pi.Amount, string(pi.Currency)),
 		Currency:          string(pi.Currency),
 		PaymentMethodID:   getStringValue(pi.PaymentMethod),
 		CustomerID:        getStringValue(pi.Customer),
@@ -222,7 +243,7 @@ func (s *StripeGateway) ConfirmPayment(ctx context.Context, paymentIntentID stri
 	return &PaymentResult{
 		ID:              pi.ID,
 		Status:          string(pi.Status),
-		Amount:          decimal.NewFromInt(pi.Amount).Div(decimal.NewFromInt(100)),
+		Amount:          fromMinorUnits(pi.Amount, string(pi.Currency)),
 		Currency:        string(pi.Currency),
 		PaymentMethodID: getStringValue(pi.PaymentMethod),
 		ClientSecret:    pi.ClientSecret,
@@ -231,12 +252,17 @@ func (s *StripeGateway) ConfirmPayment(ctx context.Context, paymentIntentID stri
 }
 
 // CapturePayment captures a payment intent
-func (s *StripeGateway) CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal) (*PaymentResult, error) {
-	params := &stripe.PaymentIntentCaptureParams{}
-	
+func (s *StripeGateway) CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal, currencyCode string, final bool) (*PaymentResult, error) {
+	params := &stripe.PaymentIntentCaptureParams{
+		FinalCapture: stripe.Bool(final),
+	}
+
 	if !amount.IsZero() {
-		amountCents := amount.Mul(decimal.NewFromInt(100)).IntPart()
-		params.AmountToCapture = stripe.Int64(amountCents)
+		money, err := currency.Lookup(currencyCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture payment: %w", err)
+		}
+		params.AmountToCapture = stripe.Int64(money.ToMinorUnits(amount))
 	}
 
 	pi, err := paymentintent.Capture(paymentIntentID, params)
@@ -247,7 +273,44 @@ func (s *StripeGateway) CapturePayment(ctx context.Context, paymentIntentID stri
 	return &PaymentResult{
 		ID:              pi.ID,
 		Status:          string(pi.Status),
-		Amount:          decimal.NewFromInt(pi.Amount).Div(decimal.NewFromInt(100)),
+		Amount:          fromMinorUnits(pi.Amount, string(pi.Currency)),
+		AmountCaptured:  fromMinorUnits(pi.AmountReceived, string(pi.Currency)),
+		Currency:        string(pi.Currency),
+		PaymentMethodID: getStringValue(pi.PaymentMethod),
+		CreatedAt:       time.Unix(pi.Created, 0),
+	}, nil
+}
+
+// GetPaymentIntent retrieves a payment intent
+func (s *StripeGateway) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error) {
+	pi, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment intent: %w", err)
+	}
+
+	return &PaymentResult{
+		ID:              pi.ID,
+		Status:          string(pi.Status),
+		Amount:          fromMinorUnits(pi.Amount, string(pi.Currency)),
+		AmountCaptured:  fromMinorUnits(pi.AmountReceived, string(pi.Currency)),
+		Currency:        string(pi.Currency),
+		PaymentMethodID: getStringValue(pi.PaymentMethod),
+		CreatedAt:       time.Unix(pi.Created, 0),
+	}, nil
+}
+
+// CancelPaymentIntent cancels a payment intent, voiding its authorization
+func (s *StripeGateway) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentResult, error) {
+	pi, err := paymentintent.Cancel(paymentIntentID, &stripe.PaymentIntentCancelParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel payment intent: %w", err)
+	}
+
+	return &PaymentResult{
+		ID:              pi.ID,
+		Status:          string(pi.Status),
+		Amount:          fromMinorUnits(pi.Amount, string(pi.Currency)),
+		AmountCaptured:  fromMinorUnits(pi.AmountReceived, string(pi.Currency)),
 		Currency:        string(pi.Currency),
 		PaymentMethodID: getStringValue(pi.PaymentMethod),
 		CreatedAt:       time.Unix(pi.Created, 0),
@@ -382,8 +445,11 @@ func (s *StripeGateway) CreateRefund(ctx context.Context, req *CreateRefundReque
 	}
 
 	if !req.Amount.IsZero() {
-		amountCents := req.Amount.Mul(decimal.NewFromInt(100)).IntPart()
-		params.Amount = stripe.Int64(amountCents)
+		money, err := currency.Lookup(req.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create refund: %w", err)
+		}
+		params.Amount = stripe.Int64(money.ToMinorUnits(req.Amount))
 	}
 
 	if req.Reason != "" {
@@ -405,7 +471,7 @@ func (s *StripeGateway) CreateRefund(ctx context.Context, req *CreateRefundReque
 	return &RefundResult{
 		ID:              r.ID,
 		PaymentIntentID: getStringValue(r.PaymentIntent),
-		Amount:          decimal.NewFromInt(r.Amount).Div(decimal.NewFromInt(100)),
+		Amount:          fromMinorUnits(r.Amount, string(r.Currency)),
 		Currency:        string(r.Currency),
 		Status:          string(r.Status),
 		Reason:          getStringValue(r.Reason),
@@ -413,21 +479,29 @@ func (s *StripeGateway) CreateRefund(ctx context.Context, req *CreateRefundReque
 	}, nil
 }
 
-// VerifyWebhookSignature verifies a Stripe webhook signature
+// VerifyWebhookSignature verifies a Stripe webhook signature, falling back to
+// the configured endpoint secret when none is given
 func (s *StripeGateway) VerifyWebhookSignature(payload []byte, signature, secret string) error {
-	_, err := webhook.ConstructEvent(payload, signature, secret)
-	if err != nil {
+	if secret == "" {
+		secret = s.webhookSecret
+	}
+
+	if err := webhook.ValidatePayload(payload, signature, secret); err != nil {
 		return fmt.Errorf("failed to verify webhook signature: %w", err)
 	}
 	return nil
 }
 
-// ParseWebhookEvent parses a Stripe webhook event
+// ParseWebhookEvent parses a Stripe webhook event. The signature is checked
+// separately by VerifyWebhookSignature.
 func (s *StripeGateway) ParseWebhookEvent(payload []byte) (*WebhookEvent, error) {
-	event, err := webhook.ConstructEvent(payload, "", s.webhookSecret)
-	if err != nil {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
 		return nil, fmt.Errorf("failed to parse webhook event: %w", err)
 	}
+	if event.Data == nil {
+		return nil, fmt.Errorf("failed to parse webhook event: missing data")
+	}
 
 	return &WebhookEvent{
 		ID:      event.ID,
@@ -470,3 +544,17 @@ func getStringValue(s interface{}) string {
 		return fmt.Sprintf("%v", s)
 	}
 }
+
+// toMinorUnits converts an amount to the integer count of minor units that
+// gateways work in. Currencies are checked when a payment intent is created,
+// so an unknown code here is treated as having no minor unit.
+func toMinorUnits(amount decimal.Decimal, currencyCode string) int64 {
+	money, _ := currency.Lookup(currencyCode)
+	return money.ToMinorUnits(amount)
+}
+
+// fromMinorUnits converts a gateway's integer amount back to a decimal one
+func fromMinorUnits(amount int64, currencyCode string) decimal.Decimal {
+	money, _ := currency.Lookup(currencyCode)
+	return money.FromMinorUnits(amount)
+}
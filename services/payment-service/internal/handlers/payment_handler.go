@@ -9,29 +9,36 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/shared/middleware"
 	"github.com/shopsphere/shared/utils"
 )
 
 // PaymentHandler handles HTTP requests for payment operations
 type PaymentHandler struct {
-	service service.PaymentService
+	service     service.PaymentService
+	idempotency *middleware.IdempotencyMiddleware
 }
 
-// NewPaymentHandler creates a new payment handler
-func NewPaymentHandler(service service.PaymentService) *PaymentHandler {
+// NewPaymentHandler creates a new payment handler. Payment creation, payment
+// processing and refunds honour Idempotency-Key headers through idempotency.
+func NewPaymentHandler(service service.PaymentService, idempotency *middleware.IdempotencyMiddleware) *PaymentHandler {
 	return &PaymentHandler{
-		service: service,
+		service:     service,
+		idempotency: idempotency,
 	}
 }
 
 // RegisterRoutes registers payment routes
 func (h *PaymentHandler) RegisterRoutes(router *mux.Router) {
 	// Payment routes
-	router.HandleFunc("/payments", h.CreatePayment).Methods("POST")
+	router.Handle("/payments", h.idempotency.HandlerFunc(h.CreatePayment)).Methods("POST")
 	router.HandleFunc("/payments/{id}", h.GetPayment).Methods("GET")
-	router.HandleFunc("/payments/{id}/process", h.ProcessPayment).Methods("POST")
+	router.Handle("/payments/{id}/process", h.idempotency.HandlerFunc(h.ProcessPayment)).Methods("POST")
 	router.HandleFunc("/payments/{id}/cancel", h.CancelPayment).Methods("POST")
 	router.HandleFunc("/payments/{id}/retry", h.RetryPayment).Methods("POST")
+	router.HandleFunc("/payments/{id}/capture", h.CapturePayment).Methods("POST")
+	router.HandleFunc("/payments/{id}/captures", h.GetPaymentCaptures).Methods("GET")
+	router.HandleFunc("/payments/{id}/void", h.VoidPayment).Methods("POST")
 	router.HandleFunc("/orders/{order_id}/payments", h.GetPaymentsByOrder).Methods("GET")
 	router.HandleFunc("/users/{user_id}/payments", h.GetUserPayments).Methods("GET")
 
@@ -44,12 +51,16 @@ func (h *PaymentHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/users/{user_id}/payment-methods/{method_id}/default", h.SetDefaultPaymentMethod).Methods("POST")
 
 	// Refund routes
-	router.HandleFunc("/refunds", h.CreateRefund).Methods("POST")
+	router.Handle("/refunds", h.idempotency.HandlerFunc(h.CreateRefund)).Methods("POST")
 	router.HandleFunc("/refunds/{id}", h.GetRefund).Methods("GET")
 	router.HandleFunc("/payments/{payment_id}/refunds", h.GetPaymentRefunds).Methods("GET")
 
 	// Webhook routes
 	router.HandleFunc("/webhooks/stripe", h.HandleStripeWebhook).Methods("POST")
+
+	// Admin routes
+	router.HandleFunc("/admin/webhooks/unprocessed", h.GetUnprocessedWebhooks).Methods("GET")
+	router.HandleFunc("/admin/webhooks/replay", h.ReplayWebhooks).Methods("POST")
 }
 
 // CreatePayment creates a new payment
@@ -140,6 +151,82 @@ func (h *PaymentHandler) CancelPayment(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CapturePayment captures part or all of an authorized payment
+func (h *PaymentHandler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+	paymentID := vars["id"]
+
+	var req service.CapturePaymentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+	}
+
+	payment, err := h.service.CapturePayment(ctx, paymentID, &req)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to capture payment", err, map[string]interface{}{
+			"payment_id": paymentID,
+			"amount":     req.Amount,
+		})
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "PAYMENT_CAPTURE_FAILED", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, payment)
+}
+
+// GetPaymentCaptures retrieves the captures of a payment
+func (h *PaymentHandler) GetPaymentCaptures(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+	paymentID := vars["id"]
+
+	captures, err := h.service.GetPaymentCaptures(ctx, paymentID)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to get payment captures", err, map[string]interface{}{
+			"payment_id": paymentID,
+		})
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "CAPTURES_RETRIEVAL_FAILED", "Failed to retrieve captures")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"captures": captures,
+	})
+}
+
+// VoidPayment releases the uncaptured part of an authorized payment
+func (h *PaymentHandler) VoidPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+	paymentID := vars["id"]
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+	}
+
+	payment, err := h.service.VoidPayment(ctx, paymentID, req.Reason)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to void payment", err, map[string]interface{}{
+			"payment_id": paymentID,
+			"reason":     req.Reason,
+		})
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "PAYMENT_VOID_FAILED", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, payment)
+}
+
 // RetryPayment retries a failed payment
 func (h *PaymentHandler) RetryPayment(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
@@ -441,3 +528,45 @@ func (h *PaymentHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 		"message": "Webhook processed successfully",
 	})
 }
+
+// GetUnprocessedWebhooks lists webhooks that have not been applied yet
+func (h *PaymentHandler) GetUnprocessedWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	webhooks, err := h.service.GetUnprocessedWebhooks(ctx, webhookBatchLimit(r))
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to get unprocessed webhooks", err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "WEBHOOKS_RETRIEVAL_FAILED", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	})
+}
+
+// ReplayWebhooks applies unprocessed webhooks again
+func (h *PaymentHandler) ReplayWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	result, err := h.service.ReplayWebhooks(ctx, webhookBatchLimit(r))
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to replay webhooks", err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "WEBHOOK_REPLAY_FAILED", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, result)
+}
+
+// webhookBatchLimit reads the limit query parameter, defaulting to 100
+func webhookBatchLimit(r *http.Request) int {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	return limit
+}
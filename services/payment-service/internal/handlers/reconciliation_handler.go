@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/payment-service/internal/settlement"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// maxSettlementReportSize bounds uploaded settlement reports
+const maxSettlementReportSize = 32 << 20
+
+// ReconciliationHandler handles HTTP requests for settlement reconciliation
+type ReconciliationHandler struct {
+	service service.ReconciliationService
+}
+
+// NewReconciliationHandler creates a new reconciliation handler
+func NewReconciliationHandler(service service.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers reconciliation routes
+func (h *ReconciliationHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/reconciliation/reports", h.ImportReport).Methods("POST")
+	router.HandleFunc("/admin/reconciliation/runs", h.ListRuns).Methods("GET")
+	router.HandleFunc("/admin/reconciliation/runs/{id}", h.GetRun).Methods("GET")
+	router.HandleFunc("/admin/reconciliation/discrepancies", h.ListDiscrepancies).Methods("GET")
+	router.HandleFunc("/admin/reconciliation/discrepancies/{id}", h.GetDiscrepancy).Methods("GET")
+	router.HandleFunc("/admin/reconciliation/discrepancies/{id}/resolve", h.ResolveDiscrepancy).Methods("POST")
+}
+
+// ImportReport reconciles an uploaded CSV settlement report, sent either as
+// the request body or as the "report" file of a multipart form
+func (h *ReconciliationHandler) ImportReport(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSettlementReportSize)
+
+	var body io.Reader = r.Body
+	name := r.URL.Query().Get("name")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("report")
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Missing report file")
+			return
+		}
+		defer file.Close()
+		body = file
+		if name == "" {
+			name = header.Filename
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("upload-%s.csv", time.Now().Format("20060102T150405"))
+	}
+
+	run, err := h.service.ReconcileReport(ctx, settlement.NewCSVSource(name, body))
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to reconcile settlement report", err, map[string]interface{}{
+			"source": name,
+		})
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, run)
+}
+
+// ListRuns lists reconciliation runs, newest first
+func (h *ReconciliationHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	limit, offset := pagination(r)
+
+	runs, err := h.service.ListRuns(ctx, limit, offset)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to list reconciliation runs", err)
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"runs":   runs,
+		"count":  len(runs),
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetRun retrieves a reconciliation run
+func (h *ReconciliationHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	run, err := h.service.GetRun(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, run)
+}
+
+// ListDiscrepancies lists discrepancies, filtered by run_id, status and type
+func (h *ReconciliationHandler) ListDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	limit, offset := pagination(r)
+
+	query := r.URL.Query()
+	filter := repository.DiscrepancyFilter{
+		RunID:  query.Get("run_id"),
+		Status: models.DiscrepancyStatus(query.Get("status")),
+		Type:   models.DiscrepancyType(query.Get("type")),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	discrepancies, err := h.service.ListDiscrepancies(ctx, filter)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to list discrepancies", err)
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"discrepancies": discrepancies,
+		"count":         len(discrepancies),
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+// GetDiscrepancy retrieves a discrepancy
+func (h *ReconciliationHandler) GetDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	discrepancy, err := h.service.GetDiscrepancy(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, discrepancy)
+}
+
+// ResolveDiscrepancy closes a discrepancy with an admin's resolution
+func (h *ReconciliationHandler) ResolveDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var req service.ResolveDiscrepancyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	discrepancy, err := h.service.ResolveDiscrepancy(ctx, mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, discrepancy)
+}
+
+// pagination reads the limit and offset query parameters
+func pagination(r *http.Request) (int, int) {
+	limit := 50
+	offset := 0
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	return limit, offset
+}
+
+func writeReconciliationError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "already reconciled"), strings.Contains(err.Error(), "is not open"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "CONFLICT", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "no records"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "RECONCILIATION_FAILED", err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// RiskHandler handles HTTP requests for payment risk assessments and the
+// manual review queue
+type RiskHandler struct {
+	risk     service.RiskService
+	payments service.PaymentService
+}
+
+// NewRiskHandler creates a new risk handler
+func NewRiskHandler(risk service.RiskService, payments service.PaymentService) *RiskHandler {
+	return &RiskHandler{
+		risk:     risk,
+		payments: payments,
+	}
+}
+
+// RegisterRoutes registers risk routes
+func (h *RiskHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/payments/{id}/risk-assessments", h.GetPaymentAssessments).Methods("GET")
+	router.HandleFunc("/admin/risk/assessments", h.ListAssessments).Methods("GET")
+	router.HandleFunc("/admin/risk/assessments/{id}", h.GetAssessment).Methods("GET")
+	router.HandleFunc("/admin/risk/assessments/{id}/review", h.ReviewPayment).Methods("POST")
+}
+
+// GetPaymentAssessments lists the risk assessments of a payment
+func (h *RiskHandler) GetPaymentAssessments(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	assessments, err := h.risk.GetPaymentAssessments(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to get risk assessments", err)
+		writeRiskError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"assessments": assessments,
+		"count":       len(assessments),
+	})
+}
+
+// ListAssessments lists assessments, filtered by decision, review_status and
+// user_id. The review queue is review_status=pending.
+func (h *RiskHandler) ListAssessments(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	limit, offset := pagination(r)
+
+	query := r.URL.Query()
+	filter := repository.RiskAssessmentFilter{
+		Decision:     models.RiskDecision(query.Get("decision")),
+		ReviewStatus: models.RiskReviewStatus(query.Get("review_status")),
+		UserID:       query.Get("user_id"),
+		Limit:        limit,
+		Offset:       offset,
+	}
+
+	assessments, err := h.risk.ListAssessments(ctx, filter)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to list risk assessments", err)
+		writeRiskError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"assessments": assessments,
+		"count":       len(assessments),
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// GetAssessment retrieves a risk assessment
+func (h *RiskHandler) GetAssessment(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	assessment, err := h.risk.GetAssessment(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, assessment)
+}
+
+// ReviewPayment approves or rejects a payment held for review and returns
+// the payment as it stands afterwards
+func (h *RiskHandler) ReviewPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var req service.RiskReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	assessmentID := mux.Vars(r)["id"]
+	payment, err := h.payments.ReviewPayment(ctx, assessmentID, &req)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to review payment", err, map[string]interface{}{
+			"assessment_id": assessmentID,
+		})
+		writeRiskError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, payment)
+}
+
+func writeRiskError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "not pending review"), strings.Contains(err.Error(), "not under review"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "CONFLICT", err.Error())
+	case strings.Contains(err.Error(), "invalid"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case strings.Contains(err.Error(), "not enabled"):
+		utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "RISK_CHECKS_DISABLED", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "RISK_REVIEW_FAILED", err.Error())
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+)
+
+// ShipmentEventHandler captures authorized payments as shipments leave the warehouse
+type ShipmentEventHandler struct {
+	service service.PaymentService
+}
+
+// NewShipmentEventHandler creates a new shipment event handler
+func NewShipmentEventHandler(service service.PaymentService) *ShipmentEventHandler {
+	return &ShipmentEventHandler{
+		service: service,
+	}
+}
+
+// RegisterHandlers registers the shipment event handlers with a consumer
+func (h *ShipmentEventHandler) RegisterHandlers(consumer *events.Consumer) {
+	events.Handle(consumer, models.EventShipmentShipped, h.HandleShipmentShipped)
+}
+
+// HandleShipmentShipped captures the shipment's share of the order's authorization
+func (h *ShipmentEventHandler) HandleShipmentShipped(ctx context.Context, event *models.DomainEvent, data models.ShipmentShippedData) error {
+	_, err := h.service.CaptureForShipment(ctx, &data)
+	return err
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// StoredValueHandler handles HTTP requests for gift cards, wallets and their ledgers
+type StoredValueHandler struct {
+	service service.StoredValueService
+}
+
+// NewStoredValueHandler creates a new stored-value handler
+func NewStoredValueHandler(service service.StoredValueService) *StoredValueHandler {
+	return &StoredValueHandler{service: service}
+}
+
+// RegisterRoutes registers gift card and wallet routes
+func (h *StoredValueHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/gift-cards/lookup", h.LookupGiftCard).Methods("POST")
+	router.HandleFunc("/gift-cards/{id}", h.GetGiftCard).Methods("GET")
+	router.HandleFunc("/gift-cards/{id}/ledger", h.GetGiftCardLedger).Methods("GET")
+	router.HandleFunc("/wallets/{userId}", h.GetWallet).Methods("GET")
+	router.HandleFunc("/wallets/{userId}/ledger", h.GetWalletLedger).Methods("GET")
+
+	router.HandleFunc("/admin/gift-cards", h.IssueGiftCard).Methods("POST")
+	router.HandleFunc("/admin/gift-cards/{id}/disable", h.DisableGiftCard).Methods("POST")
+	router.HandleFunc("/admin/wallets/{userId}/adjustments", h.AdjustWallet).Methods("POST")
+}
+
+// IssueGiftCard issues a gift card. The response is the only place its code appears.
+func (h *StoredValueHandler) IssueGiftCard(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var req service.IssueGiftCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	card, err := h.service.IssueGiftCard(ctx, &req)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to issue gift card", err)
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, card)
+}
+
+// LookupGiftCard finds a gift card and its balance by code. The code is
+// posted rather than put in the URL so it stays out of access logs.
+func (h *StoredValueHandler) LookupGiftCard(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	card, err := h.service.LookupGiftCard(ctx, req.Code)
+	if err != nil {
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, card)
+}
+
+// GetGiftCard retrieves a gift card
+func (h *StoredValueHandler) GetGiftCard(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	card, err := h.service.GetGiftCard(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, card)
+}
+
+// DisableGiftCard stops a gift card from being redeemed
+func (h *StoredValueHandler) DisableGiftCard(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	id := mux.Vars(r)["id"]
+
+	card, err := h.service.DisableGiftCard(ctx, id)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to disable gift card", err, map[string]interface{}{
+			"gift_card_id": id,
+		})
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, card)
+}
+
+// GetGiftCardLedger lists the balance changes of a gift card, newest first
+func (h *StoredValueHandler) GetGiftCardLedger(w http.ResponseWriter, r *http.Request) {
+	h.writeLedger(w, r, models.StoredValueGiftCard, mux.Vars(r)["id"])
+}
+
+// GetWallet retrieves a user's wallet in the currency query parameter
+func (h *StoredValueHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	wallet, err := h.service.GetWallet(ctx, mux.Vars(r)["userId"], r.URL.Query().Get("currency"))
+	if err != nil {
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, wallet)
+}
+
+// GetWalletLedger lists the balance changes of a user's wallet, newest first
+func (h *StoredValueHandler) GetWalletLedger(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	wallet, err := h.service.GetWallet(ctx, mux.Vars(r)["userId"], r.URL.Query().Get("currency"))
+	if err != nil {
+		writeStoredValueError(w, err)
+		return
+	}
+
+	h.writeLedger(w, r, models.StoredValueWallet, wallet.ID)
+}
+
+// AdjustWallet credits or debits a user's wallet by hand
+func (h *StoredValueHandler) AdjustWallet(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	userID := mux.Vars(r)["userId"]
+
+	var req service.WalletAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	entry, err := h.service.AdjustWallet(ctx, userID, &req)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to adjust wallet", err, map[string]interface{}{
+			"user_id": userID,
+		})
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, entry)
+}
+
+func (h *StoredValueHandler) writeLedger(w http.ResponseWriter, r *http.Request, accountType models.StoredValueAccountType, accountID string) {
+	ctx := context.Background()
+	limit, offset := pagination(r)
+
+	entries, err := h.service.GetLedger(ctx, accountType, accountID, limit, offset)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to get stored-value ledger", err)
+		writeStoredValueError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+func writeStoredValueError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteErrorResponse(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "insufficient balance"), strings.Contains(err.Error(), "not redeemable"):
+		utils.WriteErrorResponse(w, http.StatusConflict, "CONFLICT", err.Error())
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "unsupported currency"),
+		strings.Contains(err.Error(), "required"):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "STORED_VALUE_FAILED", err.Error())
+	}
+}
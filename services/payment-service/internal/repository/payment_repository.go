@@ -4,14 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/models"
 	_ "github.com/lib/pq"
 )
 
-
+// ErrShipmentAlreadyCaptured is returned by ReserveCapture when the shipment
+// already has a capture that did not fail
+var ErrShipmentAlreadyCaptured = errors.New("shipment already captured")
 
 // PaymentRepository defines the interface for payment data operations
 type PaymentRepository interface {
@@ -22,6 +27,26 @@ type PaymentRepository interface {
 	GetPaymentsByUserID(ctx context.Context, userID string, limit, offset int) ([]*models.Payment, error)
 	UpdatePayment(ctx context.Context, payment *models.Payment) error
 	UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error
+	GetExpiredAuthorizations(ctx context.Context, before time.Time, limit int) ([]*models.Payment, error)
+	ApplyGatewayStatus(ctx context.Context, update *GatewayStatusUpdate) (*models.Payment, bool, error)
+	// CompleteRiskReview records an admin's review of a held payment and moves
+	// the payment out of review in one transaction. It fails unless the review
+	// is pending and the payment is under review.
+	CompleteRiskReview(ctx context.Context, assessment *models.RiskAssessment, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error
+
+	// Authorization and capture operations
+	AuthorizePayment(ctx context.Context, paymentID, transactionID string, expiresAt time.Time, gatewayResponse *models.GatewayResponse) error
+	ReserveCapture(ctx context.Context, capture *models.PaymentCapture) (*models.Payment, error)
+	CompleteCapture(ctx context.Context, capture *models.PaymentCapture, gatewayResponse *models.GatewayResponse) (*models.Payment, error)
+	GetCapturesByPaymentID(ctx context.Context, paymentID string) ([]*models.PaymentCapture, error)
+	// GetStalePendingCaptures returns captures reserved before the given time
+	// whose gateway outcome was never recorded, oldest first
+	GetStalePendingCaptures(ctx context.Context, before time.Time, limit int) ([]*models.PaymentCapture, error)
+	// VoidAuthorization releases an authorization with the payment row
+	// locked, so no capture can be reserved against it meanwhile. It fails
+	// unless the payment is capturable with no captures pending; release is
+	// called with the locked payment to release the hold at the gateway.
+	VoidAuthorization(ctx context.Context, paymentID string, release func(*models.Payment) (*AuthorizationVoid, error)) (*models.Payment, error)
 	
 	// Payment method operations
 	CreatePaymentMethod(ctx context.Context, method *models.PaymentMethodInfo) error
@@ -34,19 +59,41 @@ type PaymentRepository interface {
 	// Refund operations
 	CreateRefund(ctx context.Context, refund *models.Refund) error
 	GetRefundByID(ctx context.Context, id string) (*models.Refund, error)
+	GetRefundByTransactionID(ctx context.Context, transactionID string) (*models.Refund, error)
 	GetRefundsByPaymentID(ctx context.Context, paymentID string) ([]*models.Refund, error)
 	UpdateRefund(ctx context.Context, refund *models.Refund) error
+	ReserveRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error)
+	CompleteRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error)
 	
 	// Webhook operations
 	CreateWebhook(ctx context.Context, webhook *models.PaymentWebhook) error
 	GetUnprocessedWebhooks(ctx context.Context, limit int) ([]*models.PaymentWebhook, error)
 	MarkWebhookProcessed(ctx context.Context, webhookID string) error
+	MarkWebhookFailed(ctx context.Context, webhookID string, reason string) error
 	
 	// Payment attempt operations
 	CreatePaymentAttempt(ctx context.Context, paymentID string, attemptNumber int, status string, errorMessage string, gatewayResponse *models.GatewayResponse) error
 	GetPaymentAttempts(ctx context.Context, paymentID string) ([]PaymentAttempt, error)
 }
 
+// GatewayStatusUpdate is a payment status reported by a gateway webhook
+type GatewayStatusUpdate struct {
+	TransactionID string
+	Status        models.PaymentStatus
+	FailureReason string
+	// OccurredAt is when the gateway raised the event
+	OccurredAt time.Time
+	// AuthorizationExpiresAt applies when the payment becomes authorized
+	AuthorizationExpiresAt *time.Time
+}
+
+// AuthorizationVoid is the outcome of releasing an authorization at the gateway
+type AuthorizationVoid struct {
+	Status          models.PaymentStatus
+	FailureReason   string
+	GatewayResponse *models.GatewayResponse
+}
+
 // PaymentAttempt represents a payment attempt record
 type PaymentAttempt struct {
 	ID              string                 `json:"id" db:"id"`
@@ -60,12 +107,16 @@ type PaymentAttempt struct {
 
 // PostgresPaymentRepository implements PaymentRepository using PostgreSQL
 type PostgresPaymentRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *events.PostgresOutbox
 }
 
 // NewPostgresPaymentRepository creates a new PostgreSQL payment repository
 func NewPostgresPaymentRepository(db *sql.DB) PaymentRepository {
-	return &PostgresPaymentRepository{db: db}
+	return &PostgresPaymentRepository{
+		db:     db,
+		outbox: events.NewPostgresOutbox(db),
+	}
 }
 
 // CreatePayment creates a new payment record
@@ -75,16 +126,24 @@ func (r *PostgresPaymentRepository) CreatePayment(ctx context.Context, payment *
 		return fmt.Errorf("failed to marshal gateway response: %w", err)
 	}
 
+	riskContextJSON, err := json.Marshal(payment.RiskContext)
+	if err != nil {
+		return fmt.Errorf("failed to marshal risk context: %w", err)
+	}
+
 	query := `
 		INSERT INTO payments (id, order_id, user_id, amount, currency, status, type, 
-			payment_method_id, transaction_id, gateway_response, failure_reason, processed_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+			payment_method_id, transaction_id, gateway_response, failure_reason,
+			authorized_amount, captured_amount, refunded_amount, authorization_expires_at, processed_at, created_at, updated_at,
+			risk_context)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`
 
 	_, err = r.db.ExecContext(ctx, query,
 		payment.ID, payment.OrderID, payment.UserID, payment.Amount, payment.Currency,
 		payment.Status, payment.Type, payment.PaymentMethodID, payment.TransactionID,
-		gatewayResponseJSON, payment.FailureReason, payment.ProcessedAt,
-		payment.CreatedAt, payment.UpdatedAt)
+		gatewayResponseJSON, payment.FailureReason,
+		payment.AuthorizedAmount, payment.CapturedAmount, payment.RefundedAmount, payment.AuthorizationExpiresAt, payment.ProcessedAt,
+		payment.CreatedAt, payment.UpdatedAt, riskContextJSON)
 
 	if err != nil {
 		return fmt.Errorf("failed to create payment: %w", err)
@@ -93,24 +152,16 @@ func (r *PostgresPaymentRepository) CreatePayment(ctx context.Context, payment *
 	return nil
 }
 
+const paymentColumns = `id, order_id, user_id, amount, currency, status, type,
+	payment_method_id, transaction_id, gateway_response, failure_reason,
+	authorized_amount, captured_amount, refunded_amount, authorization_expires_at,
+	processed_at, created_at, updated_at, gateway_updated_at, risk_context`
+
 // GetPaymentByID retrieves a payment by ID
 func (r *PostgresPaymentRepository) GetPaymentByID(ctx context.Context, id string) (*models.Payment, error) {
-	query := `
-		SELECT id, order_id, user_id, amount, currency, status, type, 
-			payment_method_id, transaction_id, gateway_response, failure_reason, 
-			processed_at, created_at, updated_at
-		FROM payments WHERE id = $1`
-
-	var payment models.Payment
-	var gatewayResponseJSON []byte
-	var processedAt sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&payment.ID, &payment.OrderID, &payment.UserID, &payment.Amount, &payment.Currency,
-		&payment.Status, &payment.Type, &payment.PaymentMethodID, &payment.TransactionID,
-		&gatewayResponseJSON, &payment.FailureReason, &processedAt,
-		&payment.CreatedAt, &payment.UpdatedAt)
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = $1`
 
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("payment not found: %s", id)
@@ -118,21 +169,147 @@ func (r *PostgresPaymentRepository) GetPaymentByID(ctx context.Context, id strin
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 
+	return payment, nil
+}
+
+// GetPaymentsByOrderID retrieves all payments for an order, oldest first
+func (r *PostgresPaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID string) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY created_at`
+
+	return r.queryPayments(ctx, query, orderID)
+}
+
+// GetExpiredAuthorizations retrieves payments whose uncaptured authorization expired before the given time
+func (r *PostgresPaymentRepository) GetExpiredAuthorizations(ctx context.Context, before time.Time, limit int) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments
+		WHERE status IN ('authorized', 'partially_captured') AND authorization_expires_at < $1
+		ORDER BY authorization_expires_at
+		LIMIT $2`
+
+	return r.queryPayments(ctx, query, before, limit)
+}
+
+func (r *PostgresPaymentRepository) queryPayments(ctx context.Context, query string, args ...interface{}) ([]*models.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var payment models.Payment
+	var gatewayResponseJSON, riskContextJSON []byte
+	var processedAt, expiresAt, gatewayUpdatedAt sql.NullTime
+
+	err := row.Scan(
+		&payment.ID, &payment.OrderID, &payment.UserID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.Type, &payment.PaymentMethodID, &payment.TransactionID,
+		&gatewayResponseJSON, &payment.FailureReason,
+		&payment.AuthorizedAmount, &payment.CapturedAmount, &payment.RefundedAmount, &expiresAt,
+		&processedAt, &payment.CreatedAt, &payment.UpdatedAt, &gatewayUpdatedAt, &riskContextJSON)
+	if err != nil {
+		return nil, err
+	}
+
 	if processedAt.Valid {
 		payment.ProcessedAt = &processedAt.Time
 	}
+	if expiresAt.Valid {
+		payment.AuthorizationExpiresAt = &expiresAt.Time
+	}
+	if gatewayUpdatedAt.Valid {
+		payment.GatewayUpdatedAt = &gatewayUpdatedAt.Time
+	}
 
 	if len(gatewayResponseJSON) > 0 {
 		if err := json.Unmarshal(gatewayResponseJSON, &payment.GatewayResponse); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal gateway response: %w", err)
 		}
 	}
+	if len(riskContextJSON) > 0 {
+		if err := json.Unmarshal(riskContextJSON, &payment.RiskContext); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal risk context: %w", err)
+		}
+	}
 
 	return &payment, nil
 }
 
 // UpdatePaymentStatus updates payment status and related fields
 func (r *PostgresPaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.updatePaymentStatusTx(ctx, tx, paymentID, "", status, transactionID, failureReason, gatewayResponse); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment status: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteRiskReview records an admin's review of a held payment and moves
+// the payment out of review together, so a failure never leaves the payment
+// under review with its review already completed
+func (r *PostgresPaymentRepository) CompleteRiskReview(ctx context.Context, assessment *models.RiskAssessment, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE risk_assessments SET
+			review_status = $2, reviewed_by = $3, review_note = $4, reviewed_at = $5, updated_at = $6
+		WHERE id = $1 AND review_status = 'pending'`
+
+	result, err := tx.ExecContext(ctx, query,
+		assessment.ID, assessment.ReviewStatus, assessment.ReviewedBy, assessment.ReviewNote,
+		assessment.ReviewedAt, assessment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to complete risk review: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("risk assessment %s is not pending review", assessment.ID)
+	}
+
+	if err := r.updatePaymentStatusTx(ctx, tx, assessment.PaymentID, models.PaymentUnderReview, status, transactionID, failureReason, gatewayResponse); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit risk review: %w", err)
+	}
+
+	return nil
+}
+
+// updatePaymentStatusTx updates payment status within a transaction and
+// writes the event for a terminal status. If from is set, the payment must
+// be in that status.
+func (r *PostgresPaymentRepository) updatePaymentStatusTx(ctx context.Context, tx *sql.Tx, paymentID string, from, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error {
 	var gatewayResponseJSON []byte
 	var err error
 
@@ -149,30 +326,802 @@ func (r *PostgresPaymentRepository) UpdatePaymentStatus(ctx context.Context, pay
 		processedAt = &now
 	}
 
+	// The gateway reports the same outcome synchronously and by webhook;
+	// whichever lands second must not announce it again
+	var current models.PaymentStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM payments WHERE id = $1 FOR UPDATE`, paymentID).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("payment not found: %s", paymentID)
+		}
+		return fmt.Errorf("failed to lock payment: %w", err)
+	}
+	if from != "" && current != from {
+		return fmt.Errorf("payment %s is not %s: %s", paymentID, from, current)
+	}
+	if current == status {
+		return nil
+	}
+
+	// A payment completed in one step (automatic capture) was authorized and
+	// captured in full; completing a partly captured payment keeps what was captured
 	query := `
 		UPDATE payments SET 
 			status = $2, transaction_id = $3, failure_reason = $4, 
-			gateway_response = $5, processed_at = $6, updated_at = $7
-		WHERE id = $1`
+			gateway_response = $5, processed_at = $6, updated_at = $7,
+			authorized_amount = CASE WHEN $2 = 'completed' AND authorized_amount = 0 THEN amount ELSE authorized_amount END,
+			captured_amount = CASE WHEN $2 = 'completed' AND captured_amount = 0 THEN amount ELSE captured_amount END
+		WHERE id = $1
+		RETURNING order_id, user_id, amount, currency, type`
 
-	_, err = r.db.ExecContext(ctx, query,
+	var payment models.Payment
+	err = tx.QueryRowContext(ctx, query,
 		paymentID, status, transactionID, failureReason,
-		gatewayResponseJSON, processedAt, time.Now())
+		gatewayResponseJSON, processedAt, time.Now()).Scan(
+		&payment.OrderID, &payment.UserID, &payment.Amount, &payment.Currency, &payment.Type)
 
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("payment not found: %s", paymentID)
+		}
 		return fmt.Errorf("failed to update payment status: %w", err)
 	}
 
+	payment.ID = paymentID
+	payment.TransactionID = transactionID
+	payment.FailureReason = failureReason
+	return r.savePaymentEvent(ctx, tx, &payment, status)
+}
+
+// savePaymentEvent writes the domain event for a terminal payment status to the outbox
+func (r *PostgresPaymentRepository) savePaymentEvent(ctx context.Context, tx *sql.Tx, payment *models.Payment, status models.PaymentStatus) error {
+	var eventType models.EventType
+	var data interface{}
+
+	switch status {
+	case models.PaymentCompleted:
+		eventType = models.EventPaymentProcessed
+		data = models.PaymentProcessedData{
+			PaymentID:     payment.ID,
+			OrderID:       payment.OrderID,
+			UserID:        payment.UserID,
+			Amount:        payment.Amount,
+			Currency:      payment.Currency,
+			PaymentMethod: models.PaymentMethod{Type: string(payment.Type)},
+			TransactionID: payment.TransactionID,
+		}
+	case models.PaymentFailed, models.PaymentBlocked:
+		eventType = models.EventPaymentFailed
+		data = models.PaymentFailedData{
+			PaymentID: payment.ID,
+			OrderID:   payment.OrderID,
+			UserID:    payment.UserID,
+			Amount:    payment.Amount,
+			Currency:  payment.Currency,
+			Reason:    payment.FailureReason,
+		}
+	default:
+		return nil
+	}
+
+	event, err := models.NewDomainEvent(eventType, payment.ID, data, events.MetadataFromContext(ctx, "payment-service"))
+	if err != nil {
+		return fmt.Errorf("failed to create payment event: %w", err)
+	}
+
+	return r.outbox.Save(ctx, tx, event)
+}
+
+// ApplyGatewayStatus applies a status reported by a gateway webhook to the
+// payment with the given transaction ID. Events older than the last one
+// applied, and transitions the payment has already moved past, are skipped;
+// the returned flag tells whether the payment changed.
+func (r *PostgresPaymentRepository) ApplyGatewayStatus(ctx context.Context, update *GatewayStatusUpdate) (*models.Payment, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE transaction_id = $1 FOR UPDATE`
+	payment, err := scanPayment(tx.QueryRowContext(ctx, query, update.TransactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, fmt.Errorf("payment not found for transaction: %s", update.TransactionID)
+		}
+		return nil, false, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	if payment.GatewayUpdatedAt != nil && update.OccurredAt.Before(*payment.GatewayUpdatedAt) {
+		return payment, false, nil
+	}
+
+	previous := payment.Status
+	if !payment.ApplyGatewayStatus(update.Status) {
+		return payment, false, nil
+	}
+
+	now := time.Now()
+	payment.FailureReason = ""
+	switch payment.Status {
+	case models.PaymentFailed:
+		payment.FailureReason = update.FailureReason
+	case models.PaymentAuthorized:
+		payment.AuthorizationExpiresAt = update.AuthorizationExpiresAt
+	}
+	if payment.ProcessedAt == nil && (payment.Status == models.PaymentCompleted || payment.Status == models.PaymentFailed) {
+		payment.ProcessedAt = &now
+	}
+	payment.GatewayUpdatedAt = &update.OccurredAt
+	payment.UpdatedAt = now
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET
+			status = $2, failure_reason = $3, authorized_amount = $4, captured_amount = $5, refunded_amount = $6,
+			authorization_expires_at = $7, processed_at = $8, gateway_updated_at = $9, updated_at = $10
+		WHERE id = $1`,
+		payment.ID, payment.Status, payment.FailureReason, payment.AuthorizedAmount, payment.CapturedAmount, payment.RefundedAmount,
+		payment.AuthorizationExpiresAt, payment.ProcessedAt, payment.GatewayUpdatedAt, payment.UpdatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	// Closing a dispute settles the payment again; it was announced already
+	if previous != models.PaymentDisputed {
+		if err := r.savePaymentEvent(ctx, tx, payment, payment.Status); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit payment status: %w", err)
+	}
+
+	return payment, true, nil
+}
+
+// AuthorizePayment records an authorization hold for the full payment amount
+func (r *PostgresPaymentRepository) AuthorizePayment(ctx context.Context, paymentID, transactionID string, expiresAt time.Time, gatewayResponse *models.GatewayResponse) error {
+	gatewayResponseJSON, err := json.Marshal(gatewayResponse)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gateway response: %w", err)
+	}
+
+	query := `
+		UPDATE payments SET
+			status = $2, transaction_id = $3, gateway_response = $4, failure_reason = '',
+			authorized_amount = amount, captured_amount = 0, authorization_expires_at = $5, updated_at = $6
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		paymentID, models.PaymentAuthorized, transactionID, gatewayResponseJSON, expiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to authorize payment: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("payment not found: %s", paymentID)
+	}
+
+	return nil
+}
+
+// ReserveCapture records a pending capture after checking, with the payment
+// row locked, that the authorization still covers it. A zero amount reserves
+// everything not yet captured or reserved and makes the capture final.
+func (r *PostgresPaymentRepository) ReserveCapture(ctx context.Context, capture *models.PaymentCapture) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := r.lockPayment(ctx, tx, capture.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.IsCapturable() {
+		return nil, fmt.Errorf("payment cannot be captured in current state: %s", payment.Status)
+	}
+	if payment.AuthorizationExpiresAt != nil && payment.AuthorizationExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("payment authorization expired at %s", payment.AuthorizationExpiresAt.Format(time.RFC3339))
+	}
+
+	if capture.ShipmentID != "" {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM payment_captures WHERE payment_id = $1 AND shipment_id = $2 AND status <> $3)`,
+			capture.PaymentID, capture.ShipmentID, models.CaptureFailed).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check shipment captures: %w", err)
+		}
+		if exists {
+			return nil, fmt.Errorf("%w: %s", ErrShipmentAlreadyCaptured, capture.ShipmentID)
+		}
+	}
+
+	var pending decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM payment_captures WHERE payment_id = $1 AND status = $2`,
+		capture.PaymentID, models.CapturePending).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum pending captures: %w", err)
+	}
+
+	available := payment.UncapturedAmount().Sub(pending)
+	if capture.Amount.IsZero() {
+		capture.Amount = available
+		capture.Final = true
+	}
+	if !capture.Amount.IsPositive() || capture.Amount.GreaterThan(available) {
+		return nil, fmt.Errorf("invalid capture amount %s: %s of the authorization is available", capture.Amount, available)
+	}
+
+	query := `
+		INSERT INTO payment_captures (id, payment_id, shipment_id, amount, final, status, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)`
+
+	_, err = tx.ExecContext(ctx, query,
+		capture.ID, capture.PaymentID, capture.ShipmentID, capture.Amount, capture.Final,
+		capture.Status, capture.CreatedAt, capture.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit capture: %w", err)
+	}
+
+	return payment, nil
+}
+
+// CompleteCapture records the gateway outcome of a reserved capture. A
+// succeeded capture adds to the captured amount; the payment is completed once
+// the capture is final or nothing is left to capture. It fails unless the
+// capture is still pending, so an outcome is never recorded twice.
+func (r *PostgresPaymentRepository) CompleteCapture(ctx context.Context, capture *models.PaymentCapture, gatewayResponse *models.GatewayResponse) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := r.lockPayment(ctx, tx, capture.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	capture.UpdatedAt = time.Now()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE payment_captures SET status = $2, transaction_id = $3, failure_reason = $4, updated_at = $5
+		WHERE id = $1 AND status = $6`,
+		capture.ID, capture.Status, capture.TransactionID, capture.FailureReason, capture.UpdatedAt, models.CapturePending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update capture: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("capture %s is no longer pending", capture.ID)
+	}
+
+	if capture.Status == models.CaptureSucceeded {
+		gatewayResponseJSON, err := json.Marshal(gatewayResponse)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal gateway response: %w", err)
+		}
+
+		now := time.Now()
+		payment.CapturedAmount = payment.CapturedAmount.Add(capture.Amount)
+		payment.Status = models.PaymentPartiallyCaptured
+		settled := capture.Final || payment.CapturedAmount.GreaterThanOrEqual(payment.AuthorizedAmount)
+		if settled {
+			// Refunds may already have been made against earlier captures
+			payment.Status = payment.SettledStatus()
+		}
+		payment.ProcessedAt = &now
+		payment.UpdatedAt = now
+		if gatewayResponse != nil {
+			payment.GatewayResponse = *gatewayResponse
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments SET status = $2, captured_amount = $3, gateway_response = $4, processed_at = $5, updated_at = $6
+			WHERE id = $1`,
+			payment.ID, payment.Status, payment.CapturedAmount, gatewayResponseJSON, payment.ProcessedAt, payment.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update captured amount: %w", err)
+		}
+
+		if settled {
+			if err := r.savePaymentEvent(ctx, tx, payment, models.PaymentCompleted); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit capture: %w", err)
+	}
+
+	return payment, nil
+}
+
+// VoidAuthorization releases an authorization while holding the payment row
+// lock, so a capture reserved concurrently waits for the void and then finds
+// the payment no longer capturable. Pending captures may still succeed at the
+// gateway, so the void is refused until they are settled.
+func (r *PostgresPaymentRepository) VoidAuthorization(ctx context.Context, paymentID string, release func(*models.Payment) (*AuthorizationVoid, error)) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := r.lockPayment(ctx, tx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.IsCapturable() {
+		return nil, fmt.Errorf("payment cannot be voided in current state: %s", payment.Status)
+	}
+
+	var pending int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM payment_captures WHERE payment_id = $1 AND status = $2`,
+		paymentID, models.CapturePending).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pending captures: %w", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("payment cannot be voided while %d captures are pending", pending)
+	}
+
+	void, err := release(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.updatePaymentStatusTx(ctx, tx, paymentID, payment.Status, void.Status, payment.TransactionID, void.FailureReason, void.GatewayResponse); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit void: %w", err)
+	}
+
+	payment.Status = void.Status
+	payment.FailureReason = void.FailureReason
+	if void.GatewayResponse != nil {
+		payment.GatewayResponse = *void.GatewayResponse
+	}
+	return payment, nil
+}
+
+const captureColumns = `id, payment_id, COALESCE(shipment_id, ''), amount, final, status,
+	COALESCE(transaction_id, ''), COALESCE(failure_reason, ''), created_at, updated_at`
+
+// GetCapturesByPaymentID retrieves the captures of a payment, oldest first
+func (r *PostgresPaymentRepository) GetCapturesByPaymentID(ctx context.Context, paymentID string) ([]*models.PaymentCapture, error) {
+	query := `SELECT ` + captureColumns + ` FROM payment_captures WHERE payment_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get captures: %w", err)
+	}
+	return scanCaptures(rows)
+}
+
+// GetStalePendingCaptures returns captures reserved before the given time
+// whose gateway outcome was never recorded, oldest first
+func (r *PostgresPaymentRepository) GetStalePendingCaptures(ctx context.Context, before time.Time, limit int) ([]*models.PaymentCapture, error) {
+	query := `
+		SELECT ` + captureColumns + ` FROM payment_captures
+		WHERE status = $1 AND created_at < $2
+		ORDER BY created_at
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, models.CapturePending, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stale pending captures: %w", err)
+	}
+	return scanCaptures(rows)
+}
+
+func scanCaptures(rows *sql.Rows) ([]*models.PaymentCapture, error) {
+	defer rows.Close()
+
+	var captures []*models.PaymentCapture
+	for rows.Next() {
+		var capture models.PaymentCapture
+		if err := rows.Scan(
+			&capture.ID, &capture.PaymentID, &capture.ShipmentID, &capture.Amount, &capture.Final, &capture.Status,
+			&capture.TransactionID, &capture.FailureReason, &capture.CreatedAt, &capture.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan capture: %w", err)
+		}
+		captures = append(captures, &capture)
+	}
+
+	return captures, rows.Err()
+}
+
+const refundColumns = `id, payment_id, order_id, amount, currency, reason, status,
+	COALESCE(transaction_id, ''), gateway_response, COALESCE(failure_reason, ''),
+	processed_at, created_at, updated_at`
+
+// CreateRefund inserts a refund without checking it against the payment; use
+// ReserveRefund for refunds that must stay within the captured amount
+func (r *PostgresPaymentRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	return r.insertRefund(ctx, r.db, refund)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *PostgresPaymentRepository) insertRefund(ctx context.Context, db execer, refund *models.Refund) error {
+	gatewayResponseJSON, err := json.Marshal(refund.GatewayResponse)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gateway response: %w", err)
+	}
+
+	query := `
+		INSERT INTO refunds (id, payment_id, order_id, amount, currency, reason, status,
+			transaction_id, gateway_response, failure_reason, processed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, NULLIF($10, ''), $11, $12, $13)`
+
+	_, err = db.ExecContext(ctx, query,
+		refund.ID, refund.PaymentID, refund.OrderID, refund.Amount, refund.Currency, refund.Reason, refund.Status,
+		refund.TransactionID, gatewayResponseJSON, refund.FailureReason, refund.ProcessedAt,
+		refund.CreatedAt, refund.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refund: %w", err)
+	}
+
 	return nil
 }
 
+// GetRefundByID retrieves a refund by ID
+func (r *PostgresPaymentRepository) GetRefundByID(ctx context.Context, id string) (*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE id = $1`
+
+	refund, err := scanRefund(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refund not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+
+	return refund, nil
+}
+
+// GetRefundByTransactionID retrieves a refund by its gateway refund ID
+func (r *PostgresPaymentRepository) GetRefundByTransactionID(ctx context.Context, transactionID string) (*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE transaction_id = $1`
+
+	refund, err := scanRefund(r.db.QueryRowContext(ctx, query, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refund not found: %s", transactionID)
+		}
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+
+	return refund, nil
+}
+
+// GetRefundsByPaymentID retrieves the refunds of a payment, oldest first
+func (r *PostgresPaymentRepository) GetRefundsByPaymentID(ctx context.Context, paymentID string) ([]*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []*models.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// UpdateRefund records the gateway's answer for a refund still in flight.
+// Terminal statuses go through CompleteRefund so the payment balance follows.
+func (r *PostgresPaymentRepository) UpdateRefund(ctx context.Context, refund *models.Refund) error {
+	gatewayResponseJSON, err := json.Marshal(refund.GatewayResponse)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gateway response: %w", err)
+	}
+
+	refund.UpdatedAt = time.Now()
+	query := `
+		UPDATE refunds SET status = $2, transaction_id = NULLIF($3, ''), gateway_response = $4, updated_at = $5
+		WHERE id = $1 AND status IN ('pending', 'processing')`
+
+	result, err := r.db.ExecContext(ctx, query,
+		refund.ID, refund.Status, refund.TransactionID, gatewayResponseJSON, refund.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update refund: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("refund not found or already completed: %s", refund.ID)
+	}
+
+	return nil
+}
+
+// ReserveRefund records a pending refund after checking, with the payment row
+// locked, that it fits in the captured amount less completed refunds and
+// refunds still in flight. A zero amount refunds everything that is left.
+func (r *PostgresPaymentRepository) ReserveRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := r.lockPayment(ctx, tx, refund.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.IsRefundable() {
+		return nil, fmt.Errorf("payment cannot be refunded in current state: %s", payment.Status)
+	}
+
+	var inFlight decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status IN ('pending', 'processing')`,
+		refund.PaymentID).Scan(&inFlight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum pending refunds: %w", err)
+	}
+
+	available := payment.RefundableAmount().Sub(inFlight)
+	if refund.Amount.IsZero() {
+		refund.Amount = available
+	}
+	if !refund.Amount.IsPositive() || refund.Amount.GreaterThan(available) {
+		return nil, fmt.Errorf("refund amount %s exceeds refundable balance %s", refund.Amount, available)
+	}
+
+	refund.OrderID = payment.OrderID
+	refund.Currency = payment.Currency
+	refund.Status = models.PaymentPending
+	if err := r.insertRefund(ctx, tx, refund); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %w", err)
+	}
+
+	return payment, nil
+}
+
+// CompleteRefund moves a refund to its terminal status. A completed refund adds
+// to the payment's refunded amount; a failed one releases its reservation.
+// Refunds that already completed or failed are left alone, so redelivered
+// webhooks are harmless.
+func (r *PostgresPaymentRepository) CompleteRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := r.lockPayment(ctx, tx, refund.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var current models.PaymentStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM refunds WHERE id = $1 FOR UPDATE`, refund.ID).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refund not found: %s", refund.ID)
+		}
+		return nil, fmt.Errorf("failed to lock refund: %w", err)
+	}
+	if current != models.PaymentPending && current != models.PaymentProcessing {
+		refund.Status = current
+		return payment, nil
+	}
+
+	gatewayResponseJSON, err := json.Marshal(refund.GatewayResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gateway response: %w", err)
+	}
+
+	now := time.Now()
+	refund.ProcessedAt = &now
+	refund.UpdatedAt = now
+	_, err = tx.ExecContext(ctx, `
+		UPDATE refunds SET status = $2, transaction_id = COALESCE(NULLIF($3, ''), transaction_id),
+			gateway_response = $4, failure_reason = NULLIF($5, ''), processed_at = $6, updated_at = $7
+		WHERE id = $1`,
+		refund.ID, refund.Status, refund.TransactionID, gatewayResponseJSON, refund.FailureReason, refund.ProcessedAt, refund.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update refund: %w", err)
+	}
+
+	if refund.Status == models.PaymentCompleted {
+		payment.RefundedAmount = payment.RefundedAmount.Add(refund.Amount)
+		if payment.Status != models.PaymentPartiallyCaptured && payment.Status != models.PaymentDisputed {
+			payment.Status = payment.SettledStatus()
+		}
+		payment.UpdatedAt = now
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments SET status = $2, refunded_amount = $3, updated_at = $4 WHERE id = $1`,
+			payment.ID, payment.Status, payment.RefundedAmount, payment.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update refunded amount: %w", err)
+		}
+
+		if err := r.saveRefundEvent(ctx, tx, payment, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %w", err)
+	}
+
+	return payment, nil
+}
+
+// saveRefundEvent writes the payment.refunded event for a completed refund to the outbox
+func (r *PostgresPaymentRepository) saveRefundEvent(ctx context.Context, tx *sql.Tx, payment *models.Payment, refund *models.Refund) error {
+	data := models.PaymentRefundedData{
+		PaymentID:      payment.ID,
+		RefundID:       refund.ID,
+		OrderID:        payment.OrderID,
+		UserID:         payment.UserID,
+		Amount:         refund.Amount,
+		RefundedAmount: payment.RefundedAmount,
+		Currency:       payment.Currency,
+		Reason:         refund.Reason,
+		FullyRefunded:  payment.Status == models.PaymentRefunded,
+	}
+
+	event, err := models.NewDomainEvent(models.EventPaymentRefunded, payment.ID, data, events.MetadataFromContext(ctx, "payment-service"))
+	if err != nil {
+		return fmt.Errorf("failed to create refund event: %w", err)
+	}
+
+	return r.outbox.Save(ctx, tx, event)
+}
+
+func scanRefund(row rowScanner) (*models.Refund, error) {
+	var refund models.Refund
+	var gatewayResponseJSON []byte
+	var processedAt sql.NullTime
+
+	err := row.Scan(
+		&refund.ID, &refund.PaymentID, &refund.OrderID, &refund.Amount, &refund.Currency, &refund.Reason, &refund.Status,
+		&refund.TransactionID, &gatewayResponseJSON, &refund.FailureReason,
+		&processedAt, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if processedAt.Valid {
+		refund.ProcessedAt = &processedAt.Time
+	}
+
+	if len(gatewayResponseJSON) > 0 {
+		if err := json.Unmarshal(gatewayResponseJSON, &refund.GatewayResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal gateway response: %w", err)
+		}
+	}
+
+	return &refund, nil
+}
+
+// lockPayment loads a payment and locks its row until the transaction ends
+func (r *PostgresPaymentRepository) lockPayment(ctx context.Context, tx *sql.Tx, paymentID string) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = $1 FOR UPDATE`
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, query, paymentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("payment not found: %s", paymentID)
+		}
+		return nil, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	return payment, nil
+}
+
 // Additional methods would continue here but truncated for token limit
 // The remaining methods follow similar patterns for CRUD operations
 // on payment_methods, refunds, webhooks, and payment_attempts tables
 
+// CreateWebhook records a webhook event. A redelivered event is not stored
+// again; the webhook is filled in from the row recorded the first time.
+func (r *PostgresPaymentRepository) CreateWebhook(ctx context.Context, webhook *models.PaymentWebhook) error {
+	dataJSON, err := json.Marshal(webhook.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook data: %w", err)
+	}
+
+	query := `
+		INSERT INTO payment_webhooks (id, event_id, event_type, payment_id, status, data, signature,
+			processed, event_created_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (event_id) DO UPDATE SET event_id = EXCLUDED.event_id
+		RETURNING id, status, processed, processed_at, attempts, COALESCE(last_error, ''), created_at`
+
+	var processedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx, query,
+		webhook.ID, webhook.EventID, webhook.EventType, webhook.PaymentID, webhook.Status, dataJSON, webhook.Signature,
+		webhook.Processed, webhook.EventCreatedAt, webhook.CreatedAt).Scan(
+		&webhook.ID, &webhook.Status, &webhook.Processed, &processedAt, &webhook.Attempts, &webhook.LastError, &webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	if processedAt.Valid {
+		webhook.ProcessedAt = &processedAt.Time
+	}
+
+	return nil
+}
+
+// GetUnprocessedWebhooks retrieves webhooks not yet applied, in the order the
+// gateway raised them
+func (r *PostgresPaymentRepository) GetUnprocessedWebhooks(ctx context.Context, limit int) ([]*models.PaymentWebhook, error) {
+	query := `
+		SELECT id, event_id, event_type, COALESCE(payment_id, ''), status, data, COALESCE(signature, ''),
+			processed, processed_at, event_created_at, attempts, COALESCE(last_error, ''), created_at
+		FROM payment_webhooks
+		WHERE processed = false
+		ORDER BY event_created_at, created_at
+		LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unprocessed webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*models.PaymentWebhook
+	for rows.Next() {
+		var webhook models.PaymentWebhook
+		var dataJSON []byte
+		var processedAt sql.NullTime
+
+		err := rows.Scan(
+			&webhook.ID, &webhook.EventID, &webhook.EventType, &webhook.PaymentID, &webhook.Status, &dataJSON, &webhook.Signature,
+			&webhook.Processed, &processedAt, &webhook.EventCreatedAt, &webhook.Attempts, &webhook.LastError, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+
+		if processedAt.Valid {
+			webhook.ProcessedAt = &processedAt.Time
+		}
+		if err := json.Unmarshal(dataJSON, &webhook.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook data: %w", err)
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
 // MarkWebhookProcessed marks a webhook as processed
 func (r *PostgresPaymentRepository) MarkWebhookProcessed(ctx context.Context, webhookID string) error {
-	query := `UPDATE payment_webhooks SET processed = true, processed_at = $2 WHERE id = $1`
+	query := `
+		UPDATE payment_webhooks SET processed = true, processed_at = $2, status = 'processed',
+			attempts = attempts + 1, last_error = NULL
+		WHERE id = $1`
 	_, err := r.db.ExecContext(ctx, query, webhookID, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to mark webhook processed: %w", err)
@@ -180,12 +1129,18 @@ func (r *PostgresPaymentRepository) MarkWebhookProcessed(ctx context.Context, we
 	return nil
 }
 
-// Implement remaining methods following similar patterns...
-func (r *PostgresPaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID string) ([]*models.Payment, error) {
-	// Implementation similar to GetPaymentByID but returns slice
-	return nil, nil // Placeholder
+// MarkWebhookFailed records a failed attempt to apply a webhook, leaving it
+// unprocessed for replay
+func (r *PostgresPaymentRepository) MarkWebhookFailed(ctx context.Context, webhookID string, reason string) error {
+	query := `UPDATE payment_webhooks SET status = 'failed', attempts = attempts + 1, last_error = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, webhookID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook failed: %w", err)
+	}
+	return nil
 }
 
+// Implement remaining methods following similar patterns...
 func (r *PostgresPaymentRepository) GetPaymentsByUserID(ctx context.Context, userID string, limit, offset int) ([]*models.Payment, error) {
 	// Implementation with pagination
 	return nil, nil // Placeholder
@@ -226,36 +1181,6 @@ func (r *PostgresPaymentRepository) SetDefaultPaymentMethod(ctx context.Context,
 	return nil // Placeholder
 }
 
-func (r *PostgresPaymentRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
-	// Refund creation
-	return nil // Placeholder
-}
-
-func (r *PostgresPaymentRepository) GetRefundByID(ctx context.Context, id string) (*models.Refund, error) {
-	// Refund retrieval
-	return nil, nil // Placeholder
-}
-
-func (r *PostgresPaymentRepository) GetRefundsByPaymentID(ctx context.Context, paymentID string) ([]*models.Refund, error) {
-	// Payment refunds retrieval
-	return nil, nil // Placeholder
-}
-
-func (r *PostgresPaymentRepository) UpdateRefund(ctx context.Context, refund *models.Refund) error {
-	// Refund update
-	return nil // Placeholder
-}
-
-func (r *PostgresPaymentRepository) CreateWebhook(ctx context.Context, webhook *models.PaymentWebhook) error {
-	// Webhook creation
-	return nil // Placeholder
-}
-
-func (r *PostgresPaymentRepository) GetUnprocessedWebhooks(ctx context.Context, limit int) ([]*models.PaymentWebhook, error) {
-	// Unprocessed webhooks retrieval
-	return nil, nil // Placeholder
-}
-
 func (r *PostgresPaymentRepository) CreatePaymentAttempt(ctx context.Context, paymentID string, attemptNumber int, status string, errorMessage string, gatewayResponse *models.GatewayResponse) error {
 	// Payment attempt creation
 	return nil // Placeholder
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopsphere/shared/models"
+)
+
+// ReconciliationRepository stores settlement reconciliation runs and their
+// discrepancies, and looks up the payments and refunds a report covers
+type ReconciliationRepository interface {
+	GetPaymentsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Payment, error)
+	GetRefundsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Refund, error)
+	// GetCollectedPayments retrieves payments with captured funds processed in the period
+	GetCollectedPayments(ctx context.Context, from, to time.Time) ([]*models.Payment, error)
+	// GetCompletedRefunds retrieves refunds completed in the period
+	GetCompletedRefunds(ctx context.Context, from, to time.Time) ([]*models.Refund, error)
+
+	// CreateRun saves a run together with the discrepancies it found
+	CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.ReconciliationDiscrepancy) error
+	GetRunByID(ctx context.Context, id string) (*models.ReconciliationRun, error)
+	GetRunByChecksum(ctx context.Context, checksum string) (*models.ReconciliationRun, error)
+	ListRuns(ctx context.Context, limit, offset int) ([]*models.ReconciliationRun, error)
+
+	GetDiscrepancyByID(ctx context.Context, id string) (*models.ReconciliationDiscrepancy, error)
+	ListDiscrepancies(ctx context.Context, filter DiscrepancyFilter) ([]*models.ReconciliationDiscrepancy, error)
+	// ResolveDiscrepancy closes an open discrepancy; it fails if it is already resolved
+	ResolveDiscrepancy(ctx context.Context, discrepancy *models.ReconciliationDiscrepancy) error
+}
+
+// DiscrepancyFilter narrows a discrepancy listing; empty fields match everything
+type DiscrepancyFilter struct {
+	RunID  string
+	Status models.DiscrepancyStatus
+	Type   models.DiscrepancyType
+	Limit  int
+	Offset int
+}
+
+// PostgresReconciliationRepository implements ReconciliationRepository using PostgreSQL
+type PostgresReconciliationRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresReconciliationRepository creates a new PostgreSQL reconciliation repository
+func NewPostgresReconciliationRepository(db *sql.DB) ReconciliationRepository {
+	return &PostgresReconciliationRepository{db: db}
+}
+
+const reconciliationRunColumns = `id, source, checksum, period_start, period_end,
+	record_count, matched_count, discrepancy_count, created_at`
+
+const discrepancyColumns = `id, run_id, type, record_type, transaction_id,
+	COALESCE(payment_id::text, ''), COALESCE(refund_id::text, ''), currency, ledger_amount, settled_amount,
+	ledger_status, settlement_status, detail, status, resolution, resolved_by, resolved_at, created_at, updated_at`
+
+// GetPaymentsByTransactionIDs retrieves the payments with the given gateway transaction IDs
+func (r *PostgresReconciliationRepository) GetPaymentsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE transaction_id = ANY($1)`
+
+	return r.queryPayments(ctx, query, pq.Array(transactionIDs))
+}
+
+// GetCollectedPayments retrieves payments with captured funds processed in the
+// period. Stored-value payments never reach the gateway and are left out.
+func (r *PostgresReconciliationRepository) GetCollectedPayments(ctx context.Context, from, to time.Time) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments
+		WHERE captured_amount > 0 AND processed_at BETWEEN $1 AND $2
+			AND type NOT IN ('gift_card', 'wallet')
+		ORDER BY processed_at`
+
+	return r.queryPayments(ctx, query, from, to)
+}
+
+func (r *PostgresReconciliationRepository) queryPayments(ctx context.Context, query string, args ...interface{}) ([]*models.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// GetRefundsByTransactionIDs retrieves the refunds with the given gateway transaction IDs
+func (r *PostgresReconciliationRepository) GetRefundsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE transaction_id = ANY($1)`
+
+	return r.queryRefunds(ctx, query, pq.Array(transactionIDs))
+}
+
+// GetCompletedRefunds retrieves refunds completed in the period. Refunds
+// credited to a gift card or wallet never reach the gateway and are left out.
+func (r *PostgresReconciliationRepository) GetCompletedRefunds(ctx context.Context, from, to time.Time) ([]*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds
+		WHERE status = 'completed' AND processed_at BETWEEN $1 AND $2
+			AND COALESCE(gateway_response->>'gateway_id', '') <> 'stored_value'
+		ORDER BY processed_at`
+
+	return r.queryRefunds(ctx, query, from, to)
+}
+
+func (r *PostgresReconciliationRepository) queryRefunds(ctx context.Context, query string, args ...interface{}) ([]*models.Refund, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []*models.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// CreateRun saves a run together with the discrepancies it found
+func (r *PostgresReconciliationRepository) CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.ReconciliationDiscrepancy) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO settlement_reconciliation_runs (`+reconciliationRunColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		run.ID, run.Source, run.Checksum, run.PeriodStart, run.PeriodEnd,
+		run.RecordCount, run.MatchedCount, run.DiscrepancyCount, run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reconciliation run: %w", err)
+	}
+
+	for _, d := range discrepancies {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO settlement_discrepancies (id, run_id, type, record_type, transaction_id,
+				payment_id, refund_id, currency, ledger_amount, settled_amount,
+				ledger_status, settlement_status, detail, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, NULLIF($7, '')::uuid, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+			d.ID, d.RunID, d.Type, d.RecordType, d.TransactionID,
+			d.PaymentID, d.RefundID, d.Currency, d.LedgerAmount, d.SettledAmount,
+			d.LedgerStatus, d.SettlementStatus, d.Detail, d.Status, d.CreatedAt, d.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create discrepancy: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reconciliation run: %w", err)
+	}
+
+	return nil
+}
+
+// GetRunByID retrieves a reconciliation run by ID
+func (r *PostgresReconciliationRepository) GetRunByID(ctx context.Context, id string) (*models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM settlement_reconciliation_runs WHERE id = $1`
+
+	run, err := scanReconciliationRun(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("reconciliation run not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get reconciliation run: %w", err)
+	}
+
+	return run, nil
+}
+
+// GetRunByChecksum retrieves the run of a report by its content checksum,
+// returning nil when the report has not been reconciled
+func (r *PostgresReconciliationRepository) GetRunByChecksum(ctx context.Context, checksum string) (*models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM settlement_reconciliation_runs WHERE checksum = $1`
+
+	run, err := scanReconciliationRun(r.db.QueryRowContext(ctx, query, checksum))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get reconciliation run: %w", err)
+	}
+
+	return run, nil
+}
+
+// ListRuns retrieves reconciliation runs, newest first
+func (r *PostgresReconciliationRepository) ListRuns(ctx context.Context, limit, offset int) ([]*models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM settlement_reconciliation_runs
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.ReconciliationRun
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetDiscrepancyByID retrieves a discrepancy by ID
+func (r *PostgresReconciliationRepository) GetDiscrepancyByID(ctx context.Context, id string) (*models.ReconciliationDiscrepancy, error) {
+	query := `SELECT ` + discrepancyColumns + ` FROM settlement_discrepancies WHERE id = $1`
+
+	discrepancy, err := scanDiscrepancy(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("discrepancy not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get discrepancy: %w", err)
+	}
+
+	return discrepancy, nil
+}
+
+// ListDiscrepancies retrieves discrepancies matching the filter, oldest first
+func (r *PostgresReconciliationRepository) ListDiscrepancies(ctx context.Context, filter DiscrepancyFilter) ([]*models.ReconciliationDiscrepancy, error) {
+	query := `SELECT ` + discrepancyColumns + ` FROM settlement_discrepancies
+		WHERE ($1 = '' OR run_id::text = $1) AND ($2 = '' OR status = $2) AND ($3 = '' OR type = $3)
+		ORDER BY created_at, transaction_id
+		LIMIT $4 OFFSET $5`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.RunID, filter.Status, filter.Type, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var discrepancies []*models.ReconciliationDiscrepancy
+	for rows.Next() {
+		discrepancy, err := scanDiscrepancy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
+
+// ResolveDiscrepancy closes an open discrepancy
+func (r *PostgresReconciliationRepository) ResolveDiscrepancy(ctx context.Context, discrepancy *models.ReconciliationDiscrepancy) error {
+	query := `
+		UPDATE settlement_discrepancies SET
+			status = $2, resolution = $3, resolved_by = $4, resolved_at = $5, updated_at = $6
+		WHERE id = $1 AND status = 'open'`
+
+	result, err := r.db.ExecContext(ctx, query,
+		discrepancy.ID, discrepancy.Status, discrepancy.Resolution, discrepancy.ResolvedBy,
+		discrepancy.ResolvedAt, discrepancy.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to resolve discrepancy: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("discrepancy %s is not open", discrepancy.ID)
+	}
+
+	return nil
+}
+
+func scanReconciliationRun(row rowScanner) (*models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	err := row.Scan(
+		&run.ID, &run.Source, &run.Checksum, &run.PeriodStart, &run.PeriodEnd,
+		&run.RecordCount, &run.MatchedCount, &run.DiscrepancyCount, &run.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func scanDiscrepancy(row rowScanner) (*models.ReconciliationDiscrepancy, error) {
+	var d models.ReconciliationDiscrepancy
+	var resolvedAt sql.NullTime
+
+	err := row.Scan(
+		&d.ID, &d.RunID, &d.Type, &d.RecordType, &d.TransactionID,
+		&d.PaymentID, &d.RefundID, &d.Currency, &d.LedgerAmount, &d.SettledAmount,
+		&d.LedgerStatus, &d.SettlementStatus, &d.Detail, &d.Status, &d.Resolution, &d.ResolvedBy, &resolvedAt,
+		&d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolvedAt.Valid {
+		d.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &d, nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/payment-service/internal/risk"
+	"github.com/shopsphere/shared/models"
+)
+
+// RiskRepository stores risk assessments and answers the payment history
+// questions the risk rules ask
+type RiskRepository interface {
+	risk.History
+
+	CreateAssessment(ctx context.Context, assessment *models.RiskAssessment) error
+	GetAssessmentByID(ctx context.Context, id string) (*models.RiskAssessment, error)
+	GetAssessmentsByPaymentID(ctx context.Context, paymentID string) ([]*models.RiskAssessment, error)
+	ListAssessments(ctx context.Context, filter RiskAssessmentFilter) ([]*models.RiskAssessment, error)
+}
+
+// RiskAssessmentFilter narrows an assessment listing; empty fields match everything
+type RiskAssessmentFilter struct {
+	Decision     models.RiskDecision
+	ReviewStatus models.RiskReviewStatus
+	UserID       string
+	Limit        int
+	Offset       int
+}
+
+// successfulPaymentStatuses are the statuses of payments that got their funds
+const successfulPaymentStatuses = `('authorized', 'partially_captured', 'completed', 'partially_refunded', 'refunded', 'disputed')`
+
+// PostgresRiskRepository implements RiskRepository using PostgreSQL
+type PostgresRiskRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRiskRepository creates a new PostgreSQL risk repository
+func NewPostgresRiskRepository(db *sql.DB) RiskRepository {
+	return &PostgresRiskRepository{db: db}
+}
+
+const riskAssessmentColumns = `id, payment_id, order_id, user_id, amount, currency, score, decision, rules,
+	review_status, reviewed_by, review_note, reviewed_at, created_at, updated_at`
+
+// CountRecentPayments counts the payment attempts since the given time that
+// share the user, payment method or IP address
+func (r *PostgresRiskRepository) CountRecentPayments(ctx context.Context, dimension risk.Dimension, value string, since time.Time, excludePaymentID string) (int, error) {
+	var column string
+	switch dimension {
+	case risk.DimensionUser:
+		column = "user_id"
+	case risk.DimensionCard:
+		column = "payment_method_id"
+	case risk.DimensionIP:
+		column = "risk_context->>'ip_address'"
+	default:
+		return 0, fmt.Errorf("unknown velocity dimension: %s", dimension)
+	}
+
+	query := `SELECT COUNT(*) FROM payments
+		WHERE ` + column + ` = $1 AND created_at >= $2 AND id::text <> $3`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, value, since, excludePaymentID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recent payments: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetUserHistory summarises the user's successful payments in the currency
+// and finds their first payment in any currency
+func (r *PostgresRiskRepository) GetUserHistory(ctx context.Context, userID, currency, excludePaymentID string) (*risk.UserHistory, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE currency = $2 AND status IN ` + successfulPaymentStatuses + `),
+			COALESCE(AVG(amount) FILTER (WHERE currency = $2 AND status IN ` + successfulPaymentStatuses + `), 0),
+			MIN(created_at)
+		FROM payments
+		WHERE user_id = $1 AND id::text <> $3`
+
+	var history risk.UserHistory
+	var firstPaymentAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID, currency, excludePaymentID).Scan(
+		&history.PaymentCount, &history.AverageAmount, &firstPaymentAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user payment history: %w", err)
+	}
+
+	if firstPaymentAt.Valid {
+		history.FirstPaymentAt = &firstPaymentAt.Time
+	}
+
+	return &history, nil
+}
+
+// CreateAssessment saves a risk assessment
+func (r *PostgresRiskRepository) CreateAssessment(ctx context.Context, assessment *models.RiskAssessment) error {
+	rulesJSON, err := json.Marshal(assessment.Rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal risk rules: %w", err)
+	}
+
+	query := `
+		INSERT INTO risk_assessments (` + riskAssessmentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+
+	_, err = r.db.ExecContext(ctx, query,
+		assessment.ID, assessment.PaymentID, assessment.OrderID, assessment.UserID,
+		assessment.Amount, assessment.Currency, assessment.Score, assessment.Decision, rulesJSON,
+		assessment.ReviewStatus, assessment.ReviewedBy, assessment.ReviewNote, assessment.ReviewedAt,
+		assessment.CreatedAt, assessment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create risk assessment: %w", err)
+	}
+
+	return nil
+}
+
+// GetAssessmentByID retrieves a risk assessment by ID
+func (r *PostgresRiskRepository) GetAssessmentByID(ctx context.Context, id string) (*models.RiskAssessment, error) {
+	query := `SELECT ` + riskAssessmentColumns + ` FROM risk_assessments WHERE id = $1`
+
+	assessment, err := scanRiskAssessment(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("risk assessment not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get risk assessment: %w", err)
+	}
+
+	return assessment, nil
+}
+
+// GetAssessmentsByPaymentID retrieves the assessments of a payment, oldest first
+func (r *PostgresRiskRepository) GetAssessmentsByPaymentID(ctx context.Context, paymentID string) ([]*models.RiskAssessment, error) {
+	query := `SELECT ` + riskAssessmentColumns + ` FROM risk_assessments
+		WHERE payment_id = $1
+		ORDER BY created_at`
+
+	return r.queryAssessments(ctx, query, paymentID)
+}
+
+// ListAssessments retrieves assessments matching the filter, oldest first so
+// the review queue is worked in order
+func (r *PostgresRiskRepository) ListAssessments(ctx context.Context, filter RiskAssessmentFilter) ([]*models.RiskAssessment, error) {
+	query := `SELECT ` + riskAssessmentColumns + ` FROM risk_assessments
+		WHERE ($1 = '' OR decision = $1) AND ($2 = '' OR review_status = $2) AND ($3 = '' OR user_id = $3)
+		ORDER BY created_at
+		LIMIT $4 OFFSET $5`
+
+	return r.queryAssessments(ctx, query, filter.Decision, filter.ReviewStatus, filter.UserID, filter.Limit, filter.Offset)
+}
+
+func (r *PostgresRiskRepository) queryAssessments(ctx context.Context, query string, args ...interface{}) ([]*models.RiskAssessment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list risk assessments: %w", err)
+	}
+	defer rows.Close()
+
+	var assessments []*models.RiskAssessment
+	for rows.Next() {
+		assessment, err := scanRiskAssessment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan risk assessment: %w", err)
+		}
+		assessments = append(assessments, assessment)
+	}
+
+	return assessments, rows.Err()
+}
+
+func scanRiskAssessment(row rowScanner) (*models.RiskAssessment, error) {
+	var a models.RiskAssessment
+	var rulesJSON []byte
+	var reviewedAt sql.NullTime
+
+	err := row.Scan(
+		&a.ID, &a.PaymentID, &a.OrderID, &a.UserID, &a.Amount, &a.Currency, &a.Score, &a.Decision, &rulesJSON,
+		&a.ReviewStatus, &a.ReviewedBy, &a.ReviewNote, &reviewedAt, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Rules = []models.RiskRuleHit{}
+	if len(rulesJSON) > 0 {
+		if err := json.Unmarshal(rulesJSON, &a.Rules); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal risk rules: %w", err)
+		}
+	}
+	if reviewedAt.Valid {
+		a.ReviewedAt = &reviewedAt.Time
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// StoredValueRepository stores gift cards, wallets and the append-only ledger
+// of their balance changes
+type StoredValueRepository interface {
+	// CreateGiftCard saves a new gift card together with the ledger entry loading it
+	CreateGiftCard(ctx context.Context, card *models.GiftCard, codeHash string, entry *models.StoredValueLedgerEntry) error
+	GetGiftCardByID(ctx context.Context, id string) (*models.GiftCard, error)
+	GetGiftCardByCodeHash(ctx context.Context, codeHash string) (*models.GiftCard, error)
+	UpdateGiftCardStatus(ctx context.Context, id string, status models.GiftCardStatus) error
+
+	GetWallet(ctx context.Context, userID, currency string) (*models.Wallet, error)
+	// GetOrCreateWallet returns the user's wallet in the currency, opening an empty one if needed
+	GetOrCreateWallet(ctx context.Context, userID, currency string) (*models.Wallet, error)
+
+	// ApplyEntry appends the entry and moves the account balance by its
+	// amount in one transaction. The account row is locked while it is
+	// checked, so concurrent debits cannot overdraw it. An entry for a
+	// payment or refund that was already applied is not applied again; the
+	// earlier entry is returned instead.
+	ApplyEntry(ctx context.Context, entry *models.StoredValueLedgerEntry) (*models.StoredValueLedgerEntry, error)
+	GetEntriesByAccount(ctx context.Context, accountType models.StoredValueAccountType, accountID string, limit, offset int) ([]*models.StoredValueLedgerEntry, error)
+	GetEntriesByPaymentID(ctx context.Context, paymentID string) ([]*models.StoredValueLedgerEntry, error)
+}
+
+// PostgresStoredValueRepository implements StoredValueRepository using PostgreSQL
+type PostgresStoredValueRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresStoredValueRepository creates a new PostgreSQL stored-value repository
+func NewPostgresStoredValueRepository(db *sql.DB) StoredValueRepository {
+	return &PostgresStoredValueRepository{db: db}
+}
+
+const giftCardColumns = `id, code_last4, currency, initial_balance, balance, status, issued_by, expires_at, created_at, updated_at`
+
+const walletColumns = `id, user_id, currency, balance, created_at, updated_at`
+
+const ledgerColumns = `id, account_type, account_id, type, amount, balance_after, currency,
+	payment_id, refund_id, note, created_by, created_at`
+
+// CreateGiftCard saves a new gift card and its issue entry
+func (r *PostgresStoredValueRepository) CreateGiftCard(ctx context.Context, card *models.GiftCard, codeHash string, entry *models.StoredValueLedgerEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO gift_cards (id, code_hash, code_last4, currency, initial_balance, balance, status,
+			issued_by, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err = tx.ExecContext(ctx, query,
+		card.ID, codeHash, card.CodeLast4, card.Currency, card.InitialBalance, card.Balance, card.Status,
+		card.IssuedBy, card.ExpiresAt, card.CreatedAt, card.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create gift card: %w", err)
+	}
+
+	if err := insertLedgerEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit gift card: %w", err)
+	}
+
+	return nil
+}
+
+// GetGiftCardByID retrieves a gift card by ID
+func (r *PostgresStoredValueRepository) GetGiftCardByID(ctx context.Context, id string) (*models.GiftCard, error) {
+	query := `SELECT ` + giftCardColumns + ` FROM gift_cards WHERE id = $1`
+	return r.getGiftCard(ctx, query, id)
+}
+
+// GetGiftCardByCodeHash retrieves a gift card by the hash of its code
+func (r *PostgresStoredValueRepository) GetGiftCardByCodeHash(ctx context.Context, codeHash string) (*models.GiftCard, error) {
+	query := `SELECT ` + giftCardColumns + ` FROM gift_cards WHERE code_hash = $1`
+	return r.getGiftCard(ctx, query, codeHash)
+}
+
+func (r *PostgresStoredValueRepository) getGiftCard(ctx context.Context, query string, arg string) (*models.GiftCard, error) {
+	var card models.GiftCard
+	var expiresAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+		&card.ID, &card.CodeLast4, &card.Currency, &card.InitialBalance, &card.Balance, &card.Status,
+		&card.IssuedBy, &expiresAt, &card.CreatedAt, &card.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("gift card not found")
+		}
+		return nil, fmt.Errorf("failed to get gift card: %w", err)
+	}
+
+	if expiresAt.Valid {
+		card.ExpiresAt = &expiresAt.Time
+	}
+
+	return &card, nil
+}
+
+// UpdateGiftCardStatus enables or disables a gift card
+func (r *PostgresStoredValueRepository) UpdateGiftCardStatus(ctx context.Context, id string, status models.GiftCardStatus) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE gift_cards SET status = $2, updated_at = $3 WHERE id = $1`, id, status, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update gift card status: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("gift card not found")
+	}
+
+	return nil
+}
+
+// GetWallet retrieves the user's wallet in a currency
+func (r *PostgresStoredValueRepository) GetWallet(ctx context.Context, userID, currency string) (*models.Wallet, error) {
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE user_id = $1 AND currency = $2`
+
+	var wallet models.Wallet
+	err := r.db.QueryRowContext(ctx, query, userID, currency).Scan(
+		&wallet.ID, &wallet.UserID, &wallet.Currency, &wallet.Balance, &wallet.CreatedAt, &wallet.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("wallet not found")
+		}
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	return &wallet, nil
+}
+
+// GetOrCreateWallet returns the user's wallet in the currency, opening it if needed
+func (r *PostgresStoredValueRepository) GetOrCreateWallet(ctx context.Context, userID, currency string) (*models.Wallet, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO wallets (user_id, currency, balance) VALUES ($1, $2, 0)
+		ON CONFLICT (user_id, currency) DO NOTHING`, userID, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wallet: %w", err)
+	}
+
+	return r.GetWallet(ctx, userID, currency)
+}
+
+// ApplyEntry appends a ledger entry and moves the account balance with it
+func (r *PostgresStoredValueRepository) ApplyEntry(ctx context.Context, entry *models.StoredValueLedgerEntry) (*models.StoredValueLedgerEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the payment before the account, in the same order everywhere, so
+	// a redemption cannot race the payment being cancelled
+	if entry.Type == models.LedgerRedemption {
+		var status models.PaymentStatus
+		err := tx.QueryRowContext(ctx, `SELECT status FROM payments WHERE id = $1 FOR UPDATE`, entry.PaymentID).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("payment not found: %s", entry.PaymentID)
+			}
+			return nil, fmt.Errorf("failed to lock payment: %w", err)
+		}
+		if status != models.PaymentPending && status != models.PaymentProcessing {
+			return nil, fmt.Errorf("payment cannot be redeemed in current state: %s", status)
+		}
+	} else if entry.Type == models.LedgerReversal {
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM payments WHERE id = $1 FOR UPDATE`, entry.PaymentID); err != nil {
+			return nil, fmt.Errorf("failed to lock payment: %w", err)
+		}
+	}
+
+	balance, err := lockAccount(ctx, tx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retried redemptions, reversals and refund credits find their first entry
+	if existing, err := findAppliedEntry(ctx, tx, entry); err != nil || existing != nil {
+		return existing, err
+	}
+
+	newBalance := balance.Add(entry.Amount)
+	if newBalance.IsNegative() {
+		return nil, fmt.Errorf("insufficient balance: %s %s available", balance, entry.Currency)
+	}
+
+	table := "gift_cards"
+	if entry.AccountType == models.StoredValueWallet {
+		table = "wallets"
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE `+table+` SET balance = $2, updated_at = $3 WHERE id = $1`,
+		entry.AccountID, newBalance, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	entry.BalanceAfter = newBalance
+	if err := insertLedgerEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ledger entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// lockAccount locks the account an entry applies to and returns its balance.
+// Debits need an active, unexpired gift card; credits do not.
+func lockAccount(ctx context.Context, tx *sql.Tx, entry *models.StoredValueLedgerEntry) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	var currency string
+
+	switch entry.AccountType {
+	case models.StoredValueGiftCard:
+		var card models.GiftCard
+		var expiresAt sql.NullTime
+		err := tx.QueryRowContext(ctx, `SELECT balance, currency, status, expires_at FROM gift_cards WHERE id = $1 FOR UPDATE`,
+			entry.AccountID).Scan(&card.Balance, &card.Currency, &card.Status, &expiresAt)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return decimal.Zero, fmt.Errorf("gift card not found")
+			}
+			return decimal.Zero, fmt.Errorf("failed to lock gift card: %w", err)
+		}
+		if expiresAt.Valid {
+			card.ExpiresAt = &expiresAt.Time
+		}
+		if entry.Amount.IsNegative() && !card.IsRedeemable(time.Now()) {
+			return decimal.Zero, fmt.Errorf("gift card is not redeemable")
+		}
+		balance, currency = card.Balance, card.Currency
+	case models.StoredValueWallet:
+		err := tx.QueryRowContext(ctx, `SELECT balance, currency FROM wallets WHERE id = $1 FOR UPDATE`,
+			entry.AccountID).Scan(&balance, &currency)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return decimal.Zero, fmt.Errorf("wallet not found")
+			}
+			return decimal.Zero, fmt.Errorf("failed to lock wallet: %w", err)
+		}
+	default:
+		return decimal.Zero, fmt.Errorf("unknown stored-value account type: %s", entry.AccountType)
+	}
+
+	if currency != entry.Currency {
+		return decimal.Zero, fmt.Errorf("currency mismatch: account holds %s, entry is in %s", currency, entry.Currency)
+	}
+
+	return balance, nil
+}
+
+// findAppliedEntry returns the entry already recorded for the same payment
+// redemption or reversal, or the same refund, if there is one
+func findAppliedEntry(ctx context.Context, tx *sql.Tx, entry *models.StoredValueLedgerEntry) (*models.StoredValueLedgerEntry, error) {
+	var row *sql.Row
+	switch {
+	case entry.RefundID != "":
+		row = tx.QueryRowContext(ctx, `SELECT `+ledgerColumns+` FROM stored_value_ledger WHERE refund_id = $1`, entry.RefundID)
+	case entry.Type == models.LedgerRedemption || entry.Type == models.LedgerReversal:
+		row = tx.QueryRowContext(ctx, `SELECT `+ledgerColumns+` FROM stored_value_ledger WHERE payment_id = $1 AND type = $2`,
+			entry.PaymentID, entry.Type)
+	default:
+		return nil, nil
+	}
+
+	existing, err := scanLedgerEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ledger: %w", err)
+	}
+
+	return existing, nil
+}
+
+func insertLedgerEntry(ctx context.Context, tx *sql.Tx, entry *models.StoredValueLedgerEntry) error {
+	query := `
+		INSERT INTO stored_value_ledger (` + ledgerColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, NULLIF($9, '')::uuid, $10, $11, $12)`
+
+	_, err := tx.ExecContext(ctx, query,
+		entry.ID, entry.AccountType, entry.AccountID, entry.Type, entry.Amount, entry.BalanceAfter, entry.Currency,
+		entry.PaymentID, entry.RefundID, entry.Note, entry.CreatedBy, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to append ledger entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetEntriesByAccount retrieves an account's ledger, newest first
+func (r *PostgresStoredValueRepository) GetEntriesByAccount(ctx context.Context, accountType models.StoredValueAccountType, accountID string, limit, offset int) ([]*models.StoredValueLedgerEntry, error) {
+	query := `SELECT ` + ledgerColumns + ` FROM stored_value_ledger
+		WHERE account_type = $1 AND account_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`
+
+	return r.queryEntries(ctx, query, accountType, accountID, limit, offset)
+}
+
+// GetEntriesByPaymentID retrieves the ledger entries of a payment, oldest first
+func (r *PostgresStoredValueRepository) GetEntriesByPaymentID(ctx context.Context, paymentID string) ([]*models.StoredValueLedgerEntry, error) {
+	query := `SELECT ` + ledgerColumns + ` FROM stored_value_ledger
+		WHERE payment_id = $1
+		ORDER BY created_at`
+
+	return r.queryEntries(ctx, query, paymentID)
+}
+
+func (r *PostgresStoredValueRepository) queryEntries(ctx context.Context, query string, args ...interface{}) ([]*models.StoredValueLedgerEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.StoredValueLedgerEntry{}
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func scanLedgerEntry(row rowScanner) (*models.StoredValueLedgerEntry, error) {
+	var entry models.StoredValueLedgerEntry
+	var paymentID, refundID sql.NullString
+
+	err := row.Scan(
+		&entry.ID, &entry.AccountType, &entry.AccountID, &entry.Type, &entry.Amount, &entry.BalanceAfter,
+		&entry.Currency, &paymentID, &refundID, &entry.Note, &entry.CreatedBy, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.PaymentID = paymentID.String
+	entry.RefundID = refundID.String
+
+	return &entry, nil
+}
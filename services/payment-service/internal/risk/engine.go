@@ -0,0 +1,111 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// Dimension is what velocity is counted by
+type Dimension string
+
+const (
+	DimensionUser Dimension = "user"
+	DimensionCard Dimension = "card" // the payment method charged
+	DimensionIP   Dimension = "ip"
+)
+
+// UserHistory summarises a user's earlier payments
+type UserHistory struct {
+	// PaymentCount and AverageAmount cover successful payments in one currency
+	PaymentCount  int
+	AverageAmount decimal.Decimal
+	// FirstPaymentAt is the user's first payment in any currency, nil for a
+	// user who never paid before
+	FirstPaymentAt *time.Time
+}
+
+// History looks up the earlier payments that rules compare a payment with.
+// The payment being assessed is never part of the results.
+type History interface {
+	// CountRecentPayments counts payment attempts since the given time that
+	// share the value of the dimension
+	CountRecentPayments(ctx context.Context, dimension Dimension, value string, since time.Time, excludePaymentID string) (int, error)
+	// GetUserHistory summarises the user's earlier payments
+	GetUserHistory(ctx context.Context, userID, currency, excludePaymentID string) (*UserHistory, error)
+}
+
+// Rule scores one kind of risk. Evaluate returns nil when the rule does not
+// trigger on the payment.
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, payment *models.Payment, history History) (*models.RiskRuleHit, error)
+}
+
+// Config holds the score thresholds of the engine
+type Config struct {
+	// ReviewScore holds payments for manual review from this score up
+	ReviewScore int
+	// BlockScore blocks payments from this score up
+	BlockScore int
+}
+
+// DefaultConfig returns the default risk engine configuration
+func DefaultConfig() Config {
+	return Config{
+		ReviewScore: 50,
+		BlockScore:  80,
+	}
+}
+
+// Engine scores payments with a set of rules. The score of a payment is the
+// sum of the scores of the rules it triggers, capped at 100.
+type Engine struct {
+	history History
+	config  Config
+	rules   []Rule
+}
+
+// NewEngine creates a risk engine evaluating the given rules
+func NewEngine(history History, config Config, rules ...Rule) *Engine {
+	return &Engine{
+		history: history,
+		config:  config,
+		rules:   rules,
+	}
+}
+
+// Assess evaluates every rule against the payment and decides whether it may
+// go to the gateway
+func (e *Engine) Assess(ctx context.Context, payment *models.Payment) (*models.RiskAssessment, error) {
+	assessment := models.NewRiskAssessment(payment)
+
+	for _, rule := range e.rules {
+		hit, err := rule.Evaluate(ctx, payment, e.history)
+		if err != nil {
+			return nil, fmt.Errorf("risk rule %s failed: %w", rule.Name(), err)
+		}
+		if hit == nil {
+			continue
+		}
+		assessment.Rules = append(assessment.Rules, *hit)
+		assessment.Score += hit.Score
+	}
+
+	if assessment.Score > 100 {
+		assessment.Score = 100
+	}
+
+	switch {
+	case assessment.Score >= e.config.BlockScore:
+		assessment.Decision = models.RiskBlock
+	case assessment.Score >= e.config.ReviewScore:
+		assessment.Decision = models.RiskReview
+		assessment.ReviewStatus = models.RiskReviewPending
+	}
+
+	return assessment, nil
+}
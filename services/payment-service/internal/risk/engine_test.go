@@ -0,0 +1,149 @@
+package risk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHistory serves fixed velocity counts and user histories
+type fakeHistory struct {
+	counts map[Dimension]int
+	user   UserHistory
+}
+
+func (h *fakeHistory) CountRecentPayments(ctx context.Context, dimension Dimension, value string, since time.Time, excludePaymentID string) (int, error) {
+	return h.counts[dimension], nil
+}
+
+func (h *fakeHistory) GetUserHistory(ctx context.Context, userID, currency, excludePaymentID string) (*UserHistory, error) {
+	user := h.user
+	return &user, nil
+}
+
+func riskPayment(amount string) *models.Payment {
+	payment := models.NewPayment("order-1", "user-1", decimal.RequireFromString(amount), "USD", models.PaymentTypeCard)
+	payment.PaymentMethodID = "pm_1"
+	payment.RiskContext = models.PaymentRiskContext{
+		IPAddress:       "203.0.113.7",
+		BillingCountry:  "US",
+		ShippingCountry: "US",
+	}
+	return payment
+}
+
+func ruleNames(assessment *models.RiskAssessment) []string {
+	names := []string{}
+	for _, hit := range assessment.Rules {
+		names = append(names, hit.Rule)
+	}
+	return names
+}
+
+func TestEngine_Assess(t *testing.T) {
+	longAgo := time.Now().AddDate(-1, 0, 0)
+	lastWeek := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name     string
+		payment  func() *models.Payment
+		history  fakeHistory
+		decision models.RiskDecision
+		rules    []string
+	}{
+		{
+			name:     "regular customer",
+			payment:  func() *models.Payment { return riskPayment("80.00") },
+			history:  fakeHistory{user: UserHistory{PaymentCount: 4, AverageAmount: decimal.NewFromInt(60), FirstPaymentAt: &longAgo}},
+			decision: models.RiskAllow,
+			rules:    []string{},
+		},
+		{
+			name: "new account shipping abroad",
+			payment: func() *models.Payment {
+				payment := riskPayment("900.00")
+				payment.RiskContext.ShippingCountry = "NG"
+				return payment
+			},
+			history:  fakeHistory{user: UserHistory{FirstPaymentAt: &lastWeek}},
+			decision: models.RiskReview,
+			rules:    []string{"country_mismatch", "new_account_high_value"},
+		},
+		{
+			name:     "first payment is high value",
+			payment:  func() *models.Payment { return riskPayment("600.00") },
+			history:  fakeHistory{},
+			decision: models.RiskAllow,
+			rules:    []string{"new_account_high_value"},
+		},
+		{
+			name:     "card testing burst",
+			payment:  func() *models.Payment { return riskPayment("5.00") },
+			history:  fakeHistory{counts: map[Dimension]int{DimensionUser: 8, DimensionCard: 6, DimensionIP: 12}, user: UserHistory{FirstPaymentAt: &longAgo}},
+			decision: models.RiskBlock,
+			rules:    []string{"velocity_user", "velocity_card", "velocity_ip"},
+		},
+		{
+			name:     "amount outlier",
+			payment:  func() *models.Payment { return riskPayment("450.00") },
+			history:  fakeHistory{counts: map[Dimension]int{DimensionCard: 3}, user: UserHistory{PaymentCount: 5, AverageAmount: decimal.NewFromInt(40), FirstPaymentAt: &longAgo}},
+			decision: models.RiskReview,
+			rules:    []string{"velocity_card", "amount_outlier"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := tt.history
+			engine := NewEngine(&history, DefaultConfig(), DefaultRules()...)
+
+			assessment, err := engine.Assess(context.Background(), tt.payment())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.decision, assessment.Decision)
+			assert.Equal(t, tt.rules, ruleNames(assessment))
+			if tt.decision == models.RiskReview {
+				assert.Equal(t, models.RiskReviewPending, assessment.ReviewStatus)
+			} else {
+				assert.Equal(t, models.RiskReviewNotRequired, assessment.ReviewStatus)
+			}
+		})
+	}
+}
+
+func TestEngine_Assess_CapsScore(t *testing.T) {
+	history := &fakeHistory{counts: map[Dimension]int{DimensionUser: 50, DimensionCard: 50, DimensionIP: 50}}
+	engine := NewEngine(history, DefaultConfig(), DefaultRules()...)
+
+	payment := riskPayment("2000.00")
+	payment.RiskContext.ShippingCountry = "BR"
+	assessment, err := engine.Assess(context.Background(), payment)
+
+	require.NoError(t, err)
+	assert.Equal(t, 100, assessment.Score)
+	assert.Equal(t, models.RiskBlock, assessment.Decision)
+}
+
+func TestNewAccountRule_UsesAccountCreatedAt(t *testing.T) {
+	rule := &NewAccountRule{MaxAge: 7 * 24 * time.Hour, Thresholds: DefaultHighValueThresholds(), Score: 40}
+	history := &fakeHistory{}
+
+	established := time.Now().AddDate(0, -6, 0)
+	payment := riskPayment("800.00")
+	payment.RiskContext.AccountCreatedAt = &established
+	hit, err := rule.Evaluate(context.Background(), payment, history)
+	require.NoError(t, err)
+	assert.Nil(t, hit)
+
+	// Currencies without a threshold are not judged
+	payment = riskPayment("800.00")
+	payment.Currency = "CHF"
+	hit, err = rule.Evaluate(context.Background(), payment, history)
+	require.NoError(t, err)
+	assert.Nil(t, hit)
+}
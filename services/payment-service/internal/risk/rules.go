@@ -0,0 +1,190 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultRules returns the standard rule set. A payment from a new account
+// shipping abroad is held for review; bursts on one card, user and IP
+// address are blocked.
+func DefaultRules() []Rule {
+	return []Rule{
+		&VelocityRule{Dimension: DimensionUser, Window: time.Hour, Limit: 5, Score: 30},
+		&VelocityRule{Dimension: DimensionCard, Window: time.Hour, Limit: 3, Score: 35},
+		&VelocityRule{Dimension: DimensionIP, Window: time.Hour, Limit: 10, Score: 30},
+		&CountryMismatchRule{Score: 25},
+		&AmountOutlierRule{Multiplier: decimal.NewFromInt(5), MinPayments: 3, Score: 30},
+		&NewAccountRule{MaxAge: 7 * 24 * time.Hour, Thresholds: DefaultHighValueThresholds(), Score: 40},
+	}
+}
+
+// DefaultHighValueThresholds returns the amounts, per currency, from which a
+// payment counts as high value
+func DefaultHighValueThresholds() map[string]decimal.Decimal {
+	return map[string]decimal.Decimal{
+		"USD": decimal.NewFromInt(500),
+		"EUR": decimal.NewFromInt(500),
+		"GBP": decimal.NewFromInt(400),
+		"CAD": decimal.NewFromInt(700),
+		"AUD": decimal.NewFromInt(750),
+		"JPY": decimal.NewFromInt(75000),
+	}
+}
+
+// VelocityRule triggers when too many payments were attempted by the same
+// user, with the same card or from the same IP address within a window
+type VelocityRule struct {
+	Dimension Dimension
+	Window    time.Duration
+	// Limit is the number of earlier attempts in the window that triggers the rule
+	Limit int
+	Score int
+}
+
+// Name returns the rule name
+func (r *VelocityRule) Name() string {
+	return "velocity_" + string(r.Dimension)
+}
+
+// Evaluate counts the earlier attempts sharing the payment's user, card or IP address
+func (r *VelocityRule) Evaluate(ctx context.Context, payment *models.Payment, history History) (*models.RiskRuleHit, error) {
+	var value string
+	switch r.Dimension {
+	case DimensionUser:
+		value = payment.UserID
+	case DimensionCard:
+		value = payment.PaymentMethodID
+	case DimensionIP:
+		value = payment.RiskContext.IPAddress
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	count, err := history.CountRecentPayments(ctx, r.Dimension, value, time.Now().Add(-r.Window), payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count < r.Limit {
+		return nil, nil
+	}
+
+	return &models.RiskRuleHit{
+		Rule:   r.Name(),
+		Score:  r.Score,
+		Detail: fmt.Sprintf("%d earlier payments by %s %s in the last %s", count, r.Dimension, value, r.Window),
+	}, nil
+}
+
+// CountryMismatchRule triggers when the billing and shipping countries differ
+type CountryMismatchRule struct {
+	Score int
+}
+
+// Name returns the rule name
+func (r *CountryMismatchRule) Name() string {
+	return "country_mismatch"
+}
+
+// Evaluate compares the billing and shipping countries
+func (r *CountryMismatchRule) Evaluate(ctx context.Context, payment *models.Payment, history History) (*models.RiskRuleHit, error) {
+	billing := strings.TrimSpace(payment.RiskContext.BillingCountry)
+	shipping := strings.TrimSpace(payment.RiskContext.ShippingCountry)
+	if billing == "" || shipping == "" || strings.EqualFold(billing, shipping) {
+		return nil, nil
+	}
+
+	return &models.RiskRuleHit{
+		Rule:   r.Name(),
+		Score:  r.Score,
+		Detail: fmt.Sprintf("billing country %s differs from shipping country %s", billing, shipping),
+	}, nil
+}
+
+// AmountOutlierRule triggers when a payment is far above what the user
+// usually pays. Users with too few earlier payments are not judged.
+type AmountOutlierRule struct {
+	// Multiplier of the user's average amount from which a payment is an outlier
+	Multiplier  decimal.Decimal
+	MinPayments int
+	Score       int
+}
+
+// Name returns the rule name
+func (r *AmountOutlierRule) Name() string {
+	return "amount_outlier"
+}
+
+// Evaluate compares the amount with the user's average in the same currency
+func (r *AmountOutlierRule) Evaluate(ctx context.Context, payment *models.Payment, history History) (*models.RiskRuleHit, error) {
+	userHistory, err := history.GetUserHistory(ctx, payment.UserID, payment.Currency, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if userHistory.PaymentCount < r.MinPayments || !userHistory.AverageAmount.IsPositive() {
+		return nil, nil
+	}
+
+	limit := userHistory.AverageAmount.Mul(r.Multiplier)
+	if payment.Amount.LessThanOrEqual(limit) {
+		return nil, nil
+	}
+
+	return &models.RiskRuleHit{
+		Rule:  r.Name(),
+		Score: r.Score,
+		Detail: fmt.Sprintf("amount %s %s is over %sx the user's average of %s over %d payments",
+			payment.Amount, payment.Currency, r.Multiplier, userHistory.AverageAmount.Round(2), userHistory.PaymentCount),
+	}, nil
+}
+
+// NewAccountRule triggers on high-value payments from accounts younger than
+// MaxAge. Payments in currencies without a threshold are not judged.
+type NewAccountRule struct {
+	MaxAge     time.Duration
+	Thresholds map[string]decimal.Decimal
+	Score      int
+}
+
+// Name returns the rule name
+func (r *NewAccountRule) Name() string {
+	return "new_account_high_value"
+}
+
+// Evaluate checks the account age of users making high-value payments
+func (r *NewAccountRule) Evaluate(ctx context.Context, payment *models.Payment, history History) (*models.RiskRuleHit, error) {
+	threshold, ok := r.Thresholds[strings.ToUpper(payment.Currency)]
+	if !ok || payment.Amount.LessThan(threshold) {
+		return nil, nil
+	}
+
+	createdAt := payment.RiskContext.AccountCreatedAt
+	if createdAt == nil {
+		userHistory, err := history.GetUserHistory(ctx, payment.UserID, payment.Currency, payment.ID)
+		if err != nil {
+			return nil, err
+		}
+		createdAt = userHistory.FirstPaymentAt
+	}
+
+	detail := fmt.Sprintf("first payment of the account is %s %s", payment.Amount, payment.Currency)
+	if createdAt != nil {
+		age := time.Since(*createdAt)
+		if age >= r.MaxAge {
+			return nil, nil
+		}
+		detail = fmt.Sprintf("payment of %s %s from an account %s old", payment.Amount, payment.Currency, age.Round(time.Hour))
+	}
+
+	return &models.RiskRuleHit{
+		Rule:   r.Name(),
+		Score:  r.Score,
+		Detail: detail,
+	}, nil
+}
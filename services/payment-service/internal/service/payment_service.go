@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/shopsphere/payment-service/internal/gateway"
 	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 )
@@ -22,6 +25,14 @@ type PaymentService interface {
 	GetPaymentsByOrder(ctx context.Context, orderID string) ([]*models.Payment, error)
 	GetUserPayments(ctx context.Context, userID string, limit, offset int) ([]*models.Payment, error)
 	CancelPayment(ctx context.Context, paymentID string, reason string) error
+
+	// Authorization and capture
+	CapturePayment(ctx context.Context, paymentID string, req *CapturePaymentRequest) (*models.Payment, error)
+	CaptureForShipment(ctx context.Context, shipment *models.ShipmentShippedData) (*models.Payment, error)
+	GetPaymentCaptures(ctx context.Context, paymentID string) ([]*models.PaymentCapture, error)
+	VoidPayment(ctx context.Context, paymentID string, reason string) (*models.Payment, error)
+	VoidExpiredAuthorizations(ctx context.Context) (int, error)
+	RecoverPendingCaptures(ctx context.Context) (int, error)
 	
 	// Payment methods
 	CreatePaymentMethod(ctx context.Context, req *CreatePaymentMethodRequest) (*models.PaymentMethodInfo, error)
@@ -33,15 +44,20 @@ type PaymentService interface {
 	
 	// Refunds
 	CreateRefund(ctx context.Context, req *CreateRefundRequest) (*models.Refund, error)
-	ProcessRefund(ctx context.Context, refundID string) (*models.Refund, error)
+	ProcessRefund(ctx context.Context, update *RefundUpdate) (*models.Refund, error)
 	GetRefund(ctx context.Context, refundID string) (*models.Refund, error)
 	GetPaymentRefunds(ctx context.Context, paymentID string) ([]*models.Refund, error)
 	
 	// Webhooks
 	ProcessWebhook(ctx context.Context, eventType string, payload []byte, signature string) error
+	GetUnprocessedWebhooks(ctx context.Context, limit int) ([]*models.PaymentWebhook, error)
+	ReplayWebhooks(ctx context.Context, limit int) (*WebhookReplayResult, error)
 	
 	// Retry logic
 	RetryFailedPayment(ctx context.Context, paymentID string) (*models.Payment, error)
+
+	// Risk review
+	ReviewPayment(ctx context.Context, assessmentID string, req *RiskReviewRequest) (*models.Payment, error)
 	
 	// Fraud detection
 	ValidatePayment(ctx context.Context, payment *models.Payment) error
@@ -57,6 +73,15 @@ type CreatePaymentRequest struct {
 	Description     string                 `json:"description"`
 	Metadata        map[string]interface{} `json:"metadata"`
 	AutoCapture     bool                   `json:"auto_capture"`
+	// Risk is scored by the risk engine when the payment is processed
+	Risk models.PaymentRiskContext `json:"risk"`
+	// Type defaults to card. Gift card and wallet payments are paid from
+	// stored value, by the gift card with GiftCardCode or the user's wallet.
+	Type         models.PaymentType `json:"type"`
+	GiftCardCode string             `json:"gift_card_code,omitempty"`
+	// AllowPartial lets a stored-value payment take the whole balance when
+	// it is less than the amount; the payment's amount is what it covers
+	AllowPartial bool `json:"allow_partial"`
 }
 
 type CreatePaymentMethodRequest struct {
@@ -97,19 +122,77 @@ type CreateRefundRequest struct {
 	Amount    decimal.Decimal `json:"amount,omitempty"`
 	Reason    string          `json:"reason" validate:"required"`
 	Metadata  map[string]interface{} `json:"metadata"`
+	// ToStoreCredit credits the refund to the customer's wallet instead of
+	// returning it to the original payment method
+	ToStoreCredit bool `json:"to_store_credit"`
+}
+
+// RefundUpdate is the gateway's report on a refund, usually from a webhook
+type RefundUpdate struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+}
+
+// WebhookReplayResult summarises a replay of unprocessed webhooks
+type WebhookReplayResult struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+}
+
+// CapturePaymentRequest captures part or all of an authorized payment. A zero
+// amount captures everything left on the authorization.
+type CapturePaymentRequest struct {
+	Amount     decimal.Decimal `json:"amount,omitempty"`
+	ShipmentID string          `json:"shipment_id,omitempty"`
+	Final      bool            `json:"final"`
+}
+
+// PaymentConfig holds payment settings
+type PaymentConfig struct {
+	// AuthorizationTTL is how long an authorization hold stays capturable
+	// before it is voided. Card networks release holds after about a week.
+	AuthorizationTTL time.Duration
+	// ExpiryBatchSize bounds the authorizations voided, and the pending
+	// captures recovered, per sweep
+	ExpiryBatchSize int
+	// PendingCaptureTimeout is how long a capture may wait for its gateway
+	// outcome before it is recovered from the gateway
+	PendingCaptureTimeout time.Duration
+	// WebhookSecret verifies gateway webhook signatures; when empty the
+	// gateway's own endpoint secret is used
+	WebhookSecret string
+}
+
+// DefaultPaymentConfig returns the default payment configuration
+func DefaultPaymentConfig() PaymentConfig {
+	return PaymentConfig{
+		AuthorizationTTL:      7 * 24 * time.Hour,
+		ExpiryBatchSize:       100,
+		PendingCaptureTimeout: 15 * time.Minute,
+	}
 }
 
 // paymentService implements PaymentService
 type paymentService struct {
-	repo    repository.PaymentRepository
-	gateway gateway.PaymentGateway
+	repo        repository.PaymentRepository
+	gateway     gateway.PaymentGateway
+	risk        RiskService
+	storedValue StoredValueService
+	config      PaymentConfig
 }
 
-// NewPaymentService creates a new payment service
-func NewPaymentService(repo repository.PaymentRepository, gateway gateway.PaymentGateway) PaymentService {
+// NewPaymentService creates a new payment service. Payments are scored by
+// risk before they are processed; a nil risk service skips the checks. Gift
+// card and wallet payments need the stored-value service and are refused
+// without one.
+func NewPaymentService(repo repository.PaymentRepository, gateway gateway.PaymentGateway, risk RiskService, storedValue StoredValueService, config PaymentConfig) PaymentService {
 	return &paymentService{
-		repo:    repo,
-		gateway: gateway,
+		repo:        repo,
+		gateway:     gateway,
+		risk:        risk,
+		storedValue: storedValue,
+		config:      config,
 	}
 }
 
@@ -120,14 +203,28 @@ func (s *paymentService) CreatePayment(ctx context.Context, req *CreatePaymentRe
 		return nil, fmt.Errorf("invalid payment request: %w", err)
 	}
 
+	// The amount must be chargeable in the currency's minor unit
+	money, err := currency.Lookup(req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment request: %w", err)
+	}
+	if !money.IsRounded(req.Amount) {
+		return nil, fmt.Errorf("invalid payment request: amount %s has more decimals than %s allows", req.Amount, money.Code)
+	}
+
+	if req.Type.IsStoredValue() {
+		return s.createStoredValuePayment(ctx, req, money)
+	}
+
 	// Create payment record
-	payment := models.NewPayment(req.OrderID, req.UserID, req.Amount, req.Currency, models.PaymentTypeCard)
+	payment := models.NewPayment(req.OrderID, req.UserID, req.Amount, money.Code, models.PaymentTypeCard)
 	payment.PaymentMethodID = req.PaymentMethodID
+	payment.RiskContext = normalizeRiskContext(req.Risk)
 
 	// Create payment intent in gateway
 	gatewayReq := &gateway.CreatePaymentIntentRequest{
 		Amount:             req.Amount,
-		Currency:           req.Currency,
+		Currency:           money.Code,
 		PaymentMethodID:    req.PaymentMethodID,
 		Description:        req.Description,
 		Metadata:           req.Metadata,
@@ -169,6 +266,60 @@ func (s *paymentService) CreatePayment(ctx context.Context, req *CreatePaymentRe
 	return payment, nil
 }
 
+// createStoredValuePayment creates a payment drawing on a gift card or the
+// user's wallet. Nothing is debited until the payment is processed.
+func (s *paymentService) createStoredValuePayment(ctx context.Context, req *CreatePaymentRequest, money currency.Currency) (*models.Payment, error) {
+	if s.storedValue == nil {
+		return nil, fmt.Errorf("invalid payment request: %s payments are not enabled", req.Type)
+	}
+
+	account, err := s.storedValue.ResolveAccount(ctx, req.Type, req.UserID, money.Code, req.GiftCardCode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment request: %w", err)
+	}
+
+	amount := req.Amount
+	if account.Balance.LessThan(amount) {
+		if !req.AllowPartial || !account.Balance.IsPositive() {
+			return nil, fmt.Errorf("invalid payment request: insufficient balance: %s %s available", account.Balance, account.Currency)
+		}
+		amount = account.Balance
+	}
+
+	// The account paying is recorded as the payment method
+	payment := models.NewPayment(req.OrderID, req.UserID, amount, money.Code, req.Type)
+	payment.PaymentMethodID = account.ID
+	payment.RiskContext = normalizeRiskContext(req.Risk)
+	payment.GatewayResponse = models.GatewayResponse{
+		GatewayID:       storedValueGatewayID,
+		Status:          "pending",
+		ResponseCode:    "200",
+		ResponseMessage: "Stored-value payment created",
+		ProcessedAt:     time.Now(),
+	}
+
+	if err := s.repo.CreatePayment(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Stored-value payment created", map[string]interface{}{
+		"payment_id": payment.ID,
+		"order_id":   payment.OrderID,
+		"type":       payment.Type,
+		"amount":     payment.Amount,
+		"requested":  req.Amount,
+	})
+
+	return payment, nil
+}
+
+// normalizeRiskContext upper-cases the country codes the risk rules compare
+func normalizeRiskContext(risk models.PaymentRiskContext) models.PaymentRiskContext {
+	risk.BillingCountry = strings.ToUpper(strings.TrimSpace(risk.BillingCountry))
+	risk.ShippingCountry = strings.ToUpper(strings.TrimSpace(risk.ShippingCountry))
+	return risk
+}
+
 // ProcessPayment processes a payment by confirming it with the gateway
 func (s *paymentService) ProcessPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
 	// Get payment from database
@@ -182,6 +333,100 @@ func (s *paymentService) ProcessPayment(ctx context.Context, paymentID string) (
 		return nil, fmt.Errorf("payment cannot be processed in current state: %s", payment.Status)
 	}
 
+	// Score the payment before it reaches the gateway
+	if s.risk != nil {
+		stopped, err := s.screenPayment(ctx, payment)
+		if err != nil || stopped != nil {
+			return stopped, err
+		}
+	}
+
+	return s.confirmPayment(ctx, payment)
+}
+
+// screenPayment runs the risk checks on a payment about to be processed. It
+// returns the payment when it was held for review or blocked, and nil when it
+// may go on to the gateway.
+func (s *paymentService) screenPayment(ctx context.Context, payment *models.Payment) (*models.Payment, error) {
+	assessment, err := s.risk.AssessPayment(ctx, payment)
+	if err != nil {
+		// Fail closed; the payment stays pending and can be processed again
+		return nil, fmt.Errorf("payment risk check failed: %w", err)
+	}
+
+	switch assessment.Decision {
+	case models.RiskReview:
+		reason := fmt.Sprintf("held for risk review (score %d)", assessment.Score)
+		if err := s.repo.UpdatePaymentStatus(ctx, payment.ID, models.PaymentUnderReview, payment.TransactionID, reason, &payment.GatewayResponse); err != nil {
+			return nil, fmt.Errorf("failed to hold payment for review: %w", err)
+		}
+
+		utils.Logger.Info(ctx, "Payment held for risk review", map[string]interface{}{
+			"payment_id":    payment.ID,
+			"assessment_id": assessment.ID,
+			"score":         assessment.Score,
+		})
+		return s.repo.GetPaymentByID(ctx, payment.ID)
+	case models.RiskBlock:
+		return s.blockPayment(ctx, payment, fmt.Sprintf("blocked by risk checks (score %d)", assessment.Score), nil)
+	}
+
+	return nil, nil
+}
+
+// blockPayment stops a payment for risk reasons and cancels its payment
+// intent. Stored-value payments have no intent and nothing redeemed yet. A
+// payment blocked in review is saved together with the review that rejected it.
+func (s *paymentService) blockPayment(ctx context.Context, payment *models.Payment, reason string, review *models.RiskAssessment) (*models.Payment, error) {
+	gatewayID := "stripe"
+	if payment.Type.IsStoredValue() {
+		gatewayID = storedValueGatewayID
+	}
+
+	gatewayResponse := &models.GatewayResponse{
+		GatewayID:       gatewayID,
+		TransactionID:   payment.TransactionID,
+		Status:          "canceled",
+		ResponseCode:    "200",
+		ResponseMessage: reason,
+		ProcessedAt:     time.Now(),
+	}
+
+	var err error
+	if review != nil {
+		err = s.repo.CompleteRiskReview(ctx, review, models.PaymentBlocked, payment.TransactionID, reason, gatewayResponse)
+	} else {
+		err = s.repo.UpdatePaymentStatus(ctx, payment.ID, models.PaymentBlocked, payment.TransactionID, reason, gatewayResponse)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to block payment: %w", err)
+	}
+
+	if !payment.Type.IsStoredValue() {
+		if _, err := s.gateway.CancelPaymentIntent(ctx, payment.TransactionID); err != nil {
+			// The intent was never confirmed, so it cannot be charged either way
+			utils.Logger.Error(ctx, "Failed to cancel payment intent of blocked payment", err, map[string]interface{}{
+				"payment_id": payment.ID,
+			})
+		}
+	}
+
+	utils.Logger.Info(ctx, "Payment blocked", map[string]interface{}{
+		"payment_id": payment.ID,
+		"reason":     reason,
+	})
+
+	return s.repo.GetPaymentByID(ctx, payment.ID)
+}
+
+// confirmPayment submits a payment to the gateway and records the outcome
+func (s *paymentService) confirmPayment(ctx context.Context, payment *models.Payment) (*models.Payment, error) {
+	if payment.Type.IsStoredValue() {
+		return s.redeemPayment(ctx, payment)
+	}
+
+	paymentID := payment.ID
+
 	// Update status to processing
 	payment.Status = models.PaymentProcessing
 	if err := s.repo.UpdatePayment(ctx, payment); err != nil {
@@ -219,9 +464,14 @@ func (s *paymentService) ProcessPayment(ctx context.Context, paymentID string) (
 	case "succeeded":
 		status = models.PaymentCompleted
 	case "requires_capture":
-		status = models.PaymentCompleted // Will be captured later if needed
+		// Funds are held; CapturePayment collects them as the order ships
+		status = models.PaymentAuthorized
 	case "processing":
+		// Still with the gateway; a webhook settles it
 		status = models.PaymentProcessing
+	case "requires_action":
+		// Waiting on the customer (3-D Secure); a webhook settles it
+		status = models.PaymentRequiresAction
 	default:
 		status = models.PaymentFailed
 	}
@@ -239,7 +489,12 @@ func (s *paymentService) ProcessPayment(ctx context.Context, paymentID string) (
 		ProcessedAt: time.Now(),
 	}
 
-	if err := s.repo.UpdatePaymentStatus(ctx, paymentID, status, result.ID, "", gatewayResponse); err != nil {
+	if status == models.PaymentAuthorized {
+		expiresAt := time.Now().Add(s.config.AuthorizationTTL)
+		if err := s.repo.AuthorizePayment(ctx, paymentID, result.ID, expiresAt, gatewayResponse); err != nil {
+			return nil, fmt.Errorf("failed to update payment status: %w", err)
+		}
+	} else if err := s.repo.UpdatePaymentStatus(ctx, paymentID, status, result.ID, "", gatewayResponse); err != nil {
 		return nil, fmt.Errorf("failed to update payment status: %w", err)
 	}
 
@@ -258,6 +513,63 @@ func (s *paymentService) ProcessPayment(ctx context.Context, paymentID string) (
 	return payment, nil
 }
 
+// redeemPayment completes a stored-value payment by debiting its gift card or
+// wallet. A payment whose redemption is retried after it already went through
+// completes with the first redemption.
+func (s *paymentService) redeemPayment(ctx context.Context, payment *models.Payment) (*models.Payment, error) {
+	var entry *models.StoredValueLedgerEntry
+	err := fmt.Errorf("%s payments are not enabled", payment.Type)
+	if s.storedValue != nil {
+		entry, err = s.storedValue.Redeem(ctx, payment)
+	}
+	if err != nil {
+		gatewayResponse := &models.GatewayResponse{
+			GatewayID:       storedValueGatewayID,
+			Status:          "failed",
+			ResponseCode:    "400",
+			ResponseMessage: err.Error(),
+			ProcessedAt:     time.Now(),
+		}
+
+		if updateErr := s.repo.UpdatePaymentStatus(ctx, payment.ID, models.PaymentFailed, payment.TransactionID, err.Error(), gatewayResponse); updateErr != nil {
+			utils.Logger.Error(ctx, "Failed to update payment status to failed", updateErr)
+		}
+
+		return nil, fmt.Errorf("payment processing failed: %w", err)
+	}
+
+	gatewayResponse := &models.GatewayResponse{
+		GatewayID:       storedValueGatewayID,
+		TransactionID:   entry.ID,
+		Status:          "succeeded",
+		ResponseCode:    "200",
+		ResponseMessage: "Stored value redeemed",
+		RawResponse: map[string]interface{}{
+			"account_type":  entry.AccountType,
+			"account_id":    entry.AccountID,
+			"balance_after": entry.BalanceAfter.String(),
+		},
+		ProcessedAt: time.Now(),
+	}
+
+	if err := s.repo.UpdatePaymentStatus(ctx, payment.ID, models.PaymentCompleted, entry.ID, "", gatewayResponse); err != nil {
+		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	payment, err = s.repo.GetPaymentByID(ctx, payment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get updated payment: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Payment processed successfully", map[string]interface{}{
+		"payment_id": payment.ID,
+		"status":     payment.Status,
+		"amount":     payment.Amount,
+	})
+
+	return payment, nil
+}
+
 // GetPayment retrieves a payment by ID
 func (s *paymentService) GetPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
 	return s.repo.GetPaymentByID(ctx, paymentID)
@@ -273,20 +585,41 @@ func (s *paymentService) GetUserPayments(ctx context.Context, userID string, lim
 	return s.repo.GetPaymentsByUserID(ctx, userID, limit, offset)
 }
 
-// CancelPayment cancels a payment
+// CancelPayment cancels a payment. An authorized payment has its hold voided.
+// A stored-value payment is cancelled before anything it redeemed is given
+// back, so no redemption can slip in after the reversal; cancelling it again
+// retries a reversal that failed.
 func (s *paymentService) CancelPayment(ctx context.Context, paymentID string, reason string) error {
 	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
 	if err != nil {
 		return fmt.Errorf("payment not found: %w", err)
 	}
 
-	if payment.Status != models.PaymentPending && payment.Status != models.PaymentProcessing {
+	if payment.IsCapturable() {
+		_, err := s.VoidPayment(ctx, paymentID, reason)
+		return err
+	}
+
+	storedValue := payment.Type.IsStoredValue() && s.storedValue != nil
+	switch payment.Status {
+	case models.PaymentPending, models.PaymentProcessing, models.PaymentRequiresAction, models.PaymentUnderReview:
+	case models.PaymentCancelled:
+		if storedValue {
+			return s.reverseRedemption(ctx, payment)
+		}
+		return fmt.Errorf("payment cannot be cancelled in current state: %s", payment.Status)
+	default:
 		return fmt.Errorf("payment cannot be cancelled in current state: %s", payment.Status)
 	}
 
+	gatewayID := "stripe"
+	if payment.Type.IsStoredValue() {
+		gatewayID = storedValueGatewayID
+	}
+
 	// Update payment status
 	gatewayResponse := &models.GatewayResponse{
-		GatewayID:       "stripe",
+		GatewayID:       gatewayID,
 		TransactionID:   payment.TransactionID,
 		Status:          "cancelled",
 		ResponseCode:    "200",
@@ -303,6 +636,268 @@ func (s *paymentService) CancelPayment(ctx context.Context, paymentID string, re
 		"reason":     reason,
 	})
 
+	if storedValue {
+		return s.reverseRedemption(ctx, payment)
+	}
+
+	return nil
+}
+
+// reverseRedemption gives back whatever a cancelled stored-value payment redeemed
+func (s *paymentService) reverseRedemption(ctx context.Context, payment *models.Payment) error {
+	if _, err := s.storedValue.ReverseRedemption(ctx, payment); err != nil {
+		return fmt.Errorf("failed to reverse stored-value redemption: %w", err)
+	}
+	return nil
+}
+
+// CapturePayment captures funds from an authorized payment. The capture is
+// reserved before the gateway is called so that concurrent captures cannot
+// exceed the authorization.
+func (s *paymentService) CapturePayment(ctx context.Context, paymentID string, req *CapturePaymentRequest) (*models.Payment, error) {
+	if req.Amount.IsNegative() {
+		return nil, fmt.Errorf("capture amount cannot be negative")
+	}
+
+	capture := models.NewPaymentCapture(paymentID, req.ShipmentID, req.Amount, req.Final)
+	payment, err := s.repo.ReserveCapture(ctx, capture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve capture: %w", err)
+	}
+
+	result, err := s.gateway.CapturePayment(ctx, payment.TransactionID, capture.Amount, payment.Currency, capture.Final)
+	if err != nil {
+		capture.Status = models.CaptureFailed
+		capture.FailureReason = err.Error()
+		if _, updateErr := s.repo.CompleteCapture(ctx, capture, nil); updateErr != nil {
+			utils.Logger.Error(ctx, "Failed to record failed capture", updateErr, map[string]interface{}{
+				"capture_id": capture.ID,
+			})
+		}
+		return nil, fmt.Errorf("failed to capture payment: %w", err)
+	}
+
+	capture.Status = models.CaptureSucceeded
+	capture.TransactionID = result.ID
+	gatewayResponse := &models.GatewayResponse{
+		GatewayID:       "stripe",
+		TransactionID:   result.ID,
+		Status:          result.Status,
+		ResponseCode:    "200",
+		ResponseMessage: "Payment captured successfully",
+		RawResponse: map[string]interface{}{
+			"status":          result.Status,
+			"amount_captured": result.AmountCaptured,
+		},
+		ProcessedAt: time.Now(),
+	}
+
+	payment, err = s.repo.CompleteCapture(ctx, capture, gatewayResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record capture: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Payment captured", map[string]interface{}{
+		"payment_id":      payment.ID,
+		"capture_id":      capture.ID,
+		"shipment_id":     capture.ShipmentID,
+		"amount":          capture.Amount,
+		"captured_amount": payment.CapturedAmount,
+		"status":          payment.Status,
+	})
+
+	return payment, nil
+}
+
+// CaptureForShipment captures the declared value of a shipped shipment from
+// the order's authorized payment, or everything left once the last shipment
+// has shipped. Orders without a capturable payment are skipped, so a nil
+// payment is returned.
+func (s *paymentService) CaptureForShipment(ctx context.Context, shipment *models.ShipmentShippedData) (*models.Payment, error) {
+	payments, err := s.repo.GetPaymentsByOrderID(ctx, shipment.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order payments: %w", err)
+	}
+
+	var payment *models.Payment
+	for _, p := range payments {
+		if p.IsCapturable() {
+			payment = p
+			break
+		}
+	}
+	if payment == nil {
+		utils.Logger.Info(ctx, "No authorized payment to capture for shipment", map[string]interface{}{
+			"shipment_id": shipment.ShipmentID,
+			"order_id":    shipment.OrderID,
+		})
+		return nil, nil
+	}
+
+	req := &CapturePaymentRequest{ShipmentID: shipment.ShipmentID}
+	switch {
+	case shipment.Final || shipment.DeclaredValue.GreaterThanOrEqual(payment.UncapturedAmount()):
+		// A zero amount takes whatever is left on the authorization
+		req.Final = true
+	case shipment.DeclaredValue.IsPositive():
+		req.Amount = shipment.DeclaredValue
+	default:
+		return payment, nil
+	}
+
+	captured, err := s.CapturePayment(ctx, payment.ID, req)
+	if errors.Is(err, repository.ErrShipmentAlreadyCaptured) {
+		// Redelivered shipment event
+		return s.repo.GetPaymentByID(ctx, payment.ID)
+	}
+	return captured, err
+}
+
+// GetPaymentCaptures retrieves the captures of a payment
+func (s *paymentService) GetPaymentCaptures(ctx context.Context, paymentID string) ([]*models.PaymentCapture, error) {
+	return s.repo.GetCapturesByPaymentID(ctx, paymentID)
+}
+
+// VoidPayment releases the uncaptured part of an authorization. A payment with
+// nothing captured is voided; a partly captured one is completed at the
+// amount already captured. The payment stays locked while the gateway
+// releases the hold, so a capture cannot be reserved against an
+// authorization being voided.
+func (s *paymentService) VoidPayment(ctx context.Context, paymentID string, reason string) (*models.Payment, error) {
+	payment, err := s.repo.VoidAuthorization(ctx, paymentID, func(payment *models.Payment) (*repository.AuthorizationVoid, error) {
+		result, err := s.gateway.CancelPaymentIntent(ctx, payment.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to void authorization: %w", err)
+		}
+
+		void := &repository.AuthorizationVoid{
+			Status:        models.PaymentVoided,
+			FailureReason: reason,
+			GatewayResponse: &models.GatewayResponse{
+				GatewayID:       "stripe",
+				TransactionID:   result.ID,
+				Status:          result.Status,
+				ResponseCode:    "200",
+				ResponseMessage: reason,
+				ProcessedAt:     time.Now(),
+			},
+		}
+		if payment.CapturedAmount.IsPositive() {
+			void.Status, void.FailureReason = payment.SettledStatus(), ""
+		}
+		return void, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Payment authorization voided", map[string]interface{}{
+		"payment_id":      paymentID,
+		"status":          payment.Status,
+		"released_amount": payment.UncapturedAmount(),
+		"reason":          reason,
+	})
+
+	return payment, nil
+}
+
+// VoidExpiredAuthorizations voids authorizations whose hold has expired and
+// returns how many were voided. Failures are logged and retried on the next
+// sweep.
+func (s *paymentService) VoidExpiredAuthorizations(ctx context.Context) (int, error) {
+	payments, err := s.repo.GetExpiredAuthorizations(ctx, time.Now(), s.config.ExpiryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired authorizations: %w", err)
+	}
+
+	voided := 0
+	for _, payment := range payments {
+		if _, err := s.VoidPayment(ctx, payment.ID, "authorization expired"); err != nil {
+			utils.Logger.Error(ctx, "Failed to void expired authorization", err, map[string]interface{}{
+				"payment_id": payment.ID,
+			})
+			continue
+		}
+		voided++
+	}
+
+	return voided, nil
+}
+
+// RecoverPendingCaptures settles captures left pending by a crash between
+// reserving a capture and recording its gateway outcome, and returns how many
+// were settled. A capture the gateway's captured amount covers is recorded as
+// succeeded; any other is recorded as failed, returning its amount to the
+// authorization. Failures are logged and retried on the next sweep.
+func (s *paymentService) RecoverPendingCaptures(ctx context.Context) (int, error) {
+	before := time.Now().Add(-s.config.PendingCaptureTimeout)
+	captures, err := s.repo.GetStalePendingCaptures(ctx, before, s.config.ExpiryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stale pending captures: %w", err)
+	}
+
+	recovered := 0
+	for _, capture := range captures {
+		if err := s.recoverCapture(ctx, capture); err != nil {
+			utils.Logger.Error(ctx, "Failed to recover pending capture", err, map[string]interface{}{
+				"capture_id": capture.ID,
+				"payment_id": capture.PaymentID,
+			})
+			continue
+		}
+		recovered++
+	}
+
+	return recovered, nil
+}
+
+// recoverCapture records the outcome of one stale pending capture from the
+// payment intent's state at the gateway. Captures are recovered oldest first,
+// so the payment's captured amount already includes earlier ones.
+func (s *paymentService) recoverCapture(ctx context.Context, capture *models.PaymentCapture) error {
+	payment, err := s.repo.GetPaymentByID(ctx, capture.PaymentID)
+	if err != nil {
+		return fmt.Errorf("payment not found: %w", err)
+	}
+
+	intent, err := s.gateway.GetPaymentIntent(ctx, payment.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	var gatewayResponse *models.GatewayResponse
+	if intent.AmountCaptured.GreaterThanOrEqual(payment.CapturedAmount.Add(capture.Amount)) {
+		capture.Status = models.CaptureSucceeded
+		capture.TransactionID = intent.ID
+		gatewayResponse = &models.GatewayResponse{
+			GatewayID:       "stripe",
+			TransactionID:   intent.ID,
+			Status:          intent.Status,
+			ResponseCode:    "200",
+			ResponseMessage: "Pending capture recovered from gateway",
+			RawResponse: map[string]interface{}{
+				"status":          intent.Status,
+				"amount_captured": intent.AmountCaptured,
+			},
+			ProcessedAt: time.Now(),
+		}
+	} else {
+		capture.Status = models.CaptureFailed
+		capture.FailureReason = "capture not found at gateway"
+	}
+
+	if _, err := s.repo.CompleteCapture(ctx, capture, gatewayResponse); err != nil {
+		return fmt.Errorf("failed to record recovered capture: %w", err)
+	}
+
+	utils.Logger.Warn(ctx, "Recovered pending capture", map[string]interface{}{
+		"payment_id":  payment.ID,
+		"capture_id":  capture.ID,
+		"shipment_id": capture.ShipmentID,
+		"amount":      capture.Amount,
+		"status":      capture.Status,
+	})
+
 	return nil
 }
 
@@ -411,53 +1006,74 @@ func (s *paymentService) SetDefaultPaymentMethod(ctx context.Context, userID, me
 	return s.repo.SetDefaultPaymentMethod(ctx, userID, methodID)
 }
 
-// CreateRefund creates a new refund
+// CreateRefund refunds part or, with a zero amount, all of what is left of a
+// captured payment. The refund is reserved against the payment before the
+// gateway is called, so concurrent refunds cannot together exceed the
+// captured amount. Refunds the gateway has not settled yet stay processing
+// until ProcessRefund completes them.
 func (s *paymentService) CreateRefund(ctx context.Context, req *CreateRefundRequest) (*models.Refund, error) {
 	// Validate request
 	if err := utils.ValidateStruct(req); err != nil {
 		return nil, fmt.Errorf("invalid refund request: %w", err)
 	}
-
-	// Get original payment
-	payment, err := s.repo.GetPaymentByID(ctx, req.PaymentID)
-	if err != nil {
-		return nil, fmt.Errorf("payment not found: %w", err)
+	if req.Amount.IsNegative() {
+		return nil, fmt.Errorf("refund amount cannot be negative")
 	}
-
-	if payment.Status != models.PaymentCompleted {
-		return nil, fmt.Errorf("payment cannot be refunded in current state: %s", payment.Status)
+	if req.ToStoreCredit && s.storedValue == nil {
+		return nil, fmt.Errorf("invalid refund request: store credit is not enabled")
 	}
 
-	// Determine refund amount
-	refundAmount := req.Amount
-	if refundAmount.IsZero() {
-		refundAmount = payment.Amount
+	// A partial refund must be payable in the payment currency's minor unit
+	if !req.Amount.IsZero() {
+		payment, err := s.repo.GetPaymentByID(ctx, req.PaymentID)
+		if err != nil {
+			return nil, err
+		}
+		money, err := currency.Lookup(payment.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refund request: %w", err)
+		}
+		if !money.IsRounded(req.Amount) {
+			return nil, fmt.Errorf("invalid refund request: amount %s has more decimals than %s allows", req.Amount, money.Code)
+		}
 	}
 
-	// Validate refund amount
-	if refundAmount.GreaterThan(payment.Amount) {
-		return nil, fmt.Errorf("refund amount cannot exceed payment amount")
+	// Reserve the refund against the payment's refundable balance
+	refund := models.NewRefund(req.PaymentID, "", req.Amount, "", req.Reason)
+	payment, err := s.repo.ReserveRefund(ctx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve refund: %w", err)
 	}
 
-	// Create refund record
-	refund := models.NewRefund(req.PaymentID, payment.OrderID, refundAmount, payment.Currency, req.Reason)
+	if payment.Type.IsStoredValue() || req.ToStoreCredit {
+		return s.creditRefund(ctx, payment, refund, req.ToStoreCredit)
+	}
 
 	// Create refund in gateway
 	gatewayReq := &gateway.CreateRefundRequest{
 		PaymentIntentID: payment.TransactionID,
-		Amount:          refundAmount,
+		Amount:          refund.Amount,
+		Currency:        payment.Currency,
 		Reason:          req.Reason,
 		Metadata:        req.Metadata,
 	}
 
 	gatewayResult, err := s.gateway.CreateRefund(ctx, gatewayReq)
 	if err != nil {
+		// Release the reservation
+		refund.Status = models.PaymentFailed
+		refund.FailureReason = err.Error()
+		if _, updateErr := s.repo.CompleteRefund(ctx, refund); updateErr != nil {
+			utils.Logger.Error(ctx, "Failed to record failed refund", updateErr, map[string]interface{}{
+				"refund_id": refund.ID,
+			})
+		}
 		return nil, fmt.Errorf("failed to create refund in gateway: %w", err)
 	}
 
 	// Update refund with gateway response
 	refund.TransactionID = gatewayResult.ID
-	refund.Status = models.PaymentStatus(gatewayResult.Status)
+	refund.Status = refundStatus(gatewayResult.Status)
 	refund.GatewayResponse = models.GatewayResponse{
 		GatewayID:       "stripe",
 		TransactionID:   gatewayResult.ID,
@@ -467,23 +1083,133 @@ func (s *paymentService) CreateRefund(ctx context.Context, req *CreateRefundRequ
 		ProcessedAt:     time.Now(),
 	}
 
-	// Save refund to database
-	if err := s.repo.CreateRefund(ctx, refund); err != nil {
+	if refund.IsFinal() {
+		if refund.Status == models.PaymentFailed {
+			refund.FailureReason = "refund " + gatewayResult.Status + " by gateway"
+		}
+		payment, err = s.repo.CompleteRefund(ctx, refund)
+	} else {
+		err = s.repo.UpdateRefund(ctx, refund)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to save refund: %w", err)
 	}
 
 	utils.Logger.Info(ctx, "Refund created successfully", map[string]interface{}{
-		"refund_id":  refund.ID,
-		"payment_id": req.PaymentID,
-		"amount":     refundAmount,
+		"refund_id":       refund.ID,
+		"payment_id":      req.PaymentID,
+		"amount":          refund.Amount,
+		"status":          refund.Status,
+		"refunded_amount": payment.RefundedAmount,
 	})
 
 	return refund, nil
 }
 
-// ProcessRefund processes a refund (placeholder for async processing)
-func (s *paymentService) ProcessRefund(ctx context.Context, refundID string) (*models.Refund, error) {
-	return s.repo.GetRefundByID(ctx, refundID)
+// creditRefund settles a reserved refund as stored value rather than through
+// the gateway; it completes straight away
+func (s *paymentService) creditRefund(ctx context.Context, payment *models.Payment, refund *models.Refund, toStoreCredit bool) (*models.Refund, error) {
+	var entry *models.StoredValueLedgerEntry
+	err := fmt.Errorf("%s payments are not enabled", payment.Type)
+	if s.storedValue != nil {
+		entry, err = s.storedValue.CreditRefund(ctx, payment, refund, toStoreCredit)
+	}
+	if err != nil {
+		// Release the reservation
+		refund.Status = models.PaymentFailed
+		refund.FailureReason = err.Error()
+		if _, updateErr := s.repo.CompleteRefund(ctx, refund); updateErr != nil {
+			utils.Logger.Error(ctx, "Failed to record failed refund", updateErr, map[string]interface{}{
+				"refund_id": refund.ID,
+			})
+		}
+		return nil, fmt.Errorf("failed to credit refund: %w", err)
+	}
+
+	refund.TransactionID = entry.ID
+	refund.Status = models.PaymentCompleted
+	refund.GatewayResponse = models.GatewayResponse{
+		GatewayID:       storedValueGatewayID,
+		TransactionID:   entry.ID,
+		Status:          "succeeded",
+		ResponseCode:    "200",
+		ResponseMessage: "Refund credited to " + string(entry.AccountType),
+		RawResponse: map[string]interface{}{
+			"account_type":  entry.AccountType,
+			"account_id":    entry.AccountID,
+			"balance_after": entry.BalanceAfter.String(),
+		},
+		ProcessedAt: time.Now(),
+	}
+
+	payment, err = s.repo.CompleteRefund(ctx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refund: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Refund created successfully", map[string]interface{}{
+		"refund_id":       refund.ID,
+		"payment_id":      payment.ID,
+		"amount":          refund.Amount,
+		"status":          refund.Status,
+		"account_type":    entry.AccountType,
+		"refunded_amount": payment.RefundedAmount,
+	})
+
+	return refund, nil
+}
+
+// ProcessRefund completes a refund the gateway settled asynchronously. Updates
+// for refunds that are still pending, or already completed or failed, change
+// nothing.
+func (s *paymentService) ProcessRefund(ctx context.Context, update *RefundUpdate) (*models.Refund, error) {
+	refund, err := s.repo.GetRefundByTransactionID(ctx, update.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("refund not found: %w", err)
+	}
+
+	status := refundStatus(update.Status)
+	if refund.IsFinal() || status == models.PaymentProcessing {
+		return refund, nil
+	}
+
+	refund.Status = status
+	refund.FailureReason = update.FailureReason
+	refund.GatewayResponse = models.GatewayResponse{
+		GatewayID:       "stripe",
+		TransactionID:   update.TransactionID,
+		Status:          update.Status,
+		ResponseCode:    "200",
+		ResponseMessage: update.FailureReason,
+		ProcessedAt:     time.Now(),
+	}
+
+	payment, err := s.repo.CompleteRefund(ctx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete refund: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Refund processed", map[string]interface{}{
+		"refund_id":       refund.ID,
+		"payment_id":      refund.PaymentID,
+		"status":          refund.Status,
+		"refunded_amount": payment.RefundedAmount,
+		"payment_status":  payment.Status,
+	})
+
+	return refund, nil
+}
+
+// refundStatus maps a gateway refund status onto the refund lifecycle
+func refundStatus(gatewayStatus string) models.PaymentStatus {
+	switch gatewayStatus {
+	case "succeeded":
+		return models.PaymentCompleted
+	case "failed", "canceled":
+		return models.PaymentFailed
+	default:
+		return models.PaymentProcessing
+	}
 }
 
 // GetRefund retrieves a refund by ID
@@ -496,10 +1222,14 @@ func (s *paymentService) GetPaymentRefunds(ctx context.Context, paymentID string
 	return s.repo.GetRefundsByPaymentID(ctx, paymentID)
 }
 
-// ProcessWebhook processes webhook events from payment gateway
+// ProcessWebhook records a gateway webhook and applies it to the payment or
+// refund it is about. Redelivered events are recorded once and ignored after
+// they have been applied. An event that cannot be applied yet, such as one for
+// a payment still being created, is kept unprocessed and an error returned so
+// the gateway delivers it again; ReplayWebhooks retries it as well.
 func (s *paymentService) ProcessWebhook(ctx context.Context, eventType string, payload []byte, signature string) error {
 	// Verify webhook signature
-	if err := s.gateway.VerifyWebhookSignature(payload, signature, ""); err != nil {
+	if err := s.gateway.VerifyWebhookSignature(payload, signature, s.config.WebhookSecret); err != nil {
 		return fmt.Errorf("invalid webhook signature: %w", err)
 	}
 
@@ -511,36 +1241,69 @@ func (s *paymentService) ProcessWebhook(ctx context.Context, eventType string, p
 
 	// Create webhook record
 	webhook := &models.PaymentWebhook{
-		ID:        uuid.New().String(),
-		EventType: event.Type,
-		Status:    "received",
-		Data:      event.Data,
-		Signature: signature,
-		Processed: false,
-		CreatedAt: time.Now(),
+		ID:             uuid.New().String(),
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Status:         "received",
+		Data:           event.Data,
+		Signature:      signature,
+		Processed:      false,
+		EventCreatedAt: event.Created,
+		CreatedAt:      time.Now(),
 	}
 
 	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
 		return fmt.Errorf("failed to save webhook: %w", err)
 	}
 
-	// Process webhook based on event type
-	switch event.Type {
-	case "payment_intent.succeeded":
-		// Handle successful payment
-		utils.Logger.Info(ctx, "Payment succeeded webhook received", map[string]interface{}{
-			"event_id": event.ID,
-		})
-	case "payment_intent.payment_failed":
-		// Handle failed payment
-		utils.Logger.Info(ctx, "Payment failed webhook received", map[string]interface{}{
-			"event_id": event.ID,
-		})
-	default:
-		utils.Logger.Info(ctx, "Unhandled webhook event type", map[string]interface{}{
-			"event_type": event.Type,
+	if webhook.Processed {
+		utils.Logger.Info(ctx, "Duplicate webhook ignored", map[string]interface{}{
 			"event_id":   event.ID,
+			"event_type": event.Type,
 		})
+		return nil
+	}
+
+	return s.handleWebhook(ctx, webhook)
+}
+
+// GetUnprocessedWebhooks retrieves webhooks that have not been applied yet
+func (s *paymentService) GetUnprocessedWebhooks(ctx context.Context, limit int) ([]*models.PaymentWebhook, error) {
+	return s.repo.GetUnprocessedWebhooks(ctx, limit)
+}
+
+// ReplayWebhooks applies unprocessed webhooks again, oldest gateway event
+// first. Webhooks that still fail stay unprocessed.
+func (s *paymentService) ReplayWebhooks(ctx context.Context, limit int) (*WebhookReplayResult, error) {
+	webhooks, err := s.repo.GetUnprocessedWebhooks(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unprocessed webhooks: %w", err)
+	}
+
+	result := &WebhookReplayResult{}
+	for _, webhook := range webhooks {
+		if err := s.handleWebhook(ctx, webhook); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Replayed++
+	}
+
+	utils.Logger.Info(ctx, "Webhooks replayed", map[string]interface{}{
+		"replayed": result.Replayed,
+		"failed":   result.Failed,
+	})
+
+	return result, nil
+}
+
+// handleWebhook applies a recorded webhook and marks the outcome on its row
+func (s *paymentService) handleWebhook(ctx context.Context, webhook *models.PaymentWebhook) error {
+	if err := s.applyWebhook(ctx, webhook); err != nil {
+		if markErr := s.repo.MarkWebhookFailed(ctx, webhook.ID, err.Error()); markErr != nil {
+			utils.Logger.Error(ctx, "Failed to mark webhook as failed", markErr)
+		}
+		return fmt.Errorf("failed to apply webhook %s: %w", webhook.EventID, err)
 	}
 
 	// Mark webhook as processed
@@ -551,6 +1314,90 @@ func (s *paymentService) ProcessWebhook(ctx context.Context, eventType string, p
 	return nil
 }
 
+// applyWebhook brings the payment or refund a webhook is about in line with it
+func (s *paymentService) applyWebhook(ctx context.Context, webhook *models.PaymentWebhook) error {
+	data := webhook.Data
+	paymentIntentID, _ := data["id"].(string)
+
+	switch webhook.EventType {
+	case "payment_intent.succeeded":
+		return s.applyGatewayStatus(ctx, webhook, paymentIntentID, models.PaymentCompleted, "")
+	case "payment_intent.payment_failed":
+		reason := "payment failed"
+		if lastError, ok := data["last_payment_error"].(map[string]interface{}); ok {
+			if message, _ := lastError["message"].(string); message != "" {
+				reason = message
+			}
+		}
+		return s.applyGatewayStatus(ctx, webhook, paymentIntentID, models.PaymentFailed, reason)
+	case "payment_intent.requires_action":
+		return s.applyGatewayStatus(ctx, webhook, paymentIntentID, models.PaymentRequiresAction, "")
+	case "payment_intent.amount_capturable_updated":
+		return s.applyGatewayStatus(ctx, webhook, paymentIntentID, models.PaymentAuthorized, "")
+	case "charge.dispute.created":
+		disputedIntentID, _ := data["payment_intent"].(string)
+		return s.applyGatewayStatus(ctx, webhook, disputedIntentID, models.PaymentDisputed, "")
+	case "charge.dispute.closed":
+		// A lost dispute returns the funds to the cardholder; won and
+		// warning_closed disputes leave the payment as it was
+		disputedIntentID, _ := data["payment_intent"].(string)
+		status := models.PaymentCompleted
+		if outcome, _ := data["status"].(string); outcome == "lost" {
+			status = models.PaymentRefunded
+		}
+		return s.applyGatewayStatus(ctx, webhook, disputedIntentID, status, "")
+	case "refund.updated", "refund.failed", "charge.refund.updated":
+		update := &RefundUpdate{}
+		update.TransactionID, _ = data["id"].(string)
+		update.Status, _ = data["status"].(string)
+		update.FailureReason, _ = data["failure_reason"].(string)
+		if _, err := s.ProcessRefund(ctx, update); err != nil {
+			return fmt.Errorf("failed to process refund webhook: %w", err)
+		}
+	default:
+		utils.Logger.Info(ctx, "Unhandled webhook event type", map[string]interface{}{
+			"event_type": webhook.EventType,
+			"event_id":   webhook.EventID,
+		})
+	}
+
+	return nil
+}
+
+// applyGatewayStatus moves the payment behind a payment intent to the status
+// a webhook reports, unless a later event has already moved it on
+func (s *paymentService) applyGatewayStatus(ctx context.Context, webhook *models.PaymentWebhook, paymentIntentID string, status models.PaymentStatus, failureReason string) error {
+	if paymentIntentID == "" {
+		return fmt.Errorf("webhook %s has no payment intent", webhook.EventID)
+	}
+
+	update := &repository.GatewayStatusUpdate{
+		TransactionID: paymentIntentID,
+		Status:        status,
+		FailureReason: failureReason,
+		OccurredAt:    webhook.EventCreatedAt,
+	}
+	if status == models.PaymentAuthorized {
+		expiresAt := time.Now().Add(s.config.AuthorizationTTL)
+		update.AuthorizationExpiresAt = &expiresAt
+	}
+
+	payment, applied, err := s.repo.ApplyGatewayStatus(ctx, update)
+	if err != nil {
+		return err
+	}
+
+	utils.Logger.Info(ctx, "Payment webhook reconciled", map[string]interface{}{
+		"event_id":       webhook.EventID,
+		"event_type":     webhook.EventType,
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"applied":        applied,
+	})
+
+	return nil
+}
+
 // RetryFailedPayment retries a failed payment
 func (s *paymentService) RetryFailedPayment(ctx context.Context, paymentID string) (*models.Payment, error) {
 	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
@@ -585,6 +1432,51 @@ func (s *paymentService) RetryFailedPayment(ctx context.Context, paymentID strin
 	return s.ProcessPayment(ctx, paymentID)
 }
 
+// ReviewPayment applies an admin's review to a payment held by the risk
+// engine: an approved payment goes on to the gateway and a rejected one is blocked
+func (s *paymentService) ReviewPayment(ctx context.Context, assessmentID string, req *RiskReviewRequest) (*models.Payment, error) {
+	if s.risk == nil {
+		return nil, fmt.Errorf("risk checks are not enabled")
+	}
+
+	assessment, err := s.risk.GetAssessment(ctx, assessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.repo.GetPaymentByID(ctx, assessment.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+	if payment.Status != models.PaymentUnderReview {
+		return nil, fmt.Errorf("payment is not under review: %s", payment.Status)
+	}
+
+	assessment, err = s.risk.DecideReview(ctx, assessmentID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if assessment.ReviewStatus == models.RiskReviewRejected {
+		return s.blockPayment(ctx, payment, "rejected in risk review", assessment)
+	}
+
+	// Back to pending so gateway webhooks apply while it is confirmed
+	if err := s.repo.CompleteRiskReview(ctx, assessment, models.PaymentPending, payment.TransactionID, "", &payment.GatewayResponse); err != nil {
+		return nil, fmt.Errorf("failed to release payment from review: %w", err)
+	}
+
+	utils.Logger.Info(ctx, "Payment approved in risk review", map[string]interface{}{
+		"assessment_id": assessment.ID,
+		"payment_id":    payment.ID,
+		"reviewed_by":   assessment.ReviewedBy,
+	})
+	payment.Status = models.PaymentPending
+	payment.FailureReason = ""
+
+	return s.confirmPayment(ctx, payment)
+}
+
 // ValidatePayment performs fraud detection and validation
 func (s *paymentService) ValidatePayment(ctx context.Context, payment *models.Payment) error {
 	// Basic validation rules
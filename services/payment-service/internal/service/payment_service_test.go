@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/shopsphere/shared/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockPaymentRepository is a mock implementation of PaymentRepository
@@ -43,6 +46,11 @@ func (m *MockPaymentRepository) UpdatePayment(ctx context.Context, payment *mode
 	return args.Error(0)
 }
 
+func (m *MockPaymentRepository) CompleteRiskReview(ctx context.Context, assessment *models.RiskAssessment, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error {
+	args := m.Called(ctx, assessment, status, transactionID, failureReason, gatewayResponse)
+	return args.Error(0)
+}
+
 func (m *MockPaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus, transactionID, failureReason string, gatewayResponse *models.GatewayResponse) error {
 	args := m.Called(ctx, paymentID, status, transactionID, failureReason, gatewayResponse)
 	return args.Error(0)
@@ -98,6 +106,24 @@ func (m *MockPaymentRepository) UpdateRefund(ctx context.Context, refund *models
 	return args.Error(0)
 }
 
+func (m *MockPaymentRepository) GetRefundByTransactionID(ctx context.Context, transactionID string) (*models.Refund, error) {
+	args := m.Called(ctx, transactionID)
+	refund, _ := args.Get(0).(*models.Refund)
+	return refund, args.Error(1)
+}
+
+func (m *MockPaymentRepository) ReserveRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error) {
+	args := m.Called(ctx, refund)
+	payment, _ := args.Get(0).(*models.Payment)
+	return payment, args.Error(1)
+}
+
+func (m *MockPaymentRepository) CompleteRefund(ctx context.Context, refund *models.Refund) (*models.Payment, error) {
+	args := m.Called(ctx, refund)
+	payment, _ := args.Get(0).(*models.Payment)
+	return payment, args.Error(1)
+}
+
 func (m *MockPaymentRepository) CreateWebhook(ctx context.Context, webhook *models.PaymentWebhook) error {
 	args := m.Called(ctx, webhook)
 	return args.Error(0)
@@ -118,6 +144,17 @@ func (m *MockPaymentRepository) MarkWebhookProcessed(ctx context.Context, webhoo
 	return args.Error(0)
 }
 
+func (m *MockPaymentRepository) MarkWebhookFailed(ctx context.Context, webhookID string, reason string) error {
+	args := m.Called(ctx, webhookID, reason)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) ApplyGatewayStatus(ctx context.Context, update *repository.GatewayStatusUpdate) (*models.Payment, bool, error) {
+	args := m.Called(ctx, update)
+	payment, _ := args.Get(0).(*models.Payment)
+	return payment, args.Bool(1), args.Error(2)
+}
+
 func (m *MockPaymentRepository) CreatePaymentAttempt(ctx context.Context, paymentID string, attemptNumber int, status, failureReason string, gatewayResponse *models.GatewayResponse) error {
 	args := m.Called(ctx, paymentID, attemptNumber, status, failureReason, gatewayResponse)
 	return args.Error(0)
@@ -128,6 +165,56 @@ func (m *MockPaymentRepository) GetPaymentAttempts(ctx context.Context, paymentI
 	return args.Get(0).([]repository.PaymentAttempt), args.Error(1)
 }
 
+func (m *MockPaymentRepository) GetExpiredAuthorizations(ctx context.Context, before time.Time, limit int) ([]*models.Payment, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]*models.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) AuthorizePayment(ctx context.Context, paymentID, transactionID string, expiresAt time.Time, gatewayResponse *models.GatewayResponse) error {
+	args := m.Called(ctx, paymentID, transactionID, expiresAt, gatewayResponse)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) ReserveCapture(ctx context.Context, capture *models.PaymentCapture) (*models.Payment, error) {
+	args := m.Called(ctx, capture)
+	payment, _ := args.Get(0).(*models.Payment)
+	return payment, args.Error(1)
+}
+
+func (m *MockPaymentRepository) CompleteCapture(ctx context.Context, capture *models.PaymentCapture, gatewayResponse *models.GatewayResponse) (*models.Payment, error) {
+	args := m.Called(ctx, capture, gatewayResponse)
+	payment, _ := args.Get(0).(*models.Payment)
+	return payment, args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetCapturesByPaymentID(ctx context.Context, paymentID string) ([]*models.PaymentCapture, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).([]*models.PaymentCapture), args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetStalePendingCaptures(ctx context.Context, before time.Time, limit int) ([]*models.PaymentCapture, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]*models.PaymentCapture), args.Error(1)
+}
+
+// VoidAuthorization releases the returned payment and applies the outcome to
+// it, as the repository does with the payment locked
+func (m *MockPaymentRepository) VoidAuthorization(ctx context.Context, paymentID string, release func(*models.Payment) (*repository.AuthorizationVoid, error)) (*models.Payment, error) {
+	args := m.Called(ctx, paymentID)
+	payment, _ := args.Get(0).(*models.Payment)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+
+	void, err := release(payment)
+	if err != nil {
+		return nil, err
+	}
+	payment.Status = void.Status
+	payment.FailureReason = void.FailureReason
+	return payment, nil
+}
+
 // MockPaymentGateway is a mock implementation of PaymentGateway
 type MockPaymentGateway struct {
 	mock.Mock
@@ -143,11 +230,23 @@ func (m *MockPaymentGateway) ConfirmPayment(ctx context.Context, paymentIntentID
 	return args.Get(0).(*gateway.PaymentResult), args.Error(1)
 }
 
-func (m *MockPaymentGateway) CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal) (*gateway.PaymentResult, error) {
-	args := m.Called(ctx, paymentIntentID, amount)
+func (m *MockPaymentGateway) CapturePayment(ctx context.Context, paymentIntentID string, amount decimal.Decimal, currency string, final bool) (*gateway.PaymentResult, error) {
+	args := m.Called(ctx, paymentIntentID, amount, currency, final)
+	result, _ := args.Get(0).(*gateway.PaymentResult)
+	return result, args.Error(1)
+}
+
+func (m *MockPaymentGateway) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentResult, error) {
+	args := m.Called(ctx, paymentIntentID)
 	return args.Get(0).(*gateway.PaymentResult), args.Error(1)
 }
 
+func (m *MockPaymentGateway) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentResult, error) {
+	args := m.Called(ctx, paymentIntentID)
+	result, _ := args.Get(0).(*gateway.PaymentResult)
+	return result, args.Error(1)
+}
+
 func (m *MockPaymentGateway) CreatePaymentMethod(ctx context.Context, req *gateway.CreatePaymentMethodRequest) (*gateway.PaymentMethodResult, error) {
 	args := m.Called(ctx, req)
 	return args.Get(0).(*gateway.PaymentMethodResult), args.Error(1)
@@ -192,7 +291,7 @@ func (m *MockPaymentGateway) ParseWebhookEvent(payload []byte) (*gateway.Webhook
 func TestPaymentService_CreatePayment(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	req := &CreatePaymentRequest{
@@ -236,7 +335,7 @@ func TestPaymentService_CreatePayment(t *testing.T) {
 func TestPaymentService_ProcessPayment(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	paymentID := "payment-123"
@@ -288,10 +387,56 @@ func TestPaymentService_ProcessPayment(t *testing.T) {
 	mockGateway.AssertExpectations(t)
 }
 
+func TestPaymentService_CreatePayment_MinorUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		wantErr  string
+	}{
+		{"zero-decimal currency", "1500", "jpy", ""},
+		{"three-decimal currency", "12.345", "KWD", ""},
+		{"fractional yen", "1500.5", "JPY", "more decimals than JPY allows"},
+		{"fractional cents", "10.005", "USD", "more decimals than USD allows"},
+		{"unknown currency", "10.00", "ABC", "unsupported currency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+			ctx := context.Background()
+			mockGateway.On("CreatePaymentIntent", ctx, mock.MatchedBy(func(req *gateway.CreatePaymentIntentRequest) bool {
+				return req.Currency == strings.ToUpper(tt.currency)
+			})).Return(&models.PaymentIntent{ID: "pi_123", Status: "requires_confirmation"}, nil)
+			mockRepo.On("CreatePayment", ctx, mock.AnythingOfType("*models.Payment")).Return(nil)
+
+			payment, err := service.CreatePayment(ctx, &CreatePaymentRequest{
+				OrderID:  "order-123",
+				UserID:   "user-123",
+				Amount:   decimal.RequireFromString(tt.amount),
+				Currency: tt.currency,
+			})
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				mockGateway.AssertNotCalled(t, "CreatePaymentIntent", mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, strings.ToUpper(tt.currency), payment.Currency)
+			mockGateway.AssertExpectations(t)
+		})
+	}
+}
+
 func TestPaymentService_CreatePaymentMethod(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	req := &CreatePaymentMethodRequest{
@@ -340,7 +485,7 @@ func TestPaymentService_CreatePaymentMethod(t *testing.T) {
 func TestPaymentService_CreateRefund(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	req := &CreateRefundRequest{
@@ -349,16 +494,20 @@ func TestPaymentService_CreateRefund(t *testing.T) {
 		Reason:    "Customer request",
 	}
 
-	// Mock payment retrieval
+	// Mock refund reservation against the payment
 	payment := &models.Payment{
-		ID:            "payment-123",
-		OrderID:       "order-123",
-		Amount:        decimal.NewFromFloat(100.00),
-		Currency:      "USD",
-		Status:        models.PaymentCompleted,
-		TransactionID: "pi_123",
+		ID:             "payment-123",
+		OrderID:        "order-123",
+		Amount:         decimal.NewFromFloat(100.00),
+		CapturedAmount: decimal.NewFromFloat(100.00),
+		Currency:       "USD",
+		Status:         models.PaymentCompleted,
+		TransactionID:  "pi_123",
 	}
-	mockRepo.On("GetPaymentByID", ctx, req.PaymentID).Return(payment, nil)
+	mockRepo.On("GetPaymentByID", ctx, "payment-123").Return(payment, nil)
+	mockRepo.On("ReserveRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.PaymentID == req.PaymentID && r.Amount.Equal(req.Amount)
+	})).Return(payment, nil)
 
 	// Mock gateway refund creation
 	mockGateway.On("CreateRefund", ctx, mock.AnythingOfType("*gateway.CreateRefundRequest")).Return(&gateway.RefundResult{
@@ -371,8 +520,13 @@ func TestPaymentService_CreateRefund(t *testing.T) {
 		CreatedAt:       time.Now(),
 	}, nil)
 
-	// Mock repository refund creation
-	mockRepo.On("CreateRefund", ctx, mock.AnythingOfType("*models.Refund")).Return(nil)
+	// Mock refund completion
+	refunded := *payment
+	refunded.Status = models.PaymentPartiallyRefunded
+	refunded.RefundedAmount = decimal.NewFromFloat(50.00)
+	mockRepo.On("CompleteRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.Status == models.PaymentCompleted && r.TransactionID == "re_123"
+	})).Return(&refunded, nil)
 
 	refund, err := service.CreateRefund(ctx, req)
 
@@ -382,15 +536,172 @@ func TestPaymentService_CreateRefund(t *testing.T) {
 	assert.Equal(t, req.Amount, refund.Amount)
 	assert.Equal(t, req.Reason, refund.Reason)
 	assert.Equal(t, "re_123", refund.TransactionID)
+	assert.Equal(t, models.PaymentCompleted, refund.Status)
 
 	mockRepo.AssertExpectations(t)
 	mockGateway.AssertExpectations(t)
 }
 
+func TestPaymentService_CreateRefund_OverRefund(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+
+	// The balance check happens under the payment row lock in the repository
+	mockRepo.On("GetPaymentByID", ctx, "payment-123").Return(&models.Payment{ID: "payment-123", Currency: "USD"}, nil)
+	mockRepo.On("ReserveRefund", ctx, mock.AnythingOfType("*models.Refund")).
+		Return(nil, fmt.Errorf("refund amount 60 exceeds refundable balance 40"))
+
+	_, err := service.CreateRefund(ctx, &CreateRefundRequest{
+		PaymentID: "payment-123",
+		Amount:    decimal.NewFromFloat(60.00),
+		Reason:    "Customer request",
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds refundable balance")
+	mockGateway.AssertNotCalled(t, "CreateRefund", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_CreateRefund_RejectsUnroundedAmount(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	mockRepo.On("GetPaymentByID", ctx, "payment-123").Return(&models.Payment{ID: "payment-123", Currency: "JPY"}, nil)
+
+	_, err := service.CreateRefund(ctx, &CreateRefundRequest{
+		PaymentID: "payment-123",
+		Amount:    decimal.NewFromFloat(10.5),
+		Reason:    "Customer request",
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "more decimals than JPY allows")
+	mockRepo.AssertNotCalled(t, "ReserveRefund", mock.Anything, mock.Anything)
+	mockGateway.AssertNotCalled(t, "CreateRefund", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_CreateRefund_Pending(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payment := &models.Payment{ID: "payment-123", Status: models.PaymentCompleted, TransactionID: "pi_123"}
+	mockRepo.On("ReserveRefund", ctx, mock.AnythingOfType("*models.Refund")).Return(payment, nil)
+	mockGateway.On("CreateRefund", ctx, mock.AnythingOfType("*gateway.CreateRefundRequest")).Return(&gateway.RefundResult{
+		ID:     "re_123",
+		Status: "pending",
+	}, nil)
+
+	// A refund the gateway has not settled stays processing until its webhook
+	mockRepo.On("UpdateRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.Status == models.PaymentProcessing && r.TransactionID == "re_123"
+	})).Return(nil)
+
+	refund, err := service.CreateRefund(ctx, &CreateRefundRequest{PaymentID: "payment-123", Reason: "Customer request"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentProcessing, refund.Status)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CompleteRefund", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_CreateRefund_GatewayFailureReleasesReservation(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payment := &models.Payment{ID: "payment-123", Status: models.PaymentCompleted, TransactionID: "pi_123"}
+	mockRepo.On("ReserveRefund", ctx, mock.AnythingOfType("*models.Refund")).Return(payment, nil)
+	mockGateway.On("CreateRefund", ctx, mock.AnythingOfType("*gateway.CreateRefundRequest")).Return((*gateway.RefundResult)(nil), assert.AnError)
+	mockRepo.On("CompleteRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.Status == models.PaymentFailed && r.FailureReason != ""
+	})).Return(payment, nil)
+
+	_, err := service.CreateRefund(ctx, &CreateRefundRequest{PaymentID: "payment-123", Reason: "Customer request"})
+
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    models.PaymentStatus
+		update     string
+		wantStatus models.PaymentStatus
+		completes  bool
+	}{
+		{"succeeded completes the refund", models.PaymentProcessing, "succeeded", models.PaymentCompleted, true},
+		{"failed releases the refund", models.PaymentProcessing, "failed", models.PaymentFailed, true},
+		{"still pending changes nothing", models.PaymentProcessing, "pending", models.PaymentProcessing, false},
+		{"redelivered webhook changes nothing", models.PaymentCompleted, "succeeded", models.PaymentCompleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+			ctx := context.Background()
+			refund := models.NewRefund("payment-123", "order-123", decimal.NewFromFloat(25.00), "USD", "Customer request")
+			refund.Status = tt.current
+			refund.TransactionID = "re_123"
+			mockRepo.On("GetRefundByTransactionID", ctx, "re_123").Return(refund, nil)
+			if tt.completes {
+				mockRepo.On("CompleteRefund", ctx, refund).Return(&models.Payment{ID: "payment-123"}, nil)
+			}
+
+			result, err := service.ProcessRefund(ctx, &RefundUpdate{TransactionID: "re_123", Status: tt.update})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, result.Status)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPaymentService_ProcessWebhook_RefundUpdated(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payload := []byte(`{"type": "refund.failed"}`)
+
+	mockGateway.On("VerifyWebhookSignature", payload, "sig", "").Return(nil)
+	mockGateway.On("ParseWebhookEvent", payload).Return(&gateway.WebhookEvent{
+		ID:   "evt_123",
+		Type: "refund.failed",
+		Data: map[string]interface{}{"id": "re_123", "status": "failed", "failure_reason": "expired_or_canceled_card"},
+	}, nil)
+	mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Return(nil)
+	mockRepo.On("MarkWebhookProcessed", ctx, mock.AnythingOfType("string")).Return(nil)
+
+	refund := models.NewRefund("payment-123", "order-123", decimal.NewFromFloat(25.00), "USD", "Customer request")
+	refund.Status = models.PaymentProcessing
+	mockRepo.On("GetRefundByTransactionID", ctx, "re_123").Return(refund, nil)
+	mockRepo.On("CompleteRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.Status == models.PaymentFailed && r.FailureReason == "expired_or_canceled_card"
+	})).Return(&models.Payment{ID: "payment-123"}, nil)
+
+	err := service.ProcessWebhook(ctx, "refund.failed", payload, "sig")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestPaymentService_CancelPayment(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	paymentID := "payment-123"
@@ -418,7 +729,7 @@ func TestPaymentService_CancelPayment(t *testing.T) {
 func TestPaymentService_ProcessWebhook(t *testing.T) {
 	mockRepo := new(MockPaymentRepository)
 	mockGateway := new(MockPaymentGateway)
-	service := NewPaymentService(mockRepo, mockGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
 
 	ctx := context.Background()
 	eventType := "stripe"
@@ -437,7 +748,14 @@ func TestPaymentService_ProcessWebhook(t *testing.T) {
 	}, nil)
 
 	// Mock webhook creation
-	mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Return(nil)
+	mockRepo.On("CreateWebhook", ctx, mock.MatchedBy(func(w *models.PaymentWebhook) bool {
+		return w.EventID == "evt_123"
+	})).Return(nil)
+
+	// The payment behind the intent is completed
+	mockRepo.On("ApplyGatewayStatus", ctx, mock.MatchedBy(func(u *repository.GatewayStatusUpdate) bool {
+		return u.TransactionID == "pi_123" && u.Status == models.PaymentCompleted
+	})).Return(&models.Payment{ID: "payment-123", Status: models.PaymentCompleted}, true, nil)
 
 	// Mock webhook processed marking
 	mockRepo.On("MarkWebhookProcessed", ctx, mock.AnythingOfType("string")).Return(nil)
@@ -449,3 +767,551 @@ func TestPaymentService_ProcessWebhook(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	mockGateway.AssertExpectations(t)
 }
+
+func TestPaymentService_ProcessWebhook_Duplicate(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payload := []byte(`{"id": "evt_123", "type": "payment_intent.succeeded"}`)
+
+	mockGateway.On("VerifyWebhookSignature", payload, "sig", "").Return(nil)
+	mockGateway.On("ParseWebhookEvent", payload).Return(&gateway.WebhookEvent{
+		ID:   "evt_123",
+		Type: "payment_intent.succeeded",
+		Data: map[string]interface{}{"id": "pi_123"},
+	}, nil)
+
+	// The event was recorded and applied on its first delivery
+	mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.PaymentWebhook).Processed = true
+	}).Return(nil)
+
+	err := service.ProcessWebhook(ctx, "stripe", payload, "sig")
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "ApplyGatewayStatus", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkWebhookProcessed", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessWebhook_PaymentStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventType  string
+		data       map[string]interface{}
+		wantIntent string
+		wantStatus models.PaymentStatus
+		wantReason string
+	}{
+		{
+			name:       "failed",
+			eventType:  "payment_intent.payment_failed",
+			data:       map[string]interface{}{"id": "pi_123", "last_payment_error": map[string]interface{}{"message": "Your card was declined."}},
+			wantIntent: "pi_123",
+			wantStatus: models.PaymentFailed,
+			wantReason: "Your card was declined.",
+		},
+		{
+			name:       "requires action",
+			eventType:  "payment_intent.requires_action",
+			data:       map[string]interface{}{"id": "pi_123"},
+			wantIntent: "pi_123",
+			wantStatus: models.PaymentRequiresAction,
+		},
+		{
+			name:       "dispute opened",
+			eventType:  "charge.dispute.created",
+			data:       map[string]interface{}{"id": "dp_123", "payment_intent": "pi_123", "status": "needs_response"},
+			wantIntent: "pi_123",
+			wantStatus: models.PaymentDisputed,
+		},
+		{
+			name:       "dispute lost",
+			eventType:  "charge.dispute.closed",
+			data:       map[string]interface{}{"id": "dp_123", "payment_intent": "pi_123", "status": "lost"},
+			wantIntent: "pi_123",
+			wantStatus: models.PaymentRefunded,
+		},
+		{
+			name:       "dispute won",
+			eventType:  "charge.dispute.closed",
+			data:       map[string]interface{}{"id": "dp_123", "payment_intent": "pi_123", "status": "won"},
+			wantIntent: "pi_123",
+			wantStatus: models.PaymentCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+			ctx := context.Background()
+			payload := []byte(tt.eventType)
+			created := time.Now()
+
+			mockGateway.On("VerifyWebhookSignature", payload, "sig", "").Return(nil)
+			mockGateway.On("ParseWebhookEvent", payload).Return(&gateway.WebhookEvent{
+				ID: "evt_123", Type: tt.eventType, Data: tt.data, Created: created,
+			}, nil)
+			mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Return(nil)
+			mockRepo.On("ApplyGatewayStatus", ctx, &repository.GatewayStatusUpdate{
+				TransactionID: tt.wantIntent,
+				Status:        tt.wantStatus,
+				FailureReason: tt.wantReason,
+				OccurredAt:    created,
+			}).Return(&models.Payment{ID: "payment-123", Status: tt.wantStatus}, true, nil)
+			mockRepo.On("MarkWebhookProcessed", ctx, mock.AnythingOfType("string")).Return(nil)
+
+			err := service.ProcessWebhook(ctx, "stripe", payload, "sig")
+
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPaymentService_ProcessWebhook_StaleEventIsProcessed(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payload := []byte(`{"id": "evt_123", "type": "payment_intent.requires_action"}`)
+
+	mockGateway.On("VerifyWebhookSignature", payload, "sig", "").Return(nil)
+	mockGateway.On("ParseWebhookEvent", payload).Return(&gateway.WebhookEvent{
+		ID:   "evt_123",
+		Type: "payment_intent.requires_action",
+		Data: map[string]interface{}{"id": "pi_123"},
+	}, nil)
+	mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Return(nil)
+
+	// The payment already succeeded, so the late event changes nothing
+	mockRepo.On("ApplyGatewayStatus", ctx, mock.AnythingOfType("*repository.GatewayStatusUpdate")).
+		Return(&models.Payment{ID: "payment-123", Status: models.PaymentCompleted}, false, nil)
+	mockRepo.On("MarkWebhookProcessed", ctx, mock.AnythingOfType("string")).Return(nil)
+
+	err := service.ProcessWebhook(ctx, "stripe", payload, "sig")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessWebhook_UnknownPaymentIsKeptForReplay(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payload := []byte(`{"id": "evt_123", "type": "payment_intent.succeeded"}`)
+
+	mockGateway.On("VerifyWebhookSignature", payload, "sig", "").Return(nil)
+	mockGateway.On("ParseWebhookEvent", payload).Return(&gateway.WebhookEvent{
+		ID:   "evt_123",
+		Type: "payment_intent.succeeded",
+		Data: map[string]interface{}{"id": "pi_new"},
+	}, nil)
+	mockRepo.On("CreateWebhook", ctx, mock.AnythingOfType("*models.PaymentWebhook")).Return(nil)
+	mockRepo.On("ApplyGatewayStatus", ctx, mock.AnythingOfType("*repository.GatewayStatusUpdate")).
+		Return(nil, false, fmt.Errorf("payment not found for transaction: pi_new"))
+	mockRepo.On("MarkWebhookFailed", ctx, mock.AnythingOfType("string"), "payment not found for transaction: pi_new").Return(nil)
+
+	err := service.ProcessWebhook(ctx, "stripe", payload, "sig")
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "MarkWebhookProcessed", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessWebhook_UsesConfiguredSecret(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	config := DefaultPaymentConfig()
+	config.WebhookSecret = "whsec_123"
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, config)
+
+	payload := []byte(`{}`)
+	mockGateway.On("VerifyWebhookSignature", payload, "sig", "whsec_123").Return(fmt.Errorf("no matching signature"))
+
+	err := service.ProcessWebhook(context.Background(), "stripe", payload, "sig")
+
+	assert.Error(t, err)
+	mockGateway.AssertExpectations(t)
+}
+
+func TestPaymentService_ReplayWebhooks(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	succeeded := &models.PaymentWebhook{
+		ID: "webhook-1", EventID: "evt_1", EventType: "payment_intent.succeeded",
+		Data: map[string]interface{}{"id": "pi_1"},
+	}
+	orphaned := &models.PaymentWebhook{
+		ID: "webhook-2", EventID: "evt_2", EventType: "payment_intent.succeeded",
+		Data: map[string]interface{}{"id": "pi_2"},
+	}
+	mockRepo.On("GetUnprocessedWebhooks", ctx, 50).Return([]*models.PaymentWebhook{succeeded, orphaned}, nil)
+
+	mockRepo.On("ApplyGatewayStatus", ctx, mock.MatchedBy(func(u *repository.GatewayStatusUpdate) bool {
+		return u.TransactionID == "pi_1"
+	})).Return(&models.Payment{ID: "payment-1", Status: models.PaymentCompleted}, true, nil)
+	mockRepo.On("MarkWebhookProcessed", ctx, "webhook-1").Return(nil)
+
+	mockRepo.On("ApplyGatewayStatus", ctx, mock.MatchedBy(func(u *repository.GatewayStatusUpdate) bool {
+		return u.TransactionID == "pi_2"
+	})).Return(nil, false, fmt.Errorf("payment not found for transaction: pi_2"))
+	mockRepo.On("MarkWebhookFailed", ctx, "webhook-2", "payment not found for transaction: pi_2").Return(nil)
+
+	result, err := service.ReplayWebhooks(ctx, 50)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &WebhookReplayResult{Replayed: 1, Failed: 1}, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessPayment_FakeGatewayRequiresAction(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	fakeGateway := gateway.NewFakeGateway(gateway.DefaultFakeGatewayConfig())
+	service := NewPaymentService(mockRepo, fakeGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+
+	// Mock payment creation
+	var payment *models.Payment
+	mockRepo.On("CreatePayment", ctx, mock.AnythingOfType("*models.Payment")).Run(func(args mock.Arguments) {
+		payment = args.Get(1).(*models.Payment)
+	}).Return(nil)
+
+	created, err := service.CreatePayment(ctx, &CreatePaymentRequest{
+		OrderID:         "order-123",
+		UserID:          "user-123",
+		Amount:          decimal.NewFromFloat(100.00),
+		Currency:        "USD",
+		PaymentMethodID: "pm_card_threeDSecure2Required",
+	})
+	assert.NoError(t, err)
+
+	mockRepo.On("GetPaymentByID", ctx, created.ID).Return(payment, nil)
+	mockRepo.On("UpdatePayment", ctx, mock.AnythingOfType("*models.Payment")).Return(nil)
+	mockRepo.On("CreatePaymentAttempt", ctx, created.ID, 1, "processing", "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	// A payment waiting on 3-D Secure waits for the customer rather than failing
+	mockRepo.On("UpdatePaymentStatus", ctx, created.ID, models.PaymentRequiresAction, created.TransactionID, "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	_, err = service.ProcessPayment(ctx, created.ID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func authorizedPayment(id string, captured string) *models.Payment {
+	payment := &models.Payment{
+		ID:               id,
+		OrderID:          "order-123",
+		UserID:           "user-123",
+		Amount:           decimal.NewFromFloat(100.00),
+		AuthorizedAmount: decimal.NewFromFloat(100.00),
+		CapturedAmount:   decimal.RequireFromString(captured),
+		Currency:         "USD",
+		Status:           models.PaymentAuthorized,
+		TransactionID:    "pi_123",
+	}
+	if payment.CapturedAmount.IsPositive() {
+		payment.Status = models.PaymentPartiallyCaptured
+	}
+	return payment
+}
+
+func TestPaymentService_ProcessPayment_Authorizes(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	paymentID := "payment-123"
+
+	payment := &models.Payment{
+		ID:            paymentID,
+		Amount:        decimal.NewFromFloat(100.00),
+		Currency:      "USD",
+		Status:        models.PaymentPending,
+		TransactionID: "pi_123",
+	}
+	mockRepo.On("GetPaymentByID", ctx, paymentID).Return(payment, nil).Once()
+	mockRepo.On("UpdatePayment", ctx, mock.AnythingOfType("*models.Payment")).Return(nil)
+	mockRepo.On("CreatePaymentAttempt", ctx, paymentID, 1, "processing", "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+	mockGateway.On("ConfirmPayment", ctx, "pi_123").Return(&gateway.PaymentResult{
+		ID:     "pi_123",
+		Status: "requires_capture",
+		Amount: decimal.NewFromFloat(100.00),
+	}, nil)
+
+	// A manual-capture intent is held rather than completed, expiring after the TTL
+	before := time.Now()
+	mockRepo.On("AuthorizePayment", ctx, paymentID, "pi_123", mock.MatchedBy(func(expiresAt time.Time) bool {
+		return !expiresAt.Before(before.Add(DefaultPaymentConfig().AuthorizationTTL))
+	}), mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+	mockRepo.On("GetPaymentByID", ctx, paymentID).Return(authorizedPayment(paymentID, "0"), nil).Once()
+
+	result, err := service.ProcessPayment(ctx, paymentID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentAuthorized, result.Status)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdatePaymentStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_CapturePayment(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	paymentID := "payment-123"
+	amount := decimal.NewFromFloat(40.00)
+
+	mockRepo.On("ReserveCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+		return c.PaymentID == paymentID && c.Amount.Equal(amount) && !c.Final && c.Status == models.CapturePending
+	})).Return(authorizedPayment(paymentID, "0"), nil)
+	mockGateway.On("CapturePayment", ctx, "pi_123", amount, "USD", false).Return(&gateway.PaymentResult{
+		ID:             "pi_123",
+		Status:         "requires_capture",
+		AmountCaptured: amount,
+	}, nil)
+
+	partial := authorizedPayment(paymentID, "40.00")
+	mockRepo.On("CompleteCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+		return c.Status == models.CaptureSucceeded && c.TransactionID == "pi_123"
+	}), mock.AnythingOfType("*models.GatewayResponse")).Return(partial, nil)
+
+	result, err := service.CapturePayment(ctx, paymentID, &CapturePaymentRequest{Amount: amount})
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentPartiallyCaptured, result.Status)
+	assert.True(t, amount.Equal(result.CapturedAmount))
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+}
+
+func TestPaymentService_CapturePayment_GatewayFailure(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	paymentID := "payment-123"
+
+	// The repository resolves a zero amount to the rest of the authorization
+	mockRepo.On("ReserveCapture", ctx, mock.AnythingOfType("*models.PaymentCapture")).Run(func(args mock.Arguments) {
+		capture := args.Get(1).(*models.PaymentCapture)
+		capture.Amount = decimal.NewFromFloat(100.00)
+		capture.Final = true
+	}).Return(authorizedPayment(paymentID, "0"), nil)
+	mockGateway.On("CapturePayment", ctx, "pi_123", decimal.NewFromFloat(100.00), "USD", true).Return(nil, assert.AnError)
+
+	// The reservation is released so the amount can be captured again
+	mockRepo.On("CompleteCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+		return c.Status == models.CaptureFailed && c.FailureReason != ""
+	}), (*models.GatewayResponse)(nil)).Return(authorizedPayment(paymentID, "0"), nil)
+
+	_, err := service.CapturePayment(ctx, paymentID, &CapturePaymentRequest{})
+
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+}
+
+func TestPaymentService_CaptureForShipment(t *testing.T) {
+	tests := []struct {
+		name          string
+		declaredValue string
+		final         bool
+		wantAmount    string
+		wantFinal     bool
+	}{
+		{"partial shipment captures its declared value", "30.00", false, "30.00", false},
+		{"final shipment captures the rest", "30.00", true, "0", true},
+		{"declared value above the remainder captures the rest", "150.00", false, "0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+			ctx := context.Background()
+			failed := &models.Payment{ID: "payment-failed", OrderID: "order-123", Status: models.PaymentFailed}
+			payment := authorizedPayment("payment-123", "0")
+			mockRepo.On("GetPaymentsByOrderID", ctx, "order-123").Return([]*models.Payment{failed, payment}, nil)
+
+			wantAmount := decimal.RequireFromString(tt.wantAmount)
+			mockRepo.On("ReserveCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+				return c.PaymentID == payment.ID && c.ShipmentID == "shipment-1" && c.Amount.Equal(wantAmount) && c.Final == tt.wantFinal
+			})).Return(nil, fmt.Errorf("stop after reservation"))
+
+			_, err := service.CaptureForShipment(ctx, &models.ShipmentShippedData{
+				ShipmentID:    "shipment-1",
+				OrderID:       "order-123",
+				DeclaredValue: decimal.RequireFromString(tt.declaredValue),
+				Final:         tt.final,
+			})
+
+			assert.Error(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPaymentService_CaptureForShipment_Skips(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+
+	// Orders paid with automatic capture have nothing to capture
+	completed := &models.Payment{ID: "payment-123", OrderID: "order-123", Status: models.PaymentCompleted}
+	mockRepo.On("GetPaymentsByOrderID", ctx, "order-123").Return([]*models.Payment{completed}, nil)
+
+	result, err := service.CaptureForShipment(ctx, &models.ShipmentShippedData{ShipmentID: "shipment-1", OrderID: "order-123"})
+
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	// A redelivered event for a shipment already captured is not an error
+	payment := authorizedPayment("payment-456", "30.00")
+	payment.OrderID = "order-456"
+	mockRepo.On("GetPaymentsByOrderID", ctx, "order-456").Return([]*models.Payment{payment}, nil)
+	mockRepo.On("ReserveCapture", ctx, mock.AnythingOfType("*models.PaymentCapture")).Return(nil, fmt.Errorf("%w: shipment-1", repository.ErrShipmentAlreadyCaptured))
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+
+	result, err = service.CaptureForShipment(ctx, &models.ShipmentShippedData{
+		ShipmentID:    "shipment-1",
+		OrderID:       "order-456",
+		DeclaredValue: decimal.NewFromFloat(30.00),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment, result)
+	mockGateway.AssertNotCalled(t, "CapturePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_RecoverPendingCaptures(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	captured := authorizedPayment("payment-123", "10.00")
+	lost := authorizedPayment("payment-456", "0")
+	lost.TransactionID = "pi_456"
+
+	// The gateway took the first capture before the crash but never saw the second
+	first := models.NewPaymentCapture(captured.ID, "shipment-1", decimal.NewFromFloat(20.00), false)
+	second := models.NewPaymentCapture(lost.ID, "shipment-2", decimal.NewFromFloat(15.00), false)
+	mockRepo.On("GetStalePendingCaptures", ctx, mock.AnythingOfType("time.Time"), DefaultPaymentConfig().ExpiryBatchSize).Return([]*models.PaymentCapture{first, second}, nil)
+	mockRepo.On("GetPaymentByID", ctx, captured.ID).Return(captured, nil)
+	mockRepo.On("GetPaymentByID", ctx, lost.ID).Return(lost, nil)
+	mockGateway.On("GetPaymentIntent", ctx, "pi_123").Return(&gateway.PaymentResult{ID: "pi_123", Status: "requires_capture", AmountCaptured: decimal.NewFromFloat(30.00)}, nil)
+	mockGateway.On("GetPaymentIntent", ctx, "pi_456").Return(&gateway.PaymentResult{ID: "pi_456", Status: "requires_capture", AmountCaptured: decimal.Zero}, nil)
+	mockRepo.On("CompleteCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+		return c.ID == first.ID && c.Status == models.CaptureSucceeded && c.TransactionID == "pi_123"
+	}), mock.AnythingOfType("*models.GatewayResponse")).Return(captured, nil)
+	mockRepo.On("CompleteCapture", ctx, mock.MatchedBy(func(c *models.PaymentCapture) bool {
+		return c.ID == second.ID && c.Status == models.CaptureFailed
+	}), (*models.GatewayResponse)(nil)).Return(lost, nil)
+
+	recovered, err := service.RecoverPendingCaptures(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, recovered)
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+}
+
+func TestPaymentService_VoidPayment(t *testing.T) {
+	tests := []struct {
+		name       string
+		captured   string
+		wantStatus models.PaymentStatus
+		wantReason string
+	}{
+		{"uncaptured authorization is voided", "0", models.PaymentVoided, "order cancelled"},
+		{"partly captured payment completes at the captured amount", "40.00", models.PaymentCompleted, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+			ctx := context.Background()
+			payment := authorizedPayment("payment-123", tt.captured)
+			mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+			mockRepo.On("VoidAuthorization", ctx, payment.ID).Return(payment, nil)
+			mockGateway.On("CancelPaymentIntent", ctx, "pi_123").Return(&gateway.PaymentResult{ID: "pi_123", Status: "canceled"}, nil)
+
+			// CancelPayment voids authorizations instead of cancelling them outright
+			err := service.CancelPayment(ctx, payment.ID, "order cancelled")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, payment.Status)
+			assert.Equal(t, tt.wantReason, payment.FailureReason)
+			mockRepo.AssertExpectations(t)
+			mockGateway.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPaymentService_VoidPayment_RefusedWhileCapturePending(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+
+	// The pending capture check happens under the payment row lock in the repository
+	mockRepo.On("VoidAuthorization", ctx, "payment-123").Return(nil, fmt.Errorf("payment cannot be voided while 1 captures are pending"))
+
+	_, err := service.VoidPayment(ctx, "payment-123", "order cancelled")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "captures are pending")
+	mockGateway.AssertNotCalled(t, "CancelPaymentIntent", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_VoidExpiredAuthorizations(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	ctx := context.Background()
+	expired := authorizedPayment("payment-123", "0")
+	failing := authorizedPayment("payment-456", "0")
+	failing.TransactionID = "pi_456"
+
+	mockRepo.On("GetExpiredAuthorizations", ctx, mock.AnythingOfType("time.Time"), DefaultPaymentConfig().ExpiryBatchSize).Return([]*models.Payment{failing, expired}, nil)
+	mockRepo.On("VoidAuthorization", ctx, expired.ID).Return(expired, nil)
+	mockRepo.On("VoidAuthorization", ctx, failing.ID).Return(failing, nil)
+	mockGateway.On("CancelPaymentIntent", ctx, "pi_456").Return((*gateway.PaymentResult)(nil), assert.AnError)
+	mockGateway.On("CancelPaymentIntent", ctx, "pi_123").Return(&gateway.PaymentResult{ID: "pi_123", Status: "canceled"}, nil)
+
+	// One failure does not stop the sweep
+	voided, err := service.VoidExpiredAuthorizations(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, voided)
+	assert.Equal(t, models.PaymentVoided, expired.Status)
+	assert.Equal(t, models.PaymentAuthorized, failing.Status)
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+}
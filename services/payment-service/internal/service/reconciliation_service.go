@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/settlement"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+// ReconciliationService checks gateway settlement reports against our
+// payments and refunds and tracks the discrepancies until admins resolve them
+type ReconciliationService interface {
+	ReconcileReport(ctx context.Context, source settlement.Source) (*models.ReconciliationRun, error)
+	GetRun(ctx context.Context, runID string) (*models.ReconciliationRun, error)
+	ListRuns(ctx context.Context, limit, offset int) ([]*models.ReconciliationRun, error)
+	GetDiscrepancy(ctx context.Context, discrepancyID string) (*models.ReconciliationDiscrepancy, error)
+	ListDiscrepancies(ctx context.Context, filter repository.DiscrepancyFilter) ([]*models.ReconciliationDiscrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, discrepancyID string, req *ResolveDiscrepancyRequest) (*models.ReconciliationDiscrepancy, error)
+}
+
+// ResolveDiscrepancyRequest records how an admin dealt with a discrepancy
+type ResolveDiscrepancyRequest struct {
+	ResolvedBy string `json:"resolved_by" validate:"required"`
+	Resolution string `json:"resolution" validate:"required"`
+}
+
+// reconciliationService implements ReconciliationService
+type reconciliationService struct {
+	repo repository.ReconciliationRepository
+}
+
+// NewReconciliationService creates a new reconciliation service
+func NewReconciliationService(repo repository.ReconciliationRepository) ReconciliationService {
+	return &reconciliationService{repo: repo}
+}
+
+// ReconcileReport matches every record of a settlement report to a payment or
+// refund by transaction ID and records a discrepancy for each one that is
+// missing or disagrees on amount or status. Payments and refunds that moved
+// money during the period the report spans but are absent from it are
+// discrepancies too. A report is only reconciled once.
+func (s *reconciliationService) ReconcileReport(ctx context.Context, source settlement.Source) (*models.ReconciliationRun, error) {
+	report, err := source.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Records) == 0 {
+		return nil, fmt.Errorf("settlement report %s has no records", report.Name)
+	}
+
+	existing, err := s.repo.GetRunByChecksum(ctx, report.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("settlement report %s was already reconciled in run %s", report.Name, existing.ID)
+	}
+
+	run := models.NewReconciliationRun(report.Name, report.Checksum)
+	run.RecordCount = len(report.Records)
+	run.PeriodStart = report.Records[0].SettledAt
+	run.PeriodEnd = report.Records[0].SettledAt
+
+	var chargeIDs, refundIDs []string
+	for _, record := range report.Records {
+		if record.SettledAt.Before(run.PeriodStart) {
+			run.PeriodStart = record.SettledAt
+		}
+		if record.SettledAt.After(run.PeriodEnd) {
+			run.PeriodEnd = record.SettledAt
+		}
+
+		if record.Type == models.SettlementRefund {
+			refundIDs = append(refundIDs, record.TransactionID)
+		} else {
+			chargeIDs = append(chargeIDs, record.TransactionID)
+		}
+	}
+
+	payments, err := s.repo.GetPaymentsByTransactionIDs(ctx, chargeIDs)
+	if err != nil {
+		return nil, err
+	}
+	paymentsByTransaction := make(map[string]*models.Payment, len(payments))
+	for _, payment := range payments {
+		paymentsByTransaction[payment.TransactionID] = payment
+	}
+
+	refunds, err := s.repo.GetRefundsByTransactionIDs(ctx, refundIDs)
+	if err != nil {
+		return nil, err
+	}
+	refundsByTransaction := make(map[string]*models.Refund, len(refunds))
+	for _, refund := range refunds {
+		refundsByTransaction[refund.TransactionID] = refund
+	}
+
+	var discrepancies []*models.ReconciliationDiscrepancy
+	reported := make(map[string]bool, len(report.Records))
+	for i := range report.Records {
+		record := &report.Records[i]
+		reported[string(record.Type)+":"+record.TransactionID] = true
+
+		var discrepancy *models.ReconciliationDiscrepancy
+		if record.Type == models.SettlementRefund {
+			discrepancy = compareRefund(run.ID, record, refundsByTransaction[record.TransactionID])
+		} else {
+			discrepancy = compareCharge(run.ID, record, paymentsByTransaction[record.TransactionID])
+		}
+
+		if discrepancy == nil {
+			run.MatchedCount++
+			continue
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	collected, err := s.repo.GetCollectedPayments(ctx, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range collected {
+		if reported[string(models.SettlementCharge)+":"+payment.TransactionID] {
+			continue
+		}
+		discrepancy := models.NewReconciliationDiscrepancy(run.ID, models.DiscrepancyMissingSettlement, models.SettlementCharge, payment.TransactionID)
+		discrepancy.PaymentID = payment.ID
+		discrepancy.Currency = payment.Currency
+		discrepancy.LedgerAmount = payment.CapturedAmount
+		discrepancy.LedgerStatus = string(payment.Status)
+		discrepancy.Detail = fmt.Sprintf("payment collected %s %s but the gateway settled nothing", payment.CapturedAmount, payment.Currency)
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	completed, err := s.repo.GetCompletedRefunds(ctx, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, refund := range completed {
+		if reported[string(models.SettlementRefund)+":"+refund.TransactionID] {
+			continue
+		}
+		discrepancy := models.NewReconciliationDiscrepancy(run.ID, models.DiscrepancyMissingSettlement, models.SettlementRefund, refund.TransactionID)
+		discrepancy.PaymentID = refund.PaymentID
+		discrepancy.RefundID = refund.ID
+		discrepancy.Currency = refund.Currency
+		discrepancy.LedgerAmount = refund.Amount
+		discrepancy.LedgerStatus = string(refund.Status)
+		discrepancy.Detail = fmt.Sprintf("refund of %s %s completed but the gateway settled nothing", refund.Amount, refund.Currency)
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	run.DiscrepancyCount = len(discrepancies)
+	if err := s.repo.CreateRun(ctx, run, discrepancies); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Settlement report reconciled", map[string]interface{}{
+		"run_id":        run.ID,
+		"source":        run.Source,
+		"records":       run.RecordCount,
+		"matched":       run.MatchedCount,
+		"discrepancies": run.DiscrepancyCount,
+	})
+
+	return run, nil
+}
+
+// compareCharge checks a settled charge against the payment with its
+// transaction ID, which should have collected the settled amount
+func compareCharge(runID string, record *settlement.Record, payment *models.Payment) *models.ReconciliationDiscrepancy {
+	if payment == nil {
+		if !record.Settled() {
+			return nil
+		}
+		discrepancy := newRecordDiscrepancy(runID, models.DiscrepancyMissingRecord, record)
+		discrepancy.Detail = fmt.Sprintf("gateway settled a charge of %s %s with no matching payment", record.Amount, record.Currency)
+		return discrepancy
+	}
+
+	collected := payment.CapturedAmount.IsPositive()
+	var discrepancy *models.ReconciliationDiscrepancy
+	switch {
+	case record.Settled() != collected:
+		discrepancy = newRecordDiscrepancy(runID, models.DiscrepancyStatusMismatch, record)
+		discrepancy.Detail = fmt.Sprintf("gateway reports the charge %s but the payment is %s", record.Status, payment.Status)
+	case collected && !sameAmount(record, payment.CapturedAmount, payment.Currency):
+		discrepancy = newRecordDiscrepancy(runID, models.DiscrepancyAmountMismatch, record)
+		discrepancy.Detail = fmt.Sprintf("gateway settled %s %s but the payment collected %s %s",
+			record.Amount, record.Currency, payment.CapturedAmount, payment.Currency)
+	default:
+		return nil
+	}
+
+	discrepancy.PaymentID = payment.ID
+	discrepancy.LedgerAmount = payment.CapturedAmount
+	discrepancy.LedgerStatus = string(payment.Status)
+	return discrepancy
+}
+
+// compareRefund checks a settled refund against the refund with its
+// transaction ID, which should have completed for the settled amount
+func compareRefund(runID string, record *settlement.Record, refund *models.Refund) *models.ReconciliationDiscrepancy {
+	if refund == nil {
+		if !record.Settled() {
+			return nil
+		}
+		discrepancy := newRecordDiscrepancy(runID, models.DiscrepancyMissingRecord, record)
+		discrepancy.Detail = fmt.Sprintf("gateway settled a refund of %s %s with no matching refund", record.Amount, record.Currency)
+		return discrepancy
+	}
+
+	completed := refund.Status == models.PaymentCompleted
+	var discrepancy *models.ReconciliationDiscrepancy
+	switch {
+	case record.Settled() != completed:
+		discrepancy = newRecordDiscrepancy(runID, models.DiscrepancyStatusMismatch, record)
+		discrepancy.Detail = fmt.Sprintf("gateway reports the refund %s but it is %s", record.Status, refund.Status)
+	case completed && !sameAmount(record, refund.Amount, refund.Currency):
+		discrepancy = newRecordDiscrepancy(runID, models.DiscrepancyAmountMismatch, record)
+		discrepancy.Detail = fmt.Sprintf("gateway settled %s %s but the refund was for %s %s",
+			record.Amount, record.Currency, refund.Amount, refund.Currency)
+	default:
+		return nil
+	}
+
+	discrepancy.PaymentID = refund.PaymentID
+	discrepancy.RefundID = refund.ID
+	discrepancy.LedgerAmount = refund.Amount
+	discrepancy.LedgerStatus = string(refund.Status)
+	return discrepancy
+}
+
+func newRecordDiscrepancy(runID string, discrepancyType models.DiscrepancyType, record *settlement.Record) *models.ReconciliationDiscrepancy {
+	discrepancy := models.NewReconciliationDiscrepancy(runID, discrepancyType, record.Type, record.TransactionID)
+	discrepancy.Currency = record.Currency
+	discrepancy.SettledAmount = record.Amount
+	discrepancy.SettlementStatus = record.Status
+	return discrepancy
+}
+
+// sameAmount compares a settled amount with a ledger amount in its currency
+func sameAmount(record *settlement.Record, amount decimal.Decimal, currency string) bool {
+	return strings.EqualFold(record.Currency, currency) && record.Amount.Equal(amount)
+}
+
+// GetRun retrieves a reconciliation run
+func (s *reconciliationService) GetRun(ctx context.Context, runID string) (*models.ReconciliationRun, error) {
+	return s.repo.GetRunByID(ctx, runID)
+}
+
+// ListRuns retrieves reconciliation runs, newest first
+func (s *reconciliationService) ListRuns(ctx context.Context, limit, offset int) ([]*models.ReconciliationRun, error) {
+	return s.repo.ListRuns(ctx, limit, offset)
+}
+
+// GetDiscrepancy retrieves a discrepancy
+func (s *reconciliationService) GetDiscrepancy(ctx context.Context, discrepancyID string) (*models.ReconciliationDiscrepancy, error) {
+	return s.repo.GetDiscrepancyByID(ctx, discrepancyID)
+}
+
+// ListDiscrepancies retrieves discrepancies matching the filter
+func (s *reconciliationService) ListDiscrepancies(ctx context.Context, filter repository.DiscrepancyFilter) ([]*models.ReconciliationDiscrepancy, error) {
+	return s.repo.ListDiscrepancies(ctx, filter)
+}
+
+// ResolveDiscrepancy closes an open discrepancy with the admin's resolution
+func (s *reconciliationService) ResolveDiscrepancy(ctx context.Context, discrepancyID string, req *ResolveDiscrepancyRequest) (*models.ReconciliationDiscrepancy, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid resolution: %w", err)
+	}
+
+	discrepancy, err := s.repo.GetDiscrepancyByID(ctx, discrepancyID)
+	if err != nil {
+		return nil, err
+	}
+	if discrepancy.Status != models.DiscrepancyOpen {
+		return nil, fmt.Errorf("discrepancy %s is not open", discrepancyID)
+	}
+
+	now := time.Now()
+	discrepancy.Status = models.DiscrepancyResolved
+	discrepancy.Resolution = req.Resolution
+	discrepancy.ResolvedBy = req.ResolvedBy
+	discrepancy.ResolvedAt = &now
+	discrepancy.UpdatedAt = now
+
+	if err := s.repo.ResolveDiscrepancy(ctx, discrepancy); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Settlement discrepancy resolved", map[string]interface{}{
+		"discrepancy_id": discrepancy.ID,
+		"type":           discrepancy.Type,
+		"resolved_by":    discrepancy.ResolvedBy,
+	})
+
+	return discrepancy, nil
+}
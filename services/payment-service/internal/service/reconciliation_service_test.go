@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/settlement"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockReconciliationRepository is a mock implementation of ReconciliationRepository
+type MockReconciliationRepository struct {
+	mock.Mock
+}
+
+func (m *MockReconciliationRepository) GetPaymentsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Payment, error) {
+	args := m.Called(ctx, transactionIDs)
+	payments, _ := args.Get(0).([]*models.Payment)
+	return payments, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) GetRefundsByTransactionIDs(ctx context.Context, transactionIDs []string) ([]*models.Refund, error) {
+	args := m.Called(ctx, transactionIDs)
+	refunds, _ := args.Get(0).([]*models.Refund)
+	return refunds, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) GetCollectedPayments(ctx context.Context, from, to time.Time) ([]*models.Payment, error) {
+	args := m.Called(ctx, from, to)
+	payments, _ := args.Get(0).([]*models.Payment)
+	return payments, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) GetCompletedRefunds(ctx context.Context, from, to time.Time) ([]*models.Refund, error) {
+	args := m.Called(ctx, from, to)
+	refunds, _ := args.Get(0).([]*models.Refund)
+	return refunds, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.ReconciliationDiscrepancy) error {
+	args := m.Called(ctx, run, discrepancies)
+	return args.Error(0)
+}
+
+func (m *MockReconciliationRepository) GetRunByID(ctx context.Context, id string) (*models.ReconciliationRun, error) {
+	args := m.Called(ctx, id)
+	run, _ := args.Get(0).(*models.ReconciliationRun)
+	return run, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) GetRunByChecksum(ctx context.Context, checksum string) (*models.ReconciliationRun, error) {
+	args := m.Called(ctx, checksum)
+	run, _ := args.Get(0).(*models.ReconciliationRun)
+	return run, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) ListRuns(ctx context.Context, limit, offset int) ([]*models.ReconciliationRun, error) {
+	args := m.Called(ctx, limit, offset)
+	runs, _ := args.Get(0).([]*models.ReconciliationRun)
+	return runs, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) GetDiscrepancyByID(ctx context.Context, id string) (*models.ReconciliationDiscrepancy, error) {
+	args := m.Called(ctx, id)
+	discrepancy, _ := args.Get(0).(*models.ReconciliationDiscrepancy)
+	return discrepancy, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) ListDiscrepancies(ctx context.Context, filter repository.DiscrepancyFilter) ([]*models.ReconciliationDiscrepancy, error) {
+	args := m.Called(ctx, filter)
+	discrepancies, _ := args.Get(0).([]*models.ReconciliationDiscrepancy)
+	return discrepancies, args.Error(1)
+}
+
+func (m *MockReconciliationRepository) ResolveDiscrepancy(ctx context.Context, discrepancy *models.ReconciliationDiscrepancy) error {
+	args := m.Called(ctx, discrepancy)
+	return args.Error(0)
+}
+
+func settledPayment(id, transactionID, captured string, status models.PaymentStatus) *models.Payment {
+	return &models.Payment{
+		ID:             id,
+		Amount:         decimal.RequireFromString(captured),
+		CapturedAmount: decimal.RequireFromString(captured),
+		Currency:       "USD",
+		Status:         status,
+		TransactionID:  transactionID,
+	}
+}
+
+func TestReconciliationService_ReconcileReport(t *testing.T) {
+	mockRepo := new(MockReconciliationRepository)
+	service := NewReconciliationService(mockRepo)
+
+	ctx := context.Background()
+	report := "transaction_id,type,amount,currency,status,settled_at\n" +
+		"pi_match,charge,100.00,USD,settled,2024-03-01T01:00:00Z\n" +
+		"pi_short,charge,90.00,USD,settled,2024-03-01T02:00:00Z\n" +
+		"pi_failed,charge,50.00,USD,failed,2024-03-01T03:00:00Z\n" +
+		"pi_unknown,charge,20.00,USD,settled,2024-03-01T04:00:00Z\n" +
+		"re_match,refund,10.00,USD,settled,2024-03-01T05:00:00Z\n" +
+		"re_pending,refund,15.00,USD,settled,2024-03-01T06:00:00Z\n"
+	periodStart := time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetRunByChecksum", ctx, mock.AnythingOfType("string")).Return(nil, nil)
+	mockRepo.On("GetPaymentsByTransactionIDs", ctx, []string{"pi_match", "pi_short", "pi_failed", "pi_unknown"}).Return([]*models.Payment{
+		settledPayment("payment-match", "pi_match", "100.00", models.PaymentCompleted),
+		settledPayment("payment-short", "pi_short", "100.00", models.PaymentCompleted),
+		settledPayment("payment-failed", "pi_failed", "50.00", models.PaymentPartiallyRefunded),
+	}, nil)
+
+	matchedRefund := models.NewRefund("payment-match", "order-1", decimal.RequireFromString("10.00"), "USD", "Damaged")
+	matchedRefund.TransactionID = "re_match"
+	matchedRefund.Status = models.PaymentCompleted
+	pendingRefund := models.NewRefund("payment-match", "order-1", decimal.RequireFromString("15.00"), "USD", "Damaged")
+	pendingRefund.TransactionID = "re_pending"
+	pendingRefund.Status = models.PaymentProcessing
+	mockRepo.On("GetRefundsByTransactionIDs", ctx, []string{"re_match", "re_pending"}).Return([]*models.Refund{matchedRefund, pendingRefund}, nil)
+
+	// pi_lost was collected during the period but never settled
+	mockRepo.On("GetCollectedPayments", ctx, periodStart, periodEnd).Return([]*models.Payment{
+		settledPayment("payment-match", "pi_match", "100.00", models.PaymentCompleted),
+		settledPayment("payment-lost", "pi_lost", "30.00", models.PaymentCompleted),
+	}, nil)
+	mockRepo.On("GetCompletedRefunds", ctx, periodStart, periodEnd).Return([]*models.Refund{matchedRefund}, nil)
+
+	var saved []*models.ReconciliationDiscrepancy
+	mockRepo.On("CreateRun", ctx, mock.AnythingOfType("*models.ReconciliationRun"), mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(2).([]*models.ReconciliationDiscrepancy)
+	}).Return(nil)
+
+	run, err := service.ReconcileReport(ctx, settlement.NewCSVSource("march.csv", strings.NewReader(report)))
+
+	require.NoError(t, err)
+	assert.Equal(t, "march.csv", run.Source)
+	assert.Equal(t, periodStart, run.PeriodStart)
+	assert.Equal(t, periodEnd, run.PeriodEnd)
+	assert.Equal(t, 6, run.RecordCount)
+	assert.Equal(t, 2, run.MatchedCount)
+	assert.Equal(t, 5, run.DiscrepancyCount)
+
+	found := make(map[string]*models.ReconciliationDiscrepancy)
+	for _, d := range saved {
+		assert.Equal(t, run.ID, d.RunID)
+		assert.Equal(t, models.DiscrepancyOpen, d.Status)
+		found[d.TransactionID] = d
+	}
+	require.Len(t, found, 5)
+
+	assert.Equal(t, models.DiscrepancyAmountMismatch, found["pi_short"].Type)
+	assert.Equal(t, "payment-short", found["pi_short"].PaymentID)
+	assert.True(t, found["pi_short"].LedgerAmount.Equal(decimal.NewFromInt(100)))
+	assert.True(t, found["pi_short"].SettledAmount.Equal(decimal.NewFromInt(90)))
+
+	assert.Equal(t, models.DiscrepancyStatusMismatch, found["pi_failed"].Type)
+	assert.Equal(t, "failed", found["pi_failed"].SettlementStatus)
+	assert.Equal(t, string(models.PaymentPartiallyRefunded), found["pi_failed"].LedgerStatus)
+
+	assert.Equal(t, models.DiscrepancyMissingRecord, found["pi_unknown"].Type)
+	assert.Empty(t, found["pi_unknown"].PaymentID)
+
+	assert.Equal(t, models.DiscrepancyStatusMismatch, found["re_pending"].Type)
+	assert.Equal(t, models.SettlementRefund, found["re_pending"].RecordType)
+	assert.Equal(t, pendingRefund.ID, found["re_pending"].RefundID)
+
+	assert.Equal(t, models.DiscrepancyMissingSettlement, found["pi_lost"].Type)
+	assert.Equal(t, "payment-lost", found["pi_lost"].PaymentID)
+	assert.True(t, found["pi_lost"].LedgerAmount.Equal(decimal.NewFromInt(30)))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReconciliationService_ReconcileReport_AlreadyReconciled(t *testing.T) {
+	mockRepo := new(MockReconciliationRepository)
+	service := NewReconciliationService(mockRepo)
+
+	ctx := context.Background()
+	report := "transaction_id,amount,currency,status,settled_at\npi_123,100.00,USD,settled,2024-03-01\n"
+
+	mockRepo.On("GetRunByChecksum", ctx, mock.AnythingOfType("string")).Return(&models.ReconciliationRun{ID: "run-1"}, nil)
+
+	_, err := service.ReconcileReport(ctx, settlement.NewCSVSource("march.csv", strings.NewReader(report)))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already reconciled in run run-1")
+	mockRepo.AssertNotCalled(t, "CreateRun", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReconciliationService_ResolveDiscrepancy(t *testing.T) {
+	mockRepo := new(MockReconciliationRepository)
+	service := NewReconciliationService(mockRepo)
+
+	ctx := context.Background()
+	discrepancy := models.NewReconciliationDiscrepancy("run-1", models.DiscrepancyAmountMismatch, models.SettlementCharge, "pi_123")
+	mockRepo.On("GetDiscrepancyByID", ctx, discrepancy.ID).Return(discrepancy, nil)
+	mockRepo.On("ResolveDiscrepancy", ctx, discrepancy).Return(nil)
+
+	resolved, err := service.ResolveDiscrepancy(ctx, discrepancy.ID, &ResolveDiscrepancyRequest{
+		ResolvedBy: "admin-1",
+		Resolution: "Gateway fee deducted from the settlement",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DiscrepancyResolved, resolved.Status)
+	assert.Equal(t, "admin-1", resolved.ResolvedBy)
+	assert.NotNil(t, resolved.ResolvedAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReconciliationService_ResolveDiscrepancy_AlreadyResolved(t *testing.T) {
+	mockRepo := new(MockReconciliationRepository)
+	service := NewReconciliationService(mockRepo)
+
+	ctx := context.Background()
+	discrepancy := models.NewReconciliationDiscrepancy("run-1", models.DiscrepancyMissingRecord, models.SettlementCharge, "pi_123")
+	discrepancy.Status = models.DiscrepancyResolved
+	mockRepo.On("GetDiscrepancyByID", ctx, discrepancy.ID).Return(discrepancy, nil)
+
+	_, err := service.ResolveDiscrepancy(ctx, discrepancy.ID, &ResolveDiscrepancyRequest{
+		ResolvedBy: "admin-1",
+		Resolution: "Duplicate",
+	})
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ResolveDiscrepancy", mock.Anything, mock.Anything)
+}
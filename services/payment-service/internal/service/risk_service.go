@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/risk"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Risk review decisions an admin can make on a held payment
+const (
+	RiskReviewApprove = "approve"
+	RiskReviewReject  = "reject"
+)
+
+// RiskService scores payments before they reach the gateway and keeps every
+// assessment, including the queue of payments held for manual review
+type RiskService interface {
+	// AssessPayment scores the payment and records the assessment
+	AssessPayment(ctx context.Context, payment *models.Payment) (*models.RiskAssessment, error)
+	GetAssessment(ctx context.Context, assessmentID string) (*models.RiskAssessment, error)
+	GetPaymentAssessments(ctx context.Context, paymentID string) ([]*models.RiskAssessment, error)
+	ListAssessments(ctx context.Context, filter repository.RiskAssessmentFilter) ([]*models.RiskAssessment, error)
+	// DecideReview applies an admin's decision to an assessment pending review
+	DecideReview(ctx context.Context, assessmentID string, req *RiskReviewRequest) (*models.RiskAssessment, error)
+}
+
+// RiskReviewRequest is an admin's decision on a payment held for review
+type RiskReviewRequest struct {
+	Decision   string `json:"decision" validate:"required"` // approve or reject
+	ReviewedBy string `json:"reviewed_by" validate:"required"`
+	Note       string `json:"note"`
+}
+
+// riskService implements RiskService
+type riskService struct {
+	engine *risk.Engine
+	repo   repository.RiskRepository
+}
+
+// NewRiskService creates a new risk service
+func NewRiskService(engine *risk.Engine, repo repository.RiskRepository) RiskService {
+	return &riskService{
+		engine: engine,
+		repo:   repo,
+	}
+}
+
+// AssessPayment scores the payment and records the assessment, whatever the decision
+func (s *riskService) AssessPayment(ctx context.Context, payment *models.Payment) (*models.RiskAssessment, error) {
+	assessment, err := s.engine.Assess(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assess payment risk: %w", err)
+	}
+
+	if err := s.repo.CreateAssessment(ctx, assessment); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Payment risk assessed", map[string]interface{}{
+		"payment_id": payment.ID,
+		"score":      assessment.Score,
+		"decision":   assessment.Decision,
+		"rules":      len(assessment.Rules),
+	})
+
+	return assessment, nil
+}
+
+// GetAssessment retrieves a risk assessment
+func (s *riskService) GetAssessment(ctx context.Context, assessmentID string) (*models.RiskAssessment, error) {
+	return s.repo.GetAssessmentByID(ctx, assessmentID)
+}
+
+// GetPaymentAssessments retrieves the assessments of a payment, one per processing attempt
+func (s *riskService) GetPaymentAssessments(ctx context.Context, paymentID string) ([]*models.RiskAssessment, error) {
+	return s.repo.GetAssessmentsByPaymentID(ctx, paymentID)
+}
+
+// ListAssessments retrieves assessments matching the filter
+func (s *riskService) ListAssessments(ctx context.Context, filter repository.RiskAssessmentFilter) ([]*models.RiskAssessment, error) {
+	return s.repo.ListAssessments(ctx, filter)
+}
+
+// DecideReview approves or rejects an assessment pending review. The review
+// is saved by PaymentService.ReviewPayment along with the payment it
+// releases or blocks.
+func (s *riskService) DecideReview(ctx context.Context, assessmentID string, req *RiskReviewRequest) (*models.RiskAssessment, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid risk review: %w", err)
+	}
+
+	var status models.RiskReviewStatus
+	switch req.Decision {
+	case RiskReviewApprove:
+		status = models.RiskReviewApproved
+	case RiskReviewReject:
+		status = models.RiskReviewRejected
+	default:
+		return nil, fmt.Errorf("invalid risk review: decision must be %s or %s", RiskReviewApprove, RiskReviewReject)
+	}
+
+	assessment, err := s.repo.GetAssessmentByID(ctx, assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	if assessment.ReviewStatus != models.RiskReviewPending {
+		return nil, fmt.Errorf("risk assessment %s is not pending review", assessmentID)
+	}
+
+	now := time.Now()
+	assessment.ReviewStatus = status
+	assessment.ReviewedBy = req.ReviewedBy
+	assessment.ReviewNote = req.Note
+	assessment.ReviewedAt = &now
+	assessment.UpdatedAt = now
+
+	return assessment, nil
+}
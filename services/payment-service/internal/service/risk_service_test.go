@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/payment-service/internal/gateway"
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/risk"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRiskRepository is a mock implementation of RiskRepository
+type MockRiskRepository struct {
+	mock.Mock
+}
+
+func (m *MockRiskRepository) CountRecentPayments(ctx context.Context, dimension risk.Dimension, value string, since time.Time, excludePaymentID string) (int, error) {
+	args := m.Called(ctx, dimension, value, since, excludePaymentID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRiskRepository) GetUserHistory(ctx context.Context, userID, currency, excludePaymentID string) (*risk.UserHistory, error) {
+	args := m.Called(ctx, userID, currency, excludePaymentID)
+	history, _ := args.Get(0).(*risk.UserHistory)
+	return history, args.Error(1)
+}
+
+func (m *MockRiskRepository) CreateAssessment(ctx context.Context, assessment *models.RiskAssessment) error {
+	args := m.Called(ctx, assessment)
+	return args.Error(0)
+}
+
+func (m *MockRiskRepository) GetAssessmentByID(ctx context.Context, id string) (*models.RiskAssessment, error) {
+	args := m.Called(ctx, id)
+	assessment, _ := args.Get(0).(*models.RiskAssessment)
+	return assessment, args.Error(1)
+}
+
+func (m *MockRiskRepository) GetAssessmentsByPaymentID(ctx context.Context, paymentID string) ([]*models.RiskAssessment, error) {
+	args := m.Called(ctx, paymentID)
+	assessments, _ := args.Get(0).([]*models.RiskAssessment)
+	return assessments, args.Error(1)
+}
+
+func (m *MockRiskRepository) ListAssessments(ctx context.Context, filter repository.RiskAssessmentFilter) ([]*models.RiskAssessment, error) {
+	args := m.Called(ctx, filter)
+	assessments, _ := args.Get(0).([]*models.RiskAssessment)
+	return assessments, args.Error(1)
+}
+
+func newRiskedPaymentService(repo *MockPaymentRepository, gw *MockPaymentGateway, riskRepo *MockRiskRepository) PaymentService {
+	engine := risk.NewEngine(riskRepo, risk.DefaultConfig(), risk.DefaultRules()...)
+	return NewPaymentService(repo, gw, NewRiskService(engine, riskRepo), nil, DefaultPaymentConfig())
+}
+
+func screenedPayment(amount string) *models.Payment {
+	return &models.Payment{
+		ID:              "payment-123",
+		OrderID:         "order-123",
+		UserID:          "user-123",
+		Amount:          decimal.RequireFromString(amount),
+		Currency:        "USD",
+		Status:          models.PaymentPending,
+		TransactionID:   "pi_123",
+		PaymentMethodID: "pm_123",
+		RiskContext: models.PaymentRiskContext{
+			IPAddress:       "203.0.113.7",
+			BillingCountry:  "US",
+			ShippingCountry: "US",
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+func heldAssessment(paymentID string) *models.RiskAssessment {
+	return &models.RiskAssessment{
+		ID:           "assessment-123",
+		PaymentID:    paymentID,
+		Score:        65,
+		Decision:     models.RiskReview,
+		ReviewStatus: models.RiskReviewPending,
+	}
+}
+
+func TestPaymentService_ProcessPayment_HeldForReview(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("900.00")
+	payment.RiskContext.ShippingCountry = "NG"
+	lastWeek := time.Now().Add(-48 * time.Hour)
+
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil).Once()
+	riskRepo.On("CountRecentPayments", ctx, mock.Anything, mock.Anything, mock.Anything, payment.ID).Return(0, nil)
+	riskRepo.On("GetUserHistory", ctx, "user-123", "USD", payment.ID).Return(&risk.UserHistory{FirstPaymentAt: &lastWeek}, nil)
+	riskRepo.On("CreateAssessment", ctx, mock.MatchedBy(func(a *models.RiskAssessment) bool {
+		return a.Decision == models.RiskReview && a.ReviewStatus == models.RiskReviewPending && len(a.Rules) == 2
+	})).Return(nil)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentUnderReview, "pi_123", "held for risk review (score 65)", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	held := *payment
+	held.Status = models.PaymentUnderReview
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(&held, nil).Once()
+
+	result, err := service.ProcessPayment(ctx, payment.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentUnderReview, result.Status)
+
+	// The gateway is never asked to confirm a held payment
+	mockGateway.AssertNotCalled(t, "ConfirmPayment", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+	riskRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessPayment_Blocked(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("5.00")
+	longAgo := time.Now().AddDate(-1, 0, 0)
+
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil).Once()
+	riskRepo.On("CountRecentPayments", ctx, mock.Anything, mock.Anything, mock.Anything, payment.ID).Return(12, nil)
+	riskRepo.On("GetUserHistory", ctx, "user-123", "USD", payment.ID).Return(&risk.UserHistory{FirstPaymentAt: &longAgo}, nil)
+	riskRepo.On("CreateAssessment", ctx, mock.MatchedBy(func(a *models.RiskAssessment) bool {
+		return a.Decision == models.RiskBlock
+	})).Return(nil)
+	mockGateway.On("CancelPaymentIntent", ctx, "pi_123").Return(&gateway.PaymentResult{ID: "pi_123", Status: "canceled"}, nil)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentBlocked, "pi_123", "blocked by risk checks (score 95)", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	blocked := *payment
+	blocked.Status = models.PaymentBlocked
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(&blocked, nil).Once()
+
+	result, err := service.ProcessPayment(ctx, payment.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentBlocked, result.Status)
+
+	mockGateway.AssertNotCalled(t, "ConfirmPayment", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+	riskRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ProcessPayment_RiskCheckFailsClosed(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("50.00")
+
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+	riskRepo.On("CountRecentPayments", ctx, mock.Anything, mock.Anything, mock.Anything, payment.ID).Return(0, assert.AnError)
+
+	_, err := service.ProcessPayment(ctx, payment.ID)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "payment risk check failed")
+	mockGateway.AssertNotCalled(t, "ConfirmPayment", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdatePaymentStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_ReviewPayment_Approve(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("900.00")
+	payment.Status = models.PaymentUnderReview
+	assessment := heldAssessment(payment.ID)
+
+	riskRepo.On("GetAssessmentByID", ctx, assessment.ID).Return(assessment, nil)
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil).Once()
+	// The review is saved together with the payment leaving review
+	mockRepo.On("CompleteRiskReview", ctx, mock.MatchedBy(func(a *models.RiskAssessment) bool {
+		return a.ReviewStatus == models.RiskReviewApproved && a.ReviewedBy == "admin-1" && a.ReviewedAt != nil
+	}), models.PaymentPending, "pi_123", "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	// The released payment is confirmed with the gateway
+	mockRepo.On("UpdatePayment", ctx, mock.AnythingOfType("*models.Payment")).Return(nil)
+	mockRepo.On("CreatePaymentAttempt", ctx, payment.ID, 1, "processing", "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+	mockGateway.On("ConfirmPayment", ctx, "pi_123").Return(&gateway.PaymentResult{
+		ID:        "pi_123",
+		Status:    "succeeded",
+		Amount:    payment.Amount,
+		Currency:  "USD",
+		CreatedAt: time.Now(),
+	}, nil)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentCompleted, "pi_123", "", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	completed := *payment
+	completed.Status = models.PaymentCompleted
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(&completed, nil).Once()
+
+	result, err := service.ReviewPayment(ctx, assessment.ID, &RiskReviewRequest{
+		Decision:   RiskReviewApprove,
+		ReviewedBy: "admin-1",
+		Note:       "customer confirmed by phone",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentCompleted, result.Status)
+
+	mockRepo.AssertExpectations(t)
+	mockGateway.AssertExpectations(t)
+	riskRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ReviewPayment_Reject(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("900.00")
+	payment.Status = models.PaymentUnderReview
+	assessment := heldAssessment(payment.ID)
+
+	riskRepo.On("GetAssessmentByID", ctx, assessment.ID).Return(assessment, nil)
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil).Once()
+	mockRepo.On("CompleteRiskReview", ctx, mock.MatchedBy(func(a *models.RiskAssessment) bool {
+		return a.ReviewStatus == models.RiskReviewRejected
+	}), models.PaymentBlocked, "pi_123", "rejected in risk review", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+	mockGateway.On("CancelPaymentIntent", ctx, "pi_123").Return(&gateway.PaymentResult{ID: "pi_123", Status: "canceled"}, nil)
+
+	blocked := *payment
+	blocked.Status = models.PaymentBlocked
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(&blocked, nil).Once()
+
+	result, err := service.ReviewPayment(ctx, assessment.ID, &RiskReviewRequest{
+		Decision:   RiskReviewReject,
+		ReviewedBy: "admin-1",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentBlocked, result.Status)
+	mockGateway.AssertNotCalled(t, "ConfirmPayment", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+	riskRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_ReviewPayment_NotUnderReview(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("900.00")
+	payment.Status = models.PaymentCompleted
+	assessment := heldAssessment(payment.ID)
+
+	riskRepo.On("GetAssessmentByID", ctx, assessment.ID).Return(assessment, nil)
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+
+	_, err := service.ReviewPayment(ctx, assessment.ID, &RiskReviewRequest{Decision: RiskReviewApprove, ReviewedBy: "admin-1"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not under review")
+	mockRepo.AssertNotCalled(t, "CompleteRiskReview", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_ReviewPayment_SaveFails(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	riskRepo := new(MockRiskRepository)
+	service := newRiskedPaymentService(mockRepo, mockGateway, riskRepo)
+
+	ctx := context.Background()
+	payment := screenedPayment("900.00")
+	payment.Status = models.PaymentUnderReview
+	assessment := heldAssessment(payment.ID)
+
+	riskRepo.On("GetAssessmentByID", ctx, assessment.ID).Return(assessment, nil)
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+	mockRepo.On("CompleteRiskReview", ctx, mock.AnythingOfType("*models.RiskAssessment"), models.PaymentBlocked, "pi_123", "rejected in risk review", mock.AnythingOfType("*models.GatewayResponse")).
+		Return(errors.New("connection reset"))
+
+	_, err := service.ReviewPayment(ctx, assessment.ID, &RiskReviewRequest{Decision: RiskReviewReject, ReviewedBy: "admin-1"})
+
+	// Nothing was saved, so the payment can still be reviewed and its intent is left alone
+	require.Error(t, err)
+	mockGateway.AssertNotCalled(t, "CancelPaymentIntent", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdatePaymentStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRiskService_DecideReview(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		req        *RiskReviewRequest
+		assessment *models.RiskAssessment
+		wantErr    string
+	}{
+		{
+			name:    "missing reviewer",
+			req:     &RiskReviewRequest{Decision: RiskReviewApprove},
+			wantErr: "invalid risk review",
+		},
+		{
+			name:    "unknown decision",
+			req:     &RiskReviewRequest{Decision: "escalate", ReviewedBy: "admin-1"},
+			wantErr: "decision must be approve or reject",
+		},
+		{
+			name: "already reviewed",
+			req:  &RiskReviewRequest{Decision: RiskReviewReject, ReviewedBy: "admin-1"},
+			assessment: &models.RiskAssessment{
+				ID:           "assessment-123",
+				ReviewStatus: models.RiskReviewApproved,
+			},
+			wantErr: "not pending review",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			riskRepo := new(MockRiskRepository)
+			if tt.assessment != nil {
+				riskRepo.On("GetAssessmentByID", ctx, tt.assessment.ID).Return(tt.assessment, nil)
+			}
+			service := NewRiskService(risk.NewEngine(riskRepo, risk.DefaultConfig()), riskRepo)
+
+			_, err := service.DecideReview(ctx, "assessment-123", tt.req)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
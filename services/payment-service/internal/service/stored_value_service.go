@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/shared/currency"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+// storedValueGatewayID marks payments and refunds settled on a gift card or
+// wallet rather than by the payment gateway
+const storedValueGatewayID = "stored_value"
+
+// giftCardCodeAlphabet leaves out characters easily misread on a printed card
+const giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// StoredValueService manages gift cards and wallets and moves their balances
+// through the ledger for payments, refunds and admin adjustments
+type StoredValueService interface {
+	// IssueGiftCard creates a loaded gift card. The code is only returned here.
+	IssueGiftCard(ctx context.Context, req *IssueGiftCardRequest) (*models.GiftCard, error)
+	LookupGiftCard(ctx context.Context, code string) (*models.GiftCard, error)
+	GetGiftCard(ctx context.Context, id string) (*models.GiftCard, error)
+	DisableGiftCard(ctx context.Context, id string) (*models.GiftCard, error)
+	GetWallet(ctx context.Context, userID, currency string) (*models.Wallet, error)
+	// AdjustWallet credits or, with a negative amount, debits a wallet by hand
+	AdjustWallet(ctx context.Context, userID string, req *WalletAdjustmentRequest) (*models.StoredValueLedgerEntry, error)
+	GetLedger(ctx context.Context, accountType models.StoredValueAccountType, accountID string, limit, offset int) ([]*models.StoredValueLedgerEntry, error)
+
+	// ResolveAccount finds the gift card or wallet a stored-value payment draws on
+	ResolveAccount(ctx context.Context, paymentType models.PaymentType, userID, currency, giftCardCode string) (*StoredValueAccount, error)
+	// Redeem debits the payment's amount from its account, once per payment
+	Redeem(ctx context.Context, payment *models.Payment) (*models.StoredValueLedgerEntry, error)
+	// ReverseRedemption gives back what a payment redeemed. It returns nil
+	// when the payment redeemed nothing.
+	ReverseRedemption(ctx context.Context, payment *models.Payment) (*models.StoredValueLedgerEntry, error)
+	// CreditRefund credits a refund to the gift card or wallet that paid, or
+	// to the customer's wallet when the payment was not stored value or
+	// toStoreCredit is set
+	CreditRefund(ctx context.Context, payment *models.Payment, refund *models.Refund, toStoreCredit bool) (*models.StoredValueLedgerEntry, error)
+}
+
+// IssueGiftCardRequest loads a new gift card
+type IssueGiftCardRequest struct {
+	Amount    decimal.Decimal `json:"amount" validate:"required"`
+	Currency  string          `json:"currency" validate:"required"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	IssuedBy  string          `json:"issued_by" validate:"required"`
+	Note      string          `json:"note"`
+}
+
+// WalletAdjustmentRequest is a manual change to a customer's wallet
+type WalletAdjustmentRequest struct {
+	Amount    decimal.Decimal `json:"amount" validate:"required"`
+	Currency  string          `json:"currency" validate:"required"`
+	Note      string          `json:"note" validate:"required"`
+	CreatedBy string          `json:"created_by" validate:"required"`
+}
+
+// StoredValueAccount is the gift card or wallet behind a stored-value payment
+type StoredValueAccount struct {
+	Type     models.StoredValueAccountType `json:"type"`
+	ID       string                        `json:"id"`
+	Currency string                        `json:"currency"`
+	Balance  decimal.Decimal               `json:"balance"`
+}
+
+// storedValueService implements StoredValueService
+type storedValueService struct {
+	repo repository.StoredValueRepository
+}
+
+// NewStoredValueService creates a new stored-value service
+func NewStoredValueService(repo repository.StoredValueRepository) StoredValueService {
+	return &storedValueService{repo: repo}
+}
+
+// IssueGiftCard creates a gift card loaded with the requested balance
+func (s *storedValueService) IssueGiftCard(ctx context.Context, req *IssueGiftCardRequest) (*models.GiftCard, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid gift card request: %w", err)
+	}
+
+	money, err := s.checkAmount(req.Amount, req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gift card request: %w", err)
+	}
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("invalid gift card request: amount must be positive")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("invalid gift card request: expiry must be in the future")
+	}
+
+	code, err := newGiftCardCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate gift card code: %w", err)
+	}
+	normalized := models.NormalizeGiftCardCode(code)
+
+	now := time.Now()
+	card := &models.GiftCard{
+		ID:             uuid.New().String(),
+		Code:           code,
+		CodeLast4:      normalized[len(normalized)-4:],
+		Currency:       money.Code,
+		InitialBalance: req.Amount,
+		Balance:        req.Amount,
+		Status:         models.GiftCardActive,
+		IssuedBy:       req.IssuedBy,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	entry := models.NewLedgerEntry(models.StoredValueGiftCard, card.ID, models.LedgerIssue, req.Amount, money.Code)
+	entry.BalanceAfter = req.Amount
+	entry.Note = req.Note
+	entry.CreatedBy = req.IssuedBy
+
+	if err := s.repo.CreateGiftCard(ctx, card, hashGiftCardCode(normalized), entry); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Gift card issued", map[string]interface{}{
+		"gift_card_id": card.ID,
+		"code_last4":   card.CodeLast4,
+		"amount":       card.InitialBalance,
+		"currency":     card.Currency,
+		"issued_by":    card.IssuedBy,
+	})
+
+	return card, nil
+}
+
+// LookupGiftCard finds a gift card by its code
+func (s *storedValueService) LookupGiftCard(ctx context.Context, code string) (*models.GiftCard, error) {
+	normalized := models.NormalizeGiftCardCode(code)
+	if normalized == "" {
+		return nil, fmt.Errorf("gift card code is required")
+	}
+	return s.repo.GetGiftCardByCodeHash(ctx, hashGiftCardCode(normalized))
+}
+
+// GetGiftCard retrieves a gift card by ID
+func (s *storedValueService) GetGiftCard(ctx context.Context, id string) (*models.GiftCard, error) {
+	return s.repo.GetGiftCardByID(ctx, id)
+}
+
+// DisableGiftCard stops a gift card from being redeemed. Refunds can still
+// be credited back to it.
+func (s *storedValueService) DisableGiftCard(ctx context.Context, id string) (*models.GiftCard, error) {
+	if err := s.repo.UpdateGiftCardStatus(ctx, id, models.GiftCardDisabled); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Gift card disabled", map[string]interface{}{
+		"gift_card_id": id,
+	})
+
+	return s.repo.GetGiftCardByID(ctx, id)
+}
+
+// GetWallet retrieves the user's wallet in a currency, opening an empty one
+// the first time it is asked for
+func (s *storedValueService) GetWallet(ctx context.Context, userID, currencyCode string) (*models.Wallet, error) {
+	money, err := currency.Lookup(currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetOrCreateWallet(ctx, userID, money.Code)
+}
+
+// AdjustWallet credits or debits a wallet by hand. Debits cannot take the
+// balance below zero.
+func (s *storedValueService) AdjustWallet(ctx context.Context, userID string, req *WalletAdjustmentRequest) (*models.StoredValueLedgerEntry, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("invalid wallet adjustment: %w", err)
+	}
+	if req.Amount.IsZero() {
+		return nil, fmt.Errorf("invalid wallet adjustment: amount cannot be zero")
+	}
+
+	money, err := s.checkAmount(req.Amount, req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet adjustment: %w", err)
+	}
+
+	wallet, err := s.repo.GetOrCreateWallet(ctx, userID, money.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.NewLedgerEntry(models.StoredValueWallet, wallet.ID, models.LedgerAdjustment, req.Amount, money.Code)
+	entry.Note = req.Note
+	entry.CreatedBy = req.CreatedBy
+
+	entry, err = s.repo.ApplyEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Wallet adjusted", map[string]interface{}{
+		"wallet_id":     wallet.ID,
+		"user_id":       userID,
+		"amount":        entry.Amount,
+		"balance_after": entry.BalanceAfter,
+		"created_by":    entry.CreatedBy,
+	})
+
+	return entry, nil
+}
+
+// GetLedger retrieves an account's ledger, newest entry first
+func (s *storedValueService) GetLedger(ctx context.Context, accountType models.StoredValueAccountType, accountID string, limit, offset int) ([]*models.StoredValueLedgerEntry, error) {
+	return s.repo.GetEntriesByAccount(ctx, accountType, accountID, limit, offset)
+}
+
+// ResolveAccount finds the gift card, by its code, or the user's wallet a
+// stored-value payment draws on
+func (s *storedValueService) ResolveAccount(ctx context.Context, paymentType models.PaymentType, userID, currencyCode, giftCardCode string) (*StoredValueAccount, error) {
+	switch paymentType {
+	case models.PaymentTypeGiftCard:
+		card, err := s.LookupGiftCard(ctx, giftCardCode)
+		if err != nil {
+			return nil, err
+		}
+		if !card.IsRedeemable(time.Now()) {
+			return nil, fmt.Errorf("gift card is not redeemable")
+		}
+		if card.Currency != currencyCode {
+			return nil, fmt.Errorf("gift card is in %s, not %s", card.Currency, currencyCode)
+		}
+		return &StoredValueAccount{Type: models.StoredValueGiftCard, ID: card.ID, Currency: card.Currency, Balance: card.Balance}, nil
+	case models.PaymentTypeWallet:
+		wallet, err := s.repo.GetWallet(ctx, userID, currencyCode)
+		if err != nil {
+			return nil, err
+		}
+		return &StoredValueAccount{Type: models.StoredValueWallet, ID: wallet.ID, Currency: wallet.Currency, Balance: wallet.Balance}, nil
+	default:
+		return nil, fmt.Errorf("payment type %s is not stored value", paymentType)
+	}
+}
+
+// Redeem debits a stored-value payment's amount from the account recorded on
+// it. Redeeming the same payment again returns the first redemption.
+func (s *storedValueService) Redeem(ctx context.Context, payment *models.Payment) (*models.StoredValueLedgerEntry, error) {
+	entry := models.NewLedgerEntry(paymentAccountType(payment), payment.PaymentMethodID, models.LedgerRedemption, payment.Amount.Neg(), payment.Currency)
+	entry.PaymentID = payment.ID
+
+	entry, err := s.repo.ApplyEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Stored value redeemed", map[string]interface{}{
+		"payment_id":    payment.ID,
+		"account_type":  entry.AccountType,
+		"account_id":    entry.AccountID,
+		"amount":        payment.Amount,
+		"balance_after": entry.BalanceAfter,
+	})
+
+	return entry, nil
+}
+
+// ReverseRedemption credits back the redemption of a payment being cancelled
+func (s *storedValueService) ReverseRedemption(ctx context.Context, payment *models.Payment) (*models.StoredValueLedgerEntry, error) {
+	entries, err := s.repo.GetEntriesByPaymentID(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var redemption *models.StoredValueLedgerEntry
+	for _, entry := range entries {
+		if entry.Type == models.LedgerRedemption {
+			redemption = entry
+			break
+		}
+	}
+	if redemption == nil {
+		return nil, nil
+	}
+
+	entry := models.NewLedgerEntry(redemption.AccountType, redemption.AccountID, models.LedgerReversal, redemption.Amount.Neg(), redemption.Currency)
+	entry.PaymentID = payment.ID
+
+	entry, err = s.repo.ApplyEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Stored value redemption reversed", map[string]interface{}{
+		"payment_id":    payment.ID,
+		"account_id":    entry.AccountID,
+		"amount":        entry.Amount,
+		"balance_after": entry.BalanceAfter,
+	})
+
+	return entry, nil
+}
+
+// CreditRefund credits a refund as stored value. Gift card refunds go back to
+// the card while it can still be redeemed and to the customer's wallet after
+// that; everything else goes to the wallet.
+func (s *storedValueService) CreditRefund(ctx context.Context, payment *models.Payment, refund *models.Refund, toStoreCredit bool) (*models.StoredValueLedgerEntry, error) {
+	accountType, accountID := paymentAccountType(payment), payment.PaymentMethodID
+
+	if payment.Type == models.PaymentTypeGiftCard && !toStoreCredit {
+		card, err := s.repo.GetGiftCardByID(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if !card.IsRedeemable(time.Now()) {
+			toStoreCredit = true
+		}
+	}
+
+	if !payment.Type.IsStoredValue() || (payment.Type == models.PaymentTypeGiftCard && toStoreCredit) {
+		wallet, err := s.repo.GetOrCreateWallet(ctx, payment.UserID, payment.Currency)
+		if err != nil {
+			return nil, err
+		}
+		accountType, accountID = models.StoredValueWallet, wallet.ID
+	}
+
+	entry := models.NewLedgerEntry(accountType, accountID, models.LedgerRefund, refund.Amount, payment.Currency)
+	entry.PaymentID = payment.ID
+	entry.RefundID = refund.ID
+	entry.Note = refund.Reason
+
+	entry, err := s.repo.ApplyEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Refund credited as stored value", map[string]interface{}{
+		"refund_id":     refund.ID,
+		"payment_id":    payment.ID,
+		"account_type":  entry.AccountType,
+		"account_id":    entry.AccountID,
+		"amount":        entry.Amount,
+		"balance_after": entry.BalanceAfter,
+	})
+
+	return entry, nil
+}
+
+// checkAmount checks that an amount is expressible in the currency's minor unit
+func (s *storedValueService) checkAmount(amount decimal.Decimal, currencyCode string) (currency.Currency, error) {
+	money, err := currency.Lookup(currencyCode)
+	if err != nil {
+		return money, err
+	}
+	if !money.IsRounded(amount) {
+		return money, fmt.Errorf("amount %s has more decimals than %s allows", amount, money.Code)
+	}
+	return money, nil
+}
+
+// paymentAccountType is the kind of account a stored-value payment draws on
+func paymentAccountType(payment *models.Payment) models.StoredValueAccountType {
+	if payment.Type == models.PaymentTypeWallet {
+		return models.StoredValueWallet
+	}
+	return models.StoredValueGiftCard
+}
+
+// newGiftCardCode generates a random code formatted XXXX-XXXX-XXXX-XXXX
+func newGiftCardCode() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range bytes {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardCodeAlphabet[int(b)%len(giftCardCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// hashGiftCardCode hashes a normalized gift card code using SHA-256
+func hashGiftCardCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
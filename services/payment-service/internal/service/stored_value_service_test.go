@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStoredValueRepository is a mock implementation of StoredValueRepository
+type MockStoredValueRepository struct {
+	mock.Mock
+}
+
+func (m *MockStoredValueRepository) CreateGiftCard(ctx context.Context, card *models.GiftCard, codeHash string, entry *models.StoredValueLedgerEntry) error {
+	args := m.Called(ctx, card, codeHash, entry)
+	return args.Error(0)
+}
+
+func (m *MockStoredValueRepository) GetGiftCardByID(ctx context.Context, id string) (*models.GiftCard, error) {
+	args := m.Called(ctx, id)
+	card, _ := args.Get(0).(*models.GiftCard)
+	return card, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) GetGiftCardByCodeHash(ctx context.Context, codeHash string) (*models.GiftCard, error) {
+	args := m.Called(ctx, codeHash)
+	card, _ := args.Get(0).(*models.GiftCard)
+	return card, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) UpdateGiftCardStatus(ctx context.Context, id string, status models.GiftCardStatus) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockStoredValueRepository) GetWallet(ctx context.Context, userID, currency string) (*models.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	wallet, _ := args.Get(0).(*models.Wallet)
+	return wallet, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) GetOrCreateWallet(ctx context.Context, userID, currency string) (*models.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	wallet, _ := args.Get(0).(*models.Wallet)
+	return wallet, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) ApplyEntry(ctx context.Context, entry *models.StoredValueLedgerEntry) (*models.StoredValueLedgerEntry, error) {
+	args := m.Called(ctx, entry)
+	if apply, ok := args.Get(0).(func(context.Context, *models.StoredValueLedgerEntry) *models.StoredValueLedgerEntry); ok {
+		return apply(ctx, entry), args.Error(1)
+	}
+	applied, _ := args.Get(0).(*models.StoredValueLedgerEntry)
+	return applied, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) GetEntriesByAccount(ctx context.Context, accountType models.StoredValueAccountType, accountID string, limit, offset int) ([]*models.StoredValueLedgerEntry, error) {
+	args := m.Called(ctx, accountType, accountID, limit, offset)
+	entries, _ := args.Get(0).([]*models.StoredValueLedgerEntry)
+	return entries, args.Error(1)
+}
+
+func (m *MockStoredValueRepository) GetEntriesByPaymentID(ctx context.Context, paymentID string) ([]*models.StoredValueLedgerEntry, error) {
+	args := m.Called(ctx, paymentID)
+	entries, _ := args.Get(0).([]*models.StoredValueLedgerEntry)
+	return entries, args.Error(1)
+}
+
+// appliedEntry returns the entry ApplyEntry was called with, with its balance filled in
+func appliedEntry(balanceAfter string) func(ctx context.Context, entry *models.StoredValueLedgerEntry) *models.StoredValueLedgerEntry {
+	return func(ctx context.Context, entry *models.StoredValueLedgerEntry) *models.StoredValueLedgerEntry {
+		entry.BalanceAfter = decimal.RequireFromString(balanceAfter)
+		return entry
+	}
+}
+
+func activeGiftCard(balance string) *models.GiftCard {
+	return &models.GiftCard{
+		ID:             "card-123",
+		CodeLast4:      "WXYZ",
+		Currency:       "USD",
+		InitialBalance: decimal.NewFromInt(100),
+		Balance:        decimal.RequireFromString(balance),
+		Status:         models.GiftCardActive,
+	}
+}
+
+func giftCardPayment(amount string) *models.Payment {
+	return &models.Payment{
+		ID:              "payment-123",
+		OrderID:         "order-123",
+		UserID:          "user-123",
+		Amount:          decimal.RequireFromString(amount),
+		Currency:        "USD",
+		Type:            models.PaymentTypeGiftCard,
+		Status:          models.PaymentPending,
+		PaymentMethodID: "card-123",
+		CreatedAt:       time.Now(),
+	}
+}
+
+func TestStoredValueService_IssueGiftCard(t *testing.T) {
+	svRepo := new(MockStoredValueRepository)
+	service := NewStoredValueService(svRepo)
+
+	ctx := context.Background()
+	var codeHash string
+	svRepo.On("CreateGiftCard", ctx, mock.AnythingOfType("*models.GiftCard"), mock.AnythingOfType("string"), mock.MatchedBy(func(e *models.StoredValueLedgerEntry) bool {
+		return e.Type == models.LedgerIssue && e.Amount.Equal(decimal.NewFromInt(50)) && e.BalanceAfter.Equal(e.Amount) && e.CreatedBy == "admin-1"
+	})).Run(func(args mock.Arguments) {
+		codeHash = args.String(2)
+	}).Return(nil)
+
+	card, err := service.IssueGiftCard(ctx, &IssueGiftCardRequest{
+		Amount:   decimal.NewFromInt(50),
+		Currency: "usd",
+		IssuedBy: "admin-1",
+	})
+
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z2-9]{4}-[A-Z2-9]{4}-[A-Z2-9]{4}-[A-Z2-9]{4}$`), card.Code)
+	assert.Equal(t, card.Code[len(card.Code)-4:], card.CodeLast4)
+	assert.Equal(t, "USD", card.Currency)
+	assert.True(t, card.Balance.Equal(decimal.NewFromInt(50)))
+	// Only the hash of the code is stored, and lookups normalize what customers type
+	assert.NotContains(t, codeHash, card.Code)
+	assert.Equal(t, hashGiftCardCode(models.NormalizeGiftCardCode(card.Code)), codeHash)
+	svRepo.AssertExpectations(t)
+}
+
+func TestStoredValueService_IssueGiftCard_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *IssueGiftCardRequest
+	}{
+		{"missing issuer", &IssueGiftCardRequest{Amount: decimal.NewFromInt(50), Currency: "USD"}},
+		{"negative amount", &IssueGiftCardRequest{Amount: decimal.NewFromInt(-5), Currency: "USD", IssuedBy: "admin-1"}},
+		{"too many decimals", &IssueGiftCardRequest{Amount: decimal.RequireFromString("10.005"), Currency: "USD", IssuedBy: "admin-1"}},
+		{"unknown currency", &IssueGiftCardRequest{Amount: decimal.NewFromInt(50), Currency: "XXX", IssuedBy: "admin-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svRepo := new(MockStoredValueRepository)
+			service := NewStoredValueService(svRepo)
+
+			_, err := service.IssueGiftCard(context.Background(), tt.req)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid gift card request")
+			svRepo.AssertNotCalled(t, "CreateGiftCard", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStoredValueService_LookupGiftCard_NormalizesCode(t *testing.T) {
+	svRepo := new(MockStoredValueRepository)
+	service := NewStoredValueService(svRepo)
+
+	ctx := context.Background()
+	card := activeGiftCard("100")
+	svRepo.On("GetGiftCardByCodeHash", ctx, hashGiftCardCode("ABCDEFGHJKLMNPQR")).Return(card, nil)
+
+	result, err := service.LookupGiftCard(ctx, " abcd-efgh jklm-npqr ")
+
+	require.NoError(t, err)
+	assert.Equal(t, card.ID, result.ID)
+}
+
+func TestPaymentService_CreatePayment_GiftCardPartial(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	svRepo := new(MockStoredValueRepository)
+	service := NewPaymentService(mockRepo, mockGateway, nil, NewStoredValueService(svRepo), DefaultPaymentConfig())
+
+	ctx := context.Background()
+	svRepo.On("GetGiftCardByCodeHash", ctx, hashGiftCardCode("ABCDEFGHJKLMNPQR")).Return(activeGiftCard("30.00"), nil)
+	mockRepo.On("CreatePayment", ctx, mock.AnythingOfType("*models.Payment")).Return(nil)
+
+	req := &CreatePaymentRequest{
+		OrderID:      "order-123",
+		UserID:       "user-123",
+		Amount:       decimal.RequireFromString("75.00"),
+		Currency:     "USD",
+		Type:         models.PaymentTypeGiftCard,
+		GiftCardCode: "ABCD-EFGH-JKLM-NPQR",
+		AllowPartial: true,
+	}
+
+	payment, err := service.CreatePayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentTypeGiftCard, payment.Type)
+	assert.Equal(t, "card-123", payment.PaymentMethodID)
+	assert.True(t, payment.Amount.Equal(decimal.RequireFromString("30.00")))
+	mockGateway.AssertNotCalled(t, "CreatePaymentIntent", mock.Anything, mock.Anything)
+
+	// Without partial payments the card must cover the whole amount
+	req.AllowPartial = false
+	_, err = service.CreatePayment(ctx, req)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient balance")
+	mockRepo.AssertNumberOfCalls(t, "CreatePayment", 1)
+}
+
+func TestPaymentService_CreatePayment_StoredValueDisabled(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	service := NewPaymentService(mockRepo, mockGateway, nil, nil, DefaultPaymentConfig())
+
+	_, err := service.CreatePayment(context.Background(), &CreatePaymentRequest{
+		OrderID:  "order-123",
+		UserID:   "user-123",
+		Amount:   decimal.NewFromInt(10),
+		Currency: "USD",
+		Type:     models.PaymentTypeWallet,
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not enabled")
+	mockRepo.AssertNotCalled(t, "CreatePayment", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_ProcessPayment_RedeemsStoredValue(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	svRepo := new(MockStoredValueRepository)
+	service := NewPaymentService(mockRepo, mockGateway, nil, NewStoredValueService(svRepo), DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payment := giftCardPayment("30.00")
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil).Once()
+
+	var redemptionID string
+	svRepo.On("ApplyEntry", ctx, mock.MatchedBy(func(e *models.StoredValueLedgerEntry) bool {
+		return e.Type == models.LedgerRedemption && e.AccountType == models.StoredValueGiftCard &&
+			e.AccountID == "card-123" && e.PaymentID == payment.ID && e.Amount.Equal(decimal.RequireFromString("-30.00"))
+	})).Return(func(ctx context.Context, entry *models.StoredValueLedgerEntry) *models.StoredValueLedgerEntry {
+		redemptionID = entry.ID
+		return appliedEntry("0")(ctx, entry)
+	}, nil)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentCompleted, mock.AnythingOfType("string"), "", mock.MatchedBy(func(r *models.GatewayResponse) bool {
+		return r.GatewayID == storedValueGatewayID && r.Status == "succeeded"
+	})).Return(nil)
+
+	completed := *payment
+	completed.Status = models.PaymentCompleted
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(&completed, nil).Once()
+
+	result, err := service.ProcessPayment(ctx, payment.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentCompleted, result.Status)
+	mockRepo.AssertCalled(t, "UpdatePaymentStatus", ctx, payment.ID, models.PaymentCompleted, redemptionID, "", mock.Anything)
+	mockGateway.AssertNotCalled(t, "ConfirmPayment", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_ProcessPayment_StoredValueInsufficientBalance(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	svRepo := new(MockStoredValueRepository)
+	service := NewPaymentService(mockRepo, mockGateway, nil, NewStoredValueService(svRepo), DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payment := giftCardPayment("30.00")
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+	// Another redemption spent the balance after this payment was created
+	svRepo.On("ApplyEntry", ctx, mock.Anything).Return(nil, assert.AnError)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentFailed, "", assert.AnError.Error(), mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+
+	result, err := service.ProcessPayment(ctx, payment.ID)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPaymentService_CancelPayment_ReversesRedemption(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	mockGateway := new(MockPaymentGateway)
+	svRepo := new(MockStoredValueRepository)
+	service := NewPaymentService(mockRepo, mockGateway, nil, NewStoredValueService(svRepo), DefaultPaymentConfig())
+
+	ctx := context.Background()
+	payment := giftCardPayment("30.00")
+	payment.Status = models.PaymentProcessing
+	redemption := models.NewLedgerEntry(models.StoredValueGiftCard, "card-123", models.LedgerRedemption, decimal.RequireFromString("-30.00"), "USD")
+	redemption.PaymentID = payment.ID
+
+	mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+	mockRepo.On("UpdatePaymentStatus", ctx, payment.ID, models.PaymentCancelled, "", "order cancelled", mock.AnythingOfType("*models.GatewayResponse")).Return(nil)
+	svRepo.On("GetEntriesByPaymentID", ctx, payment.ID).Return([]*models.StoredValueLedgerEntry{redemption}, nil)
+	svRepo.On("ApplyEntry", ctx, mock.MatchedBy(func(e *models.StoredValueLedgerEntry) bool {
+		return e.Type == models.LedgerReversal && e.AccountID == "card-123" && e.Amount.Equal(decimal.RequireFromString("30.00"))
+	})).Return(appliedEntry("30.00"), nil)
+
+	err := service.CancelPayment(ctx, payment.ID, "order cancelled")
+
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	svRepo.AssertExpectations(t)
+	mockGateway.AssertNotCalled(t, "CancelPaymentIntent", mock.Anything, mock.Anything)
+}
+
+func TestPaymentService_CreateRefund_StoredValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		paymentType   models.PaymentType
+		cardStatus    models.GiftCardStatus
+		toStoreCredit bool
+		wantAccount   models.StoredValueAccountType
+		wantAccountID string
+	}{
+		{"gift card refunded to the card", models.PaymentTypeGiftCard, models.GiftCardActive, false, models.StoredValueGiftCard, "card-123"},
+		{"disabled gift card refunded to the wallet", models.PaymentTypeGiftCard, models.GiftCardDisabled, false, models.StoredValueWallet, "wallet-123"},
+		{"card payment refunded as store credit", models.PaymentTypeCard, "", true, models.StoredValueWallet, "wallet-123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPaymentRepository)
+			mockGateway := new(MockPaymentGateway)
+			svRepo := new(MockStoredValueRepository)
+			service := NewPaymentService(mockRepo, mockGateway, nil, NewStoredValueService(svRepo), DefaultPaymentConfig())
+
+			ctx := context.Background()
+			payment := giftCardPayment("30.00")
+			payment.Type = tt.paymentType
+			payment.Status = models.PaymentCompleted
+			payment.CapturedAmount = payment.Amount
+			if tt.paymentType == models.PaymentTypeCard {
+				payment.PaymentMethodID = "pm_123"
+			}
+
+			card := activeGiftCard("0")
+			card.Status = tt.cardStatus
+			svRepo.On("GetGiftCardByID", ctx, "card-123").Return(card, nil).Maybe()
+			svRepo.On("GetOrCreateWallet", ctx, "user-123", "USD").Return(&models.Wallet{ID: "wallet-123", UserID: "user-123", Currency: "USD"}, nil).Maybe()
+
+			mockRepo.On("GetPaymentByID", ctx, payment.ID).Return(payment, nil)
+			mockRepo.On("ReserveRefund", ctx, mock.AnythingOfType("*models.Refund")).Return(payment, nil)
+			svRepo.On("ApplyEntry", ctx, mock.MatchedBy(func(e *models.StoredValueLedgerEntry) bool {
+				return e.Type == models.LedgerRefund && e.AccountType == tt.wantAccount && e.AccountID == tt.wantAccountID &&
+					e.RefundID != "" && e.Amount.Equal(decimal.NewFromInt(10))
+			})).Return(appliedEntry("10"), nil)
+			mockRepo.On("CompleteRefund", ctx, mock.MatchedBy(func(r *models.Refund) bool {
+				return r.Status == models.PaymentCompleted && r.GatewayResponse.GatewayID == storedValueGatewayID
+			})).Return(payment, nil)
+
+			refund, err := service.CreateRefund(ctx, &CreateRefundRequest{
+				PaymentID:     payment.ID,
+				Amount:        decimal.NewFromInt(10),
+				Reason:        "returned item",
+				ToStoreCredit: tt.toStoreCredit,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, models.PaymentCompleted, refund.Status)
+			svRepo.AssertExpectations(t)
+			mockGateway.AssertNotCalled(t, "CreateRefund", mock.Anything, mock.Anything)
+		})
+	}
+}
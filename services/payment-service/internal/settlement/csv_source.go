@@ -0,0 +1,155 @@
+package settlement
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// CSV settlement reports have a header row naming their columns, in any order:
+//
+//	transaction_id,type,amount,currency,status,settled_at
+//	pi_123,charge,100.00,USD,settled,2024-03-01T02:00:00Z
+//
+// Amounts are in major units. Type defaults to charge and settled_at may also
+// be a plain date.
+var requiredCSVColumns = []string{"transaction_id", "amount", "currency", "status", "settled_at"}
+
+// CSVSource reads a settlement report in CSV form
+type CSVSource struct {
+	name   string
+	reader io.Reader
+	path   string
+}
+
+// NewCSVSource creates a source reading a CSV report from r
+func NewCSVSource(name string, r io.Reader) *CSVSource {
+	return &CSVSource{name: name, reader: r}
+}
+
+// NewCSVFileSource creates a source reading a CSV report file, named after the file
+func NewCSVFileSource(path string) *CSVSource {
+	return &CSVSource{name: filepath.Base(path), path: path}
+}
+
+// Load reads and parses the report
+func (s *CSVSource) Load(ctx context.Context) (*Report, error) {
+	reader := s.reader
+	if s.path != "" {
+		file, err := os.Open(s.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open settlement report: %w", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement report: %w", err)
+	}
+
+	records, err := parseCSV(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement report %s: %w", s.name, err)
+	}
+
+	checksum := sha256.Sum256(content)
+	return &Report{
+		Name:     s.name,
+		Checksum: hex.EncodeToString(checksum[:]),
+		Records:  records,
+	}, nil
+}
+
+func parseCSV(content []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("report is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		record := Record{
+			TransactionID: field(row, "transaction_id"),
+			Type:          models.SettlementRecordType(strings.ToLower(field(row, "type"))),
+			Currency:      strings.ToUpper(field(row, "currency")),
+			Status:        strings.ToLower(field(row, "status")),
+		}
+		if record.TransactionID == "" {
+			return nil, fmt.Errorf("line %d: missing transaction_id", line)
+		}
+
+		switch record.Type {
+		case "":
+			record.Type = models.SettlementCharge
+		case models.SettlementCharge, models.SettlementRefund:
+		default:
+			return nil, fmt.Errorf("line %d: unknown type %q", line, record.Type)
+		}
+
+		record.Amount, err = decimal.NewFromString(field(row, "amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount: %w", line, err)
+		}
+
+		record.SettledAt, err = parseSettledAt(field(row, "settled_at"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid settled_at: %w", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func parseSettledAt(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,82 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVSource_Load(t *testing.T) {
+	content := "settled_at,transaction_id,type,amount,currency,status\n" +
+		"2024-03-01T02:00:00Z,pi_123,charge,100.00,usd,settled\n" +
+		"2024-03-02,re_456,refund,25.50,USD,Failed\n" +
+		"2024-03-02,pi_789,,10,USD,succeeded\n"
+
+	report, err := NewCSVSource("march.csv", strings.NewReader(content)).Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "march.csv", report.Name)
+	assert.Len(t, report.Checksum, 64)
+	require.Len(t, report.Records, 3)
+
+	assert.Equal(t, Record{
+		TransactionID: "pi_123",
+		Type:          models.SettlementCharge,
+		Amount:        decimal.RequireFromString("100.00"),
+		Currency:      "USD",
+		Status:        "settled",
+		SettledAt:     time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC),
+	}, report.Records[0])
+	assert.True(t, report.Records[0].Settled())
+
+	assert.Equal(t, models.SettlementRefund, report.Records[1].Type)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), report.Records[1].SettledAt)
+	assert.False(t, report.Records[1].Settled())
+
+	// Type defaults to charge
+	assert.Equal(t, models.SettlementCharge, report.Records[2].Type)
+}
+
+func TestCSVSource_ChecksumIdentifiesContent(t *testing.T) {
+	content := "transaction_id,amount,currency,status,settled_at\npi_123,100.00,USD,settled,2024-03-01\n"
+
+	first, err := NewCSVSource("a.csv", strings.NewReader(content)).Load(context.Background())
+	require.NoError(t, err)
+	second, err := NewCSVSource("b.csv", strings.NewReader(content)).Load(context.Background())
+	require.NoError(t, err)
+	other, err := NewCSVSource("a.csv", strings.NewReader(content+"pi_456,5,USD,settled,2024-03-01\n")).Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, first.Checksum, second.Checksum)
+	assert.NotEqual(t, first.Checksum, other.Checksum)
+}
+
+func TestCSVSource_InvalidReports(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "", "report is empty"},
+		{"missing column", "transaction_id,amount,currency,settled_at\n", "missing column status"},
+		{"missing transaction", "transaction_id,amount,currency,status,settled_at\n,1,USD,settled,2024-03-01\n", "line 2: missing transaction_id"},
+		{"bad amount", "transaction_id,amount,currency,status,settled_at\npi_1,ten,USD,settled,2024-03-01\n", "line 2: invalid amount"},
+		{"bad date", "transaction_id,amount,currency,status,settled_at\npi_1,10,USD,settled,yesterday\n", "line 2: invalid settled_at"},
+		{"unknown type", "transaction_id,type,amount,currency,status,settled_at\npi_1,payout,10,USD,settled,2024-03-01\n", `line 2: unknown type "payout"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCSVSource("report.csv", strings.NewReader(tt.content)).Load(context.Background())
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
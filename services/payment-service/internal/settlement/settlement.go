@@ -0,0 +1,43 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+// Record is one charge or refund the gateway settled, or failed to
+type Record struct {
+	TransactionID string                      `json:"transaction_id"`
+	Type          models.SettlementRecordType `json:"type"`
+	Amount        decimal.Decimal             `json:"amount"`
+	Currency      string                      `json:"currency"`
+	Status        string                      `json:"status"`
+	SettledAt     time.Time                   `json:"settled_at"`
+}
+
+// Settled reports whether the gateway actually moved the money
+func (r *Record) Settled() bool {
+	switch strings.ToLower(r.Status) {
+	case "settled", "succeeded", "available", "paid":
+		return true
+	}
+	return false
+}
+
+// Report is a settlement report from the gateway. The checksum identifies its
+// content so the same report is not reconciled twice.
+type Report struct {
+	Name     string
+	Checksum string
+	Records  []Record
+}
+
+// Source supplies settlement reports, from the gateway's reporting API or
+// from files exported from it
+type Source interface {
+	Load(ctx context.Context) (*Report, error)
+}
@@ -5,13 +5,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/shopsphere/payment-service/internal/gateway"
 	"github.com/shopsphere/payment-service/internal/handlers"
 	"github.com/shopsphere/payment-service/internal/repository"
+	"github.com/shopsphere/payment-service/internal/risk"
 	"github.com/shopsphere/payment-service/internal/service"
+	"github.com/shopsphere/payment-service/internal/settlement"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/middleware"
 	"github.com/shopsphere/shared/utils"
 )
 
@@ -26,15 +32,7 @@ func main() {
 		port = "8006"
 	}
 
-	stripeSecretKey := os.Getenv("STRIPE_SECRET_KEY")
-	if stripeSecretKey == "" {
-		log.Fatal("STRIPE_SECRET_KEY environment variable is required")
-	}
-
-	stripeWebhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
-	if stripeWebhookSecret == "" {
-		log.Fatal("STRIPE_WEBHOOK_SECRET environment variable is required")
-	}
+	paymentGateway := newPaymentGateway(port)
 
 	// Initialize database connection
 	dbConfig := &utils.DatabaseConfig{
@@ -55,10 +53,75 @@ func main() {
 	log.Printf("Connected to database: %s", dbConfig.DBName)
 
 	// Initialize components
+	paymentConfig := service.DefaultPaymentConfig()
+	if ttl, err := time.ParseDuration(os.Getenv("PAYMENT_AUTHORIZATION_TTL")); err == nil {
+		paymentConfig.AuthorizationTTL = ttl
+	}
+	paymentConfig.WebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
+
+	// Score payments before they reach the gateway; risky ones are held for
+	// review or blocked
+	riskConfig := risk.DefaultConfig()
+	riskConfig.ReviewScore = getEnvAsIntOrDefault("RISK_REVIEW_SCORE", riskConfig.ReviewScore)
+	riskConfig.BlockScore = getEnvAsIntOrDefault("RISK_BLOCK_SCORE", riskConfig.BlockScore)
+	riskRepo := repository.NewPostgresRiskRepository(db)
+	riskService := service.NewRiskService(risk.NewEngine(riskRepo, riskConfig, risk.DefaultRules()...), riskRepo)
+
+	// Gift cards and wallets pay from stored value instead of the gateway
+	storedValueService := service.NewStoredValueService(repository.NewPostgresStoredValueRepository(db))
+
 	paymentRepo := repository.NewPostgresPaymentRepository(db)
-	paymentGateway := gateway.NewStripeGateway(stripeSecretKey, stripeWebhookSecret)
-	paymentService := service.NewPaymentService(paymentRepo, paymentGateway)
-	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	paymentService := service.NewPaymentService(paymentRepo, paymentGateway, riskService, storedValueService, paymentConfig)
+
+	// Idempotency keys make retried payment and refund requests safe
+	idempotencyConfig := middleware.DefaultIdempotencyConfig("payment-service")
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil {
+		idempotencyConfig.TTL = ttl
+	}
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(db)
+	idempotency := middleware.NewIdempotencyMiddleware(idempotencyStore, idempotencyConfig)
+	paymentHandler := handlers.NewPaymentHandler(paymentService, idempotency)
+
+	reconciliationRepo := repository.NewPostgresReconciliationRepository(db)
+	reconciliationService := service.NewReconciliationService(reconciliationRepo)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	riskHandler := handlers.NewRiskHandler(riskService, paymentService)
+	storedValueHandler := handlers.NewStoredValueHandler(storedValueService)
+
+	// Relay outbox events to the broker and capture payments as shipments ship;
+	// events stay queued while Redis is unavailable and both start once it connects
+	events.ConnectRedisBroker(context.Background(), utils.NewRedisConfig(), func(broker *events.RedisBroker) {
+		relay := events.NewRelay(events.NewPostgresOutbox(db), broker, events.DefaultRelayConfig())
+		go relay.Run(context.Background())
+
+		consumer := events.NewConsumer("payment-service",
+			events.NewPostgresProcessedEventStore(db),
+			events.NewPostgresDeadLetterStore(db),
+			events.DefaultRetryPolicy())
+		handlers.NewShipmentEventHandler(paymentService).RegisterHandlers(consumer)
+		consumer.SubscribeTo(broker)
+		if err := broker.Listen(context.Background(), consumer.Name()); err != nil {
+			log.Printf("Event listener stopped: %v", err)
+		}
+	})
+
+	// Void authorizations that were never captured before their hold expired
+	go voidExpiredAuthorizations(context.Background(), paymentService)
+
+	// Settle captures whose gateway outcome was lost to a crash
+	go recoverPendingCaptures(context.Background(), paymentService)
+
+	// Drop idempotency keys past their expiry
+	go middleware.DeleteExpiredIdempotencyKeys(context.Background(), idempotencyStore, time.Hour)
+
+	// Reconcile settlement reports exported from the gateway into a directory
+	if dir := os.Getenv("SETTLEMENT_REPORT_DIR"); dir != "" {
+		interval := 24 * time.Hour
+		if parsed, err := time.ParseDuration(os.Getenv("SETTLEMENT_RECONCILIATION_INTERVAL")); err == nil {
+			interval = parsed
+		}
+		go reconcileSettlementReports(context.Background(), reconciliationService, dir, interval)
+	}
 
 	// Create router
 	router := mux.NewRouter()
@@ -83,6 +146,9 @@ func main() {
 
 	// Register payment routes
 	paymentHandler.RegisterRoutes(router)
+	reconciliationHandler.RegisterRoutes(router)
+	riskHandler.RegisterRoutes(router)
+	storedValueHandler.RegisterRoutes(router)
 
 	// Add logging middleware
 	router.Use(utils.LogMiddleware("payment-service"))
@@ -95,13 +161,130 @@ func main() {
 	log.Printf("  POST /payments/{id}/process - Process payment")
 	log.Printf("  POST /payments/{id}/cancel - Cancel payment")
 	log.Printf("  POST /payments/{id}/retry - Retry payment")
+	log.Printf("  POST /payments/{id}/capture - Capture authorized payment")
+	log.Printf("  POST /payments/{id}/void - Void authorized payment")
 	log.Printf("  POST /payment-methods - Create payment method")
 	log.Printf("  POST /refunds - Create refund")
 	log.Printf("  POST /webhooks/stripe - Stripe webhook")
+	log.Printf("  GET  /admin/webhooks/unprocessed - List unprocessed webhooks")
+	log.Printf("  POST /admin/webhooks/replay - Replay unprocessed webhooks")
+	log.Printf("  POST /admin/reconciliation/reports - Reconcile settlement report")
+	log.Printf("  GET  /admin/reconciliation/discrepancies - List settlement discrepancies")
+	log.Printf("  GET  /payments/{id}/risk-assessments - List payment risk assessments")
+	log.Printf("  GET  /admin/risk/assessments - List risk assessments")
+	log.Printf("  GET  /admin/risk/assessments/{id} - Get risk assessment")
+	log.Printf("  POST /admin/risk/assessments/{id}/review - Approve or reject held payment")
+	log.Printf("  POST /gift-cards/lookup - Look up gift card balance by code")
+	log.Printf("  GET  /gift-cards/{id}/ledger - Gift card ledger")
+	log.Printf("  GET  /wallets/{userId} - Get wallet")
+	log.Printf("  GET  /wallets/{userId}/ledger - Wallet ledger")
+	log.Printf("  POST /admin/gift-cards - Issue gift card")
+	log.Printf("  POST /admin/gift-cards/{id}/disable - Disable gift card")
+	log.Printf("  POST /admin/wallets/{userId}/adjustments - Adjust wallet balance")
 
 	log.Fatal(http.ListenAndServe(":"+port, router))
 }
 
+// newPaymentGateway selects the payment gateway named by PAYMENT_GATEWAY
+func newPaymentGateway(port string) gateway.PaymentGateway {
+	switch name := getEnvOrDefault("PAYMENT_GATEWAY", "stripe"); name {
+	case "stripe":
+		stripeSecretKey := os.Getenv("STRIPE_SECRET_KEY")
+		if stripeSecretKey == "" {
+			log.Fatal("STRIPE_SECRET_KEY environment variable is required")
+		}
+
+		stripeWebhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+		if stripeWebhookSecret == "" {
+			log.Fatal("STRIPE_WEBHOOK_SECRET environment variable is required")
+		}
+
+		return gateway.NewStripeGateway(stripeSecretKey, stripeWebhookSecret)
+	case "fake":
+		config := gateway.DefaultFakeGatewayConfig()
+		config.WebhookURL = getEnvOrDefault("FAKE_GATEWAY_WEBHOOK_URL", "http://localhost:"+port+"/webhooks/stripe")
+		config.WebhookSecret = getEnvOrDefault("STRIPE_WEBHOOK_SECRET", config.WebhookSecret)
+		if delay, err := time.ParseDuration(os.Getenv("FAKE_GATEWAY_SETTLEMENT_DELAY")); err == nil {
+			config.SettlementDelay = delay
+		}
+
+		log.Printf("Using fake payment gateway, webhooks are sent to %s", config.WebhookURL)
+		return gateway.NewFakeGateway(config)
+	default:
+		log.Fatalf("Unknown PAYMENT_GATEWAY %q, expected stripe or fake", name)
+		return nil
+	}
+}
+
+// voidExpiredAuthorizations periodically voids authorization holds past their expiry
+func voidExpiredAuthorizations(ctx context.Context, paymentService service.PaymentService) {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if voided, err := paymentService.VoidExpiredAuthorizations(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to void expired authorizations", err)
+		} else if voided > 0 {
+			utils.Logger.Info(ctx, "Voided expired authorizations", map[string]interface{}{"count": voided})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverPendingCaptures settles stale pending captures every few minutes
+func recoverPendingCaptures(ctx context.Context, paymentService service.PaymentService) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if recovered, err := paymentService.RecoverPendingCaptures(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to recover pending captures", err)
+		} else if recovered > 0 {
+			utils.Logger.Info(ctx, "Recovered pending captures", map[string]interface{}{"count": recovered})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileSettlementReports reconciles the CSV reports dropped into dir, once
+// per interval. Reconciled reports are renamed so they are not picked up again.
+func reconcileSettlementReports(ctx context.Context, reconciliationService service.ReconciliationService, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+		if err != nil {
+			utils.Logger.Error(ctx, "Failed to list settlement reports", err)
+		}
+		for _, path := range paths {
+			if _, err := reconciliationService.ReconcileReport(ctx, settlement.NewCSVFileSource(path)); err != nil {
+				utils.Logger.Error(ctx, "Failed to reconcile settlement report", err, map[string]interface{}{"path": path})
+				continue
+			}
+			if err := os.Rename(path, path+".reconciled"); err != nil {
+				utils.Logger.Error(ctx, "Failed to mark settlement report reconciled", err, map[string]interface{}{"path": path})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Helper functions for environment variables
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
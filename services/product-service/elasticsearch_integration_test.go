@@ -42,17 +42,19 @@ func TestElasticsearchIntegration(t *testing.T) {
 	// Initialize repositories
 	productRepo := repository.NewProductRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
+	variantRepo := repository.NewVariantRepository(db)
 
 	// Initialize mock Elasticsearch client for testing
 	searchService := search.NewMockElasticsearchClient()
 	analyticsService := search.NewAnalyticsService(db)
 
 	// Initialize services
-	productService := service.NewProductService(productRepo, categoryRepo, searchService, analyticsService)
+	productService := service.NewProductService(productRepo, categoryRepo, variantRepo, searchService, analyticsService)
 	categoryService := service.NewCategoryService(categoryRepo)
+	reservationService := service.NewReservationService(repository.NewReservationRepository(db), service.DefaultReservationConfig())
 
 	// Initialize handlers
-	productHandler := handlers.NewProductHandler(productService, categoryService)
+	productHandler := handlers.NewProductHandler(productService, categoryService, reservationService)
 
 	// Create test router
 	router := mux.NewRouter()
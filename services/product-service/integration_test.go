@@ -142,6 +142,7 @@ func createTestTables(db *sql.DB) error {
 		meta_description TEXT,
 		meta_keywords TEXT,
 		featured BOOLEAN DEFAULT FALSE,
+		tax_class VARCHAR(50) NOT NULL DEFAULT 'standard',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
@@ -151,15 +152,46 @@ func createTestTables(db *sql.DB) error {
 		id VARCHAR(36) PRIMARY KEY,
 		product_id VARCHAR(36) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
 		variant_id VARCHAR(36),
+		warehouse_id VARCHAR(36),
 		movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN ('in', 'out', 'adjustment', 'reserved', 'released')),
 		quantity INTEGER NOT NULL,
 		reference_type VARCHAR(50),
 		reference_id VARCHAR(36),
 		reason TEXT,
-		created_by VARCHAR(36),
+		created_by VARCHAR(100),
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	-- Domain events written with stock changes
+	CREATE TABLE IF NOT EXISTS event_outbox (
+		id VARCHAR(36) PRIMARY KEY,
+		event_type VARCHAR(100) NOT NULL,
+		aggregate_id VARCHAR(36) NOT NULL,
+		payload JSONB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		locked_until TIMESTAMP,
+		published_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Stock only changes through inventory movements
+	CREATE OR REPLACE FUNCTION update_product_stock()
+	RETURNS TRIGGER AS $$
+	BEGIN
+		IF NEW.movement_type = 'in' THEN
+			UPDATE products SET stock = stock + NEW.quantity WHERE id = NEW.product_id;
+		ELSIF NEW.movement_type = 'out' THEN
+			UPDATE products SET stock = stock - NEW.quantity WHERE id = NEW.product_id;
+		ELSIF NEW.movement_type = 'reserved' THEN
+			UPDATE products SET reserved_stock = reserved_stock + NEW.quantity WHERE id = NEW.product_id;
+		ELSIF NEW.movement_type = 'released' THEN
+			UPDATE products SET reserved_stock = reserved_stock - NEW.quantity WHERE id = NEW.product_id;
+		END IF;
+		RETURN NEW;
+	END;
+	$$ language 'plpgsql';
+
 	-- Create indexes
 	CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
 	CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path);
@@ -174,6 +206,9 @@ func createTestTables(db *sql.DB) error {
 
 	CREATE TRIGGER update_products_updated_at BEFORE UPDATE ON products
 		FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+	CREATE TRIGGER inventory_movement_trigger AFTER INSERT ON inventory_movements
+		FOR EACH ROW EXECUTE FUNCTION update_product_stock();
 	`
 	
 	if _, err := db.Exec(schemaSQL); err != nil {
@@ -185,6 +220,7 @@ func createTestTables(db *sql.DB) error {
 
 func cleanupTestDatabase(db *sql.DB) {
 	// Clean up test data
+	db.Exec("DELETE FROM event_outbox")
 	db.Exec("DELETE FROM inventory_movements")
 	db.Exec("DELETE FROM products")
 	db.Exec("DELETE FROM categories")
@@ -196,11 +232,12 @@ func setupTestRouter() *mux.Router {
 	categoryRepo := repository.NewCategoryRepository(testDB)
 
 	// Initialize services
-	productService := service.NewProductService(productRepo, categoryRepo, nil, nil)
+	productService := service.NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	categoryService := service.NewCategoryService(categoryRepo)
+	reservationService := service.NewReservationService(repository.NewReservationRepository(testDB), service.DefaultReservationConfig())
 
 	// Initialize handlers
-	productHandler := handlers.NewProductHandler(productService, categoryService)
+	productHandler := handlers.NewProductHandler(productService, categoryService, reservationService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
 	// Create router
@@ -215,6 +252,7 @@ func setupTestRouter() *mux.Router {
 	productRoutes.HandleFunc("/{id}", productHandler.UpdateProduct).Methods("PUT")
 	productRoutes.HandleFunc("/{id}", productHandler.DeleteProduct).Methods("DELETE")
 	productRoutes.HandleFunc("/{id}/reserve-stock", productHandler.ReserveStock).Methods("POST")
+	productRoutes.HandleFunc("/{id}/stock", productHandler.UpdateStock).Methods("PUT")
 
 	// Category routes
 	categoryRoutes := router.PathPrefix("/categories").Subrouter()
@@ -516,4 +554,85 @@ func TestProductIntegration_StockReservation(t *testing.T) {
 	if response["status"] != "success" {
 		t.Errorf("Expected status success, got %s", response["status"])
 	}
-}
\ No newline at end of file
+}
+
+func TestProductIntegration_StockUpdateMovesStock(t *testing.T) {
+	router := setupTestRouter()
+	
+	// Clean up before test
+	cleanupTestDatabase(testDB)
+	
+	// Create a sold-out product
+	createReq := service.CreateProductRequest{
+		SKU:    "TEST-STOCK-002",
+		Name:   "Sold Out Product",
+		Price:  decimal.NewFromFloat(19.99),
+		Status: "active",
+	}
+	
+	reqBody, _ := json.Marshal(createReq)
+	req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	var createdProduct models.Product
+	json.Unmarshal(w.Body.Bytes(), &createdProduct)
+	
+	// Count the product back to 7 units, then down to 4
+	for _, quantity := range []int{7, 4} {
+		reqBody, _ = json.Marshal(service.StockUpdateRequest{Quantity: quantity})
+		req = httptest.NewRequest("PUT", "/products/"+createdProduct.ID+"/stock", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+	
+	var stock int
+	if err := testDB.QueryRow("SELECT stock FROM products WHERE id = $1", createdProduct.ID).Scan(&stock); err != nil {
+		t.Fatalf("Failed to read stock: %v", err)
+	}
+	if stock != 4 {
+		t.Errorf("Expected stock 4, got %d", stock)
+	}
+	
+	// The restock is published as the item coming back in stock
+	rows, err := testDB.Query("SELECT payload FROM event_outbox WHERE aggregate_id = $1 ORDER BY created_at", createdProduct.ID)
+	if err != nil {
+		t.Fatalf("Failed to read outbox: %v", err)
+	}
+	defer rows.Close()
+	
+	var updates []models.InventoryUpdatedData
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			t.Fatalf("Failed to scan outbox: %v", err)
+		}
+		var event models.DomainEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		var data models.InventoryUpdatedData
+		if err := event.UnmarshalData(&data); err != nil {
+			t.Fatalf("Failed to decode event data: %v", err)
+		}
+		updates = append(updates, data)
+	}
+	
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 inventory updates, got %+v", updates)
+	}
+	if updates[0].PreviousAvailable != 0 || updates[0].NewAvailable != 7 || updates[0].Reason != "adjustment" {
+		t.Errorf("Expected the first update to restock to 7, got %+v", updates[0])
+	}
+	if updates[1].PreviousStock != 7 || updates[1].NewStock != 4 {
+		t.Errorf("Expected the second update to count stock down to 4, got %+v", updates[1])
+	}
+}
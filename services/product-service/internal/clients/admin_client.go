@@ -0,0 +1,28 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// AdminClient raises system alerts in admin-service
+type AdminClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAdminClient creates a client for the admin-service alert API
+func NewAdminClient(baseURL string) *AdminClient {
+	return &AdminClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// CreateSystemAlert implements service.AlertSender via POST /admin/alerts
+func (c *AdminClient) CreateSystemAlert(ctx context.Context, req *models.CreateSystemAlertRequest) error {
+	return postJSON(ctx, c.httpClient, c.baseURL+"/admin/alerts", req)
+}
@@ -0,0 +1,28 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// NotificationClient sends customer notifications through notification-service
+type NotificationClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewNotificationClient creates a client for the notification-service API
+func NewNotificationClient(baseURL string) *NotificationClient {
+	return &NotificationClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// SendNotification implements service.Notifier via POST /notifications
+func (c *NotificationClient) SendNotification(ctx context.Context, req *models.NotificationRequest) error {
+	return postJSON(ctx, c.httpClient, c.baseURL+"/notifications", req)
+}
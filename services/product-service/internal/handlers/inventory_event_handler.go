@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/shopsphere/product-service/internal/service"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+)
+
+// InventoryEventHandler raises low-stock alerts and sends back-in-stock
+// notifications as stock moves
+type InventoryEventHandler struct {
+	service *service.StockAlertService
+}
+
+// NewInventoryEventHandler creates a new inventory event handler
+func NewInventoryEventHandler(service *service.StockAlertService) *InventoryEventHandler {
+	return &InventoryEventHandler{
+		service: service,
+	}
+}
+
+// RegisterHandlers registers the inventory event handlers with a consumer
+func (h *InventoryEventHandler) RegisterHandlers(consumer *events.Consumer) {
+	events.Handle(consumer, models.EventInventoryUpdated, h.HandleInventoryUpdated)
+}
+
+// HandleInventoryUpdated checks the moved stock against reorder thresholds
+// and back-in-stock subscriptions
+func (h *InventoryEventHandler) HandleInventoryUpdated(ctx context.Context, event *models.DomainEvent, data models.InventoryUpdatedData) error {
+	return h.service.HandleInventoryUpdated(ctx, event.ID, &data)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shopsphere/product-service/internal/service"
+)
+
+// InventoryHandler handles HTTP requests for the inventory ledger
+type InventoryHandler struct {
+	inventoryService *service.InventoryService
+}
+
+// NewInventoryHandler creates a new inventory handler
+func NewInventoryHandler(inventoryService *service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		inventoryService: inventoryService,
+	}
+}
+
+// ListMovements handles GET /inventory/movements
+func (h *InventoryHandler) ListMovements(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req := service.GetInventoryMovementsRequest{
+		ProductID:     query.Get("product_id"),
+		VariantID:     query.Get("variant_id"),
+		MovementType:  query.Get("movement_type"),
+		ReferenceType: query.Get("reference_type"),
+		ReferenceID:   query.Get("reference_id"),
+		CreatedBy:     query.Get("created_by"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if val, err := strconv.Atoi(limit); err == nil {
+			req.Limit = val
+		}
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		if val, err := strconv.Atoi(offset); err == nil {
+			req.Offset = val
+		}
+	}
+
+	// Dropping a bad date bound would widen the result, so it is rejected
+	var err error
+	if req.From, err = parseTimeParam(query.Get("from")); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid from date", "use RFC 3339, e.g. 2024-01-31T00:00:00Z")
+		return
+	}
+	if req.To, err = parseTimeParam(query.Get("to")); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid to date", "use RFC 3339, e.g. 2024-01-31T00:00:00Z")
+		return
+	}
+
+	response, err := h.inventoryService.ListMovements(r.Context(), req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// AuditStock handles GET /inventory/audit
+func (h *InventoryHandler) AuditStock(w http.ResponseWriter, r *http.Request) {
+	report, err := h.inventoryService.AuditStock(r.Context())
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, report)
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// handleServiceError handles service layer errors
+func (h *InventoryHandler) handleServiceError(w http.ResponseWriter, err error) {
+	// Create a temporary ProductHandler to reuse the error handling logic
+	ph := &ProductHandler{}
+	ph.handleServiceError(w, err)
+}
+
+// writeJSONResponse writes a JSON response
+func (h *InventoryHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	// Create a temporary ProductHandler to reuse the JSON response logic
+	ph := &ProductHandler{}
+	ph.writeJSONResponse(w, statusCode, data)
+}
+
+// writeErrorResponse writes an error response
+func (h *InventoryHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
+	// Create a temporary ProductHandler to reuse the error response logic
+	ph := &ProductHandler{}
+	ph.writeErrorResponse(w, statusCode, code, message, details)
+}
@@ -13,15 +13,17 @@ import (
 
 // ProductHandler handles HTTP requests for products
 type ProductHandler struct {
-	productService  *service.ProductService
-	categoryService *service.CategoryService
+	productService     *service.ProductService
+	categoryService    *service.CategoryService
+	reservationService *service.ReservationService
 }
 
 // NewProductHandler creates a new product handler
-func NewProductHandler(productService *service.ProductService, categoryService *service.CategoryService) *ProductHandler {
+func NewProductHandler(productService *service.ProductService, categoryService *service.CategoryService, reservationService *service.ReservationService) *ProductHandler {
 	return &ProductHandler{
-		productService:  productService,
-		categoryService: categoryService,
+		productService:     productService,
+		categoryService:    categoryService,
+		reservationService: reservationService,
 	}
 }
 
@@ -143,7 +145,19 @@ func (h *ProductHandler) ReserveStock(w http.ResponseWriter, r *http.Request) {
 	
 	req.ProductID = productID
 	
-	err := h.productService.ReserveStock(r.Context(), req.ProductID, req.Quantity)
+	// Requests for an order or cart are held as reservations
+	if req.ReferenceID != "" {
+		reservation, err := h.reservationService.Reserve(r.Context(), req)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		
+		h.writeJSONResponse(w, http.StatusOK, reservation)
+		return
+	}
+	
+	err := h.productService.ReserveStock(r.Context(), req.ProductID, req.VariantID, req.Quantity)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
@@ -165,7 +179,19 @@ func (h *ProductHandler) ReleaseStock(w http.ResponseWriter, r *http.Request) {
 	
 	req.ProductID = productID
 	
-	err := h.productService.ReleaseStock(r.Context(), req.ProductID, req.Quantity)
+	// Requests for an order or cart are held as reservations
+	if req.ReferenceID != "" {
+		reservation, err := h.reservationService.Release(r.Context(), req)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		
+		h.writeJSONResponse(w, http.StatusOK, reservation)
+		return
+	}
+	
+	err := h.productService.ReleaseStock(r.Context(), req.ProductID, req.VariantID, req.Quantity)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
@@ -174,6 +200,43 @@ func (h *ProductHandler) ReleaseStock(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
+// CommitStock handles POST /products/{id}/commit-stock
+func (h *ProductHandler) CommitStock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	
+	var req service.StockReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+	
+	req.ProductID = vars["id"]
+	
+	reservation, err := h.reservationService.Commit(r.Context(), req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	
+	h.writeJSONResponse(w, http.StatusOK, reservation)
+}
+
+// ListReservations handles GET /reservations/{referenceType}/{referenceId}
+func (h *ProductHandler) ListReservations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	
+	reservations, err := h.reservationService.ListReservations(r.Context(), vars["referenceType"], vars["referenceId"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"reservations": reservations,
+		"count":        len(reservations),
+	})
+}
+
 // UpdateStock handles PUT /products/{id}/stock
 func (h *ProductHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/product-service/internal/service"
+	"github.com/shopsphere/shared/utils"
+)
+
+// StockAlertHandler handles HTTP requests for reorder thresholds and
+// back-in-stock subscriptions
+type StockAlertHandler struct {
+	stockAlertService *service.StockAlertService
+}
+
+// NewStockAlertHandler creates a new stock alert handler
+func NewStockAlertHandler(stockAlertService *service.StockAlertService) *StockAlertHandler {
+	return &StockAlertHandler{
+		stockAlertService: stockAlertService,
+	}
+}
+
+// ListThresholds handles GET /products/{id}/stock-thresholds
+func (h *StockAlertHandler) ListThresholds(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	thresholds, err := h.stockAlertService.ListThresholds(r.Context(), vars["id"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"thresholds": thresholds,
+		"count":      len(thresholds),
+	})
+}
+
+// SetThreshold handles PUT /products/{id}/stock-thresholds
+func (h *StockAlertHandler) SetThreshold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.SetStockThresholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	threshold, err := h.stockAlertService.SetThreshold(r.Context(), vars["id"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, threshold)
+}
+
+// DeleteThreshold handles DELETE /products/{id}/stock-thresholds?variant_id=
+func (h *StockAlertHandler) DeleteThreshold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.stockAlertService.DeleteThreshold(r.Context(), vars["id"], r.URL.Query().Get("variant_id")); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Stock threshold deleted successfully",
+	})
+}
+
+// Subscribe handles POST /products/{id}/back-in-stock
+func (h *StockAlertHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID := utils.GetUserID(r.Context())
+	if userID == "" {
+		h.writeErrorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required", "")
+		return
+	}
+
+	var req service.BackInStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+	req.UserID = userID
+
+	subscription, err := h.stockAlertService.Subscribe(r.Context(), vars["id"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, subscription)
+}
+
+// ListSubscriptions handles GET /back-in-stock-subscriptions for the calling user
+func (h *StockAlertHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.stockAlertService.ListSubscriptions(r.Context(), utils.GetUserID(r.Context()))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// Unsubscribe handles DELETE /back-in-stock-subscriptions/{id}
+func (h *StockAlertHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.stockAlertService.Unsubscribe(r.Context(), vars["id"], utils.GetUserID(r.Context())); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Unsubscribed successfully",
+	})
+}
+
+// handleServiceError handles service layer errors
+func (h *StockAlertHandler) handleServiceError(w http.ResponseWriter, err error) {
+	// Create a temporary ProductHandler to reuse the error handling logic
+	ph := &ProductHandler{}
+	ph.handleServiceError(w, err)
+}
+
+// writeJSONResponse writes a JSON response
+func (h *StockAlertHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	// Create a temporary ProductHandler to reuse the JSON response logic
+	ph := &ProductHandler{}
+	ph.writeJSONResponse(w, statusCode, data)
+}
+
+// writeErrorResponse writes an error response
+func (h *StockAlertHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
+	// Create a temporary ProductHandler to reuse the error response logic
+	ph := &ProductHandler{}
+	ph.writeErrorResponse(w, statusCode, code, message, details)
+}
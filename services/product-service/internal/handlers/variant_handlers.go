@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/product-service/internal/service"
+)
+
+// VariantHandler handles HTTP requests for product variants
+type VariantHandler struct {
+	variantService *service.VariantService
+}
+
+// NewVariantHandler creates a new variant handler
+func NewVariantHandler(variantService *service.VariantService) *VariantHandler {
+	return &VariantHandler{
+		variantService: variantService,
+	}
+}
+
+// ListVariants handles GET /products/{id}/variants
+func (h *VariantHandler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	variants, err := h.variantService.ListVariants(r.Context(), vars["id"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"variants": variants,
+		"count":    len(variants),
+	})
+}
+
+// CreateVariant handles POST /products/{id}/variants
+func (h *VariantHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.CreateVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	variant, err := h.variantService.CreateVariant(r.Context(), vars["id"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, variant)
+}
+
+// GenerateVariants handles POST /products/{id}/variants/generate
+func (h *VariantHandler) GenerateVariants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.GenerateVariantsRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+			return
+		}
+	}
+
+	variants, err := h.variantService.GenerateVariants(r.Context(), vars["id"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"variants": variants,
+		"count":    len(variants),
+	})
+}
+
+// GetVariant handles GET /products/{id}/variants/{variantId}
+func (h *VariantHandler) GetVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	variant, err := h.variantService.GetVariant(r.Context(), vars["id"], vars["variantId"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, variant)
+}
+
+// UpdateVariant handles PUT /products/{id}/variants/{variantId}
+func (h *VariantHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.UpdateVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	variant, err := h.variantService.UpdateVariant(r.Context(), vars["id"], vars["variantId"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, variant)
+}
+
+// DeleteVariant handles DELETE /products/{id}/variants/{variantId}
+func (h *VariantHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.variantService.DeleteVariant(r.Context(), vars["id"], vars["variantId"]); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleServiceError handles service layer errors
+func (h *VariantHandler) handleServiceError(w http.ResponseWriter, err error) {
+	// Create a temporary ProductHandler to reuse the error handling logic
+	ph := &ProductHandler{}
+	ph.handleServiceError(w, err)
+}
+
+// writeJSONResponse writes a JSON response
+func (h *VariantHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	// Create a temporary ProductHandler to reuse the JSON response logic
+	ph := &ProductHandler{}
+	ph.writeJSONResponse(w, statusCode, data)
+}
+
+// writeErrorResponse writes an error response
+func (h *VariantHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
+	// Create a temporary ProductHandler to reuse the error response logic
+	ph := &ProductHandler{}
+	ph.writeErrorResponse(w, statusCode, code, message, details)
+}
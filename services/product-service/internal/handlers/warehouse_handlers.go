@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shopsphere/product-service/internal/service"
+)
+
+// WarehouseHandler handles HTTP requests for warehouses, their stock and
+// the allocation of reserved stock to them
+type WarehouseHandler struct {
+	warehouseService *service.WarehouseService
+}
+
+// NewWarehouseHandler creates a new warehouse handler
+func NewWarehouseHandler(warehouseService *service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{
+		warehouseService: warehouseService,
+	}
+}
+
+// ListWarehouses handles GET /warehouses
+func (h *WarehouseHandler) ListWarehouses(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("active") == "true"
+
+	warehouses, err := h.warehouseService.ListWarehouses(r.Context(), activeOnly)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"warehouses": warehouses,
+		"count":      len(warehouses),
+	})
+}
+
+// CreateWarehouse handles POST /warehouses
+func (h *WarehouseHandler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateWarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	warehouse, err := h.warehouseService.CreateWarehouse(r.Context(), req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, warehouse)
+}
+
+// GetWarehouse handles GET /warehouses/{id}
+func (h *WarehouseHandler) GetWarehouse(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	warehouse, err := h.warehouseService.GetWarehouse(r.Context(), vars["id"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, warehouse)
+}
+
+// UpdateWarehouse handles PUT /warehouses/{id}
+func (h *WarehouseHandler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.UpdateWarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	warehouse, err := h.warehouseService.UpdateWarehouse(r.Context(), vars["id"], req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, warehouse)
+}
+
+// ListWarehouseStock handles GET /warehouses/{id}/stock
+func (h *WarehouseHandler) ListWarehouseStock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	levels, err := h.warehouseService.ListWarehouseStock(r.Context(), vars["id"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"stock": levels,
+		"count": len(levels),
+	})
+}
+
+// MoveWarehouseStock handles POST /warehouses/{id}/stock
+func (h *WarehouseHandler) MoveWarehouseStock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req service.WarehouseStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	if err := h.warehouseService.MoveStock(r.Context(), vars["id"], req); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Warehouse stock updated successfully",
+	})
+}
+
+// ListProductStock handles GET /products/{id}/warehouse-stock
+func (h *WarehouseHandler) ListProductStock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	levels, err := h.warehouseService.ListProductStock(r.Context(), vars["id"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"stock": levels,
+		"count": len(levels),
+	})
+}
+
+// TransferStock handles POST /inventory/transfers
+func (h *WarehouseHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+	var req service.TransferStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	transfer, err := h.warehouseService.TransferStock(r.Context(), req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, transfer)
+}
+
+// AllocateStock handles POST /inventory/allocations
+func (h *WarehouseHandler) AllocateStock(w http.ResponseWriter, r *http.Request) {
+	var req service.AllocateStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", "")
+		return
+	}
+
+	plan, err := h.warehouseService.AllocateStock(r.Context(), req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, plan)
+}
+
+// ListAllocations handles GET /inventory/allocations/{referenceType}/{referenceId}
+func (h *WarehouseHandler) ListAllocations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	allocations, err := h.warehouseService.ListAllocations(r.Context(), vars["referenceType"], vars["referenceId"])
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"allocations": allocations,
+		"count":       len(allocations),
+	})
+}
+
+// handleServiceError handles service layer errors
+func (h *WarehouseHandler) handleServiceError(w http.ResponseWriter, err error) {
+	// Create a temporary ProductHandler to reuse the error handling logic
+	ph := &ProductHandler{}
+	ph.handleServiceError(w, err)
+}
+
+// writeJSONResponse writes a JSON response
+func (h *WarehouseHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	// Create a temporary ProductHandler to reuse the JSON response logic
+	ph := &ProductHandler{}
+	ph.writeJSONResponse(w, statusCode, data)
+}
+
+// writeErrorResponse writes an error response
+func (h *WarehouseHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
+	// Create a temporary ProductHandler to reuse the error response logic
+	ph := &ProductHandler{}
+	ph.writeErrorResponse(w, statusCode, code, message, details)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/shopsphere/shared/models"
 )
@@ -18,14 +19,137 @@ type ProductRepository interface {
 	
 	// Inventory operations
 	UpdateStock(ctx context.Context, productID string, quantity int) error
-	ReserveStock(ctx context.Context, productID string, quantity int) error
-	ReleaseStock(ctx context.Context, productID string, quantity int) error
+	ReserveStock(ctx context.Context, productID, variantID string, quantity int) error
+	ReleaseStock(ctx context.Context, productID, variantID string, quantity int) error
 	GetAvailableStock(ctx context.Context, productID string) (int, error)
+	// SetVariantStock sets a variant's stock, recording the difference from
+	// its locked stock level as a movement. Stock may not go below the
+	// variant's reserved stock.
+	SetVariantStock(ctx context.Context, productID, variantID string, stock int, reason string) error
 	
 	// Bulk operations
 	BulkUpdateStock(ctx context.Context, updates []StockUpdate) error
 }
 
+// VariantRepository defines the interface for product variant data operations.
+// Variant stock only changes through inventory movements, so Update leaves it
+// alone and Create records the initial stock as a movement.
+type VariantRepository interface {
+	Create(ctx context.Context, variant *models.ProductVariant) error
+	GetByID(ctx context.Context, id string) (*models.ProductVariant, error)
+	Update(ctx context.Context, variant *models.ProductVariant) error
+	Delete(ctx context.Context, id string) error
+	ListByProduct(ctx context.Context, productID string) ([]*models.ProductVariant, error)
+	ListByProducts(ctx context.Context, productIDs []string) (map[string][]*models.ProductVariant, error)
+}
+
+// ReservationRepository defines the interface for stock reservation data
+// operations. Each change records the matching reserved, released or out
+// inventory movement in the same transaction.
+type ReservationRepository interface {
+	// Reserve sets the quantity held for the reservation's key, reserving or
+	// releasing the difference, and moves its expiry
+	Reserve(ctx context.Context, reservation *models.StockReservation) error
+	// Release releases an active reservation. It returns nil when the key
+	// has no reservation.
+	Release(ctx context.Context, key ReservationKey) (*models.StockReservation, error)
+	// Expire releases an active reservation that has expired by now
+	Expire(ctx context.Context, key ReservationKey, now time.Time) (*models.StockReservation, error)
+	// Commit turns quantity reserved units, or all of them when quantity is
+	// zero, into sold units
+	Commit(ctx context.Context, key ReservationKey, quantity int) (*models.StockReservation, error)
+	ListByReference(ctx context.Context, referenceType, referenceID string) ([]*models.StockReservation, error)
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]*models.StockReservation, error)
+}
+
+// ReservationKey identifies the reservation of a product or variant for an order or cart
+type ReservationKey struct {
+	ReferenceType string
+	ReferenceID   string
+	ProductID     string
+	VariantID     string
+}
+
+// WarehouseRepository defines the interface for warehouses, the stock kept at
+// them and the allocation of reserved stock to them
+type WarehouseRepository interface {
+	Create(ctx context.Context, warehouse *models.Warehouse) error
+	GetByID(ctx context.Context, id string) (*models.Warehouse, error)
+	Update(ctx context.Context, warehouse *models.Warehouse) error
+	List(ctx context.Context, activeOnly bool) ([]*models.Warehouse, error)
+	ListStock(ctx context.Context, filter WarehouseStockFilter) ([]*models.WarehouseStock, error)
+	// MoveStock records an in or out movement of stock at a warehouse
+	MoveStock(ctx context.Context, warehouseID string, update StockUpdate) error
+	// Transfer moves stock between warehouses, or assigns stock that is not
+	// kept at a warehouse when the transfer has no source
+	Transfer(ctx context.Context, transfer *models.StockTransfer) error
+	// Allocate replaces the allocations of the given active reservations
+	Allocate(ctx context.Context, reservations []*models.StockReservation, allocations []*models.StockAllocation) error
+	ListAllocations(ctx context.Context, reservationIDs []string) ([]*models.StockAllocation, error)
+}
+
+// WarehouseStockFilter represents filtering options for warehouse stock
+type WarehouseStockFilter struct {
+	WarehouseID string
+	ProductIDs  []string
+	// ExcludeReservationIDs are reservations whose allocations do not count
+	// as allocated stock, so they can be allocated again
+	ExcludeReservationIDs []string
+}
+
+// StockAlertRepository defines the interface for reorder thresholds and
+// back-in-stock subscriptions
+type StockAlertRepository interface {
+	// SetThreshold creates the threshold of a product or variant, or replaces
+	// the one it has
+	SetThreshold(ctx context.Context, threshold *models.StockThreshold) error
+	// GetThreshold returns the threshold of a product or variant. It returns
+	// nil when the item has none.
+	GetThreshold(ctx context.Context, productID, variantID string) (*models.StockThreshold, error)
+	ListThresholds(ctx context.Context, productID string) ([]*models.StockThreshold, error)
+	DeleteThreshold(ctx context.Context, productID, variantID string) error
+	Subscribe(ctx context.Context, subscription *models.BackInStockSubscription) error
+	GetSubscription(ctx context.Context, id string) (*models.BackInStockSubscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]*models.BackInStockSubscription, error)
+	// Unsubscribe deletes a subscription that has not been notified yet
+	Unsubscribe(ctx context.Context, id string) error
+	// ClaimSubscriptions marks the pending subscriptions of a product or
+	// variant as notified and returns them, so concurrent claims never
+	// return the same subscription
+	ClaimSubscriptions(ctx context.Context, productID, variantID string) ([]*models.BackInStockSubscription, error)
+	// UnclaimSubscription returns a claimed subscription to pending after
+	// its notification could not be sent
+	UnclaimSubscription(ctx context.Context, id string) error
+	// RecordAlert records that the inventory event raised the low-stock alert
+	// of a product or variant. It returns false when the alert was already
+	// recorded, so an event handled again never raises it twice.
+	RecordAlert(ctx context.Context, eventID, productID, variantID string) (bool, error)
+	// ForgetAlert drops a recorded alert that could not be raised
+	ForgetAlert(ctx context.Context, eventID, productID, variantID string) error
+}
+
+// InventoryRepository defines the interface for reading the inventory ledger
+type InventoryRepository interface {
+	ListMovements(ctx context.Context, filter MovementFilter) ([]*models.InventoryMovement, int, error)
+	// AuditStock recomputes the stock of every product and variant from the
+	// ledger. It returns the ones that drifted and how many were checked.
+	AuditStock(ctx context.Context) ([]*models.StockDrift, int, error)
+}
+
+// MovementFilter represents filtering options for inventory movements
+type MovementFilter struct {
+	ProductID     string
+	VariantID     string
+	MovementType  string
+	ReferenceType string
+	ReferenceID   string
+	CreatedBy     string
+	From          *time.Time // inclusive
+	To            *time.Time // exclusive
+	Limit         int
+	Offset        int
+}
+
 // CategoryRepository defines the interface for category data operations
 type CategoryRepository interface {
 	Create(ctx context.Context, category *models.Category) error
@@ -63,8 +187,11 @@ type CategoryFilter struct {
 
 // StockUpdate represents a stock update operation
 type StockUpdate struct {
-	ProductID string
-	Quantity  int
-	Type      string // "in", "out", "adjustment"
-	Reason    string
+	ProductID     string
+	VariantID     string // optional; the movement applies to the variant and its product
+	Quantity      int
+	Type          string // "in", "out", or "adjustment" to set stock to Quantity
+	Reason        string
+	ReferenceType string // optional; recorded on the movement with ReferenceID
+	ReferenceID   string
 }
\ No newline at end of file
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// ledgerStock and ledgerReservedStock recompute stock the way the inventory
+// movement trigger applies it; adjustments do not move stock
+const (
+	ledgerStock         = `COALESCE(SUM(CASE m.movement_type WHEN 'in' THEN m.quantity WHEN 'out' THEN -m.quantity ELSE 0 END), 0)`
+	ledgerReservedStock = `COALESCE(SUM(CASE m.movement_type WHEN 'reserved' THEN m.quantity WHEN 'released' THEN -m.quantity ELSE 0 END), 0)`
+)
+
+type inventoryRepository struct {
+	db *sql.DB
+}
+
+// NewInventoryRepository creates a new inventory ledger repository
+func NewInventoryRepository(db *sql.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+// ListMovements retrieves inventory movements, newest first
+func (r *inventoryRepository) ListMovements(ctx context.Context, filter MovementFilter) ([]*models.InventoryMovement, int, error) {
+	var conditions []string
+	var args []interface{}
+	argIndex := 1
+
+	addCondition := func(column string, value interface{}) {
+		conditions = append(conditions, fmt.Sprintf("%s $%d", column, argIndex))
+		args = append(args, value)
+		argIndex++
+	}
+
+	if filter.ProductID != "" {
+		addCondition("product_id =", filter.ProductID)
+	}
+	if filter.VariantID != "" {
+		addCondition("variant_id =", filter.VariantID)
+	}
+	if filter.MovementType != "" {
+		addCondition("movement_type =", filter.MovementType)
+	}
+	if filter.ReferenceType != "" {
+		addCondition("reference_type =", filter.ReferenceType)
+	}
+	if filter.ReferenceID != "" {
+		addCondition("reference_id =", filter.ReferenceID)
+	}
+	if filter.CreatedBy != "" {
+		addCondition("created_by =", filter.CreatedBy)
+	}
+	if filter.From != nil {
+		addCondition("created_at >=", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at <", *filter.To)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM inventory_movements " + whereClause
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, utils.NewInternalError("failed to count inventory movements", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, product_id, variant_id, movement_type, quantity, reference_type, reference_id,
+			   reason, created_by, created_at
+		FROM inventory_movements %s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d`,
+		whereClause, argIndex, argIndex+1)
+
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, utils.NewInternalError("failed to list inventory movements", err)
+	}
+	defer rows.Close()
+
+	var movements []*models.InventoryMovement
+	for rows.Next() {
+		var movement models.InventoryMovement
+		var variantID, referenceType, referenceID, reason, createdBy sql.NullString
+
+		err := rows.Scan(
+			&movement.ID, &movement.ProductID, &variantID, &movement.MovementType, &movement.Quantity,
+			&referenceType, &referenceID, &reason, &createdBy, &movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, utils.NewInternalError("failed to scan inventory movement", err)
+		}
+
+		movement.VariantID = variantID.String
+		movement.ReferenceType = referenceType.String
+		movement.ReferenceID = referenceID.String
+		movement.Reason = reason.String
+		movement.CreatedBy = createdBy.String
+		movements = append(movements, &movement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, utils.NewInternalError("failed to iterate inventory movements", err)
+	}
+
+	return movements, total, nil
+}
+
+// AuditStock compares the stock of every product and variant with the sum of
+// its movements. Every movement counts towards its product, and movements of
+// a variant count towards the variant too.
+func (r *inventoryRepository) AuditStock(ctx context.Context) ([]*models.StockDrift, int, error) {
+	productQuery := `
+		SELECT p.id, '', p.sku, p.stock, p.reserved_stock, ` + ledgerStock + `, ` + ledgerReservedStock + `
+		FROM products p
+		LEFT JOIN inventory_movements m ON m.product_id = p.id
+		GROUP BY p.id
+		ORDER BY p.sku`
+
+	variantQuery := `
+		SELECT v.product_id, v.id, v.sku, v.stock, v.reserved_stock, ` + ledgerStock + `, ` + ledgerReservedStock + `
+		FROM product_variants v
+		LEFT JOIN inventory_movements m ON m.variant_id = v.id
+		GROUP BY v.id
+		ORDER BY v.sku`
+
+	var drifts []*models.StockDrift
+	checked := 0
+	for _, query := range []string{productQuery, variantQuery} {
+		rows, err := r.db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, 0, utils.NewInternalError("failed to audit stock", err)
+		}
+
+		for rows.Next() {
+			var drift models.StockDrift
+			err := rows.Scan(&drift.ProductID, &drift.VariantID, &drift.SKU, &drift.Stock, &drift.ReservedStock,
+				&drift.LedgerStock, &drift.LedgerReservedStock)
+			if err != nil {
+				rows.Close()
+				return nil, 0, utils.NewInternalError("failed to scan stock audit", err)
+			}
+
+			checked++
+			if drift.HasDrift() {
+				drifts = append(drifts, &drift)
+			}
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, 0, utils.NewInternalError("failed to iterate stock audit", err)
+		}
+	}
+
+	return drifts, checked, nil
+}
@@ -10,20 +10,26 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 )
 
 type productRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *events.PostgresOutbox
 }
 
 // NewProductRepository creates a new product repository
 func NewProductRepository(db *sql.DB) ProductRepository {
-	return &productRepository{db: db}
+	return &productRepository{
+		db:     db,
+		outbox: events.NewPostgresOutbox(db),
+	}
 }
 
-// Create creates a new product
+// Create creates a new product. Its stock is recorded as an inventory movement
+// so that the ledger accounts for it.
 func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
 	if product.ID == "" {
 		product.ID = uuid.New().String()
@@ -36,23 +42,39 @@ func (r *productRepository) Create(ctx context.Context, product *models.Product)
 	if err != nil {
 		return utils.NewInternalError("failed to marshal product attributes", err)
 	}
+
+	pricesJSON, err := marshalPrices(product.Prices)
+	if err != nil {
+		return utils.NewInternalError("failed to marshal product prices", err)
+	}
+
+	optionsJSON, err := marshalOptions(product.Options)
+	if err != nil {
+		return utils.NewInternalError("failed to marshal product options", err)
+	}
+	
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
 	
 	query := `
 		INSERT INTO products (
 			id, sku, name, description, category_id, price, currency, stock, 
 			status, weight, length, width, height, images, attributes, 
-			featured, created_at, updated_at
+			featured, tax_class, prices, options, created_at, updated_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+			$1, $2, $3, $4, $5, $6, $7, 0, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
 		)`
 	
-	_, err = r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, query,
 		product.ID, product.SKU, product.Name, product.Description, product.CategoryID,
-		product.Price, product.Currency, product.Stock, product.Status,
+		product.Price, product.Currency, product.Status,
 		product.Attributes.Weight, product.Attributes.Dimensions.Length,
 		product.Attributes.Dimensions.Width, product.Attributes.Dimensions.Height,
-		pq.Array(product.Images), attributesJSON, false,
-		product.CreatedAt, product.UpdatedAt,
+		pq.Array(product.Images), attributesJSON, false, product.TaxClass, pricesJSON,
+		optionsJSON, product.CreatedAt, product.UpdatedAt,
 	)
 	
 	if err != nil {
@@ -62,29 +84,38 @@ func (r *productRepository) Create(ctx context.Context, product *models.Product)
 		return utils.NewInternalError("failed to create product", err)
 	}
 	
+	if product.Stock > 0 {
+		if err := r.executeStockOperationTx(ctx, tx, product.ID, "", product.Stock, "in", "Initial stock"); err != nil {
+			return err
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+	
 	return nil
 }
 
 // GetByID retrieves a product by ID
 func (r *productRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
 	query := `
-		SELECT id, sku, name, description, category_id, price, currency, stock, 
+		SELECT id, sku, name, description, category_id, price, currency, prices, stock, 
 			   reserved_stock, status, weight, length, width, height, images, 
-			   attributes, featured, created_at, updated_at
+			   attributes, featured, tax_class, options, created_at, updated_at
 		FROM products 
 		WHERE id = $1`
 	
 	product := &models.Product{}
-	var attributesJSON []byte
+	var attributesJSON, pricesJSON, optionsJSON []byte
 	var weight, length, width, height sql.NullFloat64
-	var reservedStock int
 	
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID, &product.SKU, &product.Name, &product.Description,
-		&product.CategoryID, &product.Price, &product.Currency, &product.Stock,
-		&reservedStock, &product.Status, &weight, &length, &width, &height,
-		pq.Array(&product.Images), &attributesJSON, &product.Featured,
-		&product.CreatedAt, &product.UpdatedAt,
+		&product.CategoryID, &product.Price, &product.Currency, &pricesJSON, &product.Stock,
+		&product.ReservedStock, &product.Status, &weight, &length, &width, &height,
+		pq.Array(&product.Images), &attributesJSON, &product.Featured, &product.TaxClass,
+		&optionsJSON, &product.CreatedAt, &product.UpdatedAt,
 	)
 	
 	if err != nil {
@@ -98,6 +129,12 @@ func (r *productRepository) GetByID(ctx context.Context, id string) (*models.Pro
 	if err := json.Unmarshal(attributesJSON, &product.Attributes); err != nil {
 		return nil, utils.NewInternalError("failed to unmarshal product attributes", err)
 	}
+	if err := json.Unmarshal(pricesJSON, &product.Prices); err != nil {
+		return nil, utils.NewInternalError("failed to unmarshal product prices", err)
+	}
+	if err := json.Unmarshal(optionsJSON, &product.Options); err != nil {
+		return nil, utils.NewInternalError("failed to unmarshal product options", err)
+	}
 	
 	// Set dimensions
 	if weight.Valid {
@@ -119,23 +156,22 @@ func (r *productRepository) GetByID(ctx context.Context, id string) (*models.Pro
 // GetBySKU retrieves a product by SKU
 func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
 	query := `
-		SELECT id, sku, name, description, category_id, price, currency, stock, 
+		SELECT id, sku, name, description, category_id, price, currency, prices, stock, 
 			   reserved_stock, status, weight, length, width, height, images, 
-			   attributes, featured, created_at, updated_at
+			   attributes, featured, tax_class, options, created_at, updated_at
 		FROM products 
 		WHERE sku = $1`
 	
 	product := &models.Product{}
-	var attributesJSON []byte
+	var attributesJSON, pricesJSON, optionsJSON []byte
 	var weight, length, width, height sql.NullFloat64
-	var reservedStock int
 	
 	err := r.db.QueryRowContext(ctx, query, sku).Scan(
 		&product.ID, &product.SKU, &product.Name, &product.Description,
-		&product.CategoryID, &product.Price, &product.Currency, &product.Stock,
-		&reservedStock, &product.Status, &weight, &length, &width, &height,
-		pq.Array(&product.Images), &attributesJSON, &product.Featured,
-		&product.CreatedAt, &product.UpdatedAt,
+		&product.CategoryID, &product.Price, &product.Currency, &pricesJSON, &product.Stock,
+		&product.ReservedStock, &product.Status, &weight, &length, &width, &height,
+		pq.Array(&product.Images), &attributesJSON, &product.Featured, &product.TaxClass,
+		&optionsJSON, &product.CreatedAt, &product.UpdatedAt,
 	)
 	
 	if err != nil {
@@ -149,6 +185,12 @@ func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*models.P
 	if err := json.Unmarshal(attributesJSON, &product.Attributes); err != nil {
 		return nil, utils.NewInternalError("failed to unmarshal product attributes", err)
 	}
+	if err := json.Unmarshal(pricesJSON, &product.Prices); err != nil {
+		return nil, utils.NewInternalError("failed to unmarshal product prices", err)
+	}
+	if err := json.Unmarshal(optionsJSON, &product.Options); err != nil {
+		return nil, utils.NewInternalError("failed to unmarshal product options", err)
+	}
 	
 	// Set dimensions
 	if weight.Valid {
@@ -167,7 +209,8 @@ func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*models.P
 	return product, nil
 }
 
-// Update updates a product
+// Update updates a product. A change of stock is recorded as an in or out
+// inventory movement of the difference.
 func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
 	product.UpdatedAt = time.Now()
 	
@@ -175,22 +218,48 @@ func (r *productRepository) Update(ctx context.Context, product *models.Product)
 	if err != nil {
 		return utils.NewInternalError("failed to marshal product attributes", err)
 	}
+
+	pricesJSON, err := marshalPrices(product.Prices)
+	if err != nil {
+		return utils.NewInternalError("failed to marshal product prices", err)
+	}
+
+	optionsJSON, err := marshalOptions(product.Options)
+	if err != nil {
+		return utils.NewInternalError("failed to marshal product options", err)
+	}
+	
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+	
+	var currentStock int
+	err = tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = $1 FOR UPDATE`, product.ID).Scan(&currentStock)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.NewNotFoundError("product")
+		}
+		return utils.NewInternalError("failed to lock product stock", err)
+	}
 	
 	query := `
 		UPDATE products SET 
 			sku = $2, name = $3, description = $4, category_id = $5, price = $6, 
-			currency = $7, stock = $8, status = $9, weight = $10, length = $11, 
-			width = $12, height = $13, images = $14, attributes = $15, 
-			featured = $16, updated_at = $17
+			currency = $7, status = $8, weight = $9, length = $10, 
+			width = $11, height = $12, images = $13, attributes = $14, 
+			featured = $15, tax_class = $16, updated_at = $17, prices = $18,
+			options = $19
 		WHERE id = $1`
 	
-	result, err := r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, query,
 		product.ID, product.SKU, product.Name, product.Description, product.CategoryID,
-		product.Price, product.Currency, product.Stock, product.Status,
+		product.Price, product.Currency, product.Status,
 		product.Attributes.Weight, product.Attributes.Dimensions.Length,
 		product.Attributes.Dimensions.Width, product.Attributes.Dimensions.Height,
-		pq.Array(product.Images), attributesJSON, product.Featured,
-		product.UpdatedAt,
+		pq.Array(product.Images), attributesJSON, product.Featured, product.TaxClass,
+		product.UpdatedAt, pricesJSON, optionsJSON,
 	)
 	
 	if err != nil {
@@ -200,13 +269,18 @@ func (r *productRepository) Update(ctx context.Context, product *models.Product)
 		return utils.NewInternalError("failed to update product", err)
 	}
 	
-	rowsAffected, err := result.RowsAffected()
+	switch delta := product.Stock - currentStock; {
+	case delta > 0:
+		err = r.executeStockOperationTx(ctx, tx, product.ID, "", delta, "in", "Stock changed by product update")
+	case delta < 0:
+		err = r.executeStockOperationTx(ctx, tx, product.ID, "", -delta, "out", "Stock changed by product update")
+	}
 	if err != nil {
-		return utils.NewInternalError("failed to get rows affected", err)
+		return err
 	}
 	
-	if rowsAffected == 0 {
-		return utils.NewNotFoundError("product")
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
 	}
 	
 	return nil
@@ -305,9 +379,9 @@ func (r *productRepository) List(ctx context.Context, filter ProductFilter) ([]*
 	
 	// Build main query
 	query := fmt.Sprintf(`
-		SELECT id, sku, name, description, category_id, price, currency, stock, 
+		SELECT id, sku, name, description, category_id, price, currency, prices, stock, 
 			   reserved_stock, status, weight, length, width, height, images, 
-			   attributes, featured, created_at, updated_at
+			   attributes, featured, tax_class, options, created_at, updated_at
 		FROM products %s
 		ORDER BY %s
 		LIMIT $%d OFFSET $%d`,
@@ -324,16 +398,15 @@ func (r *productRepository) List(ctx context.Context, filter ProductFilter) ([]*
 	var products []*models.Product
 	for rows.Next() {
 		product := &models.Product{}
-		var attributesJSON []byte
+		var attributesJSON, pricesJSON, optionsJSON []byte
 		var weight, length, width, height sql.NullFloat64
-		var reservedStock int
 		
 		err := rows.Scan(
 			&product.ID, &product.SKU, &product.Name, &product.Description,
-			&product.CategoryID, &product.Price, &product.Currency, &product.Stock,
-			&reservedStock, &product.Status, &weight, &length, &width, &height,
-			pq.Array(&product.Images), &attributesJSON, &product.Featured,
-			&product.CreatedAt, &product.UpdatedAt,
+			&product.CategoryID, &product.Price, &product.Currency, &pricesJSON, &product.Stock,
+			&product.ReservedStock, &product.Status, &weight, &length, &width, &height,
+			pq.Array(&product.Images), &attributesJSON, &product.Featured, &product.TaxClass,
+			&optionsJSON, &product.CreatedAt, &product.UpdatedAt,
 		)
 		
 		if err != nil {
@@ -344,6 +417,12 @@ func (r *productRepository) List(ctx context.Context, filter ProductFilter) ([]*
 		if err := json.Unmarshal(attributesJSON, &product.Attributes); err != nil {
 			return nil, 0, utils.NewInternalError("failed to unmarshal product attributes", err)
 		}
+		if err := json.Unmarshal(pricesJSON, &product.Prices); err != nil {
+			return nil, 0, utils.NewInternalError("failed to unmarshal product prices", err)
+		}
+		if err := json.Unmarshal(optionsJSON, &product.Options); err != nil {
+			return nil, 0, utils.NewInternalError("failed to unmarshal product options", err)
+		}
 		
 		// Set dimensions
 		if weight.Valid {
@@ -369,19 +448,20 @@ func (r *productRepository) List(ctx context.Context, filter ProductFilter) ([]*
 	return products, total, nil
 }
 
-// UpdateStock updates product stock
+// UpdateStock sets product stock to a counted quantity
 func (r *productRepository) UpdateStock(ctx context.Context, productID string, quantity int) error {
-	return r.executeStockOperation(ctx, productID, quantity, "adjustment", "Manual stock update")
+	return r.executeStockOperation(ctx, productID, "", quantity, "adjustment", "Manual stock update")
 }
 
-// ReserveStock reserves stock for a product
-func (r *productRepository) ReserveStock(ctx context.Context, productID string, quantity int) error {
-	return r.executeStockOperation(ctx, productID, quantity, "reserved", "Stock reserved for cart")
+// ReserveStock reserves stock for a product, or for one of its variants if
+// variantID is set
+func (r *productRepository) ReserveStock(ctx context.Context, productID, variantID string, quantity int) error {
+	return r.executeStockOperation(ctx, productID, variantID, quantity, "reserved", "Stock reserved for cart")
 }
 
-// ReleaseStock releases reserved stock
-func (r *productRepository) ReleaseStock(ctx context.Context, productID string, quantity int) error {
-	return r.executeStockOperation(ctx, productID, quantity, "released", "Stock released from cart")
+// ReleaseStock releases reserved stock of a product or one of its variants
+func (r *productRepository) ReleaseStock(ctx context.Context, productID, variantID string, quantity int) error {
+	return r.executeStockOperation(ctx, productID, variantID, quantity, "released", "Stock released from cart")
 }
 
 // GetAvailableStock returns available stock (total - reserved)
@@ -409,7 +489,15 @@ func (r *productRepository) BulkUpdateStock(ctx context.Context, updates []Stock
 	defer tx.Rollback()
 	
 	for _, update := range updates {
-		err := r.executeStockOperationTx(ctx, tx, update.ProductID, update.Quantity, update.Type, update.Reason)
+		err := r.executeStockMovementTx(ctx, tx, stockMovement{
+			ProductID:     update.ProductID,
+			VariantID:     update.VariantID,
+			Quantity:      update.Quantity,
+			Type:          update.Type,
+			Reason:        update.Reason,
+			ReferenceType: update.ReferenceType,
+			ReferenceID:   update.ReferenceID,
+		})
 		if err != nil {
 			return err
 		}
@@ -422,15 +510,55 @@ func (r *productRepository) BulkUpdateStock(ctx context.Context, updates []Stock
 	return nil
 }
 
+// SetVariantStock sets the stock of a variant. The variant is locked before
+// its stock is read, so the movement is the difference from the stock it
+// actually has rather than from a copy read earlier.
+func (r *productRepository) SetVariantStock(ctx context.Context, productID, variantID string, stock int, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMovementStock(ctx, tx, productID, variantID); err != nil {
+		return err
+	}
+
+	levels, err := readStockLevels(ctx, tx, productID, variantID)
+	if err != nil {
+		return err
+	}
+	if reserved := levels.variantStock - levels.variantAvailable; stock < reserved {
+		return utils.NewConflictError(fmt.Sprintf("stock cannot be below the %d reserved units", reserved))
+	}
+
+	err = r.executeStockMovementTx(ctx, tx, stockMovement{
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  stock,
+		Type:      "adjustment",
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
 // executeStockOperation executes a stock operation and records the movement
-func (r *productRepository) executeStockOperation(ctx context.Context, productID string, quantity int, movementType, reason string) error {
+func (r *productRepository) executeStockOperation(ctx context.Context, productID, variantID string, quantity int, movementType, reason string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return utils.NewInternalError("failed to begin transaction", err)
 	}
 	defer tx.Rollback()
 	
-	err = r.executeStockOperationTx(ctx, tx, productID, quantity, movementType, reason)
+	err = r.executeStockOperationTx(ctx, tx, productID, variantID, quantity, movementType, reason)
 	if err != nil {
 		return err
 	}
@@ -442,19 +570,245 @@ func (r *productRepository) executeStockOperation(ctx context.Context, productID
 	return nil
 }
 
-// executeStockOperationTx executes a stock operation within a transaction
-func (r *productRepository) executeStockOperationTx(ctx context.Context, tx *sql.Tx, productID string, quantity int, movementType, reason string) error {
-	// Record inventory movement
+// executeStockOperationTx executes a stock operation within a transaction.
+// Movements of a variant also move the stock of its product, so a product's
+// stock stays the total of its variants'.
+func (r *productRepository) executeStockOperationTx(ctx context.Context, tx *sql.Tx, productID, variantID string, quantity int, movementType, reason string) error {
+	return r.executeStockMovementTx(ctx, tx, stockMovement{
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  quantity,
+		Type:      movementType,
+		Reason:    reason,
+	})
+}
+
+// stockMovement is an inventory movement to record. A movement with a
+// warehouse also moves that warehouse's stock, and one with a reference
+// records the order, cart or transfer it was made for.
+type stockMovement struct {
+	ProductID     string
+	VariantID     string
+	WarehouseID   string
+	Quantity      int
+	Type          string
+	Reason        string
+	ReferenceType string
+	ReferenceID   string
+}
+
+// executeStockMovementTx records a stock movement within a transaction and
+// publishes the stock change
+func (r *productRepository) executeStockMovementTx(ctx context.Context, tx *sql.Tx, movement stockMovement) error {
+	// Lock the product row so the stock snapshot in the event is consistent
+	sku, err := lockMovementStock(ctx, tx, movement.ProductID, movement.VariantID)
+	if err != nil {
+		return err
+	}
+	
+	// Reservations only move reserved_stock, so they are not inventory updates
+	if movement.Type == "reserved" || movement.Type == "released" {
+		return recordMovementTx(ctx, tx, movement)
+	}
+	
+	previous, err := readStockLevels(ctx, tx, movement.ProductID, movement.VariantID)
+	if err != nil {
+		return err
+	}
+	
+	reason := inventoryEventReason(movement.Type)
+	if movement.Type == "adjustment" {
+		if movement, err = adjustmentMovement(movement, previous); err != nil {
+			return err
+		}
+		if movement.Quantity == 0 {
+			return nil
+		}
+	}
+	
+	if err := recordMovementTx(ctx, tx, movement); err != nil {
+		return err
+	}
+	
+	current, err := readStockLevels(ctx, tx, movement.ProductID, movement.VariantID)
+	if err != nil {
+		return err
+	}
+	
+	return r.saveInventoryEvent(ctx, tx, movement, reason, sku, previous, current)
+}
+
+// adjustmentMovement turns an adjustment, which sets stock to a counted
+// quantity, into the in or out movement that moves stock to it, so that the
+// inventory movement trigger and the ledger both apply it
+func adjustmentMovement(movement stockMovement, levels stockLevels) (stockMovement, error) {
+	if movement.Quantity < 0 {
+		return movement, utils.NewValidationError("adjusted stock cannot be negative")
+	}
+	
+	stock := levels.stock
+	if movement.VariantID != "" {
+		stock = levels.variantStock
+	}
+	
+	delta := movement.Quantity - stock
+	movement.Type = "in"
+	movement.Quantity = delta
+	if delta < 0 {
+		movement.Type = "out"
+		movement.Quantity = -delta
+	}
+	return movement, nil
+}
+
+// inventoryEventReason describes a stock movement in inventory.updated events
+func inventoryEventReason(movementType string) string {
+	switch movementType {
+	case "in":
+		return "restock"
+	case "out":
+		return "sale"
+	}
+	return "adjustment"
+}
+
+// stockLevels is the stock of a product, and of the variant that moved if
+// there is one, at one point of a stock movement
+type stockLevels struct {
+	stock            int
+	available        int
+	variantSKU       string
+	variantStock     int
+	variantAvailable int
+}
+
+// readStockLevels reads the stock of a product and, if variantID is set, of
+// one of its variants
+func readStockLevels(ctx context.Context, tx *sql.Tx, productID, variantID string) (stockLevels, error) {
+	var levels stockLevels
+	err := tx.QueryRowContext(ctx, `SELECT stock, stock - reserved_stock FROM products WHERE id = $1`, productID).
+		Scan(&levels.stock, &levels.available)
+	if err != nil {
+		return levels, utils.NewInternalError("failed to read product stock", err)
+	}
+	
+	if variantID != "" {
+		err := tx.QueryRowContext(ctx, `SELECT sku, stock, stock - reserved_stock FROM product_variants WHERE id = $1`, variantID).
+			Scan(&levels.variantSKU, &levels.variantStock, &levels.variantAvailable)
+		if err != nil {
+			return levels, utils.NewInternalError("failed to read variant stock", err)
+		}
+	}
+	
+	return levels, nil
+}
+
+// lockMovementStock locks the product, and the variant if one is given,
+// and returns the product's SKU
+func lockMovementStock(ctx context.Context, tx *sql.Tx, productID, variantID string) (string, error) {
+	var sku string
+	err := tx.QueryRowContext(ctx, `SELECT sku FROM products WHERE id = $1 FOR UPDATE`, productID).
+		Scan(&sku)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", utils.NewNotFoundError("product")
+		}
+		return "", utils.NewInternalError("failed to lock product stock", err)
+	}
+
+	if variantID != "" {
+		var id string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM product_variants WHERE id = $1 AND product_id = $2 FOR UPDATE`, variantID, productID).
+			Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", utils.NewNotFoundError("product variant")
+			}
+			return "", utils.NewInternalError("failed to lock variant stock", err)
+		}
+	}
+	
+	return sku, nil
+}
+
+// recordMovementTx inserts an inventory movement; the inventory movement
+// trigger applies it to the stock columns
+func recordMovementTx(ctx context.Context, tx *sql.Tx, movement stockMovement) error {
 	movementQuery := `
-		INSERT INTO inventory_movements (id, product_id, movement_type, quantity, reason, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+		INSERT INTO inventory_movements (id, product_id, variant_id, warehouse_id, movement_type, quantity, reference_type, reference_id, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 	
 	_, err := tx.ExecContext(ctx, movementQuery,
-		uuid.New().String(), productID, movementType, quantity, reason, time.Now())
+		uuid.New().String(), movement.ProductID, nullableString(movement.VariantID), nullableString(movement.WarehouseID),
+		movement.Type, movement.Quantity, nullableString(movement.ReferenceType), nullableString(movement.ReferenceID),
+		movement.Reason, movementActor(ctx), time.Now())
 	
 	if err != nil {
 		return utils.NewInternalError("failed to record inventory movement", err)
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// movementActor attributes a movement to the user or service that asked for
+// it. Movements product-service makes on its own, such as expiring
+// reservations, are attributed to product-service.
+func movementActor(ctx context.Context) string {
+	if userID := utils.GetUserID(ctx); userID != "" {
+		return "user:" + userID
+	}
+	if serviceName := utils.GetCallerService(ctx); serviceName != "" {
+		return "service:" + serviceName
+	}
+	return "service:product-service"
+}
+
+// saveInventoryEvent writes an inventory.updated event to the outbox
+func (r *productRepository) saveInventoryEvent(ctx context.Context, tx *sql.Tx, movement stockMovement, reason, sku string, previous, current stockLevels) error {
+	data := models.InventoryUpdatedData{
+		ProductID:         movement.ProductID,
+		SKU:               sku,
+		PreviousStock:     previous.stock,
+		NewStock:          current.stock,
+		PreviousAvailable: previous.available,
+		NewAvailable:      current.available,
+		Reason:            reason,
+	}
+	if movement.VariantID != "" {
+		data.Variant = &models.VariantStockChange{
+			VariantID:         movement.VariantID,
+			SKU:               current.variantSKU,
+			PreviousStock:     previous.variantStock,
+			NewStock:          current.variantStock,
+			PreviousAvailable: previous.variantAvailable,
+			NewAvailable:      current.variantAvailable,
+		}
+	}
+	
+	event, err := models.NewDomainEvent(models.EventInventoryUpdated, movement.ProductID, data, events.MetadataFromContext(ctx, "product-service"))
+	if err != nil {
+		return utils.NewInternalError("failed to create inventory event", err)
+	}
+	
+	if err := r.outbox.Save(ctx, tx, event); err != nil {
+		return utils.NewInternalError("failed to save inventory event", err)
+	}
+	
+	return nil
+}
+
+// marshalOptions stores a product without options as an empty array
+func marshalOptions(options []models.ProductOption) ([]byte, error) {
+	if options == nil {
+		options = []models.ProductOption{}
+	}
+	return json.Marshal(options)
+}
+
+// marshalPrices stores a product without a price list as an empty object
+func marshalPrices(prices models.PriceList) ([]byte, error) {
+	if prices == nil {
+		prices = models.PriceList{}
+	}
+	return json.Marshal(prices)
+}
@@ -0,0 +1,455 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+const reservationColumns = `id, reference_type, reference_id, product_id, variant_id, quantity,
+	committed_quantity, status, expires_at, released_at, created_at, updated_at`
+
+type reservationRepository struct {
+	db       *sql.DB
+	products *productRepository // records the inventory movements of reserved stock
+}
+
+// NewReservationRepository creates a new stock reservation repository
+func NewReservationRepository(db *sql.DB) ReservationRepository {
+	return &reservationRepository{
+		db:       db,
+		products: &productRepository{db: db, outbox: events.NewPostgresOutbox(db)},
+	}
+}
+
+// Reserve creates the reservation for its key, or changes the quantity held
+// by the existing one. Only the difference is reserved or released, so
+// reserving the same quantity again just moves the expiry.
+func (r *reservationRepository) Reserve(ctx context.Context, reservation *models.StockReservation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	available, err := lockAvailableStock(ctx, tx, reservation.ProductID, reservation.VariantID)
+	if err != nil {
+		return err
+	}
+
+	key := keyOf(reservation)
+	existing, err := getReservationForUpdate(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	held := 0
+	if existing != nil {
+		if existing.Status == models.ReservationCommitted {
+			return utils.NewConflictError("stock reservation is already committed")
+		}
+		if existing.IsActive() {
+			held = existing.Quantity
+		}
+	}
+
+	delta := reservation.Quantity - held
+	if delta > available {
+		return utils.NewConflictError(fmt.Sprintf("insufficient stock: %d available", available))
+	}
+
+	reason := fmt.Sprintf("Stock reserved for %s %s", key.ReferenceType, key.ReferenceID)
+	switch {
+	case delta > 0:
+		err = r.products.executeStockMovementTx(ctx, tx, reservationMovement(key, "reserved", delta, reason))
+	case delta < 0:
+		err = r.products.executeStockMovementTx(ctx, tx, reservationMovement(key, "released", -delta, reason))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Allocations were made for the quantity held before, so they are
+	// dropped and the reservation has to be allocated again
+	if existing != nil && delta != 0 {
+		if err := deleteAllocationsTx(ctx, tx, existing.ID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	reservation.Status = models.ReservationActive
+	reservation.ReleasedAt = nil
+	reservation.UpdatedAt = now
+
+	if existing == nil {
+		reservation.CreatedAt = now
+		query := `
+			INSERT INTO stock_reservations (
+				id, reference_type, reference_id, product_id, variant_id, quantity,
+				committed_quantity, status, expires_at, created_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+		_, err = tx.ExecContext(ctx, query,
+			reservation.ID, key.ReferenceType, key.ReferenceID, key.ProductID, nullableString(key.VariantID),
+			reservation.Quantity, reservation.CommittedQuantity, reservation.Status, reservation.ExpiresAt,
+			reservation.CreatedAt, reservation.UpdatedAt,
+		)
+		if err != nil {
+			return utils.NewInternalError("failed to create stock reservation", err)
+		}
+	} else {
+		reservation.ID = existing.ID
+		reservation.CommittedQuantity = existing.CommittedQuantity
+		reservation.CreatedAt = existing.CreatedAt
+		query := `
+			UPDATE stock_reservations
+			SET quantity = $2, status = $3, expires_at = $4, released_at = NULL, updated_at = $5
+			WHERE id = $1`
+
+		_, err = tx.ExecContext(ctx, query,
+			reservation.ID, reservation.Quantity, reservation.Status, reservation.ExpiresAt, reservation.UpdatedAt)
+		if err != nil {
+			return utils.NewInternalError("failed to update stock reservation", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// Release releases the units held by an active reservation
+func (r *reservationRepository) Release(ctx context.Context, key ReservationKey) (*models.StockReservation, error) {
+	return r.end(ctx, key, models.ReservationReleased, func(*models.StockReservation) bool { return true })
+}
+
+// Expire releases the units held by a reservation if it is still active and
+// has expired by now. The expiry is checked again under the row lock, since
+// the reservation may have been renewed since it was listed.
+func (r *reservationRepository) Expire(ctx context.Context, key ReservationKey, now time.Time) (*models.StockReservation, error) {
+	return r.end(ctx, key, models.ReservationExpired, func(reservation *models.StockReservation) bool {
+		return reservation.HasExpired(now)
+	})
+}
+
+// end releases an active reservation's units, drops its allocations and
+// gives it the final status when shouldEnd allows it. Reservations that are
+// not ended are returned unchanged.
+func (r *reservationRepository) end(ctx context.Context, key ReservationKey, status models.ReservationStatus, shouldEnd func(*models.StockReservation) bool) (*models.StockReservation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockAvailableStock(ctx, tx, key.ProductID, key.VariantID); err != nil {
+		return nil, err
+	}
+
+	reservation, err := getReservationForUpdate(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil || !reservation.IsActive() || !shouldEnd(reservation) {
+		return reservation, nil
+	}
+
+	reason := fmt.Sprintf("Stock reservation for %s %s %s", key.ReferenceType, key.ReferenceID, status)
+	err = r.products.executeStockMovementTx(ctx, tx, reservationMovement(key, "released", reservation.Quantity, reason))
+	if err != nil {
+		return nil, err
+	}
+	if err := deleteAllocationsTx(ctx, tx, reservation.ID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reservation.Status = status
+	reservation.ReleasedAt = &now
+	reservation.UpdatedAt = now
+
+	query := `UPDATE stock_reservations SET status = $2, released_at = $3, updated_at = $3 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, reservation.ID, reservation.Status, now); err != nil {
+		return nil, utils.NewInternalError("failed to update stock reservation", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return reservation, nil
+}
+
+// Commit turns reserved units into sold ones. Units of an active reservation
+// are released before they are taken out of stock; an expired reservation
+// already released them, so it is only committed while the units are still
+// available and they are only taken out. Released reservations cannot be
+// committed. Units are taken out of the warehouses the reservation was
+// allocated to first.
+func (r *reservationRepository) Commit(ctx context.Context, key ReservationKey, quantity int) (*models.StockReservation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	available, err := lockAvailableStock(ctx, tx, key.ProductID, key.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := getReservationForUpdate(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, utils.NewNotFoundError("stock reservation")
+	}
+
+	switch reservation.Status {
+	case models.ReservationReleased:
+		return nil, utils.NewConflictError("stock reservation has been released")
+	case models.ReservationCommitted:
+		if quantity == 0 {
+			return reservation, nil
+		}
+		return nil, utils.NewConflictError("stock reservation is already committed")
+	}
+
+	if quantity == 0 {
+		quantity = reservation.Quantity
+	}
+	if quantity > reservation.Quantity {
+		return nil, utils.NewConflictError(fmt.Sprintf("only %d units are reserved", reservation.Quantity))
+	}
+	// The units of an expired reservation may have been sold to someone else
+	if !reservation.IsActive() && quantity > available {
+		return nil, utils.NewConflictError(fmt.Sprintf("stock reservation has expired and only %d units are available", available))
+	}
+
+	reason := fmt.Sprintf("Stock committed for %s %s", key.ReferenceType, key.ReferenceID)
+	if reservation.IsActive() {
+		err := r.products.executeStockMovementTx(ctx, tx, reservationMovement(key, "released", quantity, reason))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := r.takeOutTx(ctx, tx, reservation, quantity, reason); err != nil {
+		return nil, err
+	}
+
+	reservation.Quantity -= quantity
+	reservation.CommittedQuantity += quantity
+	if reservation.Quantity == 0 {
+		reservation.Status = models.ReservationCommitted
+	}
+	reservation.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE stock_reservations
+		SET quantity = $2, committed_quantity = $3, status = $4, updated_at = $5
+		WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, query,
+		reservation.ID, reservation.Quantity, reservation.CommittedQuantity, reservation.Status, reservation.UpdatedAt)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to update stock reservation", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return reservation, nil
+}
+
+// takeOutTx takes committed units of a reservation out of stock, from the
+// warehouses they were allocated to first. Units that were not allocated, or
+// that a warehouse no longer has, come out of stock not kept at a warehouse.
+func (r *reservationRepository) takeOutTx(ctx context.Context, tx *sql.Tx, reservation *models.StockReservation, quantity int, reason string) error {
+	key := keyOf(reservation)
+
+	allocations, err := listAllocationsForUpdate(ctx, tx, reservation.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, allocation := range allocations {
+		if quantity == 0 {
+			break
+		}
+
+		stock, err := lockWarehouseStock(ctx, tx, allocation.WarehouseID, key.ProductID, key.VariantID)
+		if err != nil {
+			return err
+		}
+		take := min(allocation.Quantity-allocation.CommittedQuantity, quantity, stock)
+		if take <= 0 {
+			continue
+		}
+
+		movement := reservationMovement(key, "out", take, reason)
+		movement.WarehouseID = allocation.WarehouseID
+		if err := r.products.executeStockMovementTx(ctx, tx, movement); err != nil {
+			return err
+		}
+
+		query := `UPDATE stock_allocations SET committed_quantity = committed_quantity + $2 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, allocation.ID, take); err != nil {
+			return utils.NewInternalError("failed to update stock allocation", err)
+		}
+		quantity -= take
+	}
+
+	if quantity == 0 {
+		return nil
+	}
+	return r.products.executeStockMovementTx(ctx, tx, reservationMovement(key, "out", quantity, reason))
+}
+
+// ListByReference returns the reservations held for an order or cart
+func (r *reservationRepository) ListByReference(ctx context.Context, referenceType, referenceID string) ([]*models.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+		WHERE reference_type = $1 AND reference_id = $2 ORDER BY created_at`
+
+	return r.list(ctx, query, referenceType, referenceID)
+}
+
+// ListExpired returns up to limit active reservations that have expired by
+// now, oldest first
+func (r *reservationRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]*models.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+		WHERE status = 'active' AND expires_at <= $1 ORDER BY expires_at LIMIT $2`
+
+	return r.list(ctx, query, now, limit)
+}
+
+func (r *reservationRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.StockReservation, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list stock reservations", err)
+	}
+	defer rows.Close()
+
+	var reservations []*models.StockReservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to list stock reservations", err)
+	}
+
+	return reservations, nil
+}
+
+// lockAvailableStock locks the product, and the variant if one is given, and
+// returns its available stock. Locking the product first keeps the lock
+// order of stock movements.
+func lockAvailableStock(ctx context.Context, tx *sql.Tx, productID, variantID string) (int, error) {
+	var available int
+	err := tx.QueryRowContext(ctx, `SELECT stock - reserved_stock FROM products WHERE id = $1 FOR UPDATE`, productID).
+		Scan(&available)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.NewNotFoundError("product")
+		}
+		return 0, utils.NewInternalError("failed to lock product stock", err)
+	}
+
+	if variantID == "" {
+		return available, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT stock - reserved_stock FROM product_variants WHERE id = $1 AND product_id = $2 FOR UPDATE`,
+		variantID, productID).Scan(&available)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.NewNotFoundError("product variant")
+		}
+		return 0, utils.NewInternalError("failed to lock variant stock", err)
+	}
+
+	return available, nil
+}
+
+// getReservationForUpdate locks and returns the reservation for key, or nil
+// if there is none
+func getReservationForUpdate(ctx context.Context, tx *sql.Tx, key ReservationKey) (*models.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+		WHERE reference_type = $1 AND reference_id = $2 AND product_id = $3 AND COALESCE(variant_id, '') = $4
+		FOR UPDATE`
+
+	reservation, err := scanReservation(tx.QueryRowContext(ctx, query,
+		key.ReferenceType, key.ReferenceID, key.ProductID, key.VariantID))
+	if err != nil {
+		if appErr, ok := err.(*utils.AppError); ok && appErr.Code == utils.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// reservationMovement is a stock movement for the reservation with key
+func reservationMovement(key ReservationKey, movementType string, quantity int, reason string) stockMovement {
+	return stockMovement{
+		ProductID:     key.ProductID,
+		VariantID:     key.VariantID,
+		Quantity:      quantity,
+		Type:          movementType,
+		Reason:        reason,
+		ReferenceType: key.ReferenceType,
+		ReferenceID:   key.ReferenceID,
+	}
+}
+
+func keyOf(reservation *models.StockReservation) ReservationKey {
+	return ReservationKey{
+		ReferenceType: reservation.ReferenceType,
+		ReferenceID:   reservation.ReferenceID,
+		ProductID:     reservation.ProductID,
+		VariantID:     reservation.VariantID,
+	}
+}
+
+func scanReservation(row rowScanner) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	var variantID sql.NullString
+	var releasedAt sql.NullTime
+
+	err := row.Scan(
+		&reservation.ID, &reservation.ReferenceType, &reservation.ReferenceID, &reservation.ProductID, &variantID,
+		&reservation.Quantity, &reservation.CommittedQuantity, &reservation.Status, &reservation.ExpiresAt,
+		&releasedAt, &reservation.CreatedAt, &reservation.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("stock reservation")
+		}
+		return nil, utils.NewInternalError("failed to scan stock reservation", err)
+	}
+
+	reservation.VariantID = variantID.String
+	if releasedAt.Valid {
+		reservation.ReleasedAt = &releasedAt.Time
+	}
+
+	return &reservation, nil
+}
+
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+const thresholdColumns = `id, product_id, variant_id, threshold, created_at, updated_at`
+
+const subscriptionColumns = `id, product_id, variant_id, user_id, channel, recipient, notified_at, created_at`
+
+type stockAlertRepository struct {
+	db *sql.DB
+}
+
+// NewStockAlertRepository creates a new stock alert repository
+func NewStockAlertRepository(db *sql.DB) StockAlertRepository {
+	return &stockAlertRepository{db: db}
+}
+
+// SetThreshold creates or replaces the threshold of a product or variant.
+// A replaced threshold keeps its ID.
+func (r *stockAlertRepository) SetThreshold(ctx context.Context, threshold *models.StockThreshold) error {
+	threshold.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO stock_thresholds (` + thresholdColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (product_id, COALESCE(variant_id, ''))
+		DO UPDATE SET threshold = EXCLUDED.threshold, updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		threshold.ID, threshold.ProductID, nullableString(threshold.VariantID), threshold.Threshold,
+		threshold.CreatedAt, threshold.UpdatedAt,
+	).Scan(&threshold.ID, &threshold.CreatedAt)
+	if err != nil {
+		return utils.NewInternalError("failed to set stock threshold", err)
+	}
+
+	return nil
+}
+
+// GetThreshold returns the threshold of a product or variant, or nil
+func (r *stockAlertRepository) GetThreshold(ctx context.Context, productID, variantID string) (*models.StockThreshold, error) {
+	query := `SELECT ` + thresholdColumns + ` FROM stock_thresholds WHERE product_id = $1 AND COALESCE(variant_id, '') = $2`
+
+	threshold, err := scanThreshold(r.db.QueryRowContext(ctx, query, productID, variantID))
+	if err != nil {
+		if appErr, ok := err.(*utils.AppError); ok && appErr.Code == utils.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+// ListThresholds returns the thresholds of a product and its variants
+func (r *stockAlertRepository) ListThresholds(ctx context.Context, productID string) ([]*models.StockThreshold, error) {
+	query := `SELECT ` + thresholdColumns + ` FROM stock_thresholds WHERE product_id = $1 ORDER BY variant_id NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list stock thresholds", err)
+	}
+	defer rows.Close()
+
+	var thresholds []*models.StockThreshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to iterate stock thresholds", err)
+	}
+
+	return thresholds, nil
+}
+
+// DeleteThreshold deletes the threshold of a product or variant
+func (r *stockAlertRepository) DeleteThreshold(ctx context.Context, productID, variantID string) error {
+	query := `DELETE FROM stock_thresholds WHERE product_id = $1 AND COALESCE(variant_id, '') = $2`
+
+	result, err := r.db.ExecContext(ctx, query, productID, variantID)
+	if err != nil {
+		return utils.NewInternalError("failed to delete stock threshold", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.NewInternalError("failed to get rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("stock threshold")
+	}
+
+	return nil
+}
+
+// Subscribe creates a back-in-stock subscription
+func (r *stockAlertRepository) Subscribe(ctx context.Context, subscription *models.BackInStockSubscription) error {
+	query := `
+		INSERT INTO back_in_stock_subscriptions (` + subscriptionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		subscription.ID, subscription.ProductID, nullableString(subscription.VariantID), subscription.UserID,
+		subscription.Channel, subscription.Recipient, subscription.NotifiedAt, subscription.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return utils.NewConflictError("already subscribed to this item")
+		}
+		return utils.NewInternalError("failed to create back-in-stock subscription", err)
+	}
+
+	return nil
+}
+
+// GetSubscription retrieves a back-in-stock subscription by ID
+func (r *stockAlertRepository) GetSubscription(ctx context.Context, id string) (*models.BackInStockSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM back_in_stock_subscriptions WHERE id = $1`
+	return scanSubscription(r.db.QueryRowContext(ctx, query, id))
+}
+
+// ListSubscriptions returns a user's subscriptions, newest first
+func (r *stockAlertRepository) ListSubscriptions(ctx context.Context, userID string) ([]*models.BackInStockSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM back_in_stock_subscriptions WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list back-in-stock subscriptions", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// Unsubscribe deletes a pending subscription
+func (r *stockAlertRepository) Unsubscribe(ctx context.Context, id string) error {
+	query := `DELETE FROM back_in_stock_subscriptions WHERE id = $1 AND notified_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return utils.NewInternalError("failed to delete back-in-stock subscription", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.NewInternalError("failed to get rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("back-in-stock subscription")
+	}
+
+	return nil
+}
+
+// ClaimSubscriptions marks the pending subscriptions of a product or variant
+// as notified and returns them
+func (r *stockAlertRepository) ClaimSubscriptions(ctx context.Context, productID, variantID string) ([]*models.BackInStockSubscription, error) {
+	query := `
+		UPDATE back_in_stock_subscriptions SET notified_at = $3
+		WHERE product_id = $1 AND COALESCE(variant_id, '') = $2 AND notified_at IS NULL
+		RETURNING ` + subscriptionColumns
+
+	rows, err := r.db.QueryContext(ctx, query, productID, variantID, time.Now())
+	if err != nil {
+		return nil, utils.NewInternalError("failed to claim back-in-stock subscriptions", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// UnclaimSubscription returns a claimed subscription to pending
+func (r *stockAlertRepository) UnclaimSubscription(ctx context.Context, id string) error {
+	query := `UPDATE back_in_stock_subscriptions SET notified_at = NULL WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			// The customer subscribed again in the meantime
+			return nil
+		}
+		return utils.NewInternalError("failed to unclaim back-in-stock subscription", err)
+	}
+
+	return nil
+}
+
+// RecordAlert records that the inventory event raised the low-stock alert of
+// a product or variant, returning false when it was already recorded
+func (r *stockAlertRepository) RecordAlert(ctx context.Context, eventID, productID, variantID string) (bool, error) {
+	query := `
+		INSERT INTO raised_stock_alerts (event_id, product_id, variant_id, raised_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id, product_id, variant_id) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, eventID, productID, variantID, time.Now())
+	if err != nil {
+		return false, utils.NewInternalError("failed to record stock alert", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, utils.NewInternalError("failed to get affected rows", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// ForgetAlert drops a recorded alert so that it is raised again
+func (r *stockAlertRepository) ForgetAlert(ctx context.Context, eventID, productID, variantID string) error {
+	query := `DELETE FROM raised_stock_alerts WHERE event_id = $1 AND product_id = $2 AND variant_id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, eventID, productID, variantID); err != nil {
+		return utils.NewInternalError("failed to forget stock alert", err)
+	}
+
+	return nil
+}
+
+func scanThreshold(row rowScanner) (*models.StockThreshold, error) {
+	var threshold models.StockThreshold
+	var variantID sql.NullString
+
+	err := row.Scan(
+		&threshold.ID, &threshold.ProductID, &variantID, &threshold.Threshold,
+		&threshold.CreatedAt, &threshold.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("stock threshold")
+		}
+		return nil, utils.NewInternalError("failed to scan stock threshold", err)
+	}
+
+	threshold.VariantID = variantID.String
+	return &threshold, nil
+}
+
+func scanSubscriptions(rows *sql.Rows) ([]*models.BackInStockSubscription, error) {
+	var subscriptions []*models.BackInStockSubscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to iterate back-in-stock subscriptions", err)
+	}
+
+	return subscriptions, nil
+}
+
+func scanSubscription(row rowScanner) (*models.BackInStockSubscription, error) {
+	var subscription models.BackInStockSubscription
+	var variantID sql.NullString
+	var notifiedAt sql.NullTime
+
+	err := row.Scan(
+		&subscription.ID, &subscription.ProductID, &variantID, &subscription.UserID,
+		&subscription.Channel, &subscription.Recipient, &notifiedAt, &subscription.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("back-in-stock subscription")
+		}
+		return nil, utils.NewInternalError("failed to scan back-in-stock subscription", err)
+	}
+
+	subscription.VariantID = variantID.String
+	if notifiedAt.Valid {
+		subscription.NotifiedAt = &notifiedAt.Time
+	}
+
+	return &subscription, nil
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+const variantColumns = `id, product_id, sku, name, price, prices, compare_price, cost_price,
+	stock, reserved_stock, weight, attributes, image_url, is_default, created_at, updated_at`
+
+type variantRepository struct {
+	db       *sql.DB
+	products *productRepository // records the inventory movements of variant stock
+}
+
+// NewVariantRepository creates a new product variant repository
+func NewVariantRepository(db *sql.DB) VariantRepository {
+	return &variantRepository{
+		db:       db,
+		products: &productRepository{db: db, outbox: events.NewPostgresOutbox(db)},
+	}
+}
+
+// Create creates a new variant. Its stock is recorded as an inventory
+// movement so that it is added to the product's stock too.
+func (r *variantRepository) Create(ctx context.Context, variant *models.ProductVariant) error {
+	if variant.ID == "" {
+		variant.ID = uuid.New().String()
+	}
+
+	variant.CreatedAt = time.Now()
+	variant.UpdatedAt = time.Now()
+
+	attributesJSON, pricesJSON, err := marshalVariantJSON(variant)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if variant.IsDefault {
+		if err := clearDefaultVariant(ctx, tx, variant.ProductID, variant.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO product_variants (
+			id, product_id, sku, name, price, prices, compare_price, cost_price,
+			stock, reserved_stock, weight, attributes, image_url, is_default, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, 0, 0, $9, $10, $11, $12, $13, $14
+		)`
+
+	_, err = tx.ExecContext(ctx, query,
+		variant.ID, variant.ProductID, variant.SKU, variant.Name, variant.Price, pricesJSON,
+		nullableDecimal(variant.ComparePrice), nullableDecimal(variant.CostPrice),
+		variant.Weight, attributesJSON, variant.ImageURL, variant.IsDefault,
+		variant.CreatedAt, variant.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return utils.NewConflictError("variant with this SKU already exists")
+		}
+		return utils.NewInternalError("failed to create product variant", err)
+	}
+
+	if variant.Stock > 0 {
+		err := r.products.executeStockOperationTx(ctx, tx, variant.ProductID, variant.ID, variant.Stock, "in", "Initial variant stock")
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a variant by ID
+func (r *variantRepository) GetByID(ctx context.Context, id string) (*models.ProductVariant, error) {
+	query := fmt.Sprintf(`SELECT %s FROM product_variants WHERE id = $1`, variantColumns)
+
+	variant, err := scanVariant(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("product variant")
+		}
+		return nil, utils.NewInternalError("failed to get product variant", err)
+	}
+
+	return variant, nil
+}
+
+// Update updates a variant's details. Stock is not changed.
+func (r *variantRepository) Update(ctx context.Context, variant *models.ProductVariant) error {
+	variant.UpdatedAt = time.Now()
+
+	attributesJSON, pricesJSON, err := marshalVariantJSON(variant)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if variant.IsDefault {
+		if err := clearDefaultVariant(ctx, tx, variant.ProductID, variant.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE product_variants SET
+			sku = $2, name = $3, price = $4, prices = $5, compare_price = $6, cost_price = $7,
+			weight = $8, attributes = $9, image_url = $10, is_default = $11, updated_at = $12
+		WHERE id = $1`
+
+	result, err := tx.ExecContext(ctx, query,
+		variant.ID, variant.SKU, variant.Name, variant.Price, pricesJSON,
+		nullableDecimal(variant.ComparePrice), nullableDecimal(variant.CostPrice),
+		variant.Weight, attributesJSON, variant.ImageURL, variant.IsDefault, variant.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return utils.NewConflictError("variant with this SKU already exists")
+		}
+		return utils.NewInternalError("failed to update product variant", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.NewInternalError("failed to get rows affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("product variant")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a variant. Its remaining stock is written off the product,
+// and a variant with reserved stock cannot be deleted.
+func (r *variantRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the product before the variant, in the same order as stock movements
+	var productID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.id FROM products p JOIN product_variants v ON v.product_id = p.id
+		WHERE v.id = $1 FOR UPDATE OF p`, id).Scan(&productID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.NewNotFoundError("product variant")
+		}
+		return utils.NewInternalError("failed to lock product stock", err)
+	}
+
+	var sku string
+	var stock, reservedStock int
+	err = tx.QueryRowContext(ctx, `SELECT sku, stock, reserved_stock FROM product_variants WHERE id = $1 FOR UPDATE`, id).
+		Scan(&sku, &stock, &reservedStock)
+	if err != nil {
+		return utils.NewInternalError("failed to lock variant stock", err)
+	}
+
+	if reservedStock > 0 {
+		return utils.NewConflictError("variant has reserved stock")
+	}
+
+	// Recorded against the product only, since the variant is about to go;
+	// its earlier movements stay in the product's ledger
+	if stock > 0 {
+		err := r.products.executeStockOperationTx(ctx, tx, productID, "", stock, "out", "Variant "+sku+" deleted")
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_variants WHERE id = $1`, id); err != nil {
+		return utils.NewInternalError("failed to delete product variant", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// ListByProduct retrieves the variants of a product, default variant first
+func (r *variantRepository) ListByProduct(ctx context.Context, productID string) ([]*models.ProductVariant, error) {
+	variants, err := r.ListByProducts(ctx, []string{productID})
+	if err != nil {
+		return nil, err
+	}
+	return variants[productID], nil
+}
+
+// ListByProducts retrieves the variants of several products, keyed by product ID
+func (r *variantRepository) ListByProducts(ctx context.Context, productIDs []string) (map[string][]*models.ProductVariant, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM product_variants
+		WHERE product_id = ANY($1)
+		ORDER BY is_default DESC, created_at, sku`, variantColumns)
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list product variants", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[string][]*models.ProductVariant)
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, utils.NewInternalError("failed to scan product variant", err)
+		}
+		variants[variant.ProductID] = append(variants[variant.ProductID], variant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to iterate product variants", err)
+	}
+
+	return variants, nil
+}
+
+// clearDefaultVariant unsets the default flag on the product's other variants
+func clearDefaultVariant(ctx context.Context, tx *sql.Tx, productID, variantID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE product_variants SET is_default = false WHERE product_id = $1 AND id <> $2 AND is_default`,
+		productID, variantID)
+	if err != nil {
+		return utils.NewInternalError("failed to clear default variant", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVariant(row rowScanner) (*models.ProductVariant, error) {
+	variant := &models.ProductVariant{}
+	var pricesJSON, attributesJSON []byte
+	var comparePrice, costPrice decimal.NullDecimal
+	var stock, reservedStock sql.NullInt64
+	var weight sql.NullFloat64
+	var imageURL sql.NullString
+	var isDefault sql.NullBool
+
+	err := row.Scan(
+		&variant.ID, &variant.ProductID, &variant.SKU, &variant.Name, &variant.Price, &pricesJSON,
+		&comparePrice, &costPrice, &stock, &reservedStock, &weight, &attributesJSON,
+		&imageURL, &isDefault, &variant.CreatedAt, &variant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(pricesJSON, &variant.Prices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal variant prices: %w", err)
+	}
+	if len(attributesJSON) > 0 {
+		if err := json.Unmarshal(attributesJSON, &variant.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal variant attributes: %w", err)
+		}
+	}
+
+	if comparePrice.Valid {
+		variant.ComparePrice = &comparePrice.Decimal
+	}
+	if costPrice.Valid {
+		variant.CostPrice = &costPrice.Decimal
+	}
+	variant.Stock = int(stock.Int64)
+	variant.ReservedStock = int(reservedStock.Int64)
+	variant.Weight = weight.Float64
+	variant.ImageURL = imageURL.String
+	variant.IsDefault = isDefault.Bool
+
+	return variant, nil
+}
+
+func marshalVariantJSON(variant *models.ProductVariant) (attributesJSON, pricesJSON []byte, err error) {
+	attributes := variant.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	if attributesJSON, err = json.Marshal(attributes); err != nil {
+		return nil, nil, utils.NewInternalError("failed to marshal variant attributes", err)
+	}
+	if pricesJSON, err = marshalPrices(variant.Prices); err != nil {
+		return nil, nil, utils.NewInternalError("failed to marshal variant prices", err)
+	}
+	return attributesJSON, pricesJSON, nil
+}
+
+func nullableDecimal(d *decimal.Decimal) interface{} {
+	if d == nil {
+		return nil
+	}
+	return *d
+}
@@ -0,0 +1,532 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+const warehouseColumns = `id, code, name, street, city, state, postal_code, country,
+	priority, is_active, created_at, updated_at`
+
+// allocatedStock returns the SQL adding up the units of the active
+// reservations r allocated to the warehouse stock row ws that have not been
+// committed yet. conditions further restricts the reservations.
+func allocatedStock(conditions string) string {
+	return `COALESCE((
+		SELECT SUM(a.quantity - a.committed_quantity)
+		FROM stock_allocations a
+		JOIN stock_reservations r ON r.id = a.reservation_id
+		WHERE a.warehouse_id = ws.warehouse_id AND r.product_id = ws.product_id
+			AND COALESCE(r.variant_id, '') = COALESCE(ws.variant_id, '') AND r.status = 'active'` + conditions + `
+	), 0)`
+}
+
+type warehouseRepository struct {
+	db       *sql.DB
+	products *productRepository // records the inventory movements of warehouse stock
+}
+
+// NewWarehouseRepository creates a new warehouse repository
+func NewWarehouseRepository(db *sql.DB) WarehouseRepository {
+	return &warehouseRepository{
+		db:       db,
+		products: &productRepository{db: db, outbox: events.NewPostgresOutbox(db)},
+	}
+}
+
+// Create creates a new warehouse
+func (r *warehouseRepository) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	query := `
+		INSERT INTO warehouses (` + warehouseColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	address := warehouse.Address
+	_, err := r.db.ExecContext(ctx, query,
+		warehouse.ID, warehouse.Code, warehouse.Name, address.Street, address.City, address.State,
+		address.PostalCode, address.Country, warehouse.Priority, warehouse.IsActive,
+		warehouse.CreatedAt, warehouse.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return utils.NewConflictError("warehouse with this code already exists")
+		}
+		return utils.NewInternalError("failed to create warehouse", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a warehouse by ID
+func (r *warehouseRepository) GetByID(ctx context.Context, id string) (*models.Warehouse, error) {
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE id = $1`
+	return scanWarehouse(r.db.QueryRowContext(ctx, query, id))
+}
+
+// Update updates a warehouse
+func (r *warehouseRepository) Update(ctx context.Context, warehouse *models.Warehouse) error {
+	warehouse.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE warehouses
+		SET code = $2, name = $3, street = $4, city = $5, state = $6, postal_code = $7,
+			country = $8, priority = $9, is_active = $10, updated_at = $11
+		WHERE id = $1`
+
+	address := warehouse.Address
+	result, err := r.db.ExecContext(ctx, query,
+		warehouse.ID, warehouse.Code, warehouse.Name, address.Street, address.City, address.State,
+		address.PostalCode, address.Country, warehouse.Priority, warehouse.IsActive, warehouse.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return utils.NewConflictError("warehouse with this code already exists")
+		}
+		return utils.NewInternalError("failed to update warehouse", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.NewInternalError("failed to get rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("warehouse")
+	}
+
+	return nil
+}
+
+// List returns warehouses in priority order
+func (r *warehouseRepository) List(ctx context.Context, activeOnly bool) ([]*models.Warehouse, error) {
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses`
+	if activeOnly {
+		query += ` WHERE is_active`
+	}
+	query += ` ORDER BY priority, code`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list warehouses", err)
+	}
+	defer rows.Close()
+
+	var warehouses []*models.Warehouse
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to list warehouses", err)
+	}
+
+	return warehouses, nil
+}
+
+// ListStock returns the stock kept at warehouses, with the units allocated
+// to active reservations
+func (r *warehouseRepository) ListStock(ctx context.Context, filter WarehouseStockFilter) ([]*models.WarehouseStock, error) {
+	var conditions []string
+	var args []interface{}
+
+	excluded := ""
+	if len(filter.ExcludeReservationIDs) > 0 {
+		args = append(args, pq.Array(filter.ExcludeReservationIDs))
+		excluded = fmt.Sprintf(" AND r.id <> ALL($%d)", len(args))
+	}
+
+	if filter.WarehouseID != "" {
+		args = append(args, filter.WarehouseID)
+		conditions = append(conditions, fmt.Sprintf("ws.warehouse_id = $%d", len(args)))
+	}
+	if filter.ProductIDs != nil {
+		args = append(args, pq.Array(filter.ProductIDs))
+		conditions = append(conditions, fmt.Sprintf("ws.product_id = ANY($%d)", len(args)))
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT ws.warehouse_id, ws.product_id, ws.variant_id, ws.stock,
+			` + allocatedStock(excluded) + `,
+			ws.updated_at
+		FROM warehouse_stock ws ` + whereClause + `
+		ORDER BY ws.warehouse_id, ws.product_id, ws.variant_id NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list warehouse stock", err)
+	}
+	defer rows.Close()
+
+	var levels []*models.WarehouseStock
+	for rows.Next() {
+		var level models.WarehouseStock
+		var variantID sql.NullString
+		err := rows.Scan(&level.WarehouseID, &level.ProductID, &variantID, &level.Stock,
+			&level.AllocatedStock, &level.UpdatedAt)
+		if err != nil {
+			return nil, utils.NewInternalError("failed to scan warehouse stock", err)
+		}
+		level.VariantID = variantID.String
+		levels = append(levels, &level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to list warehouse stock", err)
+	}
+
+	return levels, nil
+}
+
+// MoveStock records an in or out movement at a warehouse. It also changes
+// the stock of the product, and of the variant if the update has one.
+func (r *warehouseRepository) MoveStock(ctx context.Context, warehouseID string, update StockUpdate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMovementStock(ctx, tx, update.ProductID, update.VariantID); err != nil {
+		return err
+	}
+
+	if update.Type == "out" {
+		stock, err := lockWarehouseStock(ctx, tx, warehouseID, update.ProductID, update.VariantID)
+		if err != nil {
+			return err
+		}
+		if update.Quantity > stock {
+			return utils.NewConflictError(fmt.Sprintf("insufficient stock at warehouse: %d in stock", stock))
+		}
+	}
+
+	err = r.products.executeStockMovementTx(ctx, tx, stockMovement{
+		ProductID:     update.ProductID,
+		VariantID:     update.VariantID,
+		WarehouseID:   warehouseID,
+		Quantity:      update.Quantity,
+		Type:          update.Type,
+		Reason:        update.Reason,
+		ReferenceType: update.ReferenceType,
+		ReferenceID:   update.ReferenceID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// Transfer records a transfer as an out movement at its source and an in
+// movement at its destination. The product's stock does not change, so no
+// inventory event is published. Units allocated to reservations at the
+// source cannot be transferred.
+func (r *warehouseRepository) Transfer(ctx context.Context, transfer *models.StockTransfer) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMovementStock(ctx, tx, transfer.ProductID, transfer.VariantID); err != nil {
+		return err
+	}
+
+	var available int
+	if transfer.FromWarehouseID != "" {
+		available, err = availableWarehouseStock(ctx, tx, transfer.FromWarehouseID, transfer.ProductID, transfer.VariantID)
+	} else {
+		available, err = unassignedStock(ctx, tx, transfer.ProductID, transfer.VariantID)
+	}
+	if err != nil {
+		return err
+	}
+	if transfer.Quantity > available {
+		return utils.NewConflictError(fmt.Sprintf("insufficient stock to transfer: %d available", available))
+	}
+
+	transfer.CreatedBy = movementActor(ctx)
+	transfer.CreatedAt = time.Now()
+
+	movement := stockMovement{
+		ProductID:     transfer.ProductID,
+		VariantID:     transfer.VariantID,
+		Quantity:      transfer.Quantity,
+		Reason:        transfer.Reason,
+		ReferenceType: "transfer",
+		ReferenceID:   transfer.ID,
+	}
+
+	out := movement
+	out.Type = "out"
+	out.WarehouseID = transfer.FromWarehouseID
+	if err := recordMovementTx(ctx, tx, out); err != nil {
+		return err
+	}
+
+	in := movement
+	in.Type = "in"
+	in.WarehouseID = transfer.ToWarehouseID
+	if err := recordMovementTx(ctx, tx, in); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO stock_transfers (
+			id, from_warehouse_id, to_warehouse_id, product_id, variant_id, quantity, reason, created_by, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = tx.ExecContext(ctx, query,
+		transfer.ID, nullableString(transfer.FromWarehouseID), transfer.ToWarehouseID, transfer.ProductID,
+		nullableString(transfer.VariantID), transfer.Quantity, transfer.Reason, transfer.CreatedBy, transfer.CreatedAt,
+	)
+	if err != nil {
+		return utils.NewInternalError("failed to record stock transfer", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// Allocate replaces the allocations of the reservations. Reservations are
+// locked in product order, before the warehouse stock they are allocated
+// from, to keep the lock order of reserving and committing stock.
+func (r *warehouseRepository) Allocate(ctx context.Context, reservations []*models.StockReservation, allocations []*models.StockAllocation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.NewInternalError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	sorted := make([]*models.StockReservation, len(reservations))
+	copy(sorted, reservations)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ProductID != sorted[j].ProductID {
+			return sorted[i].ProductID < sorted[j].ProductID
+		}
+		return sorted[i].VariantID < sorted[j].VariantID
+	})
+
+	for _, reservation := range sorted {
+		key := keyOf(reservation)
+		if _, err := lockAvailableStock(ctx, tx, key.ProductID, key.VariantID); err != nil {
+			return err
+		}
+		current, err := getReservationForUpdate(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if current == nil || !current.IsActive() || current.Quantity != reservation.Quantity {
+			return utils.NewConflictError("stock reservation changed while it was being allocated")
+		}
+		if err := deleteAllocationsTx(ctx, tx, reservation.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, allocation := range allocations {
+		available, err := availableWarehouseStock(ctx, tx, allocation.WarehouseID, allocation.ProductID, allocation.VariantID)
+		if err != nil {
+			return err
+		}
+		if allocation.Quantity > available {
+			return utils.NewConflictError(fmt.Sprintf("insufficient stock at warehouse: %d available", available))
+		}
+
+		if allocation.ID == "" {
+			allocation.ID = uuid.New().String()
+		}
+		allocation.CreatedAt = time.Now()
+		allocation.UpdatedAt = allocation.CreatedAt
+
+		query := `
+			INSERT INTO stock_allocations (id, reservation_id, warehouse_id, quantity, committed_quantity, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+		_, err = tx.ExecContext(ctx, query,
+			allocation.ID, allocation.ReservationID, allocation.WarehouseID, allocation.Quantity,
+			allocation.CommittedQuantity, allocation.CreatedAt, allocation.UpdatedAt,
+		)
+		if err != nil {
+			return utils.NewInternalError("failed to create stock allocation", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewInternalError("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// ListAllocations returns the allocations of the reservations
+func (r *warehouseRepository) ListAllocations(ctx context.Context, reservationIDs []string) ([]*models.StockAllocation, error) {
+	query := `
+		SELECT a.id, a.reservation_id, a.warehouse_id, r.product_id, r.variant_id, a.quantity,
+			   a.committed_quantity, a.created_at, a.updated_at
+		FROM stock_allocations a
+		JOIN stock_reservations r ON r.id = a.reservation_id
+		WHERE a.reservation_id = ANY($1)
+		ORDER BY a.created_at, a.id`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(reservationIDs))
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list stock allocations", err)
+	}
+	defer rows.Close()
+
+	return scanAllocations(rows)
+}
+
+// lockWarehouseStock locks the stock of a product or variant at a warehouse
+// and returns it; a warehouse that never had any has none
+func lockWarehouseStock(ctx context.Context, tx *sql.Tx, warehouseID, productID, variantID string) (int, error) {
+	var stock int
+	err := tx.QueryRowContext(ctx, `
+		SELECT stock FROM warehouse_stock
+		WHERE warehouse_id = $1 AND product_id = $2 AND COALESCE(variant_id, '') = $3
+		FOR UPDATE`, warehouseID, productID, variantID).Scan(&stock)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, utils.NewInternalError("failed to lock warehouse stock", err)
+	}
+
+	return stock, nil
+}
+
+// availableWarehouseStock locks the stock of a product or variant at a
+// warehouse and returns the units not allocated to active reservations
+func availableWarehouseStock(ctx context.Context, tx *sql.Tx, warehouseID, productID, variantID string) (int, error) {
+	stock, err := lockWarehouseStock(ctx, tx, warehouseID, productID, variantID)
+	if err != nil || stock == 0 {
+		return 0, err
+	}
+
+	var allocated int
+	err = tx.QueryRowContext(ctx, `
+		SELECT `+allocatedStock("")+`
+		FROM warehouse_stock ws
+		WHERE ws.warehouse_id = $1 AND ws.product_id = $2 AND COALESCE(ws.variant_id, '') = $3`,
+		warehouseID, productID, variantID).Scan(&allocated)
+	if err != nil {
+		return 0, utils.NewInternalError("failed to read allocated warehouse stock", err)
+	}
+
+	return max(stock-allocated, 0), nil
+}
+
+// unassignedStock returns the stock of a product or variant that is not kept
+// at any warehouse. The caller must hold the product lock.
+func unassignedStock(ctx context.Context, tx *sql.Tx, productID, variantID string) (int, error) {
+	query := `
+		SELECT p.stock - COALESCE((SELECT SUM(stock) FROM warehouse_stock WHERE product_id = p.id), 0)
+		FROM products p WHERE p.id = $1`
+	args := []interface{}{productID}
+	if variantID != "" {
+		query = `
+			SELECT v.stock - COALESCE((SELECT SUM(stock) FROM warehouse_stock WHERE variant_id = v.id), 0)
+			FROM product_variants v WHERE v.id = $1`
+		args = []interface{}{variantID}
+	}
+
+	var stock int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&stock); err != nil {
+		return 0, utils.NewInternalError("failed to read unassigned stock", err)
+	}
+
+	return max(stock, 0), nil
+}
+
+// listAllocationsForUpdate locks and returns a reservation's allocations in
+// the order they were made
+func listAllocationsForUpdate(ctx context.Context, tx *sql.Tx, reservationID string) ([]*models.StockAllocation, error) {
+	query := `
+		SELECT a.id, a.reservation_id, a.warehouse_id, r.product_id, r.variant_id, a.quantity,
+			   a.committed_quantity, a.created_at, a.updated_at
+		FROM stock_allocations a
+		JOIN stock_reservations r ON r.id = a.reservation_id
+		WHERE a.reservation_id = $1
+		ORDER BY a.created_at, a.id
+		FOR UPDATE OF a`
+
+	rows, err := tx.QueryContext(ctx, query, reservationID)
+	if err != nil {
+		return nil, utils.NewInternalError("failed to list stock allocations", err)
+	}
+	defer rows.Close()
+
+	return scanAllocations(rows)
+}
+
+// deleteAllocationsTx drops a reservation's allocations
+func deleteAllocationsTx(ctx context.Context, tx *sql.Tx, reservationID string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM stock_allocations WHERE reservation_id = $1`, reservationID); err != nil {
+		return utils.NewInternalError("failed to delete stock allocations", err)
+	}
+	return nil
+}
+
+func scanAllocations(rows *sql.Rows) ([]*models.StockAllocation, error) {
+	var allocations []*models.StockAllocation
+	for rows.Next() {
+		var allocation models.StockAllocation
+		var variantID sql.NullString
+		err := rows.Scan(
+			&allocation.ID, &allocation.ReservationID, &allocation.WarehouseID, &allocation.ProductID, &variantID,
+			&allocation.Quantity, &allocation.CommittedQuantity, &allocation.CreatedAt, &allocation.UpdatedAt,
+		)
+		if err != nil {
+			return nil, utils.NewInternalError("failed to scan stock allocation", err)
+		}
+		allocation.VariantID = variantID.String
+		allocations = append(allocations, &allocation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.NewInternalError("failed to list stock allocations", err)
+	}
+
+	return allocations, nil
+}
+
+func scanWarehouse(row rowScanner) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	address := &warehouse.Address
+
+	err := row.Scan(
+		&warehouse.ID, &warehouse.Code, &warehouse.Name, &address.Street, &address.City, &address.State,
+		&address.PostalCode, &address.Country, &warehouse.Priority, &warehouse.IsActive,
+		&warehouse.CreatedAt, &warehouse.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("warehouse")
+		}
+		return nil, utils.NewInternalError("failed to scan warehouse", err)
+	}
+
+	return &warehouse, nil
+}
@@ -70,6 +70,19 @@ type ProductDocument struct {
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 	Custom      map[string]interface{} `json:"custom"`
+	Variants    []VariantDocument      `json:"variants"`
+}
+
+// VariantDocument represents a product variant nested in a product document
+type VariantDocument struct {
+	ID         string                 `json:"id"`
+	SKU        string                 `json:"sku"`
+	Name       string                 `json:"name"`
+	Price      float64                `json:"price"`
+	Stock      int                    `json:"stock"`
+	Color      string                 `json:"color"`
+	Size       string                 `json:"size"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 // SearchRequest represents a search request
@@ -166,7 +179,20 @@ func (es *ElasticsearchClient) initializeIndices() error {
 				"featured": {"type": "boolean"},
 				"created_at": {"type": "date"},
 				"updated_at": {"type": "date"},
-				"custom": {"type": "object", "dynamic": true}
+				"custom": {"type": "object", "dynamic": true},
+				"variants": {
+					"type": "nested",
+					"properties": {
+						"id": {"type": "keyword"},
+						"sku": {"type": "keyword"},
+						"name": {"type": "text", "analyzer": "standard"},
+						"price": {"type": "double"},
+						"stock": {"type": "integer"},
+						"color": {"type": "keyword"},
+						"size": {"type": "keyword"},
+						"attributes": {"type": "object", "dynamic": true}
+					}
+				}
 			}
 		},
 		"settings": {
@@ -382,6 +408,22 @@ func (es *ElasticsearchClient) GetSearchSuggestions(ctx context.Context, query s
 func (es *ElasticsearchClient) productToDocument(product *models.Product) *ProductDocument {
 	price, _ := product.Price.Float64()
 	
+	variants := make([]VariantDocument, 0, len(product.Variants))
+	for i := range product.Variants {
+		variant := &product.Variants[i]
+		variantPrice, _ := variant.Price.Float64()
+		variants = append(variants, VariantDocument{
+			ID:         variant.ID,
+			SKU:        variant.SKU,
+			Name:       variant.Name,
+			Price:      variantPrice,
+			Stock:      variant.Stock,
+			Color:      variant.OptionValue("color"),
+			Size:       variant.OptionValue("size"),
+			Attributes: variant.Attributes,
+		})
+	}
+	
 	return &ProductDocument{
 		ID:          product.ID,
 		SKU:         product.SKU,
@@ -401,6 +443,7 @@ func (es *ElasticsearchClient) productToDocument(product *models.Product) *Produ
 		CreatedAt:   product.CreatedAt,
 		UpdatedAt:   product.UpdatedAt,
 		Custom:      product.Attributes.Custom,
+		Variants:    variants,
 	}
 }
 
@@ -415,16 +458,29 @@ func (es *ElasticsearchClient) buildSearchQuery(req SearchRequest) map[string]in
 	var boolQuery map[string]interface{}
 	
 	if req.Query != "" {
-		// Multi-match query for text search
+		// Multi-match query for text search, also matching variant names and SKUs
 		boolQuery = map[string]interface{}{
 			"bool": map[string]interface{}{
 				"must": []interface{}{
 					map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":  req.Query,
-							"fields": []string{"name^3", "description^2", "brand", "sku"},
-							"type":   "best_fields",
-							"fuzziness": "AUTO",
+						"bool": map[string]interface{}{
+							"should": []interface{}{
+								map[string]interface{}{
+									"multi_match": map[string]interface{}{
+										"query":  req.Query,
+										"fields": []string{"name^3", "description^2", "brand", "sku"},
+										"type":   "best_fields",
+										"fuzziness": "AUTO",
+									},
+								},
+								nestedVariantQuery(map[string]interface{}{
+									"multi_match": map[string]interface{}{
+										"query":  req.Query,
+										"fields": []string{"variants.name", "variants.sku"},
+									},
+								}),
+							},
+							"minimum_should_match": 1,
 						},
 					},
 				},
@@ -448,12 +504,31 @@ func (es *ElasticsearchClient) buildSearchQuery(req SearchRequest) map[string]in
 		
 		for field, value := range req.Filters {
 			switch field {
-			case "category_id", "status", "brand", "color", "size":
+			case "category_id", "status", "brand":
 				filters = append(filters, map[string]interface{}{
 					"term": map[string]interface{}{
 						field: value,
 					},
 				})
+			case "color", "size":
+				// Matches the product itself or any of its variants
+				filters = append(filters, map[string]interface{}{
+					"bool": map[string]interface{}{
+						"should": []interface{}{
+							map[string]interface{}{
+								"term": map[string]interface{}{
+									field: value,
+								},
+							},
+							nestedVariantQuery(map[string]interface{}{
+								"term": map[string]interface{}{
+									"variants." + field: value,
+								},
+							}),
+						},
+						"minimum_should_match": 1,
+					},
+				})
 			case "price_min":
 				if priceMin, ok := value.(float64); ok {
 					filters = append(filters, map[string]interface{}{
@@ -669,6 +744,18 @@ func (es *ElasticsearchClient) parseSuggestionResponse(result map[string]interfa
 	return suggestions, nil
 }
 
+// nestedVariantQuery wraps a query on variant fields. Indices created before
+// variants were indexed have no nested mapping, which the query tolerates.
+func nestedVariantQuery(query map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":            "variants",
+			"query":           query,
+			"ignore_unmapped": true,
+		},
+	}
+}
+
 // documentToProduct converts an Elasticsearch document to a product model
 func (es *ElasticsearchClient) documentToProduct(doc map[string]interface{}) (*models.Product, error) {
 	product := &models.Product{}
@@ -734,6 +821,28 @@ func (es *ElasticsearchClient) documentToProduct(doc map[string]interface{}) (*m
 		product.Attributes.Custom = custom
 	}
 
+	// Handle variants
+	if variants, ok := doc["variants"].([]interface{}); ok {
+		for _, v := range variants {
+			variantDoc, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			variant := models.ProductVariant{ProductID: product.ID}
+			variant.ID, _ = variantDoc["id"].(string)
+			variant.SKU, _ = variantDoc["sku"].(string)
+			variant.Name, _ = variantDoc["name"].(string)
+			if price, ok := variantDoc["price"].(float64); ok {
+				variant.Price = decimal.NewFromFloat(price)
+			}
+			if stock, ok := variantDoc["stock"].(float64); ok {
+				variant.Stock = int(stock)
+			}
+			variant.Attributes, _ = variantDoc["attributes"].(map[string]interface{})
+			product.Variants = append(product.Variants, variant)
+		}
+	}
+
 	// Handle timestamps
 	if createdAt, ok := doc["created_at"].(string); ok {
 		if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
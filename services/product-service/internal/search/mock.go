@@ -85,7 +85,9 @@ func (m *MockElasticsearchClient) GetSearchSuggestions(ctx context.Context, quer
 
 func (m *MockElasticsearchClient) matchesQuery(product *models.Product, req SearchRequest) bool {
 	if req.Query != "" {
-		if !contains(product.Name, req.Query) && !contains(product.Description, req.Query) {
+		if !contains(product.Name, req.Query) && !contains(product.Description, req.Query) && !variantMatches(product, func(v *models.ProductVariant) bool {
+			return contains(v.Name, req.Query) || contains(v.SKU, req.Query)
+		}) {
 			return false
 		}
 	}
@@ -105,12 +107,31 @@ func (m *MockElasticsearchClient) matchesQuery(product *models.Product, req Sear
 			if product.Attributes.Brand != value.(string) {
 				return false
 			}
+		case "color", "size":
+			productValue := product.Attributes.Color
+			if field == "size" {
+				productValue = product.Attributes.Size
+			}
+			if productValue != value.(string) && !variantMatches(product, func(v *models.ProductVariant) bool {
+				return v.OptionValue(field) == value.(string)
+			}) {
+				return false
+			}
 		}
 	}
 	
 	return true
 }
 
+func variantMatches(product *models.Product, match func(*models.ProductVariant) bool) bool {
+	for i := range product.Variants {
+		if match(&product.Variants[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 func contains(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
\ No newline at end of file
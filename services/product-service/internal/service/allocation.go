@@ -0,0 +1,162 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// stockItem identifies a product, or one of its variants
+type stockItem struct {
+	productID string
+	variantID string
+}
+
+// planAllocation assigns lines to the warehouses that will ship them.
+// levels holds the stock kept at the warehouses; only their available units
+// are allocated. Units no warehouse has are returned as unallocated.
+func planAllocation(strategy models.AllocationStrategy, shipTo models.Address, warehouses []*models.Warehouse,
+	levels []*models.WarehouseStock, lines []models.AllocationLine) ([]models.AllocationShipment, []models.AllocationLine) {
+	available := make(map[string]map[stockItem]int, len(warehouses))
+	for _, level := range levels {
+		if available[level.WarehouseID] == nil {
+			available[level.WarehouseID] = make(map[stockItem]int)
+		}
+		available[level.WarehouseID][stockItem{level.ProductID, level.VariantID}] += level.Available()
+	}
+
+	// Lines for the same item are allocated together
+	var items []stockItem
+	remaining := make(map[stockItem]int, len(lines))
+	for _, line := range lines {
+		item := stockItem{line.ProductID, line.VariantID}
+		if _, ok := remaining[item]; !ok {
+			items = append(items, item)
+		}
+		remaining[item] += line.Quantity
+	}
+
+	ranked := rankWarehouses(strategy, shipTo, warehouses)
+	assigned := make(map[string]map[stockItem]int, len(ranked))
+	assign := func(warehouse *models.Warehouse, item stockItem) {
+		take := min(available[warehouse.ID][item], remaining[item])
+		if take <= 0 {
+			return
+		}
+		if assigned[warehouse.ID] == nil {
+			assigned[warehouse.ID] = make(map[stockItem]int)
+		}
+		assigned[warehouse.ID][item] += take
+		available[warehouse.ID][item] -= take
+		remaining[item] -= take
+	}
+
+	if strategy == models.AllocationFewestSplits {
+		// Repeatedly ship from the warehouse that covers the most of what is
+		// left, so a warehouse that has everything ships on its own
+		candidates := ranked
+		for len(candidates) > 0 {
+			best, bestUnits := 0, 0
+			for i, warehouse := range candidates {
+				units := 0
+				for _, item := range items {
+					units += min(available[warehouse.ID][item], remaining[item])
+				}
+				if units > bestUnits {
+					best, bestUnits = i, units
+				}
+			}
+			if bestUnits == 0 {
+				break
+			}
+			for _, item := range items {
+				assign(candidates[best], item)
+			}
+			candidates = append(candidates[:best:best], candidates[best+1:]...)
+		}
+	} else {
+		for _, item := range items {
+			for _, warehouse := range ranked {
+				assign(warehouse, item)
+			}
+		}
+	}
+
+	var shipments []models.AllocationShipment
+	for _, warehouse := range ranked {
+		if assigned[warehouse.ID] == nil {
+			continue
+		}
+		shipment := models.AllocationShipment{Warehouse: warehouse}
+		for _, item := range items {
+			if quantity := assigned[warehouse.ID][item]; quantity > 0 {
+				shipment.Lines = append(shipment.Lines, models.AllocationLine{
+					ProductID: item.productID,
+					VariantID: item.variantID,
+					Quantity:  quantity,
+				})
+			}
+		}
+		shipments = append(shipments, shipment)
+	}
+
+	var unallocated []models.AllocationLine
+	for _, item := range items {
+		if remaining[item] > 0 {
+			unallocated = append(unallocated, models.AllocationLine{
+				ProductID: item.productID,
+				VariantID: item.variantID,
+				Quantity:  remaining[item],
+			})
+		}
+	}
+
+	return shipments, unallocated
+}
+
+// rankWarehouses orders warehouses by preference. The priority strategy
+// follows warehouse priority; the others prefer warehouses nearer the
+// shipping address and fall back to priority between equally near ones.
+func rankWarehouses(strategy models.AllocationStrategy, shipTo models.Address, warehouses []*models.Warehouse) []*models.Warehouse {
+	ranked := make([]*models.Warehouse, len(warehouses))
+	copy(ranked, warehouses)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if strategy != models.AllocationPriority {
+			di, dj := distanceBand(shipTo, ranked[i].Address), distanceBand(shipTo, ranked[j].Address)
+			if di != dj {
+				return di < dj
+			}
+		}
+		return ranked[i].Priority < ranked[j].Priority
+	})
+
+	return ranked
+}
+
+// distanceBand estimates how far apart two addresses are without geocoding
+// them: 0 for the same postal code, 1 for the same postal area (the first
+// three characters of the code), 2 for the same state, 3 for the same
+// country and 4 otherwise
+func distanceBand(a, b models.Address) int {
+	normalize := func(s string) string {
+		return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	}
+
+	country := normalize(a.Country)
+	if country == "" || country != normalize(b.Country) {
+		return 4
+	}
+
+	postalA, postalB := normalize(a.PostalCode), normalize(b.PostalCode)
+	switch {
+	case postalA != "" && postalA == postalB:
+		return 0
+	case len(postalA) >= 3 && len(postalB) >= 3 && postalA[:3] == postalB[:3]:
+		return 1
+	case a.State != "" && normalize(a.State) == normalize(b.State):
+		return 2
+	}
+	return 3
+}
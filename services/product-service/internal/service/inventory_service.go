@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// movementTypes are the movement types recorded in the inventory ledger
+var movementTypes = map[string]bool{
+	"in":         true,
+	"out":        true,
+	"adjustment": true,
+	"reserved":   true,
+	"released":   true,
+}
+
+// InventoryService handles reads of the inventory ledger
+type InventoryService struct {
+	inventoryRepo repository.InventoryRepository
+}
+
+// NewInventoryService creates a new inventory service
+func NewInventoryService(inventoryRepo repository.InventoryRepository) *InventoryService {
+	return &InventoryService{
+		inventoryRepo: inventoryRepo,
+	}
+}
+
+// ListMovements retrieves inventory movements matching the request, newest first
+func (s *InventoryService) ListMovements(ctx context.Context, req GetInventoryMovementsRequest) (*GetInventoryMovementsResponse, error) {
+	if req.MovementType != "" && !movementTypes[req.MovementType] {
+		return nil, utils.NewValidationError("invalid movement type")
+	}
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return nil, utils.NewValidationError("from must be before to")
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	movements, total, err := s.inventoryRepo.ListMovements(ctx, repository.MovementFilter{
+		ProductID:     req.ProductID,
+		VariantID:     req.VariantID,
+		MovementType:  req.MovementType,
+		ReferenceType: req.ReferenceType,
+		ReferenceID:   req.ReferenceID,
+		CreatedBy:     req.CreatedBy,
+		From:          req.From,
+		To:            req.To,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if movements == nil {
+		movements = []*models.InventoryMovement{}
+	}
+
+	return &GetInventoryMovementsResponse{
+		Movements: movements,
+		Total:     total,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	}, nil
+}
+
+// AuditStock recomputes stock from the inventory ledger and reports the
+// products and variants whose stored stock has drifted from it
+func (s *InventoryService) AuditStock(ctx context.Context) (*StockAuditReport, error) {
+	drifts, checked, err := s.inventoryRepo.AuditStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if drifts == nil {
+		drifts = []*models.StockDrift{}
+	}
+
+	return &StockAuditReport{
+		Checked: checked,
+		Drifts:  drifts,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Mock inventory repository that records the last filter it was given
+type mockInventoryRepository struct {
+	movements []*models.InventoryMovement
+	drifts    []*models.StockDrift
+	checked   int
+	filter    repository.MovementFilter
+}
+
+func (m *mockInventoryRepository) ListMovements(ctx context.Context, filter repository.MovementFilter) ([]*models.InventoryMovement, int, error) {
+	m.filter = filter
+	var movements []*models.InventoryMovement
+	for _, movement := range m.movements {
+		if filter.ProductID != "" && movement.ProductID != filter.ProductID {
+			continue
+		}
+		if filter.MovementType != "" && movement.MovementType != filter.MovementType {
+			continue
+		}
+		movements = append(movements, movement)
+	}
+	return movements, len(movements), nil
+}
+
+func (m *mockInventoryRepository) AuditStock(ctx context.Context) ([]*models.StockDrift, int, error) {
+	return m.drifts, m.checked, nil
+}
+
+func TestInventoryService_ListMovements(t *testing.T) {
+	repo := &mockInventoryRepository{
+		movements: []*models.InventoryMovement{
+			{ID: "m1", ProductID: "prod1", MovementType: "in", Quantity: 10, CreatedBy: "user:admin"},
+			{ID: "m2", ProductID: "prod1", MovementType: "reserved", Quantity: 2, ReferenceType: "order", ReferenceID: "order1", CreatedBy: "service:order-service"},
+			{ID: "m3", ProductID: "prod2", MovementType: "in", Quantity: 5, CreatedBy: "user:admin"},
+		},
+	}
+	service := NewInventoryService(repo)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	response, err := service.ListMovements(context.Background(), GetInventoryMovementsRequest{
+		ProductID:    "prod1",
+		MovementType: "reserved",
+		From:         &from,
+		To:           &to,
+		Limit:        500,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Total != 1 || response.Movements[0].ID != "m2" {
+		t.Errorf("Expected only movement m2, got %+v", response.Movements)
+	}
+	if response.Limit != 100 || repo.filter.Limit != 100 {
+		t.Errorf("Expected the limit to be capped at 100, got %d", response.Limit)
+	}
+	if repo.filter.From != &from || repo.filter.To != &to {
+		t.Errorf("Expected the date range to be passed to the repository")
+	}
+}
+
+func TestInventoryService_ListMovements_ValidationError(t *testing.T) {
+	service := NewInventoryService(&mockInventoryRepository{})
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		req  GetInventoryMovementsRequest
+	}{
+		{"unknown movement type", GetInventoryMovementsRequest{MovementType: "stolen"}},
+		{"from after to", GetInventoryMovementsRequest{From: &from, To: &to}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ListMovements(context.Background(), tt.req)
+			if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrValidation {
+				t.Errorf("Expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInventoryService_AuditStock(t *testing.T) {
+	repo := &mockInventoryRepository{checked: 3}
+	service := NewInventoryService(repo)
+
+	report, err := service.AuditStock(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Checked != 3 || report.Drifts == nil || len(report.Drifts) != 0 {
+		t.Errorf("Expected a clean report of 3 items, got %+v", report)
+	}
+
+	repo.drifts = []*models.StockDrift{{ProductID: "prod1", SKU: "SKU-1", Stock: 8, LedgerStock: 10}}
+	report, err = service.AuditStock(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Drifts) != 1 || !report.Drifts[0].HasDrift() {
+		t.Errorf("Expected one drifted product, got %+v", report.Drifts)
+	}
+}
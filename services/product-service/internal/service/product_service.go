@@ -8,23 +8,28 @@ import (
 
 	"github.com/shopsphere/product-service/internal/repository"
 	"github.com/shopsphere/product-service/internal/search"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
 )
 
 // ProductService handles product business logic
 type ProductService struct {
 	productRepo     repository.ProductRepository
 	categoryRepo    repository.CategoryRepository
+	variantRepo     repository.VariantRepository
 	searchService   search.SearchService
 	analyticsService search.SearchAnalytics
 }
 
-// NewProductService creates a new product service
-func NewProductService(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository, searchService search.SearchService, analyticsService search.SearchAnalytics) *ProductService {
+// NewProductService creates a new product service. Without a variant
+// repository products are served and indexed without their variants.
+func NewProductService(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository, variantRepo repository.VariantRepository, searchService search.SearchService, analyticsService search.SearchAnalytics) *ProductService {
 	return &ProductService{
 		productRepo:      productRepo,
 		categoryRepo:     categoryRepo,
+		variantRepo:      variantRepo,
 		searchService:    searchService,
 		analyticsService: analyticsService,
 	}
@@ -46,11 +51,27 @@ func (s *ProductService) CreateProduct(ctx context.Context, req CreateProductReq
 	}
 	
 	// Create product
+	var err error
 	product := models.NewProduct(req.SKU, req.Name, req.Description, req.CategoryID, req.Price)
-	product.Currency = req.Currency
+	if req.Currency != "" {
+		product.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	}
+	if err := validateProductPricing(product.Currency, product.Price, req.Prices); err != nil {
+		return nil, err
+	}
+	product.Prices = normalizePriceList(req.Prices)
+	if req.Options != nil {
+		if product.Options, err = normalizeOptions(req.Options); err != nil {
+			return nil, err
+		}
+	}
 	product.Stock = req.Stock
 	product.Status = models.ProductStatus(req.Status)
 	product.Images = req.Images
+	product.TaxClass = req.TaxClass
+	if product.TaxClass == "" {
+		product.TaxClass = models.TaxClassStandard
+	}
 	
 	// Set attributes
 	if req.Attributes != nil {
@@ -90,7 +111,12 @@ func (s *ProductService) GetProduct(ctx context.Context, id string) (*models.Pro
 		return nil, utils.NewValidationError("product ID is required")
 	}
 	
-	return s.productRepo.GetByID(ctx, id)
+	product, err := s.productRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	
+	return product, s.attachVariants(ctx, product)
 }
 
 // GetProductBySKU retrieves a product by SKU
@@ -99,7 +125,12 @@ func (s *ProductService) GetProductBySKU(ctx context.Context, sku string) (*mode
 		return nil, utils.NewValidationError("product SKU is required")
 	}
 	
-	return s.productRepo.GetBySKU(ctx, sku)
+	product, err := s.productRepo.GetBySKU(ctx, sku)
+	if err != nil {
+		return nil, err
+	}
+	
+	return product, s.attachVariants(ctx, product)
 }
 
 // UpdateProduct updates a product
@@ -142,7 +173,13 @@ func (s *ProductService) UpdateProduct(ctx context.Context, id string, req Updat
 		product.Price = *req.Price
 	}
 	if req.Currency != nil {
-		product.Currency = *req.Currency
+		product.Currency = strings.ToUpper(strings.TrimSpace(*req.Currency))
+	}
+	if req.Prices != nil {
+		product.Prices = normalizePriceList(req.Prices)
+	}
+	if err := validateProductPricing(product.Currency, product.Price, product.Prices); err != nil {
+		return nil, err
 	}
 	if req.Stock != nil {
 		product.Stock = *req.Stock
@@ -153,6 +190,24 @@ func (s *ProductService) UpdateProduct(ctx context.Context, id string, req Updat
 	if req.Images != nil {
 		product.Images = req.Images
 	}
+	if req.TaxClass != nil && *req.TaxClass != "" {
+		product.TaxClass = *req.TaxClass
+	}
+	if req.Options != nil {
+		if product.Options, err = normalizeOptions(req.Options); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Variants must keep a value for every option
+	if err := s.attachVariants(ctx, product); err != nil {
+		return nil, err
+	}
+	for i := range product.Variants {
+		if _, err := variantOptionValues(product.Options, product.Variants[i].Attributes); err != nil {
+			return nil, utils.NewValidationError(fmt.Sprintf("variant %s: %s", product.Variants[i].SKU, err.Error()))
+		}
+	}
 	
 	// Update attributes
 	if req.Attributes != nil {
@@ -273,8 +328,9 @@ func (s *ProductService) ListProducts(ctx context.Context, req ListProductsReque
 	}, nil
 }
 
-// ReserveStock reserves stock for a product (used by cart service)
-func (s *ProductService) ReserveStock(ctx context.Context, productID string, quantity int) error {
+// ReserveStock reserves stock for a product (used by cart service). With a
+// variant ID the variant's own stock must cover the quantity.
+func (s *ProductService) ReserveStock(ctx context.Context, productID, variantID string, quantity int) error {
 	if productID == "" {
 		return utils.NewValidationError("product ID is required")
 	}
@@ -283,20 +339,29 @@ func (s *ProductService) ReserveStock(ctx context.Context, productID string, qua
 	}
 	
 	// Check available stock
-	availableStock, err := s.productRepo.GetAvailableStock(ctx, productID)
-	if err != nil {
-		return err
+	var availableStock int
+	if variantID != "" {
+		variant, err := s.getVariant(ctx, productID, variantID)
+		if err != nil {
+			return err
+		}
+		availableStock = variant.AvailableStock()
+	} else {
+		var err error
+		if availableStock, err = s.productRepo.GetAvailableStock(ctx, productID); err != nil {
+			return err
+		}
 	}
 	
 	if availableStock < quantity {
 		return utils.NewConflictError("insufficient stock available")
 	}
 	
-	return s.productRepo.ReserveStock(ctx, productID, quantity)
+	return s.productRepo.ReserveStock(ctx, productID, variantID, quantity)
 }
 
-// ReleaseStock releases reserved stock
-func (s *ProductService) ReleaseStock(ctx context.Context, productID string, quantity int) error {
+// ReleaseStock releases reserved stock of a product or one of its variants
+func (s *ProductService) ReleaseStock(ctx context.Context, productID, variantID string, quantity int) error {
 	if productID == "" {
 		return utils.NewValidationError("product ID is required")
 	}
@@ -304,14 +369,17 @@ func (s *ProductService) ReleaseStock(ctx context.Context, productID string, qua
 		return utils.NewValidationError("quantity must be positive")
 	}
 	
-	return s.productRepo.ReleaseStock(ctx, productID, quantity)
+	return s.productRepo.ReleaseStock(ctx, productID, variantID, quantity)
 }
 
-// UpdateStock updates product stock
+// UpdateStock sets product stock to a counted quantity
 func (s *ProductService) UpdateStock(ctx context.Context, productID string, quantity int) error {
 	if productID == "" {
 		return utils.NewValidationError("product ID is required")
 	}
+	if quantity < 0 {
+		return utils.NewValidationError("stock cannot be negative")
+	}
 	
 	return s.productRepo.UpdateStock(ctx, productID, quantity)
 }
@@ -339,10 +407,13 @@ func (s *ProductService) BulkUpdateStock(ctx context.Context, updates []BulkStoc
 	repoUpdates := make([]repository.StockUpdate, len(updates))
 	for i, update := range updates {
 		repoUpdates[i] = repository.StockUpdate{
-			ProductID: update.ProductID,
-			Quantity:  update.Quantity,
-			Type:      update.Type,
-			Reason:    update.Reason,
+			ProductID:     update.ProductID,
+			VariantID:     update.VariantID,
+			Quantity:      update.Quantity,
+			Type:          update.Type,
+			Reason:        update.Reason,
+			ReferenceType: update.ReferenceType,
+			ReferenceID:   update.ReferenceID,
 		}
 	}
 	
@@ -564,6 +635,45 @@ func (s *ProductService) validateUpdateProductRequest(req UpdateProductRequest)
 	return nil
 }
 
+// validateProductPricing checks that the base price and every entry of the
+// price list use a supported currency and fit its minor units
+func validateProductPricing(baseCurrency string, price decimal.Decimal, prices models.PriceList) error {
+	base, err := currency.Lookup(baseCurrency)
+	if err != nil {
+		return utils.NewValidationError(err.Error())
+	}
+	if !base.IsRounded(price) {
+		return utils.NewValidationError(fmt.Sprintf("price %s has more decimals than %s allows", price, base.Code))
+	}
+
+	for code, amount := range prices {
+		c, err := currency.Lookup(code)
+		if err != nil {
+			return utils.NewValidationError(err.Error())
+		}
+		if c.Code == base.Code {
+			return utils.NewValidationError(fmt.Sprintf("prices must not repeat the base currency %s", base.Code))
+		}
+		if !amount.IsPositive() {
+			return utils.NewValidationError(fmt.Sprintf("price in %s must be positive", c.Code))
+		}
+		if !c.IsRounded(amount) {
+			return utils.NewValidationError(fmt.Sprintf("price %s has more decimals than %s allows", amount, c.Code))
+		}
+	}
+
+	return nil
+}
+
+// normalizePriceList upper-cases the currency codes of a price list
+func normalizePriceList(prices models.PriceList) models.PriceList {
+	normalized := make(models.PriceList, len(prices))
+	for code, amount := range prices {
+		normalized[strings.ToUpper(strings.TrimSpace(code))] = amount
+	}
+	return normalized
+}
+
 // validateListProductsRequest validates list products request
 func (s *ProductService) validateListProductsRequest(req ListProductsRequest) error {
 	v := utils.NewValidator()
@@ -751,6 +861,10 @@ func (s *ProductService) BulkIndexProducts(ctx context.Context, productIDs []str
 		return utils.NewValidationError("no valid products found for indexing")
 	}
 	
+	if err := s.attachVariants(ctx, products...); err != nil {
+		return err
+	}
+	
 	return s.searchService.BulkIndexProducts(ctx, products)
 }
 
@@ -780,6 +894,9 @@ func (s *ProductService) ReindexAllProducts(ctx context.Context) error {
 		}
 		
 		// Index batch
+		if err := s.attachVariants(ctx, products...); err != nil {
+			return err
+		}
 		if err := s.searchService.BulkIndexProducts(ctx, products); err != nil {
 			return err
 		}
@@ -795,6 +912,47 @@ func (s *ProductService) ReindexAllProducts(ctx context.Context) error {
 	return nil
 }
 
+// attachVariants loads the variants of the given products onto them
+func (s *ProductService) attachVariants(ctx context.Context, products ...*models.Product) error {
+	if s.variantRepo == nil || len(products) == 0 {
+		return nil
+	}
+	
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	
+	variants, err := s.variantRepo.ListByProducts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	
+	for _, product := range products {
+		product.Variants = make([]models.ProductVariant, 0, len(variants[product.ID]))
+		for _, variant := range variants[product.ID] {
+			product.Variants = append(product.Variants, *variant)
+		}
+	}
+	return nil
+}
+
+// getVariant retrieves a variant, treating variants of other products as not found
+func (s *ProductService) getVariant(ctx context.Context, productID, variantID string) (*models.ProductVariant, error) {
+	if s.variantRepo == nil {
+		return nil, utils.NewNotFoundError("product variant")
+	}
+	
+	variant, err := s.variantRepo.GetByID(ctx, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if variant.ProductID != productID {
+		return nil, utils.NewNotFoundError("product variant")
+	}
+	return variant, nil
+}
+
 // convertSortFields converts service sort fields to search sort fields
 func convertSortFields(sorts []SortField) []search.SortField {
 	var searchSorts []search.SortField
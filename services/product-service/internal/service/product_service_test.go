@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/shopspring/decimal"
@@ -97,7 +98,7 @@ func (m *mockProductRepository) UpdateStock(ctx context.Context, productID strin
 	return nil
 }
 
-func (m *mockProductRepository) ReserveStock(ctx context.Context, productID string, quantity int) error {
+func (m *mockProductRepository) ReserveStock(ctx context.Context, productID, variantID string, quantity int) error {
 	product, exists := m.products[productID]
 	if !exists {
 		return utils.NewNotFoundError("product")
@@ -108,7 +109,7 @@ func (m *mockProductRepository) ReserveStock(ctx context.Context, productID stri
 	return nil
 }
 
-func (m *mockProductRepository) ReleaseStock(ctx context.Context, productID string, quantity int) error {
+func (m *mockProductRepository) ReleaseStock(ctx context.Context, productID, variantID string, quantity int) error {
 	_, exists := m.products[productID]
 	if !exists {
 		return utils.NewNotFoundError("product")
@@ -124,6 +125,10 @@ func (m *mockProductRepository) GetAvailableStock(ctx context.Context, productID
 	return product.Stock, nil
 }
 
+func (m *mockProductRepository) SetVariantStock(ctx context.Context, productID, variantID string, stock int, reason string) error {
+	return nil
+}
+
 func (m *mockProductRepository) BulkUpdateStock(ctx context.Context, updates []repository.StockUpdate) error {
 	return nil
 }
@@ -202,7 +207,7 @@ func (m *mockCategoryRepository) GetPath(ctx context.Context, categoryID string)
 func TestProductService_CreateProduct(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -238,7 +243,7 @@ func TestProductService_CreateProduct(t *testing.T) {
 func TestProductService_CreateProduct_ValidationError(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -265,10 +270,59 @@ func TestProductService_CreateProduct_ValidationError(t *testing.T) {
 	}
 }
 
+func TestProductService_CreateProduct_PriceList(t *testing.T) {
+	productRepo := newMockProductRepository()
+	categoryRepo := newMockCategoryRepository()
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
+	
+	ctx := context.Background()
+	
+	req := CreateProductRequest{
+		SKU:         "TEST-002",
+		Name:        "Test Product",
+		Description: "A test product",
+		Price:       decimal.RequireFromString("19.99"),
+		Currency:    "usd",
+		Prices: models.PriceList{
+			"jpy": decimal.RequireFromString("2980"),
+			"KWD": decimal.RequireFromString("6.150"),
+		},
+	}
+	
+	product, err := service.CreateProduct(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	
+	if product.Currency != "USD" {
+		t.Errorf("Expected currency USD, got %s", product.Currency)
+	}
+	
+	if price, ok := product.PriceIn("JPY"); !ok || !price.Equal(decimal.NewFromInt(2980)) {
+		t.Errorf("Expected JPY price 2980, got %s", price)
+	}
+	
+	// Prices with more precision than the currency allows are rejected
+	invalid := []models.PriceList{
+		{"JPY": decimal.RequireFromString("2980.5")},
+		{"KWD": decimal.RequireFromString("6.1505")},
+		{"XYZ": decimal.RequireFromString("10")},
+		{"USD": decimal.RequireFromString("19.99")},
+		{"EUR": decimal.Zero},
+	}
+	for i, prices := range invalid {
+		req.SKU = fmt.Sprintf("TEST-INVALID-%d", i)
+		req.Prices = prices
+		if _, err := service.CreateProduct(ctx, req); err == nil {
+			t.Errorf("Expected validation error for prices %v", prices)
+		}
+	}
+}
+
 func TestProductService_GetProduct(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -290,7 +344,7 @@ func TestProductService_GetProduct(t *testing.T) {
 func TestProductService_GetProduct_NotFound(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -313,7 +367,7 @@ func TestProductService_GetProduct_NotFound(t *testing.T) {
 func TestProductService_UpdateProduct(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -346,7 +400,7 @@ func TestProductService_UpdateProduct(t *testing.T) {
 func TestProductService_DeleteProduct(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -370,7 +424,7 @@ func TestProductService_DeleteProduct(t *testing.T) {
 func TestProductService_ReserveStock(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -380,7 +434,7 @@ func TestProductService_ReserveStock(t *testing.T) {
 	productRepo.Create(ctx, testProduct)
 	
 	// Test successful stock reservation
-	err := service.ReserveStock(ctx, testProduct.ID, 5)
+	err := service.ReserveStock(ctx, testProduct.ID, "", 5)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -389,7 +443,7 @@ func TestProductService_ReserveStock(t *testing.T) {
 func TestProductService_ReserveStock_InsufficientStock(t *testing.T) {
 	productRepo := newMockProductRepository()
 	categoryRepo := newMockCategoryRepository()
-	service := NewProductService(productRepo, categoryRepo, nil, nil)
+	service := NewProductService(productRepo, categoryRepo, nil, nil, nil)
 	
 	ctx := context.Background()
 	
@@ -399,7 +453,7 @@ func TestProductService_ReserveStock_InsufficientStock(t *testing.T) {
 	productRepo.Create(ctx, testProduct)
 	
 	// Test insufficient stock error
-	err := service.ReserveStock(ctx, testProduct.ID, 10)
+	err := service.ReserveStock(ctx, testProduct.ID, "", 10)
 	if err == nil {
 		t.Fatal("Expected insufficient stock error, got nil")
 	}
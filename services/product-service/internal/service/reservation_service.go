@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// expiredReservationBatchSize is how many expired reservations ReleaseExpired
+// loads at a time
+const expiredReservationBatchSize = 100
+
+// ReservationConfig holds how long stock reservations are held for
+type ReservationConfig struct {
+	DefaultTTL time.Duration // used when a request has no TTL
+	MaxTTL     time.Duration
+}
+
+// DefaultReservationConfig returns the default reservation configuration
+func DefaultReservationConfig() ReservationConfig {
+	return ReservationConfig{
+		DefaultTTL: 30 * time.Minute,
+		MaxTTL:     7 * 24 * time.Hour,
+	}
+}
+
+// ReservationService handles stock reservations held for orders and carts
+type ReservationService struct {
+	reservationRepo repository.ReservationRepository
+	config          ReservationConfig
+	now             func() time.Time
+}
+
+// NewReservationService creates a new reservation service
+func NewReservationService(reservationRepo repository.ReservationRepository, config ReservationConfig) *ReservationService {
+	return &ReservationService{
+		reservationRepo: reservationRepo,
+		config:          config,
+		now:             time.Now,
+	}
+}
+
+// Reserve holds quantity units for the request's order or cart until the TTL
+// runs out. Calling it again for the same reference and item sets the held
+// quantity rather than adding to it, and renews the expiry.
+func (s *ReservationService) Reserve(ctx context.Context, req StockReservationRequest) (*models.StockReservation, error) {
+	if err := validateReservationReference(req); err != nil {
+		return nil, err
+	}
+	if req.Quantity <= 0 {
+		return nil, utils.NewValidationError("quantity must be positive")
+	}
+
+	ttl := s.config.DefaultTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > s.config.MaxTTL {
+		return nil, utils.NewValidationError(fmt.Sprintf("ttl_seconds must be between 1 and %d", int(s.config.MaxTTL.Seconds())))
+	}
+
+	reservation := models.NewStockReservation(req.ReferenceType, req.ReferenceID, req.ProductID, req.VariantID,
+		req.Quantity, s.now().Add(ttl))
+	if err := s.reservationRepo.Reserve(ctx, reservation); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// Release releases the units held for the request's order or cart. Releasing
+// a reservation that is no longer active returns it unchanged.
+func (s *ReservationService) Release(ctx context.Context, req StockReservationRequest) (*models.StockReservation, error) {
+	if err := validateReservationReference(req); err != nil {
+		return nil, err
+	}
+
+	reservation, err := s.reservationRepo.Release(ctx, reservationKey(req))
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, utils.NewNotFoundError("stock reservation")
+	}
+
+	return reservation, nil
+}
+
+// Commit takes the reserved units out of stock once the order is fulfilled.
+// A quantity of zero commits everything the reservation holds.
+func (s *ReservationService) Commit(ctx context.Context, req StockReservationRequest) (*models.StockReservation, error) {
+	if err := validateReservationReference(req); err != nil {
+		return nil, err
+	}
+	if req.Quantity < 0 {
+		return nil, utils.NewValidationError("quantity cannot be negative")
+	}
+
+	return s.reservationRepo.Commit(ctx, reservationKey(req), req.Quantity)
+}
+
+// ListReservations retrieves the reservations held for an order or cart
+func (s *ReservationService) ListReservations(ctx context.Context, referenceType, referenceID string) ([]*models.StockReservation, error) {
+	if !isReservationReferenceType(referenceType) {
+		return nil, utils.NewValidationError("reference type must be order or cart")
+	}
+	if referenceID == "" {
+		return nil, utils.NewValidationError("reference ID is required")
+	}
+
+	reservations, err := s.reservationRepo.ListByReference(ctx, referenceType, referenceID)
+	if err != nil {
+		return nil, err
+	}
+	if reservations == nil {
+		reservations = []*models.StockReservation{}
+	}
+
+	return reservations, nil
+}
+
+// ReleaseExpired releases the stock of every reservation that has expired and
+// returns how many were released. Reservations renewed after they were listed
+// are left alone.
+func (s *ReservationService) ReleaseExpired(ctx context.Context) (int, error) {
+	now := s.now()
+	released := 0
+
+	for {
+		reservations, err := s.reservationRepo.ListExpired(ctx, now, expiredReservationBatchSize)
+		if err != nil {
+			return released, err
+		}
+
+		progressed := false
+		for _, reservation := range reservations {
+			expired, err := s.reservationRepo.Expire(ctx, repository.ReservationKey{
+				ReferenceType: reservation.ReferenceType,
+				ReferenceID:   reservation.ReferenceID,
+				ProductID:     reservation.ProductID,
+				VariantID:     reservation.VariantID,
+			}, now)
+			if err != nil {
+				return released, err
+			}
+			if expired != nil && expired.Status == models.ReservationExpired {
+				released++
+			}
+			if expired == nil || !expired.IsActive() {
+				progressed = true
+			}
+		}
+
+		// A short batch is the last one; a batch that changed nothing would
+		// be listed again forever
+		if len(reservations) < expiredReservationBatchSize || !progressed {
+			return released, nil
+		}
+	}
+}
+
+func validateReservationReference(req StockReservationRequest) error {
+	if req.ProductID == "" {
+		return utils.NewValidationError("product ID is required")
+	}
+	if !isReservationReferenceType(req.ReferenceType) {
+		return utils.NewValidationError("reference type must be order or cart")
+	}
+	if req.ReferenceID == "" {
+		return utils.NewValidationError("reference ID is required")
+	}
+	return nil
+}
+
+func isReservationReferenceType(referenceType string) bool {
+	return referenceType == models.ReservationReferenceOrder || referenceType == models.ReservationReferenceCart
+}
+
+func reservationKey(req StockReservationRequest) repository.ReservationKey {
+	return repository.ReservationKey{
+		ReferenceType: req.ReferenceType,
+		ReferenceID:   req.ReferenceID,
+		ProductID:     req.ProductID,
+		VariantID:     req.VariantID,
+	}
+}
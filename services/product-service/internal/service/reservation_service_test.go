@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Mock reservation repository that tracks available and reserved stock per product
+type mockReservationRepository struct {
+	reservations map[repository.ReservationKey]*models.StockReservation
+	available    map[string]int
+	reserved     map[string]int
+	sold         map[string]int
+}
+
+func newMockReservationRepository(available map[string]int) *mockReservationRepository {
+	return &mockReservationRepository{
+		reservations: make(map[repository.ReservationKey]*models.StockReservation),
+		available:    available,
+		reserved:     make(map[string]int),
+		sold:         make(map[string]int),
+	}
+}
+
+func (m *mockReservationRepository) Reserve(ctx context.Context, reservation *models.StockReservation) error {
+	key := repository.ReservationKey{
+		ReferenceType: reservation.ReferenceType,
+		ReferenceID:   reservation.ReferenceID,
+		ProductID:     reservation.ProductID,
+		VariantID:     reservation.VariantID,
+	}
+
+	held := 0
+	existing, exists := m.reservations[key]
+	if exists {
+		if existing.Status == models.ReservationCommitted {
+			return utils.NewConflictError("stock reservation is already committed")
+		}
+		if existing.IsActive() {
+			held = existing.Quantity
+		}
+		reservation.ID = existing.ID
+	}
+
+	delta := reservation.Quantity - held
+	if delta > m.available[key.ProductID] {
+		return utils.NewConflictError("insufficient stock")
+	}
+	m.available[key.ProductID] -= delta
+	m.reserved[key.ProductID] += delta
+
+	reservation.Status = models.ReservationActive
+	copied := *reservation
+	m.reservations[key] = &copied
+	return nil
+}
+
+func (m *mockReservationRepository) Release(ctx context.Context, key repository.ReservationKey) (*models.StockReservation, error) {
+	return m.end(key, models.ReservationReleased, func(*models.StockReservation) bool { return true })
+}
+
+func (m *mockReservationRepository) Expire(ctx context.Context, key repository.ReservationKey, now time.Time) (*models.StockReservation, error) {
+	return m.end(key, models.ReservationExpired, func(r *models.StockReservation) bool { return r.HasExpired(now) })
+}
+
+func (m *mockReservationRepository) end(key repository.ReservationKey, status models.ReservationStatus, shouldEnd func(*models.StockReservation) bool) (*models.StockReservation, error) {
+	reservation, exists := m.reservations[key]
+	if !exists {
+		return nil, nil
+	}
+	if reservation.IsActive() && shouldEnd(reservation) {
+		m.available[key.ProductID] += reservation.Quantity
+		m.reserved[key.ProductID] -= reservation.Quantity
+		reservation.Status = status
+	}
+	copied := *reservation
+	return &copied, nil
+}
+
+func (m *mockReservationRepository) Commit(ctx context.Context, key repository.ReservationKey, quantity int) (*models.StockReservation, error) {
+	reservation, exists := m.reservations[key]
+	if !exists {
+		return nil, utils.NewNotFoundError("stock reservation")
+	}
+	if reservation.Status == models.ReservationReleased {
+		return nil, utils.NewConflictError("stock reservation has been released")
+	}
+	if quantity == 0 {
+		quantity = reservation.Quantity
+	}
+	if quantity > reservation.Quantity {
+		return nil, utils.NewConflictError("not enough units are reserved")
+	}
+
+	if reservation.IsActive() {
+		m.reserved[key.ProductID] -= quantity
+	} else {
+		m.available[key.ProductID] -= quantity
+	}
+	m.sold[key.ProductID] += quantity
+
+	reservation.Quantity -= quantity
+	reservation.CommittedQuantity += quantity
+	if reservation.Quantity == 0 {
+		reservation.Status = models.ReservationCommitted
+	}
+	copied := *reservation
+	return &copied, nil
+}
+
+func (m *mockReservationRepository) ListByReference(ctx context.Context, referenceType, referenceID string) ([]*models.StockReservation, error) {
+	var reservations []*models.StockReservation
+	for key, reservation := range m.reservations {
+		if key.ReferenceType == referenceType && key.ReferenceID == referenceID {
+			copied := *reservation
+			reservations = append(reservations, &copied)
+		}
+	}
+	return reservations, nil
+}
+
+func (m *mockReservationRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]*models.StockReservation, error) {
+	var reservations []*models.StockReservation
+	for _, reservation := range m.reservations {
+		if reservation.HasExpired(now) && len(reservations) < limit {
+			copied := *reservation
+			reservations = append(reservations, &copied)
+		}
+	}
+	return reservations, nil
+}
+
+func newTestReservationService(repo repository.ReservationRepository, now *time.Time) *ReservationService {
+	service := NewReservationService(repo, DefaultReservationConfig())
+	service.now = func() time.Time { return *now }
+	return service
+}
+
+func orderReservation(orderID, productID string, quantity int) StockReservationRequest {
+	return StockReservationRequest{
+		ProductID:     productID,
+		Quantity:      quantity,
+		ReferenceType: models.ReservationReferenceOrder,
+		ReferenceID:   orderID,
+	}
+}
+
+func TestReservationService_Reserve_IsIdempotent(t *testing.T) {
+	repo := newMockReservationRepository(map[string]int{"prod1": 10})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestReservationService(repo, &now)
+	ctx := context.Background()
+
+	first, err := service.Reserve(ctx, orderReservation("order1", "prod1", 3))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !first.ExpiresAt.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("Expected the default TTL, got expiry %v", first.ExpiresAt)
+	}
+
+	// Retrying the same reservation must not hold the units twice
+	now = now.Add(10 * time.Minute)
+	second, err := service.Reserve(ctx, orderReservation("order1", "prod1", 3))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("Expected the same reservation, got %s and %s", first.ID, second.ID)
+	}
+	if repo.reserved["prod1"] != 3 {
+		t.Errorf("Expected 3 units reserved, got %d", repo.reserved["prod1"])
+	}
+	if !second.ExpiresAt.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("Expected the expiry to be renewed, got %v", second.ExpiresAt)
+	}
+
+	// Reserving a smaller quantity releases the difference
+	if _, err := service.Reserve(ctx, orderReservation("order1", "prod1", 1)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if repo.reserved["prod1"] != 1 || repo.available["prod1"] != 9 {
+		t.Errorf("Expected 1 reserved and 9 available, got %d and %d", repo.reserved["prod1"], repo.available["prod1"])
+	}
+
+	// Releasing twice releases the units once
+	for i := 0; i < 2; i++ {
+		released, err := service.Release(ctx, orderReservation("order1", "prod1", 0))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if released.Status != models.ReservationReleased {
+			t.Errorf("Expected status released, got %s", released.Status)
+		}
+	}
+	if repo.reserved["prod1"] != 0 || repo.available["prod1"] != 10 {
+		t.Errorf("Expected all stock available, got %d reserved and %d available", repo.reserved["prod1"], repo.available["prod1"])
+	}
+}
+
+func TestReservationService_Reserve_ValidationError(t *testing.T) {
+	repo := newMockReservationRepository(map[string]int{"prod1": 10})
+	now := time.Now()
+	service := newTestReservationService(repo, &now)
+
+	tests := []struct {
+		name string
+		req  StockReservationRequest
+	}{
+		{"missing reference", StockReservationRequest{ProductID: "prod1", Quantity: 1}},
+		{"unknown reference type", StockReservationRequest{ProductID: "prod1", Quantity: 1, ReferenceType: "wishlist", ReferenceID: "w1"}},
+		{"zero quantity", orderReservation("order1", "prod1", 0)},
+		{"negative TTL", StockReservationRequest{ProductID: "prod1", Quantity: 1, ReferenceType: "cart", ReferenceID: "c1", TTLSeconds: -1}},
+		{"TTL above maximum", StockReservationRequest{ProductID: "prod1", Quantity: 1, ReferenceType: "cart", ReferenceID: "c1", TTLSeconds: 8 * 24 * 3600}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Reserve(context.Background(), tt.req)
+			if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrValidation {
+				t.Errorf("Expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReservationService_Reserve_InsufficientStock(t *testing.T) {
+	repo := newMockReservationRepository(map[string]int{"prod1": 5})
+	now := time.Now()
+	service := newTestReservationService(repo, &now)
+	ctx := context.Background()
+
+	if _, err := service.Reserve(ctx, orderReservation("order1", "prod1", 4)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err := service.Reserve(ctx, orderReservation("order2", "prod1", 2))
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+
+	// The order already holding stock can grow its reservation into what is left
+	if _, err := service.Reserve(ctx, orderReservation("order1", "prod1", 5)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestReservationService_ReleaseExpired(t *testing.T) {
+	repo := newMockReservationRepository(map[string]int{"prod1": 10, "prod2": 10})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestReservationService(repo, &now)
+	ctx := context.Background()
+
+	cart := StockReservationRequest{ProductID: "prod1", Quantity: 2, ReferenceType: "cart", ReferenceID: "cart1", TTLSeconds: 60}
+	if _, err := service.Reserve(ctx, cart); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.Reserve(ctx, orderReservation("order1", "prod2", 3)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	now = now.Add(5 * time.Minute)
+	released, err := service.ReleaseExpired(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if released != 1 {
+		t.Errorf("Expected 1 expired reservation, got %d", released)
+	}
+	if repo.available["prod1"] != 10 {
+		t.Errorf("Expected the cart's stock to be released, got %d available", repo.available["prod1"])
+	}
+	if repo.reserved["prod2"] != 3 {
+		t.Errorf("Expected the order's stock to stay reserved, got %d", repo.reserved["prod2"])
+	}
+
+	reservations, err := service.ListReservations(ctx, "cart", "cart1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reservations) != 1 || reservations[0].Status != models.ReservationExpired {
+		t.Errorf("Expected the cart's reservation to be expired, got %+v", reservations)
+	}
+}
+
+func TestReservationService_Commit(t *testing.T) {
+	repo := newMockReservationRepository(map[string]int{"prod1": 10})
+	now := time.Now()
+	service := newTestReservationService(repo, &now)
+	ctx := context.Background()
+
+	if _, err := service.Reserve(ctx, orderReservation("order1", "prod1", 3)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A partial shipment commits part of the reservation
+	partial, err := service.Commit(ctx, orderReservation("order1", "prod1", 2))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if partial.Status != models.ReservationActive || partial.Quantity != 1 || partial.CommittedQuantity != 2 {
+		t.Errorf("Expected 1 unit still held and 2 committed, got %+v", partial)
+	}
+
+	_, err = service.Commit(ctx, orderReservation("order1", "prod1", 2))
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error committing more than is held, got %v", err)
+	}
+
+	committed, err := service.Commit(ctx, orderReservation("order1", "prod1", 0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if committed.Status != models.ReservationCommitted || committed.CommittedQuantity != 3 {
+		t.Errorf("Expected the reservation to be committed, got %+v", committed)
+	}
+	if repo.sold["prod1"] != 3 || repo.reserved["prod1"] != 0 {
+		t.Errorf("Expected 3 units sold and none reserved, got %d and %d", repo.sold["prod1"], repo.reserved["prod1"])
+	}
+
+	// A committed reservation cannot be reserved again
+	_, err = service.Reserve(ctx, orderReservation("order1", "prod1", 3))
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// lowStockAlertType is the admin alert type raised when stock reaches a reorder threshold
+const lowStockAlertType = "low_stock"
+
+// AlertSender raises alerts on the admin dashboard
+type AlertSender interface {
+	CreateSystemAlert(ctx context.Context, req *models.CreateSystemAlertRequest) error
+}
+
+// Notifier sends notifications to customers
+type Notifier interface {
+	SendNotification(ctx context.Context, req *models.NotificationRequest) error
+}
+
+// StockAlertService handles reorder thresholds and back-in-stock
+// subscriptions, and reacts to inventory updates by raising low-stock alerts
+// and notifying subscribers
+type StockAlertService struct {
+	repo        repository.StockAlertRepository
+	productRepo repository.ProductRepository
+	variantRepo repository.VariantRepository
+	alerts      AlertSender
+	notifier    Notifier
+}
+
+// NewStockAlertService creates a new stock alert service
+func NewStockAlertService(repo repository.StockAlertRepository, productRepo repository.ProductRepository,
+	variantRepo repository.VariantRepository, alerts AlertSender, notifier Notifier) *StockAlertService {
+	return &StockAlertService{
+		repo:        repo,
+		productRepo: productRepo,
+		variantRepo: variantRepo,
+		alerts:      alerts,
+		notifier:    notifier,
+	}
+}
+
+// SetThreshold sets the reorder threshold of a product, or of one of its variants
+func (s *StockAlertService) SetThreshold(ctx context.Context, productID string, req SetStockThresholdRequest) (*models.StockThreshold, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if req.Threshold < 0 {
+		return nil, utils.NewValidationError("threshold cannot be negative")
+	}
+	if _, _, err := s.getItem(ctx, productID, req.VariantID); err != nil {
+		return nil, err
+	}
+
+	threshold := models.NewStockThreshold(productID, req.VariantID, req.Threshold)
+	if err := s.repo.SetThreshold(ctx, threshold); err != nil {
+		return nil, err
+	}
+	return threshold, nil
+}
+
+// ListThresholds returns the reorder thresholds of a product and its variants
+func (s *StockAlertService) ListThresholds(ctx context.Context, productID string) ([]*models.StockThreshold, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	return s.repo.ListThresholds(ctx, productID)
+}
+
+// DeleteThreshold removes the reorder threshold of a product or variant
+func (s *StockAlertService) DeleteThreshold(ctx context.Context, productID, variantID string) error {
+	if productID == "" {
+		return utils.NewValidationError("product ID is required")
+	}
+	return s.repo.DeleteThreshold(ctx, productID, variantID)
+}
+
+// Subscribe asks for the customer to be notified when an item that is out
+// of stock is available again
+func (s *StockAlertService) Subscribe(ctx context.Context, productID string, req BackInStockRequest) (*models.BackInStockSubscription, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if req.UserID == "" {
+		return nil, utils.NewValidationError("user ID is required")
+	}
+	if req.Recipient == "" {
+		return nil, utils.NewValidationError("recipient is required")
+	}
+	if req.Channel == "" {
+		req.Channel = models.ChannelEmail
+	}
+	if req.Channel != models.ChannelEmail && req.Channel != models.ChannelSMS && req.Channel != models.ChannelPush {
+		return nil, utils.NewValidationError("invalid notification channel")
+	}
+
+	_, variant, err := s.getItem(ctx, productID, req.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	available := 0
+	if variant != nil {
+		available = variant.AvailableStock()
+	} else if available, err = s.productRepo.GetAvailableStock(ctx, productID); err != nil {
+		return nil, err
+	}
+	if available > 0 {
+		return nil, utils.NewConflictError("item is in stock")
+	}
+
+	subscription := models.NewBackInStockSubscription(productID, req.VariantID, req.UserID, req.Channel, req.Recipient)
+	if err := s.repo.Subscribe(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// ListSubscriptions returns a customer's back-in-stock subscriptions
+func (s *StockAlertService) ListSubscriptions(ctx context.Context, userID string) ([]*models.BackInStockSubscription, error) {
+	if userID == "" {
+		return nil, utils.NewValidationError("user ID is required")
+	}
+	return s.repo.ListSubscriptions(ctx, userID)
+}
+
+// Unsubscribe cancels one of the customer's subscriptions that has not been
+// notified yet
+func (s *StockAlertService) Unsubscribe(ctx context.Context, id, userID string) error {
+	if userID == "" {
+		return utils.NewValidationError("user ID is required")
+	}
+
+	subscription, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if subscription.UserID != userID {
+		return utils.NewNotFoundError("back-in-stock subscription")
+	}
+	return s.repo.Unsubscribe(ctx, id)
+}
+
+// HandleInventoryUpdated notifies the subscribers of items whose available
+// stock rose above zero and raises an alert for items whose stock fell to
+// their reorder threshold. Each alert is recorded against the event before it
+// is raised, so handling the event again never raises an alert twice.
+func (s *StockAlertService) HandleInventoryUpdated(ctx context.Context, eventID string, data *models.InventoryUpdatedData) error {
+	var errs []error
+	if data.PreviousAvailable <= 0 && data.NewAvailable > 0 {
+		errs = append(errs, s.notifyBackInStock(ctx, data.ProductID, ""))
+	}
+	if variant := data.Variant; variant != nil && variant.PreviousAvailable <= 0 && variant.NewAvailable > 0 {
+		errs = append(errs, s.notifyBackInStock(ctx, data.ProductID, variant.VariantID))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if err := s.alertLowStock(ctx, eventID, data.ProductID, "", data.SKU, data.PreviousStock, data.NewStock); err != nil {
+		return err
+	}
+	if variant := data.Variant; variant != nil {
+		return s.alertLowStock(ctx, eventID, data.ProductID, variant.VariantID, variant.SKU, variant.PreviousStock, variant.NewStock)
+	}
+	return nil
+}
+
+// notifyBackInStock notifies the pending subscribers of an item. Each
+// subscription is claimed before it is notified; one whose notification
+// fails is returned to pending so a retry picks it up again.
+func (s *StockAlertService) notifyBackInStock(ctx context.Context, productID, variantID string) error {
+	subscriptions, err := s.repo.ClaimSubscriptions(ctx, productID, variantID)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	name := s.itemName(ctx, productID, variantID)
+	subject := name + " is back in stock"
+	body := fmt.Sprintf("Good news: %s is available again.", name)
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		err := s.notifier.SendNotification(ctx, &models.NotificationRequest{
+			UserID:    subscription.UserID,
+			Channel:   subscription.Channel,
+			Recipient: subscription.Recipient,
+			Subject:   &subject,
+			Body:      &body,
+			Variables: map[string]interface{}{
+				"product_id":      productID,
+				"variant_id":      variantID,
+				"product_name":    name,
+				"subscription_id": subscription.ID,
+			},
+		})
+		if err == nil {
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("failed to notify subscription %s: %w", subscription.ID, err))
+		if unclaimErr := s.repo.UnclaimSubscription(ctx, subscription.ID); unclaimErr != nil {
+			utils.Logger.Error(ctx, "Failed to return back-in-stock subscription to pending", unclaimErr, map[string]interface{}{
+				"subscription_id": subscription.ID,
+			})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// alertLowStock raises a low-stock alert when an item's stock fell to its
+// reorder threshold, unless the event already raised it. An alert that could
+// not be raised is forgotten again so a retry raises it.
+func (s *StockAlertService) alertLowStock(ctx context.Context, eventID, productID, variantID, sku string, previous, current int) error {
+	threshold, err := s.repo.GetThreshold(ctx, productID, variantID)
+	if err != nil || threshold == nil || !threshold.IsCrossed(previous, current) {
+		return err
+	}
+
+	recorded, err := s.repo.RecordAlert(ctx, eventID, productID, variantID)
+	if err != nil || !recorded {
+		return err
+	}
+
+	severity := models.AlertSeverityWarning
+	if current <= 0 {
+		severity = models.AlertSeverityCritical
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"product_id": productID,
+		"variant_id": variantID,
+		"sku":        sku,
+		"stock":      current,
+		"threshold":  threshold.Threshold,
+	})
+	if err != nil {
+		return utils.NewInternalError("failed to encode alert metadata", err)
+	}
+
+	source := "product-service"
+	name := s.itemName(ctx, productID, variantID)
+	err = s.alerts.CreateSystemAlert(ctx, &models.CreateSystemAlertRequest{
+		AlertType:     lowStockAlertType,
+		Severity:      string(severity),
+		Title:         fmt.Sprintf("Low stock: %s", sku),
+		Message:       fmt.Sprintf("%s has %d units left, at or below its reorder threshold of %d", name, current, threshold.Threshold),
+		SourceService: &source,
+		Metadata:      metadata,
+	})
+	if err != nil {
+		if forgetErr := s.repo.ForgetAlert(ctx, eventID, productID, variantID); forgetErr != nil {
+			utils.Logger.Error(ctx, "Failed to forget unraised stock alert", forgetErr, map[string]interface{}{
+				"event_id":   eventID,
+				"product_id": productID,
+				"variant_id": variantID,
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// getItem returns a product and, if variantID is set, the product's variant
+func (s *StockAlertService) getItem(ctx context.Context, productID, variantID string) (*models.Product, *models.ProductVariant, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if variantID == "" {
+		return product, nil, nil
+	}
+
+	variant, err := s.variantRepo.GetByID(ctx, variantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if variant.ProductID != productID {
+		return nil, nil, utils.NewNotFoundError("product variant")
+	}
+	return product, variant, nil
+}
+
+// itemName names a product or variant in alerts and notifications, falling
+// back to its ID when it cannot be read
+func (s *StockAlertService) itemName(ctx context.Context, productID, variantID string) string {
+	product, variant, err := s.getItem(ctx, productID, variantID)
+	switch {
+	case err != nil && variantID != "":
+		return variantID
+	case err != nil:
+		return productID
+	case variant != nil:
+		return product.Name + " - " + variant.Name
+	}
+	return product.Name
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Mock stock alert repository keeping thresholds by item and subscriptions in order
+type mockStockAlertRepository struct {
+	thresholds    map[string]*models.StockThreshold // keyed by product and variant ID
+	subscriptions []*models.BackInStockSubscription
+	raised        map[string]bool // keyed by event, product and variant ID
+}
+
+func newMockStockAlertRepository() *mockStockAlertRepository {
+	return &mockStockAlertRepository{
+		thresholds: make(map[string]*models.StockThreshold),
+		raised:     make(map[string]bool),
+	}
+}
+
+func (m *mockStockAlertRepository) SetThreshold(ctx context.Context, threshold *models.StockThreshold) error {
+	m.thresholds[threshold.ProductID+"/"+threshold.VariantID] = threshold
+	return nil
+}
+
+func (m *mockStockAlertRepository) GetThreshold(ctx context.Context, productID, variantID string) (*models.StockThreshold, error) {
+	return m.thresholds[productID+"/"+variantID], nil
+}
+
+func (m *mockStockAlertRepository) ListThresholds(ctx context.Context, productID string) ([]*models.StockThreshold, error) {
+	var thresholds []*models.StockThreshold
+	for _, threshold := range m.thresholds {
+		if threshold.ProductID == productID {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	return thresholds, nil
+}
+
+func (m *mockStockAlertRepository) DeleteThreshold(ctx context.Context, productID, variantID string) error {
+	delete(m.thresholds, productID+"/"+variantID)
+	return nil
+}
+
+func (m *mockStockAlertRepository) Subscribe(ctx context.Context, subscription *models.BackInStockSubscription) error {
+	for _, existing := range m.subscriptions {
+		if existing.NotifiedAt == nil && existing.UserID == subscription.UserID &&
+			existing.ProductID == subscription.ProductID && existing.VariantID == subscription.VariantID {
+			return utils.NewConflictError("already subscribed to this item")
+		}
+	}
+	m.subscriptions = append(m.subscriptions, subscription)
+	return nil
+}
+
+func (m *mockStockAlertRepository) GetSubscription(ctx context.Context, id string) (*models.BackInStockSubscription, error) {
+	for _, subscription := range m.subscriptions {
+		if subscription.ID == id {
+			return subscription, nil
+		}
+	}
+	return nil, utils.NewNotFoundError("back-in-stock subscription")
+}
+
+func (m *mockStockAlertRepository) ListSubscriptions(ctx context.Context, userID string) ([]*models.BackInStockSubscription, error) {
+	var subscriptions []*models.BackInStockSubscription
+	for _, subscription := range m.subscriptions {
+		if subscription.UserID == userID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *mockStockAlertRepository) Unsubscribe(ctx context.Context, id string) error {
+	for i, subscription := range m.subscriptions {
+		if subscription.ID == id && subscription.NotifiedAt == nil {
+			m.subscriptions = append(m.subscriptions[:i], m.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return utils.NewNotFoundError("back-in-stock subscription")
+}
+
+func (m *mockStockAlertRepository) ClaimSubscriptions(ctx context.Context, productID, variantID string) ([]*models.BackInStockSubscription, error) {
+	var claimed []*models.BackInStockSubscription
+	now := time.Now()
+	for _, subscription := range m.subscriptions {
+		if subscription.NotifiedAt == nil && subscription.ProductID == productID && subscription.VariantID == variantID {
+			subscription.NotifiedAt = &now
+			claimed = append(claimed, subscription)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *mockStockAlertRepository) UnclaimSubscription(ctx context.Context, id string) error {
+	for _, subscription := range m.subscriptions {
+		if subscription.ID == id {
+			subscription.NotifiedAt = nil
+		}
+	}
+	return nil
+}
+
+func (m *mockStockAlertRepository) RecordAlert(ctx context.Context, eventID, productID, variantID string) (bool, error) {
+	key := eventID + "/" + productID + "/" + variantID
+	if m.raised[key] {
+		return false, nil
+	}
+	m.raised[key] = true
+	return true, nil
+}
+
+func (m *mockStockAlertRepository) ForgetAlert(ctx context.Context, eventID, productID, variantID string) error {
+	delete(m.raised, eventID+"/"+productID+"/"+variantID)
+	return nil
+}
+
+// Mock alert sender and notifier recording what they were asked to send
+type mockStockAlerts struct {
+	alerts        []*models.CreateSystemAlertRequest
+	notifications []*models.NotificationRequest
+	failFor       string // recipient whose notifications fail
+	failAlertFor  string // SKU whose alerts fail
+}
+
+func (m *mockStockAlerts) CreateSystemAlert(ctx context.Context, req *models.CreateSystemAlertRequest) error {
+	if req.Title == "Low stock: "+m.failAlertFor {
+		return errors.New("admin-service unavailable")
+	}
+	m.alerts = append(m.alerts, req)
+	return nil
+}
+
+func (m *mockStockAlerts) SendNotification(ctx context.Context, req *models.NotificationRequest) error {
+	if req.Recipient == m.failFor {
+		return errors.New("notification-service unavailable")
+	}
+	m.notifications = append(m.notifications, req)
+	return nil
+}
+
+func newStockAlertFixture() (*StockAlertService, *mockStockAlertRepository, *mockStockAlerts) {
+	products := newMockProductRepository()
+	products.products["prod1"] = &models.Product{ID: "prod1", SKU: "MUG-1", Name: "Mug", Stock: 0}
+	variants := newMockVariantRepository()
+	variants.variants["var1"] = &models.ProductVariant{ID: "var1", ProductID: "prod1", SKU: "MUG-1-RED", Name: "Red"}
+
+	repo := newMockStockAlertRepository()
+	alerts := &mockStockAlerts{}
+	return NewStockAlertService(repo, products, variants, alerts, alerts), repo, alerts
+}
+
+func TestStockAlertService_NotifiesBackInStockOnce(t *testing.T) {
+	ctx := context.Background()
+	service, repo, alerts := newStockAlertFixture()
+
+	for _, recipient := range []string{"a@example.com", "b@example.com"} {
+		_, err := service.Subscribe(ctx, "prod1", BackInStockRequest{UserID: "user-" + recipient, Recipient: recipient})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	restock := &models.InventoryUpdatedData{ProductID: "prod1", SKU: "MUG-1", NewStock: 5, NewAvailable: 5, Reason: "restock"}
+	if err := service.HandleInventoryUpdated(ctx, "evt1", restock); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(alerts.notifications) != 2 {
+		t.Fatalf("Expected both subscribers to be notified, got %d notifications", len(alerts.notifications))
+	}
+	if subject := *alerts.notifications[0].Subject; subject != "Mug is back in stock" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+
+	// Selling out and restocking again does not notify the same subscribers
+	sellOut := &models.InventoryUpdatedData{ProductID: "prod1", PreviousStock: 5, PreviousAvailable: 5, Reason: "sale"}
+	if err := service.HandleInventoryUpdated(ctx, "evt2", sellOut); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.HandleInventoryUpdated(ctx, "evt3", restock); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(alerts.notifications) != 2 {
+		t.Errorf("Expected subscribers to be notified only once, got %d notifications", len(alerts.notifications))
+	}
+	for _, subscription := range repo.subscriptions {
+		if subscription.NotifiedAt == nil {
+			t.Errorf("Expected subscription %s to be marked notified", subscription.ID)
+		}
+	}
+}
+
+func TestStockAlertService_RetriesFailedNotifications(t *testing.T) {
+	ctx := context.Background()
+	service, repo, alerts := newStockAlertFixture()
+	alerts.failFor = "b@example.com"
+
+	for _, recipient := range []string{"a@example.com", "b@example.com"} {
+		_, err := service.Subscribe(ctx, "prod1", BackInStockRequest{VariantID: "var1", UserID: "user-" + recipient, Recipient: recipient})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	restock := &models.InventoryUpdatedData{
+		ProductID: "prod1", NewStock: 3, NewAvailable: 3,
+		Variant: &models.VariantStockChange{VariantID: "var1", NewStock: 3, NewAvailable: 3},
+	}
+	if err := service.HandleInventoryUpdated(ctx, "evt1", restock); err == nil {
+		t.Fatal("Expected the failed notification to fail the event")
+	}
+	if repo.subscriptions[1].NotifiedAt != nil {
+		t.Error("Expected the failed subscription to be pending again")
+	}
+
+	alerts.failFor = ""
+	if err := service.HandleInventoryUpdated(ctx, "evt1", restock); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if len(alerts.notifications) != 2 || alerts.notifications[1].Recipient != "b@example.com" {
+		t.Errorf("Expected only the failed subscriber to be notified on retry, got %+v", alerts.notifications)
+	}
+	if name := alerts.notifications[0].Variables["product_name"]; name != "Mug - Red" {
+		t.Errorf("Expected the variant to be named, got %v", name)
+	}
+}
+
+func TestStockAlertService_AlertsWhenThresholdCrossed(t *testing.T) {
+	ctx := context.Background()
+	service, _, alerts := newStockAlertFixture()
+
+	if _, err := service.SetThreshold(ctx, "prod1", SetStockThresholdRequest{Threshold: 5}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.SetThreshold(ctx, "prod1", SetStockThresholdRequest{VariantID: "var1", Threshold: 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     models.InventoryUpdatedData
+		severity []string
+	}{
+		{
+			name:     "product falls to its threshold",
+			data:     models.InventoryUpdatedData{ProductID: "prod1", SKU: "MUG-1", PreviousStock: 8, NewStock: 5},
+			severity: []string{"warning"},
+		},
+		{
+			name: "product already below its threshold",
+			data: models.InventoryUpdatedData{ProductID: "prod1", SKU: "MUG-1", PreviousStock: 5, NewStock: 4},
+		},
+		{
+			name: "variant sells out",
+			data: models.InventoryUpdatedData{
+				ProductID: "prod1", SKU: "MUG-1", PreviousStock: 4, NewStock: 1,
+				Variant: &models.VariantStockChange{VariantID: "var1", SKU: "MUG-1-RED", PreviousStock: 3},
+			},
+			severity: []string{"critical"},
+		},
+		{
+			name: "restock",
+			data: models.InventoryUpdatedData{ProductID: "prod1", SKU: "MUG-1", PreviousStock: 1, NewStock: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts.alerts = nil
+			if err := service.HandleInventoryUpdated(ctx, tt.name, &tt.data); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(alerts.alerts) != len(tt.severity) {
+				t.Fatalf("Expected %d alerts, got %+v", len(tt.severity), alerts.alerts)
+			}
+			for i, alert := range alerts.alerts {
+				if alert.AlertType != lowStockAlertType || alert.Severity != tt.severity[i] || *alert.SourceService != "product-service" {
+					t.Errorf("Unexpected alert %+v", alert)
+				}
+			}
+		})
+	}
+}
+
+func TestStockAlertService_RaisesAlertsOncePerEvent(t *testing.T) {
+	ctx := context.Background()
+	service, _, alerts := newStockAlertFixture()
+	alerts.failAlertFor = "MUG-1-RED"
+
+	if _, err := service.SetThreshold(ctx, "prod1", SetStockThresholdRequest{Threshold: 5}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.SetThreshold(ctx, "prod1", SetStockThresholdRequest{VariantID: "var1", Threshold: 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sale := &models.InventoryUpdatedData{
+		ProductID: "prod1", SKU: "MUG-1", PreviousStock: 6, NewStock: 4,
+		Variant: &models.VariantStockChange{VariantID: "var1", SKU: "MUG-1-RED", PreviousStock: 3, NewStock: 1},
+	}
+	if err := service.HandleInventoryUpdated(ctx, "evt1", sale); err == nil {
+		t.Fatal("Expected the failed variant alert to fail the event")
+	}
+
+	alerts.failAlertFor = ""
+	for i := 0; i < 2; i++ {
+		if err := service.HandleInventoryUpdated(ctx, "evt1", sale); err != nil {
+			t.Fatalf("Expected the retry to succeed, got %v", err)
+		}
+	}
+	if len(alerts.alerts) != 2 {
+		t.Fatalf("Expected one alert for the product and one for the variant, got %+v", alerts.alerts)
+	}
+	if title := alerts.alerts[1].Title; title != "Low stock: MUG-1-RED" {
+		t.Errorf("Expected the retry to raise only the variant alert, got %q", title)
+	}
+}
+
+func TestStockAlertService_Subscribe_Errors(t *testing.T) {
+	service, _, _ := newStockAlertFixture()
+	service.productRepo.(*mockProductRepository).products["prod2"] = &models.Product{ID: "prod2", Stock: 3}
+
+	tests := []struct {
+		name      string
+		productID string
+		req       BackInStockRequest
+		code      utils.ErrorCode
+	}{
+		{"missing recipient", "prod1", BackInStockRequest{UserID: "user1"}, utils.ErrValidation},
+		{"unknown channel", "prod1", BackInStockRequest{UserID: "user1", Recipient: "a@example.com", Channel: "fax"}, utils.ErrValidation},
+		{"unknown variant", "prod1", BackInStockRequest{VariantID: "var9", UserID: "user1", Recipient: "a@example.com"}, utils.ErrNotFound},
+		{"in stock", "prod2", BackInStockRequest{UserID: "user1", Recipient: "a@example.com"}, utils.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Subscribe(context.Background(), tt.productID, tt.req)
+			if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != tt.code {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
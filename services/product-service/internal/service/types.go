@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/shopspring/decimal"
 	"github.com/shopsphere/shared/models"
 )
@@ -15,10 +17,13 @@ type CreateProductRequest struct {
 	CategoryID  string                     `json:"category_id"`
 	Price       decimal.Decimal            `json:"price" validate:"required"`
 	Currency    string                     `json:"currency"`
+	Prices      models.PriceList           `json:"prices"`
 	Stock       int                        `json:"stock"`
 	Status      string                     `json:"status"`
 	Images      []string                   `json:"images"`
 	Attributes  *ProductAttributesRequest  `json:"attributes"`
+	TaxClass    string                     `json:"tax_class"`
+	Options     []models.ProductOption     `json:"options"`
 }
 
 // UpdateProductRequest represents a request to update a product
@@ -29,10 +34,13 @@ type UpdateProductRequest struct {
 	CategoryID  *string                    `json:"category_id"`
 	Price       *decimal.Decimal           `json:"price"`
 	Currency    *string                    `json:"currency"`
+	Prices      models.PriceList           `json:"prices"`
 	Stock       *int                       `json:"stock"`
 	Status      *string                    `json:"status"`
 	Images      []string                   `json:"images"`
 	Attributes  *UpdateProductAttributesRequest `json:"attributes"`
+	TaxClass    *string                    `json:"tax_class"`
+	Options     []models.ProductOption     `json:"options"` // nil leaves the options unchanged
 }
 
 // ProductAttributesRequest represents product attributes in requests
@@ -180,16 +188,25 @@ type SearchTerm struct {
 
 // BulkStockUpdate represents a bulk stock update operation
 type BulkStockUpdate struct {
-	ProductID string `json:"product_id" validate:"required"`
-	Quantity  int    `json:"quantity" validate:"required"`
-	Type      string `json:"type" validate:"required"` // "in", "out", "adjustment"
-	Reason    string `json:"reason"`
+	ProductID     string `json:"product_id" validate:"required"`
+	VariantID     string `json:"variant_id"`
+	Quantity      int    `json:"quantity" validate:"required"`
+	Type          string `json:"type" validate:"required"` // "in", "out", or "adjustment" to set stock to Quantity
+	Reason        string `json:"reason"`
+	ReferenceType string `json:"reference_type"` // what the update was made for, such as an order
+	ReferenceID   string `json:"reference_id"`
 }
 
 // StockReservationRequest represents a request to reserve stock
+// A reference makes it a reservation held for that order or cart until the
+// TTL runs out.
 type StockReservationRequest struct {
-	ProductID string `json:"product_id" validate:"required"`
-	Quantity  int    `json:"quantity" validate:"required"`
+	ProductID     string `json:"product_id" validate:"required"`
+	VariantID     string `json:"variant_id"`
+	Quantity      int    `json:"quantity" validate:"required"`
+	ReferenceType string `json:"reference_type"` // order or cart
+	ReferenceID   string `json:"reference_id"`
+	TTLSeconds    int    `json:"ttl_seconds"`
 }
 
 // StockUpdateRequest represents a request to update stock
@@ -198,6 +215,47 @@ type StockUpdateRequest struct {
 	Reason   string `json:"reason"`
 }
 
+// Variant Service DTOs
+
+// CreateVariantRequest represents a request to create a product variant.
+// Attributes hold one value for each of the product's options.
+type CreateVariantRequest struct {
+	SKU          string            `json:"sku" validate:"required"`
+	Name         string            `json:"name"`
+	Price        *decimal.Decimal  `json:"price"` // defaults to the product price
+	Prices       models.PriceList  `json:"prices"`
+	ComparePrice *decimal.Decimal  `json:"compare_price"`
+	CostPrice    *decimal.Decimal  `json:"cost_price"`
+	Stock        int               `json:"stock"`
+	Weight       float64           `json:"weight"`
+	Attributes   map[string]string `json:"attributes"`
+	ImageURL     string            `json:"image_url"`
+	IsDefault    bool              `json:"is_default"`
+}
+
+// UpdateVariantRequest represents a request to update a product variant.
+// A stock change is recorded as an inventory movement.
+type UpdateVariantRequest struct {
+	SKU          *string           `json:"sku"`
+	Name         *string           `json:"name"`
+	Price        *decimal.Decimal  `json:"price"`
+	Prices       models.PriceList  `json:"prices"`
+	ComparePrice *decimal.Decimal  `json:"compare_price"`
+	CostPrice    *decimal.Decimal  `json:"cost_price"`
+	Stock        *int              `json:"stock"`
+	Weight       *float64          `json:"weight"`
+	Attributes   map[string]string `json:"attributes"`
+	ImageURL     *string           `json:"image_url"`
+	IsDefault    *bool             `json:"is_default"`
+}
+
+// GenerateVariantsRequest represents a request to create a variant for every
+// combination of the product's option values that does not have one yet
+type GenerateVariantsRequest struct {
+	Price *decimal.Decimal `json:"price"` // defaults to the product price
+	Stock int              `json:"stock"`
+}
+
 // Category Service DTOs
 
 // CreateCategoryRequest represents a request to create a category
@@ -263,35 +321,101 @@ type DeleteImageRequest struct {
 
 // Inventory DTOs
 
-// InventoryMovement represents an inventory movement record
-type InventoryMovement struct {
-	ID            string `json:"id"`
-	ProductID     string `json:"product_id"`
-	VariantID     *string `json:"variant_id"`
-	MovementType  string `json:"movement_type"`
-	Quantity      int    `json:"quantity"`
-	ReferenceType string `json:"reference_type"`
-	ReferenceID   string `json:"reference_id"`
-	Reason        string `json:"reason"`
-	CreatedBy     string `json:"created_by"`
-	CreatedAt     string `json:"created_at"`
-}
-
-// GetInventoryMovementsRequest represents a request to get inventory movements
+// GetInventoryMovementsRequest represents a request to get inventory movements.
+// Every filter is optional; From and To bound created_at, To exclusive.
 type GetInventoryMovementsRequest struct {
-	ProductID     string `json:"product_id"`
-	MovementType  string `json:"movement_type"`
-	ReferenceType string `json:"reference_type"`
-	Limit         int    `json:"limit"`
-	Offset        int    `json:"offset"`
+	ProductID     string     `json:"product_id"`
+	VariantID     string     `json:"variant_id"`
+	MovementType  string     `json:"movement_type"`
+	ReferenceType string     `json:"reference_type"`
+	ReferenceID   string     `json:"reference_id"`
+	CreatedBy     string     `json:"created_by"`
+	From          *time.Time `json:"from"`
+	To            *time.Time `json:"to"`
+	Limit         int        `json:"limit"`
+	Offset        int        `json:"offset"`
 }
 
 // GetInventoryMovementsResponse represents a response to get inventory movements
 type GetInventoryMovementsResponse struct {
-	Movements []*InventoryMovement `json:"movements"`
-	Total     int                  `json:"total"`
-	Limit     int                  `json:"limit"`
-	Offset    int                  `json:"offset"`
+	Movements []*models.InventoryMovement `json:"movements"`
+	Total     int                         `json:"total"`
+	Limit     int                         `json:"limit"`
+	Offset    int                         `json:"offset"`
+}
+
+// StockAuditReport lists the products and variants whose stored stock differs
+// from the stock recomputed from the inventory ledger
+type StockAuditReport struct {
+	Checked int                  `json:"checked"`
+	Drifts  []*models.StockDrift `json:"drifts"`
+}
+
+// Warehouse DTOs
+
+// CreateWarehouseRequest represents a request to create a warehouse
+type CreateWarehouseRequest struct {
+	Code     string         `json:"code" validate:"required"`
+	Name     string         `json:"name" validate:"required"`
+	Address  models.Address `json:"address"`
+	Priority int            `json:"priority"` // lower numbers are preferred
+}
+
+// UpdateWarehouseRequest represents a request to update a warehouse.
+// Warehouses are deactivated rather than deleted.
+type UpdateWarehouseRequest struct {
+	Code     *string         `json:"code"`
+	Name     *string         `json:"name"`
+	Address  *models.Address `json:"address"`
+	Priority *int            `json:"priority"`
+	IsActive *bool           `json:"is_active"`
+}
+
+// WarehouseStockRequest represents stock received at, or removed from, a
+// warehouse
+type WarehouseStockRequest struct {
+	ProductID string `json:"product_id" validate:"required"`
+	VariantID string `json:"variant_id"`
+	Quantity  int    `json:"quantity" validate:"required"`
+	Type      string `json:"type" validate:"required"` // "in" or "out"
+	Reason    string `json:"reason"`
+}
+
+// TransferStockRequest represents a request to move stock between
+// warehouses. Without a source warehouse it assigns stock that is not kept
+// at any warehouse yet.
+type TransferStockRequest struct {
+	FromWarehouseID string `json:"from_warehouse_id"`
+	ToWarehouseID   string `json:"to_warehouse_id" validate:"required"`
+	ProductID       string `json:"product_id" validate:"required"`
+	VariantID       string `json:"variant_id"`
+	Quantity        int    `json:"quantity" validate:"required"`
+	Reason          string `json:"reason"`
+}
+
+// AllocateStockRequest represents a request to allocate the stock reserved
+// for an order or cart to the warehouses it will ship from
+type AllocateStockRequest struct {
+	ReferenceType   string                    `json:"reference_type" validate:"required"`
+	ReferenceID     string                    `json:"reference_id" validate:"required"`
+	ShippingAddress models.Address            `json:"shipping_address"`
+	Strategy        models.AllocationStrategy `json:"strategy"` // defaults to the configured strategy
+}
+
+// SetStockThresholdRequest represents a request to set the reorder threshold
+// of a product, or of one of its variants
+type SetStockThresholdRequest struct {
+	VariantID string `json:"variant_id"`
+	Threshold int    `json:"threshold"`
+}
+
+// BackInStockRequest represents a customer's request to be notified when a
+// product, or one of its variants, is available again
+type BackInStockRequest struct {
+	VariantID string                     `json:"variant_id"`
+	UserID    string                     `json:"-"`       // the authenticated user, never read from the body
+	Channel   models.NotificationChannel `json:"channel"` // defaults to email
+	Recipient string                     `json:"recipient" validate:"required"`
 }
 
 // ProductStockInfo represents product stock information
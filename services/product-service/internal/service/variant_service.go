@@ -0,0 +1,577 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/product-service/internal/search"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+// maxGeneratedVariants caps how many variants GenerateVariants creates at once
+const maxGeneratedVariants = 100
+
+// VariantService handles product variant business logic
+type VariantService struct {
+	variantRepo   repository.VariantRepository
+	productRepo   repository.ProductRepository
+	searchService search.SearchService
+}
+
+// NewVariantService creates a new variant service
+func NewVariantService(variantRepo repository.VariantRepository, productRepo repository.ProductRepository, searchService search.SearchService) *VariantService {
+	return &VariantService{
+		variantRepo:   variantRepo,
+		productRepo:   productRepo,
+		searchService: searchService,
+	}
+}
+
+// ListVariants retrieves the variants of a product, default variant first
+func (s *VariantService) ListVariants(ctx context.Context, productID string) ([]*models.ProductVariant, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	variants, err := s.variantRepo.ListByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if variants == nil {
+		variants = []*models.ProductVariant{}
+	}
+
+	return variants, nil
+}
+
+// GetVariant retrieves a variant of a product
+func (s *VariantService) GetVariant(ctx context.Context, productID, variantID string) (*models.ProductVariant, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if variantID == "" {
+		return nil, utils.NewValidationError("variant ID is required")
+	}
+
+	variant, err := s.variantRepo.GetByID(ctx, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if variant.ProductID != productID {
+		return nil, utils.NewNotFoundError("product variant")
+	}
+
+	return variant, nil
+}
+
+// CreateVariant creates a variant of a product. The first variant of a
+// product becomes its default.
+func (s *VariantService) CreateVariant(ctx context.Context, productID string, req CreateVariantRequest) (*models.ProductVariant, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if err := validateCreateVariantRequest(req); err != nil {
+		return nil, err
+	}
+
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	price := product.Price
+	if req.Price != nil {
+		price = *req.Price
+	}
+	if err := validateVariantPricing(product.Currency, price, req.Prices, req.ComparePrice, req.CostPrice); err != nil {
+		return nil, err
+	}
+
+	attributes, err := variantOptionValues(product.Options, stringAttributes(req.Attributes))
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.variantRepo.ListByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateCombination(product.Options, existing, attributes, ""); err != nil {
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = variantName(product, attributes)
+	}
+
+	variant := models.NewProductVariant(product.ID, req.SKU, name, price)
+	variant.Prices = normalizePriceList(req.Prices)
+	variant.ComparePrice = req.ComparePrice
+	variant.CostPrice = req.CostPrice
+	variant.Stock = req.Stock
+	variant.Weight = req.Weight
+	variant.Attributes = attributes
+	variant.ImageURL = req.ImageURL
+	variant.IsDefault = req.IsDefault || len(existing) == 0
+
+	if err := s.variantRepo.Create(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	s.reindexProduct(ctx, product)
+
+	return variant, nil
+}
+
+// UpdateVariant updates a variant of a product. A stock change is recorded
+// as an inventory movement of the difference from the variant's locked stock
+// and may not go below its reserved stock.
+func (s *VariantService) UpdateVariant(ctx context.Context, productID, variantID string, req UpdateVariantRequest) (*models.ProductVariant, error) {
+	if err := validateUpdateVariantRequest(req); err != nil {
+		return nil, err
+	}
+
+	variant, err := s.GetVariant(ctx, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SKU != nil {
+		variant.SKU = *req.SKU
+	}
+	if req.Name != nil {
+		variant.Name = *req.Name
+	}
+	if req.Price != nil {
+		variant.Price = *req.Price
+	}
+	if req.Prices != nil {
+		variant.Prices = normalizePriceList(req.Prices)
+	}
+	if req.ComparePrice != nil {
+		variant.ComparePrice = req.ComparePrice
+	}
+	if req.CostPrice != nil {
+		variant.CostPrice = req.CostPrice
+	}
+	if req.Weight != nil {
+		variant.Weight = *req.Weight
+	}
+	if req.ImageURL != nil {
+		variant.ImageURL = *req.ImageURL
+	}
+	if req.IsDefault != nil {
+		if !*req.IsDefault && variant.IsDefault {
+			return nil, utils.NewValidationError("make another variant the default instead")
+		}
+		variant.IsDefault = *req.IsDefault
+	}
+
+	if err := validateVariantPricing(product.Currency, variant.Price, variant.Prices, variant.ComparePrice, variant.CostPrice); err != nil {
+		return nil, err
+	}
+
+	if req.Attributes != nil {
+		attributes, err := variantOptionValues(product.Options, stringAttributes(req.Attributes))
+		if err != nil {
+			return nil, err
+		}
+
+		existing, err := s.variantRepo.ListByProduct(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDuplicateCombination(product.Options, existing, attributes, variant.ID); err != nil {
+			return nil, err
+		}
+		variant.Attributes = attributes
+	}
+
+	if req.Stock != nil && *req.Stock < variant.ReservedStock {
+		return nil, utils.NewConflictError(fmt.Sprintf("stock cannot be below the %d reserved units", variant.ReservedStock))
+	}
+
+	if err := s.variantRepo.Update(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	if req.Stock != nil {
+		if err := s.productRepo.SetVariantStock(ctx, productID, variant.ID, *req.Stock, "Variant stock updated"); err != nil {
+			return nil, err
+		}
+		variant.Stock = *req.Stock
+	}
+
+	s.reindexProduct(ctx, product)
+
+	return variant, nil
+}
+
+// DeleteVariant deletes a variant of a product. When it was the default,
+// the next variant becomes the default.
+func (s *VariantService) DeleteVariant(ctx context.Context, productID, variantID string) error {
+	variant, err := s.GetVariant(ctx, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.variantRepo.Delete(ctx, variant.ID); err != nil {
+		return err
+	}
+
+	if variant.IsDefault {
+		remaining, err := s.variantRepo.ListByProduct(ctx, productID)
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			remaining[0].IsDefault = true
+			if err := s.variantRepo.Update(ctx, remaining[0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if product, err := s.productRepo.GetByID(ctx, productID); err == nil {
+		s.reindexProduct(ctx, product)
+	}
+
+	return nil
+}
+
+// GenerateVariants creates a variant for every combination of the product's
+// option values that does not have one yet
+func (s *VariantService) GenerateVariants(ctx context.Context, productID string, req GenerateVariantsRequest) ([]*models.ProductVariant, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if req.Stock < 0 {
+		return nil, utils.NewValidationError("stock must be non-negative")
+	}
+
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.Options) == 0 {
+		return nil, utils.NewValidationError("product has no options to generate variants from")
+	}
+
+	price := product.Price
+	if req.Price != nil {
+		price = *req.Price
+	}
+	if err := validateVariantPricing(product.Currency, price, nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	combinations := optionCombinations(product.Options)
+	if len(combinations) > maxGeneratedVariants {
+		return nil, utils.NewValidationError(fmt.Sprintf("options have %d combinations, more than the %d that can be generated", len(combinations), maxGeneratedVariants))
+	}
+
+	existing, err := s.variantRepo.ListByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, variant := range existing {
+		taken[combinationKey(product.Options, variant.Attributes)] = true
+	}
+
+	created := []*models.ProductVariant{}
+	for _, attributes := range combinations {
+		if taken[combinationKey(product.Options, attributes)] {
+			continue
+		}
+
+		sku := variantSKU(product.SKU, product.Options, attributes)
+		v := utils.NewValidator()
+		if v.SKU("sku", sku); v.HasErrors() {
+			return created, utils.NewValidationError(fmt.Sprintf("generated SKU %s is invalid: %s", sku, v.Errors().Error()))
+		}
+
+		variant := models.NewProductVariant(product.ID, sku, variantName(product, attributes), price)
+		variant.Stock = req.Stock
+		variant.Attributes = attributes
+		variant.IsDefault = len(existing) == 0 && len(created) == 0
+
+		if err := s.variantRepo.Create(ctx, variant); err != nil {
+			return created, err
+		}
+		created = append(created, variant)
+	}
+
+	if len(created) > 0 {
+		s.reindexProduct(ctx, product)
+	}
+
+	return created, nil
+}
+
+// reindexProduct indexes the product with its current variants
+func (s *VariantService) reindexProduct(ctx context.Context, product *models.Product) {
+	if s.searchService == nil {
+		return
+	}
+
+	variants, err := s.variantRepo.ListByProduct(ctx, product.ID)
+	if err == nil {
+		product.Variants = make([]models.ProductVariant, 0, len(variants))
+		for _, variant := range variants {
+			product.Variants = append(product.Variants, *variant)
+		}
+		err = s.searchService.IndexProduct(ctx, product)
+	}
+	if err != nil {
+		// Log error but don't fail the operation
+		utils.Logger.Error(ctx, "Failed to reindex product variants in Elasticsearch", err, map[string]interface{}{
+			"product_id": product.ID,
+		})
+	}
+}
+
+// validateCreateVariantRequest validates create variant request
+func validateCreateVariantRequest(req CreateVariantRequest) error {
+	v := utils.NewValidator()
+
+	v.Required("sku", req.SKU).SKU("sku", req.SKU)
+	v.MaxLength("name", req.Name, 255)
+
+	if req.Stock < 0 {
+		return utils.NewValidationError("stock must be non-negative")
+	}
+	if req.Weight < 0 {
+		return utils.NewValidationError("weight must be non-negative")
+	}
+
+	if v.HasErrors() {
+		return utils.NewValidationError(v.Errors().Error())
+	}
+
+	return nil
+}
+
+// validateUpdateVariantRequest validates update variant request
+func validateUpdateVariantRequest(req UpdateVariantRequest) error {
+	v := utils.NewValidator()
+
+	if req.SKU != nil {
+		v.Required("sku", *req.SKU).SKU("sku", *req.SKU)
+	}
+	if req.Name != nil {
+		v.Required("name", *req.Name).MaxLength("name", *req.Name, 255)
+	}
+
+	if req.Stock != nil && *req.Stock < 0 {
+		return utils.NewValidationError("stock must be non-negative")
+	}
+	if req.Weight != nil && *req.Weight < 0 {
+		return utils.NewValidationError("weight must be non-negative")
+	}
+
+	if v.HasErrors() {
+		return utils.NewValidationError(v.Errors().Error())
+	}
+
+	return nil
+}
+
+// validateVariantPricing validates a variant's prices, which are in the
+// currency of its product
+func validateVariantPricing(baseCurrency string, price decimal.Decimal, prices models.PriceList, comparePrice, costPrice *decimal.Decimal) error {
+	if !price.IsPositive() {
+		return utils.NewValidationError("price must be positive")
+	}
+	if err := validateProductPricing(baseCurrency, price, prices); err != nil {
+		return err
+	}
+
+	if comparePrice != nil && comparePrice.IsNegative() {
+		return utils.NewValidationError("compare_price must be non-negative")
+	}
+	if costPrice != nil && costPrice.IsNegative() {
+		return utils.NewValidationError("cost_price must be non-negative")
+	}
+
+	return nil
+}
+
+// normalizeOptions trims option names and values and rejects empty or
+// repeated ones. Names and values are compared case-insensitively.
+func normalizeOptions(options []models.ProductOption) ([]models.ProductOption, error) {
+	normalized := make([]models.ProductOption, 0, len(options))
+	names := make(map[string]bool, len(options))
+
+	for _, option := range options {
+		name := strings.TrimSpace(option.Name)
+		if name == "" {
+			return nil, utils.NewValidationError("option name is required")
+		}
+		if names[strings.ToLower(name)] {
+			return nil, utils.NewValidationError(fmt.Sprintf("option %s is repeated", name))
+		}
+		names[strings.ToLower(name)] = true
+
+		if len(option.Values) == 0 {
+			return nil, utils.NewValidationError(fmt.Sprintf("option %s must have at least one value", name))
+		}
+
+		values := make([]string, 0, len(option.Values))
+		seen := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return nil, utils.NewValidationError(fmt.Sprintf("option %s has an empty value", name))
+			}
+			if seen[strings.ToLower(value)] {
+				return nil, utils.NewValidationError(fmt.Sprintf("option %s repeats value %s", name, value))
+			}
+			seen[strings.ToLower(value)] = true
+			values = append(values, value)
+		}
+
+		normalized = append(normalized, models.ProductOption{Name: name, Values: values})
+	}
+
+	return normalized, nil
+}
+
+// variantOptionValues checks that attributes hold one valid value for each
+// option and nothing else, and returns them spelled as the options spell them
+func variantOptionValues(options []models.ProductOption, attributes map[string]interface{}) (map[string]interface{}, error) {
+	variant := models.ProductVariant{Attributes: attributes}
+	values := make(map[string]interface{}, len(options))
+
+	for _, option := range options {
+		value := variant.OptionValue(option.Name)
+		if value == "" {
+			return nil, utils.NewValidationError(fmt.Sprintf("a value for option %s is required", option.Name))
+		}
+
+		canonical := ""
+		for _, allowed := range option.Values {
+			if strings.EqualFold(allowed, strings.TrimSpace(value)) {
+				canonical = allowed
+				break
+			}
+		}
+		if canonical == "" {
+			return nil, utils.NewValidationError(fmt.Sprintf("%s is not a value of option %s", value, option.Name))
+		}
+		values[option.Name] = canonical
+	}
+
+	for key := range attributes {
+		known := false
+		for _, option := range options {
+			known = known || strings.EqualFold(key, option.Name)
+		}
+		if !known {
+			return nil, utils.NewValidationError(fmt.Sprintf("%s is not an option of the product", key))
+		}
+	}
+
+	return values, nil
+}
+
+// checkDuplicateCombination rejects option values another variant already has
+func checkDuplicateCombination(options []models.ProductOption, variants []*models.ProductVariant, attributes map[string]interface{}, exceptID string) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	key := combinationKey(options, attributes)
+	for _, variant := range variants {
+		if variant.ID != exceptID && combinationKey(options, variant.Attributes) == key {
+			return utils.NewConflictError(fmt.Sprintf("variant %s already has these option values", variant.SKU))
+		}
+	}
+	return nil
+}
+
+// combinationKey identifies a variant's option values, in option order
+func combinationKey(options []models.ProductOption, attributes map[string]interface{}) string {
+	variant := models.ProductVariant{Attributes: attributes}
+	values := make([]string, len(options))
+	for i, option := range options {
+		values[i] = strings.ToLower(variant.OptionValue(option.Name))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// optionCombinations returns the attributes of every combination of option values
+func optionCombinations(options []models.ProductOption) []map[string]interface{} {
+	combinations := []map[string]interface{}{{}}
+	for _, option := range options {
+		next := make([]map[string]interface{}, 0, len(combinations)*len(option.Values))
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				attributes := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					attributes[k] = v
+				}
+				attributes[option.Name] = value
+				next = append(next, attributes)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// variantName names a variant after its product and option values, e.g.
+// "T-Shirt - Red / M"
+func variantName(product *models.Product, attributes map[string]interface{}) string {
+	variant := models.ProductVariant{Attributes: attributes}
+	values := make([]string, 0, len(product.Options))
+	for _, option := range product.Options {
+		values = append(values, variant.OptionValue(option.Name))
+	}
+	if len(values) == 0 {
+		return product.Name
+	}
+	return product.Name + " - " + strings.Join(values, " / ")
+}
+
+// variantSKU derives a variant SKU from the product SKU and option values,
+// e.g. TSHIRT-RED-M
+func variantSKU(productSKU string, options []models.ProductOption, attributes map[string]interface{}) string {
+	variant := models.ProductVariant{Attributes: attributes}
+	parts := []string{productSKU}
+	for _, option := range options {
+		var b strings.Builder
+		for _, r := range strings.ToUpper(variant.OptionValue(option.Name)) {
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			} else if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+				b.WriteByte('-')
+			}
+		}
+		parts = append(parts, strings.TrimSuffix(b.String(), "-"))
+	}
+	return strings.Join(parts, "-")
+}
+
+// stringAttributes converts request attributes to a variant's attribute map
+func stringAttributes(attributes map[string]string) map[string]interface{} {
+	converted := make(map[string]interface{}, len(attributes))
+	for k, v := range attributes {
+		converted[k] = v
+	}
+	return converted
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+	"github.com/shopspring/decimal"
+)
+
+// Mock variant repository
+type mockVariantRepository struct {
+	variants map[string]*models.ProductVariant
+}
+
+func newMockVariantRepository() *mockVariantRepository {
+	return &mockVariantRepository{
+		variants: make(map[string]*models.ProductVariant),
+	}
+}
+
+func (m *mockVariantRepository) Create(ctx context.Context, variant *models.ProductVariant) error {
+	for _, v := range m.variants {
+		if v.SKU == variant.SKU {
+			return utils.NewConflictError("variant with this SKU already exists")
+		}
+	}
+	m.clearDefault(variant)
+	copied := *variant
+	m.variants[variant.ID] = &copied
+	return nil
+}
+
+func (m *mockVariantRepository) GetByID(ctx context.Context, id string) (*models.ProductVariant, error) {
+	variant, exists := m.variants[id]
+	if !exists {
+		return nil, utils.NewNotFoundError("product variant")
+	}
+	copied := *variant
+	return &copied, nil
+}
+
+func (m *mockVariantRepository) Update(ctx context.Context, variant *models.ProductVariant) error {
+	existing, exists := m.variants[variant.ID]
+	if !exists {
+		return utils.NewNotFoundError("product variant")
+	}
+	m.clearDefault(variant)
+	copied := *variant
+	copied.Stock = existing.Stock
+	m.variants[variant.ID] = &copied
+	return nil
+}
+
+func (m *mockVariantRepository) Delete(ctx context.Context, id string) error {
+	if _, exists := m.variants[id]; !exists {
+		return utils.NewNotFoundError("product variant")
+	}
+	delete(m.variants, id)
+	return nil
+}
+
+func (m *mockVariantRepository) ListByProduct(ctx context.Context, productID string) ([]*models.ProductVariant, error) {
+	variants, _ := m.ListByProducts(ctx, []string{productID})
+	return variants[productID], nil
+}
+
+func (m *mockVariantRepository) ListByProducts(ctx context.Context, productIDs []string) (map[string][]*models.ProductVariant, error) {
+	result := make(map[string][]*models.ProductVariant)
+	for _, productID := range productIDs {
+		for _, v := range m.variants {
+			if v.ProductID == productID {
+				copied := *v
+				result[productID] = append(result[productID], &copied)
+			}
+		}
+		sort.Slice(result[productID], func(i, j int) bool {
+			a, b := result[productID][i], result[productID][j]
+			if a.IsDefault != b.IsDefault {
+				return a.IsDefault
+			}
+			return a.SKU < b.SKU
+		})
+	}
+	return result, nil
+}
+
+func (m *mockVariantRepository) clearDefault(variant *models.ProductVariant) {
+	if !variant.IsDefault {
+		return
+	}
+	for _, v := range m.variants {
+		if v.ProductID == variant.ProductID && v.ID != variant.ID {
+			v.IsDefault = false
+		}
+	}
+}
+
+// stockRecordingRepository records stock updates and applies them to variants
+type stockRecordingRepository struct {
+	*mockProductRepository
+	variants *mockVariantRepository
+	updates  []repository.StockUpdate
+
+	// beforeSet runs before a variant's stock is locked, to simulate a
+	// concurrent movement
+	beforeSet func(variant *models.ProductVariant)
+}
+
+func (m *stockRecordingRepository) SetVariantStock(ctx context.Context, productID, variantID string, stock int, reason string) error {
+	variant, exists := m.variants.variants[variantID]
+	if !exists || variant.ProductID != productID {
+		return utils.NewNotFoundError("product variant")
+	}
+	if m.beforeSet != nil {
+		m.beforeSet(variant)
+	}
+	if stock < variant.ReservedStock {
+		return utils.NewConflictError(fmt.Sprintf("stock cannot be below the %d reserved units", variant.ReservedStock))
+	}
+	if stock == variant.Stock {
+		return nil
+	}
+
+	update := repository.StockUpdate{
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  stock - variant.Stock,
+		Type:      "in",
+		Reason:    reason,
+	}
+	if update.Quantity < 0 {
+		update.Quantity = -update.Quantity
+		update.Type = "out"
+	}
+	return m.BulkUpdateStock(ctx, []repository.StockUpdate{update})
+}
+
+func (m *stockRecordingRepository) BulkUpdateStock(ctx context.Context, updates []repository.StockUpdate) error {
+	for _, update := range updates {
+		if variant, exists := m.variants.variants[update.VariantID]; exists {
+			if update.Type == "out" {
+				variant.Stock -= update.Quantity
+			} else {
+				variant.Stock += update.Quantity
+			}
+		}
+	}
+	m.updates = append(m.updates, updates...)
+	return nil
+}
+
+func newVariantTestService() (*VariantService, *stockRecordingRepository, *models.Product) {
+	variantRepo := newMockVariantRepository()
+	productRepo := &stockRecordingRepository{mockProductRepository: newMockProductRepository(), variants: variantRepo}
+
+	product := models.NewProduct("TSHIRT", "T-Shirt", "A cotton t-shirt", "", decimal.NewFromFloat(20))
+	product.Options = []models.ProductOption{
+		{Name: "Color", Values: []string{"Red", "Navy Blue"}},
+		{Name: "Size", Values: []string{"S", "M"}},
+	}
+	productRepo.products[product.ID] = product
+
+	return NewVariantService(variantRepo, productRepo, nil), productRepo, product
+}
+
+func TestVariantService_CreateVariant(t *testing.T) {
+	service, _, product := newVariantTestService()
+	ctx := context.Background()
+
+	variant, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Stock:      5,
+		Attributes: map[string]string{"color": "red", "SIZE": "m"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if variant.Name != "T-Shirt - Red / M" {
+		t.Errorf("Expected generated name, got %s", variant.Name)
+	}
+	if variant.Attributes["Color"] != "Red" || variant.Attributes["Size"] != "M" {
+		t.Errorf("Expected attributes spelled as the options, got %v", variant.Attributes)
+	}
+	if !variant.Price.Equal(product.Price) {
+		t.Errorf("Expected product price %s, got %s", product.Price, variant.Price)
+	}
+	if !variant.IsDefault {
+		t.Error("Expected the first variant to be the default")
+	}
+
+	// Same option values under another SKU
+	_, err = service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M2",
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error for duplicate option values, got %v", err)
+	}
+
+	// Second variant does not take over the default
+	second, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-S",
+		Attributes: map[string]string{"Color": "Red", "Size": "S"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if second.IsDefault {
+		t.Error("Expected only the first variant to be the default")
+	}
+}
+
+func TestVariantService_CreateVariant_ValidationError(t *testing.T) {
+	service, _, product := newVariantTestService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  CreateVariantRequest
+	}{
+		{"missing option", CreateVariantRequest{SKU: "TSHIRT-RED", Attributes: map[string]string{"Color": "Red"}}},
+		{"unknown value", CreateVariantRequest{SKU: "TSHIRT-GREEN-M", Attributes: map[string]string{"Color": "Green", "Size": "M"}}},
+		{"unknown option", CreateVariantRequest{SKU: "TSHIRT-RED-M", Attributes: map[string]string{"Color": "Red", "Size": "M", "Fit": "Slim"}}},
+		{"invalid SKU", CreateVariantRequest{SKU: "tshirt red", Attributes: map[string]string{"Color": "Red", "Size": "M"}}},
+		{"negative stock", CreateVariantRequest{SKU: "TSHIRT-RED-M", Stock: -1, Attributes: map[string]string{"Color": "Red", "Size": "M"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateVariant(ctx, product.ID, tt.req)
+			if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrValidation {
+				t.Errorf("Expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVariantService_UpdateVariant_Stock(t *testing.T) {
+	service, productRepo, product := newVariantTestService()
+	ctx := context.Background()
+
+	variant, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Stock:      10,
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	productRepo.variants.variants[variant.ID].ReservedStock = 4
+
+	stock := 3
+	_, err = service.UpdateVariant(ctx, product.ID, variant.ID, UpdateVariantRequest{Stock: &stock})
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error below reserved stock, got %v", err)
+	}
+
+	stock = 6
+	updated, err := service.UpdateVariant(ctx, product.ID, variant.ID, UpdateVariantRequest{Stock: &stock})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.Stock != 6 {
+		t.Errorf("Expected stock 6, got %d", updated.Stock)
+	}
+
+	if len(productRepo.updates) != 1 {
+		t.Fatalf("Expected 1 stock movement, got %d", len(productRepo.updates))
+	}
+	movement := productRepo.updates[0]
+	if movement.VariantID != variant.ID || movement.Type != "out" || movement.Quantity != 4 {
+		t.Errorf("Expected an out movement of 4 for the variant, got %+v", movement)
+	}
+}
+
+func TestVariantService_UpdateVariant_StockChangedConcurrently(t *testing.T) {
+	service, productRepo, product := newVariantTestService()
+	ctx := context.Background()
+
+	variant, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Stock:      10,
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A sale of 3 lands after the variant was read for the update
+	productRepo.beforeSet = func(v *models.ProductVariant) {
+		productRepo.beforeSet = nil
+		v.Stock -= 3
+	}
+
+	stock := 12
+	if _, err := service.UpdateVariant(ctx, product.ID, variant.ID, UpdateVariantRequest{Stock: &stock}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := productRepo.variants.variants[variant.ID].Stock; got != 12 {
+		t.Errorf("Expected stock 12, got %d", got)
+	}
+	if len(productRepo.updates) != 1 {
+		t.Fatalf("Expected 1 stock movement, got %d", len(productRepo.updates))
+	}
+	movement := productRepo.updates[0]
+	if movement.Type != "in" || movement.Quantity != 5 {
+		t.Errorf("Expected an in movement of 5 from the locked stock, got %+v", movement)
+	}
+}
+
+func TestVariantService_GetVariant_OtherProduct(t *testing.T) {
+	service, productRepo, product := newVariantTestService()
+	ctx := context.Background()
+
+	variant, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	other := models.NewProduct("MUG", "Mug", "A mug", "", decimal.NewFromFloat(8))
+	productRepo.products[other.ID] = other
+
+	_, err = service.GetVariant(ctx, other.ID, variant.ID)
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrNotFound {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestVariantService_DeleteVariant_PromotesDefault(t *testing.T) {
+	service, _, product := newVariantTestService()
+	ctx := context.Background()
+
+	first, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-S",
+		Attributes: map[string]string{"Color": "Red", "Size": "S"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := service.DeleteVariant(ctx, product.ID, first.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	remaining, err := service.GetVariant(ctx, product.ID, second.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !remaining.IsDefault {
+		t.Error("Expected the remaining variant to become the default")
+	}
+}
+
+func TestVariantService_GenerateVariants(t *testing.T) {
+	service, _, product := newVariantTestService()
+	ctx := context.Background()
+
+	if _, err := service.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	created, err := service.GenerateVariants(ctx, product.ID, GenerateVariantsRequest{Stock: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(created) != 3 {
+		t.Fatalf("Expected 3 new variants, got %d", len(created))
+	}
+
+	skus := make(map[string]bool)
+	for _, variant := range created {
+		skus[variant.SKU] = true
+		if variant.IsDefault {
+			t.Errorf("Expected %s not to take over the default", variant.SKU)
+		}
+		if variant.Stock != 2 {
+			t.Errorf("Expected stock 2, got %d", variant.Stock)
+		}
+	}
+	for _, sku := range []string{"TSHIRT-RED-S", "TSHIRT-NAVY-BLUE-S", "TSHIRT-NAVY-BLUE-M"} {
+		if !skus[sku] {
+			t.Errorf("Expected generated SKU %s, got %v", sku, skus)
+		}
+	}
+
+	// Nothing left to generate
+	created, err = service.GenerateVariants(ctx, product.ID, GenerateVariantsRequest{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(created) != 0 {
+		t.Errorf("Expected no new variants, got %d", len(created))
+	}
+}
+
+func TestProductService_UpdateProduct_OptionsKeepVariantsValid(t *testing.T) {
+	variantService, productRepo, product := newVariantTestService()
+	service := NewProductService(productRepo, newMockCategoryRepository(), variantService.variantRepo, nil, nil)
+	ctx := context.Background()
+
+	if _, err := variantService.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Dropping the value the variant uses
+	_, err := service.UpdateProduct(ctx, product.ID, UpdateProductRequest{
+		Options: []models.ProductOption{
+			{Name: "Color", Values: []string{"Navy Blue"}},
+			{Name: "Size", Values: []string{"S", "M"}},
+		},
+	})
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrValidation {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	// Repeated option names
+	_, err = service.UpdateProduct(ctx, product.ID, UpdateProductRequest{
+		Options: []models.ProductOption{
+			{Name: "Color", Values: []string{"Red"}},
+			{Name: "color", Values: []string{"Blue"}},
+		},
+	})
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrValidation {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	updated, err := service.UpdateProduct(ctx, product.ID, UpdateProductRequest{
+		Options: []models.ProductOption{
+			{Name: "Color", Values: []string{"Red", "Green"}},
+			{Name: "Size", Values: []string{" S ", "M", "L"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(updated.Variants) != 1 || updated.Options[1].Values[0] != "S" {
+		t.Errorf("Expected trimmed options and the product's variant, got %+v", updated)
+	}
+}
+
+func TestProductService_ReserveStock_Variant(t *testing.T) {
+	variantService, productRepo, product := newVariantTestService()
+	service := NewProductService(productRepo, newMockCategoryRepository(), variantService.variantRepo, nil, nil)
+	ctx := context.Background()
+	product.Stock = 50
+
+	variant, err := variantService.CreateVariant(ctx, product.ID, CreateVariantRequest{
+		SKU:        "TSHIRT-RED-M",
+		Stock:      3,
+		Attributes: map[string]string{"Color": "Red", "Size": "M"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := service.ReserveStock(ctx, product.ID, variant.ID, 3); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// The product has stock to spare but the variant does not
+	err = service.ReserveStock(ctx, product.ID, variant.ID, 4)
+	if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != utils.ErrConflict {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// WarehouseConfig holds how reserved stock is allocated to warehouses
+type WarehouseConfig struct {
+	DefaultStrategy models.AllocationStrategy // used when a request has no strategy
+}
+
+// DefaultWarehouseConfig returns the default warehouse configuration
+func DefaultWarehouseConfig() WarehouseConfig {
+	return WarehouseConfig{
+		DefaultStrategy: models.AllocationNearest,
+	}
+}
+
+// WarehouseService handles warehouses, the stock kept at them and the
+// allocation of reserved stock to the warehouses it ships from
+type WarehouseService struct {
+	warehouseRepo   repository.WarehouseRepository
+	reservationRepo repository.ReservationRepository
+	config          WarehouseConfig
+}
+
+// NewWarehouseService creates a new warehouse service
+func NewWarehouseService(warehouseRepo repository.WarehouseRepository, reservationRepo repository.ReservationRepository, config WarehouseConfig) *WarehouseService {
+	return &WarehouseService{
+		warehouseRepo:   warehouseRepo,
+		reservationRepo: reservationRepo,
+		config:          config,
+	}
+}
+
+// CreateWarehouse creates a new warehouse
+func (s *WarehouseService) CreateWarehouse(ctx context.Context, req CreateWarehouseRequest) (*models.Warehouse, error) {
+	if req.Code == "" {
+		return nil, utils.NewValidationError("warehouse code is required")
+	}
+	if req.Name == "" {
+		return nil, utils.NewValidationError("warehouse name is required")
+	}
+	if err := validateWarehouseAddress(req.Address); err != nil {
+		return nil, err
+	}
+
+	warehouse := models.NewWarehouse(req.Code, req.Name, req.Address, req.Priority)
+	if err := s.warehouseRepo.Create(ctx, warehouse); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info(ctx, "Warehouse created", map[string]interface{}{
+		"warehouse_id": warehouse.ID,
+		"code":         warehouse.Code,
+	})
+
+	return warehouse, nil
+}
+
+// GetWarehouse retrieves a warehouse by ID
+func (s *WarehouseService) GetWarehouse(ctx context.Context, id string) (*models.Warehouse, error) {
+	if id == "" {
+		return nil, utils.NewValidationError("warehouse ID is required")
+	}
+	return s.warehouseRepo.GetByID(ctx, id)
+}
+
+// UpdateWarehouse updates a warehouse. An inactive warehouse keeps its stock
+// but receives no stock and is not allocated any.
+func (s *WarehouseService) UpdateWarehouse(ctx context.Context, id string, req UpdateWarehouseRequest) (*models.Warehouse, error) {
+	warehouse, err := s.GetWarehouse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code != nil {
+		if *req.Code == "" {
+			return nil, utils.NewValidationError("warehouse code is required")
+		}
+		warehouse.Code = *req.Code
+	}
+	if req.Name != nil {
+		if *req.Name == "" {
+			return nil, utils.NewValidationError("warehouse name is required")
+		}
+		warehouse.Name = *req.Name
+	}
+	if req.Address != nil {
+		if err := validateWarehouseAddress(*req.Address); err != nil {
+			return nil, err
+		}
+		warehouse.Address = *req.Address
+	}
+	if req.Priority != nil {
+		warehouse.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		warehouse.IsActive = *req.IsActive
+	}
+
+	if err := s.warehouseRepo.Update(ctx, warehouse); err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
+
+// ListWarehouses returns warehouses in priority order
+func (s *WarehouseService) ListWarehouses(ctx context.Context, activeOnly bool) ([]*models.Warehouse, error) {
+	warehouses, err := s.warehouseRepo.List(ctx, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	if warehouses == nil {
+		warehouses = []*models.Warehouse{}
+	}
+	return warehouses, nil
+}
+
+// ListWarehouseStock returns the stock kept at a warehouse
+func (s *WarehouseService) ListWarehouseStock(ctx context.Context, warehouseID string) ([]*models.WarehouseStock, error) {
+	if _, err := s.GetWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.listStock(ctx, repository.WarehouseStockFilter{WarehouseID: warehouseID})
+}
+
+// ListProductStock returns the stock of a product and its variants at each
+// warehouse
+func (s *WarehouseService) ListProductStock(ctx context.Context, productID string) ([]*models.WarehouseStock, error) {
+	if productID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	return s.listStock(ctx, repository.WarehouseStockFilter{ProductIDs: []string{productID}})
+}
+
+// MoveStock receives stock at, or removes stock from, a warehouse
+func (s *WarehouseService) MoveStock(ctx context.Context, warehouseID string, req WarehouseStockRequest) error {
+	if req.ProductID == "" {
+		return utils.NewValidationError("product ID is required")
+	}
+	if req.Quantity <= 0 {
+		return utils.NewValidationError("quantity must be positive")
+	}
+	if req.Type != "in" && req.Type != "out" {
+		return utils.NewValidationError("type must be in or out")
+	}
+
+	warehouse, err := s.GetWarehouse(ctx, warehouseID)
+	if err != nil {
+		return err
+	}
+	if req.Type == "in" && !warehouse.IsActive {
+		return utils.NewConflictError("warehouse is not active")
+	}
+
+	reason := req.Reason
+	if reason == "" && req.Type == "in" {
+		reason = "Stock received at " + warehouse.Code
+	} else if reason == "" {
+		reason = "Stock removed from " + warehouse.Code
+	}
+
+	return s.warehouseRepo.MoveStock(ctx, warehouse.ID, repository.StockUpdate{
+		ProductID: req.ProductID,
+		VariantID: req.VariantID,
+		Quantity:  req.Quantity,
+		Type:      req.Type,
+		Reason:    reason,
+	})
+}
+
+// TransferStock moves stock between warehouses
+func (s *WarehouseService) TransferStock(ctx context.Context, req TransferStockRequest) (*models.StockTransfer, error) {
+	if req.ProductID == "" {
+		return nil, utils.NewValidationError("product ID is required")
+	}
+	if req.Quantity <= 0 {
+		return nil, utils.NewValidationError("quantity must be positive")
+	}
+	if req.FromWarehouseID == req.ToWarehouseID {
+		return nil, utils.NewValidationError("cannot transfer stock to the warehouse it is at")
+	}
+
+	to, err := s.GetWarehouse(ctx, req.ToWarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if !to.IsActive {
+		return nil, utils.NewConflictError("destination warehouse is not active")
+	}
+
+	from := "unassigned stock"
+	if req.FromWarehouseID != "" {
+		source, err := s.warehouseRepo.GetByID(ctx, req.FromWarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		from = source.Code
+	}
+
+	transfer := &models.StockTransfer{
+		ID:              uuid.New().String(),
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   to.ID,
+		ProductID:       req.ProductID,
+		VariantID:       req.VariantID,
+		Quantity:        req.Quantity,
+		Reason:          req.Reason,
+	}
+	if transfer.Reason == "" {
+		transfer.Reason = "Stock transferred from " + from + " to " + to.Code
+	}
+
+	if err := s.warehouseRepo.Transfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// AllocateStock allocates the stock held by an order's or cart's active
+// reservations to the warehouses it will ship from, replacing any earlier
+// allocation. Units no warehouse can ship are left unallocated rather than
+// failing the allocation; they ship from stock not kept at a warehouse.
+func (s *WarehouseService) AllocateStock(ctx context.Context, req AllocateStockRequest) (*models.AllocationPlan, error) {
+	if !isReservationReferenceType(req.ReferenceType) {
+		return nil, utils.NewValidationError("reference type must be order or cart")
+	}
+	if req.ReferenceID == "" {
+		return nil, utils.NewValidationError("reference ID is required")
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = s.config.DefaultStrategy
+	}
+	if !strategy.IsValid() {
+		return nil, utils.NewValidationError("strategy must be nearest, fewest_splits or priority")
+	}
+
+	reservations, err := s.activeReservations(ctx, req.ReferenceType, req.ReferenceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, utils.NewNotFoundError("stock reservation")
+	}
+
+	warehouses, err := s.warehouseRepo.List(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	byItem := make(map[stockItem]*models.StockReservation, len(reservations))
+	reservationIDs := make([]string, 0, len(reservations))
+	productIDs := make([]string, 0, len(reservations))
+	lines := make([]models.AllocationLine, 0, len(reservations))
+	for _, reservation := range reservations {
+		byItem[stockItem{reservation.ProductID, reservation.VariantID}] = reservation
+		reservationIDs = append(reservationIDs, reservation.ID)
+		productIDs = append(productIDs, reservation.ProductID)
+		lines = append(lines, models.AllocationLine{
+			ProductID: reservation.ProductID,
+			VariantID: reservation.VariantID,
+			Quantity:  reservation.Quantity,
+		})
+	}
+
+	// The reference's own allocations are being replaced, so they do not
+	// count against the stock it can be allocated
+	levels, err := s.warehouseRepo.ListStock(ctx, repository.WarehouseStockFilter{
+		ProductIDs:            productIDs,
+		ExcludeReservationIDs: reservationIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shipments, unallocated := planAllocation(strategy, req.ShippingAddress, warehouses, levels, lines)
+
+	var allocations []*models.StockAllocation
+	for _, shipment := range shipments {
+		for _, line := range shipment.Lines {
+			allocations = append(allocations, &models.StockAllocation{
+				ReservationID: byItem[stockItem{line.ProductID, line.VariantID}].ID,
+				WarehouseID:   shipment.Warehouse.ID,
+				ProductID:     line.ProductID,
+				VariantID:     line.VariantID,
+				Quantity:      line.Quantity,
+			})
+		}
+	}
+
+	if err := s.warehouseRepo.Allocate(ctx, reservations, allocations); err != nil {
+		return nil, err
+	}
+
+	plan := &models.AllocationPlan{
+		ReferenceType: req.ReferenceType,
+		ReferenceID:   req.ReferenceID,
+		Strategy:      strategy,
+		Shipments:     shipments,
+		Unallocated:   unallocated,
+	}
+	if plan.Shipments == nil {
+		plan.Shipments = []models.AllocationShipment{}
+	}
+	if plan.Unallocated == nil {
+		plan.Unallocated = []models.AllocationLine{}
+	}
+
+	fields := map[string]interface{}{
+		"reference_type": req.ReferenceType,
+		"reference_id":   req.ReferenceID,
+		"strategy":       strategy,
+		"shipments":      len(plan.Shipments),
+	}
+	if origin := plan.Origin(); origin != nil {
+		fields["origin"] = origin.Code
+	}
+	utils.Logger.Info(ctx, "Stock allocated to warehouses", fields)
+
+	return plan, nil
+}
+
+// ListAllocations retrieves the allocations of an order's or cart's
+// reservations
+func (s *WarehouseService) ListAllocations(ctx context.Context, referenceType, referenceID string) ([]*models.StockAllocation, error) {
+	if !isReservationReferenceType(referenceType) {
+		return nil, utils.NewValidationError("reference type must be order or cart")
+	}
+	if referenceID == "" {
+		return nil, utils.NewValidationError("reference ID is required")
+	}
+
+	reservations, err := s.reservationRepo.ListByReference(ctx, referenceType, referenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := []*models.StockAllocation{}
+	if len(reservations) == 0 {
+		return allocations, nil
+	}
+
+	reservationIDs := make([]string, 0, len(reservations))
+	for _, reservation := range reservations {
+		reservationIDs = append(reservationIDs, reservation.ID)
+	}
+
+	listed, err := s.warehouseRepo.ListAllocations(ctx, reservationIDs)
+	if err != nil {
+		return nil, err
+	}
+	return append(allocations, listed...), nil
+}
+
+func (s *WarehouseService) activeReservations(ctx context.Context, referenceType, referenceID string) ([]*models.StockReservation, error) {
+	reservations, err := s.reservationRepo.ListByReference(ctx, referenceType, referenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make([]*models.StockReservation, 0, len(reservations))
+	for _, reservation := range reservations {
+		if reservation.IsActive() && !reservation.HasExpired(now) {
+			active = append(active, reservation)
+		}
+	}
+	return active, nil
+}
+
+func (s *WarehouseService) listStock(ctx context.Context, filter repository.WarehouseStockFilter) ([]*models.WarehouseStock, error) {
+	levels, err := s.warehouseRepo.ListStock(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if levels == nil {
+		levels = []*models.WarehouseStock{}
+	}
+	return levels, nil
+}
+
+func validateWarehouseAddress(address models.Address) error {
+	if address.Street == "" || address.City == "" || address.Country == "" {
+		return utils.NewValidationError("warehouse address needs a street, city and country")
+	}
+	return nil
+}
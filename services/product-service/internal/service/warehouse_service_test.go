@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/product-service/internal/repository"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Mock warehouse repository that records the allocations it is given
+type mockWarehouseRepository struct {
+	warehouses  []*models.Warehouse
+	levels      []*models.WarehouseStock
+	filter      repository.WarehouseStockFilter
+	allocations []*models.StockAllocation
+}
+
+func (m *mockWarehouseRepository) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	m.warehouses = append(m.warehouses, warehouse)
+	return nil
+}
+
+func (m *mockWarehouseRepository) GetByID(ctx context.Context, id string) (*models.Warehouse, error) {
+	for _, warehouse := range m.warehouses {
+		if warehouse.ID == id {
+			copied := *warehouse
+			return &copied, nil
+		}
+	}
+	return nil, utils.NewNotFoundError("warehouse")
+}
+
+func (m *mockWarehouseRepository) Update(ctx context.Context, warehouse *models.Warehouse) error {
+	return nil
+}
+
+func (m *mockWarehouseRepository) List(ctx context.Context, activeOnly bool) ([]*models.Warehouse, error) {
+	var warehouses []*models.Warehouse
+	for _, warehouse := range m.warehouses {
+		if !activeOnly || warehouse.IsActive {
+			warehouses = append(warehouses, warehouse)
+		}
+	}
+	return warehouses, nil
+}
+
+func (m *mockWarehouseRepository) ListStock(ctx context.Context, filter repository.WarehouseStockFilter) ([]*models.WarehouseStock, error) {
+	m.filter = filter
+	return m.levels, nil
+}
+
+func (m *mockWarehouseRepository) MoveStock(ctx context.Context, warehouseID string, update repository.StockUpdate) error {
+	return nil
+}
+
+func (m *mockWarehouseRepository) Transfer(ctx context.Context, transfer *models.StockTransfer) error {
+	return nil
+}
+
+func (m *mockWarehouseRepository) Allocate(ctx context.Context, reservations []*models.StockReservation, allocations []*models.StockAllocation) error {
+	m.allocations = allocations
+	return nil
+}
+
+func (m *mockWarehouseRepository) ListAllocations(ctx context.Context, reservationIDs []string) ([]*models.StockAllocation, error) {
+	return m.allocations, nil
+}
+
+// testWarehouses are an Ohio, a Nevada and an Illinois warehouse, with the
+// Nevada one preferred
+func testWarehouses() []*models.Warehouse {
+	east := models.NewWarehouse("EAST", "Columbus", models.Address{Street: "1 Fulfillment Way", City: "Columbus", State: "OH", PostalCode: "43215", Country: "US"}, 2)
+	west := models.NewWarehouse("WEST", "Reno", models.Address{Street: "2 Desert Rd", City: "Reno", State: "NV", PostalCode: "89501", Country: "US"}, 1)
+	central := models.NewWarehouse("CENTRAL", "Chicago", models.Address{Street: "3 Lake St", City: "Chicago", State: "IL", PostalCode: "60601", Country: "US"}, 3)
+	return []*models.Warehouse{east, west, central}
+}
+
+func TestPlanAllocation(t *testing.T) {
+	warehouses := testWarehouses()
+	east, west, central := warehouses[0], warehouses[1], warehouses[2]
+	levels := []*models.WarehouseStock{
+		{WarehouseID: east.ID, ProductID: "prod1", Stock: 5, AllocatedStock: 2},
+		{WarehouseID: west.ID, ProductID: "prod1", Stock: 10},
+		{WarehouseID: west.ID, ProductID: "prod2", Stock: 5},
+		{WarehouseID: central.ID, ProductID: "prod1", Stock: 1},
+		{WarehouseID: central.ID, ProductID: "prod2", Stock: 2},
+	}
+	lines := []models.AllocationLine{
+		{ProductID: "prod1", Quantity: 4},
+		{ProductID: "prod2", Quantity: 2},
+	}
+	shipTo := models.Address{City: "Cincinnati", State: "OH", PostalCode: "45202", Country: "US"}
+
+	tests := []struct {
+		name     string
+		strategy models.AllocationStrategy
+		expected map[string]map[string]int // warehouse code to product quantities
+	}{
+		{
+			// Ohio is nearest but only has 3 units of prod1 that are not allocated
+			name:     "nearest",
+			strategy: models.AllocationNearest,
+			expected: map[string]map[string]int{"EAST": {"prod1": 3}, "WEST": {"prod1": 1, "prod2": 2}},
+		},
+		{
+			name:     "priority",
+			strategy: models.AllocationPriority,
+			expected: map[string]map[string]int{"WEST": {"prod1": 4, "prod2": 2}},
+		},
+		{
+			name:     "fewest splits",
+			strategy: models.AllocationFewestSplits,
+			expected: map[string]map[string]int{"WEST": {"prod1": 4, "prod2": 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipments, unallocated := planAllocation(tt.strategy, shipTo, warehouses, levels, lines)
+			if len(unallocated) != 0 {
+				t.Errorf("Expected everything to be allocated, got %+v unallocated", unallocated)
+			}
+
+			actual := make(map[string]map[string]int)
+			for _, shipment := range shipments {
+				actual[shipment.Warehouse.Code] = make(map[string]int)
+				for _, line := range shipment.Lines {
+					actual[shipment.Warehouse.Code][line.ProductID] = line.Quantity
+				}
+			}
+			if len(actual) != len(tt.expected) {
+				t.Fatalf("Expected shipments from %v, got %v", tt.expected, actual)
+			}
+			for code, quantities := range tt.expected {
+				for productID, quantity := range quantities {
+					if actual[code][productID] != quantity {
+						t.Errorf("Expected %d of %s from %s, got %v", quantity, productID, code, actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPlanAllocation_Unallocated(t *testing.T) {
+	warehouses := testWarehouses()
+	levels := []*models.WarehouseStock{
+		{WarehouseID: warehouses[0].ID, ProductID: "prod1", VariantID: "var1", Stock: 2},
+	}
+	lines := []models.AllocationLine{
+		{ProductID: "prod1", VariantID: "var1", Quantity: 3},
+		{ProductID: "prod1", VariantID: "var2", Quantity: 1},
+	}
+
+	shipments, unallocated := planAllocation(models.AllocationNearest, models.Address{}, warehouses, levels, lines)
+	if len(shipments) != 1 || shipments[0].Units() != 2 {
+		t.Errorf("Expected the 2 units in stock to ship from one warehouse, got %+v", shipments)
+	}
+	if len(unallocated) != 2 || unallocated[0].Quantity != 1 || unallocated[1].VariantID != "var2" {
+		t.Errorf("Expected 1 unit of each variant to be unallocated, got %+v", unallocated)
+	}
+}
+
+func TestWarehouseService_AllocateStock(t *testing.T) {
+	reservations := newMockReservationRepository(map[string]int{"prod1": 10})
+	reservation := models.NewStockReservation(models.ReservationReferenceOrder, "order1", "prod1", "", 3, time.Now().Add(time.Hour))
+	if err := reservations.Reserve(context.Background(), reservation); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	warehouses := testWarehouses()
+	repo := &mockWarehouseRepository{
+		warehouses: warehouses,
+		levels: []*models.WarehouseStock{
+			{WarehouseID: warehouses[0].ID, ProductID: "prod1", Stock: 1},
+			{WarehouseID: warehouses[2].ID, ProductID: "prod1", Stock: 10},
+		},
+	}
+	service := NewWarehouseService(repo, reservations, DefaultWarehouseConfig())
+
+	plan, err := service.AllocateStock(context.Background(), AllocateStockRequest{
+		ReferenceType:   models.ReservationReferenceOrder,
+		ReferenceID:     "order1",
+		ShippingAddress: models.Address{State: "OH", PostalCode: "45202", Country: "US"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if plan.Strategy != models.AllocationNearest {
+		t.Errorf("Expected the default strategy, got %s", plan.Strategy)
+	}
+	if origin := plan.Origin(); origin == nil || origin.Code != "CENTRAL" {
+		t.Errorf("Expected the order to ship from CENTRAL, got %+v", origin)
+	}
+	if len(repo.filter.ExcludeReservationIDs) != 1 || repo.filter.ExcludeReservationIDs[0] != reservation.ID {
+		t.Errorf("Expected the order's own allocations to be excluded, got %+v", repo.filter)
+	}
+	if len(repo.allocations) != 2 {
+		t.Fatalf("Expected 2 allocations, got %d", len(repo.allocations))
+	}
+	for _, allocation := range repo.allocations {
+		if allocation.ReservationID != reservation.ID {
+			t.Errorf("Expected allocations of reservation %s, got %+v", reservation.ID, allocation)
+		}
+	}
+}
+
+func TestWarehouseService_AllocateStock_Errors(t *testing.T) {
+	service := NewWarehouseService(&mockWarehouseRepository{}, newMockReservationRepository(map[string]int{}), DefaultWarehouseConfig())
+
+	tests := []struct {
+		name string
+		req  AllocateStockRequest
+		code utils.ErrorCode
+	}{
+		{"unknown strategy", AllocateStockRequest{ReferenceType: "order", ReferenceID: "order1", Strategy: "cheapest"}, utils.ErrValidation},
+		{"unknown reference type", AllocateStockRequest{ReferenceType: "invoice", ReferenceID: "inv1"}, utils.ErrValidation},
+		{"nothing reserved", AllocateStockRequest{ReferenceType: "order", ReferenceID: "order1"}, utils.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.AllocateStock(context.Background(), tt.req)
+			if appErr, ok := err.(*utils.AppError); !ok || appErr.Code != tt.code {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopsphere/product-service/internal/clients"
 	"github.com/shopsphere/product-service/internal/handlers"
 	"github.com/shopsphere/product-service/internal/repository"
 	"github.com/shopsphere/product-service/internal/search"
 	"github.com/shopsphere/product-service/internal/service"
+	"github.com/shopsphere/shared/events"
+	"github.com/shopsphere/shared/middleware"
+	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 )
 
@@ -31,9 +38,21 @@ func main() {
 	}
 	defer db.Close()
 
+	// "product-service audit-stock" reports stock that drifted from the inventory ledger and exits
+	if len(os.Args) > 1 && os.Args[1] == "audit-stock" {
+		code := auditStock(ctx, service.NewInventoryService(repository.NewInventoryRepository(db)))
+		db.Close()
+		os.Exit(code)
+	}
+
 	// Initialize repositories
 	productRepo := repository.NewProductRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
+	variantRepo := repository.NewVariantRepository(db)
+	reservationRepo := repository.NewReservationRepository(db)
+	inventoryRepo := repository.NewInventoryRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	stockAlertRepo := repository.NewStockAlertRepository(db)
 
 	// Initialize Elasticsearch client
 	var searchService search.SearchService
@@ -63,15 +82,67 @@ func main() {
 	analyticsService = search.NewAnalyticsService(db)
 
 	// Initialize services
-	productService := service.NewProductService(productRepo, categoryRepo, searchService, analyticsService)
+	productService := service.NewProductService(productRepo, categoryRepo, variantRepo, searchService, analyticsService)
 	categoryService := service.NewCategoryService(categoryRepo)
+	variantService := service.NewVariantService(variantRepo, productRepo, searchService)
+	reservationService := service.NewReservationService(reservationRepo, service.DefaultReservationConfig())
+	inventoryService := service.NewInventoryService(inventoryRepo)
+
+	warehouseConfig := service.DefaultWarehouseConfig()
+	if strategy := models.AllocationStrategy(os.Getenv("ALLOCATION_STRATEGY")); strategy.IsValid() {
+		warehouseConfig.DefaultStrategy = strategy
+	} else if strategy != "" {
+		utils.Logger.Warn(ctx, "Unknown allocation strategy, using the default", map[string]interface{}{
+			"strategy": strategy,
+			"default":  warehouseConfig.DefaultStrategy,
+		})
+	}
+	warehouseService := service.NewWarehouseService(warehouseRepo, reservationRepo, warehouseConfig)
+
+	// Low-stock alerts go to the admin dashboard and back-in-stock notices through notification-service
+	adminServiceURL := os.Getenv("ADMIN_SERVICE_URL")
+	if adminServiceURL == "" {
+		adminServiceURL = "http://localhost:8010"
+	}
+	notificationServiceURL := os.Getenv("NOTIFICATION_SERVICE_URL")
+	if notificationServiceURL == "" {
+		notificationServiceURL = "http://localhost:8009"
+	}
+	stockAlertService := service.NewStockAlertService(stockAlertRepo, productRepo, variantRepo,
+		clients.NewAdminClient(adminServiceURL), clients.NewNotificationClient(notificationServiceURL))
+
+	// Relay outbox events to the broker and react to the inventory updates
+	// product-service publishes itself; events stay queued while Redis is
+	// unavailable and both start once it connects
+	events.ConnectRedisBroker(ctx, utils.NewRedisConfig(), func(broker *events.RedisBroker) {
+		relay := events.NewRelay(events.NewPostgresOutbox(db), broker, events.DefaultRelayConfig())
+		go relay.Run(ctx)
+
+		consumer := events.NewConsumer("product-service",
+			events.NewPostgresProcessedEventStore(db),
+			events.NewPostgresDeadLetterStore(db),
+			events.DefaultRetryPolicy())
+		handlers.NewInventoryEventHandler(stockAlertService).RegisterHandlers(consumer)
+		consumer.SubscribeTo(broker)
+		if err := broker.Listen(ctx, consumer.Name()); err != nil {
+			utils.Logger.Error(ctx, "Event listener stopped", err)
+		}
+	})
+
+	// Release the stock held by reservations that have expired
+	go releaseExpiredReservations(ctx, reservationService)
 
 	// Initialize handlers
-	productHandler := handlers.NewProductHandler(productService, categoryService)
+	productHandler := handlers.NewProductHandler(productService, categoryService, reservationService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
+	variantHandler := handlers.NewVariantHandler(variantService)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+	warehouseHandler := handlers.NewWarehouseHandler(warehouseService)
+	stockAlertHandler := handlers.NewStockAlertHandler(stockAlertService)
 
-	// Create router
+	// Create router; movements are attributed to the user and service in the request headers
 	router := mux.NewRouter()
+	router.Use(middleware.Caller)
 
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -97,7 +168,19 @@ func main() {
 	productRoutes.HandleFunc("/{id}", productHandler.DeleteProduct).Methods("DELETE")
 	productRoutes.HandleFunc("/{id}/reserve-stock", productHandler.ReserveStock).Methods("POST")
 	productRoutes.HandleFunc("/{id}/release-stock", productHandler.ReleaseStock).Methods("POST")
+	productRoutes.HandleFunc("/{id}/commit-stock", productHandler.CommitStock).Methods("POST")
 	productRoutes.HandleFunc("/{id}/stock", productHandler.UpdateStock).Methods("PUT")
+	productRoutes.HandleFunc("/{id}/warehouse-stock", warehouseHandler.ListProductStock).Methods("GET")
+	productRoutes.HandleFunc("/{id}/stock-thresholds", stockAlertHandler.ListThresholds).Methods("GET")
+	productRoutes.HandleFunc("/{id}/stock-thresholds", stockAlertHandler.SetThreshold).Methods("PUT")
+	productRoutes.HandleFunc("/{id}/stock-thresholds", stockAlertHandler.DeleteThreshold).Methods("DELETE")
+	productRoutes.HandleFunc("/{id}/back-in-stock", stockAlertHandler.Subscribe).Methods("POST")
+	productRoutes.HandleFunc("/{id}/variants", variantHandler.ListVariants).Methods("GET")
+	productRoutes.HandleFunc("/{id}/variants", variantHandler.CreateVariant).Methods("POST")
+	productRoutes.HandleFunc("/{id}/variants/generate", variantHandler.GenerateVariants).Methods("POST")
+	productRoutes.HandleFunc("/{id}/variants/{variantId}", variantHandler.GetVariant).Methods("GET")
+	productRoutes.HandleFunc("/{id}/variants/{variantId}", variantHandler.UpdateVariant).Methods("PUT")
+	productRoutes.HandleFunc("/{id}/variants/{variantId}", variantHandler.DeleteVariant).Methods("DELETE")
 
 	// Category routes
 	categoryRoutes := router.PathPrefix("/categories").Subrouter()
@@ -111,6 +194,29 @@ func main() {
 	categoryRoutes.HandleFunc("/{id}/children", categoryHandler.GetCategoryChildren).Methods("GET")
 	categoryRoutes.HandleFunc("/{id}/path", categoryHandler.GetCategoryPath).Methods("GET")
 
+	// Reservation routes
+	router.HandleFunc("/reservations/{referenceType}/{referenceId}", productHandler.ListReservations).Methods("GET")
+
+	// Back-in-stock subscription routes for the calling user
+	router.HandleFunc("/back-in-stock-subscriptions", stockAlertHandler.ListSubscriptions).Methods("GET")
+	router.HandleFunc("/back-in-stock-subscriptions/{id}", stockAlertHandler.Unsubscribe).Methods("DELETE")
+
+	// Inventory ledger routes
+	router.HandleFunc("/inventory/movements", inventoryHandler.ListMovements).Methods("GET")
+	router.HandleFunc("/inventory/audit", inventoryHandler.AuditStock).Methods("GET")
+	router.HandleFunc("/inventory/transfers", warehouseHandler.TransferStock).Methods("POST")
+	router.HandleFunc("/inventory/allocations", warehouseHandler.AllocateStock).Methods("POST")
+	router.HandleFunc("/inventory/allocations/{referenceType}/{referenceId}", warehouseHandler.ListAllocations).Methods("GET")
+
+	// Warehouse routes
+	warehouseRoutes := router.PathPrefix("/warehouses").Subrouter()
+	warehouseRoutes.HandleFunc("", warehouseHandler.ListWarehouses).Methods("GET")
+	warehouseRoutes.HandleFunc("", warehouseHandler.CreateWarehouse).Methods("POST")
+	warehouseRoutes.HandleFunc("/{id}", warehouseHandler.GetWarehouse).Methods("GET")
+	warehouseRoutes.HandleFunc("/{id}", warehouseHandler.UpdateWarehouse).Methods("PUT")
+	warehouseRoutes.HandleFunc("/{id}/stock", warehouseHandler.ListWarehouseStock).Methods("GET")
+	warehouseRoutes.HandleFunc("/{id}/stock", warehouseHandler.MoveWarehouseStock).Methods("POST")
+
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -119,4 +225,48 @@ func main() {
 
 	utils.Logger.Info(ctx, "Product Service listening on port", map[string]interface{}{"port": port})
 	log.Fatal(http.ListenAndServe(":"+port, router))
+}
+
+// releaseExpiredReservations periodically releases the stock of expired reservations
+func releaseExpiredReservations(ctx context.Context, reservationService *service.ReservationService) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if released, err := reservationService.ReleaseExpired(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to release expired stock reservations", err)
+		} else if released > 0 {
+			utils.Logger.Info(ctx, "Released expired stock reservations", map[string]interface{}{"count": released})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// auditStock prints the products and variants whose stock drifted from the
+// inventory ledger. It returns the exit code: 1 when any drifted.
+func auditStock(ctx context.Context, inventoryService *service.InventoryService) int {
+	report, err := inventoryService.AuditStock(ctx)
+	if err != nil {
+		utils.Logger.Error(ctx, "Failed to audit stock", err)
+		return 2
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SKU\tPRODUCT\tVARIANT\tSTOCK\tLEDGER STOCK\tRESERVED\tLEDGER RESERVED")
+	for _, drift := range report.Drifts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", drift.SKU, drift.ProductID, drift.VariantID,
+			drift.Stock, drift.LedgerStock, drift.ReservedStock, drift.LedgerReservedStock)
+	}
+	w.Flush()
+	fmt.Printf("%d of %d products and variants drifted from the inventory ledger\n", len(report.Drifts), report.Checked)
+
+	if len(report.Drifts) > 0 {
+		return 1
+	}
+	return 0
 }
\ No newline at end of file
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/models"
 )
 
@@ -42,12 +44,16 @@ type ShippingRepository interface {
 
 // PostgresShippingRepository implements ShippingRepository using PostgreSQL
 type PostgresShippingRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *events.PostgresOutbox
 }
 
 // NewPostgresShippingRepository creates a new PostgreSQL shipping repository
 func NewPostgresShippingRepository(db *sql.DB) *PostgresShippingRepository {
-	return &PostgresShippingRepository{db: db}
+	return &PostgresShippingRepository{
+		db:     db,
+		outbox: events.NewPostgresOutbox(db),
+	}
 }
 
 // CreateShippingMethod creates a new shipping method
@@ -416,11 +422,76 @@ func (r *PostgresShippingRepository) UpdateShipment(ctx context.Context, shipmen
 	return err
 }
 
-// UpdateShipmentStatus updates a shipment's status
+// UpdateShipmentStatus updates a shipment's status. A shipment leaving the
+// warehouse records a shipment.shipped event in the same transaction; the
+// order's shipments are locked so that exactly one event is marked final.
 func (r *PostgresShippingRepository) UpdateShipmentStatus(ctx context.Context, id string, status models.ShipmentStatus) error {
-	query := `UPDATE shipments SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id, status)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, order_id, user_id, tracking_number, status, declared_value
+		FROM shipments
+		WHERE order_id = (SELECT order_id FROM shipments WHERE id = $1)
+		ORDER BY id
+		FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	var shipment *models.Shipment
+	final := true
+	for rows.Next() {
+		s := &models.Shipment{}
+		if err := rows.Scan(&s.ID, &s.OrderID, &s.UserID, &s.TrackingNumber, &s.Status, &s.DeclaredValue); err != nil {
+			rows.Close()
+			return err
+		}
+		if s.ID == id {
+			shipment = s
+		} else if s.Status != models.ShipmentCancelled && !s.Status.HasShipped() {
+			final = false
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if shipment == nil {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE shipments SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id, status)
+	if err != nil {
+		return err
+	}
+
+	if status.HasShipped() && !shipment.Status.HasShipped() {
+		data := models.ShipmentShippedData{
+			ShipmentID:     shipment.ID,
+			OrderID:        shipment.OrderID,
+			UserID:         shipment.UserID,
+			TrackingNumber: shipment.TrackingNumber,
+			DeclaredValue:  shipment.DeclaredValue,
+			Final:          final,
+			ShippedAt:      time.Now(),
+		}
+
+		event, err := models.NewDomainEvent(models.EventShipmentShipped, shipment.ID, data, events.MetadataFromContext(ctx, "shipping-service"))
+		if err != nil {
+			return err
+		}
+		if err := r.outbox.Save(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // CreateTrackingEvent creates a new tracking event
@@ -5,9 +5,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopsphere/shared/events"
 	"github.com/shopsphere/shared/models"
 	"github.com/shopsphere/shared/utils"
 	"github.com/shopsphere/shipping-service/internal/gateway"
@@ -17,9 +21,13 @@ import (
 )
 
 func main() {
+	// The service context is cancelled on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize logger
 	logger := utils.NewStructuredLogger(os.Stdout, utils.LogLevelInfo, "shipping-service")
-	logger.Info(context.Background(), "Starting Shipping Service", nil)
+	logger.Info(ctx, "Starting Shipping Service", nil)
 
 	// Initialize database connection
 	dbConfig := &utils.DatabaseConfig{
@@ -33,11 +41,19 @@ func main() {
 
 	db, err := dbConfig.Connect()
 	if err != nil {
-		logger.Error(context.Background(), "Failed to connect to database", err)
+		logger.Error(ctx, "Failed to connect to database", err)
 		log.Fatal(err)
 	}
 	defer db.Close()
 
+	// Relay outbox events to the broker; events stay queued in the outbox while
+	// Redis is unavailable and the relay starts once it connects. The relay
+	// stops with the service context and is waited for before the database
+	// is closed.
+	relayDone := events.ConnectRedisBroker(ctx, utils.NewRedisConfig(), func(broker *events.RedisBroker) {
+		events.NewRelay(events.NewPostgresOutbox(db), broker, events.DefaultRelayConfig()).Run(ctx)
+	})
+
 	// Initialize repository
 	repo := repository.NewPostgresShippingRepository(db)
 
@@ -77,11 +93,28 @@ func main() {
 
 	// Start server
 	port := getEnv("PORT", "8007")
-	logger.Info(context.Background(), "Shipping Service listening", map[string]interface{}{
+	logger.Info(ctx, "Shipping Service listening", map[string]interface{}{
 		"port": port,
 	})
 
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(shutdownCtx, "Failed to shut down server", err)
+		}
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	// Let the relay finish its batch before the deferred db.Close
+	stop()
+	<-relayDone
+	logger.Info(context.Background(), "Shipping Service stopped")
 }
 
 // getEnv gets environment variable with fallback
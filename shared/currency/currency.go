@@ -0,0 +1,115 @@
+// Package currency provides ISO 4217 currency metadata and the rounding
+// rules that go with it, plus exchange rates for converting between
+// currencies.
+package currency
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCode is the store's own currency. Shipping fees and promotions are
+// defined in it, and carts and orders use it unless another is chosen.
+const DefaultCode = "USD"
+
+// Currency describes an ISO 4217 currency
+type Currency struct {
+	Code    string `json:"code"`
+	Numeric string `json:"numeric"`
+	Name    string `json:"name"`
+	Symbol  string `json:"symbol"`
+	// MinorUnits is the number of digits after the decimal point, e.g. 2 for
+	// USD (cents), 0 for JPY and 3 for KWD (fils)
+	MinorUnits int32 `json:"minor_units"`
+}
+
+// currencies lists the currencies the platform can price and charge in
+var currencies = map[string]Currency{
+	"AED": {Code: "AED", Numeric: "784", Name: "UAE Dirham", Symbol: "د.إ", MinorUnits: 2},
+	"AUD": {Code: "AUD", Numeric: "036", Name: "Australian Dollar", Symbol: "A$", MinorUnits: 2},
+	"BHD": {Code: "BHD", Numeric: "048", Name: "Bahraini Dinar", Symbol: "BD", MinorUnits: 3},
+	"BRL": {Code: "BRL", Numeric: "986", Name: "Brazilian Real", Symbol: "R$", MinorUnits: 2},
+	"CAD": {Code: "CAD", Numeric: "124", Name: "Canadian Dollar", Symbol: "CA$", MinorUnits: 2},
+	"CHF": {Code: "CHF", Numeric: "756", Name: "Swiss Franc", Symbol: "CHF", MinorUnits: 2},
+	"CLP": {Code: "CLP", Numeric: "152", Name: "Chilean Peso", Symbol: "CLP$", MinorUnits: 0},
+	"CNY": {Code: "CNY", Numeric: "156", Name: "Yuan Renminbi", Symbol: "¥", MinorUnits: 2},
+	"CZK": {Code: "CZK", Numeric: "203", Name: "Czech Koruna", Symbol: "Kč", MinorUnits: 2},
+	"DKK": {Code: "DKK", Numeric: "208", Name: "Danish Krone", Symbol: "kr", MinorUnits: 2},
+	"EUR": {Code: "EUR", Numeric: "978", Name: "Euro", Symbol: "€", MinorUnits: 2},
+	"GBP": {Code: "GBP", Numeric: "826", Name: "Pound Sterling", Symbol: "£", MinorUnits: 2},
+	"HKD": {Code: "HKD", Numeric: "344", Name: "Hong Kong Dollar", Symbol: "HK$", MinorUnits: 2},
+	"HUF": {Code: "HUF", Numeric: "348", Name: "Forint", Symbol: "Ft", MinorUnits: 2},
+	"IDR": {Code: "IDR", Numeric: "360", Name: "Rupiah", Symbol: "Rp", MinorUnits: 2},
+	"ILS": {Code: "ILS", Numeric: "376", Name: "New Israeli Sheqel", Symbol: "₪", MinorUnits: 2},
+	"INR": {Code: "INR", Numeric: "356", Name: "Indian Rupee", Symbol: "₹", MinorUnits: 2},
+	"ISK": {Code: "ISK", Numeric: "352", Name: "Iceland Krona", Symbol: "kr", MinorUnits: 0},
+	"JOD": {Code: "JOD", Numeric: "400", Name: "Jordanian Dinar", Symbol: "JD", MinorUnits: 3},
+	"JPY": {Code: "JPY", Numeric: "392", Name: "Yen", Symbol: "¥", MinorUnits: 0},
+	"KRW": {Code: "KRW", Numeric: "410", Name: "Won", Symbol: "₩", MinorUnits: 0},
+	"KWD": {Code: "KWD", Numeric: "414", Name: "Kuwaiti Dinar", Symbol: "KD", MinorUnits: 3},
+	"MXN": {Code: "MXN", Numeric: "484", Name: "Mexican Peso", Symbol: "MX$", MinorUnits: 2},
+	"MYR": {Code: "MYR", Numeric: "458", Name: "Malaysian Ringgit", Symbol: "RM", MinorUnits: 2},
+	"NOK": {Code: "NOK", Numeric: "578", Name: "Norwegian Krone", Symbol: "kr", MinorUnits: 2},
+	"NZD": {Code: "NZD", Numeric: "554", Name: "New Zealand Dollar", Symbol: "NZ$", MinorUnits: 2},
+	"OMR": {Code: "OMR", Numeric: "512", Name: "Rial Omani", Symbol: "RO", MinorUnits: 3},
+	"PHP": {Code: "PHP", Numeric: "608", Name: "Philippine Peso", Symbol: "₱", MinorUnits: 2},
+	"PLN": {Code: "PLN", Numeric: "985", Name: "Zloty", Symbol: "zł", MinorUnits: 2},
+	"SAR": {Code: "SAR", Numeric: "682", Name: "Saudi Riyal", Symbol: "SR", MinorUnits: 2},
+	"SEK": {Code: "SEK", Numeric: "752", Name: "Swedish Krona", Symbol: "kr", MinorUnits: 2},
+	"SGD": {Code: "SGD", Numeric: "702", Name: "Singapore Dollar", Symbol: "S$", MinorUnits: 2},
+	"THB": {Code: "THB", Numeric: "764", Name: "Baht", Symbol: "฿", MinorUnits: 2},
+	"TND": {Code: "TND", Numeric: "788", Name: "Tunisian Dinar", Symbol: "DT", MinorUnits: 3},
+	"TRY": {Code: "TRY", Numeric: "949", Name: "Turkish Lira", Symbol: "₺", MinorUnits: 2},
+	"TWD": {Code: "TWD", Numeric: "901", Name: "New Taiwan Dollar", Symbol: "NT$", MinorUnits: 2},
+	"UGX": {Code: "UGX", Numeric: "800", Name: "Uganda Shilling", Symbol: "USh", MinorUnits: 0},
+	"USD": {Code: "USD", Numeric: "840", Name: "US Dollar", Symbol: "$", MinorUnits: 2},
+	"VND": {Code: "VND", Numeric: "704", Name: "Dong", Symbol: "₫", MinorUnits: 0},
+	"ZAR": {Code: "ZAR", Numeric: "710", Name: "Rand", Symbol: "R", MinorUnits: 2},
+}
+
+// Lookup returns the currency for an ISO 4217 code, ignoring case and
+// surrounding whitespace
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, fmt.Errorf("unsupported currency: %q", code)
+	}
+	return c, nil
+}
+
+// IsSupported reports whether the code names a known currency
+func IsSupported(code string) bool {
+	_, err := Lookup(code)
+	return err == nil
+}
+
+// Round rounds an amount to the currency's minor unit, halves away from zero
+func (c Currency) Round(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(c.MinorUnits)
+}
+
+// IsRounded reports whether the amount needs no more precision than the
+// currency's minor unit, e.g. 10.5 JPY is not
+func (c Currency) IsRounded(amount decimal.Decimal) bool {
+	return amount.Equal(c.Round(amount))
+}
+
+// ToMinorUnits converts an amount to an integer count of minor units, the
+// form payment gateways expect: 12.34 USD is 1234, 1234 JPY is 1234 and
+// 1.234 KWD is 1234. The amount is rounded first.
+func (c Currency) ToMinorUnits(amount decimal.Decimal) int64 {
+	return c.Round(amount).Shift(c.MinorUnits).IntPart()
+}
+
+// FromMinorUnits converts an integer count of minor units back to an amount
+func (c Currency) FromMinorUnits(units int64) decimal.Decimal {
+	return decimal.New(units, -c.MinorUnits)
+}
+
+// Format renders an amount with the currency's number of decimals and its
+// code, e.g. "12.50 USD" or "1250 JPY"
+func (c Currency) Format(amount decimal.Decimal) string {
+	return c.Round(amount).StringFixed(c.MinorUnits) + " " + c.Code
+}
@@ -0,0 +1,149 @@
+package currency
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLookup(t *testing.T) {
+	c, err := Lookup(" jpy ")
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if c.Code != "JPY" || c.MinorUnits != 0 {
+		t.Errorf("Expected JPY with 0 minor units, got %s with %d", c.Code, c.MinorUnits)
+	}
+
+	if _, err := Lookup("XYZ"); err == nil {
+		t.Error("Expected error for unknown currency")
+	}
+	if IsSupported("") {
+		t.Error("Expected empty code to be unsupported")
+	}
+}
+
+func TestCurrency_MinorUnits(t *testing.T) {
+	tests := []struct {
+		code   string
+		amount string
+		units  int64
+		back   string
+	}{
+		{"USD", "12.34", 1234, "12.34"},
+		{"USD", "12.345", 1235, "12.35"},
+		{"EUR", "0.5", 50, "0.5"},
+		{"JPY", "1234", 1234, "1234"},
+		{"JPY", "1234.5", 1235, "1235"},
+		{"KWD", "1.234", 1234, "1.234"},
+		{"KWD", "1.2345", 1235, "1.235"},
+		{"BHD", "10", 10000, "10"},
+	}
+
+	for _, tt := range tests {
+		c, err := Lookup(tt.code)
+		if err != nil {
+			t.Fatalf("Lookup(%s) returned error: %v", tt.code, err)
+		}
+		amount := decimal.RequireFromString(tt.amount)
+		if units := c.ToMinorUnits(amount); units != tt.units {
+			t.Errorf("%s %s: expected %d minor units, got %d", tt.amount, tt.code, tt.units, units)
+		}
+		if back := c.FromMinorUnits(tt.units); !back.Equal(decimal.RequireFromString(tt.back)) {
+			t.Errorf("%d %s minor units: expected %s, got %s", tt.units, tt.code, tt.back, back)
+		}
+	}
+}
+
+func TestCurrency_IsRounded(t *testing.T) {
+	jpy, _ := Lookup("JPY")
+	usd, _ := Lookup("USD")
+	kwd, _ := Lookup("KWD")
+
+	if jpy.IsRounded(decimal.RequireFromString("10.5")) {
+		t.Error("Expected 10.5 JPY to need rounding")
+	}
+	if !usd.IsRounded(decimal.RequireFromString("10.50")) {
+		t.Error("Expected 10.50 USD to be rounded")
+	}
+	if !kwd.IsRounded(decimal.RequireFromString("10.125")) {
+		t.Error("Expected 10.125 KWD to be rounded")
+	}
+	if got := kwd.Format(decimal.NewFromInt(3)); got != "3.000 KWD" {
+		t.Errorf("Expected 3.000 KWD, got %s", got)
+	}
+}
+
+func TestRates_Convert(t *testing.T) {
+	asOf := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	rates, err := NewRates("USD", asOf, map[string]decimal.Decimal{
+		"EUR": decimal.RequireFromString("0.92"),
+		"JPY": decimal.RequireFromString("150"),
+		"KWD": decimal.RequireFromString("0.307"),
+	})
+	if err != nil {
+		t.Fatalf("NewRates returned error: %v", err)
+	}
+
+	tests := []struct {
+		from, to string
+		amount   string
+		expected string
+	}{
+		{"USD", "EUR", "10.00", "9.2"},
+		{"USD", "JPY", "19.99", "2999"},
+		{"EUR", "USD", "9.20", "10"},
+		{"EUR", "JPY", "10.00", "1630"},
+		{"USD", "KWD", "10.00", "3.07"},
+		{"JPY", "JPY", "500", "500"},
+	}
+
+	for _, tt := range tests {
+		converted, conversion, err := rates.Convert(decimal.RequireFromString(tt.amount), tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("Convert %s->%s returned error: %v", tt.from, tt.to, err)
+		}
+		if !converted.Equal(decimal.RequireFromString(tt.expected)) {
+			t.Errorf("%s %s in %s: expected %s, got %s", tt.amount, tt.from, tt.to, tt.expected, converted)
+		}
+		if conversion.From != tt.from || conversion.To != tt.to || !conversion.AsOf.Equal(asOf) {
+			t.Errorf("Unexpected conversion %+v", conversion)
+		}
+	}
+
+	if _, _, err := rates.Convert(decimal.NewFromInt(1), "USD", "GBP"); err == nil {
+		t.Error("Expected error converting to a currency without a rate")
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	rates, err := LoadRates(strings.NewReader(`{"base": "eur", "as_of": "2024-03-01T00:00:00Z", "rates": {"USD": "1.087", "GBP": 0.857}}`))
+	if err != nil {
+		t.Fatalf("LoadRates returned error: %v", err)
+	}
+	if rates.Base != "EUR" {
+		t.Errorf("Expected base EUR, got %s", rates.Base)
+	}
+
+	conversion, err := rates.Rate("USD", "GBP")
+	if err != nil {
+		t.Fatalf("Rate returned error: %v", err)
+	}
+	if !conversion.Rate.Equal(decimal.RequireFromString("0.7884084637")) {
+		t.Errorf("Unexpected cross rate %s", conversion.Rate)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"base": "XXX", "rates": {}}`,
+		`{"base": "USD", "rates": {"EUR": "0"}}`,
+		`{"base": "USD", "rates": {"ABC": "1.5"}}`,
+	}
+	for _, body := range invalid {
+		if _, err := LoadRates(strings.NewReader(body)); err == nil {
+			t.Errorf("Expected error loading %s", body)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ratePrecision is the number of decimals kept on derived cross rates
+const ratePrecision = 10
+
+// Conversion is the exchange rate used to convert one currency into another.
+// It is snapshotted on orders so totals can be explained after rates change.
+type Conversion struct {
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Rate decimal.Decimal `json:"rate"`
+	AsOf time.Time       `json:"as_of"`
+}
+
+// Apply converts an amount and rounds it to the target currency
+func (c Conversion) Apply(amount decimal.Decimal) decimal.Decimal {
+	converted := amount.Mul(c.Rate)
+	if to, err := Lookup(c.To); err == nil {
+		return to.Round(converted)
+	}
+	return converted
+}
+
+// Rates is a table of exchange rates quoted against a base currency
+type Rates struct {
+	Base  string
+	AsOf  time.Time
+	rates map[string]decimal.Decimal // units of each currency per unit of Base
+}
+
+// ratesFile is the JSON layout of an exchange rate file:
+//
+//	{"base": "USD", "as_of": "2024-03-01T00:00:00Z", "rates": {"EUR": "0.92", "JPY": "150.1"}}
+type ratesFile struct {
+	Base  string                     `json:"base"`
+	AsOf  time.Time                  `json:"as_of"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+// NewRates creates a rate table. Every rate is the number of units of that
+// currency one unit of base buys.
+func NewRates(base string, asOf time.Time, rates map[string]decimal.Decimal) (*Rates, error) {
+	baseCurrency, err := Lookup(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base currency: %w", err)
+	}
+
+	table := &Rates{
+		Base:  baseCurrency.Code,
+		AsOf:  asOf,
+		rates: map[string]decimal.Decimal{baseCurrency.Code: decimal.NewFromInt(1)},
+	}
+	for code, rate := range rates {
+		c, err := Lookup(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate: %w", err)
+		}
+		if !rate.IsPositive() {
+			return nil, fmt.Errorf("invalid rate for %s: must be positive", c.Code)
+		}
+		if c.Code == baseCurrency.Code && !rate.Equal(decimal.NewFromInt(1)) {
+			return nil, fmt.Errorf("invalid rate for base currency %s: must be 1", c.Code)
+		}
+		table.rates[c.Code] = rate
+	}
+	return table, nil
+}
+
+// LoadRates reads a rate table in the JSON file format
+func LoadRates(r io.Reader) (*Rates, error) {
+	var file ratesFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates: %w", err)
+	}
+	if file.AsOf.IsZero() {
+		file.AsOf = time.Now()
+	}
+	return NewRates(file.Base, file.AsOf, file.Rates)
+}
+
+// LoadRatesFile reads a rate table from a JSON file
+func LoadRatesFile(path string) (*Rates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open exchange rates: %w", err)
+	}
+	defer f.Close()
+
+	rates, err := LoadRates(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rates, nil
+}
+
+// Rate returns the conversion from one currency to another, crossing
+// through the base currency when neither side is the base
+func (r *Rates) Rate(from, to string) (Conversion, error) {
+	fromCode := strings.ToUpper(strings.TrimSpace(from))
+	toCode := strings.ToUpper(strings.TrimSpace(to))
+
+	if fromCode == toCode {
+		return Conversion{From: fromCode, To: toCode, Rate: decimal.NewFromInt(1), AsOf: r.AsOf}, nil
+	}
+
+	fromRate, ok := r.rates[fromCode]
+	if !ok {
+		return Conversion{}, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := r.rates[toCode]
+	if !ok {
+		return Conversion{}, fmt.Errorf("no exchange rate for %s", to)
+	}
+
+	return Conversion{
+		From: fromCode,
+		To:   toCode,
+		Rate: toRate.DivRound(fromRate, ratePrecision),
+		AsOf: r.AsOf,
+	}, nil
+}
+
+// Convert converts an amount between currencies, rounded to the target
+// currency, and returns the conversion it used
+func (r *Rates) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, Conversion, error) {
+	conversion, err := r.Rate(from, to)
+	if err != nil {
+		return decimal.Zero, Conversion{}, err
+	}
+	return conversion.Apply(amount), conversion, nil
+}
@@ -0,0 +1,283 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// RetryPolicy controls how often and how quickly a failed handler is retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy returns the default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay before the given retry (1-based)
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if delay > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the consumer dead-letters the event without retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// ProcessedEventStore remembers which events a consumer has already handled.
+// A consumer claims an event before handling it so that two deliveries of the
+// same event are never handled twice; a claim that is neither marked processed
+// nor released lapses at its deadline, so an event whose handler crashed is
+// handled again on redelivery.
+type ProcessedEventStore interface {
+	Claim(ctx context.Context, consumer string, event *models.DomainEvent, until time.Time) (bool, error)
+	MarkProcessed(ctx context.Context, consumer string, event *models.DomainEvent) error
+	Release(ctx context.Context, consumer, eventID string) error
+}
+
+// claimLease bounds how long a consumer may take to handle an event,
+// retries included, before another delivery may claim it
+const claimLease = 5 * time.Minute
+
+// DeadLetter is an event a consumer gave up on
+type DeadLetter struct {
+	ID          string              `json:"id"`
+	Consumer    string              `json:"consumer"`
+	Event       *models.DomainEvent `json:"event"`
+	Error       string              `json:"error"`
+	Attempts    int                 `json:"attempts"`
+	ReplayCount int                 `json:"replay_count"`
+	ReplayedAt  *time.Time          `json:"replayed_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// DeadLetterFilter narrows a dead-letter listing
+type DeadLetterFilter struct {
+	Consumer        string
+	EventType       models.EventType
+	IncludeReplayed bool
+	Limit           int
+	Offset          int
+}
+
+// DeadLetterStore parks poison events for inspection and replay
+type DeadLetterStore interface {
+	Save(ctx context.Context, letter *DeadLetter) error
+	Get(ctx context.Context, id string) (*DeadLetter, error)
+	List(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int, error)
+	MarkReplayed(ctx context.Context, id string) error
+}
+
+// Consumer dispatches domain events to registered handlers. Every event is
+// handled once per consumer name, failed handlers are retried with
+// exponential backoff and events that still fail are dead-lettered.
+type Consumer struct {
+	name        string
+	processed   ProcessedEventStore
+	deadLetters DeadLetterStore
+	retry       RetryPolicy
+
+	mutex    sync.RWMutex
+	handlers map[models.EventType]Handler
+
+	// sleep is swapped out in tests to avoid real backoff delays
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewConsumer creates a new consumer. The name scopes deduplication, so two
+// services can each process the same event exactly once.
+func NewConsumer(name string, processed ProcessedEventStore, deadLetters DeadLetterStore, retry RetryPolicy) *Consumer {
+	if retry.MaxAttempts <= 0 {
+		retry = DefaultRetryPolicy()
+	}
+
+	return &Consumer{
+		name:        name,
+		processed:   processed,
+		deadLetters: deadLetters,
+		retry:       retry,
+		handlers:    make(map[models.EventType]Handler),
+		sleep:       sleepContext,
+	}
+}
+
+// Name returns the consumer name
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+// Register adds an untyped handler for an event type, replacing any existing one
+func (c *Consumer) Register(eventType models.EventType, handler Handler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.handlers[eventType] = handler
+}
+
+// Handle registers a typed handler. The event payload is decoded into T with
+// UnmarshalData before fn is called; payloads that fail to decode are dead-lettered.
+func Handle[T any](c *Consumer, eventType models.EventType, fn func(ctx context.Context, event *models.DomainEvent, data T) error) {
+	c.Register(eventType, func(ctx context.Context, event *models.DomainEvent) error {
+		var data T
+		if err := event.UnmarshalData(&data); err != nil {
+			return Permanent(fmt.Errorf("failed to decode %s payload: %w", eventType, err))
+		}
+		return fn(ctx, event, data)
+	})
+}
+
+// EventTypes returns the event types this consumer handles
+func (c *Consumer) EventTypes() []models.EventType {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	types := make([]models.EventType, 0, len(c.handlers))
+	for eventType := range c.handlers {
+		types = append(types, eventType)
+	}
+	return types
+}
+
+// SubscribeTo registers the consumer with a broker for all of its event types
+func (c *Consumer) SubscribeTo(broker Broker) {
+	for _, eventType := range c.EventTypes() {
+		broker.Subscribe(eventType, c.Dispatch)
+	}
+}
+
+// Dispatch delivers one event to its handler. Handler failures end up in the
+// dead-letter store; only infrastructure failures are returned to the caller.
+func (c *Consumer) Dispatch(ctx context.Context, event *models.DomainEvent) error {
+	c.mutex.RLock()
+	handler, ok := c.handlers[event.EventType]
+	c.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	claimed, err := c.processed.Claim(ctx, c.name, event, time.Now().Add(claimLease))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	attempts, err := c.runWithRetry(ctx, handler, event)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.release(ctx, event)
+			return ctx.Err()
+		}
+		if err := c.deadLetter(ctx, event, attempts, err); err != nil {
+			c.release(ctx, event)
+			return err
+		}
+		// Replaying the dead letter handles the event again
+		return c.processed.Release(ctx, c.name, event.ID)
+	}
+
+	return c.processed.MarkProcessed(ctx, c.name, event)
+}
+
+// release gives up the claim on an event that was not handled so that its
+// redelivery is handled straight away
+func (c *Consumer) release(ctx context.Context, event *models.DomainEvent) {
+	if err := c.processed.Release(context.WithoutCancel(ctx), c.name, event.ID); err != nil {
+		utils.Logger.Error(ctx, "Failed to release event claim", err, map[string]interface{}{
+			"consumer": c.name,
+			"event_id": event.ID,
+		})
+	}
+}
+
+func (c *Consumer) runWithRetry(ctx context.Context, handler Handler, event *models.DomainEvent) (int, error) {
+	var err error
+	for attempt := 1; attempt <= c.retry.MaxAttempts; attempt++ {
+		if err = handler(ctx, event); err == nil {
+			return attempt, nil
+		}
+		if IsPermanent(err) || attempt == c.retry.MaxAttempts {
+			return attempt, err
+		}
+
+		utils.Logger.Warn(ctx, "Event handler failed, retrying", map[string]interface{}{
+			"consumer":   c.name,
+			"event_id":   event.ID,
+			"event_type": event.EventType,
+			"attempt":    attempt,
+			"error":      err.Error(),
+		})
+		if sleepErr := c.sleep(ctx, c.retry.Backoff(attempt)); sleepErr != nil {
+			return attempt, sleepErr
+		}
+	}
+	return c.retry.MaxAttempts, err
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, event *models.DomainEvent, attempts int, cause error) error {
+	utils.Logger.Error(ctx, "Event moved to dead-letter store", cause, map[string]interface{}{
+		"consumer":   c.name,
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"attempts":   attempts,
+	})
+
+	return c.deadLetters.Save(ctx, &DeadLetter{
+		ID:        uuid.New().String(),
+		Consumer:  c.name,
+		Event:     event,
+		Error:     cause.Error(),
+		Attempts:  attempts,
+		CreatedAt: time.Now(),
+	})
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
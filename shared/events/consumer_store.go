@@ -0,0 +1,249 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// PostgresProcessedEventStore tracks handled events in the processed_events table
+type PostgresProcessedEventStore struct {
+	db *sql.DB
+}
+
+// NewPostgresProcessedEventStore creates a new PostgreSQL processed-event store
+func NewPostgresProcessedEventStore(db *sql.DB) *PostgresProcessedEventStore {
+	return &PostgresProcessedEventStore{db: db}
+}
+
+// IsProcessed reports whether consumer has already handled the event
+func (s *PostgresProcessedEventStore) IsProcessed(ctx context.Context, consumer, eventID string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM processed_events
+			WHERE consumer = $1 AND event_id = $2 AND claimed_until IS NULL
+		)`
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, consumer, eventID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check processed event: %w", err)
+	}
+	return exists, nil
+}
+
+// Claim claims the event for consumer until the given time. It returns false
+// when the event was already handled or another claim on it has not lapsed.
+func (s *PostgresProcessedEventStore) Claim(ctx context.Context, consumer string, event *models.DomainEvent, until time.Time) (bool, error) {
+	query := `
+		INSERT INTO processed_events (consumer, event_id, event_type, processed_at, claimed_until)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (consumer, event_id) DO UPDATE SET claimed_until = EXCLUDED.claimed_until
+		WHERE processed_events.claimed_until < EXCLUDED.processed_at`
+
+	result, err := s.db.ExecContext(ctx, query, consumer, event.ID, event.EventType, time.Now(), until)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// Release drops consumer's claim on an event it did not handle
+func (s *PostgresProcessedEventStore) Release(ctx context.Context, consumer, eventID string) error {
+	query := `DELETE FROM processed_events WHERE consumer = $1 AND event_id = $2 AND claimed_until IS NOT NULL`
+
+	if _, err := s.db.ExecContext(ctx, query, consumer, eventID); err != nil {
+		return fmt.Errorf("failed to release event claim: %w", err)
+	}
+	return nil
+}
+
+// MarkProcessed records that consumer handled the event
+func (s *PostgresProcessedEventStore) MarkProcessed(ctx context.Context, consumer string, event *models.DomainEvent) error {
+	query := `
+		INSERT INTO processed_events (consumer, event_id, event_type, processed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (consumer, event_id) DO UPDATE
+		SET processed_at = EXCLUDED.processed_at, claimed_until = NULL`
+
+	if _, err := s.db.ExecContext(ctx, query, consumer, event.ID, event.EventType, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark event processed: %w", err)
+	}
+	return nil
+}
+
+// PostgresDeadLetterStore keeps dead-lettered events in the dead_letter_events table
+type PostgresDeadLetterStore struct {
+	db *sql.DB
+}
+
+// NewPostgresDeadLetterStore creates a new PostgreSQL dead-letter store
+func NewPostgresDeadLetterStore(db *sql.DB) *PostgresDeadLetterStore {
+	return &PostgresDeadLetterStore{db: db}
+}
+
+// Save stores a dead-lettered event
+func (s *PostgresDeadLetterStore) Save(ctx context.Context, letter *DeadLetter) error {
+	payload, err := json.Marshal(letter.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead-letter event: %w", err)
+	}
+
+	query := `
+		INSERT INTO dead_letter_events (
+			id, consumer, event_id, event_type, aggregate_id, payload, error, attempts, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = s.db.ExecContext(ctx, query,
+		letter.ID, letter.Consumer, letter.Event.ID, letter.Event.EventType, letter.Event.AggregateID,
+		payload, letter.Error, letter.Attempts, letter.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save dead-letter event: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a dead-lettered event by ID
+func (s *PostgresDeadLetterStore) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	query := `
+		SELECT id, consumer, payload, error, attempts, replay_count, replayed_at, created_at
+		FROM dead_letter_events WHERE id = $1`
+
+	letter, err := scanDeadLetter(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NewNotFoundError("dead-letter event")
+		}
+		return nil, fmt.Errorf("failed to get dead-letter event: %w", err)
+	}
+	return letter, nil
+}
+
+// List returns dead-lettered events, newest first, with the total match count
+func (s *PostgresDeadLetterStore) List(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Consumer != "" {
+		args = append(args, filter.Consumer)
+		conditions = append(conditions, fmt.Sprintf("consumer = $%d", len(args)))
+	}
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		conditions = append(conditions, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+	if !filter.IncludeReplayed {
+		conditions = append(conditions, "replayed_at IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM dead_letter_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead-letter events: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT id, consumer, payload, error, attempts, replay_count, replayed_at, created_at
+		FROM dead_letter_events%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead-letter events: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []*DeadLetter
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan dead-letter event: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead-letter events: %w", err)
+	}
+
+	return letters, total, nil
+}
+
+// MarkReplayed records that the event was sent back to the broker
+func (s *PostgresDeadLetterStore) MarkReplayed(ctx context.Context, id string) error {
+	query := `UPDATE dead_letter_events SET replayed_at = $2, replay_count = replay_count + 1 WHERE id = $1`
+
+	result, err := s.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark dead-letter event replayed: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.NewNotFoundError("dead-letter event")
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetter, error) {
+	var letter DeadLetter
+	var payload []byte
+	var replayedAt sql.NullTime
+
+	err := row.Scan(&letter.ID, &letter.Consumer, &payload, &letter.Error, &letter.Attempts,
+		&letter.ReplayCount, &replayedAt, &letter.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.DomainEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead-letter event: %w", err)
+	}
+	letter.Event = &event
+
+	if replayedAt.Valid {
+		letter.ReplayedAt = &replayedAt.Time
+	}
+
+	return &letter, nil
+}
+
+// ReplayDeadLetter republishes a dead-lettered event so its consumer can try
+// again. Consumers that already handled the event skip it by event ID.
+func ReplayDeadLetter(ctx context.Context, store DeadLetterStore, publisher Publisher, id string) (*DeadLetter, error) {
+	letter, err := store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := publisher.Publish(ctx, letter.Event); err != nil {
+		return nil, fmt.Errorf("failed to replay dead-letter event: %w", err)
+	}
+
+	if err := store.MarkReplayed(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return store.Get(ctx, id)
+}
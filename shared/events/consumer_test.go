@@ -0,0 +1,258 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopspring/decimal"
+)
+
+func newTestConsumer(maxAttempts int) (*Consumer, *InMemoryProcessedEventStore, *InMemoryDeadLetterStore, *[]time.Duration) {
+	processed := NewInMemoryProcessedEventStore()
+	deadLetters := NewInMemoryDeadLetterStore()
+	consumer := NewConsumer("test-consumer", processed, deadLetters, RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     25 * time.Millisecond,
+		Multiplier:     2,
+	})
+
+	var delays []time.Duration
+	consumer.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return consumer, processed, deadLetters, &delays
+}
+
+func TestConsumer_HandleDecodesTypedPayload(t *testing.T) {
+	consumer, _, _, _ := newTestConsumer(3)
+
+	var received models.PaymentProcessedData
+	Handle(consumer, models.EventPaymentProcessed, func(ctx context.Context, event *models.DomainEvent, data models.PaymentProcessedData) error {
+		received = data
+		return nil
+	})
+
+	event, _ := models.NewDomainEvent(models.EventPaymentProcessed, "payment-1", models.PaymentProcessedData{
+		PaymentID: "payment-1",
+		OrderID:   "order-1",
+		Amount:    decimal.NewFromFloat(49.99),
+	}, models.EventMetadata{})
+
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if received.OrderID != "order-1" || !received.Amount.Equal(decimal.NewFromFloat(49.99)) {
+		t.Errorf("Unexpected payload: %+v", received)
+	}
+}
+
+func TestConsumer_DispatchIsIdempotent(t *testing.T) {
+	consumer, _, _, _ := newTestConsumer(3)
+
+	calls := 0
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		calls++
+		return nil
+	})
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	for i := 0; i < 3; i++ {
+		if err := consumer.Dispatch(context.Background(), event); err != nil {
+			t.Fatalf("Dispatch failed: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestConsumer_ConcurrentDeliveriesAreHandledOnce(t *testing.T) {
+	consumer, _, _, _ := newTestConsumer(3)
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	calls := 0
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		calls++
+		close(started)
+		<-finish
+		return nil
+	})
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	done := make(chan error)
+	go func() { done <- consumer.Dispatch(context.Background(), event) }()
+	<-started
+
+	// A redelivery while the first is still being handled is skipped
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestConsumer_LapsedClaimIsHandledAgain(t *testing.T) {
+	consumer, processed, _, _ := newTestConsumer(3)
+
+	calls := 0
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		calls++
+		return nil
+	})
+
+	// A consumer that crashed mid-handler leaves a claim behind
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	if ok, _ := processed.Claim(context.Background(), "test-consumer", event, time.Now().Add(-time.Second)); !ok {
+		t.Fatal("Expected the event to be claimed")
+	}
+
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the lapsed claim to be handled, ran %d times", calls)
+	}
+	if ok, _ := processed.IsProcessed(context.Background(), "test-consumer", event.ID); !ok {
+		t.Error("Expected event to be marked processed")
+	}
+}
+
+func TestConsumer_RetriesWithBackoffThenSucceeds(t *testing.T) {
+	consumer, processed, deadLetters, delays := newTestConsumer(5)
+
+	calls := 0
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		calls++
+		if calls < 4 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}
+	if len(*delays) != len(expected) {
+		t.Fatalf("Expected %d backoffs, got %v", len(expected), *delays)
+	}
+	for i, d := range expected {
+		if (*delays)[i] != d {
+			t.Errorf("Backoff %d: expected %v, got %v", i, d, (*delays)[i])
+		}
+	}
+
+	if ok, _ := processed.IsProcessed(context.Background(), "test-consumer", event.ID); !ok {
+		t.Error("Expected event to be marked processed")
+	}
+	if _, total, _ := deadLetters.List(context.Background(), DeadLetterFilter{}); total != 0 {
+		t.Errorf("Expected no dead letters, got %d", total)
+	}
+}
+
+func TestConsumer_DeadLettersAfterMaxAttempts(t *testing.T) {
+	consumer, processed, deadLetters, _ := newTestConsumer(3)
+
+	calls := 0
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		calls++
+		return errors.New("downstream unavailable")
+	})
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	letters, total, _ := deadLetters.List(context.Background(), DeadLetterFilter{})
+	if total != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", total)
+	}
+	if letters[0].Attempts != 3 || letters[0].Error != "downstream unavailable" || letters[0].Event.ID != event.ID {
+		t.Errorf("Unexpected dead letter: %+v", letters[0])
+	}
+	if ok, _ := processed.IsProcessed(context.Background(), "test-consumer", event.ID); ok {
+		t.Error("Dead-lettered event must not be marked processed")
+	}
+	if ok, _ := processed.Claim(context.Background(), "test-consumer", event, time.Now().Add(time.Minute)); !ok {
+		t.Error("Expected the dead-lettered event's claim to be released")
+	}
+}
+
+func TestConsumer_UndecodablePayloadIsNotRetried(t *testing.T) {
+	consumer, _, deadLetters, delays := newTestConsumer(5)
+
+	Handle(consumer, models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent, data models.OrderCreatedData) error {
+		t.Error("Handler should not be called for an undecodable payload")
+		return nil
+	})
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	event.Data = json.RawMessage(`{"total": "not-a-number"}`)
+
+	if err := consumer.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if len(*delays) != 0 {
+		t.Errorf("Expected no retries, got %v", *delays)
+	}
+	if _, total, _ := deadLetters.List(context.Background(), DeadLetterFilter{}); total != 1 {
+		t.Errorf("Expected 1 dead letter, got %d", total)
+	}
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	consumer, processed, deadLetters, _ := newTestConsumer(1)
+	broker := NewInMemoryBroker()
+
+	healthy := false
+	consumer.Register(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		if !healthy {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	})
+	consumer.SubscribeTo(broker)
+
+	event := newTestEvent(t, models.EventOrderCreated, "order-1")
+	if err := broker.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	letters, _, _ := deadLetters.List(context.Background(), DeadLetterFilter{})
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+
+	healthy = true
+	replayed, err := ReplayDeadLetter(context.Background(), deadLetters, broker, letters[0].ID)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if replayed.ReplayedAt == nil || replayed.ReplayCount != 1 {
+		t.Errorf("Expected dead letter to be marked replayed, got %+v", replayed)
+	}
+	if ok, _ := processed.IsProcessed(context.Background(), "test-consumer", event.ID); !ok {
+		t.Error("Expected replayed event to be processed")
+	}
+	if _, total, _ := deadLetters.List(context.Background(), DeadLetterFilter{}); total != 0 {
+		t.Errorf("Expected replayed letters to be hidden by default, got %d", total)
+	}
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// InMemoryBroker is a synchronous, process-local Broker.
+// It is intended for tests and single-process development setups.
+type InMemoryBroker struct {
+	mutex     sync.RWMutex
+	handlers  map[models.EventType][]Handler
+	published []*models.DomainEvent
+}
+
+// NewInMemoryBroker creates a new in-memory broker
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		handlers: make(map[models.EventType][]Handler),
+	}
+}
+
+// Subscribe registers a handler for the given event type
+func (b *InMemoryBroker) Subscribe(eventType models.EventType, handler Handler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish records the event and delivers it to every subscribed handler
+func (b *InMemoryBroker) Publish(ctx context.Context, event *models.DomainEvent) error {
+	b.mutex.Lock()
+	b.published = append(b.published, event)
+	handlers := append([]Handler(nil), b.handlers[event.EventType]...)
+	b.mutex.Unlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Published returns every event published so far, in order
+func (b *InMemoryBroker) Published() []*models.DomainEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return append([]*models.DomainEvent(nil), b.published...)
+}
+
+// Reset clears the recorded events but keeps subscriptions
+func (b *InMemoryBroker) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.published = nil
+}
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// InMemoryProcessedEventStore is a ProcessedEventStore for tests
+type InMemoryProcessedEventStore struct {
+	mutex     sync.RWMutex
+	processed map[string]bool
+	claims    map[string]time.Time
+}
+
+// NewInMemoryProcessedEventStore creates a new in-memory processed-event store
+func NewInMemoryProcessedEventStore() *InMemoryProcessedEventStore {
+	return &InMemoryProcessedEventStore{processed: make(map[string]bool), claims: make(map[string]time.Time)}
+}
+
+// IsProcessed reports whether consumer has already handled the event
+func (s *InMemoryProcessedEventStore) IsProcessed(ctx context.Context, consumer, eventID string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.processed[consumer+"/"+eventID], nil
+}
+
+// Claim claims the event for consumer until the given time
+func (s *InMemoryProcessedEventStore) Claim(ctx context.Context, consumer string, event *models.DomainEvent, until time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := consumer + "/" + event.ID
+	if s.processed[key] || time.Now().Before(s.claims[key]) {
+		return false, nil
+	}
+	s.claims[key] = until
+	return true, nil
+}
+
+// Release drops consumer's claim on an event it did not handle
+func (s *InMemoryProcessedEventStore) Release(ctx context.Context, consumer, eventID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.claims, consumer+"/"+eventID)
+	return nil
+}
+
+// MarkProcessed records that consumer handled the event
+func (s *InMemoryProcessedEventStore) MarkProcessed(ctx context.Context, consumer string, event *models.DomainEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.processed[consumer+"/"+event.ID] = true
+	delete(s.claims, consumer+"/"+event.ID)
+	return nil
+}
+
+// InMemoryDeadLetterStore is a DeadLetterStore for tests
+type InMemoryDeadLetterStore struct {
+	mutex   sync.RWMutex
+	letters map[string]*DeadLetter
+}
+
+// NewInMemoryDeadLetterStore creates a new in-memory dead-letter store
+func NewInMemoryDeadLetterStore() *InMemoryDeadLetterStore {
+	return &InMemoryDeadLetterStore{letters: make(map[string]*DeadLetter)}
+}
+
+// Save stores a dead-lettered event
+func (s *InMemoryDeadLetterStore) Save(ctx context.Context, letter *DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *letter
+	s.letters[letter.ID] = &stored
+	return nil
+}
+
+// Get retrieves a dead-lettered event by ID
+func (s *InMemoryDeadLetterStore) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	letter, ok := s.letters[id]
+	if !ok {
+		return nil, utils.NewNotFoundError("dead-letter event")
+	}
+	result := *letter
+	return &result, nil
+}
+
+// List returns dead-lettered events matching the filter, newest first
+func (s *InMemoryDeadLetterStore) List(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var matched []*DeadLetter
+	for _, letter := range s.letters {
+		if filter.Consumer != "" && letter.Consumer != filter.Consumer {
+			continue
+		}
+		if filter.EventType != "" && letter.Event.EventType != filter.EventType {
+			continue
+		}
+		if !filter.IncludeReplayed && letter.ReplayedAt != nil {
+			continue
+		}
+		result := *letter
+		matched = append(matched, &result)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, total, nil
+}
+
+// MarkReplayed records that the event was sent back to the broker
+func (s *InMemoryDeadLetterStore) MarkReplayed(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	letter, ok := s.letters[id]
+	if !ok {
+		return utils.NewNotFoundError("dead-letter event")
+	}
+	now := time.Now()
+	letter.ReplayedAt = &now
+	letter.ReplayCount++
+	return nil
+}
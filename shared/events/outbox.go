@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+// OutboxMessage is a domain event waiting in the outbox to be relayed
+type OutboxMessage struct {
+	ID        string
+	Event     *models.DomainEvent
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// OutboxStore is the relay's view of the outbox table
+type OutboxStore interface {
+	// Claim locks up to limit unpublished messages for this relay instance
+	Claim(ctx context.Context, limit int) ([]*OutboxMessage, error)
+	MarkPublished(ctx context.Context, id string) error
+	// MarkFailed records the publish error and leaves the message unclaimed
+	// until retryAt
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+}
+
+// PostgresOutbox stores domain events in the event_outbox table.
+// Events are written with Save inside the caller's transaction so that
+// they are committed or rolled back together with the business change.
+type PostgresOutbox struct {
+	db    *sql.DB
+	lease time.Duration
+}
+
+// NewPostgresOutbox creates a new PostgreSQL-backed outbox
+func NewPostgresOutbox(db *sql.DB) *PostgresOutbox {
+	return &PostgresOutbox{
+		db:    db,
+		lease: 30 * time.Second,
+	}
+}
+
+// Save writes the event to the outbox as part of tx
+func (o *PostgresOutbox) Save(ctx context.Context, tx *sql.Tx, event *models.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO event_outbox (id, event_type, aggregate_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.ExecContext(ctx, query,
+		event.ID, event.EventType, event.AggregateID, payload, event.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to write event to outbox: %w", err)
+	}
+
+	return nil
+}
+
+// Claim leases a batch of unpublished events. A lease that is not released by
+// MarkPublished or MarkFailed expires, so a crashed relay never strands events.
+func (o *PostgresOutbox) Claim(ctx context.Context, limit int) ([]*OutboxMessage, error) {
+	query := `
+		UPDATE event_outbox SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until < $3)
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, attempts, COALESCE(last_error, ''), created_at`
+
+	now := time.Now()
+	rows, err := o.db.QueryContext(ctx, query, limit, now.Add(o.lease), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&msg.ID, &payload, &msg.Attempts, &msg.LastError, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+
+		var event models.DomainEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outbox event %s: %w", msg.ID, err)
+		}
+		msg.Event = &event
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox events: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not preserve the subquery ordering
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+
+	return messages, nil
+}
+
+// MarkPublished records that the event reached the broker
+func (o *PostgresOutbox) MarkPublished(ctx context.Context, id string) error {
+	query := `UPDATE event_outbox SET published_at = $2, locked_until = NULL WHERE id = $1`
+	if _, err := o.db.ExecContext(ctx, query, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records the publish error and extends the lease until retryAt,
+// so the event is not claimed again before then
+func (o *PostgresOutbox) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	query := `
+		UPDATE event_outbox SET attempts = attempts + 1, last_error = $2, locked_until = $3
+		WHERE id = $1`
+	if _, err := o.db.ExecContext(ctx, query, id, cause.Error(), retryAt); err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+	return nil
+}
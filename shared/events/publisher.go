@@ -0,0 +1,40 @@
+package events
+
+import (
+	"context"
+
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// Publisher publishes domain events to interested consumers
+type Publisher interface {
+	Publish(ctx context.Context, event *models.DomainEvent) error
+}
+
+// Handler processes a single domain event
+type Handler func(ctx context.Context, event *models.DomainEvent) error
+
+// Broker is a Publisher that also lets consumers subscribe to event types
+type Broker interface {
+	Publisher
+	Subscribe(eventType models.EventType, handler Handler)
+}
+
+// PublisherFunc adapts an ordinary function to the Publisher interface
+type PublisherFunc func(ctx context.Context, event *models.DomainEvent) error
+
+// Publish calls f(ctx, event)
+func (f PublisherFunc) Publish(ctx context.Context, event *models.DomainEvent) error {
+	return f(ctx, event)
+}
+
+// MetadataFromContext builds event metadata from the request context
+func MetadataFromContext(ctx context.Context, serviceName string) models.EventMetadata {
+	return models.EventMetadata{
+		UserID:      utils.GetUserID(ctx),
+		ServiceName: serviceName,
+		TraceID:     utils.GetTraceID(ctx),
+		Source:      serviceName,
+	}
+}
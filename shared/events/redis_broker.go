@@ -0,0 +1,285 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopsphere/shared/models"
+	"github.com/shopsphere/shared/utils"
+)
+
+// RedisBroker distributes domain events between services over Redis Streams.
+// Each event type is appended to its own stream. Listeners read the streams
+// through a consumer group, so every event is handled by one replica of each
+// listening service and stays pending until that replica acknowledges it.
+// Events left pending by a replica that failed or crashed are claimed again
+// by a listener once they have been idle for ClaimIdle. Events are handled by
+// a pool of workers so a handler backing off between retries does not hold up
+// the events behind it.
+type RedisBroker struct {
+	client *redis.Client
+	prefix string
+
+	// MaxLen caps each stream at roughly this many events
+	MaxLen int64
+	// ClaimIdle is how long an unacknowledged event waits before it is redelivered
+	ClaimIdle time.Duration
+	// Workers is how many events a listener handles at once
+	Workers int
+
+	mutex    sync.RWMutex
+	handlers map[models.EventType][]Handler
+}
+
+// NewRedisBroker creates a new Redis-backed broker
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{
+		client:    client,
+		prefix:    "shopsphere:events:",
+		MaxLen:    100000,
+		ClaimIdle: time.Minute,
+		Workers:   8,
+		handlers:  make(map[models.EventType][]Handler),
+	}
+}
+
+// Publish appends the event to the stream for its event type
+func (b *RedisBroker) Publish(ctx context.Context, event *models.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.stream(event.EventType),
+		MaxLen: b.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": payload},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish event to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe registers a handler for the given event type.
+// Handlers must be registered before Listen is called.
+func (b *RedisBroker) Subscribe(eventType models.EventType, handler Handler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Listen receives events for every subscribed type as a member of the given
+// consumer group until ctx is cancelled. Replicas of a service share a group
+// and split its events between them. A group created for the first time only
+// receives events published from then on.
+func (b *RedisBroker) Listen(ctx context.Context, group string) error {
+	b.mutex.RLock()
+	streams := make([]string, 0, len(b.handlers))
+	for eventType := range b.handlers {
+		streams = append(streams, b.stream(eventType))
+	}
+	b.mutex.RUnlock()
+
+	if len(streams) == 0 {
+		return nil
+	}
+	sort.Strings(streams)
+
+	for _, stream := range streams {
+		err := b.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group for %s: %w", stream, err)
+		}
+	}
+
+	consumer := consumerName()
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+
+	// Reading blocks while every worker is busy
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	workers := make(chan struct{}, max(b.Workers, 1))
+	deliver := func(stream string, msg redis.XMessage) {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			b.deliver(ctx, group, stream, msg)
+		}()
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= b.ClaimIdle {
+			b.claimStale(ctx, group, consumer, streams, deliver)
+			lastClaim = time.Now()
+		}
+
+		result, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  args,
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			utils.Logger.Error(ctx, "Failed to read events from redis", err, map[string]interface{}{
+				"group": group,
+			})
+			sleepContext(ctx, time.Second)
+			continue
+		}
+
+		for _, stream := range result {
+			for _, msg := range stream.Messages {
+				deliver(stream.Stream, msg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// claimStale takes over events that other members of the group, or this
+// listener, read but never acknowledged
+func (b *RedisBroker) claimStale(ctx context.Context, group, consumer string, streams []string, deliver func(stream string, msg redis.XMessage)) {
+	for _, stream := range streams {
+		messages, _, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			MinIdle:  b.ClaimIdle,
+			Start:    "0-0",
+			Count:    100,
+			Consumer: consumer,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				utils.Logger.Error(ctx, "Failed to claim pending events from redis", err, map[string]interface{}{
+					"group":  group,
+					"stream": stream,
+				})
+			}
+			continue
+		}
+
+		for _, msg := range messages {
+			deliver(stream, msg)
+		}
+	}
+}
+
+// deliver dispatches one stream entry and acknowledges it once every handler
+// succeeded. Entries that cannot be decoded are acknowledged and dropped.
+func (b *RedisBroker) deliver(ctx context.Context, group, stream string, msg redis.XMessage) {
+	payload, _ := msg.Values["event"].(string)
+
+	var event models.DomainEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		utils.Logger.Error(ctx, "Failed to decode event from redis", err, map[string]interface{}{
+			"stream":    stream,
+			"stream_id": msg.ID,
+		})
+	} else if err := b.dispatch(ctx, &event); err != nil {
+		return
+	}
+
+	if err := b.client.XAck(ctx, stream, group, msg.ID).Err(); err != nil {
+		utils.Logger.Error(ctx, "Failed to acknowledge event", err, map[string]interface{}{
+			"stream":    stream,
+			"stream_id": msg.ID,
+		})
+	}
+}
+
+func (b *RedisBroker) dispatch(ctx context.Context, event *models.DomainEvent) error {
+	b.mutex.RLock()
+	handlers := b.handlers[event.EventType]
+	b.mutex.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			utils.Logger.Error(ctx, "Event handler failed", err, map[string]interface{}{
+				"event_id":   event.ID,
+				"event_type": event.EventType,
+			})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *RedisBroker) stream(eventType models.EventType) string {
+	return b.prefix + string(eventType)
+}
+
+// consumerName identifies this process within a consumer group
+func consumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + uuid.New().String()[:8]
+}
+
+// ConnectRedisBroker connects to Redis in the background and calls onConnect
+// with a broker once Redis answers. Failed attempts are retried with backoff
+// until ctx is cancelled, so a service started while Redis is down begins
+// relaying and listening as soon as Redis is back. The returned channel is
+// closed once onConnect returns or connecting is given up.
+func ConnectRedisBroker(ctx context.Context, config *utils.RedisConfig, onConnect func(broker *RedisBroker)) <-chan struct{} {
+	retry := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for attempt := 1; ; attempt++ {
+			client, err := config.Connect()
+			if err == nil {
+				if attempt > 1 {
+					utils.Logger.Info(ctx, "Connected to Redis", map[string]interface{}{
+						"attempts": attempt,
+					})
+				}
+				onConnect(NewRedisBroker(client))
+				return
+			}
+
+			delay := retry.Backoff(attempt)
+			utils.Logger.Error(ctx, "Failed to connect to Redis, retrying", err, map[string]interface{}{
+				"attempt":  attempt,
+				"retry_in": delay.String(),
+			})
+			if sleepContext(ctx, delay) != nil {
+				return
+			}
+		}
+	}()
+
+	return done
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopsphere/shared/utils"
+)
+
+// RelayConfig holds outbox relay configuration
+type RelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// InitialBackoff and MaxBackoff bound how long an event that failed to
+	// publish waits before it is tried again; the wait doubles per attempt
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRelayConfig returns the default relay configuration
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval:   time.Second,
+		BatchSize:      100,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+	}
+}
+
+// Relay drains the outbox and hands each event to a publisher
+type Relay struct {
+	store     OutboxStore
+	publisher Publisher
+	config    RelayConfig
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(store OutboxStore, publisher Publisher, config RelayConfig) *Relay {
+	defaults := DefaultRelayConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, failed, err := r.flush(ctx)
+			if err != nil {
+				utils.Logger.Error(ctx, "Failed to relay outbox events", err)
+				break
+			}
+			// A full batch means there is probably more waiting, unless the
+			// broker is failing, in which case draining waits for the next tick
+			if n < r.config.BatchSize || failed > 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending events and returns how many were claimed.
+// Events that fail to publish are retried after a backoff that grows with
+// each failed attempt.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	n, _, err := r.flush(ctx)
+	return n, err
+}
+
+// flush publishes one batch and also returns how many events failed to publish
+func (r *Relay) flush(ctx context.Context) (int, int, error) {
+	messages, err := r.store.Claim(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	failed := 0
+	for _, msg := range messages {
+		if err := r.publisher.Publish(ctx, msg.Event); err != nil {
+			failed++
+			retryIn := r.backoff(msg.Attempts + 1)
+			utils.Logger.Warn(ctx, "Failed to publish outbox event", map[string]interface{}{
+				"event_id":   msg.ID,
+				"event_type": msg.Event.EventType,
+				"attempts":   msg.Attempts + 1,
+				"retry_in":   retryIn.String(),
+				"error":      err.Error(),
+			})
+			if markErr := r.store.MarkFailed(ctx, msg.ID, err, time.Now().Add(retryIn)); markErr != nil {
+				return len(messages), failed, markErr
+			}
+			continue
+		}
+
+		if err := r.store.MarkPublished(ctx, msg.ID); err != nil {
+			return len(messages), failed, err
+		}
+	}
+
+	return len(messages), failed, nil
+}
+
+// backoff returns how long an event waits after its given failed attempt (1-based)
+func (r *Relay) backoff(attempts int) time.Duration {
+	policy := RetryPolicy{InitialBackoff: r.config.InitialBackoff, MaxBackoff: r.config.MaxBackoff, Multiplier: 2}
+	return policy.Backoff(attempts)
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopsphere/shared/models"
+)
+
+type memoryOutbox struct {
+	mutex     sync.Mutex
+	messages  []*OutboxMessage
+	published map[string]bool
+	claimed   map[string]bool
+	retryAt   map[string]time.Time
+	claims    int
+}
+
+func newMemoryOutbox(events ...*models.DomainEvent) *memoryOutbox {
+	o := &memoryOutbox{published: map[string]bool{}, claimed: map[string]bool{}, retryAt: map[string]time.Time{}}
+	for _, e := range events {
+		o.messages = append(o.messages, &OutboxMessage{ID: e.ID, Event: e, CreatedAt: e.Timestamp})
+	}
+	return o
+}
+
+func (o *memoryOutbox) Claim(ctx context.Context, limit int) ([]*OutboxMessage, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.claims++
+	var batch []*OutboxMessage
+	for _, msg := range o.messages {
+		if len(batch) == limit {
+			break
+		}
+		if o.published[msg.ID] || o.claimed[msg.ID] || time.Now().Before(o.retryAt[msg.ID]) {
+			continue
+		}
+		o.claimed[msg.ID] = true
+		batch = append(batch, msg)
+	}
+	return batch, nil
+}
+
+func (o *memoryOutbox) MarkPublished(ctx context.Context, id string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.published[id] = true
+	delete(o.claimed, id)
+	return nil
+}
+
+func (o *memoryOutbox) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, msg := range o.messages {
+		if msg.ID == id {
+			msg.Attempts++
+			msg.LastError = cause.Error()
+		}
+	}
+	o.retryAt[id] = retryAt
+	delete(o.claimed, id)
+	return nil
+}
+
+func newTestEvent(t *testing.T, eventType models.EventType, aggregateID string) *models.DomainEvent {
+	event, err := models.NewDomainEvent(eventType, aggregateID, map[string]string{"id": aggregateID}, models.EventMetadata{})
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	return event
+}
+
+func TestRelay_FlushDeliversToSubscribers(t *testing.T) {
+	orderEvent := newTestEvent(t, models.EventOrderCreated, "order-1")
+	paymentEvent := newTestEvent(t, models.EventPaymentProcessed, "payment-1")
+	store := newMemoryOutbox(orderEvent, paymentEvent)
+
+	broker := NewInMemoryBroker()
+	var received []string
+	broker.Subscribe(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		var data map[string]string
+		if err := event.UnmarshalData(&data); err != nil {
+			return err
+		}
+		received = append(received, data["id"])
+		return nil
+	})
+
+	relay := NewRelay(store, broker, RelayConfig{BatchSize: 10})
+	n, err := relay.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 events claimed, got %d", n)
+	}
+	if len(broker.Published()) != 2 {
+		t.Errorf("Expected 2 events published, got %d", len(broker.Published()))
+	}
+	if len(received) != 1 || received[0] != "order-1" {
+		t.Errorf("Expected order handler to receive order-1, got %v", received)
+	}
+	if !store.published[orderEvent.ID] || !store.published[paymentEvent.ID] {
+		t.Error("Expected both events to be marked published")
+	}
+
+	// Nothing left to relay
+	n, err = relay.Flush(context.Background())
+	if err != nil || n != 0 {
+		t.Errorf("Expected empty second flush, got n=%d err=%v", n, err)
+	}
+}
+
+func TestRelay_FlushRetriesFailedEvents(t *testing.T) {
+	event := newTestEvent(t, models.EventInventoryUpdated, "product-1")
+	store := newMemoryOutbox(event)
+
+	failures := 1
+	publisher := PublisherFunc(func(ctx context.Context, e *models.DomainEvent) error {
+		if failures > 0 {
+			failures--
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+
+	relay := NewRelay(store, publisher, DefaultRelayConfig())
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if store.published[event.ID] {
+		t.Fatal("Expected event to remain unpublished after broker failure")
+	}
+	if store.messages[0].Attempts != 1 || store.messages[0].LastError != "broker unavailable" {
+		t.Errorf("Expected failed attempt to be recorded, got %+v", store.messages[0])
+	}
+
+	// The event backs off before it is tried again
+	if n, _ := relay.Flush(context.Background()); n != 0 {
+		t.Fatalf("Expected the failed event to wait out its backoff, got %d claimed", n)
+	}
+	if wait := time.Until(store.retryAt[event.ID]); wait <= 0 || wait > DefaultRelayConfig().InitialBackoff {
+		t.Errorf("Expected a retry within the initial backoff, got %s", wait)
+	}
+
+	store.retryAt[event.ID] = time.Time{}
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if !store.published[event.ID] {
+		t.Error("Expected event to be published on retry")
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(newMemoryOutbox(), NewInMemoryBroker(), RelayConfig{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRelay_RunStopsDrainingWhenPublishFails(t *testing.T) {
+	store := newMemoryOutbox(
+		newTestEvent(t, models.EventOrderCreated, "order-1"),
+		newTestEvent(t, models.EventOrderCreated, "order-2"),
+		newTestEvent(t, models.EventOrderCreated, "order-3"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	publisher := PublisherFunc(func(context.Context, *models.DomainEvent) error {
+		// Stop after this tick so Run returns
+		cancel()
+		return errors.New("broker unavailable")
+	})
+
+	// Full batches would normally be drained back to back
+	NewRelay(store, publisher, RelayConfig{BatchSize: 1, PollInterval: time.Hour}).Run(ctx)
+
+	if store.claims != 1 {
+		t.Errorf("Expected draining to stop after the failed publish, got %d claims", store.claims)
+	}
+}
+
+func TestInMemoryBroker_PublishReturnsHandlerErrors(t *testing.T) {
+	broker := NewInMemoryBroker()
+	broker.Subscribe(models.EventOrderCreated, func(ctx context.Context, event *models.DomainEvent) error {
+		return errors.New("handler failed")
+	})
+
+	err := broker.Publish(context.Background(), newTestEvent(t, models.EventOrderCreated, "order-1"))
+	if err == nil {
+		t.Error("Expected handler error to be returned")
+	}
+
+	broker.Reset()
+	if len(broker.Published()) != 0 {
+		t.Error("Expected Reset to clear published events")
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/shopsphere/shared/utils"
+)
+
+// UserIDHeader carries the ID of the user a request is made for when it comes
+// through the gateway or another service
+const UserIDHeader = "X-User-ID"
+
+// ServiceNameHeader carries the name of the service making a request
+const ServiceNameHeader = "X-Service-Name"
+
+// Caller adds the calling user and service from the request headers to the
+// context. A user already set by Authenticate is kept.
+func Caller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if userID := r.Header.Get(UserIDHeader); userID != "" && utils.GetUserID(ctx) == "" {
+			ctx = utils.WithUserID(ctx, userID)
+		}
+		if serviceName := r.Header.Get(ServiceNameHeader); serviceName != "" {
+			ctx = utils.WithCallerService(ctx, serviceName)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
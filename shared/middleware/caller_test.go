@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopsphere/shared/utils"
+)
+
+func TestCaller_AddsUserAndServiceToContext(t *testing.T) {
+	var userID, service string
+	handler := Caller(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID = utils.GetUserID(r.Context())
+		service = utils.GetCallerService(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/products/p1/reserve-stock", nil)
+	req.Header.Set(UserIDHeader, "user-1")
+	req.Header.Set(ServiceNameHeader, "order-service")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if userID != "user-1" || service != "order-service" {
+		t.Errorf("Expected user-1 from order-service, got %q from %q", userID, service)
+	}
+
+	// An authenticated user wins over the header
+	req = httptest.NewRequest(http.MethodGet, "/inventory/movements", nil)
+	req.Header.Set(UserIDHeader, "user-2")
+	req = req.WithContext(utils.WithUserID(req.Context(), "admin-1"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if userID != "admin-1" || service != "" {
+		t.Errorf("Expected admin-1 without a service, got %q from %q", userID, service)
+	}
+}
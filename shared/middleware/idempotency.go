@@ -0,0 +1,338 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shopsphere/shared/utils"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from a stored key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// ErrIdempotencyKeyExists is returned by IdempotencyStore.Begin when the key
+// is already held by an unexpired record
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// IdempotencyRecord is the stored state of one idempotency key. A record
+// without a StatusCode belongs to a request that is still being handled.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response for the key has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// IdempotencyStore persists idempotency keys and the responses they produced
+type IdempotencyStore interface {
+	// Begin claims the key for a new request. When the key is already held
+	// it returns the existing record and ErrIdempotencyKeyExists.
+	Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the response for a claimed key
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release drops a claimed key whose request failed so it can be retried
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes keys past their expiry
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// IdempotencyConfig configures the idempotency middleware
+type IdempotencyConfig struct {
+	// Scope namespaces keys, normally the service name
+	Scope string
+	// TTL is how long a key and its response are kept
+	TTL time.Duration
+	// MaxBodySize bounds the request bodies that are fingerprinted
+	MaxBodySize int64
+}
+
+// DefaultIdempotencyConfig returns the default configuration for a service
+func DefaultIdempotencyConfig(scope string) IdempotencyConfig {
+	return IdempotencyConfig{
+		Scope:       scope,
+		TTL:         24 * time.Hour,
+		MaxBodySize: 1 << 20,
+	}
+}
+
+// IdempotencyMiddleware makes retries of mutating requests safe. Requests
+// carrying an Idempotency-Key header are handled once; retries with the same
+// key and body get the stored response, and a key reused with a different
+// request is rejected. Requests without the header are passed through.
+type IdempotencyMiddleware struct {
+	store  IdempotencyStore
+	config IdempotencyConfig
+	now    func() time.Time
+}
+
+// NewIdempotencyMiddleware creates a new idempotency middleware
+func NewIdempotencyMiddleware(store IdempotencyStore, config IdempotencyConfig) *IdempotencyMiddleware {
+	if config.TTL <= 0 {
+		config.TTL = DefaultIdempotencyConfig(config.Scope).TTL
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultIdempotencyConfig(config.Scope).MaxBodySize
+	}
+	return &IdempotencyMiddleware{store: store, config: config, now: time.Now}
+}
+
+// Handler wraps a handler with idempotency key support
+func (m *IdempotencyMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, m.config.MaxBodySize+1))
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Failed to read request body")
+			return
+		}
+		if int64(len(body)) > m.config.MaxBodySize {
+			utils.WriteErrorResponse(w, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", "Request body is too large for an idempotent request")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		now := m.now()
+		record := &IdempotencyRecord{
+			Key:         m.storageKey(r, key),
+			Fingerprint: fingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(m.config.TTL),
+		}
+
+		existing, err := m.store.Begin(ctx, record)
+		if errors.Is(err, ErrIdempotencyKeyExists) {
+			m.replay(w, existing, record)
+			return
+		}
+		if err != nil {
+			utils.Logger.Error(ctx, "Failed to claim idempotency key", err, map[string]interface{}{
+				"idempotency_key": key,
+			})
+			utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "IDEMPOTENCY_UNAVAILABLE", "Idempotency keys are temporarily unavailable")
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				// The handler panicked; free the key so the client can retry
+				m.release(ctx, record.Key)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+		completed = true
+
+		// Server errors are not stored so a retry gets another attempt
+		if recorder.statusCode >= http.StatusInternalServerError {
+			m.release(ctx, record.Key)
+			return
+		}
+
+		record.StatusCode = recorder.statusCode
+		record.Header = storedHeader(w.Header())
+		record.Body = recorder.body.Bytes()
+		if err := m.store.Complete(ctx, record); err != nil {
+			utils.Logger.Error(ctx, "Failed to store idempotent response", err, map[string]interface{}{
+				"idempotency_key": key,
+			})
+		}
+	})
+}
+
+// HandlerFunc wraps a handler function with idempotency key support
+func (m *IdempotencyMiddleware) HandlerFunc(next http.HandlerFunc) http.Handler {
+	return m.Handler(next)
+}
+
+// replay answers a request whose key was already used
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, existing, record *IdempotencyRecord) {
+	switch {
+	case existing.Fingerprint != record.Fingerprint:
+		utils.WriteErrorResponse(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+			"Idempotency-Key was already used for a different request")
+	case !existing.Completed():
+		utils.WriteErrorResponse(w, http.StatusConflict, "IDEMPOTENCY_KEY_IN_PROGRESS",
+			"A request with this Idempotency-Key is still being processed")
+	default:
+		for name, values := range existing.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(existing.StatusCode)
+		w.Write(existing.Body)
+	}
+}
+
+func (m *IdempotencyMiddleware) release(ctx context.Context, key string) {
+	if err := m.store.Release(ctx, key); err != nil {
+		utils.Logger.Error(ctx, "Failed to release idempotency key", err, map[string]interface{}{
+			"idempotency_key": key,
+		})
+	}
+}
+
+// storageKey namespaces the client's key by service and caller so two
+// callers cannot collide on, or read, each other's keys
+func (m *IdempotencyMiddleware) storageKey(r *http.Request, key string) string {
+	caller := utils.GetUserID(r.Context())
+	if caller == "" {
+		caller = r.Header.Get(UserIDHeader)
+	}
+	return m.config.Scope + ":" + caller + ":" + key
+}
+
+// fingerprint identifies a request by method, path and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// storedHeader keeps the response headers worth replaying
+func storedHeader(header http.Header) http.Header {
+	stored := http.Header{}
+	for _, name := range []string{"Content-Type", "Location"} {
+		if values, ok := header[name]; ok {
+			stored[name] = values
+		}
+	}
+	return stored
+}
+
+// responseRecorder passes the response through while keeping a copy
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// DeleteExpiredIdempotencyKeys removes expired keys from the store once per
+// interval until the context is cancelled
+func DeleteExpiredIdempotencyKeys(ctx context.Context, store IdempotencyStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := store.DeleteExpired(ctx); err != nil {
+			utils.Logger.Error(ctx, "Failed to delete expired idempotency keys", err)
+		} else if deleted > 0 {
+			utils.Logger.Info(ctx, "Deleted expired idempotency keys", map[string]interface{}{"count": deleted})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// InMemoryIdempotencyStore is an IdempotencyStore for tests and single
+// instance development setups
+type InMemoryIdempotencyStore struct {
+	mutex   sync.Mutex
+	records map[string]*IdempotencyRecord
+	now     func() time.Time
+}
+
+// NewInMemoryIdempotencyStore creates a new in-memory idempotency store
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{records: make(map[string]*IdempotencyRecord), now: time.Now}
+}
+
+// Begin claims the key unless an unexpired record holds it
+func (s *InMemoryIdempotencyStore) Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if existing, ok := s.records[record.Key]; ok && existing.ExpiresAt.After(s.now()) {
+		stored := *existing
+		return &stored, ErrIdempotencyKeyExists
+	}
+	stored := *record
+	s.records[record.Key] = &stored
+	return nil, nil
+}
+
+// Complete stores the response for a claimed key
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := *record
+	s.records[record.Key] = &stored
+	return nil
+}
+
+// Release drops a claimed key
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// DeleteExpired removes keys past their expiry
+func (s *InMemoryIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deleted int64
+	for key, record := range s.records {
+		if !record.ExpiresAt.After(s.now()) {
+			delete(s.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
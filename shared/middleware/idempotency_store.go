@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PostgresIdempotencyStore keeps idempotency keys in the idempotency_keys table
+type PostgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+// NewPostgresIdempotencyStore creates a new PostgreSQL idempotency store
+func NewPostgresIdempotencyStore(db *sql.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{db: db}
+}
+
+// Begin claims the key, taking over an expired record if there is one. The
+// insert is atomic so concurrent requests with the same key cannot both win.
+func (s *PostgresIdempotencyStore) Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			response_headers = NULL,
+			response_body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $3
+		RETURNING key`
+
+	var key string
+	err := s.db.QueryRowContext(ctx, query, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	existing, err := s.get(ctx, record.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the insert and the read; report it as in progress
+		// so the client retries
+		return &IdempotencyRecord{Key: record.Key, Fingerprint: record.Fingerprint}, ErrIdempotencyKeyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return existing, ErrIdempotencyKeyExists
+}
+
+// Complete stores the response for a claimed key
+func (s *PostgresIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response headers: %w", err)
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $2, response_headers = $3, response_body = $4
+		WHERE key = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, record.Key, record.StatusCode, header, record.Body); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release drops a claimed key that has no stored response
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`
+
+	if _, err := s.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes keys past their expiry
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *PostgresIdempotencyStore) get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	query := `
+		SELECT key, fingerprint, status_code, response_headers, response_body, created_at, expires_at
+		FROM idempotency_keys WHERE key = $1`
+
+	var record IdempotencyRecord
+	var statusCode sql.NullInt64
+	var header []byte
+	err := s.db.QueryRowContext(ctx, query, key).Scan(
+		&record.Key, &record.Fingerprint, &statusCode, &header, &record.Body,
+		&record.CreatedAt, &record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	record.StatusCode = int(statusCode.Int64)
+	if len(header) > 0 {
+		record.Header = http.Header{}
+		if err := json.Unmarshal(header, &record.Header); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response headers: %w", err)
+		}
+	}
+	return &record, nil
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newIdempotentServer(store IdempotencyStore, status int) (http.Handler, *int32) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"call": n, "body": string(body)})
+	})
+	return NewIdempotencyMiddleware(store, DefaultIdempotencyConfig("test")).Handler(handler), &calls
+}
+
+func sendIdempotent(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_ReplaysIdenticalRetry(t *testing.T) {
+	handler, calls := newIdempotentServer(NewInMemoryIdempotencyStore(), http.StatusCreated)
+
+	first := sendIdempotent(handler, "key-1", `{"amount": "10.00"}`)
+	retry := sendIdempotent(handler, "key-1", `{"amount": "10.00"}`)
+
+	if *calls != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", *calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed %d %q, got %d %q", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected replay headers, got %v", retry.Header())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("Expected first response not to be marked as replayed")
+	}
+
+	// Requests without a key are never deduplicated
+	sendIdempotent(handler, "", `{"amount": "10.00"}`)
+	sendIdempotent(handler, "", `{"amount": "10.00"}`)
+	if *calls != 3 {
+		t.Errorf("Expected requests without a key to run, ran %d times", *calls)
+	}
+}
+
+func TestIdempotency_RejectsReusedKey(t *testing.T) {
+	handler, calls := newIdempotentServer(NewInMemoryIdempotencyStore(), http.StatusCreated)
+
+	sendIdempotent(handler, "key-1", `{"amount": "10.00"}`)
+	rec := sendIdempotent(handler, "key-1", `{"amount": "99.00"}`)
+
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "IDEMPOTENCY_KEY_REUSED") {
+		t.Errorf("Expected 422 IDEMPOTENCY_KEY_REUSED, got %d %s", rec.Code, rec.Body.String())
+	}
+	if *calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", *calls)
+	}
+}
+
+func TestIdempotency_InProgressAndServerErrors(t *testing.T) {
+	store := NewInMemoryIdempotencyStore()
+
+	// A key claimed by a request still running is a conflict
+	store.Begin(context.Background(), &IdempotencyRecord{
+		Key:         "test::busy",
+		Fingerprint: fingerprint(httptest.NewRequest(http.MethodPost, "/payments", nil), []byte(`{}`)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	handler, _ := newIdempotentServer(store, http.StatusCreated)
+	if rec := sendIdempotent(handler, "busy", `{}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a request in progress, got %d", rec.Code)
+	}
+
+	// Server errors are not stored, so the retry runs again
+	handler, calls := newIdempotentServer(store, http.StatusBadGateway)
+	sendIdempotent(handler, "flaky", `{}`)
+	sendIdempotent(handler, "flaky", `{}`)
+	if *calls != 2 {
+		t.Errorf("Expected failed request to be retried, ran %d times", *calls)
+	}
+}
+
+func TestIdempotency_KeysExpire(t *testing.T) {
+	store := NewInMemoryIdempotencyStore()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	store.now = clock
+
+	var calls int32
+	middleware := NewIdempotencyMiddleware(store, DefaultIdempotencyConfig("test"))
+	middleware.now = clock
+	handler := middleware.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	sendIdempotent(handler, "key-1", `{}`)
+	now = now.Add(25 * time.Hour)
+	sendIdempotent(handler, "key-1", `{"different": true}`)
+
+	if calls != 2 {
+		t.Errorf("Expected expired key to be reusable, ran %d times", calls)
+	}
+	if deleted, _ := store.DeleteExpired(context.Background()); deleted != 0 {
+		t.Errorf("Expected the reclaimed key to be kept, deleted %d", deleted)
+	}
+}
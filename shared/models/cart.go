@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopspring/decimal"
 )
 
@@ -24,7 +25,12 @@ type Cart struct {
 	Status    CartStatus      `json:"status" db:"status"`
 	Items     []CartItem      `json:"items"`
 	Subtotal  decimal.Decimal `json:"subtotal" db:"subtotal"`
-	Currency  string          `json:"currency" db:"currency"`
+	// CouponCodes are applied when the cart is priced and at checkout
+	CouponCodes []string `json:"coupon_codes"`
+	// Discount and Discounts are the promotions the cart currently qualifies for
+	Discount  decimal.Decimal `json:"discount"`
+	Discounts []OrderDiscount `json:"discounts"`
+	Currency  string          `json:"currency" db:"currency"` // display currency chosen for the cart; item prices are in it
 	ExpiresAt time.Time       `json:"expires_at" db:"expires_at"`
 	CreatedAt time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
@@ -35,6 +41,7 @@ type CartItem struct {
 	ID        string          `json:"id" db:"id"`
 	CartID    string          `json:"cart_id" db:"cart_id"`
 	ProductID string          `json:"product_id" db:"product_id"`
+	VariantID string          `json:"variant_id,omitempty" db:"variant_id"`
 	SKU       string          `json:"sku" db:"sku"`
 	Name      string          `json:"name" db:"name"`
 	Price     decimal.Decimal `json:"price" db:"price"`
@@ -52,19 +59,21 @@ func NewCart(userID, sessionID string) *Cart {
 		SessionID: sessionID,
 		Status:    CartActive,
 		Items:     []CartItem{},
-		Currency:  "USD",
+		Currency:  currency.DefaultCode,
 		ExpiresAt: time.Now().Add(24 * time.Hour), // 24 hours expiry
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
 
-// AddItem adds an item to the cart
-func (c *Cart) AddItem(productID, sku, name string, price decimal.Decimal, quantity int) {
+// AddItem adds an item to the cart. variantID is empty for products
+// without variants.
+func (c *Cart) AddItem(productID, variantID, sku, name string, price decimal.Decimal, quantity int) {
 	item := CartItem{
 		ID:        uuid.New().String(),
 		CartID:    c.ID,
 		ProductID: productID,
+		VariantID: variantID,
 		SKU:       sku,
 		Name:      name,
 		Price:     price,
@@ -93,9 +102,9 @@ func (c *Cart) calculateSubtotal() {
 }
 
 // UpdateItem updates an existing item in the cart
-func (c *Cart) UpdateItem(productID string, quantity int) bool {
+func (c *Cart) UpdateItem(productID, variantID string, quantity int) bool {
 	for i, item := range c.Items {
-		if item.ProductID == productID {
+		if item.ProductID == productID && item.VariantID == variantID {
 			if quantity <= 0 {
 				// Remove item if quantity is 0 or negative
 				c.Items = append(c.Items[:i], c.Items[i+1:]...)
@@ -113,9 +122,9 @@ func (c *Cart) UpdateItem(productID string, quantity int) bool {
 }
 
 // RemoveItem removes an item from the cart
-func (c *Cart) RemoveItem(productID string) bool {
+func (c *Cart) RemoveItem(productID, variantID string) bool {
 	for i, item := range c.Items {
-		if item.ProductID == productID {
+		if item.ProductID == productID && item.VariantID == variantID {
 			c.Items = append(c.Items[:i], c.Items[i+1:]...)
 			c.UpdatedAt = time.Now()
 			c.CalculateSubtotal()
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckoutStatus represents the status of a checkout saga
+type CheckoutStatus string
+
+const (
+	CheckoutStarted      CheckoutStatus = "started"
+	CheckoutCompleted    CheckoutStatus = "completed"
+	CheckoutCompensating CheckoutStatus = "compensating"
+	CheckoutCompensated  CheckoutStatus = "compensated"
+	// CheckoutAwaitingReview means the payment is held for risk review; the
+	// checkout resumes once an admin approves or rejects it
+	CheckoutAwaitingReview CheckoutStatus = "awaiting_review"
+	// CheckoutFailed means compensation itself failed and needs manual attention
+	CheckoutFailed CheckoutStatus = "failed"
+)
+
+// CheckoutStep is one forward step of the checkout saga
+type CheckoutStep string
+
+const (
+	CheckoutStepValidateCart   CheckoutStep = "validate_cart"
+	CheckoutStepCreateOrder    CheckoutStep = "create_order"
+	CheckoutStepReserveStock   CheckoutStep = "reserve_stock"
+	CheckoutStepCreatePayment  CheckoutStep = "create_payment"
+	CheckoutStepProcessPayment CheckoutStep = "process_payment"
+	CheckoutStepCreateShipment CheckoutStep = "create_shipment"
+	CheckoutStepClearCart      CheckoutStep = "clear_cart"
+)
+
+// CheckoutSaga is the persisted state of one checkout attempt
+type CheckoutSaga struct {
+	ID             string         `json:"id" db:"id"`
+	UserID         string         `json:"user_id" db:"user_id"`
+	SessionID      string         `json:"session_id" db:"session_id"`
+	CartID         string         `json:"cart_id" db:"cart_id"`
+	Status         CheckoutStatus `json:"status" db:"status"`
+	CurrentStep    CheckoutStep   `json:"current_step" db:"current_step"`
+	CompletedSteps []CheckoutStep `json:"completed_steps" db:"completed_steps"`
+	OrderID        string         `json:"order_id,omitempty" db:"order_id"`
+	PaymentID      string         `json:"payment_id,omitempty" db:"payment_id"`
+	// StoredValuePaymentID is the gift card or wallet payment covering part
+	// or all of the order; PaymentID, if any, is the card paying the rest
+	StoredValuePaymentID string     `json:"stored_value_payment_id,omitempty" db:"stored_value_payment_id"`
+	ShipmentID           string     `json:"shipment_id,omitempty" db:"shipment_id"`
+	Error                string     `json:"error,omitempty" db:"error"`
+	CompensationErrors   []string   `json:"compensation_errors,omitempty" db:"compensation_errors"`
+	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// NewCheckoutSaga creates a new checkout saga in the started state
+func NewCheckoutSaga(userID, sessionID string) *CheckoutSaga {
+	return &CheckoutSaga{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		SessionID:      sessionID,
+		Status:         CheckoutStarted,
+		CompletedSteps: []CheckoutStep{},
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// HasCompleted reports whether the given step finished successfully
+func (s *CheckoutSaga) HasCompleted(step CheckoutStep) bool {
+	for _, completed := range s.CompletedSteps {
+		if completed == step {
+			return true
+		}
+	}
+	return false
+}
@@ -35,6 +35,9 @@ const (
 	EventPaymentFailed    EventType = "payment.failed"
 	EventPaymentRefunded  EventType = "payment.refunded"
 
+	// Shipment events
+	EventShipmentShipped EventType = "shipment.shipped"
+
 	// Cart events
 	EventCartItemAdded   EventType = "cart.item.added"
 	EventCartItemRemoved EventType = "cart.item.removed"
@@ -120,13 +123,28 @@ type ProductCreatedData struct {
 	Stock       int             `json:"stock"`
 }
 
-// InventoryUpdatedData represents data for inventory updated event
+// InventoryUpdatedData represents data for inventory updated event. Stock
+// counts every unit on hand; available stock leaves out reserved units.
 type InventoryUpdatedData struct {
-	ProductID    string `json:"product_id"`
-	SKU          string `json:"sku"`
-	PreviousStock int   `json:"previous_stock"`
-	NewStock     int    `json:"new_stock"`
-	Reason       string `json:"reason"` // sale, restock, adjustment
+	ProductID         string `json:"product_id"`
+	SKU               string `json:"sku"`
+	PreviousStock     int    `json:"previous_stock"`
+	NewStock          int    `json:"new_stock"`
+	PreviousAvailable int    `json:"previous_available"`
+	NewAvailable      int    `json:"new_available"`
+	Reason            string `json:"reason"` // sale, restock, adjustment
+	// Variant is set when the stock of one of the product's variants moved
+	Variant *VariantStockChange `json:"variant,omitempty"`
+}
+
+// VariantStockChange is the change to a variant's stock in an inventory update
+type VariantStockChange struct {
+	VariantID         string `json:"variant_id"`
+	SKU               string `json:"sku"`
+	PreviousStock     int    `json:"previous_stock"`
+	NewStock          int    `json:"new_stock"`
+	PreviousAvailable int    `json:"previous_available"`
+	NewAvailable      int    `json:"new_available"`
 }
 
 // Order Events Data Structures
@@ -175,6 +193,33 @@ type PaymentFailedData struct {
 	ErrorCode     string          `json:"error_code"`
 }
 
+// PaymentRefundedData represents data for payment refunded event
+type PaymentRefundedData struct {
+	PaymentID      string          `json:"payment_id"`
+	RefundID       string          `json:"refund_id"`
+	OrderID        string          `json:"order_id"`
+	UserID         string          `json:"user_id"`
+	Amount         decimal.Decimal `json:"amount"`
+	RefundedAmount decimal.Decimal `json:"refunded_amount"`
+	Currency       string          `json:"currency"`
+	Reason         string          `json:"reason"`
+	FullyRefunded  bool            `json:"fully_refunded"`
+}
+
+// Shipment Events Data Structures
+
+// ShipmentShippedData represents data for shipment shipped event. Final is set
+// on the last of an order's shipments to leave the warehouse.
+type ShipmentShippedData struct {
+	ShipmentID     string          `json:"shipment_id"`
+	OrderID        string          `json:"order_id"`
+	UserID         string          `json:"user_id"`
+	TrackingNumber string          `json:"tracking_number"`
+	DeclaredValue  decimal.Decimal `json:"declared_value"`
+	Final          bool            `json:"final"`
+	ShippedAt      time.Time       `json:"shipped_at"`
+}
+
 // Cart Events Data Structures
 
 // CartItemAddedData represents data for cart item added event
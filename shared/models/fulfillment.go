@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FulfillmentStatus represents the status of a fulfillment
+type FulfillmentStatus string
+
+const (
+	FulfillmentPending    FulfillmentStatus = "pending"
+	FulfillmentProcessing FulfillmentStatus = "processing"
+	FulfillmentShipped    FulfillmentStatus = "shipped"
+	FulfillmentDelivered  FulfillmentStatus = "delivered"
+	FulfillmentCancelled  FulfillmentStatus = "cancelled"
+)
+
+// Fulfillment is a shipment of some or all of an order's items. An order
+// can be split across several fulfillments shipped separately.
+type Fulfillment struct {
+	ID             string            `json:"id" db:"id"`
+	OrderID        string            `json:"order_id" db:"order_id"`
+	Status         FulfillmentStatus `json:"status" db:"fulfillment_status"`
+	Items          []FulfillmentItem `json:"items"`
+	Carrier        string            `json:"carrier" db:"carrier"`
+	TrackingNumber string            `json:"tracking_number" db:"tracking_number"`
+	TrackingURL    string            `json:"tracking_url" db:"tracking_url"`
+	ShippedAt      *time.Time        `json:"shipped_at" db:"shipped_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at" db:"delivered_at"`
+	Notes          string            `json:"notes" db:"notes"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// FulfillmentItem is a quantity of one order item in a fulfillment
+type FulfillmentItem struct {
+	ID            string    `json:"id" db:"id"`
+	FulfillmentID string    `json:"fulfillment_id" db:"fulfillment_id"`
+	OrderItemID   string    `json:"order_item_id" db:"order_item_id"`
+	Quantity      int       `json:"quantity" db:"quantity"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// IsActive reports whether the fulfillment still accounts for its items
+func (f *Fulfillment) IsActive() bool {
+	return f.Status != FulfillmentCancelled
+}
+
+// HasShipped reports whether the fulfillment's items have left the warehouse
+func (f *Fulfillment) HasShipped() bool {
+	return f.Status == FulfillmentShipped || f.Status == FulfillmentDelivered
+}
+
+// NewFulfillment creates a new pending fulfillment for an order
+func NewFulfillment(orderID string) *Fulfillment {
+	return &Fulfillment{
+		ID:        uuid.New().String(),
+		OrderID:   orderID,
+		Status:    FulfillmentPending,
+		Items:     []FulfillmentItem{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReservationStatus represents the status of a stock reservation
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+	ReservationCommitted ReservationStatus = "committed"
+)
+
+// Reference types a stock reservation can be held for
+const (
+	ReservationReferenceOrder = "order"
+	ReservationReferenceCart  = "cart"
+)
+
+// StockReservation holds units of a product, or of one of its variants, for
+// an order or cart until it expires. There is one reservation per reference
+// and product or variant; reserving again changes the quantity held.
+type StockReservation struct {
+	ID                string            `json:"id" db:"id"`
+	ReferenceType     string            `json:"reference_type" db:"reference_type"`
+	ReferenceID       string            `json:"reference_id" db:"reference_id"`
+	ProductID         string            `json:"product_id" db:"product_id"`
+	VariantID         string            `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity          int               `json:"quantity" db:"quantity"` // units held, or held when it ended
+	CommittedQuantity int               `json:"committed_quantity" db:"committed_quantity"`
+	Status            ReservationStatus `json:"status" db:"status"`
+	ExpiresAt         time.Time         `json:"expires_at" db:"expires_at"`
+	ReleasedAt        *time.Time        `json:"released_at,omitempty" db:"released_at"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// NewStockReservation creates a new active reservation
+func NewStockReservation(referenceType, referenceID, productID, variantID string, quantity int, expiresAt time.Time) *StockReservation {
+	return &StockReservation{
+		ID:            uuid.New().String(),
+		ReferenceType: referenceType,
+		ReferenceID:   referenceID,
+		ProductID:     productID,
+		VariantID:     variantID,
+		Quantity:      quantity,
+		Status:        ReservationActive,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+}
+
+// IsActive reports whether the reservation still holds its units
+func (r *StockReservation) IsActive() bool {
+	return r.Status == ReservationActive
+}
+
+// HasExpired reports whether an active reservation has outlived its TTL
+func (r *StockReservation) HasExpired(now time.Time) bool {
+	return r.IsActive() && !now.Before(r.ExpiresAt)
+}
+
+// InventoryMovement is one entry of the inventory ledger. In and out
+// movements change stock and reserved and released movements change reserved
+// stock. Stock adjustments are recorded as the in or out movement that takes
+// stock to the counted quantity.
+type InventoryMovement struct {
+	ID            string    `json:"id" db:"id"`
+	ProductID     string    `json:"product_id" db:"product_id"`
+	VariantID     string    `json:"variant_id,omitempty" db:"variant_id"`
+	MovementType  string    `json:"movement_type" db:"movement_type"`
+	Quantity      int       `json:"quantity" db:"quantity"`
+	ReferenceType string    `json:"reference_type,omitempty" db:"reference_type"`
+	ReferenceID   string    `json:"reference_id,omitempty" db:"reference_id"`
+	Reason        string    `json:"reason" db:"reason"`
+	CreatedBy     string    `json:"created_by" db:"created_by"` // user:<id> or service:<name>
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// StockDrift compares the stock stored on a product, or on one of its
+// variants, with the stock recomputed from the inventory ledger
+type StockDrift struct {
+	ProductID           string `json:"product_id"`
+	VariantID           string `json:"variant_id,omitempty"`
+	SKU                 string `json:"sku"`
+	Stock               int    `json:"stock"`
+	LedgerStock         int    `json:"ledger_stock"`
+	ReservedStock       int    `json:"reserved_stock"`
+	LedgerReservedStock int    `json:"ledger_reserved_stock"`
+}
+
+// HasDrift reports whether the stored stock differs from the ledger
+func (d *StockDrift) HasDrift() bool {
+	return d.Stock != d.LedgerStock || d.ReservedStock != d.LedgerReservedStock
+}
+
+// StockThreshold is the reorder point of a product, or of one of its
+// variants. Stock falling to the threshold or below raises a low-stock alert.
+type StockThreshold struct {
+	ID        string    `json:"id" db:"id"`
+	ProductID string    `json:"product_id" db:"product_id"`
+	VariantID string    `json:"variant_id,omitempty" db:"variant_id"`
+	Threshold int       `json:"threshold" db:"threshold"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NewStockThreshold creates a new reorder threshold
+func NewStockThreshold(productID, variantID string, threshold int) *StockThreshold {
+	return &StockThreshold{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		VariantID: variantID,
+		Threshold: threshold,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// IsCrossed reports whether stock moving from previous to current fell to
+// the threshold or below
+func (t *StockThreshold) IsCrossed(previous, current int) bool {
+	return previous > t.Threshold && current <= t.Threshold
+}
+
+// BackInStockSubscription asks for a customer to be notified once when a
+// product, or one of its variants, is available again. NotifiedAt is set
+// when the notification is sent; the subscription is not used again.
+type BackInStockSubscription struct {
+	ID         string              `json:"id" db:"id"`
+	ProductID  string              `json:"product_id" db:"product_id"`
+	VariantID  string              `json:"variant_id,omitempty" db:"variant_id"`
+	UserID     string              `json:"user_id" db:"user_id"`
+	Channel    NotificationChannel `json:"channel" db:"channel"`
+	Recipient  string              `json:"recipient" db:"recipient"`
+	NotifiedAt *time.Time          `json:"notified_at,omitempty" db:"notified_at"`
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`
+}
+
+// NewBackInStockSubscription creates a new pending subscription
+func NewBackInStockSubscription(productID, variantID, userID string, channel NotificationChannel, recipient string) *BackInStockSubscription {
+	return &BackInStockSubscription{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		VariantID: variantID,
+		UserID:    userID,
+		Channel:   channel,
+		Recipient: recipient,
+		CreatedAt: time.Now(),
+	}
+}
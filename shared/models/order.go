@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopspring/decimal"
 )
 
@@ -11,13 +12,15 @@ import (
 type OrderStatus string
 
 const (
-	OrderPending    OrderStatus = "pending"
-	OrderConfirmed  OrderStatus = "confirmed"
-	OrderProcessing OrderStatus = "processing"
-	OrderShipped    OrderStatus = "shipped"
-	OrderDelivered  OrderStatus = "delivered"
-	OrderCancelled  OrderStatus = "cancelled"
-	OrderRefunded   OrderStatus = "refunded"
+	OrderPending          OrderStatus = "pending"
+	OrderConfirmed        OrderStatus = "confirmed"
+	OrderProcessing       OrderStatus = "processing"
+	// OrderPartiallyShipped means some, but not all, items have shipped
+	OrderPartiallyShipped OrderStatus = "partially_shipped"
+	OrderShipped          OrderStatus = "shipped"
+	OrderDelivered        OrderStatus = "delivered"
+	OrderCancelled        OrderStatus = "cancelled"
+	OrderRefunded         OrderStatus = "refunded"
 )
 
 // Order represents an order in the system
@@ -29,12 +32,18 @@ type Order struct {
 	Items                 []OrderItem     `json:"items"`
 	Subtotal              decimal.Decimal `json:"subtotal" db:"subtotal"`
 	Tax                   decimal.Decimal `json:"tax" db:"tax"`
+	TaxIncluded           decimal.Decimal `json:"tax_included" db:"tax_included"` // part of Tax already contained in item prices and shipping
 	Shipping              decimal.Decimal `json:"shipping" db:"shipping"`
 	Discount              decimal.Decimal `json:"discount" db:"discount"`
+	Discounts             []OrderDiscount `json:"discounts"`
+	Fulfillments          []Fulfillment   `json:"fulfillments"`
 	Total                 decimal.Decimal `json:"total" db:"total"`
 	Currency              string          `json:"currency" db:"currency"`
+	ExchangeRates         []ExchangeRate  `json:"exchange_rates" db:"exchange_rates"` // rates used to price items and fees in Currency
 	ShippingAddress       Address         `json:"shipping_address"`
 	BillingAddress        Address         `json:"billing_address"`
+	OriginWarehouseID     string          `json:"origin_warehouse_id,omitempty" db:"origin_warehouse_id"` // warehouse allocated to ship the order
+	OriginAddress         *Address        `json:"origin_address,omitempty" db:"origin_address"`
 	PaymentMethod         PaymentMethod   `json:"payment_method"`
 	PaymentStatus         string          `json:"payment_status" db:"payment_status"`
 	PaymentReference      string          `json:"payment_reference" db:"payment_reference"`
@@ -53,6 +62,22 @@ type Order struct {
 	UpdatedAt             time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// ExchangeRate is a conversion snapshotted on an order when it was priced
+// from amounts in another currency
+type ExchangeRate = currency.Conversion
+
+// PriceSource records where an order item's unit price was taken from
+type PriceSource string
+
+const (
+	PriceSourceProduct PriceSource = "product"
+	PriceSourceVariant PriceSource = "variant"
+	// PriceSourceClient marks items priced from the request, before catalog pricing was enforced
+	PriceSourceClient PriceSource = "client"
+	// PriceSourceSubscription marks items of a renewal order, priced by their subscription plan
+	PriceSourceSubscription PriceSource = "subscription"
+)
+
 // OrderItem represents an item in an order
 type OrderItem struct {
 	ID                 string                 `json:"id" db:"id"`
@@ -63,8 +88,12 @@ type OrderItem struct {
 	Name               string                 `json:"name" db:"name"`
 	Description        string                 `json:"description" db:"description"`
 	Price              decimal.Decimal        `json:"price" db:"price"`
+	PriceSource        PriceSource            `json:"price_source" db:"price_source"`
 	Quantity           int                    `json:"quantity" db:"quantity"`
 	Total              decimal.Decimal        `json:"total" db:"total"`
+	TaxClass           string                 `json:"tax_class" db:"tax_class"`
+	Tax                decimal.Decimal        `json:"tax" db:"tax_amount"`
+	TaxBreakdown       []TaxLine              `json:"tax_breakdown" db:"tax_breakdown"`
 	ProductAttributes  map[string]interface{} `json:"product_attributes" db:"product_attributes"`
 	ImageURL           string                 `json:"image_url" db:"image_url"`
 	CreatedAt          time.Time              `json:"created_at" db:"created_at"`
@@ -98,7 +127,7 @@ func NewOrder(userID string) *Order {
 		UserID:   userID,
 		Status:   OrderPending,
 		Items:    []OrderItem{},
-		Currency: "USD",
+		Currency: currency.DefaultCode,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
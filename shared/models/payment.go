@@ -17,6 +17,21 @@ const (
 	PaymentFailed    PaymentStatus = "failed"
 	PaymentCancelled PaymentStatus = "cancelled"
 	PaymentRefunded  PaymentStatus = "refunded"
+	// PaymentAuthorized holds funds on the card without capturing them
+	PaymentAuthorized        PaymentStatus = "authorized"
+	PaymentPartiallyCaptured PaymentStatus = "partially_captured"
+	// PaymentVoided is an authorization released without any capture
+	PaymentVoided PaymentStatus = "voided"
+	// PaymentPartiallyRefunded has had part of its captured amount refunded
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	// PaymentRequiresAction waits on the customer, usually for 3-D Secure
+	PaymentRequiresAction PaymentStatus = "requires_action"
+	// PaymentDisputed has a chargeback open against it
+	PaymentDisputed PaymentStatus = "disputed"
+	// PaymentUnderReview was held by the risk engine until an admin reviews it
+	PaymentUnderReview PaymentStatus = "under_review"
+	// PaymentBlocked was stopped by the risk engine or rejected in review
+	PaymentBlocked PaymentStatus = "blocked"
 )
 
 // PaymentType represents the type of payment
@@ -28,8 +43,18 @@ const (
 	PaymentTypeApplePay PaymentType = "apple_pay"
 	PaymentTypeGooglePay PaymentType = "google_pay"
 	PaymentTypeBankTransfer PaymentType = "bank_transfer"
+	// PaymentTypeGiftCard and PaymentTypeWallet are paid from stored value
+	// held by the shop rather than through the payment gateway
+	PaymentTypeGiftCard PaymentType = "gift_card"
+	PaymentTypeWallet   PaymentType = "wallet"
 )
 
+// IsStoredValue reports whether payments of the type are paid from a gift
+// card or wallet balance
+func (t PaymentType) IsStoredValue() bool {
+	return t == PaymentTypeGiftCard || t == PaymentTypeWallet
+}
+
 // Payment represents a payment transaction
 type Payment struct {
 	ID              string          `json:"id" db:"id"`
@@ -43,9 +68,153 @@ type Payment struct {
 	TransactionID   string          `json:"transaction_id" db:"transaction_id"`
 	GatewayResponse GatewayResponse `json:"gateway_response" db:"gateway_response"`
 	FailureReason   string          `json:"failure_reason" db:"failure_reason"`
-	ProcessedAt     *time.Time      `json:"processed_at" db:"processed_at"`
-	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time       `json:"updated_at" db:"updated_at"`
+	// AuthorizedAmount is the amount held on the card; CapturedAmount is how
+	// much of it has been collected so far and RefundedAmount how much of that
+	// has been paid back by completed refunds
+	AuthorizedAmount       decimal.Decimal `json:"authorized_amount" db:"authorized_amount"`
+	CapturedAmount         decimal.Decimal `json:"captured_amount" db:"captured_amount"`
+	RefundedAmount         decimal.Decimal `json:"refunded_amount" db:"refunded_amount"`
+	AuthorizationExpiresAt *time.Time      `json:"authorization_expires_at,omitempty" db:"authorization_expires_at"`
+	ProcessedAt            *time.Time      `json:"processed_at" db:"processed_at"`
+	CreatedAt              time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at" db:"updated_at"`
+	// GatewayUpdatedAt is when the gateway event last applied to the payment
+	// happened; older events arriving late are ignored
+	GatewayUpdatedAt *time.Time `json:"gateway_updated_at,omitempty" db:"gateway_updated_at"`
+	// RiskContext is scored by the risk engine before the payment is processed
+	RiskContext PaymentRiskContext `json:"risk_context" db:"risk_context"`
+}
+
+// IsCapturable reports whether part of the authorization is still uncaptured
+func (p *Payment) IsCapturable() bool {
+	return p.Status == PaymentAuthorized || p.Status == PaymentPartiallyCaptured
+}
+
+// UncapturedAmount returns the part of the authorization not captured yet
+func (p *Payment) UncapturedAmount() decimal.Decimal {
+	if !p.IsCapturable() {
+		return decimal.Zero
+	}
+	return p.AuthorizedAmount.Sub(p.CapturedAmount)
+}
+
+// IsRefundable reports whether refunds can be made against the payment
+func (p *Payment) IsRefundable() bool {
+	switch p.Status {
+	case PaymentCompleted, PaymentPartiallyCaptured, PaymentPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// RefundableAmount returns the captured amount not refunded yet. Refunds still
+// in flight are not accounted for.
+func (p *Payment) RefundableAmount() decimal.Decimal {
+	if !p.IsRefundable() {
+		return decimal.Zero
+	}
+	return p.CapturedAmount.Sub(p.RefundedAmount)
+}
+
+// SettledStatus returns the status of a payment whose capture is finished,
+// taking the refunds already made against it into account
+func (p *Payment) SettledStatus() PaymentStatus {
+	switch {
+	case !p.RefundedAmount.IsPositive():
+		return PaymentCompleted
+	case p.RefundedAmount.GreaterThanOrEqual(p.CapturedAmount):
+		return PaymentRefunded
+	default:
+		return PaymentPartiallyRefunded
+	}
+}
+
+// ApplyGatewayStatus moves the payment to a status reported by a gateway
+// webhook. It returns false, leaving the payment untouched, when the report
+// repeats the current status or would undo a later one, so redelivered and
+// out-of-order events are harmless. A completed report on a disputed payment
+// means the dispute was won and a refunded one that it was lost.
+func (p *Payment) ApplyGatewayStatus(status PaymentStatus) bool {
+	awaitingGateway := p.Status == PaymentPending || p.Status == PaymentProcessing ||
+		p.Status == PaymentRequiresAction || p.Status == PaymentFailed
+
+	switch status {
+	case PaymentRequiresAction, PaymentFailed:
+		if !awaitingGateway {
+			return false
+		}
+	case PaymentAuthorized:
+		if !awaitingGateway {
+			return false
+		}
+		p.AuthorizedAmount = p.Amount
+		p.CapturedAmount = decimal.Zero
+	case PaymentCompleted:
+		switch {
+		case awaitingGateway:
+			p.AuthorizedAmount = p.Amount
+			p.CapturedAmount = p.Amount
+		case p.Status == PaymentDisputed:
+			status = p.SettledStatus()
+		default:
+			return false
+		}
+	case PaymentDisputed:
+		if p.Status != PaymentCompleted && p.Status != PaymentPartiallyRefunded && p.Status != PaymentRefunded {
+			return false
+		}
+	case PaymentRefunded:
+		if p.Status != PaymentDisputed {
+			return false
+		}
+		p.RefundedAmount = p.CapturedAmount
+	default:
+		return false
+	}
+
+	if status == p.Status {
+		return false
+	}
+	p.Status = status
+	return true
+}
+
+// CaptureStatus represents the status of a capture against an authorization
+type CaptureStatus string
+
+const (
+	CapturePending   CaptureStatus = "pending"
+	CaptureSucceeded CaptureStatus = "succeeded"
+	CaptureFailed    CaptureStatus = "failed"
+)
+
+// PaymentCapture is one capture of an authorized payment, usually for a
+// shipment. The final capture releases whatever is left of the authorization.
+type PaymentCapture struct {
+	ID            string          `json:"id" db:"id"`
+	PaymentID     string          `json:"payment_id" db:"payment_id"`
+	ShipmentID    string          `json:"shipment_id,omitempty" db:"shipment_id"`
+	Amount        decimal.Decimal `json:"amount" db:"amount"`
+	Final         bool            `json:"final" db:"final"`
+	Status        CaptureStatus   `json:"status" db:"status"`
+	TransactionID string          `json:"transaction_id" db:"transaction_id"`
+	FailureReason string          `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// NewPaymentCapture creates a new pending capture
+func NewPaymentCapture(paymentID, shipmentID string, amount decimal.Decimal, final bool) *PaymentCapture {
+	return &PaymentCapture{
+		ID:         uuid.New().String(),
+		PaymentID:  paymentID,
+		ShipmentID: shipmentID,
+		Amount:     amount,
+		Final:      final,
+		Status:     CapturePending,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
 }
 
 // GatewayResponse represents the response from payment gateway
@@ -62,15 +231,18 @@ type GatewayResponse struct {
 // NewPayment creates a new payment with default values
 func NewPayment(orderID, userID string, amount decimal.Decimal, currency string, paymentType PaymentType) *Payment {
 	return &Payment{
-		ID:        uuid.New().String(),
-		OrderID:   orderID,
-		UserID:    userID,
-		Amount:    amount,
-		Currency:  currency,
-		Status:    PaymentPending,
-		Type:      paymentType,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:               uuid.New().String(),
+		OrderID:          orderID,
+		UserID:           userID,
+		Amount:           amount,
+		Currency:         currency,
+		Status:           PaymentPending,
+		Type:             paymentType,
+		AuthorizedAmount: decimal.Zero,
+		CapturedAmount:   decimal.Zero,
+		RefundedAmount:   decimal.Zero,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 }
 
@@ -123,11 +295,17 @@ type Refund struct {
 	Status          PaymentStatus   `json:"status" db:"status"`
 	TransactionID   string          `json:"transaction_id" db:"transaction_id"`
 	GatewayResponse GatewayResponse `json:"gateway_response" db:"gateway_response"`
+	FailureReason   string          `json:"failure_reason,omitempty" db:"failure_reason"`
 	ProcessedAt     *time.Time      `json:"processed_at" db:"processed_at"`
 	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// IsFinal reports whether the refund has reached a terminal status
+func (r *Refund) IsFinal() bool {
+	return r.Status == PaymentCompleted || r.Status == PaymentFailed || r.Status == PaymentCancelled
+}
+
 // NewRefund creates a new refund with default values
 func NewRefund(paymentID, orderID string, amount decimal.Decimal, currency, reason string) *Refund {
 	return &Refund{
@@ -169,4 +347,10 @@ type PaymentWebhook struct {
 	Processed   bool                   `json:"processed" db:"processed"`
 	ProcessedAt *time.Time             `json:"processed_at" db:"processed_at"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
+	// EventID is the gateway's event ID, used to ignore redeliveries, and
+	// EventCreatedAt when the gateway raised it
+	EventID        string    `json:"event_id" db:"event_id"`
+	EventCreatedAt time.Time `json:"event_created_at" db:"event_created_at"`
+	Attempts       int       `json:"attempts" db:"attempts"`
+	LastError      string    `json:"last_error,omitempty" db:"last_error"`
 }
\ No newline at end of file
@@ -1,9 +1,11 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopsphere/shared/currency"
 	"github.com/shopspring/decimal"
 )
 
@@ -19,20 +21,118 @@ const (
 
 // Product represents a product in the catalog
 type Product struct {
-	ID          string            `json:"id" db:"id"`
-	SKU         string            `json:"sku" db:"sku"`
-	Name        string            `json:"name" db:"name"`
-	Description string            `json:"description" db:"description"`
-	CategoryID  string            `json:"category_id" db:"category_id"`
-	Price       decimal.Decimal   `json:"price" db:"price"`
-	Currency    string            `json:"currency" db:"currency"`
-	Stock       int               `json:"stock" db:"stock"`
-	Status      ProductStatus     `json:"status" db:"status"`
-	Images      []string          `json:"images" db:"images"`
-	Attributes  ProductAttributes `json:"attributes" db:"attributes"`
-	Featured    bool              `json:"featured" db:"featured"`
-	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+	ID            string            `json:"id" db:"id"`
+	SKU           string            `json:"sku" db:"sku"`
+	Name          string            `json:"name" db:"name"`
+	Description   string            `json:"description" db:"description"`
+	CategoryID    string            `json:"category_id" db:"category_id"`
+	Price         decimal.Decimal   `json:"price" db:"price"`
+	Currency      string            `json:"currency" db:"currency"`
+	Prices        PriceList         `json:"prices" db:"prices"`
+	Stock         int               `json:"stock" db:"stock"`
+	ReservedStock int               `json:"reserved_stock" db:"reserved_stock"`
+	Status        ProductStatus     `json:"status" db:"status"`
+	Images        []string          `json:"images" db:"images"`
+	Attributes    ProductAttributes `json:"attributes" db:"attributes"`
+	Featured      bool              `json:"featured" db:"featured"`
+	TaxClass      string            `json:"tax_class" db:"tax_class"`
+	Options       []ProductOption   `json:"options" db:"options"`
+	Variants      []ProductVariant  `json:"variants,omitempty" db:"-"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ProductOption is an axis the variants of a product vary along, such as
+// size or color. Each variant has one of Values under Name in its attributes.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// TaxClassStandard is the tax class of products that do not name one
+const TaxClassStandard = "standard"
+
+// PriceList holds explicit prices in currencies other than the product's
+// own, keyed by ISO 4217 code. Currencies without an entry are converted
+// from the base price at the current exchange rate.
+type PriceList map[string]decimal.Decimal
+
+// AvailableStock returns the units that are in stock and not reserved
+func (p *Product) AvailableStock() int {
+	return p.Stock - p.ReservedStock
+}
+
+// PriceIn returns the product's list price in a currency, if it has one
+func (p *Product) PriceIn(code string) (decimal.Decimal, bool) {
+	code = strings.ToUpper(code)
+	if strings.EqualFold(p.Currency, code) {
+		return p.Price, true
+	}
+	price, ok := p.Prices[code]
+	return price, ok
+}
+
+// ProductVariant represents a purchasable variation of a product (size, color, etc.)
+type ProductVariant struct {
+	ID            string                 `json:"id" db:"id"`
+	ProductID     string                 `json:"product_id" db:"product_id"`
+	SKU           string                 `json:"sku" db:"sku"`
+	Name          string                 `json:"name" db:"name"`
+	Price         decimal.Decimal        `json:"price" db:"price"`
+	Prices        PriceList              `json:"prices" db:"prices"`
+	ComparePrice  *decimal.Decimal       `json:"compare_price,omitempty" db:"compare_price"`
+	CostPrice     *decimal.Decimal       `json:"cost_price,omitempty" db:"cost_price"`
+	Stock         int                    `json:"stock" db:"stock"`
+	ReservedStock int                    `json:"reserved_stock" db:"reserved_stock"`
+	Weight        float64                `json:"weight" db:"weight"`
+	Attributes    map[string]interface{} `json:"attributes" db:"attributes"`
+	ImageURL      string                 `json:"image_url" db:"image_url"`
+	IsDefault     bool                   `json:"is_default" db:"is_default"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// NewProductVariant creates a new variant of a product
+func NewProductVariant(productID, sku, name string, price decimal.Decimal) *ProductVariant {
+	return &ProductVariant{
+		ID:         uuid.New().String(),
+		ProductID:  productID,
+		SKU:        sku,
+		Name:       name,
+		Price:      price,
+		Prices:     PriceList{},
+		Attributes: make(map[string]interface{}),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+}
+
+// AvailableStock returns the units that are in stock and not reserved
+func (v *ProductVariant) AvailableStock() int {
+	return v.Stock - v.ReservedStock
+}
+
+// OptionValue returns the variant's value for a product option
+func (v *ProductVariant) OptionValue(option string) string {
+	for key, value := range v.Attributes {
+		if strings.EqualFold(key, option) {
+			if s, ok := value.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// PriceIn returns the variant's list price in a currency, if it has one.
+// Variants are priced in the currency of their product.
+func (v *ProductVariant) PriceIn(code, productCurrency string) (decimal.Decimal, bool) {
+	code = strings.ToUpper(code)
+	if strings.EqualFold(productCurrency, code) {
+		return v.Price, true
+	}
+	price, ok := v.Prices[code]
+	return price, ok
 }
 
 // ProductAttributes represents additional product attributes
@@ -62,11 +162,12 @@ func NewProduct(sku, name, description, categoryID string, price decimal.Decimal
 		Description: description,
 		CategoryID:  categoryID,
 		Price:       price,
-		Currency:    "USD",
+		Currency:    currency.DefaultCode,
 		Stock:       0,
 		Status:      ProductInactive,
 		Images:      []string{},
 		Attributes:  ProductAttributes{Custom: make(map[string]interface{})},
+		Options:     []ProductOption{},
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
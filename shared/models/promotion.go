@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PromotionType is how a promotion computes its discount
+type PromotionType string
+
+const (
+	PromotionPercentage   PromotionType = "percentage"    // Value percent off eligible items
+	PromotionFixedAmount  PromotionType = "fixed_amount"  // Value off eligible items
+	PromotionFreeShipping PromotionType = "free_shipping" // shipping is free
+	PromotionBuyXGetY     PromotionType = "buy_x_get_y"   // every BuyQuantity units make GetQuantity more units free
+)
+
+// Promotion is a discount rule. Promotions with a Code are coupons the
+// customer must enter; promotions without one apply automatically.
+type Promotion struct {
+	ID           string          `json:"id" db:"id"`
+	Code         string          `json:"code" db:"code"`
+	Name         string          `json:"name" db:"name"`
+	Description  string          `json:"description" db:"description"`
+	Type         PromotionType   `json:"type" db:"type"`
+	Value        decimal.Decimal `json:"value" db:"value"`
+	MinSubtotal  decimal.Decimal `json:"min_subtotal" db:"min_subtotal"`
+	BuyQuantity  int             `json:"buy_quantity" db:"buy_quantity"`
+	GetQuantity  int             `json:"get_quantity" db:"get_quantity"`
+	ProductIDs   []string        `json:"product_ids" db:"product_ids"` // empty applies to every product
+	UsageLimit   int             `json:"usage_limit" db:"usage_limit"`       // 0 is unlimited
+	PerUserLimit int             `json:"per_user_limit" db:"per_user_limit"` // 0 is unlimited
+	UsageCount   int             `json:"usage_count" db:"usage_count"`
+	StartsAt     *time.Time      `json:"starts_at" db:"starts_at"`
+	EndsAt       *time.Time      `json:"ends_at" db:"ends_at"`
+	Active       bool            `json:"active" db:"active"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IsCoupon reports whether the promotion needs a code to apply
+func (p *Promotion) IsCoupon() bool {
+	return p.Code != ""
+}
+
+// InWindow reports whether the promotion's validity window contains at
+func (p *Promotion) InWindow(at time.Time) bool {
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !at.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// AppliesToProduct reports whether items of the product are eligible
+func (p *Promotion) AppliesToProduct(productID string) bool {
+	if len(p.ProductIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountType is the origin of an order discount
+type DiscountType string
+
+const (
+	DiscountCoupon    DiscountType = "coupon"
+	DiscountPromotion DiscountType = "promotion"
+	DiscountLoyalty   DiscountType = "loyalty"
+	DiscountManual    DiscountType = "manual"
+)
+
+// DiscountTarget is what part of an order a discount reduces
+type DiscountTarget string
+
+const (
+	DiscountOnOrder    DiscountTarget = "order"
+	DiscountOnShipping DiscountTarget = "shipping"
+	DiscountOnItem     DiscountTarget = "item"
+)
+
+// OrderDiscount is a discount applied to an order or, before checkout, a cart
+type OrderDiscount struct {
+	ID          string          `json:"id" db:"id"`
+	OrderID     string          `json:"order_id,omitempty" db:"order_id"`
+	PromotionID string          `json:"promotion_id,omitempty" db:"promotion_id"`
+	Type        DiscountType    `json:"discount_type" db:"discount_type"`
+	Code        string          `json:"discount_code,omitempty" db:"discount_code"`
+	Name        string          `json:"discount_name" db:"discount_name"`
+	Amount      decimal.Decimal `json:"discount_amount" db:"discount_amount"`
+	Percentage  decimal.Decimal `json:"discount_percentage" db:"discount_percentage"`
+	AppliedTo   DiscountTarget  `json:"applied_to" db:"applied_to"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// NewPromotion creates a new active promotion
+func NewPromotion(code, name string, promotionType PromotionType, value decimal.Decimal) *Promotion {
+	return &Promotion{
+		ID:        uuid.New().String(),
+		Code:      code,
+		Name:      name,
+		Type:      promotionType,
+		Value:     value,
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
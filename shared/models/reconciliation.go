@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SettlementRecordType is the kind of money movement in a settlement report
+type SettlementRecordType string
+
+const (
+	SettlementCharge SettlementRecordType = "charge"
+	SettlementRefund SettlementRecordType = "refund"
+)
+
+// DiscrepancyType is how a payment or refund differs from what the gateway settled
+type DiscrepancyType string
+
+const (
+	DiscrepancyMissingRecord     DiscrepancyType = "missing_record"     // settled by the gateway, unknown to us
+	DiscrepancyMissingSettlement DiscrepancyType = "missing_settlement" // collected or refunded by us, not settled
+	DiscrepancyAmountMismatch    DiscrepancyType = "amount_mismatch"
+	DiscrepancyStatusMismatch    DiscrepancyType = "status_mismatch"
+)
+
+// DiscrepancyStatus tracks whether an admin has dealt with a discrepancy
+type DiscrepancyStatus string
+
+const (
+	DiscrepancyOpen     DiscrepancyStatus = "open"
+	DiscrepancyResolved DiscrepancyStatus = "resolved"
+)
+
+// ReconciliationRun is one settlement report checked against our payments
+// and refunds. The period spans the settlement times in the report.
+type ReconciliationRun struct {
+	ID               string    `json:"id" db:"id"`
+	Source           string    `json:"source" db:"source"`
+	Checksum         string    `json:"checksum" db:"checksum"`
+	PeriodStart      time.Time `json:"period_start" db:"period_start"`
+	PeriodEnd        time.Time `json:"period_end" db:"period_end"`
+	RecordCount      int       `json:"record_count" db:"record_count"`
+	MatchedCount     int       `json:"matched_count" db:"matched_count"`
+	DiscrepancyCount int       `json:"discrepancy_count" db:"discrepancy_count"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// NewReconciliationRun creates a new reconciliation run
+func NewReconciliationRun(source, checksum string) *ReconciliationRun {
+	return &ReconciliationRun{
+		ID:        uuid.New().String(),
+		Source:    source,
+		Checksum:  checksum,
+		CreatedAt: time.Now(),
+	}
+}
+
+// ReconciliationDiscrepancy is a payment or refund that does not agree with
+// the settlement report. Ledger fields are ours, settled fields the gateway's.
+type ReconciliationDiscrepancy struct {
+	ID               string               `json:"id" db:"id"`
+	RunID            string               `json:"run_id" db:"run_id"`
+	Type             DiscrepancyType      `json:"type" db:"type"`
+	RecordType       SettlementRecordType `json:"record_type" db:"record_type"`
+	TransactionID    string               `json:"transaction_id" db:"transaction_id"`
+	PaymentID        string               `json:"payment_id,omitempty" db:"payment_id"`
+	RefundID         string               `json:"refund_id,omitempty" db:"refund_id"`
+	Currency         string               `json:"currency" db:"currency"`
+	LedgerAmount     decimal.Decimal      `json:"ledger_amount" db:"ledger_amount"`
+	SettledAmount    decimal.Decimal      `json:"settled_amount" db:"settled_amount"`
+	LedgerStatus     string               `json:"ledger_status,omitempty" db:"ledger_status"`
+	SettlementStatus string               `json:"settlement_status,omitempty" db:"settlement_status"`
+	Detail           string               `json:"detail" db:"detail"`
+	Status           DiscrepancyStatus    `json:"status" db:"status"`
+	Resolution       string               `json:"resolution,omitempty" db:"resolution"`
+	ResolvedBy       string               `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolvedAt       *time.Time           `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt        time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// NewReconciliationDiscrepancy creates a new open discrepancy
+func NewReconciliationDiscrepancy(runID string, discrepancyType DiscrepancyType, recordType SettlementRecordType, transactionID string) *ReconciliationDiscrepancy {
+	now := time.Now()
+	return &ReconciliationDiscrepancy{
+		ID:            uuid.New().String(),
+		RunID:         runID,
+		Type:          discrepancyType,
+		RecordType:    recordType,
+		TransactionID: transactionID,
+		LedgerAmount:  decimal.Zero,
+		SettledAmount: decimal.Zero,
+		Status:        DiscrepancyOpen,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
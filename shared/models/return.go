@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ReturnStatus represents the status of a return
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+	ReturnInspected ReturnStatus = "inspected"
+	ReturnRefunded  ReturnStatus = "refunded"
+	ReturnCancelled ReturnStatus = "cancelled"
+)
+
+// Return is a return merchandise authorization (RMA) for some of an order's
+// items. It is approved with a return shipping label, inspected on arrival
+// and refunded for the units accepted back.
+type Return struct {
+	ID              string          `json:"id" db:"id"`
+	RMANumber       string          `json:"rma_number" db:"rma_number"`
+	OrderID         string          `json:"order_id" db:"order_id"`
+	UserID          string          `json:"user_id" db:"user_id"`
+	Status          ReturnStatus    `json:"status" db:"status"`
+	Reason          string          `json:"reason" db:"reason"`
+	Notes           string          `json:"notes" db:"notes"`
+	RejectionReason string          `json:"rejection_reason,omitempty" db:"rejection_reason"`
+	Items           []ReturnItem    `json:"items"`
+	ShipmentID      string          `json:"shipment_id,omitempty" db:"shipment_id"`
+	TrackingNumber  string          `json:"tracking_number,omitempty" db:"tracking_number"`
+	LabelURL        string          `json:"label_url,omitempty" db:"label_url"`
+	RefundAmount    decimal.Decimal `json:"refund_amount" db:"refund_amount"`
+	Currency        string          `json:"currency" db:"currency"`
+	RefundID        string          `json:"refund_id,omitempty" db:"refund_id"`
+	ApprovedAt      *time.Time      `json:"approved_at" db:"approved_at"`
+	ReceivedAt      *time.Time      `json:"received_at" db:"received_at"`
+	InspectedAt     *time.Time      `json:"inspected_at" db:"inspected_at"`
+	RefundedAt      *time.Time      `json:"refunded_at" db:"refunded_at"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// ReturnItem is a quantity of one order item being returned
+type ReturnItem struct {
+	ID          string `json:"id" db:"id"`
+	ReturnID    string `json:"return_id" db:"return_id"`
+	OrderItemID string `json:"order_item_id" db:"order_item_id"`
+	ProductID   string `json:"product_id" db:"product_id"`
+	VariantID   string `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity    int    `json:"quantity" db:"quantity"`
+	Reason      string `json:"reason" db:"reason"`
+	// AcceptedQuantity is the number of units refunded after inspection
+	AcceptedQuantity int `json:"accepted_quantity" db:"accepted_quantity"`
+	// RestockedQuantity is the number of accepted units put back into stock
+	RestockedQuantity int             `json:"restocked_quantity" db:"restocked_quantity"`
+	Condition         string          `json:"condition,omitempty" db:"condition"`
+	RefundAmount      decimal.Decimal `json:"refund_amount" db:"refund_amount"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+}
+
+// IsActive reports whether the return still claims its items
+func (r *Return) IsActive() bool {
+	return r.Status != ReturnRejected && r.Status != ReturnCancelled
+}
+
+// NewReturn creates a new requested return for an order
+func NewReturn(orderID, userID, currency string) *Return {
+	return &Return{
+		ID:           uuid.New().String(),
+		OrderID:      orderID,
+		UserID:       userID,
+		Status:       ReturnRequested,
+		Items:        []ReturnItem{},
+		RefundAmount: decimal.Zero,
+		Currency:     currency,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+}
+
+// ReturnNumber formats the RMA number of an order's nth return, for example
+// RMA-ORD-20260601-0000422-1
+func ReturnNumber(orderNumber string, n int) string {
+	return fmt.Sprintf("RMA-%s-%d", orderNumber, n)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RiskDecision is what the risk engine decided to do with a payment
+type RiskDecision string
+
+const (
+	RiskAllow  RiskDecision = "allow"
+	RiskReview RiskDecision = "review" // held until an admin approves or rejects it
+	RiskBlock  RiskDecision = "block"
+)
+
+// RiskReviewStatus tracks a held payment through manual review
+type RiskReviewStatus string
+
+const (
+	RiskReviewNotRequired RiskReviewStatus = "not_required"
+	RiskReviewPending     RiskReviewStatus = "pending"
+	RiskReviewApproved    RiskReviewStatus = "approved"
+	RiskReviewRejected    RiskReviewStatus = "rejected"
+)
+
+// PaymentRiskContext is what the client knows about a payment beyond the
+// payment itself. Empty fields are not scored.
+type PaymentRiskContext struct {
+	IPAddress       string `json:"ip_address,omitempty"`
+	BillingCountry  string `json:"billing_country,omitempty"`
+	ShippingCountry string `json:"shipping_country,omitempty"`
+	// AccountCreatedAt is when the customer signed up; without it the
+	// customer's first payment stands in for the account age
+	AccountCreatedAt *time.Time `json:"account_created_at,omitempty"`
+}
+
+// RiskRuleHit is a risk rule that triggered on a payment
+type RiskRuleHit struct {
+	Rule   string `json:"rule"`
+	Score  int    `json:"score"`
+	Detail string `json:"detail"`
+}
+
+// RiskAssessment is the risk engine's verdict on one payment attempt along
+// with the rules that led to it
+type RiskAssessment struct {
+	ID           string           `json:"id" db:"id"`
+	PaymentID    string           `json:"payment_id" db:"payment_id"`
+	OrderID      string           `json:"order_id" db:"order_id"`
+	UserID       string           `json:"user_id" db:"user_id"`
+	Amount       decimal.Decimal  `json:"amount" db:"amount"`
+	Currency     string           `json:"currency" db:"currency"`
+	Score        int              `json:"score" db:"score"`
+	Decision     RiskDecision     `json:"decision" db:"decision"`
+	Rules        []RiskRuleHit    `json:"rules" db:"rules"`
+	ReviewStatus RiskReviewStatus `json:"review_status" db:"review_status"`
+	ReviewedBy   string           `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewNote   string           `json:"review_note,omitempty" db:"review_note"`
+	ReviewedAt   *time.Time       `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt    time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// NewRiskAssessment creates an assessment of a payment that allows it until
+// rules are added
+func NewRiskAssessment(payment *Payment) *RiskAssessment {
+	now := time.Now()
+	return &RiskAssessment{
+		ID:           uuid.New().String(),
+		PaymentID:    payment.ID,
+		OrderID:      payment.OrderID,
+		UserID:       payment.UserID,
+		Amount:       payment.Amount,
+		Currency:     payment.Currency,
+		Decision:     RiskAllow,
+		Rules:        []RiskRuleHit{},
+		ReviewStatus: RiskReviewNotRequired,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
@@ -245,6 +245,16 @@ func (s ShipmentStatus) IsValid() bool {
 	}
 }
 
+// HasShipped reports whether the carrier has taken the shipment
+func (s ShipmentStatus) HasShipped() bool {
+	switch s {
+	case ShipmentPickedUp, ShipmentInTransit, ShipmentOutForDelivery, ShipmentDelivered, ShipmentReturned:
+		return true
+	default:
+		return false
+	}
+}
+
 // CanTransitionTo checks if the shipment can transition to the given status
 func (s ShipmentStatus) CanTransitionTo(newStatus ShipmentStatus) bool {
 	transitions := map[ShipmentStatus][]ShipmentStatus{
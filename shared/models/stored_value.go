@@ -0,0 +1,110 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// StoredValueAccountType is the kind of account holding stored value
+type StoredValueAccountType string
+
+const (
+	StoredValueGiftCard StoredValueAccountType = "gift_card"
+	StoredValueWallet   StoredValueAccountType = "wallet"
+)
+
+// GiftCardStatus represents the status of a gift card
+type GiftCardStatus string
+
+const (
+	GiftCardActive   GiftCardStatus = "active"
+	GiftCardDisabled GiftCardStatus = "disabled"
+)
+
+// GiftCard is a prepaid balance redeemable with its code. Only a hash of the
+// code is stored; the code itself is returned once, when the card is issued.
+type GiftCard struct {
+	ID             string          `json:"id" db:"id"`
+	Code           string          `json:"code,omitempty" db:"-"`
+	CodeLast4      string          `json:"code_last4" db:"code_last4"`
+	Currency       string          `json:"currency" db:"currency"`
+	InitialBalance decimal.Decimal `json:"initial_balance" db:"initial_balance"`
+	Balance        decimal.Decimal `json:"balance" db:"balance"`
+	Status         GiftCardStatus  `json:"status" db:"status"`
+	IssuedBy       string          `json:"issued_by" db:"issued_by"`
+	ExpiresAt      *time.Time      `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IsRedeemable reports whether the card can pay at the given time
+func (g *GiftCard) IsRedeemable(now time.Time) bool {
+	return g.Status == GiftCardActive && (g.ExpiresAt == nil || now.Before(*g.ExpiresAt))
+}
+
+// NormalizeGiftCardCode strips the separators and case customers type codes with
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// Wallet is a customer's store credit in one currency
+type Wallet struct {
+	ID        string          `json:"id" db:"id"`
+	UserID    string          `json:"user_id" db:"user_id"`
+	Currency  string          `json:"currency" db:"currency"`
+	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// LedgerEntryType is the reason a stored-value balance changed
+type LedgerEntryType string
+
+const (
+	// LedgerIssue loads a new gift card
+	LedgerIssue LedgerEntryType = "issue"
+	// LedgerRedemption pays for a payment
+	LedgerRedemption LedgerEntryType = "redemption"
+	// LedgerReversal gives back a redemption whose payment was cancelled
+	LedgerReversal LedgerEntryType = "reversal"
+	// LedgerRefund credits a refund, to the card or wallet that paid or as store credit
+	LedgerRefund LedgerEntryType = "refund"
+	// LedgerAdjustment is a manual credit or debit by an admin
+	LedgerAdjustment LedgerEntryType = "adjustment"
+)
+
+// StoredValueLedgerEntry is one change to a gift card or wallet balance.
+// Entries are only ever appended; the account balance always equals the sum
+// of its entries. Amount is positive for credits and negative for debits.
+type StoredValueLedgerEntry struct {
+	ID           string                 `json:"id" db:"id"`
+	AccountType  StoredValueAccountType `json:"account_type" db:"account_type"`
+	AccountID    string                 `json:"account_id" db:"account_id"`
+	Type         LedgerEntryType        `json:"type" db:"type"`
+	Amount       decimal.Decimal        `json:"amount" db:"amount"`
+	BalanceAfter decimal.Decimal        `json:"balance_after" db:"balance_after"`
+	Currency     string                 `json:"currency" db:"currency"`
+	PaymentID    string                 `json:"payment_id,omitempty" db:"payment_id"`
+	RefundID     string                 `json:"refund_id,omitempty" db:"refund_id"`
+	Note         string                 `json:"note,omitempty" db:"note"`
+	CreatedBy    string                 `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+}
+
+// NewLedgerEntry creates a ledger entry for an account; the balance after it
+// is filled in when it is applied
+func NewLedgerEntry(accountType StoredValueAccountType, accountID string, entryType LedgerEntryType, amount decimal.Decimal, currency string) *StoredValueLedgerEntry {
+	return &StoredValueLedgerEntry{
+		ID:          uuid.New().String(),
+		AccountType: accountType,
+		AccountID:   accountID,
+		Type:        entryType,
+		Amount:      amount,
+		Currency:    currency,
+		CreatedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionInterval is the unit of a subscription plan's billing period
+type SubscriptionInterval string
+
+const (
+	SubscriptionIntervalDay   SubscriptionInterval = "day"
+	SubscriptionIntervalWeek  SubscriptionInterval = "week"
+	SubscriptionIntervalMonth SubscriptionInterval = "month"
+	SubscriptionIntervalYear  SubscriptionInterval = "year"
+)
+
+// IsValid reports whether the interval is a known unit
+func (i SubscriptionInterval) IsValid() bool {
+	switch i {
+	case SubscriptionIntervalDay, SubscriptionIntervalWeek, SubscriptionIntervalMonth, SubscriptionIntervalYear:
+		return true
+	}
+	return false
+}
+
+// SubscriptionPlan sells a product on a recurring basis: every IntervalCount
+// Intervals the subscriber is sent the product and charged Price for it
+type SubscriptionPlan struct {
+	ID            string               `json:"id" db:"id"`
+	ProductID     string               `json:"product_id" db:"product_id"`
+	VariantID     string               `json:"variant_id,omitempty" db:"variant_id"`
+	Name          string               `json:"name" db:"name"`
+	Description   string               `json:"description" db:"description"`
+	Interval      SubscriptionInterval `json:"interval" db:"interval"`
+	IntervalCount int                  `json:"interval_count" db:"interval_count"`
+	TrialDays     int                  `json:"trial_days" db:"trial_days"`
+	Price         decimal.Decimal      `json:"price" db:"price"` // per unit, per period
+	Currency      string               `json:"currency" db:"currency"`
+	Active        bool                 `json:"active" db:"active"`
+	CreatedAt     time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// PeriodEnd returns the end of a billing period starting at start. Monthly
+// and yearly periods that start on a day the target month lacks end on its
+// last day rather than spilling into the next month.
+func (p *SubscriptionPlan) PeriodEnd(start time.Time) time.Time {
+	count := p.IntervalCount
+	if count < 1 {
+		count = 1
+	}
+
+	switch p.Interval {
+	case SubscriptionIntervalDay:
+		return start.AddDate(0, 0, count)
+	case SubscriptionIntervalWeek:
+		return start.AddDate(0, 0, 7*count)
+	case SubscriptionIntervalYear:
+		return addMonths(start, 12*count)
+	default:
+		return addMonths(start, count)
+	}
+}
+
+func addMonths(t time.Time, months int) time.Time {
+	end := t.AddDate(0, months, 0)
+	if end.Day() != t.Day() {
+		// Went past the end of the month: step back to its last day
+		end = end.AddDate(0, 0, -end.Day())
+	}
+	return end
+}
+
+// SubscriptionStatus represents the status of a subscription
+type SubscriptionStatus string
+
+const (
+	// SubscriptionTrialing has not been charged yet; the first renewal ends the trial
+	SubscriptionTrialing SubscriptionStatus = "trialing"
+	SubscriptionActive   SubscriptionStatus = "active"
+	// SubscriptionPastDue has a failed renewal that is being retried
+	SubscriptionPastDue   SubscriptionStatus = "past_due"
+	SubscriptionPaused    SubscriptionStatus = "paused"
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription is a customer's standing order for a plan. It is renewed at
+// the end of each period: an order is created and charged to the saved
+// payment method, and the next period begins.
+type Subscription struct {
+	ID                 string             `json:"id" db:"id"`
+	UserID             string             `json:"user_id" db:"user_id"`
+	PlanID             string             `json:"plan_id" db:"plan_id"`
+	Quantity           int                `json:"quantity" db:"quantity"`
+	Status             SubscriptionStatus `json:"status" db:"status"`
+	Email              string             `json:"email" db:"email"` // where billing notices are sent
+	PaymentMethodID    string             `json:"payment_method_id" db:"payment_method_id"`
+	ShippingAddress    Address            `json:"shipping_address" db:"shipping_address"`
+	BillingAddress     Address            `json:"billing_address" db:"billing_address"`
+	ShippingMethodID   string             `json:"shipping_method_id" db:"shipping_method_id"`
+	TrialEndsAt        *time.Time         `json:"trial_ends_at,omitempty" db:"trial_ends_at"`
+	CurrentPeriodStart time.Time          `json:"current_period_start" db:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `json:"current_period_end" db:"current_period_end"`
+	// NextBillingAt is when the next renewal is attempted: the end of the
+	// current period, or the next dunning retry while past due
+	NextBillingAt      time.Time  `json:"next_billing_at" db:"next_billing_at"`
+	FailedAttempts     int        `json:"failed_attempts" db:"failed_attempts"`
+	CancelAtPeriodEnd  bool       `json:"cancel_at_period_end" db:"cancel_at_period_end"`
+	PausedAt           *time.Time `json:"paused_at,omitempty" db:"paused_at"`
+	ResumeAt           *time.Time `json:"resume_at,omitempty" db:"resume_at"` // automatic resume, if set
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CancellationReason string     `json:"cancellation_reason,omitempty" db:"cancellation_reason"`
+	LastOrderID        string     `json:"last_order_id,omitempty" db:"last_order_id"`
+	// Version is bumped by every change, so a change made from a stale copy
+	// of the subscription is refused
+	Version   int       `json:"version" db:"version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IsBillable reports whether the subscription is renewed when it comes due
+func (s *Subscription) IsBillable() bool {
+	switch s.Status {
+	case SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue:
+		return true
+	}
+	return false
+}
+
+// SubscriptionRenewalStatus is the outcome of one renewal attempt
+type SubscriptionRenewalStatus string
+
+const (
+	// RenewalPending is a renewal being charged. It is recorded before the
+	// order is placed so that a renewal interrupted by a crash is resumed
+	// rather than charged again.
+	RenewalPending SubscriptionRenewalStatus = "pending"
+	RenewalPaid    SubscriptionRenewalStatus = "paid"
+	RenewalFailed  SubscriptionRenewalStatus = "failed"
+	RenewalSkipped SubscriptionRenewalStatus = "skipped"
+)
+
+// SubscriptionRenewal records one attempt to bill a subscription period, or
+// the customer skipping it. A period that needed dunning retries has one
+// failed renewal per failed attempt. A subscription has at most one pending
+// renewal at a time.
+type SubscriptionRenewal struct {
+	ID             string                    `json:"id" db:"id"`
+	SubscriptionID string                    `json:"subscription_id" db:"subscription_id"`
+	PeriodStart    time.Time                 `json:"period_start" db:"period_start"`
+	PeriodEnd      time.Time                 `json:"period_end" db:"period_end"`
+	Status         SubscriptionRenewalStatus `json:"status" db:"status"`
+	Attempt        int                       `json:"attempt" db:"attempt"`
+	OrderID        string                    `json:"order_id,omitempty" db:"order_id"`
+	PaymentID      string                    `json:"payment_id,omitempty" db:"payment_id"`
+	Amount         decimal.Decimal           `json:"amount" db:"amount"`
+	Currency       string                    `json:"currency" db:"currency"`
+	FailureReason  string                    `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt      time.Time                 `json:"created_at" db:"created_at"`
+}
+
+// NewSubscriptionRenewal creates a renewal record for a subscription period
+func NewSubscriptionRenewal(subscriptionID string, periodStart, periodEnd time.Time, status SubscriptionRenewalStatus, at time.Time) *SubscriptionRenewal {
+	return &SubscriptionRenewal{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscriptionID,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+		Status:         status,
+		Amount:         decimal.Zero,
+		CreatedAt:      at,
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TaxClassShipping is the tax class shipping charges are taxed under
+const TaxClassShipping = "shipping"
+
+// TaxRule is a tax rate for a jurisdiction and tax class. Empty Country,
+// State, PostalCodePrefix or TaxClass match anything.
+type TaxRule struct {
+	ID               string          `json:"id" db:"id"`
+	Name             string          `json:"name" db:"name"`
+	Country          string          `json:"country" db:"country"`
+	State            string          `json:"state" db:"state"`
+	PostalCodePrefix string          `json:"postal_code_prefix" db:"postal_code_prefix"`
+	TaxClass         string          `json:"tax_class" db:"tax_class"`
+	Rate             decimal.Decimal `json:"rate" db:"rate"`           // 0.0725 for 7.25%
+	Inclusive        bool            `json:"inclusive" db:"inclusive"` // prices already include this tax
+	ApplyToShipping  bool            `json:"apply_to_shipping" db:"apply_to_shipping"`
+	Active           bool            `json:"active" db:"active"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// TaxLine is the tax one rule charged on an order line
+type TaxLine struct {
+	RuleID    string          `json:"rule_id"`
+	Name      string          `json:"name"`
+	Rate      decimal.Decimal `json:"rate"`
+	Amount    decimal.Decimal `json:"amount"`
+	Inclusive bool            `json:"inclusive"`
+}
+
+// NewTaxRule creates a new active tax rule
+func NewTaxRule(name string, rate decimal.Decimal) *TaxRule {
+	return &TaxRule{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Rate:      rate,
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
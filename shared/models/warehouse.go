@@ -0,0 +1,152 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Warehouse is a location stock is kept and shipped from
+type Warehouse struct {
+	ID        string    `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	Address   Address   `json:"address"`
+	Priority  int       `json:"priority" db:"priority"` // lower numbers are preferred
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NewWarehouse creates a new active warehouse
+func NewWarehouse(code, name string, address Address, priority int) *Warehouse {
+	return &Warehouse{
+		ID:        uuid.New().String(),
+		Code:      code,
+		Name:      name,
+		Address:   address,
+		Priority:  priority,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// WarehouseStock is the stock of a product, or of one of its variants, kept
+// at one warehouse. A product's stock is the total over its warehouses plus
+// any stock that has not been assigned to one.
+type WarehouseStock struct {
+	WarehouseID    string    `json:"warehouse_id" db:"warehouse_id"`
+	ProductID      string    `json:"product_id" db:"product_id"`
+	VariantID      string    `json:"variant_id,omitempty" db:"variant_id"`
+	Stock          int       `json:"stock" db:"stock"`
+	AllocatedStock int       `json:"allocated_stock" db:"allocated_stock"` // held by active reservations allocated here
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Available returns the units that can still be allocated to new orders
+func (s *WarehouseStock) Available() int {
+	if s.Stock < s.AllocatedStock {
+		return 0
+	}
+	return s.Stock - s.AllocatedStock
+}
+
+// StockTransfer moves units of a product or variant between warehouses. An
+// empty FromWarehouseID assigns stock that is not kept at any warehouse yet.
+type StockTransfer struct {
+	ID              string    `json:"id" db:"id"`
+	FromWarehouseID string    `json:"from_warehouse_id,omitempty" db:"from_warehouse_id"`
+	ToWarehouseID   string    `json:"to_warehouse_id" db:"to_warehouse_id"`
+	ProductID       string    `json:"product_id" db:"product_id"`
+	VariantID       string    `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity        int       `json:"quantity" db:"quantity"`
+	Reason          string    `json:"reason" db:"reason"`
+	CreatedBy       string    `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// AllocationStrategy decides which warehouses fulfill an order
+type AllocationStrategy string
+
+const (
+	// AllocationNearest ships each item from the warehouses nearest the
+	// shipping address
+	AllocationNearest AllocationStrategy = "nearest"
+	// AllocationFewestSplits ships from as few warehouses as possible
+	AllocationFewestSplits AllocationStrategy = "fewest_splits"
+	// AllocationPriority ships each item from the warehouses in priority order
+	AllocationPriority AllocationStrategy = "priority"
+)
+
+// IsValid reports whether the strategy is known
+func (s AllocationStrategy) IsValid() bool {
+	switch s {
+	case AllocationNearest, AllocationFewestSplits, AllocationPriority:
+		return true
+	}
+	return false
+}
+
+// StockAllocation assigns units of a stock reservation to the warehouse they
+// will ship from. Committing the reservation takes them out of that
+// warehouse's stock.
+type StockAllocation struct {
+	ID                string    `json:"id" db:"id"`
+	ReservationID     string    `json:"reservation_id" db:"reservation_id"`
+	WarehouseID       string    `json:"warehouse_id" db:"warehouse_id"`
+	ProductID         string    `json:"product_id" db:"product_id"`
+	VariantID         string    `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity          int       `json:"quantity" db:"quantity"`
+	CommittedQuantity int       `json:"committed_quantity" db:"committed_quantity"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AllocationLine is a quantity of a product or variant
+type AllocationLine struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// AllocationShipment is the part of an allocation shipped from one warehouse
+type AllocationShipment struct {
+	Warehouse *Warehouse       `json:"warehouse"`
+	Lines     []AllocationLine `json:"lines"`
+}
+
+// Units returns the number of units shipped
+func (s *AllocationShipment) Units() int {
+	units := 0
+	for _, line := range s.Lines {
+		units += line.Quantity
+	}
+	return units
+}
+
+// AllocationPlan is where the stock reserved for an order or cart ships
+// from. Unallocated holds the units no warehouse has in stock; they ship from
+// stock that is not kept at any warehouse.
+type AllocationPlan struct {
+	ReferenceType string               `json:"reference_type"`
+	ReferenceID   string               `json:"reference_id"`
+	Strategy      AllocationStrategy   `json:"strategy"`
+	Shipments     []AllocationShipment `json:"shipments"`
+	Unallocated   []AllocationLine     `json:"unallocated"`
+}
+
+// Origin returns the warehouse most units ship from, or nil when nothing was
+// allocated. An order has one ship-from address, so a split allocation is
+// shipped and quoted from its largest part.
+func (p *AllocationPlan) Origin() *Warehouse {
+	var origin *Warehouse
+	most := 0
+	for i := range p.Shipments {
+		if units := p.Shipments[i].Units(); units > most {
+			origin = p.Shipments[i].Warehouse
+			most = units
+		}
+	}
+	return origin
+}
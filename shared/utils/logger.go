@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -37,6 +38,8 @@ const (
 	ServiceNameKey ContextKey = "service_name"
 	// RequestIDKey is the context key for request ID
 	RequestIDKey ContextKey = "request_id"
+	// CallerServiceKey is the context key for the service that made the request
+	CallerServiceKey ContextKey = "caller_service"
 )
 
 // LogEntry represents a structured log entry
@@ -201,6 +204,11 @@ func WithServiceName(ctx context.Context, serviceName string) context.Context {
 	return context.WithValue(ctx, ServiceNameKey, serviceName)
 }
 
+// WithCallerService adds the name of the calling service to the context
+func WithCallerService(ctx context.Context, serviceName string) context.Context {
+	return context.WithValue(ctx, CallerServiceKey, serviceName)
+}
+
 // WithRequestID adds a request ID to the context
 func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, RequestIDKey, requestID)
@@ -230,6 +238,14 @@ func GetServiceName(ctx context.Context) string {
 	return ""
 }
 
+// GetCallerService retrieves the name of the calling service from context
+func GetCallerService(ctx context.Context) string {
+	if serviceName := ctx.Value(CallerServiceKey); serviceName != nil {
+		return serviceName.(string)
+	}
+	return ""
+}
+
 // GetRequestID retrieves the request ID from context
 func GetRequestID(ctx context.Context) string {
 	if requestID := ctx.Value(RequestIDKey); requestID != nil {
@@ -263,7 +279,7 @@ func LogMiddleware(serviceName string) func(next http.Handler) http.Handler {
 				"path":       r.URL.Path,
 				"query":      r.URL.RawQuery,
 				"user_agent": r.UserAgent(),
-				"remote_ip":  getClientIP(r),
+				"remote_ip":  ClientIP(r),
 			})
 			
 			// Process request
@@ -292,19 +308,85 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
+// defaultTrustedProxies are the loopback and private networks the gateway
+// and load balancers run on
+const defaultTrustedProxies = "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"
+
+// trustedProxies are the networks whose forwarding headers ClientIP honours,
+// set with TRUSTED_PROXIES as comma-separated CIDRs or addresses
+var trustedProxies = parseTrustedProxies(getEnvOrDefault("TRUSTED_PROXIES", defaultTrustedProxies))
+
+// parseTrustedProxies parses CIDRs and single addresses, skipping invalid entries
+func parseTrustedProxies(list string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// isTrustedProxy reports whether an address belongs to a trusted proxy
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP extracts the client IP from the request. Forwarding headers are
+// only honoured when the request came through a trusted proxy, and
+// X-Forwarded-For is read from the nearest hop back: the first address not
+// added by a trusted proxy is the client, and anything left of it was
+// supplied by the client itself.
+func ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
 	}
-	
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			client = hop
+			if !isTrustedProxy(hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
 		return xri
 	}
-	
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
\ No newline at end of file
+	return remote
+}
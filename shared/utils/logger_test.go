@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "direct request",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forwarding headers from an untrusted peer are ignored",
+			remoteAddr: "203.0.113.7:51234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "client behind a trusted proxy",
+			remoteAddr: "10.0.0.5:443",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed hops left of the client are ignored",
+			remoteAddr: "10.0.0.5:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.9"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "every hop trusted",
+			remoteAddr: "127.0.0.1:443",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.20, 10.0.0.9"},
+			want:       "192.168.1.20",
+		},
+		{
+			name:       "real IP from a trusted proxy",
+			remoteAddr: "10.0.0.5:443",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks := parseTrustedProxies("203.0.113.7, 2001:db8::/32, not-an-ip")
+	if len(networks) != 2 {
+		t.Fatalf("Expected 2 networks, got %v", networks)
+	}
+	if !networks[0].Contains([]byte{203, 0, 113, 7}) || networks[0].Contains([]byte{203, 0, 113, 8}) {
+		t.Errorf("Expected a single address to trust only itself, got %v", networks[0])
+	}
+}